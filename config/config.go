@@ -53,6 +53,22 @@ type Config struct {
 	AllowedMimeTypes   []string `mapstructure:"ALLOWED_MIME_TYPES"`
 	FileUploadProvider string   `mapstructure:"FILE_UPLOAD_PROVIDER"`
 
+	// ClamAV virus scanning of uploads. The address is host:port or unix:/path/to/clamd.sock,
+	// uploads are not scanned when it is empty.
+	ClamAVAddress string        `mapstructure:"CLAMAV_ADDRESS"`
+	ClamAVTimeout time.Duration `mapstructure:"CLAMAV_TIMEOUT"`
+	// VirusScanFailOpen accepts uploads when the scanner can't be reached instead of rejecting them
+	VirusScanFailOpen bool `mapstructure:"VIRUS_SCAN_FAIL_OPEN"`
+	// QuarantineBucket keeps infected uploads, FILE_BUCKET when empty
+	QuarantineBucket string `mapstructure:"QUARANTINE_BUCKET"`
+
+	// FormEncryptionKeys encrypts form fields persisted with is_encrypted, as comma separated
+	// id:base64 pairs of 32 byte keys. The last key encrypts new values.
+	FormEncryptionKeys string `mapstructure:"FORM_ENCRYPTION_KEYS"`
+	// FormChallengeKey signs the challenges anonymous visitors solve to submit public forms.
+	// Public forms are disabled when it is empty.
+	FormChallengeKey string `mapstructure:"FORM_CHALLENGE_KEY"`
+
 	ExchangeRatePrecision int32 `mapstructure:"EXCHANGE_RATE_PRECISION"`
 
 	// Polaris credentials
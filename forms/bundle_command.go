@@ -0,0 +1,216 @@
+package forms
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/forms/service"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// RunBundleCommand exports and imports form bundles from the command line, so environments
+// can be seeded from bundles kept in the repository. It is called from the application's main
+// with the arguments that follow the command name:
+//
+//	export [-format json|yaml] [-o file] <form id or slug>
+//	import -as <user id> [-dry-run] [-on-conflict fail|skip|new_version] [-map old=new]... <file or directory>...
+//
+// Importing a directory imports its .json, .yaml and .yml files in name order.
+func RunBundleCommand(ctx context.Context, formService *service.FormService, store db.Store, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: export|import [flags] <args>")
+	}
+
+	switch args[0] {
+	case "export":
+		return runBundleExport(ctx, formService, store, args[1:], stdout)
+	case "import":
+		return runBundleImport(ctx, formService, args[1:], stdout)
+	default:
+		return fmt.Errorf("unknown command %q, expected export or import", args[0])
+	}
+}
+
+func runBundleExport(ctx context.Context, formService *service.FormService, store db.Store, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	encoding := flags.String("format", "", "json or yaml, by default taken from the -o extension")
+	output := flags.String("o", "", "file to write, stdout when empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: export [-format json|yaml] [-o file] <form id or slug>")
+	}
+
+	formID, err := uuid.Parse(flags.Arg(0))
+	if err != nil {
+		form, err := store.GetFormDefinitionBySlug(ctx, flags.Arg(0))
+		if err != nil {
+			return fmt.Errorf("form %s: %w", flags.Arg(0), err)
+		}
+		formID = form.ID
+	}
+
+	bundle, err := formService.ExportFormBundle(ctx, formID)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		if *encoding == "" {
+			*encoding = service.BundleEncodingJSON
+		}
+		return service.EncodeFormBundle(stdout, bundle, *encoding)
+	}
+
+	if *encoding == "" {
+		*encoding = service.FormBundleEncoding(*output)
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := service.EncodeFormBundle(file, bundle, *encoding); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func runBundleImport(ctx context.Context, formService *service.FormService, args []string, stdout io.Writer) error {
+	idMap := bundleIDMap{}
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	as := flags.String("as", "", "ID of the user the forms are created by")
+	dryRun := flags.Bool("dry-run", false, "validate the bundles without importing them")
+	onConflict := flags.String("on-conflict", db.FormImportConflictFail, "when the slug exists: fail, skip or new_version")
+	changeNote := flags.String("note", "", "change note of new versions")
+	flags.Var(idMap, "map", "old=new ID to replace, may be repeated")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	importedBy, err := uuid.Parse(*as)
+	if err != nil {
+		return fmt.Errorf("-as must be a user ID: %w", err)
+	}
+
+	paths, err := bundlePaths(flags.Args())
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return errors.New("no bundles to import")
+	}
+
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		bundle, err := service.DecodeFormBundle(file, service.FormBundleEncoding(path))
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		result, err := formService.ImportFormBundle(ctx, service.ImportFormBundleInput{
+			Bundle:     bundle,
+			OnConflict: *onConflict,
+			DryRun:     *dryRun,
+			IDMap:      idMap,
+			ImportedBy: importedBy,
+			ChangeNote: *changeNote,
+		})
+		if err != nil {
+			var validationErr *validator.ValidationError
+			if errors.As(err, &validationErr) {
+				for _, key := range sortedKeys(validationErr.Fields) {
+					fmt.Fprintf(stdout, "%s: %s: %s\n", path, key, validationErr.Fields[key])
+				}
+			}
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		if result.DryRun {
+			fmt.Fprintf(stdout, "%s: %s would be %s\n", path, result.Slug, result.Action)
+		} else {
+			fmt.Fprintf(stdout, "%s: %s %s (%d option sources, %d events, %d assignments added)\n",
+				path, result.Slug, result.Action, result.OptionSourcesCreated, result.EventsCreated, result.AssignmentsCreated)
+		}
+		for _, warning := range result.Warnings {
+			fmt.Fprintf(stdout, "%s: warning: %s\n", path, warning)
+		}
+	}
+
+	return nil
+}
+
+// bundlePaths expands directories to the bundle files they contain
+func bundlePaths(args []string) ([]string, error) {
+	var paths []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+
+		entries, err := os.ReadDir(arg)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			switch strings.ToLower(filepath.Ext(entry.Name())) {
+			case ".json", ".yaml", ".yml":
+				if !entry.IsDir() {
+					paths = append(paths, filepath.Join(arg, entry.Name()))
+				}
+			}
+		}
+	}
+	return paths, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// bundleIDMap collects repeated -map old=new flags
+type bundleIDMap map[string]string
+
+func (m bundleIDMap) String() string {
+	pairs := make([]string, 0, len(m))
+	for from, to := range m {
+		pairs = append(pairs, from+"="+to)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (m bundleIDMap) Set(value string) error {
+	from, to, ok := strings.Cut(value, "=")
+	if !ok || from == "" || to == "" {
+		return fmt.Errorf("invalid id mapping %q, expected old=new", value)
+	}
+	m[from] = to
+	return nil
+}
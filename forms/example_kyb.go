@@ -248,60 +248,71 @@ func CreateKYBForm(ctx context.Context, store db.Store, creatorID uuid.UUID) err
 				},
 			},
 
-			// Step 3: Ownership Structure (Dynamic section)
+			// Step 3: Ownership Structure, one item per owner
 			{
-				FieldName:    "number_of_owners",
-				FieldType:    "number",
+				FieldName:    "owners",
+				FieldType:    "group",
 				StepNumber:   3,
-				Label:        map[string]string{"en": "Number of Owners/Shareholders"},
+				Label:        map[string]string{"en": "Owners/Shareholders"},
 				DisplayOrder: 14,
 				IsRequired:   true,
 				ValidationRules: map[string]interface{}{
-					"required": true,
-					"min":      1,
-					"max":      20,
-				},
-			},
-			// Note: In real implementation, you'd dynamically generate owner fields based on number_of_owners
-			{
-				FieldName:    "owner_1_name",
-				FieldType:    "text",
-				StepNumber:   3,
-				Label:        map[string]string{"en": "Owner 1 - Full Name"},
-				DisplayOrder: 15,
-				IsRequired:   true,
-			},
-			{
-				FieldName:    "owner_1_ownership_percentage",
-				FieldType:    "number",
-				StepNumber:   3,
-				Label:        map[string]string{"en": "Owner 1 - Ownership Percentage"},
-				DisplayOrder: 16,
-				IsRequired:   true,
-				ValidationRules: map[string]interface{}{
-					"required": true,
-					"min":      0,
-					"max":      100,
+					"min_items": 1,
+					"max_items": 20,
 				},
-			},
-			{
-				FieldName:    "owner_1_is_ubo",
-				FieldType:    "checkbox",
-				StepNumber:   3,
-				Label:        map[string]string{"en": "Owner 1 - Ultimate Beneficial Owner (25%+ ownership)"},
-				DisplayOrder: 17,
-				IsRequired:   false,
-			},
-			{
-				FieldName:    "owner_1_id_document",
-				FieldType:    "file",
-				StepNumber:   3,
-				Label:        map[string]string{"en": "Owner 1 - ID Document"},
-				DisplayOrder: 18,
-				IsRequired:   true,
-				FileConfig: map[string]interface{}{
-					"max_size":      10485760,
-					"allowed_types": []string{"application/pdf", "image/jpeg", "image/png"},
+				Options: map[string]interface{}{
+					"type": "group",
+					"fields": []db.FieldInput{
+						{
+							FieldName:    "first_name",
+							FieldType:    "text",
+							Label:        map[string]string{"en": "First Name"},
+							DisplayOrder: 1,
+							IsRequired:   true,
+						},
+						{
+							FieldName:    "last_name",
+							FieldType:    "text",
+							Label:        map[string]string{"en": "Last Name"},
+							DisplayOrder: 2,
+							IsRequired:   true,
+						},
+						{
+							FieldName:    "id_number",
+							FieldType:    "text",
+							Label:        map[string]string{"en": "ID Number"},
+							DisplayOrder: 3,
+							IsRequired:   true,
+						},
+						{
+							FieldName:    "holding_ratio",
+							FieldType:    "number",
+							Label:        map[string]string{"en": "Ownership Percentage"},
+							DisplayOrder: 4,
+							IsRequired:   true,
+							ValidationRules: map[string]interface{}{
+								"min": 0,
+								"max": 100,
+							},
+						},
+						{
+							FieldName:    "is_ubo",
+							FieldType:    "checkbox",
+							Label:        map[string]string{"en": "Ultimate Beneficial Owner (25%+ ownership)"},
+							DisplayOrder: 5,
+						},
+						{
+							FieldName:    "id_document",
+							FieldType:    "file",
+							Label:        map[string]string{"en": "ID Document"},
+							DisplayOrder: 6,
+							IsRequired:   true,
+							FileConfig: map[string]interface{}{
+								"max_size":      10485760,
+								"allowed_types": []string{"application/pdf", "image/jpeg", "image/png"},
+							},
+						},
+					},
 				},
 			},
 
@@ -464,10 +475,9 @@ func CreateKYBForm(ctx context.Context, store db.Store, creatorID uuid.UUID) err
 		PersistenceConfig: &db.PersistenceConfigInput{
 			PersistenceMode: "multi_table",
 			TargetConfigs: []map[string]interface{}{
-				{"table_name": "businesses", "priority": 1},
-				{"table_name": "business_owners", "priority": 2},
-				{"table_name": "documents", "priority": 3},
-				{"table_name": "form_submissions", "priority": 4}, // Also keep full submission
+				// Resubmitting updates the user's business and its owners rather than adding rows
+				{"table_name": "businesses", "priority": 1, "upsert_key": []string{"created_by"}},
+				{"table_name": "business_owners", "priority": 2, "upsert_key": []string{"business_id", "id_number"}},
 			},
 			FieldMappings: map[string]interface{}{
 				"legal_business_name": map[string]interface{}{
@@ -481,6 +491,7 @@ func CreateKYBForm(ctx context.Context, store db.Store, creatorID uuid.UUID) err
 					"table_name":  "businesses",
 					"column_name": "registration_number",
 					"data_type":   "varchar",
+					"transform":   "trim,uppercase",
 				},
 				"business_type": map[string]interface{}{
 					"form_field":  "business_type",
@@ -499,9 +510,22 @@ func CreateKYBForm(ctx context.Context, store db.Store, creatorID uuid.UUID) err
 					"table_name":  "businesses",
 					"column_name": "registration_date",
 					"data_type":   "varchar",
-					"transform":   "date_to_string",
+					"transform":   "date",
+				},
+				// Each owner becomes a business_owners row linked to the business
+				"owners": map[string]interface{}{
+					"form_field":    "owners",
+					"table_name":    "business_owners",
+					"parent_table":  "businesses",
+					"parent_column": "business_id",
+					"item_mappings": map[string]string{
+						"first_name":    "first_name",
+						"last_name":     "last_name",
+						"id_number":     "id_number",
+						"holding_ratio": "holding_ratio",
+						"is_ubo":        "is_ubo",
+					},
 				},
-				// Owner mappings would be dynamic based on number of owners
 			},
 			ValidationHooks: []map[string]interface{}{
 				{
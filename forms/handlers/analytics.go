@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/timchuks/monieverse/internal/forms/service"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+const analyticsDateLayout = "2006-01-02"
+
+// defaultAnalyticsDays is the period analytics cover when no dates are given
+const defaultAnalyticsDays = 30
+
+// GetFormAnalytics reports step conversion, abandonment, field errors and approval turnaround
+// of the submissions of a form started in a date range
+func (h *FormHandler) GetFormAnalytics(ctx *gin.Context) {
+	formID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	var query FormAnalyticsQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	to, from := today, today.AddDate(0, 0, -defaultAnalyticsDays)
+
+	v := validator.New()
+	if query.To != "" {
+		parsed, err := time.Parse(analyticsDateLayout, query.To)
+		v.Check(err == nil, "to", "must be a date in the format YYYY-MM-DD")
+		to = parsed
+	}
+	if query.From != "" {
+		parsed, err := time.Parse(analyticsDateLayout, query.From)
+		v.Check(err == nil, "from", "must be a date in the format YYYY-MM-DD")
+		from = parsed
+	} else if query.To != "" {
+		from = to.AddDate(0, 0, -defaultAnalyticsDays)
+	}
+	if v.Valid() {
+		v.Check(!to.Before(from), "to", "must not be before from")
+	}
+	v.Check(query.Version >= 0, "version", "must not be negative")
+	v.Check(query.AbandonedAfterDays >= 0, "abandoned_after_days", "must not be negative")
+	if !v.Valid() {
+		h.srv.SendValidationError(ctx, validator.NewValidationError("validation failed", v.Errors))
+		return
+	}
+
+	analytics, err := h.formService.GetFormAnalytics(ctx, service.FormAnalyticsInput{
+		FormID:         formID,
+		Version:        query.Version,
+		From:           from,
+		To:             to.AddDate(0, 0, 1),
+		AbandonedAfter: time.Duration(query.AbandonedAfterDays) * 24 * time.Hour,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.srv.ErrorJSONResponse(ctx, http.StatusNotFound, fmt.Errorf("form not found"))
+			return
+		}
+		h.srv.Logger.Error(err, map[string]interface{}{
+			"form_id": formID,
+			"query":   query,
+		})
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, errors.New("unable to compute form analytics"))
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Form analytics retrieved successfully", analytics)
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/forms/service"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// validateApprovalWorkflow checks the stages of an approval workflow and that forms whose type
+// requires several approvers (KYB) cannot be configured with fewer.
+func validateApprovalWorkflow(v *validator.Validator, formType string, requiresApproval bool, workflow *ApprovalWorkflowInput) {
+	if workflow == nil {
+		return
+	}
+
+	var approvers int32
+	names := make(map[string]bool, len(workflow.Stages))
+	for i, stage := range workflow.Stages {
+		key := fmt.Sprintf("approval_workflow.stages[%d]", i)
+		v.Check(stage.Name != "", key, "must have a name")
+		v.Check(!names[stage.Name], key, fmt.Sprintf("duplicate stage %q", stage.Name))
+		v.Check(stage.Permission != "", key, "must have a permission")
+		v.Check(stage.Order >= 0, key, "order must not be negative")
+		v.Check(stage.RequiredApprovals >= 0, key, "required_approvals must not be negative")
+		v.Check(stage.SLAHours >= 0, key, "sla_hours must not be negative")
+		names[stage.Name] = true
+
+		if stage.RequiredApprovals > 0 {
+			approvers += stage.RequiredApprovals
+		} else {
+			approvers++
+		}
+	}
+
+	if minimum := service.MinimumApprovers[formType]; requiresApproval && len(workflow.Stages) > 0 && minimum > 0 {
+		v.Check(approvers >= minimum, "approval_workflow.stages", fmt.Sprintf("%s forms require at least %d approvers", formType, minimum))
+	}
+}
+
+// RequestChanges sends a submission back to the user with comments on individual fields
+func (h *FormHandler) RequestChanges(ctx *gin.Context) {
+	reviewer := h.srv.ContextGetUser(ctx)
+
+	submissionID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	var req RequestChangesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.Comment == "" && len(req.FieldComments) == 0 {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, fmt.Errorf("comment or field_comments is required"))
+		return
+	}
+
+	if err := h.formService.RequestChanges(ctx, submissionID, reviewer.ID, req.Comment, req.FieldComments); err != nil {
+		h.approvalError(ctx, err)
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Changes requested successfully", nil)
+}
+
+// GetApprovalHistory retrieves the approval stages and audit trail of a submission
+func (h *FormHandler) GetApprovalHistory(ctx *gin.Context) {
+	submissionID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	history, err := h.formService.GetApprovalHistory(ctx, submissionID)
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Approval history retrieved successfully", history)
+}
+
+// ListApprovalQueue lists the approval stages awaiting a decision from the current user
+func (h *FormHandler) ListApprovalQueue(ctx *gin.Context) {
+	user := h.srv.ContextGetUser(ctx)
+
+	tasks, err := h.srv.Store.ListActiveApprovalTasks(ctx)
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	queue := []db.FormApprovalTask{}
+	for _, task := range tasks {
+		if h.srv.Store.HasPermission(ctx, *user, task.Permission) {
+			queue = append(queue, task)
+		}
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Approval queue retrieved successfully", queue)
+}
+
+// EscalateOverdueApprovals escalates approval stages that have passed their SLA
+func (h *FormHandler) EscalateOverdueApprovals(ctx *gin.Context) {
+	escalated, err := h.formService.EscalateOverdueApprovals(ctx)
+	if errors.Is(err, service.ErrApprovalEscalationRunning) {
+		h.srv.ErrorJSONResponse(ctx, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		h.srv.Logger.Error(err, nil)
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Overdue approvals escalated successfully", map[string]interface{}{
+		"escalated": escalated,
+	})
+}
+
+func (h *FormHandler) approvalError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		h.srv.ErrorJSONResponse(ctx, http.StatusNotFound, fmt.Errorf("submission not found"))
+	case errors.Is(err, service.ErrNoApprovalTask), errors.Is(err, db.ErrMakerChecker):
+		h.srv.ErrorJSONResponse(ctx, http.StatusForbidden, err)
+	case errors.Is(err, db.ErrDuplicateApprover), errors.Is(err, db.ErrApprovalTaskNotActive):
+		h.srv.ErrorJSONResponse(ctx, http.StatusConflict, err)
+	default:
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/forms/service"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// ExportFormBundle downloads a form with its events, assignments and option sources as a
+// bundle that can be imported into another environment
+func (h *FormHandler) ExportFormBundle(ctx *gin.Context) {
+	formID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	encoding := ctx.DefaultQuery("format", service.BundleEncodingJSON)
+	if encoding != service.BundleEncodingJSON && encoding != service.BundleEncodingYAML {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, fmt.Errorf("format must be json or yaml"))
+		return
+	}
+
+	bundle, err := h.formService.ExportFormBundle(ctx, formID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.srv.ErrorJSONResponse(ctx, http.StatusNotFound, fmt.Errorf("form not found"))
+			return
+		}
+		h.srv.Logger.Error(err, map[string]interface{}{
+			"form_id": formID,
+		})
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, errors.New("unable to export form"))
+		return
+	}
+
+	var data bytes.Buffer
+	if err := service.EncodeFormBundle(&data, bundle, encoding); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	contentType := "application/json"
+	if encoding == service.BundleEncodingYAML {
+		contentType = "application/yaml"
+	}
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", bundle.Form.Slug+".form."+encoding))
+	ctx.Data(http.StatusOK, contentType, data.Bytes())
+}
+
+// ImportFormBundle imports a JSON or YAML bundle. With dry_run=true the bundle is only
+// validated and the response says whether the form would be created, skipped or updated.
+func (h *FormHandler) ImportFormBundle(ctx *gin.Context) {
+	user := h.srv.ContextGetUser(ctx)
+
+	var query ImportFormBundleQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	encoding := query.Format
+	if encoding == "" {
+		encoding = service.BundleEncodingJSON
+		if strings.Contains(ctx.ContentType(), "yaml") {
+			encoding = service.BundleEncodingYAML
+		}
+	}
+
+	idMap, err := parseIDMap(query.Map)
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	bundle, err := service.DecodeFormBundle(ctx.Request.Body, encoding)
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := h.formService.ImportFormBundle(ctx, service.ImportFormBundleInput{
+		Bundle:     bundle,
+		OnConflict: query.OnConflict,
+		DryRun:     query.DryRun,
+		IDMap:      idMap,
+		ImportedBy: user.ID,
+		ChangeNote: query.ChangeNote,
+	})
+	if err != nil {
+		var validationErr *validator.ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			h.srv.SendValidationError(ctx, validationErr)
+		case errors.Is(err, service.ErrUnsupportedBundle):
+			h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		case errors.Is(err, db.ErrFormSlugExists):
+			h.srv.ErrorJSONResponse(ctx, http.StatusConflict, err)
+		default:
+			h.srv.Logger.Error(err, map[string]interface{}{
+				"slug": bundle.Form.Slug,
+			})
+			h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, errors.New("unable to import form"))
+		}
+		return
+	}
+
+	status := http.StatusOK
+	if result.Action == db.FormImportCreated && !result.DryRun {
+		status = http.StatusCreated
+	}
+	h.srv.SuccessJSONResponse(ctx, status, "Form bundle imported successfully", result)
+}
+
+// parseIDMap parses old=new pairs of IDs to replace in an imported bundle
+func parseIDMap(pairs []string) (map[string]string, error) {
+	idMap := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		from, to, ok := strings.Cut(pair, "=")
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid id mapping %q, expected old=new", pair)
+		}
+		idMap[from] = to
+	}
+	return idMap, nil
+}
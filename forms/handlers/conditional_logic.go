@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/timchuks/monieverse/internal/forms/service"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+var conditionActions = []string{
+	service.ConditionActionShow,
+	service.ConditionActionHide,
+	service.ConditionActionRequire,
+}
+
+var conditionOperators = []string{
+	service.OperatorEquals,
+	service.OperatorNotEquals,
+	service.OperatorIn,
+	service.OperatorNotIn,
+	service.OperatorGt,
+	service.OperatorGte,
+	service.OperatorLt,
+	service.OperatorLte,
+	service.OperatorContains,
+	service.OperatorIsEmpty,
+	service.OperatorIsNotEmpty,
+}
+
+// validateConditionalLogic checks that every rule uses a known action and operators and only
+// references other fields of the form.
+func validateConditionalLogic(v *validator.Validator, fields []FieldInput) {
+	names := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		names[field.FieldName] = true
+	}
+
+	for _, field := range fields {
+		logic := field.ConditionalLogic
+		if logic == nil {
+			continue
+		}
+
+		key := fmt.Sprintf("%s.conditional_logic", field.FieldName)
+		v.Check(logic.Action == "" || validator.In(logic.Action, conditionActions...), key, "must have a show, hide or require action")
+
+		validateConditionGroup(v, key, field.FieldName, names, ConditionGroupInput{
+			Conditions: logic.Conditions,
+			Logic:      logic.Logic,
+			Groups:     logic.Groups,
+		})
+	}
+}
+
+func validateConditionGroup(v *validator.Validator, key, fieldName string, names map[string]bool, group ConditionGroupInput) {
+	v.Check(group.Logic == "" || validator.In(group.Logic, "all", "any"), key, "logic must be all or any")
+
+	for _, cond := range group.Conditions {
+		v.Check(names[cond.Field], key, fmt.Sprintf("references unknown field %q", cond.Field))
+		v.Check(cond.Field != fieldName, key, "must not reference its own field")
+		v.Check(validator.In(cond.Operator, conditionOperators...), key, fmt.Sprintf("unsupported operator %q", cond.Operator))
+	}
+
+	for _, nested := range group.Groups {
+		validateConditionGroup(v, key, fieldName, names, nested)
+	}
+}
+
+// conditionGroupToMap converts a condition group, including nested groups, to the stored format
+func conditionGroupToMap(group ConditionGroupInput) map[string]interface{} {
+	conditions := make([]map[string]interface{}, len(group.Conditions))
+	for i, cond := range group.Conditions {
+		conditions[i] = map[string]interface{}{
+			"field":    cond.Field,
+			"operator": cond.Operator,
+			"value":    cond.Value,
+		}
+	}
+
+	result := map[string]interface{}{
+		"conditions": conditions,
+		"logic":      group.Logic,
+	}
+
+	if len(group.Groups) > 0 {
+		groups := make([]map[string]interface{}, len(group.Groups))
+		for i, nested := range group.Groups {
+			groups[i] = conditionGroupToMap(nested)
+		}
+		result["groups"] = groups
+	}
+
+	return result
+}
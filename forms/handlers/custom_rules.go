@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// GetCustomRuleOptions lists the rule types, compare operators, uniqueness checks and
+// validation hooks the custom rules of fields and steps can use
+func (h *FormHandler) GetCustomRuleOptions(ctx *gin.Context) {
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Custom rule options retrieved successfully", h.formService.GetCustomRuleOptions())
+}
+
+// validateCustomRules checks the custom rules of fields and steps reference fields of the form
+// and known checks and hooks
+func (h *FormHandler) validateCustomRules(v *validator.Validator, steps []StepInput, fields []FieldInput) {
+	fieldTypes := make(map[string]string, len(fields))
+	for _, field := range fields {
+		fieldTypes[field.FieldName] = field.FieldType
+	}
+
+	check := func(key, fieldName string, validationRules map[string]interface{}) {
+		raw, ok := validationRules["custom_rules"]
+		if !ok || raw == nil {
+			return
+		}
+		rules, ok := raw.(map[string]interface{})
+		if !ok {
+			v.AddError(key, "must be an object of rules by name")
+			return
+		}
+		h.formService.CheckCustomRules(v, key, fieldName, rules, fieldTypes)
+	}
+
+	for _, field := range fields {
+		check(fmt.Sprintf("%s.custom_rules", field.FieldName), field.FieldName, field.ValidationRules)
+	}
+	for _, step := range steps {
+		check(fmt.Sprintf("steps.%d.custom_rules", step.StepNumber), "", step.ValidationRules)
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ListFailedFormEvents lists the event deliveries of a form that ran out of attempts
+func (h *FormHandler) ListFailedFormEvents(ctx *gin.Context) {
+	formID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	deliveries, err := h.srv.Store.ListFailedFormEventOutbox(ctx, formID)
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Failed form events retrieved successfully", deliveries)
+}
+
+// RetryFormEvent queues a failed event delivery again
+func (h *FormHandler) RetryFormEvent(ctx *gin.Context) {
+	deliveryID, err := uuid.Parse(ctx.Param("deliveryId"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.srv.Store.RetryFormEventOutbox(ctx, deliveryID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.srv.ErrorJSONResponse(ctx, http.StatusNotFound, fmt.Errorf("failed delivery not found"))
+			return
+		}
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Form event queued for retry", nil)
+}
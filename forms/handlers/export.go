@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/timchuks/monieverse/internal/forms/service"
+)
+
+// ExportSubmission downloads the user's own submission as a PDF, or with format=zip as an
+// archive of the PDF, the uploaded files and a manifest of their SHA-256 hashes
+func (h *FormHandler) ExportSubmission(ctx *gin.Context) {
+	h.exportSubmission(ctx, false)
+}
+
+// AdminExportSubmission downloads any submission as a PDF or archive
+func (h *FormHandler) AdminExportSubmission(ctx *gin.Context) {
+	h.exportSubmission(ctx, true)
+}
+
+func (h *FormHandler) exportSubmission(ctx *gin.Context, admin bool) {
+	user := h.srv.ContextGetUser(ctx)
+
+	submissionID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	locale := ctx.Query("locale")
+	if locale == "" {
+		locale, _ = h.requestLanguage(ctx)
+	}
+
+	input := service.ExportSubmissionInput{
+		SubmissionID: submissionID,
+		UserID:       user.ID,
+		Admin:        admin,
+		Locale:       locale,
+	}
+	filename := "submission-" + submissionID.String()
+
+	switch ctx.DefaultQuery("format", "pdf") {
+	case "pdf":
+		pdf, err := h.formService.ExportSubmissionPDF(ctx, input)
+		if err != nil {
+			h.exportError(ctx, submissionID, err)
+			return
+		}
+		ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".pdf"))
+		ctx.Data(http.StatusOK, "application/pdf", pdf)
+
+	case "zip":
+		// Buffered so a failed download is still reported as an error
+		var archive bytes.Buffer
+		if _, err := h.formService.ExportSubmissionArchive(ctx, input, &archive); err != nil {
+			h.exportError(ctx, submissionID, err)
+			return
+		}
+		ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".zip"))
+		ctx.Data(http.StatusOK, "application/zip", archive.Bytes())
+
+	default:
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, fmt.Errorf("format must be pdf or zip"))
+	}
+}
+
+func (h *FormHandler) exportError(ctx *gin.Context, submissionID uuid.UUID, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		h.srv.ErrorJSONResponse(ctx, http.StatusNotFound, fmt.Errorf("submission not found"))
+	case errors.Is(err, service.ErrExportNotAllowed):
+		h.srv.ErrorJSONResponse(ctx, http.StatusForbidden, err)
+	default:
+		h.srv.Logger.Error(err, map[string]interface{}{
+			"submission_id": submissionID,
+		})
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, errors.New("unable to export submission"))
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timchuks/monieverse/internal/forms/service"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// GetFieldTypes lists the rich field types with the phone plans and identity numbers renderers
+// need to present them
+func (h *FormHandler) GetFieldTypes(ctx *gin.Context) {
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Field types retrieved successfully", service.GetFieldTypeCatalog())
+}
+
+// validateFieldTypes checks the rules of rich fields, including those of group items: that
+// date bounds parse, countries and identity numbers are known and choice lists have options.
+func validateFieldTypes(v *validator.Validator, fields []FieldInput) {
+	for _, field := range fields {
+		if field.Options != nil && service.IsGroupFieldType(field.FieldType) {
+			validateFieldTypes(v, field.Options.Fields)
+			continue
+		}
+
+		if service.IsChoiceListFieldType(field.FieldType) {
+			v.Check(field.Options != nil && (len(field.Options.Static) > 0 || field.Options.Dynamic != nil),
+				fmt.Sprintf("%s.options", field.FieldName), "choice lists must have options")
+		}
+
+		if len(field.ValidationRules) == 0 {
+			continue
+		}
+
+		key := fmt.Sprintf("%s.validation_rules", field.FieldName)
+		var rules service.ValidationRules
+		data, _ := json.Marshal(field.ValidationRules)
+		if err := json.Unmarshal(data, &rules); err != nil {
+			v.AddError(key, err.Error())
+			continue
+		}
+		if err := service.ValidateFieldTypeRules(field.FieldType, rules); err != nil {
+			v.AddError(key, err.Error())
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/timchuks/monieverse/internal/forms/service"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// groupItemFieldName matches the names nested fields may use, as they become part of
+// submission keys like owners[0].first_name
+var groupItemFieldName = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+// validateGroupFields checks that group fields declare their item fields, that those have
+// distinct names and that groups are not nested.
+func validateGroupFields(v *validator.Validator, fields []FieldInput) {
+	for _, field := range fields {
+		if !service.IsGroupFieldType(field.FieldType) {
+			continue
+		}
+
+		key := fmt.Sprintf("%s.options.fields", field.FieldName)
+		if field.Options == nil || len(field.Options.Fields) == 0 {
+			v.AddError(key, "group fields must have at least one field")
+			continue
+		}
+
+		names := make(map[string]bool, len(field.Options.Fields))
+		for _, item := range field.Options.Fields {
+			v.Check(groupItemFieldName.MatchString(item.FieldName), key, fmt.Sprintf("invalid field name %q", item.FieldName))
+			v.Check(!names[item.FieldName], key, fmt.Sprintf("duplicate field %q", item.FieldName))
+			v.Check(!service.IsGroupFieldType(item.FieldType), key, "groups cannot be nested")
+			v.Check(item.ConditionalLogic == nil, key, "fields of a group cannot have conditional logic")
+			names[item.FieldName] = true
+		}
+	}
+}
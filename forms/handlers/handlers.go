@@ -17,6 +17,7 @@ import (
 	"github.com/timchuks/monieverse/core/server"
 	db "github.com/timchuks/monieverse/internal/db/sqlc"
 	"github.com/timchuks/monieverse/internal/forms/service"
+	"github.com/timchuks/monieverse/internal/validator"
 )
 
 type FormHandler struct {
@@ -56,7 +57,35 @@ func (h *FormHandler) CreateFormDefinition(ctx *gin.Context) {
 		return
 	}
 
-	// Build input
+	v := validator.New()
+	validateConditionalLogic(v, req.Fields)
+	validateGroupFields(v, req.Fields)
+	validateFieldTypes(v, req.Fields)
+	validatePrefill(v, req.Fields)
+	h.validateCustomRules(v, req.Steps, req.Fields)
+	validateApprovalWorkflow(v, req.FormType, req.RequiresApproval, req.ApprovalWorkflow)
+	if !v.Valid() {
+		h.srv.SendValidationError(ctx, validator.NewValidationError("validation failed", v.Errors))
+		return
+	}
+
+	input := formDefinitionInput(req, user.ID)
+
+	// Create form
+	form, err := h.srv.Store.CreateFormDefinitionTx(ctx, input)
+	if err != nil {
+		h.srv.Logger.Error(err, map[string]interface{}{
+			"request": req,
+		})
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusCreated, "Form created successfully", form)
+}
+
+// formDefinitionInput converts a create form request to the store input
+func formDefinitionInput(req CreateFormRequest, createdBy uuid.UUID) *db.FormDefinitionInput {
 	input := &db.FormDefinitionInput{
 		Name:                      req.Name,
 		Slug:                      req.Slug,
@@ -65,141 +94,24 @@ func (h *FormHandler) CreateFormDefinition(ctx *gin.Context) {
 		IsMultiStep:               req.IsMultiStep,
 		RequiresApproval:          req.RequiresApproval,
 		IsEditableAfterSubmission: req.IsEditableAfterSubmission,
-		CreatedBy:                 user.ID,
-	}
-
-	// Convert ApprovalWorkflow
-	if req.ApprovalWorkflow != nil {
-		approvalWorkflow := &db.ApprovalWorkflowInput{
-			States:      make([]map[string]interface{}, len(req.ApprovalWorkflow.States)),
-			Transitions: make([]map[string]interface{}, len(req.ApprovalWorkflow.Transitions)),
-		}
-
-		// Convert states
-		for i, state := range req.ApprovalWorkflow.States {
-			approvalWorkflow.States[i] = map[string]interface{}{
-				"name":        state.Name,
-				"label":       state.Label,
-				"is_final":    state.IsFinal,
-				"permissions": state.Permissions,
-			}
-		}
-
-		// Convert transitions
-		for i, transition := range req.ApprovalWorkflow.Transitions {
-			approvalWorkflow.Transitions[i] = map[string]interface{}{
-				"from":        transition.From,
-				"to":          transition.To,
-				"label":       transition.Label,
-				"permissions": transition.Permissions,
-			}
-		}
-
-		input.ApprovalWorkflow = approvalWorkflow
+		CreatedBy:                 createdBy,
+		ApprovalWorkflow:          approvalWorkflowInput(req.ApprovalWorkflow),
 	}
 
 	// Add steps
 	for _, step := range req.Steps {
 		input.Steps = append(input.Steps, db.StepInput{
-			StepNumber:  step.StepNumber,
-			Name:        step.Name,
-			Description: step.Description,
-			IsOptional:  step.IsOptional,
+			StepNumber:      step.StepNumber,
+			Name:            step.Name,
+			Description:     step.Description,
+			IsOptional:      step.IsOptional,
+			ValidationRules: step.ValidationRules,
 		})
 	}
 
 	// Add fields
 	for _, field := range req.Fields {
-		fieldInput := db.FieldInput{
-			FieldName:    field.FieldName,
-			FieldType:    field.FieldType,
-			Label:        field.Label,
-			DisplayOrder: field.DisplayOrder,
-			IsRequired:   field.IsRequired,
-			IsReadonly:   field.IsReadonly,
-		}
-
-		if field.StepNumber != nil {
-			fieldInput.StepNumber = *field.StepNumber
-		}
-
-		if field.Placeholder != nil {
-			fieldInput.Placeholder = field.Placeholder
-		}
-
-		if field.HelpText != nil {
-			fieldInput.HelpText = field.HelpText
-		}
-
-		if field.ValidationRules != nil {
-			fieldInput.ValidationRules = field.ValidationRules
-		}
-
-		// Convert Options to map
-		if field.Options != nil {
-			optionsMap := map[string]interface{}{
-				"type": field.Options.Type,
-			}
-
-			if len(field.Options.Static) > 0 {
-				staticOptions := make([]map[string]interface{}, len(field.Options.Static))
-				for i, opt := range field.Options.Static {
-					staticOptions[i] = map[string]interface{}{
-						"value": opt.Value,
-						"label": opt.Label,
-					}
-				}
-				optionsMap["static"] = staticOptions
-			}
-
-			if field.Options.Dynamic != nil {
-				dynamicMap := map[string]interface{}{
-					"source_name": field.Options.Dynamic.SourceName,
-				}
-				if field.Options.Dynamic.FilterParams != nil {
-					dynamicMap["filter_params"] = field.Options.Dynamic.FilterParams
-				}
-				optionsMap["dynamic"] = dynamicMap
-			}
-
-			fieldInput.Options = optionsMap
-		}
-
-		if field.DefaultValue != nil {
-			fieldInput.DefaultValue = field.DefaultValue
-		}
-
-		// Convert ConditionalLogic to map
-		if field.ConditionalLogic != nil {
-			conditions := make([]map[string]interface{}, len(field.ConditionalLogic.Conditions))
-			for i, cond := range field.ConditionalLogic.Conditions {
-				conditions[i] = map[string]interface{}{
-					"field":    cond.Field,
-					"operator": cond.Operator,
-					"value":    cond.Value,
-				}
-			}
-
-			fieldInput.ConditionalLogic = map[string]interface{}{
-				"action":     field.ConditionalLogic.Action,
-				"conditions": conditions,
-				"logic":      field.ConditionalLogic.Logic,
-			}
-		}
-
-		// Convert FileConfig to map
-		if field.FileConfig != nil {
-			fileConfigMap := map[string]interface{}{
-				"max_size":      field.FileConfig.MaxSize,
-				"allowed_types": field.FileConfig.AllowedTypes,
-			}
-			if field.FileConfig.MaxFiles > 0 {
-				fileConfigMap["max_files"] = field.FileConfig.MaxFiles
-			}
-			fieldInput.FileConfig = fileConfigMap
-		}
-
-		input.Fields = append(input.Fields, fieldInput)
+		input.Fields = append(input.Fields, fieldDefinitionInput(field))
 	}
 
 	// Convert PersistenceConfig
@@ -237,6 +149,11 @@ func (h *FormHandler) CreateFormDefinition(ctx *gin.Context) {
 			if mapping.MetaKey != "" {
 				mappingMap["meta_key"] = mapping.MetaKey
 			}
+			if len(mapping.ItemMappings) > 0 {
+				mappingMap["item_mappings"] = mapping.ItemMappings
+				mappingMap["parent_table"] = mapping.ParentTable
+				mappingMap["parent_column"] = mapping.ParentColumn
+			}
 			fieldMappings[key] = mappingMap
 		}
 		persistenceConfig.FieldMappings = fieldMappings
@@ -260,21 +177,158 @@ func (h *FormHandler) CreateFormDefinition(ctx *gin.Context) {
 		input.PersistenceConfig = persistenceConfig
 	}
 
-	// Create form
-	form, err := h.srv.Store.CreateFormDefinitionTx(ctx, input)
-	if err != nil {
-		h.srv.Logger.Error(err, map[string]interface{}{
-			"request": req,
+	return input
+}
+
+// fieldDefinitionInput converts a field of a form request to the store input
+func fieldDefinitionInput(field FieldInput) db.FieldInput {
+	fieldInput := db.FieldInput{
+		FieldName:    field.FieldName,
+		FieldType:    field.FieldType,
+		Label:        field.Label,
+		DisplayOrder: field.DisplayOrder,
+		IsRequired:   field.IsRequired,
+		IsReadonly:   field.IsReadonly,
+	}
+
+	if field.StepNumber != nil {
+		fieldInput.StepNumber = *field.StepNumber
+	}
+
+	if field.Placeholder != nil {
+		fieldInput.Placeholder = field.Placeholder
+	}
+
+	if field.HelpText != nil {
+		fieldInput.HelpText = field.HelpText
+	}
+
+	if field.ValidationRules != nil {
+		fieldInput.ValidationRules = field.ValidationRules
+	}
+
+	// Convert Options to map
+	if field.Options != nil {
+		optionsMap := map[string]interface{}{
+			"type": field.Options.Type,
+		}
+
+		if len(field.Options.Static) > 0 {
+			staticOptions := make([]map[string]interface{}, len(field.Options.Static))
+			for i, opt := range field.Options.Static {
+				staticOptions[i] = map[string]interface{}{
+					"value": opt.Value,
+					"label": opt.Label,
+				}
+			}
+			optionsMap["static"] = staticOptions
+		}
+
+		if field.Options.Dynamic != nil {
+			dynamicMap := map[string]interface{}{
+				"source_name": field.Options.Dynamic.SourceName,
+			}
+			if field.Options.Dynamic.FilterParams != nil {
+				dynamicMap["filter_params"] = field.Options.Dynamic.FilterParams
+			}
+			optionsMap["dynamic"] = dynamicMap
+		}
+
+		fieldInput.Options = optionsMap
+	}
+
+	if field.DefaultValue != nil {
+		fieldInput.DefaultValue = field.DefaultValue
+	}
+
+	// Convert ConditionalLogic to map
+	if field.ConditionalLogic != nil {
+		fieldInput.ConditionalLogic = conditionGroupToMap(ConditionGroupInput{
+			Conditions: field.ConditionalLogic.Conditions,
+			Logic:      field.ConditionalLogic.Logic,
+			Groups:     field.ConditionalLogic.Groups,
 		})
-		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
-		return
+		fieldInput.ConditionalLogic["action"] = field.ConditionalLogic.Action
 	}
 
-	h.srv.SuccessJSONResponse(ctx, http.StatusCreated, "Form created successfully", form)
+	// Convert FileConfig to map
+	if field.FileConfig != nil {
+		fileConfigMap := map[string]interface{}{
+			"max_size":      field.FileConfig.MaxSize,
+			"allowed_types": field.FileConfig.AllowedTypes,
+		}
+		if field.FileConfig.MaxFiles > 0 {
+			fileConfigMap["max_files"] = field.FileConfig.MaxFiles
+		}
+		fieldInput.FileConfig = fileConfigMap
+	}
+
+	if field.Prefill != nil {
+		fieldInput.Prefill = map[string]interface{}{
+			"source":        field.Prefill.Source,
+			"lock_verified": field.Prefill.LockVerified,
+		}
+	}
+
+	return fieldInput
 }
 
-// UpdateFormDefinition updates a form
+// approvalWorkflowInput converts a request's approval workflow to the stored format
+func approvalWorkflowInput(workflow *ApprovalWorkflowInput) *db.ApprovalWorkflowInput {
+	if workflow == nil {
+		return nil
+	}
+
+	approvalWorkflow := &db.ApprovalWorkflowInput{
+		States:      make([]map[string]interface{}, len(workflow.States)),
+		Transitions: make([]map[string]interface{}, len(workflow.Transitions)),
+	}
+
+	// Convert states
+	for i, state := range workflow.States {
+		approvalWorkflow.States[i] = map[string]interface{}{
+			"name":        state.Name,
+			"label":       state.Label,
+			"is_final":    state.IsFinal,
+			"permissions": state.Permissions,
+		}
+	}
+
+	// Convert transitions
+	for i, transition := range workflow.Transitions {
+		approvalWorkflow.Transitions[i] = map[string]interface{}{
+			"from":        transition.From,
+			"to":          transition.To,
+			"label":       transition.Label,
+			"permissions": transition.Permissions,
+		}
+	}
+
+	// Convert stages
+	for i, stage := range workflow.Stages {
+		order := stage.Order
+		if order <= 0 {
+			order = int32(i + 1)
+		}
+		approvalWorkflow.Stages = append(approvalWorkflow.Stages, map[string]interface{}{
+			"name":               stage.Name,
+			"label":              stage.Label,
+			"order":              order,
+			"permission":         stage.Permission,
+			"required_approvals": stage.RequiredApprovals,
+			"sla_hours":          stage.SLAHours,
+			"escalate_to":        stage.EscalateTo,
+		})
+	}
+
+	return approvalWorkflow
+}
+
+// UpdateFormDefinition updates a form. Activation takes effect immediately; the other changes
+// are staged in the form's draft version so that submissions in progress are not affected.
 func (h *FormHandler) UpdateFormDefinition(ctx *gin.Context) {
+	user := h.srv.ContextGetUser(ctx)
+
 	formID, err := uuid.Parse(ctx.Param("id"))
 	if err != nil {
 		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
@@ -287,27 +341,64 @@ func (h *FormHandler) UpdateFormDefinition(ctx *gin.Context) {
 		return
 	}
 
-	// Update form
-	params := db.UpdateFormDefinitionParams{
+	current, err := h.srv.Store.GetFormDefinition(ctx, formID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.srv.ErrorJSONResponse(ctx, http.StatusNotFound, fmt.Errorf("form not found"))
+			return
+		}
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	v := validator.New()
+	validateApprovalWorkflow(v, current.FormType, current.RequiresApproval, req.ApprovalWorkflow)
+	if !v.Valid() {
+		h.srv.SendValidationError(ctx, validator.NewValidationError("validation failed", v.Errors))
+		return
+	}
+
+	// Only the active flag is changed on the live form
+	form, err := h.srv.Store.UpdateFormDefinition(ctx, db.UpdateFormDefinitionParams{
 		ID:                        formID,
+		Name:                      current.Name,
+		Description:               current.Description,
+		IsActive:                  req.IsActive,
+		ApprovalWorkflow:          current.ApprovalWorkflow,
+		IsEditableAfterSubmission: current.IsEditableAfterSubmission,
+	})
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	input := &db.FormDefinitionInput{
 		Name:                      req.Name,
 		Description:               req.Description,
-		IsActive:                  req.IsActive,
+		IsMultiStep:               current.IsMultiStep,
+		RequiresApproval:          current.RequiresApproval,
 		IsEditableAfterSubmission: req.IsEditableAfterSubmission,
+		CreatedBy:                 user.ID,
+		ApprovalWorkflow:          approvalWorkflowInput(req.ApprovalWorkflow),
 	}
 
-	if req.ApprovalWorkflow != nil {
-		workflowJSON, _ := json.Marshal(req.ApprovalWorkflow)
-		params.ApprovalWorkflow = workflowJSON
+	if input.ApprovalWorkflow == nil && string(current.ApprovalWorkflow) != "{}" {
+		_ = json.Unmarshal(current.ApprovalWorkflow, &input.ApprovalWorkflow)
 	}
 
-	form, err := h.srv.Store.UpdateFormDefinition(ctx, params)
+	draft, err := h.srv.Store.SaveFormDraftVersionTx(ctx, &db.FormDraftVersionInput{
+		FormDefinitionID: formID,
+		Definition:       input,
+	})
 	if err != nil {
 		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
-	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Form updated successfully", form)
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Form updated successfully, publish the draft version to apply the changes", map[string]interface{}{
+		"form":  form,
+		"draft": draft,
+	})
 }
 
 // GetFormDefinition retrieves a form
@@ -408,6 +499,12 @@ func (h *FormHandler) CreateFormAssignment(ctx *gin.Context) {
 	if req.Conditions != nil {
 		conditionsJSON, _ := json.Marshal(req.Conditions)
 		params.Conditions.RawMessage = conditionsJSON
+		params.Conditions.Valid = true
+	}
+
+	if err := service.ValidateFormAssignment(req.AssignmentType, params.Conditions.RawMessage); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
 	}
 
 	if req.ValidFrom != nil {
@@ -427,6 +524,34 @@ func (h *FormHandler) CreateFormAssignment(ctx *gin.Context) {
 	h.srv.SuccessJSONResponse(ctx, http.StatusCreated, "Assignment created successfully", assignment)
 }
 
+// ExplainFormAssignment reports which form of a type a user would get, and why each
+// assignment does or doesn't apply to them
+func (h *FormHandler) ExplainFormAssignment(ctx *gin.Context) {
+	userID, err := uuid.Parse(ctx.Query("user_id"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, fmt.Errorf("invalid user_id: %w", err))
+		return
+	}
+
+	formType := ctx.Query("type")
+	if formType == "" {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, fmt.Errorf("form type is required"))
+		return
+	}
+
+	explanation, err := h.formService.ExplainFormAssignment(ctx, userID, formType)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.srv.ErrorJSONResponse(ctx, http.StatusNotFound, fmt.Errorf("user not found"))
+			return
+		}
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Assignment explained successfully", explanation)
+}
+
 // CreatePersistenceConfig creates persistence config
 func (h *FormHandler) CreatePersistenceConfig(ctx *gin.Context) {
 	formID, err := uuid.Parse(ctx.Param("id"))
@@ -453,6 +578,11 @@ func (h *FormHandler) CreatePersistenceConfig(ctx *gin.Context) {
 	mappingsJSON, _ := json.Marshal(req.FieldMappings)
 	params.FieldMappings = mappingsJSON
 
+	if err := validatePersistenceConfig(req.PersistenceMode, targetJSON, mappingsJSON); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
 	if req.TransformationRules != nil {
 		rulesJSON, _ := json.Marshal(req.TransformationRules)
 		params.TransformationRules = rulesJSON
@@ -469,6 +599,12 @@ func (h *FormHandler) CreatePersistenceConfig(ctx *gin.Context) {
 		return
 	}
 
+	// Answers stored before a field was marked is_encrypted are encrypted now
+	if _, err := h.srv.Store.SealFormSubmissionsTx(ctx, formID); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
 	h.srv.SuccessJSONResponse(ctx, http.StatusCreated, "Persistence config created successfully", config)
 }
 
@@ -490,6 +626,7 @@ func (h *FormHandler) GetUserForm(ctx *gin.Context) {
 		return
 	}
 
+	h.localizeForm(ctx, form)
 	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Form retrieved successfully", form)
 }
 
@@ -544,7 +681,7 @@ func (h *FormHandler) SubmitForm(ctx *gin.Context) {
 
 	submission, err := h.formService.SubmitForm(ctx, input)
 	if err != nil {
-		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		h.srv.ErrorJSONResponse(ctx, submissionErrorStatus(err), h.localizeError(ctx, err))
 		return
 	}
 
@@ -585,6 +722,7 @@ func (h *FormHandler) GetFormSubmission(ctx *gin.Context) {
 		"files":      files,
 	}
 
+	setRevisionETag(ctx, submission.Revision)
 	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Submission retrieved successfully", response)
 }
 
@@ -639,7 +777,7 @@ func (h *FormHandler) ApproveSubmission(ctx *gin.Context) {
 	}
 
 	if err := h.formService.ApproveSubmission(ctx, submissionID, approver.ID, req.Notes); err != nil {
-		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+		h.approvalError(ctx, err)
 		return
 	}
 
@@ -668,7 +806,7 @@ func (h *FormHandler) RejectSubmission(ctx *gin.Context) {
 	}
 
 	if err := h.formService.RejectSubmission(ctx, submissionID, approver.ID, req.Reason); err != nil {
-		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+		h.approvalError(ctx, err)
 		return
 	}
 
@@ -746,6 +884,11 @@ func (h *FormHandler) UpdatePersistenceConfig(ctx *gin.Context) {
 	mappingsJSON, _ := json.Marshal(req.FieldMappings)
 	params.FieldMappings = mappingsJSON
 
+	if err := validatePersistenceConfig(req.PersistenceMode, targetJSON, mappingsJSON); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
 	if req.TransformationRules != nil {
 		rulesJSON, _ := json.Marshal(req.TransformationRules)
 		params.TransformationRules = rulesJSON
@@ -762,6 +905,12 @@ func (h *FormHandler) UpdatePersistenceConfig(ctx *gin.Context) {
 		return
 	}
 
+	// Answers stored before a field was marked is_encrypted are encrypted now
+	if _, err := h.srv.Store.SealFormSubmissionsTx(ctx, formID); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
 	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Persistence config updated successfully", config)
 }
 
@@ -781,6 +930,7 @@ func (h *FormHandler) GetFormSubmissionForEdit(ctx *gin.Context) {
 		return
 	}
 
+	h.localizeForm(ctx, formData)
 	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Form retrieved successfully", formData)
 }
 
@@ -836,13 +986,20 @@ func (h *FormHandler) UpdateFormSubmission(ctx *gin.Context) {
 		delete(data, "_partial") // Remove from data
 	}
 
+	revision, err := expectedRevision(ctx, data)
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
 	input := service.UpdateSubmissionInput{
-		SubmissionID:    submissionID,
-		UserID:          user.ID,
-		Data:            data,
-		Files:           ctx.Request.MultipartForm.File,
-		Status:          status,
-		IsPartialUpdate: isPartialUpdate,
+		SubmissionID:     submissionID,
+		UserID:           user.ID,
+		Data:             data,
+		Files:            ctx.Request.MultipartForm.File,
+		Status:           status,
+		IsPartialUpdate:  isPartialUpdate,
+		ExpectedRevision: revision,
 		Metadata: map[string]interface{}{
 			"ip_address": ctx.ClientIP(),
 			"user_agent": ctx.Request.UserAgent(),
@@ -852,10 +1009,14 @@ func (h *FormHandler) UpdateFormSubmission(ctx *gin.Context) {
 
 	submission, err := h.formService.UpdateFormSubmission(ctx, input)
 	if err != nil {
-		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		if h.revisionConflict(ctx, err) {
+			return
+		}
+		h.srv.ErrorJSONResponse(ctx, submissionErrorStatus(err), h.localizeError(ctx, err))
 		return
 	}
 
+	setRevisionETag(ctx, submission.Revision)
 	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Form updated successfully", submission)
 }
 
@@ -961,20 +1122,33 @@ func (h *FormHandler) SaveStepProgress(ctx *gin.Context) {
 
 	status := ctx.DefaultQuery("status", "in_progress")
 
+	// Steps have their own revisions, so that saving one step doesn't conflict with another
+	revision, err := expectedRevision(ctx, data)
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
 	input := service.SaveStepProgressInput{
-		SubmissionID: submissionID,
-		UserID:       user.ID,
-		StepNumber:   int32(stepNumber),
-		Status:       status,
-		Data:         data,
+		SubmissionID:     submissionID,
+		UserID:           user.ID,
+		StepNumber:       int32(stepNumber),
+		Status:           status,
+		Data:             data,
+		ExpectedRevision: revision,
 	}
 
 	result, err := h.formService.SaveStepProgress(ctx, input)
 	if err != nil {
-		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		if h.revisionConflict(ctx, err) {
+			return
+		}
+		h.srv.ErrorJSONResponse(ctx, submissionErrorStatus(err), h.localizeError(ctx, err))
 		return
 	}
 
+	setRevisionETag(ctx, result.Revision)
+
 	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Step progress saved", result)
 }
 
@@ -994,6 +1168,7 @@ func (h *FormHandler) GetFormWithProgress(ctx *gin.Context) {
 		return
 	}
 
+	h.localizeForm(ctx, form)
 	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Form retrieved successfully", form)
 }
 
@@ -1009,7 +1184,7 @@ func (h *FormHandler) CompleteForm(ctx *gin.Context) {
 
 	submission, err := h.formService.CompleteForm(ctx, submissionID, user.ID)
 	if err != nil {
-		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, h.localizeError(ctx, err))
 		return
 	}
 
@@ -1066,10 +1241,12 @@ func (h *FormHandler) GetStepData(ctx *gin.Context) {
 	var stepData map[string]interface{}
 	_ = json.Unmarshal(progress.Data.RawMessage, &stepData)
 
+	setRevisionETag(ctx, progress.Revision)
 	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Step data retrieved", map[string]interface{}{
 		"status":       progress.Status,
 		"data":         stepData,
 		"completed_at": progress.CompletedAt,
+		"revision":     progress.Revision,
 	})
 }
 
@@ -1210,7 +1387,7 @@ func (h *FormHandler) CreateDraftSubmission(ctx *gin.Context) {
 
 	submission, err := h.formService.CreateSubmission(ctx, input)
 	if err != nil {
-		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		h.srv.ErrorJSONResponse(ctx, submissionErrorStatus(err), h.localizeError(ctx, err))
 		return
 	}
 
@@ -1256,7 +1433,7 @@ func (h *FormHandler) SubmitFormFinal(ctx *gin.Context) {
 
 	submission, err := h.formService.CreateSubmission(ctx, input)
 	if err != nil {
-		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		h.srv.ErrorJSONResponse(ctx, submissionErrorStatus(err), h.localizeError(ctx, err))
 		return
 	}
 
@@ -1268,3 +1445,24 @@ func (h *FormHandler) SubmitFormFinal(ctx *gin.Context) {
 
 	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Form submitted successfully", response)
 }
+
+// validatePersistenceConfig checks a persistence config only writes allow-listed tables and columns
+func validatePersistenceConfig(mode string, targetJSON, mappingsJSON json.RawMessage) error {
+	targets, mappings, err := db.ParsePersistenceConfig(db.FormPersistenceConfig{
+		TargetConfigs: targetJSON,
+		FieldMappings: mappingsJSON,
+	})
+	if err != nil {
+		return err
+	}
+	return db.ValidatePersistenceConfig(mode, targets, mappings)
+}
+
+// submissionErrorStatus is the status of a failed submission. Most failures are the user's
+// input, but uploads are refused while the virus scanner is down.
+func submissionErrorStatus(err error) int {
+	if errors.Is(err, service.ErrVirusScanUnavailable) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GetFieldOptions lists the options of a field. The other query params are the values of the
+// fields it depends on, e.g. GET /forms/{id}/fields/bank/options?country=NG
+func (h *FormHandler) GetFieldOptions(ctx *gin.Context) {
+	formID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	var version int64
+	if v := ctx.Query("_version"); v != "" {
+		if version, err = strconv.ParseInt(v, 10, 32); err != nil {
+			h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, fmt.Errorf("invalid version"))
+			return
+		}
+	}
+
+	values := map[string]interface{}{}
+	for key, value := range ctx.Request.URL.Query() {
+		if key != "_version" && len(value) > 0 {
+			values[key] = value[0]
+		}
+	}
+
+	options, err := h.formService.GetFieldOptions(ctx, formID, int32(version), ctx.Param("field"), values)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.srv.ErrorJSONResponse(ctx, http.StatusNotFound, fmt.Errorf("field not found"))
+			return
+		}
+		h.srv.Logger.Error(err, map[string]interface{}{
+			"form_id": formID,
+			"field":   ctx.Param("field"),
+		})
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, fmt.Errorf("failed to load options"))
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Field options retrieved successfully", options)
+}
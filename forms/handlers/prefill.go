@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timchuks/monieverse/internal/forms/service"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// GetPrefillSources lists the user, business and KYC data fields can be prefilled from, and
+// which of it can be locked once verified
+func (h *FormHandler) GetPrefillSources(ctx *gin.Context) {
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Prefill sources retrieved successfully", service.GetPrefillSources())
+}
+
+// validatePrefill checks fields are prefilled from known sources. The fields of group items
+// are filled per item and can't be prefilled.
+func validatePrefill(v *validator.Validator, fields []FieldInput) {
+	for _, field := range fields {
+		if field.Options != nil && service.IsGroupFieldType(field.FieldType) {
+			for _, item := range field.Options.Fields {
+				v.Check(item.Prefill == nil, fmt.Sprintf("%s.%s.prefill", field.FieldName, item.FieldName),
+					"fields of group items can't be prefilled")
+			}
+		}
+
+		if field.Prefill == nil {
+			continue
+		}
+
+		err := service.ValidatePrefillConfig(field.FieldType, service.PrefillConfig{
+			Source:       field.Prefill.Source,
+			LockVerified: field.Prefill.LockVerified,
+		})
+		if err != nil {
+			v.AddError(fmt.Sprintf("%s.prefill", field.FieldName), err.Error())
+		}
+	}
+}
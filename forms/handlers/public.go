@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/forms/service"
+	"github.com/timchuks/monieverse/internal/tracking"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// publicFormRequestsPerMinute is how many requests to public forms a client IP may make per
+// minute. Forms limit their submissions further with their own hourly limits.
+const publicFormRequestsPerMinute = 20
+
+// PublicFormRateLimit limits requests to public forms per client IP, counted in the shared Redis.
+// Requests are let through when it can't be reached.
+func (h *FormHandler) PublicFormRateLimit() gin.HandlerFunc {
+	limiter := tracking.NewLimiter(tracking.NewRedisCounter(h.srv.Config), "forms:public", publicFormRequestsPerMinute, time.Minute)
+
+	return func(ctx *gin.Context) {
+		allowed, err := limiter.Allow(ctx, ctx.ClientIP())
+		if err != nil {
+			h.srv.Logger.Error(err, map[string]interface{}{
+				"ip": ctx.ClientIP(),
+			})
+		} else if !allowed {
+			h.srv.ErrorJSONResponse(ctx, http.StatusTooManyRequests, errors.New("too many requests, try again later"))
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// GetPublicForm returns a public form, by ID or slug, with the challenge to submit it
+// GET /public/forms/{id_or_slug}
+func (h *FormHandler) GetPublicForm(ctx *gin.Context) {
+	form, err := h.formService.GetPublicForm(ctx, ctx.Param("id"))
+	if err != nil {
+		h.publicFormError(ctx, err)
+		return
+	}
+	h.localizeForm(ctx, form)
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Form retrieved successfully", form)
+}
+
+// SubmitPublicForm takes an anonymous submission of a public form
+// POST /public/forms/{id_or_slug}/submit
+// Content-Type: multipart/form-data
+// Body: form fields + _challenge=<token>&_solution=<proof of work>
+func (h *FormHandler) SubmitPublicForm(ctx *gin.Context) {
+	formData, err := h.parseStandardFormData(ctx)
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+	if len(formData.Files) > 0 {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, errors.New("public forms don't accept uploads"))
+		return
+	}
+
+	challenge, _ := formData.Meta.Metadata["challenge"].(string)
+	solution, _ := formData.Meta.Metadata["solution"].(string)
+
+	result, err := h.formService.SubmitPublicForm(ctx, service.PublicSubmissionInput{
+		FormRef:   ctx.Param("id"),
+		Data:      formData.Fields,
+		Challenge: challenge,
+		Solution:  solution,
+		IPAddress: ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+	})
+	if err != nil {
+		h.publicFormError(ctx, err)
+		return
+	}
+
+	message := "Form submitted successfully"
+	if result.VerificationRequired {
+		message = "Check your email to confirm your submission"
+	}
+	h.srv.SuccessJSONResponse(ctx, http.StatusCreated, message, result)
+}
+
+// VerifyPublicSubmission confirms the email of a public submission from its verification link
+// POST /public/forms/verify
+// Body: {"token": "..."}
+func (h *FormHandler) VerifyPublicSubmission(ctx *gin.Context) {
+	var req VerifyPublicSubmissionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	submission, err := h.formService.VerifyPublicSubmission(ctx, req.Token)
+	if err != nil {
+		h.publicFormError(ctx, err)
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Submission confirmed", service.PublicSubmissionResult{
+		ID:     submission.ID,
+		Status: submission.Status,
+	})
+}
+
+func (h *FormHandler) publicFormError(ctx *gin.Context, err error) {
+	err = h.localizeError(ctx, err)
+	var validationErr *validator.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		h.srv.SendValidationError(ctx, validationErr)
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, service.ErrFormNotActive), errors.Is(err, service.ErrPublicFormNotPublic),
+		errors.Is(err, service.ErrPublicFormsDisabled):
+		h.srv.ErrorJSONResponse(ctx, http.StatusNotFound, fmt.Errorf("form not found"))
+	case errors.Is(err, service.ErrInvalidChallenge):
+		h.srv.ErrorJSONResponse(ctx, http.StatusForbidden, err)
+	case errors.Is(err, service.ErrPublicRateLimited):
+		h.srv.ErrorJSONResponse(ctx, http.StatusTooManyRequests, err)
+	case errors.Is(err, db.ErrInvalidVerificationToken):
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+	default:
+		h.srv.Logger.Error(err, map[string]interface{}{
+			"form": ctx.Param("id"),
+		})
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, errors.New("unable to process submission"))
+	}
+}
+
+// GetPublicFormAccess returns whether a form is public and the protections of its submissions
+// GET /admin/forms/{id}/public
+func (h *FormHandler) GetPublicFormAccess(ctx *gin.Context) {
+	formID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, fmt.Errorf("invalid form ID"))
+		return
+	}
+
+	access, err := h.formService.GetPublicFormAccess(ctx, formID)
+	if err != nil {
+		h.publicFormAccessError(ctx, formID, err, "unable to get public access")
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Public access retrieved successfully", access)
+}
+
+// UpdatePublicFormAccess makes a form public or private
+// PUT /admin/forms/{id}/public
+func (h *FormHandler) UpdatePublicFormAccess(ctx *gin.Context) {
+	user := h.srv.ContextGetUser(ctx)
+
+	formID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, fmt.Errorf("invalid form ID"))
+		return
+	}
+
+	var req PublicFormAccessRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	// Verification and disposable email blocking are on unless turned off
+	params := db.UpsertFormPublicAccessParams{
+		FormDefinitionID:         formID,
+		Enabled:                  req.Enabled,
+		EmailField:               req.EmailField,
+		RequireEmailVerification: req.RequireEmailVerification == nil || *req.RequireEmailVerification,
+		BlockDisposableEmails:    req.BlockDisposableEmails == nil || *req.BlockDisposableEmails,
+		BlockedEmailDomains:      req.BlockedEmailDomains,
+		HoneypotField:            req.HoneypotField,
+		ChallengeDifficulty:      req.ChallengeDifficulty,
+		HourlyLimit:              req.HourlyLimit,
+		HourlyLimitPerIP:         req.HourlyLimitPerIP,
+		UpdatedBy:                user.ID,
+	}
+	if params.BlockedEmailDomains == nil {
+		params.BlockedEmailDomains = []string{}
+	}
+
+	access, err := h.formService.UpdatePublicFormAccess(ctx, params)
+	if err != nil {
+		h.publicFormAccessError(ctx, formID, err, "unable to update public access")
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Public access updated successfully", access)
+}
+
+// ListPublicSubmissions lists the anonymous submissions of a form, with the users they were
+// linked to once their submitters signed up
+// GET /admin/forms/{id}/public/submissions?status=verified&page=1&page_size=20
+func (h *FormHandler) ListPublicSubmissions(ctx *gin.Context) {
+	formID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, fmt.Errorf("invalid form ID"))
+		return
+	}
+
+	var query PublicSubmissionsQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 || query.PageSize > 100 {
+		query.PageSize = 20
+	}
+
+	submissions, err := h.formService.ListPublicSubmissions(ctx, db.ListPublicFormSubmissionsParams{
+		FormDefinitionID: formID,
+		Status:           query.Status,
+		Limit:            int32(query.PageSize),
+		Offset:           int32((query.Page - 1) * query.PageSize),
+	})
+	if err != nil {
+		h.publicFormAccessError(ctx, formID, err, "unable to list public submissions")
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Public submissions retrieved successfully", submissions)
+}
+
+func (h *FormHandler) publicFormAccessError(ctx *gin.Context, formID uuid.UUID, err error, message string) {
+	var validationErr *validator.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		h.srv.SendValidationError(ctx, validationErr)
+	case errors.Is(err, sql.ErrNoRows):
+		h.srv.ErrorJSONResponse(ctx, http.StatusNotFound, fmt.Errorf("form not found"))
+	default:
+		h.srv.Logger.Error(err, map[string]interface{}{
+			"form_id": formID,
+		})
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, errors.New(message))
+	}
+}
@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/forms/service"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// ListRetentionPolicies lists the retention policies of a form
+// GET /admin/forms/{id}/retention
+func (h *FormHandler) ListRetentionPolicies(ctx *gin.Context) {
+	formID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, fmt.Errorf("invalid form ID"))
+		return
+	}
+
+	policies, err := h.formService.ListRetentionPolicies(ctx, formID)
+	if err != nil {
+		h.retentionError(ctx, err, "form", "unable to list retention policies")
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Retention policies retrieved successfully", policies)
+}
+
+// CreateRetentionPolicy adds a retention policy to a form
+// POST /admin/forms/{id}/retention
+// Body: {"name": "Rejected drafts", "trigger": "age", "statuses": ["draft", "rejected"], "after_days": 90, "action": "delete"}
+func (h *FormHandler) CreateRetentionPolicy(ctx *gin.Context) {
+	user := h.srv.ContextGetUser(ctx)
+
+	formID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, fmt.Errorf("invalid form ID"))
+		return
+	}
+
+	var req RetentionPolicyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	policy, err := h.formService.CreateRetentionPolicy(ctx, db.CreateFormRetentionPolicyParams{
+		FormDefinitionID: formID,
+		Name:             req.Name,
+		Trigger:          req.Trigger,
+		Statuses:         req.Statuses,
+		AfterDays:        req.AfterDays,
+		Action:           req.Action,
+		Enabled:          req.Enabled == nil || *req.Enabled,
+		CreatedBy:        user.ID,
+	})
+	if err != nil {
+		h.retentionError(ctx, err, "form", "unable to create retention policy")
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusCreated, "Retention policy created successfully", policy)
+}
+
+// UpdateRetentionPolicy changes a retention policy
+// PUT /admin/forms/retention/{policyId}
+func (h *FormHandler) UpdateRetentionPolicy(ctx *gin.Context) {
+	policyID, err := uuid.Parse(ctx.Param("policyId"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, fmt.Errorf("invalid policy ID"))
+		return
+	}
+
+	var req RetentionPolicyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	policy, err := h.formService.UpdateRetentionPolicy(ctx, db.UpdateFormRetentionPolicyParams{
+		ID:        policyID,
+		Name:      req.Name,
+		Trigger:   req.Trigger,
+		Statuses:  req.Statuses,
+		AfterDays: req.AfterDays,
+		Action:    req.Action,
+		Enabled:   req.Enabled == nil || *req.Enabled,
+	})
+	if err != nil {
+		h.retentionError(ctx, err, "policy", "unable to update retention policy")
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Retention policy updated successfully", policy)
+}
+
+// DeleteRetentionPolicy removes a retention policy
+// DELETE /admin/forms/retention/{policyId}
+func (h *FormHandler) DeleteRetentionPolicy(ctx *gin.Context) {
+	policyID, err := uuid.Parse(ctx.Param("policyId"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, fmt.Errorf("invalid policy ID"))
+		return
+	}
+
+	if err := h.formService.DeleteRetentionPolicy(ctx, policyID); err != nil {
+		h.retentionError(ctx, err, "policy", "unable to delete retention policy")
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Retention policy deleted successfully", nil)
+}
+
+// RunRetentionPolicies applies the retention policies of all forms now instead of waiting for
+// the scheduled run
+// POST /admin/forms/retention/run
+func (h *FormHandler) RunRetentionPolicies(ctx *gin.Context) {
+	result, err := h.formService.PurgeExpiredSubmissions(ctx)
+	if err != nil {
+		h.retentionError(ctx, err, "policy", "unable to apply retention policies")
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Retention policies applied", result)
+}
+
+// ListLegalHolds lists legal holds, of one user with user_id
+// GET /admin/forms/legal-holds?user_id=...&active=true&page=1&page_size=20
+func (h *FormHandler) ListLegalHolds(ctx *gin.Context) {
+	var query LegalHoldsQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+	page, pageSize := pagination(query.Page, query.PageSize)
+
+	holds, err := h.formService.ListLegalHolds(ctx, db.ListLegalHoldsParams{
+		UserID:     parseOptionalUUID(query.UserID),
+		ActiveOnly: query.Active,
+		Limit:      int32(pageSize),
+		Offset:     int32((page - 1) * pageSize),
+	})
+	if err != nil {
+		h.retentionError(ctx, err, "user", "unable to list legal holds")
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Legal holds retrieved successfully", holds)
+}
+
+// PlaceLegalHold keeps a user's data from being purged or erased until released
+// POST /admin/forms/legal-holds
+// Body: {"user_id": "...", "form_definition_id": "...", "reason": "Fraud investigation"}
+func (h *FormHandler) PlaceLegalHold(ctx *gin.Context) {
+	user := h.srv.ContextGetUser(ctx)
+
+	var req LegalHoldRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	hold, err := h.formService.PlaceLegalHold(ctx, db.CreateLegalHoldParams{
+		UserID:           uuid.MustParse(req.UserID),
+		FormDefinitionID: parseOptionalUUID(req.FormDefinitionID),
+		Reason:           req.Reason,
+		PlacedBy:         user.ID,
+	})
+	if err != nil {
+		h.retentionError(ctx, err, "user or form", "unable to place legal hold")
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusCreated, "Legal hold placed successfully", hold)
+}
+
+// ReleaseLegalHold releases a legal hold
+// POST /admin/forms/legal-holds/{holdId}/release
+func (h *FormHandler) ReleaseLegalHold(ctx *gin.Context) {
+	user := h.srv.ContextGetUser(ctx)
+
+	holdID, err := uuid.Parse(ctx.Param("holdId"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, fmt.Errorf("invalid legal hold ID"))
+		return
+	}
+
+	hold, err := h.formService.ReleaseLegalHold(ctx, holdID, user.ID)
+	if err != nil {
+		h.retentionError(ctx, err, "legal hold", "unable to release legal hold")
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Legal hold released successfully", hold)
+}
+
+// CreateDataRequest asks for a copy of the user's data or for it to be erased
+// POST /forms/data-requests
+// Body: {"type": "export|erasure"}
+func (h *FormHandler) CreateDataRequest(ctx *gin.Context) {
+	user := h.srv.ContextGetUser(ctx)
+
+	var req DataRequestRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	request, err := h.formService.RequestUserData(ctx, db.CreateDataSubjectRequestParams{
+		UserID:      user.ID,
+		Type:        req.Type,
+		RequestedBy: user.ID,
+	})
+	if err != nil {
+		h.retentionError(ctx, err, "user", "unable to create data request")
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusCreated, "Data request received", request)
+}
+
+// GetDataRequests lists the user's data requests
+// GET /forms/data-requests
+func (h *FormHandler) GetDataRequests(ctx *gin.Context) {
+	user := h.srv.ContextGetUser(ctx)
+
+	var query DataRequestsQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+	page, pageSize := pagination(query.Page, query.PageSize)
+
+	requests, err := h.formService.ListDataRequests(ctx, db.ListDataSubjectRequestsParams{
+		UserID: db.NewNullUUID(user.ID),
+		Status: query.Status,
+		Limit:  int32(pageSize),
+		Offset: int32((page - 1) * pageSize),
+	})
+	if err != nil {
+		h.retentionError(ctx, err, "user", "unable to list data requests")
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Data requests retrieved successfully", requests)
+}
+
+// DownloadDataExport returns a temporary link to the export of a completed request
+// GET /forms/data-requests/{requestId}/download
+func (h *FormHandler) DownloadDataExport(ctx *gin.Context) {
+	user := h.srv.ContextGetUser(ctx)
+
+	requestID, err := uuid.Parse(ctx.Param("requestId"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, fmt.Errorf("invalid data request ID"))
+		return
+	}
+
+	url, err := h.formService.GetDataExportURL(ctx, requestID, user.ID)
+	if err != nil {
+		h.retentionError(ctx, err, "data request", "unable to get data export")
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Data export retrieved successfully", gin.H{"url": url})
+}
+
+// ListDataRequests lists the data requests of all users, pending ones first
+// GET /admin/forms/data-requests?user_id=...&status=pending&page=1&page_size=20
+func (h *FormHandler) ListDataRequests(ctx *gin.Context) {
+	var query DataRequestsQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+	page, pageSize := pagination(query.Page, query.PageSize)
+
+	requests, err := h.formService.ListDataRequests(ctx, db.ListDataSubjectRequestsParams{
+		UserID: parseOptionalUUID(query.UserID),
+		Status: query.Status,
+		Limit:  int32(pageSize),
+		Offset: int32((page - 1) * pageSize),
+	})
+	if err != nil {
+		h.retentionError(ctx, err, "user", "unable to list data requests")
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Data requests retrieved successfully", requests)
+}
+
+// CreateUserDataRequest opens a data request on behalf of a user, e.g. one received by email
+// POST /admin/forms/data-requests
+// Body: {"user_id": "...", "type": "export|erasure"}
+func (h *FormHandler) CreateUserDataRequest(ctx *gin.Context) {
+	user := h.srv.ContextGetUser(ctx)
+
+	var req DataRequestRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+	userID := parseOptionalUUID(req.UserID)
+	if !userID.Valid {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, fmt.Errorf("user_id is required"))
+		return
+	}
+
+	request, err := h.formService.RequestUserData(ctx, db.CreateDataSubjectRequestParams{
+		UserID:      userID.UUID,
+		Type:        req.Type,
+		RequestedBy: user.ID,
+	})
+	if err != nil {
+		h.retentionError(ctx, err, "user", "unable to create data request")
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusCreated, "Data request created successfully", request)
+}
+
+// ProcessDataRequest exports or erases the data of a pending request
+// POST /admin/forms/data-requests/{requestId}/process
+func (h *FormHandler) ProcessDataRequest(ctx *gin.Context) {
+	user := h.srv.ContextGetUser(ctx)
+
+	requestID, err := uuid.Parse(ctx.Param("requestId"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, fmt.Errorf("invalid data request ID"))
+		return
+	}
+
+	request, err := h.formService.ProcessDataRequest(ctx, requestID, user.ID)
+	if err != nil {
+		h.retentionError(ctx, err, "user", "unable to process data request")
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Data request processed", request)
+}
+
+func (h *FormHandler) retentionError(ctx *gin.Context, err error, missing, message string) {
+	var validationErr *validator.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		h.srv.SendValidationError(ctx, validationErr)
+	case errors.Is(err, sql.ErrNoRows):
+		h.srv.ErrorJSONResponse(ctx, http.StatusNotFound, fmt.Errorf("%s not found", missing))
+	case errors.Is(err, db.ErrRetentionPolicyNotFound), errors.Is(err, db.ErrLegalHoldNotFound),
+		errors.Is(err, db.ErrDataRequestNotFound), errors.Is(err, service.ErrDataExportUnavailable):
+		h.srv.ErrorJSONResponse(ctx, http.StatusNotFound, err)
+	case errors.Is(err, service.ErrDataRequestProcessed), errors.Is(err, service.ErrRetentionPurgeRunning):
+		h.srv.ErrorJSONResponse(ctx, http.StatusConflict, err)
+	default:
+		h.srv.Logger.Error(err, map[string]interface{}{
+			"path": ctx.FullPath(),
+		})
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, errors.New(message))
+	}
+}
+
+// pagination returns the page and page size of a query, 1 and 20 when unset or out of range
+func pagination(page, pageSize int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	return page, pageSize
+}
+
+// parseOptionalUUID parses an ID that may be left out; binding has already checked its format
+func parseOptionalUUID(value string) uuid.NullUUID {
+	id, err := uuid.Parse(value)
+	return uuid.NullUUID{UUID: id, Valid: err == nil}
+}
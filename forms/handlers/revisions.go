@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/forms/service"
+)
+
+// expectedRevision is the revision a save was based on, from the If-Match header or, for
+// multipart forms that can't set headers, the _revision field. 0 means no check, which is
+// also what If-Match: * asks for.
+func expectedRevision(ctx *gin.Context, data map[string]interface{}) (int32, error) {
+	value := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if formValue, ok := data["_revision"]; ok {
+		delete(data, "_revision")
+		if value == "" {
+			value = fmt.Sprint(formValue)
+		}
+	}
+
+	value = strings.Trim(strings.TrimPrefix(value, "W/"), `"`)
+	if value == "" || value == "*" {
+		return 0, nil
+	}
+
+	revision, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || revision < 1 {
+		return 0, fmt.Errorf("invalid revision %q", value)
+	}
+	return int32(revision), nil
+}
+
+// setRevisionETag tells the client the revision to send back with its next save
+func setRevisionETag(ctx *gin.Context, revision int32) {
+	ctx.Header("ETag", fmt.Sprintf(`"%d"`, revision))
+}
+
+// revisionConflict responds with 409 and the fields changed since the client's revision when
+// err is a revision conflict
+func (h *FormHandler) revisionConflict(ctx *gin.Context, err error) bool {
+	var conflict *db.RevisionConflictError
+	if !errors.As(err, &conflict) {
+		return false
+	}
+
+	setRevisionETag(ctx, conflict.CurrentRevision)
+	ctx.JSON(http.StatusConflict, gin.H{
+		"status":  "error",
+		"message": conflict.Error(),
+		"data":    conflict,
+	})
+	return true
+}
+
+// AutosaveSubmission saves a field-level patch of a draft
+func (h *FormHandler) AutosaveSubmission(ctx *gin.Context) {
+	user := h.srv.ContextGetUser(ctx)
+
+	submissionID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	var req AutosaveRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	base := req.Revision
+	if ctx.GetHeader("If-Match") != "" {
+		if base, err = expectedRevision(ctx, nil); err != nil {
+			h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	submission, err := h.formService.AutosaveSubmission(ctx, service.AutosaveInput{
+		SubmissionID: submissionID,
+		UserID:       user.ID,
+		BaseRevision: base,
+		Set:          req.Set,
+		Unset:        req.Unset,
+	})
+	if err != nil {
+		if h.revisionConflict(ctx, err) {
+			return
+		}
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, h.localizeError(ctx, err))
+		return
+	}
+
+	setRevisionETag(ctx, submission.Revision)
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Draft saved", map[string]interface{}{
+		"id":         submission.ID,
+		"revision":   submission.Revision,
+		"updated_at": submission.UpdatedAt,
+	})
+}
+
+// ListSubmissionRevisions lists the revision history of a submission, newest first
+func (h *FormHandler) ListSubmissionRevisions(ctx *gin.Context) {
+	user := h.srv.ContextGetUser(ctx)
+
+	submissionID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	revisions, err := h.formService.ListSubmissionRevisions(ctx, submissionID, user.ID)
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Revisions retrieved successfully", revisions)
+}
+
+// RestoreSubmissionRevision makes the data of an earlier revision current again
+func (h *FormHandler) RestoreSubmissionRevision(ctx *gin.Context) {
+	user := h.srv.ContextGetUser(ctx)
+
+	submissionID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	revision, err := strconv.ParseInt(ctx.Param("revision"), 10, 32)
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	expected, err := expectedRevision(ctx, nil)
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	submission, err := h.formService.RestoreSubmissionRevision(ctx, service.RestoreRevisionInput{
+		SubmissionID:     submissionID,
+		UserID:           user.ID,
+		Revision:         int32(revision),
+		ExpectedRevision: expected,
+	})
+	if err != nil {
+		if h.revisionConflict(ctx, err) {
+			return
+		}
+		if errors.Is(err, db.ErrRevisionNotFound) {
+			h.srv.ErrorJSONResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	setRevisionETag(ctx, submission.Revision)
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Revision restored successfully", submission)
+}
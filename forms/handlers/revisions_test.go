@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+)
+
+func revisionTestContext(ifMatch string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest(http.MethodPut, "/", nil)
+	if ifMatch != "" {
+		ctx.Request.Header.Set("If-Match", ifMatch)
+	}
+	return ctx, w
+}
+
+func TestExpectedRevision(t *testing.T) {
+	for header, want := range map[string]int32{
+		"":             0,
+		"*":            0,
+		`"7"`:          7,
+		`W/"7"`:        7,
+		" 12 ":         12,
+		`"2147483647"`: 2147483647,
+	} {
+		ctx, _ := revisionTestContext(header)
+		got, err := expectedRevision(ctx, nil)
+		require.NoError(t, err, header)
+		require.Equal(t, want, got, header)
+	}
+
+	for _, header := range []string{`"0"`, `"-1"`, `"abc"`, `"2147483648"`} {
+		ctx, _ := revisionTestContext(header)
+		_, err := expectedRevision(ctx, nil)
+		require.Error(t, err, header)
+	}
+
+	// Multipart saves send the revision as a form field, which If-Match overrides
+	ctx, _ := revisionTestContext("")
+	data := map[string]interface{}{"_revision": "4", "business_name": "Acme"}
+	got, err := expectedRevision(ctx, data)
+	require.NoError(t, err)
+	require.Equal(t, int32(4), got)
+	require.NotContains(t, data, "_revision")
+
+	ctx, _ = revisionTestContext(`"6"`)
+	data = map[string]interface{}{"_revision": "4"}
+	got, err = expectedRevision(ctx, data)
+	require.NoError(t, err)
+	require.Equal(t, int32(6), got)
+	require.Empty(t, data)
+}
+
+func TestRevisionConflict(t *testing.T) {
+	h := &FormHandler{}
+
+	ctx, w := revisionTestContext("")
+	require.False(t, h.revisionConflict(ctx, errors.New("access denied")))
+	require.Empty(t, w.Body.String())
+
+	submissionID := uuid.New()
+	ctx, w = revisionTestContext(`"3"`)
+	err := fmt.Errorf("failed to update submission: %w", &db.RevisionConflictError{
+		SubmissionID:     submissionID,
+		ExpectedRevision: 3,
+		CurrentRevision:  5,
+		Changes: []db.FieldChange{
+			{Field: "business_name", Base: "Acme", Current: "Acme Ltd", Yours: "Acme Limited"},
+			{Field: "website", Base: "acme.ng"},
+		},
+	})
+	require.True(t, h.revisionConflict(ctx, err))
+	require.Equal(t, http.StatusConflict, w.Code)
+	require.Equal(t, `"5"`, w.Header().Get("ETag"))
+
+	var body struct {
+		Status string                   `json:"status"`
+		Data   db.RevisionConflictError `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Equal(t, "error", body.Status)
+	require.Equal(t, submissionID, body.Data.SubmissionID)
+	require.Equal(t, int32(3), body.Data.ExpectedRevision)
+	require.Equal(t, int32(5), body.Data.CurrentRevision)
+	require.Equal(t, []db.FieldChange{
+		{Field: "business_name", Base: "Acme", Current: "Acme Ltd", Yours: "Acme Limited"},
+		{Field: "website", Base: "acme.ng"},
+	}, body.Data.Changes)
+}
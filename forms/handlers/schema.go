@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/forms/service"
+)
+
+// GetFormSchema returns the JSON Schema of the current version of an active form, for clients
+// and integrators to generate types from and check payloads against before submitting
+// GET /forms/{id}/schema
+func (h *FormHandler) GetFormSchema(ctx *gin.Context) {
+	h.formSchema(ctx, true)
+}
+
+// AdminGetFormSchema returns the JSON Schema of any version of a form
+// GET /admin/forms/{id}/schema?version=2
+func (h *FormHandler) AdminGetFormSchema(ctx *gin.Context) {
+	h.formSchema(ctx, false)
+}
+
+// GetFormOpenAPI returns an OpenAPI document of the submission endpoints of an active form
+// GET /forms/{id}/openapi
+func (h *FormHandler) GetFormOpenAPI(ctx *gin.Context) {
+	h.formOpenAPI(ctx, true)
+}
+
+// AdminGetFormOpenAPI returns the OpenAPI document of any version of a form
+// GET /admin/forms/{id}/openapi?version=2
+func (h *FormHandler) AdminGetFormOpenAPI(ctx *gin.Context) {
+	h.formOpenAPI(ctx, false)
+}
+
+func (h *FormHandler) formSchema(ctx *gin.Context, activeOnly bool) {
+	formID, version, ok := h.parseSchemaParams(ctx, activeOnly)
+	if !ok {
+		return
+	}
+
+	schema, err := h.formService.GetFormJSONSchema(ctx, formID, version, activeOnly)
+	if err != nil {
+		h.formSchemaError(ctx, formID, err)
+		return
+	}
+
+	ctx.Header("Content-Type", "application/schema+json")
+	ctx.JSON(http.StatusOK, schema)
+}
+
+func (h *FormHandler) formOpenAPI(ctx *gin.Context, activeOnly bool) {
+	formID, version, ok := h.parseSchemaParams(ctx, activeOnly)
+	if !ok {
+		return
+	}
+
+	doc, err := h.formService.GetFormOpenAPI(ctx, formID, version, activeOnly, formRoutesBasePath(ctx.FullPath()))
+	if err != nil {
+		h.formSchemaError(ctx, formID, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, doc)
+}
+
+// parseSchemaParams reads the form ID and, for admins, the version to describe. 0 is the current version.
+func (h *FormHandler) parseSchemaParams(ctx *gin.Context, currentOnly bool) (uuid.UUID, int32, bool) {
+	formID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, fmt.Errorf("invalid form ID"))
+		return uuid.Nil, 0, false
+	}
+
+	if currentOnly || ctx.Query("version") == "" {
+		return formID, 0, true
+	}
+	version, err := strconv.ParseInt(ctx.Query("version"), 10, 32)
+	if err != nil || version < 1 {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, fmt.Errorf("invalid version"))
+		return uuid.Nil, 0, false
+	}
+	return formID, int32(version), true
+}
+
+func (h *FormHandler) formSchemaError(ctx *gin.Context, formID uuid.UUID, err error) {
+	switch {
+	case errors.Is(err, db.ErrFormVersionNotFound):
+		h.srv.ErrorJSONResponse(ctx, http.StatusNotFound, err)
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, service.ErrFormNotActive):
+		h.srv.ErrorJSONResponse(ctx, http.StatusNotFound, fmt.Errorf("form not found"))
+	default:
+		h.srv.Logger.Error(err, map[string]interface{}{
+			"form_id": formID,
+		})
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, errors.New("unable to describe form"))
+	}
+}
+
+// formRoutesBasePath is where the form routes are mounted, from the route of the request, e.g.
+// /api/v1 for /api/v1/admin/forms/:id/openapi
+func formRoutesBasePath(route string) string {
+	for _, group := range []string{"/admin/forms/", "/forms/"} {
+		if i := strings.Index(route, group); i >= 0 {
+			return route[:i]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/forms/service"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// maxTranslationsFileSize bounds the size of an imported translations file
+const maxTranslationsFileSize = 5 << 20
+
+// localizable is a form whose text can be kept in one language
+type localizable interface {
+	Localize(lang string)
+}
+
+// requestLanguage returns the language a client asked for with the lang query parameter or
+// the Accept-Language header, and whether it asked for one. Clients that don't ask get the
+// text of forms in every language, as before languages were negotiated.
+func (h *FormHandler) requestLanguage(ctx *gin.Context) (string, bool) {
+	if lang, ok := service.SupportedLanguage(ctx.Query("lang")); ok {
+		return lang, true
+	}
+	if header := ctx.GetHeader("Accept-Language"); header != "" {
+		return service.NegotiateLanguage(header), true
+	}
+	return service.DefaultLanguage, false
+}
+
+// localizeForm keeps the text of a form in the language the client asked for
+func (h *FormHandler) localizeForm(ctx *gin.Context, form localizable) {
+	lang, ok := h.requestLanguage(ctx)
+	if !ok {
+		return
+	}
+	ctx.Header("Content-Language", lang)
+	ctx.Header("Vary", "Accept-Language")
+	form.Localize(lang)
+}
+
+// localizeError translates validation errors into the language the client asked for
+func (h *FormHandler) localizeError(ctx *gin.Context, err error) error {
+	lang, _ := h.requestLanguage(ctx)
+	return service.LocalizeError(err, lang)
+}
+
+// ListMissingTranslations lists the text of a form lacking translations
+// GET /admin/forms/{id}/translations/missing?languages=fr,zh&version=2
+func (h *FormHandler) ListMissingTranslations(ctx *gin.Context) {
+	formID, version, ok := h.parseSchemaParams(ctx, false)
+	if !ok {
+		return
+	}
+
+	missing, err := h.formService.MissingTranslations(ctx, formID, version, parseLanguages(ctx.Query("languages")))
+	if err != nil {
+		h.translationError(ctx, formID, err)
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Missing translations retrieved successfully", missing)
+}
+
+// ExportTranslations downloads the text of a form as CSV, a column for each language
+// GET /admin/forms/{id}/translations/export?languages=en,fr,zh&version=2
+func (h *FormHandler) ExportTranslations(ctx *gin.Context) {
+	formID, version, ok := h.parseSchemaParams(ctx, false)
+	if !ok {
+		return
+	}
+
+	// Buffered so a failed download is still reported as an error
+	var file bytes.Buffer
+	if err := h.formService.ExportTranslations(ctx, formID, version, parseLanguages(ctx.Query("languages")), &file); err != nil {
+		h.translationError(ctx, formID, err)
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "translations-"+formID.String()+".csv"))
+	ctx.Data(http.StatusOK, "text/csv; charset=utf-8", file.Bytes())
+}
+
+// ImportTranslations merges the translations of a CSV export into the text of a form. Empty
+// cells leave the text as it is.
+// POST /admin/forms/{id}/translations/import?version=2
+// Content-Type: text/csv, or multipart/form-data with the CSV in file
+func (h *FormHandler) ImportTranslations(ctx *gin.Context) {
+	formID, version, ok := h.parseSchemaParams(ctx, false)
+	if !ok {
+		return
+	}
+
+	var body io.Reader = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxTranslationsFileSize)
+	if strings.HasPrefix(ctx.ContentType(), "multipart/") {
+		header, err := ctx.FormFile("file")
+		if err != nil {
+			h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
+		defer file.Close()
+		body = io.LimitReader(file, maxTranslationsFileSize)
+	}
+
+	translations, err := service.ReadTranslationsCSV(body)
+	if err != nil {
+		h.translationError(ctx, formID, err)
+		return
+	}
+
+	result, err := h.formService.ImportTranslations(ctx, formID, version, translations)
+	if err != nil {
+		h.translationError(ctx, formID, err)
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Translations imported successfully", result)
+}
+
+// parseLanguages splits a comma separated list of languages
+func parseLanguages(query string) []string {
+	var languages []string
+	for _, lang := range strings.Split(query, ",") {
+		if lang = strings.TrimSpace(lang); lang != "" {
+			languages = append(languages, lang)
+		}
+	}
+	return languages
+}
+
+func (h *FormHandler) translationError(ctx *gin.Context, formID uuid.UUID, err error) {
+	var validationErr *validator.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		h.srv.SendValidationError(ctx, validationErr)
+	case errors.Is(err, service.ErrInvalidTranslations):
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+	case errors.Is(err, db.ErrFormVersionNotFound):
+		h.srv.ErrorJSONResponse(ctx, http.StatusNotFound, err)
+	case errors.Is(err, sql.ErrNoRows):
+		h.srv.ErrorJSONResponse(ctx, http.StatusNotFound, fmt.Errorf("form not found"))
+	default:
+		h.srv.Logger.Error(err, map[string]interface{}{
+			"form_id": formID,
+		})
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, errors.New("unable to process translations"))
+	}
+}
@@ -22,6 +22,19 @@ type CreateFormRequest struct {
 type ApprovalWorkflowInput struct {
 	States      []ApprovalStateInput `json:"states"`
 	Transitions []TransitionInput    `json:"transitions"`
+	Stages      []ApprovalStageInput `json:"stages"`
+}
+
+// ApprovalStageInput is a stage of a submission's approval. Stages run in ascending order;
+// stages with the same order run in parallel.
+type ApprovalStageInput struct {
+	Name              string `json:"name"`
+	Label             string `json:"label"`
+	Order             int32  `json:"order"`
+	Permission        string `json:"permission"`
+	RequiredApprovals int32  `json:"required_approvals"`
+	SLAHours          int32  `json:"sla_hours"`
+	EscalateTo        string `json:"escalate_to"`
 }
 
 type ApprovalStateInput struct {
@@ -39,10 +52,11 @@ type TransitionInput struct {
 }
 
 type StepInput struct {
-	StepNumber  int    `json:"step_number"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	IsOptional  bool   `json:"is_optional"`
+	StepNumber      int                    `json:"step_number"`
+	Name            string                 `json:"name"`
+	Description     string                 `json:"description"`
+	IsOptional      bool                   `json:"is_optional"`
+	ValidationRules map[string]interface{} `json:"validation_rules,omitempty"`
 }
 
 type FieldInput struct {
@@ -60,12 +74,15 @@ type FieldInput struct {
 	DefaultValue     *string                `json:"default_value,omitempty"`
 	ConditionalLogic *ConditionalLogicInput `json:"conditional_logic,omitempty"`
 	FileConfig       *FileConfigInput       `json:"file_config,omitempty"`
+	Prefill          *PrefillInput          `json:"prefill,omitempty"`
 }
 
 type OptionsInput struct {
 	Type    string              `json:"type"`
 	Static  []OptionInput       `json:"static,omitempty"`
 	Dynamic *DynamicSourceInput `json:"dynamic,omitempty"`
+	// Fields are the fields of each item of a group field
+	Fields []FieldInput `json:"fields,omitempty"`
 }
 
 type OptionInput struct {
@@ -79,9 +96,16 @@ type DynamicSourceInput struct {
 }
 
 type ConditionalLogicInput struct {
-	Action     string           `json:"action"`
-	Conditions []ConditionInput `json:"conditions"`
-	Logic      string           `json:"logic"`
+	Action     string                `json:"action"`
+	Conditions []ConditionInput      `json:"conditions"`
+	Logic      string                `json:"logic"`
+	Groups     []ConditionGroupInput `json:"groups,omitempty"`
+}
+
+type ConditionGroupInput struct {
+	Conditions []ConditionInput      `json:"conditions"`
+	Logic      string                `json:"logic"`
+	Groups     []ConditionGroupInput `json:"groups,omitempty"`
 }
 
 type ConditionInput struct {
@@ -96,6 +120,13 @@ type FileConfigInput struct {
 	MaxFiles     int      `json:"max_files,omitempty"`
 }
 
+// PrefillInput fills a field from the user's data, e.g. user.first_name or
+// identity_verification_data.date_of_birth, locking it once the data is verified
+type PrefillInput struct {
+	Source       string `json:"source"`
+	LockVerified bool   `json:"lock_verified,omitempty"`
+}
+
 type PersistenceConfigInput struct {
 	PersistenceMode     string                       `json:"persistence_mode"`
 	TargetConfigs       []TargetConfigInput          `json:"target_configs"`
@@ -108,6 +139,7 @@ type TargetConfigInput struct {
 	TableName  string                 `json:"table_name"`
 	Conditions map[string]interface{} `json:"conditions,omitempty"`
 	Priority   int                    `json:"priority"`
+	UpsertKey  []string               `json:"upsert_key,omitempty"`
 }
 
 type FieldMappingInput struct {
@@ -118,6 +150,10 @@ type FieldMappingInput struct {
 	Transform   *string `json:"transform,omitempty"`
 	IsEncrypted bool    `json:"is_encrypted"`
 	MetaKey     string  `json:"meta_key,omitempty"`
+	// ItemMappings maps the fields of a group's items to columns of TableName, one row per item
+	ItemMappings map[string]string `json:"item_mappings,omitempty"`
+	ParentTable  string            `json:"parent_table,omitempty"`
+	ParentColumn string            `json:"parent_column,omitempty"`
 }
 
 type ValidationHookInput struct {
@@ -134,6 +170,28 @@ type UpdateFormRequest struct {
 	ApprovalWorkflow          *ApprovalWorkflowInput `json:"approval_workflow,omitempty"`
 }
 
+// SaveFormDraftRequest stages a new version of a form. Omitting fields keeps the current
+// steps and fields and only changes the form's settings.
+type SaveFormDraftRequest struct {
+	Name                      string                  `json:"name" binding:"required"`
+	Description               string                  `json:"description"`
+	IsMultiStep               bool                    `json:"is_multi_step"`
+	RequiresApproval          bool                    `json:"requires_approval"`
+	IsEditableAfterSubmission bool                    `json:"is_editable_after_submission"`
+	ApprovalWorkflow          *ApprovalWorkflowInput  `json:"approval_workflow,omitempty"`
+	Steps                     []StepInput             `json:"steps,omitempty"`
+	Fields                    []FieldInput            `json:"fields,omitempty"`
+	PersistenceConfig         *PersistenceConfigInput `json:"persistence_config,omitempty"`
+	ChangeNote                string                  `json:"change_note"`
+}
+
+// MigrateSubmissionsRequest moves draft submissions from an older version to the published one.
+// FieldMappings maps old field names to new ones; an empty name drops the answer.
+type MigrateSubmissionsRequest struct {
+	FromVersion   int32             `json:"from_version" binding:"required"`
+	FieldMappings map[string]string `json:"field_mappings"`
+}
+
 // CreateAssignmentRequest for form assignments
 type CreateAssignmentRequest struct {
 	AssignmentType  string                 `json:"assignment_type" binding:"required"`
@@ -158,3 +216,103 @@ type ApprovalRequest struct {
 	Notes  string `json:"notes"`
 	Reason string `json:"reason"`
 }
+
+// RequestChangesRequest sends a submission back to the user with per-field comments
+type RequestChangesRequest struct {
+	Comment       string            `json:"comment"`
+	FieldComments map[string]string `json:"field_comments"`
+}
+
+// FormAnalyticsQuery filters form analytics. Dates are YYYY-MM-DD and both inclusive; a zero
+// version covers every version.
+type FormAnalyticsQuery struct {
+	From               string `form:"from"`
+	To                 string `form:"to"`
+	Version            int32  `form:"version"`
+	AbandonedAfterDays int    `form:"abandoned_after_days"`
+}
+
+// ImportFormBundleQuery controls a form bundle import. Map holds old=new pairs of IDs from the
+// source environment to replace, such as the users of user_id assignments.
+type ImportFormBundleQuery struct {
+	Format     string   `form:"format" binding:"omitempty,oneof=json yaml"`
+	OnConflict string   `form:"on_conflict" binding:"omitempty,oneof=fail skip new_version"`
+	DryRun     bool     `form:"dry_run"`
+	Map        []string `form:"map"`
+	ChangeNote string   `form:"change_note"`
+}
+
+// AutosaveRequest patches fields of a draft. Revision is the revision the patch is based on,
+// when it isn't sent in an If-Match header.
+type AutosaveRequest struct {
+	Set      map[string]interface{} `json:"set"`
+	Unset    []string               `json:"unset"`
+	Revision int32                  `json:"revision"`
+}
+
+// PublicFormAccessRequest makes a form public or private and sets the protections of its
+// anonymous submissions. Limits are per hour; 0 is unlimited.
+type PublicFormAccessRequest struct {
+	Enabled                  bool     `json:"enabled"`
+	EmailField               string   `json:"email_field"`
+	RequireEmailVerification *bool    `json:"require_email_verification"`
+	BlockDisposableEmails    *bool    `json:"block_disposable_emails"`
+	BlockedEmailDomains      []string `json:"blocked_email_domains"`
+	HoneypotField            string   `json:"honeypot_field"`
+	ChallengeDifficulty      int32    `json:"challenge_difficulty"`
+	HourlyLimit              int32    `json:"hourly_limit"`
+	HourlyLimitPerIP         int32    `json:"hourly_limit_per_ip"`
+}
+
+// PublicSubmissionsQuery pages through the public submissions of a form
+type PublicSubmissionsQuery struct {
+	Status   string `form:"status" binding:"omitempty,oneof=pending_verification verified"`
+	Page     int    `form:"page"`
+	PageSize int    `form:"page_size"`
+}
+
+// VerifyPublicSubmissionRequest carries the token of a verification link
+type VerifyPublicSubmissionRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// RetentionPolicyRequest creates or updates a retention policy. Statuses limit it to
+// submissions with those statuses or approval statuses; it is enabled unless turned off.
+type RetentionPolicyRequest struct {
+	Name      string   `json:"name" binding:"required"`
+	Trigger   string   `json:"trigger" binding:"required,oneof=age account_closed"`
+	Statuses  []string `json:"statuses"`
+	AfterDays int32    `json:"after_days" binding:"required"`
+	Action    string   `json:"action" binding:"required,oneof=delete anonymize"`
+	Enabled   *bool    `json:"enabled"`
+}
+
+// LegalHoldRequest places a legal hold on a user's data, or on their submissions of one form
+type LegalHoldRequest struct {
+	UserID           string `json:"user_id" binding:"required,uuid"`
+	FormDefinitionID string `json:"form_definition_id" binding:"omitempty,uuid"`
+	Reason           string `json:"reason" binding:"required"`
+}
+
+// LegalHoldsQuery pages through legal holds
+type LegalHoldsQuery struct {
+	UserID   string `form:"user_id" binding:"omitempty,uuid"`
+	Active   bool   `form:"active"`
+	Page     int    `form:"page"`
+	PageSize int    `form:"page_size"`
+}
+
+// DataRequestRequest asks for a copy of the user's data, for it to be erased or for the user's
+// account to be closed. Admins opening a request on behalf of a user name them.
+type DataRequestRequest struct {
+	UserID string `json:"user_id" binding:"omitempty,uuid"`
+	Type   string `json:"type" binding:"required,oneof=export erasure closure"`
+}
+
+// DataRequestsQuery pages through data requests
+type DataRequestsQuery struct {
+	UserID   string `form:"user_id" binding:"omitempty,uuid"`
+	Status   string `form:"status" binding:"omitempty,oneof=pending completed held"`
+	Page     int    `form:"page"`
+	PageSize int    `form:"page_size"`
+}
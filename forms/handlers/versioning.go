@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// SaveFormDraft creates or replaces the draft version of a form
+func (h *FormHandler) SaveFormDraft(ctx *gin.Context) {
+	user := h.srv.ContextGetUser(ctx)
+
+	formID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	var req SaveFormDraftRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	form, err := h.srv.Store.GetFormDefinition(ctx, formID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.srv.ErrorJSONResponse(ctx, http.StatusNotFound, fmt.Errorf("form not found"))
+			return
+		}
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	v := validator.New()
+	validateConditionalLogic(v, req.Fields)
+	validateGroupFields(v, req.Fields)
+	validateFieldTypes(v, req.Fields)
+	validatePrefill(v, req.Fields)
+	h.validateCustomRules(v, req.Steps, req.Fields)
+	validateApprovalWorkflow(v, form.FormType, req.RequiresApproval, req.ApprovalWorkflow)
+	if !v.Valid() {
+		h.srv.SendValidationError(ctx, validator.NewValidationError("validation failed", v.Errors))
+		return
+	}
+
+	input := formDefinitionInput(CreateFormRequest{
+		Name:                      req.Name,
+		Description:               req.Description,
+		IsMultiStep:               req.IsMultiStep,
+		RequiresApproval:          req.RequiresApproval,
+		IsEditableAfterSubmission: req.IsEditableAfterSubmission,
+		ApprovalWorkflow:          req.ApprovalWorkflow,
+		Steps:                     req.Steps,
+		Fields:                    req.Fields,
+		PersistenceConfig:         req.PersistenceConfig,
+	}, user.ID)
+
+	draft, err := h.srv.Store.SaveFormDraftVersionTx(ctx, &db.FormDraftVersionInput{
+		FormDefinitionID: formID,
+		Definition:       input,
+		ChangeNote:       req.ChangeNote,
+	})
+	if err != nil {
+		h.srv.Logger.Error(err, map[string]interface{}{
+			"form_id": formID,
+		})
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Draft version saved successfully", draft)
+}
+
+// ListFormVersions lists the versions of a form, newest first
+func (h *FormHandler) ListFormVersions(ctx *gin.Context) {
+	formID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	versions, err := h.srv.Store.ListFormVersions(ctx, formID)
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Form versions retrieved successfully", versions)
+}
+
+// GetFormVersion retrieves a version of a form with its steps and fields
+func (h *FormHandler) GetFormVersion(ctx *gin.Context) {
+	formID, version, ok := h.parseFormVersionParams(ctx)
+	if !ok {
+		return
+	}
+
+	detail, err := h.formService.GetFormVersion(ctx, formID, version)
+	if err != nil {
+		h.formVersionError(ctx, err)
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Form version retrieved successfully", detail)
+}
+
+// DiffFormVersions compares two versions of a form
+// GET /admin/forms/{id}/diff?from=1&to=2
+func (h *FormHandler) DiffFormVersions(ctx *gin.Context) {
+	formID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	from, fromErr := strconv.ParseInt(ctx.Query("from"), 10, 32)
+	to, toErr := strconv.ParseInt(ctx.Query("to"), 10, 32)
+
+	v := validator.New()
+	v.Check(fromErr == nil && from > 0, "from", "must be a version number")
+	v.Check(toErr == nil && to > 0, "to", "must be a version number")
+	if !v.Valid() {
+		h.srv.SendValidationError(ctx, validator.NewValidationError("validation failed", v.Errors))
+		return
+	}
+
+	diff, err := h.formService.DiffFormVersions(ctx, formID, int32(from), int32(to))
+	if err != nil {
+		h.formVersionError(ctx, err)
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Form versions compared successfully", diff)
+}
+
+// PublishFormVersion makes a draft version the current version of the form
+func (h *FormHandler) PublishFormVersion(ctx *gin.Context) {
+	user := h.srv.ContextGetUser(ctx)
+
+	formID, version, ok := h.parseFormVersionParams(ctx)
+	if !ok {
+		return
+	}
+
+	form, err := h.formService.PublishFormVersion(ctx, formID, version, user.ID)
+	if err != nil {
+		h.formVersionError(ctx, err)
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Form version published successfully", form)
+}
+
+// MigrateFormSubmissions moves draft submissions from an older version to the published version
+func (h *FormHandler) MigrateFormSubmissions(ctx *gin.Context) {
+	formID, version, ok := h.parseFormVersionParams(ctx)
+	if !ok {
+		return
+	}
+
+	var req MigrateSubmissionsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	migrated, err := h.formService.MigrateFormSubmissions(ctx, db.FormSubmissionMigrationInput{
+		FormDefinitionID: formID,
+		FromVersion:      req.FromVersion,
+		ToVersion:        version,
+		FieldMappings:    req.FieldMappings,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrFormVersionNotFound) {
+			h.srv.ErrorJSONResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+		h.srv.ErrorJSONResponse(ctx, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.srv.SuccessJSONResponse(ctx, http.StatusOK, "Submissions migrated successfully", map[string]interface{}{
+		"migrated": migrated,
+	})
+}
+
+func (h *FormHandler) parseFormVersionParams(ctx *gin.Context) (uuid.UUID, int32, bool) {
+	formID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return uuid.Nil, 0, false
+	}
+
+	version, err := strconv.ParseInt(ctx.Param("version"), 10, 32)
+	if err != nil || version < 1 {
+		h.srv.ErrorJSONResponse(ctx, http.StatusBadRequest, fmt.Errorf("invalid version"))
+		return uuid.Nil, 0, false
+	}
+
+	return formID, int32(version), true
+}
+
+func (h *FormHandler) formVersionError(ctx *gin.Context, err error) {
+	var validationErr *validator.ValidationError
+	switch {
+	case errors.Is(err, db.ErrFormVersionNotFound), errors.Is(err, sql.ErrNoRows):
+		h.srv.ErrorJSONResponse(ctx, http.StatusNotFound, db.ErrFormVersionNotFound)
+	case errors.Is(err, db.ErrFormVersionNotDraft):
+		h.srv.ErrorJSONResponse(ctx, http.StatusConflict, err)
+	case errors.As(err, &validationErr):
+		h.srv.SendValidationError(ctx, validationErr)
+	default:
+		h.srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+	}
+}
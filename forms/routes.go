@@ -3,22 +3,45 @@ package forms
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/timchuks/monieverse/core/server"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
 	"github.com/timchuks/monieverse/internal/forms/handlers"
 	"github.com/timchuks/monieverse/internal/forms/service"
 )
 
-func RegisterFormRoutes(r *gin.RouterGroup, srv *server.Server) {
-	formService := service.NewFormService(
+// NewFormService builds the form service of the server. The worker builds one too, to run the
+// periodic form jobs of service.FormJobs with RunFormJob.
+func NewFormService(srv *server.Server) *service.FormService {
+	var virusScanner service.VirusScanner = service.NewNullVirusScanner()
+	if srv.Config.ClamAVAddress != "" {
+		clamav := service.NewClamAVScanner(srv.Config.ClamAVAddress, srv.Config.ClamAVTimeout)
+		if err := clamav.Ping(); err != nil {
+			srv.Logger.Error(err, map[string]interface{}{
+				"message": "clamd is not reachable, uploads will fail to scan until it is",
+			})
+		}
+		virusScanner = clamav
+	}
+
+	if err := db.LoadFieldEncryptionKeys(srv.Config.FormEncryptionKeys); err != nil {
+		srv.Logger.Error(err, map[string]interface{}{
+			"message": "invalid FORM_ENCRYPTION_KEYS, encrypted form fields can't be persisted",
+		})
+	}
+
+	return service.NewFormService(
 		srv.Store,
 		srv.Uploader,
 		nil,
 		srv.TaskDistributor,
 		&srv.Config,
-		service.NewNullVirusScanner(),
+		virusScanner,
 		service.CreateContentValidator(),
 		srv.Logger,
 	)
+}
 
+func RegisterFormRoutes(r *gin.RouterGroup, srv *server.Server) {
+	formService := NewFormService(srv)
 	handler := handlers.NewFormHandler(srv, formService)
 
 	// User routes
@@ -28,6 +51,9 @@ func RegisterFormRoutes(r *gin.RouterGroup, srv *server.Server) {
 	//srv.Idempotency(ratelimiter.OperationTypeFormSubmit, nil),
 	userRoutes.GET("/", handler.GetUserForm) // ?type=kyc|kyb|contact etc
 
+	// Rich field types with the phone plans and identity numbers they are checked against
+	userRoutes.GET("/field-types", handler.GetFieldTypes)
+
 	// CREATE: Start new form submission as draft
 	// POST /forms/{form_id}/draft
 	// Content-Type: multipart/form-data
@@ -52,18 +78,55 @@ func RegisterFormRoutes(r *gin.RouterGroup, srv *server.Server) {
 	// UPDATE: Update entire submission
 	// PUT /submissions/{id}
 	// Content-Type: multipart/form-data
+	// If-Match: "3" (or _revision=3); a stale revision gets a 409 with the fields changed since
 	// Body: updated_fields + files + _status=draft|submitted + _partial=true|false
 	userRoutes.PUT("/submissions/:id",
 		handler.UpdateFormSubmission)
 
+	// UPDATE: Autosave a draft with a field-level patch. A patch based on an older revision
+	// still applies unless it touches fields changed since; otherwise it gets a 409 with them.
+	// PATCH /submissions/{id}/autosave
+	// If-Match: "3"
+	// Body: {"set": {"business_name": "Acme"}, "unset": ["website"]}
+	userRoutes.PATCH("/submissions/:id/autosave", handler.AutosaveSubmission)
+
+	// READ: Revision history of a submission; restoring makes an earlier revision's data current
+	userRoutes.GET("/submissions/:id/revisions", handler.ListSubmissionRevisions)
+	userRoutes.POST("/submissions/:id/revisions/:revision/restore", handler.RestoreSubmissionRevision)
+
+	// READ: Download a submitted form as a PDF, or a ZIP of the PDF, uploads and their hashes
+	// GET /submissions/{id}/export?format=pdf|zip&locale=en
+	userRoutes.GET("/submissions/:id/export", handler.ExportSubmission)
+
 	// DELETE: Remove uploaded files
 	userRoutes.DELETE("/submissions/:id/files/:fileId", handler.DeleteFormSubmissionFile)
 
 	userRoutes.GET("/progress", handler.GetFormWithProgress) // ?type=kyb
 
+	// READ: Options of a dynamic field; dependent dropdowns pass the fields they depend on
+	// GET /forms/{form_id}/fields/{field}/options?country=NG
+	userRoutes.GET("/:id/fields/:field/options", handler.GetFieldOptions)
+
+	// READ: JSON Schema (draft 2020-12) of an active form and an OpenAPI document of its
+	// submission endpoints, for generating client types and checking payloads before submitting
+	// GET /forms/{form_id}/schema
+	// GET /forms/{form_id}/openapi
+	userRoutes.GET("/:id/schema", handler.GetFormSchema)
+	userRoutes.GET("/:id/openapi", handler.GetFormOpenAPI)
+
+	// Data subject requests: a copy of the user's forms, KYC results, identity documents and
+	// user_meta, their erasure, or the closure of the account. Admins process them; exports can
+	// be downloaded for 7 days.
+	// POST /forms/data-requests
+	// Body: {"type": "export|erasure|closure"}
+	userRoutes.POST("/data-requests", handler.CreateDataRequest)
+	userRoutes.GET("/data-requests", handler.GetDataRequests)
+	userRoutes.GET("/data-requests/:requestId/download", handler.DownloadDataExport)
+
 	// UPDATE: Save progress for specific step
 	// PUT /submissions/{id}/steps/{step}
 	// Content-Type: multipart/form-data
+	// If-Match: "2" (or _revision=2), the revision of the step from GET /submissions/{id}/steps/{step}
 	// Body: step_fields + files + _status=in_progress|completed
 	userRoutes.PUT("/submissions/:id/steps/:step", handler.SaveStepProgress)
 
@@ -76,6 +139,27 @@ func RegisterFormRoutes(r *gin.RouterGroup, srv *server.Server) {
 	// Body: _status=submitted (optional additional data)
 	userRoutes.POST("/submissions/:id/complete", handler.CompleteForm)
 
+	// Public routes
+	// Forms made public by admins take anonymous submissions, behind a honeypot, a signed
+	// proof of work challenge, rate limits and email verification
+	publicRoutes := r.Group("/public/forms")
+	publicRoutes.Use(handler.PublicFormRateLimit())
+
+	// READ: A public form by ID or slug with the challenge to submit it
+	// GET /public/forms/{id_or_slug}
+	publicRoutes.GET("/:id", handler.GetPublicForm)
+
+	// CREATE: Submit a public form
+	// POST /public/forms/{id_or_slug}/submit
+	// Content-Type: multipart/form-data
+	// Body: form fields + _challenge=<token>&_solution=<proof of work>
+	publicRoutes.POST("/:id/submit", handler.SubmitPublicForm)
+
+	// UPDATE: Confirm the email of a submission from the link emailed to the visitor
+	// POST /public/forms/verify
+	// Body: {"token": "..."}
+	publicRoutes.POST("/verify", handler.VerifyPublicSubmission)
+
 	// Admin routes
 	adminRoutes := r.Group("/admin/forms")
 	adminRoutes.Use(srv.AuthenticatedUseRequired())
@@ -87,9 +171,73 @@ func RegisterFormRoutes(r *gin.RouterGroup, srv *server.Server) {
 	adminRoutes.GET("/:id", handler.GetFormDefinition)
 	adminRoutes.GET("/", handler.ListFormDefinitions)
 
+	// Form Versioning
+	// Changes are staged in a draft version; publishing makes it current for new submissions
+	adminRoutes.PUT("/:id/draft", handler.SaveFormDraft)
+	adminRoutes.GET("/:id/versions", handler.ListFormVersions)
+	adminRoutes.GET("/:id/versions/:version", handler.GetFormVersion)
+	adminRoutes.POST("/:id/versions/:version/publish", handler.PublishFormVersion)
+	adminRoutes.POST("/:id/versions/:version/migrate", handler.MigrateFormSubmissions)
+	adminRoutes.GET("/:id/diff", handler.DiffFormVersions) // ?from=1&to=2
+
+	// Prefill Sources
+	// Fields can be prefilled from user, business, identity verification and user_meta data;
+	// verified values can be locked read-only
+	adminRoutes.GET("/prefill-sources", handler.GetPrefillSources)
+
+	// Custom Rules
+	// Fields and steps can declare compare, sum, unique and remote rules in their validation rules
+	adminRoutes.GET("/custom-rules", handler.GetCustomRuleOptions)
+
+	// Form Schemas
+	// JSON Schema and OpenAPI document of any version of a form, the current one by default
+	adminRoutes.GET("/:id/schema", handler.AdminGetFormSchema)   // ?version=2
+	adminRoutes.GET("/:id/openapi", handler.AdminGetFormOpenAPI) // ?version=2
+
+	// Translations
+	// Forms are served in the language negotiated with Accept-Language or ?lang=, falling back to
+	// English. Translations are exchanged as CSV with a column for each language.
+	adminRoutes.GET("/:id/translations/missing", handler.ListMissingTranslations) // ?languages=fr,zh&version=2
+	adminRoutes.GET("/:id/translations/export", handler.ExportTranslations)       // ?languages=en,fr,zh&version=2
+	adminRoutes.POST("/:id/translations/import", handler.ImportTranslations)      // ?version=2
+
+	// Public Access
+	// Anonymous submissions are linked to users who sign up with the email they verified
+	adminRoutes.GET("/:id/public", handler.GetPublicFormAccess)
+	adminRoutes.PUT("/:id/public", handler.UpdatePublicFormAccess)
+	adminRoutes.GET("/:id/public/submissions", handler.ListPublicSubmissions) // ?status=pending_verification|verified&page=1&page_size=20
+
+	// Data Retention
+	// Policies delete or anonymize submissions some days after they were last updated or the
+	// submitter's account was closed; the purge runs hourly and on demand. Legal holds exempt a
+	// user's data, or their submissions of one form, from purges and erasure.
+	adminRoutes.GET("/:id/retention", handler.ListRetentionPolicies)
+	adminRoutes.POST("/:id/retention", handler.CreateRetentionPolicy)
+	adminRoutes.PUT("/retention/:policyId", handler.UpdateRetentionPolicy)
+	adminRoutes.DELETE("/retention/:policyId", handler.DeleteRetentionPolicy)
+	adminRoutes.POST("/retention/run", handler.RunRetentionPolicies)
+	adminRoutes.GET("/legal-holds", handler.ListLegalHolds) // ?user_id=...&active=true
+	adminRoutes.POST("/legal-holds", handler.PlaceLegalHold)
+	adminRoutes.POST("/legal-holds/:holdId/release", handler.ReleaseLegalHold)
+
+	// Data Subject Requests
+	adminRoutes.GET("/data-requests", handler.ListDataRequests) // ?user_id=...&status=pending|completed|held
+	adminRoutes.POST("/data-requests", handler.CreateUserDataRequest)
+	adminRoutes.POST("/data-requests/:requestId/process", handler.ProcessDataRequest)
+
+	// Form Analytics
+	// Step funnel and timing, abandonment, field validation errors and approval turnaround
+	adminRoutes.GET("/:id/analytics", handler.GetFormAnalytics) // ?from=2025-01-01&to=2025-01-31&version=2&abandoned_after_days=7
+
+	// Form Bundles
+	// Export a form with its events, assignments and option sources and import it elsewhere
+	adminRoutes.GET("/:id/bundle", handler.ExportFormBundle) // ?format=json|yaml
+	adminRoutes.POST("/import", handler.ImportFormBundle)    // ?dry_run=true&on_conflict=fail|skip|new_version&map=old=new
+
 	// Form Assignment Management
 	adminRoutes.POST("/:id/assignments", handler.CreateFormAssignment)
 	adminRoutes.GET("/:id/assignments", handler.GetFormAssignments)
+	adminRoutes.GET("/assignments/explain", handler.ExplainFormAssignment) // ?user_id=...&type=kyb
 
 	// Persistence Configuration
 	adminRoutes.POST("/:id/persistence", handler.CreatePersistenceConfig)
@@ -98,4 +246,18 @@ func RegisterFormRoutes(r *gin.RouterGroup, srv *server.Server) {
 	// Submission Management & Approval
 	adminRoutes.POST("/submissions/:id/approve", handler.ApproveSubmission)
 	adminRoutes.POST("/submissions/:id/reject", handler.RejectSubmission)
+	adminRoutes.POST("/submissions/:id/request-changes", handler.RequestChanges)
+	adminRoutes.GET("/submissions/:id/approvals", handler.GetApprovalHistory)
+	adminRoutes.GET("/submissions/:id/export", handler.AdminExportSubmission) // ?format=pdf|zip&locale=en
+
+	// Approval Queue
+	// Stages awaiting a decision from the current user. Overdue stages are escalated every few
+	// minutes; escalate runs it straight away.
+	adminRoutes.GET("/approvals", handler.ListApprovalQueue)
+	adminRoutes.POST("/approvals/escalate", handler.EscalateOverdueApprovals)
+
+	// Form Events
+	// Deliveries are retried with backoff; those that run out of attempts can be retried by hand
+	adminRoutes.GET("/:id/events/failed", handler.ListFailedFormEvents)
+	adminRoutes.POST("/events/:deliveryId/retry", handler.RetryFormEvent)
 }
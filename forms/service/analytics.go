@@ -0,0 +1,312 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// DefaultAbandonmentIdle is how long a draft must go untouched to count as abandoned
+const DefaultAbandonmentIdle = 7 * 24 * time.Hour
+
+// FormAnalyticsInput selects the submissions of a form started in [From, To). A zero Version
+// covers every version.
+type FormAnalyticsInput struct {
+	FormID  uuid.UUID
+	Version int32
+	From    time.Time
+	To      time.Time
+	// AbandonedAfter is how long a draft must be idle to count as abandoned
+	AbandonedAfter time.Duration
+}
+
+// FormAnalytics is the funnel, field errors and approval turnaround of a form
+type FormAnalytics struct {
+	FormID         uuid.UUID             `json:"form_id"`
+	Version        int32                 `json:"version,omitempty"`
+	From           time.Time             `json:"from"`
+	To             time.Time             `json:"to"`
+	Started        int64                 `json:"started"`
+	Submitted      int64                 `json:"submitted"`
+	Abandoned      int64                 `json:"abandoned"`
+	ConversionRate float64               `json:"conversion_rate"`
+	Steps          []StepAnalytics       `json:"steps"`
+	FieldErrors    []FieldErrorAnalytics `json:"field_errors"`
+	// ApprovalTurnaround covers whole approval rounds, ApprovalStages each stage
+	ApprovalTurnaround *db.FormApprovalStageRow  `json:"approval_turnaround,omitempty"`
+	ApprovalStages     []db.FormApprovalStageRow `json:"approval_stages"`
+}
+
+// StepAnalytics is how submissions got through a step. Rates are fractions of the submissions
+// that reached the step, except FunnelRate which is of all started submissions.
+type StepAnalytics struct {
+	StepNumber     int32   `json:"step_number"`
+	Name           string  `json:"name"`
+	Reached        int64   `json:"reached"`
+	Completed      int64   `json:"completed"`
+	CompletionRate float64 `json:"completion_rate"`
+	FunnelRate     float64 `json:"funnel_rate"`
+	// Abandoned counts the drafts that stopped at this step; AbandonmentShare is their share
+	// of all abandoned drafts
+	Abandoned        int64   `json:"abandoned"`
+	AbandonmentShare float64 `json:"abandonment_share"`
+	MedianSeconds    float64 `json:"median_seconds"`
+	P90Seconds       float64 `json:"p90_seconds"`
+}
+
+// FieldErrorAnalytics is how often a field failed validation. Rate is the fraction of started
+// submissions whose user hit the error.
+type FieldErrorAnalytics struct {
+	FieldName  string              `json:"field_name"`
+	Label      string              `json:"label"`
+	StepNumber int32               `json:"step_number,omitempty"`
+	Failures   int64               `json:"failures"`
+	Users      int64               `json:"users"`
+	Rate       float64             `json:"rate"`
+	Messages   []FieldErrorMessage `json:"messages"`
+}
+
+type FieldErrorMessage struct {
+	Message  string `json:"message"`
+	Failures int64  `json:"failures"`
+	Users    int64  `json:"users"`
+}
+
+// GetFormAnalytics reports step conversion and timing, where drafts are abandoned, the fields
+// that fail validation most and how long approvals take
+func (s *FormService) GetFormAnalytics(ctx context.Context, input FormAnalyticsInput) (*FormAnalytics, error) {
+	form, err := s.store.GetFormDefinition(ctx, input.FormID)
+	if err != nil {
+		return nil, fmt.Errorf("form not found: %w", err)
+	}
+
+	// Steps and labels are named after the version asked for, or the current one
+	version := input.Version
+	if version == 0 {
+		version = form.Version
+	}
+	steps, fields, err := s.formStructure(ctx, form, version)
+	if err != nil {
+		return nil, err
+	}
+
+	idle := input.AbandonedAfter
+	if idle <= 0 {
+		idle = DefaultAbandonmentIdle
+	}
+
+	filter := db.FormAnalyticsFilter{
+		FormDefinitionID: form.ID,
+		Version:          input.Version,
+		From:             input.From,
+		To:               input.To,
+	}
+
+	totals, err := s.store.GetFormSubmissionTotals(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	funnel, err := s.store.GetFormStepFunnel(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	abandonment, err := s.store.GetFormAbandonment(ctx, filter, time.Now().Add(-idle))
+	if err != nil {
+		return nil, err
+	}
+	fieldErrors, err := s.store.GetFormFieldErrors(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	approvals, err := s.store.GetFormApprovalTurnaround(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	analytics := &FormAnalytics{
+		FormID:         form.ID,
+		Version:        input.Version,
+		From:           input.From,
+		To:             input.To,
+		Started:        totals.Started,
+		Submitted:      totals.Submitted,
+		ConversionRate: analyticsRate(totals.Submitted, totals.Started),
+		ApprovalStages: []db.FormApprovalStageRow{},
+	}
+	analytics.Steps, analytics.Abandoned = buildStepAnalytics(steps, funnel, abandonment, totals.Started)
+	analytics.FieldErrors = s.buildFieldErrorAnalytics(steps, fields, fieldErrors, totals.Started)
+
+	for i, row := range approvals {
+		if row.StageName == "" {
+			analytics.ApprovalTurnaround = &approvals[i]
+			continue
+		}
+		analytics.ApprovalStages = append(analytics.ApprovalStages, row)
+	}
+
+	return analytics, nil
+}
+
+// buildStepAnalytics joins the funnel and abandonment of each step to the steps of the form.
+// Steps with no activity are still listed.
+func buildStepAnalytics(steps []db.FormStep, funnel []db.FormStepFunnelRow, abandonment []db.FormAbandonmentRow, started int64) ([]StepAnalytics, int64) {
+	byNumber := make(map[int32]*StepAnalytics)
+	step := func(number int32) *StepAnalytics {
+		if analytics, ok := byNumber[number]; ok {
+			return analytics
+		}
+		analytics := &StepAnalytics{StepNumber: number}
+		byNumber[number] = analytics
+		return analytics
+	}
+
+	for _, s := range steps {
+		step(s.StepNumber).Name = s.Name
+	}
+
+	for _, row := range funnel {
+		analytics := step(row.StepNumber)
+		analytics.Reached = row.Started
+		analytics.Completed = row.Completed
+		analytics.MedianSeconds = row.MedianSeconds
+		analytics.P90Seconds = row.P90Seconds
+	}
+
+	var abandoned int64
+	for _, row := range abandonment {
+		step(row.StepNumber).Abandoned = row.Abandoned
+		abandoned += row.Abandoned
+	}
+
+	result := make([]StepAnalytics, 0, len(byNumber))
+	for _, analytics := range byNumber {
+		analytics.CompletionRate = analyticsRate(analytics.Completed, analytics.Reached)
+		analytics.FunnelRate = analyticsRate(analytics.Completed, started)
+		analytics.AbandonmentShare = analyticsRate(analytics.Abandoned, abandoned)
+		result = append(result, *analytics)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StepNumber < result[j].StepNumber
+	})
+
+	return result, abandoned
+}
+
+// buildFieldErrorAnalytics groups the failures of each field with its label and step, most
+// failures first
+func (s *FormService) buildFieldErrorAnalytics(steps []db.FormStep, fields []db.FormField, rows []db.FormFieldErrorRow, started int64) []FieldErrorAnalytics {
+	stepNumbers := make(map[uuid.UUID]int32, len(steps))
+	for _, step := range steps {
+		stepNumbers[step.ID] = step.StepNumber
+	}
+
+	byField := make(map[string]*FieldErrorAnalytics)
+	var order []string
+	for _, row := range rows {
+		analytics, ok := byField[row.FieldName]
+		if !ok {
+			analytics = &FieldErrorAnalytics{
+				FieldName: row.FieldName,
+				Label:     row.FieldName,
+				Messages:  []FieldErrorMessage{},
+			}
+			if field, label := s.analyticsField(fields, row.FieldName); field != nil {
+				analytics.Label = label
+				analytics.StepNumber = stepNumbers[field.FormStepID]
+			}
+			byField[row.FieldName] = analytics
+			order = append(order, row.FieldName)
+		}
+
+		if row.Message == "" {
+			analytics.Failures = row.Failures
+			analytics.Users = row.Users
+			analytics.Rate = analyticsRate(row.Users, started)
+			continue
+		}
+		analytics.Messages = append(analytics.Messages, FieldErrorMessage{
+			Message:  row.Message,
+			Failures: row.Failures,
+			Users:    row.Users,
+		})
+	}
+
+	result := make([]FieldErrorAnalytics, 0, len(order))
+	for _, name := range order {
+		result = append(result, *byField[name])
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Failures > result[j].Failures
+	})
+	return result
+}
+
+// analyticsField finds the field of an error key and its label. Fields of group items, keyed
+// like owners[].first_name, are labelled with their group.
+func (s *FormService) analyticsField(fields []db.FormField, name string) (*db.FormField, string) {
+	if field := s.findField(fields, name); field != nil {
+		return field, localizedLabel(field.Label, DefaultExportLocale, field.FieldName)
+	}
+
+	group, itemName, ok := strings.Cut(name, "[].")
+	if !ok {
+		return nil, ""
+	}
+	groupField := s.findField(fields, group)
+	if groupField == nil {
+		return nil, ""
+	}
+	label := localizedLabel(groupField.Label, DefaultExportLocale, group)
+
+	itemFields, err := s.groupItemFields(*groupField)
+	if err == nil {
+		for _, itemField := range itemFields {
+			if itemField.FieldName == itemName {
+				return groupField, label + " / " + localizedLabel(itemField.Label, DefaultExportLocale, itemName)
+			}
+		}
+	}
+	return groupField, label + " / " + itemName
+}
+
+// recordValidationFailures keeps the field errors of a failed validation for analytics.
+// Failing to record them never fails the request.
+func (s *FormService) recordValidationFailures(ctx context.Context, formID uuid.UUID, version int32, submissionID uuid.NullUUID, userID uuid.UUID, stepNumber *int32, err error) {
+	var validationErr *validator.ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Fields) == 0 {
+		return
+	}
+
+	params := db.RecordFormValidationFailuresParams{
+		FormDefinitionID: formID,
+		FormVersion:      version,
+		FormSubmissionID: submissionID,
+		UserID:           userID,
+		Errors:           validationErr.Fields,
+	}
+	if stepNumber != nil {
+		params.StepNumber = db.NewNullInt32(*stepNumber)
+	}
+
+	if err := s.store.RecordFormValidationFailures(ctx, params); err != nil {
+		s.logger.Error(err, map[string]interface{}{
+			"form_id":       formID,
+			"submission_id": submissionID,
+		})
+	}
+}
+
+func analyticsRate(count, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return math.Round(float64(count)/float64(total)*10000) / 10000
+}
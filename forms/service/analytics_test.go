@@ -0,0 +1,84 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+)
+
+func TestBuildStepAnalytics(t *testing.T) {
+	steps := []db.FormStep{
+		{StepNumber: 1, Name: "Business"},
+		{StepNumber: 2, Name: "Owners"},
+		{StepNumber: 3, Name: "Documents"},
+	}
+	funnel := []db.FormStepFunnelRow{
+		{StepNumber: 1, Started: 100, Completed: 90, MedianSeconds: 120},
+		{StepNumber: 2, Started: 90, Completed: 80, MedianSeconds: 300},
+		{StepNumber: 3, Started: 80, Completed: 40, MedianSeconds: 900},
+	}
+	abandonment := []db.FormAbandonmentRow{
+		{StepNumber: 1, Abandoned: 5},
+		{StepNumber: 3, Abandoned: 35},
+	}
+
+	result, abandoned := buildStepAnalytics(steps, funnel, abandonment, 100)
+	require.Equal(t, int64(40), abandoned)
+	require.Len(t, result, 3)
+
+	require.Equal(t, "Owners", result[1].Name)
+	require.Equal(t, int64(0), result[1].Abandoned)
+	require.Equal(t, 0.8889, result[1].CompletionRate)
+
+	require.Equal(t, "Documents", result[2].Name)
+	require.Equal(t, 0.5, result[2].CompletionRate)
+	require.Equal(t, 0.4, result[2].FunnelRate)
+	require.Equal(t, 0.875, result[2].AbandonmentShare)
+	require.Equal(t, float64(900), result[2].MedianSeconds)
+
+	// Steps without activity are listed; nothing divides by zero
+	result, _ = buildStepAnalytics(steps, nil, nil, 0)
+	require.Len(t, result, 3)
+	require.Zero(t, result[0].CompletionRate)
+}
+
+func TestBuildFieldErrorAnalytics(t *testing.T) {
+	step := uuid.New()
+	ownerFields, _ := json.Marshal(FieldOptions{Fields: []db.FieldInput{
+		{FieldName: "id_number", FieldType: "text", Label: map[string]string{"en": "ID number"}},
+	}})
+	fields := []db.FormField{
+		{FormStepID: step, FieldName: "tax_id", FieldType: "text", Label: json.RawMessage(`{"en":"Tax ID"}`)},
+		{FormStepID: step, FieldName: "owners", FieldType: FieldTypeGroup, Label: json.RawMessage(`{"en":"Owners"}`), Options: ownerFields},
+	}
+	rows := []db.FormFieldErrorRow{
+		{FieldName: "owners[].id_number", Failures: 12, Users: 9},
+		{FieldName: "owners[].id_number", Message: "field is required", Failures: 12, Users: 9},
+		{FieldName: "tax_id", Failures: 30, Users: 20},
+		{FieldName: "tax_id", Message: "invalid format", Failures: 25, Users: 18},
+		{FieldName: "tax_id", Message: "field is required", Failures: 5, Users: 4},
+		{FieldName: "removed_field", Failures: 1, Users: 1},
+		{FieldName: "removed_field", Message: "field is required", Failures: 1, Users: 1},
+	}
+
+	s := &FormService{}
+	result := s.buildFieldErrorAnalytics([]db.FormStep{{ID: step, StepNumber: 3}}, fields, rows, 100)
+	require.Len(t, result, 3)
+
+	require.Equal(t, "tax_id", result[0].FieldName)
+	require.Equal(t, "Tax ID", result[0].Label)
+	require.Equal(t, int32(3), result[0].StepNumber)
+	require.Equal(t, 0.2, result[0].Rate)
+	require.Len(t, result[0].Messages, 2)
+	require.Equal(t, "invalid format", result[0].Messages[0].Message)
+
+	require.Equal(t, "Owners / ID number", result[1].Label)
+	require.Equal(t, int32(3), result[1].StepNumber)
+
+	// Fields no longer in the form keep their name
+	require.Equal(t, "removed_field", result[2].Label)
+	require.Zero(t, result[2].StepNumber)
+}
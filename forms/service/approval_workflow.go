@@ -0,0 +1,320 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+)
+
+// DefaultApprovalPermission is required to approve forms whose workflow defines no stages
+const DefaultApprovalPermission = "admin.forms"
+
+// MinimumApprovers is the number of distinct approvers a form type requires. Compliance
+// requires two-person approval on KYB.
+var MinimumApprovers = map[string]int32{
+	"kyb": 2,
+}
+
+// approvalEscalationLock is the advisory lock held while overdue approvals are escalated
+const approvalEscalationLock = "forms.approval_escalation"
+
+var (
+	ErrNoApprovalTask = errors.New("no approval stage awaiting your decision")
+	// ErrApprovalEscalationRunning is returned when another instance is escalating approvals
+	ErrApprovalEscalationRunning = errors.New("overdue approvals are being escalated by another run")
+)
+
+// ApprovalStage is a stage of an approval workflow. Stages run in ascending Order; stages
+// sharing an Order run in parallel and must all be approved before the next Order starts.
+type ApprovalStage struct {
+	Name              string `json:"name"`
+	Label             string `json:"label,omitempty"`
+	Order             int32  `json:"order"`
+	Permission        string `json:"permission"`
+	RequiredApprovals int32  `json:"required_approvals"`
+	SLAHours          int32  `json:"sla_hours,omitempty"`
+	EscalateTo        string `json:"escalate_to,omitempty"`
+}
+
+// ApprovalWorkflow is the "stages" part of FormDefinition.ApprovalWorkflow
+type ApprovalWorkflow struct {
+	Stages []ApprovalStage `json:"stages"`
+}
+
+// ApprovalHistory is the approval state and audit trail of a submission
+type ApprovalHistory struct {
+	Tasks  []db.FormApprovalTask  `json:"tasks"`
+	Events []db.FormApprovalEvent `json:"events"`
+}
+
+// ParseApprovalWorkflow reads the stages of a form's approval workflow. Forms without stages
+// get a single stage requiring DefaultApprovalPermission and the form type's minimum approvers.
+func ParseApprovalWorkflow(form db.FormDefinition) (*ApprovalWorkflow, error) {
+	var workflow ApprovalWorkflow
+	if len(form.ApprovalWorkflow) > 0 {
+		if err := json.Unmarshal(form.ApprovalWorkflow, &workflow); err != nil {
+			return nil, fmt.Errorf("invalid approval workflow: %w", err)
+		}
+	}
+
+	if len(workflow.Stages) == 0 {
+		workflow.Stages = []ApprovalStage{{
+			Name:       "review",
+			Order:      1,
+			Permission: DefaultApprovalPermission,
+		}}
+		if minimum := MinimumApprovers[form.FormType]; minimum > 0 {
+			workflow.Stages[0].RequiredApprovals = minimum
+		}
+	}
+
+	for i := range workflow.Stages {
+		stage := &workflow.Stages[i]
+		if stage.Order <= 0 {
+			stage.Order = int32(i + 1)
+		}
+		if stage.RequiredApprovals <= 0 {
+			stage.RequiredApprovals = 1
+		}
+		if stage.Permission == "" {
+			stage.Permission = DefaultApprovalPermission
+		}
+	}
+
+	sort.SliceStable(workflow.Stages, func(i, j int) bool {
+		return workflow.Stages[i].Order < workflow.Stages[j].Order
+	})
+
+	return &workflow, nil
+}
+
+// RequiredApprovers is the number of distinct people needed to approve a submission
+func (w *ApprovalWorkflow) RequiredApprovers() int32 {
+	var total int32
+	for _, stage := range w.Stages {
+		total += stage.RequiredApprovals
+	}
+	return total
+}
+
+// startApproval starts a new approval round for a submitted form. Forms that don't require
+// approval are left alone.
+func (s *FormService) startApproval(ctx context.Context, form db.FormDefinition, submission *db.FormSubmission, actorID uuid.UUID) {
+	if !form.RequiresApproval || submission == nil || submission.Status != "submitted" {
+		return
+	}
+
+	if _, err := s.startApprovalRound(ctx, form, submission.ID, actorID); err != nil {
+		s.logger.Error(err, map[string]interface{}{
+			"form_id":       form.ID,
+			"submission_id": submission.ID,
+		})
+	}
+}
+
+func (s *FormService) startApprovalRound(ctx context.Context, form db.FormDefinition, submissionID uuid.UUID, actorID uuid.UUID) ([]db.FormApprovalTask, error) {
+	workflow, err := ParseApprovalWorkflow(form)
+	if err != nil {
+		return nil, err
+	}
+
+	stages := make([]db.ApprovalStageInput, len(workflow.Stages))
+	for i, stage := range workflow.Stages {
+		stages[i] = db.ApprovalStageInput{
+			Name:              stage.Name,
+			Order:             stage.Order,
+			Permission:        stage.Permission,
+			RequiredApprovals: stage.RequiredApprovals,
+			SLAHours:          stage.SLAHours,
+			EscalateTo:        stage.EscalateTo,
+		}
+	}
+
+	tasks, err := s.store.StartApprovalRoundTx(ctx, &db.StartApprovalRoundInput{
+		SubmissionID: submissionID,
+		ActorID:      actorID,
+		Stages:       stages,
+	}, func(tasks []db.FormApprovalTask) ([]db.EnqueueFormEventsParams, error) {
+		return outboxEvents(form.ID, formEvent{eventType: "approval_requested", data: tasks})
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.eventsEnqueued(ctx)
+
+	return tasks, nil
+}
+
+// recordEdit adds an edit by someone other than the submitter to the audit trail, so that
+// they cannot later approve their own changes.
+func (s *FormService) recordEdit(ctx context.Context, submission db.FormSubmission, editorID uuid.UUID) {
+	if submission.UserID == editorID {
+		return
+	}
+
+	if err := s.store.CreateFormApprovalEvent(ctx, db.CreateFormApprovalEventParams{
+		FormSubmissionID: submission.ID,
+		Action:           db.ApprovalActionEdited,
+		ActorID:          db.NewNullUUID(editorID),
+	}); err != nil {
+		s.logger.Error(err, map[string]interface{}{
+			"submission_id": submission.ID,
+		})
+	}
+}
+
+// decideApproval applies an approver's decision to the active stage they are permitted to decide
+func (s *FormService) decideApproval(ctx context.Context, submissionID, approverID uuid.UUID, action, comment string, fieldComments map[string]string) (*db.ApprovalDecisionResult, error) {
+	submission, err := s.store.GetFormSubmission(ctx, submissionID)
+	if err != nil {
+		return nil, err
+	}
+
+	form, err := s.store.GetFormDefinition(ctx, submission.FormDefinitionID)
+	if err != nil {
+		return nil, err
+	}
+
+	approver, err := s.store.GetUser(ctx, approverID)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := s.store.GetCurrentApprovalTasks(ctx, submissionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Submissions made before the workflow engine, or to forms without approval, start their
+	// first round when they are first reviewed
+	if len(tasks) == 0 && submission.Status == "submitted" {
+		if tasks, err = s.startApprovalRound(ctx, form, submissionID, submission.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	var task *db.FormApprovalTask
+	for i := range tasks {
+		if tasks[i].Status == db.ApprovalTaskStatusActive && s.store.HasPermission(ctx, approver, tasks[i].Permission) {
+			task = &tasks[i]
+			break
+		}
+	}
+
+	if task == nil {
+		return nil, ErrNoApprovalTask
+	}
+
+	result, err := s.store.DecideApprovalTaskTx(ctx, &db.ApprovalDecisionInput{
+		TaskID:        task.ID,
+		ActorID:       approverID,
+		Action:        action,
+		Comment:       comment,
+		FieldComments: fieldComments,
+	}, func(result *db.ApprovalDecisionResult) ([]db.EnqueueFormEventsParams, error) {
+		events := []formEvent{{eventType: "before_" + approvalEventName(action), data: submission}}
+		if !result.Completed {
+			return outboxEvents(form.ID, append(events, formEvent{eventType: "approval_stage_completed", data: result})...)
+		}
+
+		events = append(events, formEvent{eventType: "after_" + approvalEventName(action), data: result.Submission})
+		switch action {
+		case db.ApprovalActionApproved:
+			events = append(events, formEvent{eventType: EventApproved, data: result.Submission})
+		case db.ApprovalActionRejected:
+			events = append(events, formEvent{eventType: EventRejected, data: result.Submission})
+		}
+		return outboxEvents(form.ID, events...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.eventsEnqueued(ctx)
+
+	return result, nil
+}
+
+func approvalEventName(action string) string {
+	switch action {
+	case db.ApprovalActionApproved:
+		return "approve"
+	case db.ApprovalActionRejected:
+		return "reject"
+	default:
+		return "request_changes"
+	}
+}
+
+// RequestChanges sends a submission back to the user with comments on individual fields
+func (s *FormService) RequestChanges(ctx context.Context, submissionID uuid.UUID, reviewerID uuid.UUID, comment string, fieldComments map[string]string) error {
+	_, err := s.decideApproval(ctx, submissionID, reviewerID, db.ApprovalActionChangesRequested, comment, fieldComments)
+	return err
+}
+
+// GetApprovalHistory returns the current approval stages and the audit trail of a submission
+func (s *FormService) GetApprovalHistory(ctx context.Context, submissionID uuid.UUID) (*ApprovalHistory, error) {
+	tasks, err := s.store.GetCurrentApprovalTasks(ctx, submissionID)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := s.store.ListFormApprovalEvents(ctx, submissionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ApprovalHistory{Tasks: tasks, Events: events}, nil
+}
+
+// reviewComments returns the per-field comments of the latest request for changes
+func (s *FormService) reviewComments(ctx context.Context, submission db.FormSubmission) map[string]string {
+	if submission.ApprovalStatus != db.ApprovalActionChangesRequested {
+		return nil
+	}
+
+	events, err := s.store.ListFormApprovalEvents(ctx, submission.ID)
+	if err != nil {
+		return nil
+	}
+
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Action != db.ApprovalActionChangesRequested {
+			continue
+		}
+		var comments map[string]string
+		_ = json.Unmarshal(events[i].FieldComments, &comments)
+		return comments
+	}
+
+	return nil
+}
+
+// EscalateOverdueApprovals reassigns approval stages that have passed their SLA to their
+// EscalateTo permission and fires an approval_escalated form event for each, so notifications
+// can be routed to the new approvers. The worker runs it periodically, see FormJobs. Only one
+// run at a time, across instances, is allowed; others get ErrApprovalEscalationRunning.
+func (s *FormService) EscalateOverdueApprovals(ctx context.Context) (int, error) {
+	unlock, ok, err := s.store.TryAdvisoryLock(ctx, approvalEscalationLock)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrApprovalEscalationRunning
+	}
+	defer unlock()
+
+	tasks, err := s.store.EscalateOverdueApprovalTasksTx(ctx, func(escalation db.EscalatedApprovalTask) ([]db.EnqueueFormEventsParams, error) {
+		return outboxEvents(escalation.FormDefinitionID, formEvent{eventType: "approval_escalated", data: escalation.FormApprovalTask})
+	})
+	if err != nil {
+		return 0, err
+	}
+	s.eventsEnqueued(ctx)
+
+	return len(tasks), nil
+}
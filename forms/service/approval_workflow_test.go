@@ -0,0 +1,44 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+)
+
+func TestParseApprovalWorkflowDefaults(t *testing.T) {
+	workflow, err := ParseApprovalWorkflow(db.FormDefinition{FormType: "kyc", ApprovalWorkflow: json.RawMessage(`{}`)})
+	require.NoError(t, err)
+	require.Equal(t, []ApprovalStage{{Name: "review", Order: 1, Permission: DefaultApprovalPermission, RequiredApprovals: 1}}, workflow.Stages)
+
+	// KYB needs two-person approval even without configured stages
+	workflow, err = ParseApprovalWorkflow(db.FormDefinition{FormType: "kyb"})
+	require.NoError(t, err)
+	require.Equal(t, int32(2), workflow.RequiredApprovers())
+}
+
+func TestParseApprovalWorkflowStages(t *testing.T) {
+	workflow, err := ParseApprovalWorkflow(db.FormDefinition{
+		FormType: "kyb",
+		ApprovalWorkflow: json.RawMessage(`{"stages": [
+			{"name": "compliance", "order": 2, "permission": "compliance.approve", "sla_hours": 24, "escalate_to": "compliance.lead"},
+			{"name": "operations", "order": 1},
+			{"name": "risk", "order": 1, "permission": "risk.approve", "required_approvals": 2}
+		]}`),
+	})
+	require.NoError(t, err)
+
+	require.Len(t, workflow.Stages, 3)
+	require.Equal(t, "operations", workflow.Stages[0].Name)
+	require.Equal(t, DefaultApprovalPermission, workflow.Stages[0].Permission)
+	require.Equal(t, int32(1), workflow.Stages[0].RequiredApprovals)
+	require.Equal(t, "risk", workflow.Stages[1].Name)
+	require.Equal(t, "compliance", workflow.Stages[2].Name)
+	require.Equal(t, int32(24), workflow.Stages[2].SLAHours)
+	require.Equal(t, int32(4), workflow.RequiredApprovers())
+
+	_, err = ParseApprovalWorkflow(db.FormDefinition{ApprovalWorkflow: json.RawMessage(`{"stages": {}}`)})
+	require.Error(t, err)
+}
@@ -0,0 +1,456 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+)
+
+// KYCTierMetaKey is the user meta key holding a user's KYC tier. Users without one are tier 1
+// once KYC verified and tier 0 before.
+const KYCTierMetaKey = "kyc_tier"
+
+const defaultTransactionVolumeDays = 30
+
+var ErrNoFormAssigned = errors.New("no form found for user")
+
+// AssignmentConditions are the conditions of an assignment, all of which must hold for it to
+// apply. Lists match when the user has any of their values.
+type AssignmentConditions struct {
+	Countries    conditionValues `json:"countries,omitempty"`
+	AccountTypes conditionValues `json:"account_types,omitempty"`
+	// UserType is the single account type of assignments created before AccountTypes
+	UserType string `json:"user_type,omitempty"`
+	// KYCVerified limits the assignment to KYC verified users; false places no restriction.
+	// Use max_kyc_tier 0 for users who aren't verified.
+	KYCVerified bool `json:"kyc_verified,omitempty"`
+	MinKYCTier  *int `json:"min_kyc_tier,omitempty"`
+	MaxKYCTier  *int `json:"max_kyc_tier,omitempty"`
+	// Permissions must all be held by the user
+	Permissions []string `json:"permissions,omitempty"`
+	// UserMeta maps meta keys to the values they may have
+	UserMeta          map[string]conditionValues  `json:"user_meta,omitempty"`
+	TransactionVolume *TransactionVolumeCondition `json:"transaction_volume,omitempty"`
+	Cohort            *CohortCondition            `json:"cohort,omitempty"`
+	// NewCustomersOnly limits the assignment to users who registered in the last 30 days
+	NewCustomersOnly bool `json:"new_customers_only,omitempty"`
+}
+
+// TransactionVolumeCondition bounds the total of a user's completed transactions in Currency
+// over the last Days days, 30 when unset. Amounts in different currencies are never added up,
+// so the currency is required.
+type TransactionVolumeCondition struct {
+	Min      *decimal.Decimal `json:"min,omitempty"`
+	Max      *decimal.Decimal `json:"max,omitempty"`
+	Days     int              `json:"days,omitempty"`
+	Currency string           `json:"currency,omitempty"`
+}
+
+// CohortCondition places a stable percentage of users in an A/B cohort. Users are bucketed by
+// hashing their ID with the seed, which defaults to the form's ID, so assignments sharing a seed
+// split users the same way.
+type CohortCondition struct {
+	Percent float64 `json:"percent"`
+	Seed    string  `json:"seed,omitempty"`
+}
+
+// conditionValues is a list of values that may also be written as a single value
+type conditionValues []string
+
+func (c *conditionValues) UnmarshalJSON(data []byte) error {
+	var values []interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		values = []interface{}{value}
+	}
+
+	*c = make(conditionValues, 0, len(values))
+	for _, value := range values {
+		switch v := value.(type) {
+		case string:
+			*c = append(*c, v)
+		case bool:
+			*c = append(*c, strconv.FormatBool(v))
+		case float64:
+			*c = append(*c, strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			return fmt.Errorf("condition values must be strings, numbers or booleans, not %T", value)
+		}
+	}
+	return nil
+}
+
+func (c conditionValues) matches(value string) bool {
+	for _, v := range c {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseAssignmentConditions decodes the conditions of an assignment. Unknown conditions are
+// an error rather than ignored, so a typo can't widen who gets a form.
+func ParseAssignmentConditions(raw []byte) (AssignmentConditions, error) {
+	var conditions AssignmentConditions
+	if len(bytes.TrimSpace(raw)) == 0 || bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return conditions, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&conditions); err != nil {
+		return conditions, fmt.Errorf("invalid assignment conditions: %w", err)
+	}
+
+	if cohort := conditions.Cohort; cohort != nil && (cohort.Percent < 0 || cohort.Percent > 100) {
+		return conditions, errors.New("invalid assignment conditions: cohort percent must be between 0 and 100")
+	}
+	if volume := conditions.TransactionVolume; volume != nil {
+		if volume.Days < 0 {
+			return conditions, errors.New("invalid assignment conditions: transaction volume days can't be negative")
+		}
+		if strings.TrimSpace(volume.Currency) == "" {
+			return conditions, errors.New("invalid assignment conditions: transaction volume needs a currency")
+		}
+	}
+	return conditions, nil
+}
+
+// AssignmentSubject is the user assignments are resolved for
+type AssignmentSubject struct {
+	User        db.User
+	Permissions []string
+	Meta        map[string]string
+	// TransactionVolume sums the user's completed transactions in a currency since a time. It
+	// is only called for assignments with a transaction volume condition.
+	TransactionVolume func(since time.Time, currency string) (decimal.Decimal, error)
+}
+
+// KYCTier is the user's KYC tier
+func (s AssignmentSubject) KYCTier() int {
+	if tier, err := strconv.Atoi(s.Meta[KYCTierMetaKey]); err == nil {
+		return tier
+	}
+	if s.User.KycVerified == "verified" {
+		return 1
+	}
+	return 0
+}
+
+// AssignmentCheck is the outcome of one rule of an assignment
+type AssignmentCheck struct {
+	Rule   string `json:"rule"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail"`
+}
+
+// AssignmentEvaluation is whether an assignment applies to a user. Checks stop at the first
+// that fails.
+type AssignmentEvaluation struct {
+	AssignmentID     uuid.UUID         `json:"assignment_id"`
+	FormDefinitionID uuid.UUID         `json:"form_definition_id"`
+	AssignmentType   string            `json:"assignment_type"`
+	AssignmentValue  string            `json:"assignment_value"`
+	Priority         int32             `json:"priority"`
+	Matched          bool              `json:"matched"`
+	Checks           []AssignmentCheck `json:"checks"`
+}
+
+// AssignmentExplanation is which assignment, if any, gives a user a form of a type, and why
+// the assignments were or weren't chosen, in priority order
+type AssignmentExplanation struct {
+	UserID      uuid.UUID              `json:"user_id"`
+	FormType    string                 `json:"form_type"`
+	Selected    *AssignmentEvaluation  `json:"selected"`
+	Evaluations []AssignmentEvaluation `json:"evaluations"`
+}
+
+// resolveAssignments evaluates assignments in priority order, earliest created first among
+// equals, and selects the first that applies. With explain set every assignment is evaluated,
+// otherwise evaluation stops at the selected one.
+func resolveAssignments(assignments []db.GetFormAssignmentsRow, subject AssignmentSubject, now time.Time, explain bool) (*AssignmentExplanation, *db.GetFormAssignmentsRow) {
+	sorted := append([]db.GetFormAssignmentsRow(nil), assignments...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Priority != sorted[j].Priority {
+			return sorted[i].Priority > sorted[j].Priority
+		}
+		return sorted[i].CreatedAt.Before(sorted[j].CreatedAt)
+	})
+
+	explanation := &AssignmentExplanation{UserID: subject.User.ID}
+	selected := -1
+	for i := range sorted {
+		evaluation := evaluateAssignment(sorted[i], subject, now)
+		explanation.Evaluations = append(explanation.Evaluations, evaluation)
+
+		if evaluation.Matched && selected < 0 {
+			selected = i
+			if !explain {
+				break
+			}
+		}
+	}
+
+	if selected < 0 {
+		return explanation, nil
+	}
+	explanation.Selected = &explanation.Evaluations[selected]
+	return explanation, &sorted[selected]
+}
+
+// evaluateAssignment checks the time window, target and conditions of an assignment
+func evaluateAssignment(assignment db.GetFormAssignmentsRow, subject AssignmentSubject, now time.Time) AssignmentEvaluation {
+	evaluation := AssignmentEvaluation{
+		AssignmentID:     assignment.ID,
+		FormDefinitionID: assignment.FormDefinitionID,
+		AssignmentType:   assignment.AssignmentType,
+		AssignmentValue:  assignment.AssignmentValue,
+		Priority:         assignment.Priority,
+	}
+	check := func(rule string, passed bool, detail string, args ...interface{}) bool {
+		evaluation.Checks = append(evaluation.Checks, AssignmentCheck{Rule: rule, Passed: passed, Detail: fmt.Sprintf(detail, args...)})
+		return passed
+	}
+
+	if assignment.ValidFrom.Valid && now.Before(assignment.ValidFrom.Time) {
+		check("valid_from", false, "starts at %s", assignment.ValidFrom.Time.Format(time.RFC3339))
+		return evaluation
+	}
+	if assignment.ValidUntil.Valid && !now.Before(assignment.ValidUntil.Time) {
+		check("valid_until", false, "ended at %s", assignment.ValidUntil.Time.Format(time.RFC3339))
+		return evaluation
+	}
+
+	if !checkAssignmentTarget(assignment, subject.User, check) {
+		return evaluation
+	}
+
+	conditions, err := ParseAssignmentConditions(assignment.Conditions.RawMessage)
+	if !check("conditions", err == nil, "%v", errorDetail(err, "valid")) {
+		return evaluation
+	}
+	if !checkAssignmentConditions(conditions, assignment, subject, now, check) {
+		return evaluation
+	}
+
+	evaluation.Matched = true
+	return evaluation
+}
+
+func errorDetail(err error, ok string) string {
+	if err != nil {
+		return err.Error()
+	}
+	return ok
+}
+
+type checkFunc func(rule string, passed bool, detail string, args ...interface{}) bool
+
+// checkAssignmentTarget checks the user is who the assignment's type and value target
+func checkAssignmentTarget(assignment db.GetFormAssignmentsRow, user db.User, check checkFunc) bool {
+	value := assignment.AssignmentValue
+	switch assignment.AssignmentType {
+	case "user_id":
+		return check("user_id", value == user.ID.String(), "assigned to user %s", value)
+	case "user_type":
+		return check("user_type", strings.EqualFold(value, user.AccountType), "assigned to %s accounts, user is %q", value, user.AccountType)
+	case "country":
+		return check("country", strings.EqualFold(value, user.CountryCode), "assigned to country %s, user is in %q", value, user.CountryCode)
+	case "state":
+		return check("state", strings.EqualFold(value, user.State), "assigned to state %s, user is in %q", value, user.State)
+	case "custom":
+		return check("custom", true, "assigned by conditions only")
+	default:
+		return check("assignment_type", false, "unknown assignment type %q", assignment.AssignmentType)
+	}
+}
+
+func checkAssignmentConditions(conditions AssignmentConditions, assignment db.GetFormAssignmentsRow, subject AssignmentSubject, now time.Time, check checkFunc) bool {
+	user := subject.User
+
+	if len(conditions.Countries) > 0 &&
+		!check("countries", conditions.Countries.matches(user.CountryCode), "user is in %q", user.CountryCode) {
+		return false
+	}
+	if len(conditions.AccountTypes) > 0 &&
+		!check("account_types", conditions.AccountTypes.matches(user.AccountType), "user has a %q account", user.AccountType) {
+		return false
+	}
+	if conditions.UserType != "" &&
+		!check("user_type", strings.EqualFold(conditions.UserType, user.AccountType), "user has a %q account", user.AccountType) {
+		return false
+	}
+	if conditions.KYCVerified &&
+		!check("kyc_verified", user.KycVerified == "verified", "user KYC status is %q", user.KycVerified) {
+		return false
+	}
+
+	tier := subject.KYCTier()
+	if conditions.MinKYCTier != nil && !check("min_kyc_tier", tier >= *conditions.MinKYCTier, "user is tier %d", tier) {
+		return false
+	}
+	if conditions.MaxKYCTier != nil && !check("max_kyc_tier", tier <= *conditions.MaxKYCTier, "user is tier %d", tier) {
+		return false
+	}
+
+	for _, permission := range conditions.Permissions {
+		held := false
+		for _, p := range subject.Permissions {
+			held = held || p == permission
+		}
+		if !check("permissions", held, "requires %s", permission) {
+			return false
+		}
+	}
+
+	metaKeys := make([]string, 0, len(conditions.UserMeta))
+	for key := range conditions.UserMeta {
+		metaKeys = append(metaKeys, key)
+	}
+	sort.Strings(metaKeys)
+	for _, key := range metaKeys {
+		value, ok := subject.Meta[key]
+		if !check("user_meta", ok && conditions.UserMeta[key].matches(value), "%s is %q", key, value) {
+			return false
+		}
+	}
+
+	if conditions.NewCustomersOnly {
+		registered := now.Sub(user.CreatedAt)
+		if !check("new_customers_only", registered <= 30*24*time.Hour, "user registered %s", user.CreatedAt.Format(time.RFC3339)) {
+			return false
+		}
+	}
+
+	if cohort := conditions.Cohort; cohort != nil {
+		seed := cohort.Seed
+		if seed == "" {
+			seed = assignment.FormDefinitionID.String()
+		}
+		bucket := cohortBucket(seed, user.ID)
+		if !check("cohort", bucket < cohort.Percent, "user is in bucket %.2f of 100", bucket) {
+			return false
+		}
+	}
+
+	if volume := conditions.TransactionVolume; volume != nil {
+		days := volume.Days
+		if days == 0 {
+			days = defaultTransactionVolumeDays
+		}
+		if subject.TransactionVolume == nil {
+			return check("transaction_volume", false, "transaction volume is not available")
+		}
+		total, err := subject.TransactionVolume(now.AddDate(0, 0, -days), volume.Currency)
+		if err != nil {
+			return check("transaction_volume", false, "failed to get transaction volume: %v", err)
+		}
+		passed := (volume.Min == nil || total.GreaterThanOrEqual(*volume.Min)) &&
+			(volume.Max == nil || total.LessThanOrEqual(*volume.Max))
+		if !check("transaction_volume", passed, "user transacted %s %s in the last %d days", total.String(), volume.Currency, days) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// cohortBucket places a user in [0, 100) by the hash of their ID and a seed
+func cohortBucket(seed string, userID uuid.UUID) float64 {
+	h := fnv.New32a()
+	h.Write([]byte(seed + ":" + userID.String()))
+	return float64(h.Sum32()%10000) / 100
+}
+
+// assignmentSubject loads what assignment conditions are evaluated against
+func (s *FormService) assignmentSubject(ctx context.Context, userID uuid.UUID) (AssignmentSubject, error) {
+	user, err := s.store.GetUser(ctx, userID)
+	if err != nil {
+		return AssignmentSubject{}, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	permissions, err := s.store.GetPermissionsForUser(ctx, userID)
+	if err != nil {
+		return AssignmentSubject{}, fmt.Errorf("failed to get user permissions: %w", err)
+	}
+
+	meta, err := s.store.ListUserMetaValues(ctx, userID)
+	if err != nil {
+		return AssignmentSubject{}, fmt.Errorf("failed to get user meta: %w", err)
+	}
+
+	return AssignmentSubject{
+		User:        user,
+		Permissions: permissions,
+		Meta:        meta,
+		TransactionVolume: func(since time.Time, currency string) (decimal.Decimal, error) {
+			return s.store.GetUserTransactionVolume(ctx, db.TransactionVolumeFilter{
+				UserID:       userID,
+				Since:        since,
+				CurrencyCode: currency,
+			})
+		},
+	}, nil
+}
+
+// ResolveFormAssignment returns the assignment that gives a user a form of a type
+func (s *FormService) ResolveFormAssignment(ctx context.Context, userID uuid.UUID, formType string) (*db.GetFormAssignmentsRow, error) {
+	_, selected, err := s.resolveFormAssignment(ctx, userID, formType, false)
+	if err != nil {
+		return nil, err
+	}
+	if selected == nil {
+		return nil, ErrNoFormAssigned
+	}
+	return selected, nil
+}
+
+// ExplainFormAssignment reports which form of a type a user would get and why
+func (s *FormService) ExplainFormAssignment(ctx context.Context, userID uuid.UUID, formType string) (*AssignmentExplanation, error) {
+	explanation, _, err := s.resolveFormAssignment(ctx, userID, formType, true)
+	return explanation, err
+}
+
+func (s *FormService) resolveFormAssignment(ctx context.Context, userID uuid.UUID, formType string, explain bool) (*AssignmentExplanation, *db.GetFormAssignmentsRow, error) {
+	subject, err := s.assignmentSubject(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	assignments, err := s.store.ListFormAssignmentsByType(ctx, formType)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get form assignments: %w", err)
+	}
+
+	explanation, selected := resolveAssignments(assignments, subject, time.Now(), explain)
+	explanation.FormType = formType
+	return explanation, selected, nil
+}
+
+// ValidateFormAssignment checks an assignment has a known type and valid conditions
+func ValidateFormAssignment(assignmentType string, conditions []byte) error {
+	known := false
+	for _, t := range assignmentTypes {
+		known = known || t == assignmentType
+	}
+	if !known {
+		return fmt.Errorf("unknown assignment type %q", assignmentType)
+	}
+	_, err := ParseAssignmentConditions(conditions)
+	return err
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+)
+
+func testAssignment(assignmentType, value string, priority int32, conditions string) db.GetFormAssignmentsRow {
+	assignment := db.GetFormAssignmentsRow{
+		ID:               uuid.New(),
+		FormDefinitionID: uuid.New(),
+		AssignmentType:   assignmentType,
+		AssignmentValue:  value,
+		Priority:         priority,
+		CreatedAt:        time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		FormType:         "kyb",
+	}
+	if conditions != "" {
+		assignment.Conditions.RawMessage = json.RawMessage(conditions)
+		assignment.Conditions.Valid = true
+	}
+	return assignment
+}
+
+func testAssignmentSubject() AssignmentSubject {
+	return AssignmentSubject{
+		User: db.User{
+			ID:          uuid.MustParse("6f1c2b7e-0000-4000-8000-000000000001"),
+			CountryCode: "NG",
+			State:       "Lagos",
+			AccountType: "business",
+			KycVerified: "verified",
+			CreatedAt:   time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		},
+		Permissions: []string{"transfers.international"},
+		Meta:        map[string]string{KYCTierMetaKey: "2", "industry": "fintech"},
+		TransactionVolume: func(since time.Time, currency string) (decimal.Decimal, error) {
+			if currency == "USD" {
+				return decimal.NewFromInt(900), nil
+			}
+			return decimal.NewFromInt(25000000), nil
+		},
+	}
+}
+
+func TestResolveAssignments(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	subject := testAssignmentSubject()
+
+	expired := testAssignment("custom", "expired", 100, "")
+	expired.ValidUntil = db.NewNullTime(now.Add(-time.Hour))
+	upcoming := testAssignment("custom", "upcoming", 90, "")
+	upcoming.ValidFrom = db.NewNullTime(now.Add(time.Hour))
+	otherCountry := testAssignment("country", "GH", 80, "")
+	highVolume := testAssignment("custom", "high volume", 70, `{"transaction_volume":{"min":"1000","currency":"USD"}}`)
+	tiered := testAssignment("user_type", "business", 60, `{"countries":["NG","GH"],"min_kyc_tier":2,"permissions":["transfers.international"],"user_meta":{"industry":["fintech","banking"]}}`)
+	fallback := testAssignment("custom", "fallback", 10, "")
+
+	assignments := []db.GetFormAssignmentsRow{fallback, tiered, highVolume, otherCountry, upcoming, expired}
+
+	explanation, selected := resolveAssignments(assignments, subject, now, false)
+	require.NotNil(t, selected)
+	require.Equal(t, tiered.ID, selected.ID)
+	require.Len(t, explanation.Evaluations, 5, "evaluation stops at the selected assignment")
+
+	rules := func(e AssignmentEvaluation) string {
+		last := e.Checks[len(e.Checks)-1]
+		return last.Rule
+	}
+	require.Equal(t, "valid_until", rules(explanation.Evaluations[0]))
+	require.Equal(t, "valid_from", rules(explanation.Evaluations[1]))
+	require.Equal(t, "country", rules(explanation.Evaluations[2]))
+	require.Equal(t, "transaction_volume", rules(explanation.Evaluations[3]))
+	require.Contains(t, explanation.Evaluations[3].Checks[2].Detail, "900 USD")
+
+	// Explaining evaluates every assignment
+	explanation, selected = resolveAssignments(assignments, subject, now, true)
+	require.Len(t, explanation.Evaluations, 6)
+	require.Equal(t, tiered.ID, explanation.Selected.AssignmentID)
+	require.True(t, explanation.Evaluations[5].Matched)
+
+	// Equal priorities go to the earliest created
+	later := testAssignment("custom", "later", 60, "")
+	later.CreatedAt = tiered.CreatedAt.Add(time.Hour)
+	_, selected = resolveAssignments([]db.GetFormAssignmentsRow{later, tiered}, subject, now, false)
+	require.Equal(t, tiered.ID, selected.ID)
+
+	_, selected = resolveAssignments([]db.GetFormAssignmentsRow{otherCountry}, subject, now, false)
+	require.Nil(t, selected)
+}
+
+func TestAssignmentConditions(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	subject := testAssignmentSubject()
+
+	for conditions, matched := range map[string]bool{
+		`{"user_type":"business","kyc_verified":true}`:                                     true,
+		`{"account_types":"individual"}`:                                                   false,
+		`{"max_kyc_tier":1}`:                                                               false,
+		`{"permissions":["transfers.international","admin.forms"]}`:                        false,
+		`{"user_meta":{"industry":"retail"}}`:                                              false,
+		`{"user_meta":{"is_pep":false}}`:                                                   false,
+		`{"new_customers_only":true}`:                                                      false,
+		`{"transaction_volume":{"min":1000000,"max":50000000,"days":90,"currency":"NGN"}}`: true,
+		`{"transaction_volume":{"min":1000000,"max":50000000,"days":90}}`:                  false, // no currency
+		`{"cohort":{"percent":100}}`:                                                       true,
+		`{"cohort":{"percent":0}}`:                                                         false,
+		`{"country":"NG"}`:                                                                 false, // unknown condition
+		`{"cohort":{"percent":150}}`:                                                       false,
+	} {
+		evaluation := evaluateAssignment(testAssignment("custom", "", 1, conditions), subject, now)
+		require.Equal(t, matched, evaluation.Matched, conditions)
+	}
+
+	// A volume that can't be loaded fails the condition rather than the resolution
+	subject.TransactionVolume = func(time.Time, string) (decimal.Decimal, error) {
+		return decimal.Zero, errors.New("connection refused")
+	}
+	evaluation := evaluateAssignment(testAssignment("custom", "", 1, `{"transaction_volume":{"min":1,"currency":"NGN"}}`), subject, now)
+	require.False(t, evaluation.Matched)
+	require.Contains(t, evaluation.Checks[len(evaluation.Checks)-1].Detail, "connection refused")
+
+	// Users without a tier are tier 1 once verified
+	subject.Meta = nil
+	require.Equal(t, 1, subject.KYCTier())
+	subject.User.KycVerified = "pending"
+	require.Equal(t, 0, subject.KYCTier())
+}
+
+func TestCohortBucket(t *testing.T) {
+	seed := "kyb-redesign"
+	inCohort := 0
+	for i := 0; i < 10000; i++ {
+		userID := uuid.New()
+		bucket := cohortBucket(seed, userID)
+		require.Equal(t, bucket, cohortBucket(seed, userID), "buckets are stable")
+		if bucket < 20 {
+			inCohort++
+		}
+	}
+	require.InDelta(t, 2000, inCohort, 300)
+}
+
+func TestValidateFormAssignment(t *testing.T) {
+	require.NoError(t, ValidateFormAssignment("custom", nil))
+	require.NoError(t, ValidateFormAssignment("country", []byte(`{"min_kyc_tier":2,"cohort":{"percent":10,"seed":"a"}}`)))
+	require.ErrorContains(t, ValidateFormAssignment("region", nil), "region")
+	require.ErrorContains(t, ValidateFormAssignment("custom", []byte(`{"kyc_teir":2}`)), "kyc_teir")
+	require.Error(t, ValidateFormAssignment("custom", []byte(`{"transaction_volume":{"days":-1}}`)))
+}
@@ -0,0 +1,808 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/validator"
+	"gopkg.in/yaml.v3"
+)
+
+// A form bundle is a form definition with its events, assignments and option sources that
+// can be exported from one environment and imported into another. Bundles carry no IDs of
+// their own: steps are referenced by number, fields by name and option sources by name.
+const (
+	FormBundleFormat  = "monieverse.form-bundle"
+	FormBundleVersion = 1
+)
+
+// Encodings of a form bundle
+const (
+	BundleEncodingJSON = "json"
+	BundleEncodingYAML = "yaml"
+)
+
+// RedactedBundleValue replaces the secret and header values of webhook events in an exported
+// bundle. A bundle can't be imported until each is replaced with the value to use in the
+// environment it is imported into.
+const RedactedBundleValue = "<redacted>"
+
+var ErrUnsupportedBundle = errors.New("unsupported form bundle")
+
+// FormBundle is an exported form
+type FormBundle struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	// Source is the exported form in its own environment, kept for reference only
+	Source        FormBundleSource           `json:"source"`
+	Form          db.FormDefinitionInput     `json:"form"`
+	OptionSources []db.FormOptionSourceInput `json:"option_sources,omitempty"`
+	Events        []db.FormEventInput        `json:"events,omitempty"`
+	Assignments   []db.FormAssignmentInput   `json:"assignments,omitempty"`
+}
+
+type FormBundleSource struct {
+	FormID      uuid.UUID `json:"form_id"`
+	FormVersion int32     `json:"form_version"`
+}
+
+// ImportFormBundleInput imports a bundle. OnConflict decides what happens when a form with
+// the bundle's slug exists and defaults to failing. IDMap replaces IDs from the source
+// environment, like the user of a user_id assignment or an ID in a handler config, with
+// their counterpart in this one.
+type ImportFormBundleInput struct {
+	Bundle     *FormBundle
+	OnConflict string
+	DryRun     bool
+	IDMap      map[string]string
+	ImportedBy uuid.UUID
+	ChangeNote string
+}
+
+// FormBundleImportResult is the outcome of an import. A dry run reports the action the import
+// would take without changing anything.
+type FormBundleImportResult struct {
+	Slug                 string             `json:"slug"`
+	Action               string             `json:"action"`
+	DryRun               bool               `json:"dry_run"`
+	Form                 *db.FormDefinition `json:"form,omitempty"`
+	OptionSourcesCreated int                `json:"option_sources_created"`
+	EventsCreated        int                `json:"events_created"`
+	AssignmentsCreated   int                `json:"assignments_created"`
+	Warnings             []string           `json:"warnings"`
+}
+
+// assignmentTypes are the assignment types GetFormAssignments matches on
+var assignmentTypes = []string{"user_id", "user_type", "country", "state", "custom"}
+
+// ExportFormBundle exports the current version of a form
+func (s *FormService) ExportFormBundle(ctx context.Context, formID uuid.UUID) (*FormBundle, error) {
+	form, err := s.store.GetFormDefinition(ctx, formID)
+	if err != nil {
+		return nil, err
+	}
+
+	steps, fields, err := s.formStructure(ctx, form, form.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &FormBundle{
+		Format:     FormBundleFormat,
+		Version:    FormBundleVersion,
+		ExportedAt: time.Now().UTC(),
+		Source: FormBundleSource{
+			FormID:      form.ID,
+			FormVersion: form.Version,
+		},
+		Form: db.FormDefinitionInput{
+			Name:                      form.Name,
+			Slug:                      form.Slug,
+			Description:               form.Description,
+			FormType:                  form.FormType,
+			IsMultiStep:               form.IsMultiStep,
+			RequiresApproval:          form.RequiresApproval,
+			IsEditableAfterSubmission: form.IsEditableAfterSubmission,
+		},
+	}
+
+	if bundle.Form.ApprovalWorkflow, err = bundleApprovalWorkflow(form.ApprovalWorkflow); err != nil {
+		return nil, err
+	}
+
+	stepNumbers := make(map[uuid.UUID]int, len(steps))
+	for _, step := range steps {
+		stepNumbers[step.ID] = int(step.StepNumber)
+		// Single step forms get their step when they are created
+		if form.IsMultiStep {
+			input := db.StepInput{
+				StepNumber:  int(step.StepNumber),
+				Name:        step.Name,
+				Description: step.Description,
+				IsOptional:  step.IsOptional,
+			}
+			if err := decodeBundleObject(step.ValidationRules, &input.ValidationRules); err != nil {
+				return nil, fmt.Errorf("step %d: %w", step.StepNumber, err)
+			}
+			bundle.Form.Steps = append(bundle.Form.Steps, input)
+		}
+	}
+
+	sources := make(map[string]db.FormOptionSourceInput)
+	for _, field := range fields {
+		input, err := bundleFieldInput(field, stepNumbers[field.FormStepID])
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.FieldName, err)
+		}
+		if err := s.bundleOptionSources(ctx, input.Options, sources); err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.FieldName, err)
+		}
+		bundle.Form.Fields = append(bundle.Form.Fields, input)
+	}
+
+	for _, source := range sources {
+		bundle.OptionSources = append(bundle.OptionSources, source)
+	}
+	sort.Slice(bundle.OptionSources, func(i, j int) bool {
+		return bundle.OptionSources[i].Name < bundle.OptionSources[j].Name
+	})
+
+	config, err := s.store.GetPersistenceConfig(ctx, form.ID)
+	switch {
+	case err == nil:
+		if bundle.Form.PersistenceConfig, err = bundlePersistenceConfig(config); err != nil {
+			return nil, err
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	}
+
+	events, err := s.store.ListFormEvents(ctx, form.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		config, err := redactHandlerConfig(event.HandlerType, event.HandlerConfig)
+		if err != nil {
+			return nil, fmt.Errorf("%s event: %w", event.EventType, err)
+		}
+		bundle.Events = append(bundle.Events, db.FormEventInput{
+			EventType:     event.EventType,
+			HandlerType:   event.HandlerType,
+			HandlerConfig: config,
+			IsActive:      event.IsActive,
+		})
+	}
+
+	assignments, err := s.store.ListFormAssignments(ctx, form.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, assignment := range assignments {
+		input := db.FormAssignmentInput{
+			AssignmentType:  assignment.AssignmentType,
+			AssignmentValue: assignment.AssignmentValue,
+			Priority:        assignment.Priority,
+		}
+		if assignment.Conditions.Valid {
+			input.Conditions = assignment.Conditions.RawMessage
+		}
+		if assignment.ValidFrom.Valid {
+			input.ValidFrom = &assignment.ValidFrom.Time
+		}
+		if assignment.ValidUntil.Valid {
+			input.ValidUntil = &assignment.ValidUntil.Time
+		}
+		bundle.Assignments = append(bundle.Assignments, input)
+	}
+
+	return bundle, nil
+}
+
+// bundleFieldInput converts a stored field back to the input it was created from
+func bundleFieldInput(field db.FormField, stepNumber int) (db.FieldInput, error) {
+	input := db.FieldInput{
+		FieldName:    field.FieldName,
+		FieldType:    field.FieldType,
+		StepNumber:   stepNumber,
+		DisplayOrder: int(field.DisplayOrder),
+		IsRequired:   field.IsRequired,
+		IsReadonly:   field.IsReadonly,
+	}
+	if field.DefaultValue != "" {
+		input.DefaultValue = &field.DefaultValue
+	}
+
+	for _, text := range []struct {
+		raw    json.RawMessage
+		target *map[string]string
+	}{
+		{field.Label, &input.Label},
+		{field.Placeholder, &input.Placeholder},
+		{field.HelpText, &input.HelpText},
+	} {
+		if err := decodeBundleObject(text.raw, text.target); err != nil {
+			return input, err
+		}
+	}
+
+	for _, object := range []struct {
+		raw    json.RawMessage
+		target *map[string]interface{}
+	}{
+		{field.ValidationRules, &input.ValidationRules},
+		{field.Options, &input.Options},
+		{field.ConditionalLogic, &input.ConditionalLogic},
+		{field.FileConfig, &input.FileConfig},
+		{field.Prefill, &input.Prefill},
+	} {
+		if err := decodeBundleObject(object.raw, object.target); err != nil {
+			return input, err
+		}
+	}
+
+	return input, nil
+}
+
+// decodeBundleObject decodes a stored JSON object, leaving target nil when the object is empty
+func decodeBundleObject[T any](raw json.RawMessage, target *map[string]T) error {
+	if err := json.Unmarshal(raw, target); len(raw) > 0 && err != nil {
+		return err
+	}
+	if len(*target) == 0 {
+		*target = nil
+	}
+	return nil
+}
+
+// bundleOptionSources collects the dynamic option sources of a field's options, including
+// those of a group's item fields. Sources referenced by ID are rewritten to reference them
+// by name, as IDs differ between environments.
+func (s *FormService) bundleOptionSources(ctx context.Context, options map[string]interface{}, sources map[string]db.FormOptionSourceInput) error {
+	if dynamic, ok := options["dynamic"].(map[string]interface{}); ok {
+		var source db.FormDynamicOption
+		var err error
+		if id, _ := dynamic["source_id"].(string); id != "" && id != uuid.Nil.String() {
+			sourceID, err := uuid.Parse(id)
+			if err != nil {
+				return fmt.Errorf("invalid option source %q: %w", id, err)
+			}
+			source, err = s.store.GetDynamicOption(ctx, sourceID)
+		} else {
+			name, _ := dynamic["source_name"].(string)
+			source, err = s.store.GetDynamicOptionByName(ctx, name)
+		}
+		if err != nil {
+			return fmt.Errorf("option source: %w", err)
+		}
+
+		delete(dynamic, "source_id")
+		dynamic["source_name"] = source.Name
+		sources[source.Name] = db.FormOptionSourceInput{
+			Name:          source.Name,
+			SourceType:    source.SourceType,
+			SourceConfig:  source.SourceConfig,
+			CacheDuration: source.CacheDuration,
+		}
+	}
+
+	items, _ := options["fields"].([]interface{})
+	for _, item := range items {
+		field, _ := item.(map[string]interface{})
+		itemOptions, _ := field["options"].(map[string]interface{})
+		if err := s.bundleOptionSources(ctx, itemOptions, sources); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func bundleApprovalWorkflow(raw json.RawMessage) (*db.ApprovalWorkflowInput, error) {
+	var workflow db.ApprovalWorkflowInput
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &workflow); err != nil {
+			return nil, fmt.Errorf("invalid approval workflow: %w", err)
+		}
+	}
+	if len(workflow.States) == 0 && len(workflow.Transitions) == 0 && len(workflow.Stages) == 0 {
+		return nil, nil
+	}
+	return &workflow, nil
+}
+
+func bundlePersistenceConfig(config db.FormPersistenceConfig) (*db.PersistenceConfigInput, error) {
+	input := &db.PersistenceConfigInput{PersistenceMode: config.PersistenceMode}
+	if err := json.Unmarshal(config.TargetConfigs, &input.TargetConfigs); len(config.TargetConfigs) > 0 && err != nil {
+		return nil, err
+	}
+	if err := decodeBundleObject(config.FieldMappings, &input.FieldMappings); err != nil {
+		return nil, err
+	}
+	if err := decodeBundleObject(config.TransformationRules, &input.TransformationRules); err != nil {
+		return nil, err
+	}
+	// Forms created without hooks store an empty object rather than a list
+	if hooks := bytes.TrimSpace(config.ValidationHooks); len(hooks) > 0 && hooks[0] == '[' {
+		if err := json.Unmarshal(hooks, &input.ValidationHooks); err != nil {
+			return nil, err
+		}
+	}
+	return input, nil
+}
+
+// ImportFormBundle validates a bundle and imports it. Validation problems are returned as a
+// *validator.ValidationError, and a dry run stops right after validation.
+func (s *FormService) ImportFormBundle(ctx context.Context, input ImportFormBundleInput) (*FormBundleImportResult, error) {
+	bundle := input.Bundle
+	if bundle.Format != FormBundleFormat || bundle.Version < 1 || bundle.Version > FormBundleVersion {
+		return nil, fmt.Errorf("%w: %s version %d", ErrUnsupportedBundle, bundle.Format, bundle.Version)
+	}
+
+	onConflict := input.OnConflict
+	if onConflict == "" {
+		onConflict = db.FormImportConflictFail
+	}
+
+	v := validator.New()
+	v.Check(validator.In(onConflict, db.FormImportConflictFail, db.FormImportConflictSkip, db.FormImportConflictNewVersion),
+		"on_conflict", "must be fail, skip or new_version")
+	s.validateFormBundle(v, bundle)
+
+	// Sources the bundle doesn't carry must already exist here
+	for _, name := range bundleSourceNames(bundle) {
+		if _, err := s.store.GetDynamicOptionByName(ctx, name); err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				return nil, err
+			}
+			v.AddError("option_sources."+name, "option source not found")
+		}
+	}
+
+	existing, err := s.store.GetFormDefinitionBySlug(ctx, bundle.Form.Slug)
+	exists := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if exists && onConflict == db.FormImportConflictNewVersion {
+		v.Check(existing.FormType == bundle.Form.FormType, "form.form_type",
+			fmt.Sprintf("the existing form is a %s form", existing.FormType))
+	}
+
+	if !v.Valid() {
+		return nil, validator.NewValidationError("invalid form bundle", v.Errors)
+	}
+
+	importInput, err := bundleImportInput(bundle, input.IDMap, input.ImportedBy)
+	if err != nil {
+		return nil, err
+	}
+	importInput.OnConflict = onConflict
+	importInput.ChangeNote = input.ChangeNote
+
+	result := &FormBundleImportResult{
+		Slug:     bundle.Form.Slug,
+		DryRun:   input.DryRun,
+		Warnings: bundleWarnings(importInput, input.IDMap),
+	}
+
+	if input.DryRun {
+		switch {
+		case !exists:
+			result.Action = db.FormImportCreated
+		case onConflict == db.FormImportConflictSkip:
+			result.Action = db.FormImportSkipped
+		case onConflict == db.FormImportConflictNewVersion:
+			result.Action = db.FormImportUpdated
+		default:
+			return nil, db.ErrFormSlugExists
+		}
+		if exists {
+			result.Form = &existing
+		}
+		return result, nil
+	}
+
+	imported, err := s.store.ImportFormDefinitionTx(ctx, importInput)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Action = imported.Action
+	result.Form = &imported.Form
+	result.OptionSourcesCreated = imported.OptionSourcesCreated
+	result.EventsCreated = imported.EventsCreated
+	result.AssignmentsCreated = imported.AssignmentsCreated
+	return result, nil
+}
+
+// validateFormBundle checks that a bundle is complete and consistent on its own
+func (s *FormService) validateFormBundle(v *validator.Validator, bundle *FormBundle) {
+	form := bundle.Form
+	v.Check(form.Name != "", "form.name", "must be provided")
+	v.Check(form.Slug != "", "form.slug", "must be provided")
+	v.Check(form.FormType != "", "form.form_type", "must be provided")
+	v.Check(len(form.Fields) > 0, "form.fields", "must have at least one field")
+
+	steps := make(map[int]bool, len(form.Steps))
+	for _, step := range form.Steps {
+		key := fmt.Sprintf("form.steps[%d]", step.StepNumber)
+		v.Check(step.StepNumber > 0, key, "step number must be positive")
+		v.Check(!steps[step.StepNumber], key, "duplicate step number")
+		steps[step.StepNumber] = true
+	}
+
+	names := make(map[string]bool, len(form.Fields))
+	fieldTypes := make(map[string]string, len(form.Fields))
+	for _, field := range form.Fields {
+		v.Check(!names[field.FieldName], "form.fields."+field.FieldName, "duplicate field")
+		names[field.FieldName] = true
+		fieldTypes[field.FieldName] = field.FieldType
+	}
+
+	for _, step := range form.Steps {
+		s.checkBundleCustomRules(v, fmt.Sprintf("form.steps[%d].custom_rules", step.StepNumber), "", step.ValidationRules, fieldTypes)
+	}
+
+	for i, field := range form.Fields {
+		key := "form.fields." + field.FieldName
+		if field.FieldName == "" {
+			key = fmt.Sprintf("form.fields[%d]", i)
+			v.AddError(key, "must have a field name")
+		}
+		v.Check(field.FieldType != "", key, "must have a field type")
+		if form.IsMultiStep {
+			v.Check(steps[field.StepNumber], key, fmt.Sprintf("step %d does not exist", field.StepNumber))
+		}
+		if IsGroupFieldType(field.FieldType) {
+			items, _ := field.Options["fields"].([]interface{})
+			v.Check(len(items) > 0, key+".options.fields", "group fields must have at least one field")
+		}
+
+		s.checkBundleCustomRules(v, key+".custom_rules", field.FieldName, field.ValidationRules, fieldTypes)
+
+		if field.Prefill != nil {
+			raw, err := json.Marshal(field.Prefill)
+			if err != nil {
+				v.AddError(key+".prefill", err.Error())
+				continue
+			}
+			config, err := ParsePrefillConfig(raw)
+			if err == nil && config != nil {
+				err = ValidatePrefillConfig(field.FieldType, *config)
+			}
+			if err != nil {
+				v.AddError(key+".prefill", err.Error())
+			}
+		}
+
+		if field.ConditionalLogic != nil {
+			raw, err := json.Marshal(field.ConditionalLogic)
+			if err != nil {
+				v.AddError(key+".conditional_logic", err.Error())
+				continue
+			}
+			logic, err := ParseConditionalLogic(raw)
+			if err != nil {
+				v.AddError(key+".conditional_logic", err.Error())
+				continue
+			}
+			for _, name := range conditionFields(logic.ConditionGroup) {
+				v.Check(names[name] && name != field.FieldName, key+".conditional_logic", fmt.Sprintf("references unknown field %q", name))
+			}
+		}
+	}
+
+	if form.RequiresApproval && form.ApprovalWorkflow != nil && len(form.ApprovalWorkflow.Stages) > 0 {
+		raw, err := json.Marshal(form.ApprovalWorkflow)
+		if err == nil {
+			var workflow *ApprovalWorkflow
+			workflow, err = ParseApprovalWorkflow(db.FormDefinition{FormType: form.FormType, ApprovalWorkflow: raw})
+			if err == nil {
+				minimum := MinimumApprovers[form.FormType]
+				v.Check(workflow.RequiredApprovers() >= minimum, "form.approval_workflow.stages",
+					fmt.Sprintf("%s forms require at least %d approvers", form.FormType, minimum))
+			}
+		}
+		if err != nil {
+			v.AddError("form.approval_workflow", err.Error())
+		}
+	}
+
+	for _, source := range bundle.OptionSources {
+		key := "option_sources." + source.Name
+		v.Check(source.Name != "", "option_sources", "must have a name")
+		_, ok := s.optionSource(source.SourceType)
+		v.Check(ok, key, fmt.Sprintf("unknown source type %q", source.SourceType))
+	}
+
+	for i, event := range bundle.Events {
+		key := fmt.Sprintf("events[%d]", i)
+		v.Check(event.EventType != "", key, "must have an event type")
+		_, ok := s.eventHandler(event.HandlerType)
+		v.Check(ok, key, fmt.Sprintf("unknown handler type %q", event.HandlerType))
+		for _, path := range redactedHandlerValues(event.HandlerType, event.HandlerConfig) {
+			v.AddError(key+"."+path, "was redacted on export and must be supplied")
+		}
+	}
+
+	for i, assignment := range bundle.Assignments {
+		key := fmt.Sprintf("assignments[%d]", i)
+		v.Check(assignment.AssignmentValue != "", key, "must have a value")
+		if err := ValidateFormAssignment(assignment.AssignmentType, assignment.Conditions); err != nil {
+			v.AddError(key, err.Error())
+		}
+	}
+}
+
+// redactHandlerConfig replaces the secret and header values of a webhook config with
+// RedactedBundleValue, keeping the rest of the config as it is
+func redactHandlerConfig(handlerType string, raw json.RawMessage) (json.RawMessage, error) {
+	if handlerType != HandlerTypeWebhook || len(raw) == 0 {
+		return raw, nil
+	}
+
+	var config map[string]interface{}
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil, err
+	}
+	if secret, ok := config["secret"].(string); ok && secret != "" {
+		config["secret"] = RedactedBundleValue
+	}
+	if headers, ok := config["headers"].(map[string]interface{}); ok {
+		for name := range headers {
+			headers[name] = RedactedBundleValue
+		}
+	}
+	return json.Marshal(config)
+}
+
+// redactedHandlerValues lists the values of a webhook config that are still redacted, as
+// "secret" and "headers.<name>"
+func redactedHandlerValues(handlerType string, raw json.RawMessage) []string {
+	if handlerType != HandlerTypeWebhook || len(raw) == 0 {
+		return nil
+	}
+
+	var config WebhookConfig
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil
+	}
+	var paths []string
+	if config.Secret == RedactedBundleValue {
+		paths = append(paths, "secret")
+	}
+	for name, value := range config.Headers {
+		if value == RedactedBundleValue {
+			paths = append(paths, "headers."+name)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// conditionFields lists the fields a condition group references
+func conditionFields(group ConditionGroup) []string {
+	var fields []string
+	for _, condition := range group.Conditions {
+		fields = append(fields, condition.Field)
+	}
+	for _, nested := range group.Groups {
+		fields = append(fields, conditionFields(nested)...)
+	}
+	return fields
+}
+
+// bundleSourceNames lists the option sources the fields of a bundle use but the bundle does
+// not include
+func bundleSourceNames(bundle *FormBundle) []string {
+	included := make(map[string]bool, len(bundle.OptionSources))
+	for _, source := range bundle.OptionSources {
+		included[source.Name] = true
+	}
+
+	var names []string
+	var collect func(options map[string]interface{})
+	collect = func(options map[string]interface{}) {
+		if dynamic, ok := options["dynamic"].(map[string]interface{}); ok {
+			if name, _ := dynamic["source_name"].(string); name != "" && !included[name] {
+				included[name] = true
+				names = append(names, name)
+			}
+		}
+		items, _ := options["fields"].([]interface{})
+		for _, item := range items {
+			field, _ := item.(map[string]interface{})
+			itemOptions, _ := field["options"].(map[string]interface{})
+			collect(itemOptions)
+		}
+	}
+	for _, field := range bundle.Form.Fields {
+		collect(field.Options)
+	}
+	return names
+}
+
+// bundleImportInput builds the store input of a bundle, replacing the IDs of idMap in event
+// configs, option source configs and assignments
+func bundleImportInput(bundle *FormBundle, idMap map[string]string, importedBy uuid.UUID) (*db.FormImportInput, error) {
+	def := bundle.Form
+	def.CreatedBy = importedBy
+
+	input := &db.FormImportInput{Definition: &def}
+
+	for _, source := range bundle.OptionSources {
+		config, err := remapBundleIDs(source.SourceConfig, idMap)
+		if err != nil {
+			return nil, fmt.Errorf("option source %s: %w", source.Name, err)
+		}
+		source.SourceConfig = config
+		input.OptionSources = append(input.OptionSources, source)
+	}
+
+	for _, event := range bundle.Events {
+		config, err := remapBundleIDs(event.HandlerConfig, idMap)
+		if err != nil {
+			return nil, fmt.Errorf("%s event: %w", event.EventType, err)
+		}
+		event.HandlerConfig = config
+		input.Events = append(input.Events, event)
+	}
+
+	for _, assignment := range bundle.Assignments {
+		if mapped, ok := idMap[assignment.AssignmentValue]; ok {
+			assignment.AssignmentValue = mapped
+		}
+		conditions, err := remapBundleIDs(assignment.Conditions, idMap)
+		if err != nil {
+			return nil, fmt.Errorf("%s assignment: %w", assignment.AssignmentType, err)
+		}
+		assignment.Conditions = conditions
+		input.Assignments = append(input.Assignments, assignment)
+	}
+
+	return input, nil
+}
+
+// remapBundleIDs replaces every string of a JSON document that is a key of idMap
+func remapBundleIDs(raw json.RawMessage, idMap map[string]string) (json.RawMessage, error) {
+	if len(idMap) == 0 || len(raw) == 0 {
+		return raw, nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, err
+	}
+
+	var remap func(value interface{}) interface{}
+	remap = func(value interface{}) interface{} {
+		switch v := value.(type) {
+		case string:
+			if mapped, ok := idMap[v]; ok {
+				return mapped
+			}
+		case map[string]interface{}:
+			for key, item := range v {
+				v[key] = remap(item)
+			}
+		case []interface{}:
+			for i, item := range v {
+				v[i] = remap(item)
+			}
+		}
+		return value
+	}
+
+	return json.Marshal(remap(value))
+}
+
+// bundleWarnings flags the parts of an import that likely refer to the source environment
+func bundleWarnings(input *db.FormImportInput, idMap map[string]string) []string {
+	warnings := []string{}
+	mapped := make(map[string]bool, len(idMap))
+	for _, id := range idMap {
+		mapped[id] = true
+	}
+
+	for _, assignment := range input.Assignments {
+		if assignment.AssignmentType == "user_id" && !mapped[assignment.AssignmentValue] {
+			warnings = append(warnings, fmt.Sprintf("user_id assignment %s was not remapped and may not exist here", assignment.AssignmentValue))
+		}
+	}
+	return warnings
+}
+
+// FormBundleEncoding is the encoding of a bundle file, by its extension
+func FormBundleEncoding(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return BundleEncodingYAML
+	default:
+		return BundleEncodingJSON
+	}
+}
+
+// EncodeFormBundle writes a bundle as indented JSON or as YAML
+func EncodeFormBundle(w io.Writer, bundle *FormBundle, encoding string) error {
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if encoding != BundleEncodingYAML {
+		_, err = w.Write(append(data, '\n'))
+		return err
+	}
+
+	// JSON is YAML, so decoding it keeps the field order of the JSON encoding. Clearing the
+	// styles writes it back in block style.
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	clearYAMLStyle(&node)
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func clearYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearYAMLStyle(child)
+	}
+}
+
+// DecodeFormBundle reads a JSON or YAML bundle
+func DecodeFormBundle(r io.Reader, encoding string) (*FormBundle, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if encoding == BundleEncodingYAML {
+		var value interface{}
+		if err := yaml.Unmarshal(data, &value); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedBundle, err)
+		}
+		if data, err = json.Marshal(value); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedBundle, err)
+		}
+	}
+
+	var bundle FormBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedBundle, err)
+	}
+	return &bundle, nil
+}
+
+// checkBundleCustomRules checks the custom rules of the validation rules of a field or a step
+func (s *FormService) checkBundleCustomRules(v *validator.Validator, key, fieldName string, validationRules map[string]interface{}, fieldTypes map[string]string) {
+	raw, ok := validationRules["custom_rules"]
+	if !ok || raw == nil {
+		return
+	}
+	rules, ok := raw.(map[string]interface{})
+	if !ok {
+		v.AddError(key, "must be an object of rules by name")
+		return
+	}
+	s.CheckCustomRules(v, key, fieldName, rules, fieldTypes)
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+func testFormBundle() *FormBundle {
+	return &FormBundle{
+		Format:     FormBundleFormat,
+		Version:    FormBundleVersion,
+		ExportedAt: time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC),
+		Source:     FormBundleSource{FormID: uuid.New(), FormVersion: 3},
+		Form: db.FormDefinitionInput{
+			Name:        "Business Verification (KYB)",
+			Slug:        "kyb-verification",
+			FormType:    "kyb",
+			IsMultiStep: true,
+			Steps: []db.StepInput{
+				{StepNumber: 1, Name: "Business"},
+				{StepNumber: 2, Name: "Owners"},
+			},
+			Fields: []db.FieldInput{
+				{FieldName: "business_type", FieldType: "select", StepNumber: 1, Label: map[string]string{"en": "Business Type"}},
+				{
+					FieldName:  "registration_number",
+					FieldType:  "text",
+					StepNumber: 1,
+					ConditionalLogic: map[string]interface{}{
+						"action":     "require",
+						"conditions": []interface{}{map[string]interface{}{"field": "business_type", "operator": "equals", "value": "llc"}},
+					},
+				},
+				{
+					FieldName:  "industry",
+					FieldType:  "select",
+					StepNumber: 2,
+					Options: map[string]interface{}{
+						"type":    "dynamic",
+						"dynamic": map[string]interface{}{"source_name": "industries"},
+					},
+				},
+			},
+		},
+		OptionSources: []db.FormOptionSourceInput{
+			{Name: "industries", SourceType: "static", SourceConfig: json.RawMessage(`{"options":[]}`)},
+		},
+		Events: []db.FormEventInput{
+			{EventType: "submitted", HandlerType: "webhook", HandlerConfig: json.RawMessage(`{"url":"https://example.com","owner":"11111111-1111-1111-1111-111111111111"}`), IsActive: true},
+		},
+		Assignments: []db.FormAssignmentInput{
+			{AssignmentType: "user_type", AssignmentValue: "business", Priority: 10},
+			{AssignmentType: "user_id", AssignmentValue: "22222222-2222-2222-2222-222222222222"},
+		},
+	}
+}
+
+func testBundleService() *FormService {
+	return &FormService{
+		eventHandlers: map[string]EventHandler{"webhook": nil},
+		optionSources: map[string]OptionSource{"static": nil},
+	}
+}
+
+func TestBundleFieldInput(t *testing.T) {
+	field := db.FormField{
+		FieldName:        "country",
+		FieldType:        "select",
+		Label:            json.RawMessage(`{"en":"Country"}`),
+		Placeholder:      json.RawMessage(`{}`),
+		HelpText:         json.RawMessage(`{}`),
+		ValidationRules:  json.RawMessage(`{"required":true}`),
+		Options:          json.RawMessage(`{"type":"static","static":[{"value":"NG","label":{"en":"Nigeria"}}]}`),
+		DisplayOrder:     4,
+		IsRequired:       true,
+		DefaultValue:     "NG",
+		ConditionalLogic: json.RawMessage(`{}`),
+		FileConfig:       json.RawMessage(`{}`),
+	}
+
+	input, err := bundleFieldInput(field, 2)
+	require.NoError(t, err)
+	require.Equal(t, 2, input.StepNumber)
+	require.Equal(t, 4, input.DisplayOrder)
+	require.Equal(t, "Country", input.Label["en"])
+	require.Equal(t, "NG", *input.DefaultValue)
+	require.Equal(t, true, input.ValidationRules["required"])
+	require.Equal(t, "static", input.Options["type"])
+
+	// Empty objects are left out of the bundle
+	require.Nil(t, input.Placeholder)
+	require.Nil(t, input.HelpText)
+	require.Nil(t, input.ConditionalLogic)
+	require.Nil(t, input.FileConfig)
+}
+
+func TestBundleImportInput(t *testing.T) {
+	bundle := testFormBundle()
+	importer := uuid.New()
+	idMap := map[string]string{
+		"11111111-1111-1111-1111-111111111111": "33333333-3333-3333-3333-333333333333",
+	}
+
+	input, err := bundleImportInput(bundle, idMap, importer)
+	require.NoError(t, err)
+	require.Equal(t, importer, input.Definition.CreatedBy)
+	require.Equal(t, uuid.Nil, bundle.Form.CreatedBy)
+	require.JSONEq(t, `{"url":"https://example.com","owner":"33333333-3333-3333-3333-333333333333"}`, string(input.Events[0].HandlerConfig))
+
+	// The user of an unmapped user_id assignment probably doesn't exist here
+	warnings := bundleWarnings(input, idMap)
+	require.Len(t, warnings, 1)
+	require.Contains(t, warnings[0], "22222222-2222-2222-2222-222222222222")
+
+	idMap["22222222-2222-2222-2222-222222222222"] = "44444444-4444-4444-4444-444444444444"
+	input, err = bundleImportInput(bundle, idMap, importer)
+	require.NoError(t, err)
+	require.Equal(t, "44444444-4444-4444-4444-444444444444", input.Assignments[1].AssignmentValue)
+	require.Empty(t, bundleWarnings(input, idMap))
+}
+
+func TestValidateFormBundle(t *testing.T) {
+	s := testBundleService()
+
+	v := validator.New()
+	s.validateFormBundle(v, testFormBundle())
+	require.True(t, v.Valid(), v.Errors)
+
+	bundle := testFormBundle()
+	bundle.Form.Fields[0].StepNumber = 3
+	bundle.Form.Fields = append(bundle.Form.Fields, db.FieldInput{FieldName: "industry", FieldType: "text", StepNumber: 1})
+	bundle.Form.Fields[1].ConditionalLogic["conditions"] = []interface{}{map[string]interface{}{"field": "legal_form", "operator": "equals"}}
+	bundle.Form.RequiresApproval = true
+	bundle.Form.ApprovalWorkflow = &db.ApprovalWorkflowInput{Stages: []map[string]interface{}{{"name": "compliance", "permission": "admin.forms"}}}
+	bundle.Events[0].HandlerType = "carrier_pigeon"
+	bundle.Assignments[0].AssignmentType = "region"
+
+	v = validator.New()
+	s.validateFormBundle(v, bundle)
+	require.Contains(t, v.Errors["form.fields.business_type"], "step 3")
+	require.Equal(t, "duplicate field", v.Errors["form.fields.industry"])
+	require.Contains(t, v.Errors["form.fields.registration_number.conditional_logic"], "legal_form")
+	require.Contains(t, v.Errors["form.approval_workflow.stages"], "at least 2 approvers")
+	require.Contains(t, v.Errors["events[0]"], "carrier_pigeon")
+	require.Contains(t, v.Errors["assignments[0]"], "region")
+
+	// Sources the bundle doesn't carry have to exist where it is imported
+	bundle = testFormBundle()
+	require.Empty(t, bundleSourceNames(bundle))
+	bundle.OptionSources = nil
+	require.Equal(t, []string{"industries"}, bundleSourceNames(bundle))
+}
+
+func TestBundleWebhookSecrets(t *testing.T) {
+	config := json.RawMessage(`{"url":"https://example.com","secret":"s3cret","headers":{"Authorization":"Bearer abc"},"timeout_seconds":5}`)
+
+	redacted, err := redactHandlerConfig(HandlerTypeWebhook, config)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"url":"https://example.com","secret":"<redacted>","headers":{"Authorization":"<redacted>"},"timeout_seconds":5}`, string(redacted))
+	require.NotContains(t, string(redacted), "s3cret")
+
+	// Other handlers and webhooks without a secret are exported as they are
+	email := json.RawMessage(`{"to":["ops@example.com"]}`)
+	unchanged, err := redactHandlerConfig(HandlerTypeEmail, email)
+	require.NoError(t, err)
+	require.Equal(t, email, unchanged)
+	unsigned, err := redactHandlerConfig(HandlerTypeWebhook, json.RawMessage(`{"url":"https://example.com","secret":""}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"url":"https://example.com","secret":""}`, string(unsigned))
+
+	// Importing needs the redacted values supplied again
+	s := testBundleService()
+	bundle := testFormBundle()
+	bundle.Events[0].HandlerType = HandlerTypeWebhook
+	bundle.Events[0].HandlerConfig = redacted
+
+	v := validator.New()
+	s.validateFormBundle(v, bundle)
+	require.Contains(t, v.Errors["events[0].secret"], "redacted")
+	require.Contains(t, v.Errors["events[0].headers.Authorization"], "redacted")
+
+	bundle.Events[0].HandlerConfig = config
+	v = validator.New()
+	s.validateFormBundle(v, bundle)
+	require.True(t, v.Valid(), v.Errors)
+}
+
+func TestFormBundleEncoding(t *testing.T) {
+	bundle := testFormBundle()
+
+	var yamlData bytes.Buffer
+	require.NoError(t, EncodeFormBundle(&yamlData, bundle, BundleEncodingYAML))
+	require.True(t, strings.HasPrefix(yamlData.String(), "format: "+FormBundleFormat+"\n"))
+	require.Contains(t, yamlData.String(), "\n  slug: kyb-verification\n")
+
+	var jsonData bytes.Buffer
+	require.NoError(t, EncodeFormBundle(&jsonData, bundle, BundleEncodingJSON))
+
+	fromYAML, err := DecodeFormBundle(&yamlData, BundleEncodingYAML)
+	require.NoError(t, err)
+	fromJSON, err := DecodeFormBundle(&jsonData, BundleEncodingJSON)
+	require.NoError(t, err)
+	require.Equal(t, fromJSON.Form, fromYAML.Form)
+	require.Equal(t, fromJSON.Assignments, fromYAML.Assignments)
+	require.Equal(t, bundle.ExportedAt, fromYAML.ExportedAt)
+	require.JSONEq(t, string(bundle.Events[0].HandlerConfig), string(fromYAML.Events[0].HandlerConfig))
+
+	_, err = DecodeFormBundle(strings.NewReader("form: [unterminated"), BundleEncodingYAML)
+	require.ErrorIs(t, err, ErrUnsupportedBundle)
+
+	require.Equal(t, BundleEncodingYAML, FormBundleEncoding("seeds/kyb.form.YML"))
+	require.Equal(t, BundleEncodingJSON, FormBundleEncoding("seeds/kyb.form.json"))
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	defaultClamAVTimeout = 30 * time.Second
+	clamAVChunkSize      = 64 * 1024
+)
+
+// ClamAVScanner scans files with a clamd daemon using its INSTREAM command, so the daemon does
+// not need access to the files. Files larger than clamd's StreamMaxLength fail to scan.
+type ClamAVScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamAVScanner creates a scanner for the clamd listening on address, either host:port or
+// unix:/path/to/clamd.sock. The timeout bounds a whole scan, including connecting.
+func NewClamAVScanner(address string, timeout time.Duration) *ClamAVScanner {
+	if timeout <= 0 {
+		timeout = defaultClamAVTimeout
+	}
+
+	network := "tcp"
+	if path, ok := strings.CutPrefix(address, "unix:"); ok {
+		network, address = "unix", path
+	} else if strings.HasPrefix(address, "/") {
+		network = "unix"
+	}
+
+	return &ClamAVScanner{network: network, address: address, timeout: timeout}
+}
+
+// ScanFile streams the file to clamd. An error means the file could not be scanned, not that
+// it is infected.
+func (c *ClamAVScanner) ScanFile(file multipart.File) (ScanResult, error) {
+	reply, err := c.command("zINSTREAM", file)
+	if err != nil {
+		return ScanResult{}, err
+	}
+
+	// Replies are "stream: OK", "stream: <signature> FOUND" or "<reason> ERROR"
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return ScanResult{Status: ScanStatusClean, ScannedAt: time.Now()}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return ScanResult{
+			Status:    ScanStatusInfected,
+			Signature: strings.TrimSuffix(reply, " FOUND"),
+			ScannedAt: time.Now(),
+		}, nil
+	default:
+		return ScanResult{}, fmt.Errorf("clamav: %s", reply)
+	}
+}
+
+// Ping checks that clamd is reachable
+func (c *ClamAVScanner) Ping() error {
+	reply, err := c.command("zPING", nil)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("clamav: unexpected reply to ping %q", reply)
+	}
+	return nil
+}
+
+// command sends a null terminated command, followed by the content of stream in length
+// prefixed chunks when it is not nil, and returns clamd's reply
+func (c *ClamAVScanner) command(command string, stream io.Reader) (string, error) {
+	conn, err := net.DialTimeout(c.network, c.address, c.timeout)
+	if err != nil {
+		return "", fmt.Errorf("clamav: %w", err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return "", fmt.Errorf("clamav: %w", err)
+	}
+
+	w := bufio.NewWriterSize(conn, clamAVChunkSize+4)
+	w.WriteString(command + "\x00")
+
+	if stream != nil {
+		chunk := make([]byte, clamAVChunkSize)
+		size := make([]byte, 4)
+		for {
+			n, err := io.ReadFull(stream, chunk)
+			if n > 0 {
+				binary.BigEndian.PutUint32(size, uint32(n))
+				w.Write(size)
+				w.Write(chunk[:n])
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err != nil {
+				return "", fmt.Errorf("clamav: reading file: %w", err)
+			}
+		}
+
+		// A zero length chunk ends the stream
+		binary.BigEndian.PutUint32(size, 0)
+		w.Write(size)
+	}
+
+	// bufio.Writer keeps the first write error, so checking Flush covers every write
+	if err := w.Flush(); err != nil {
+		// clamd closes the connection early when the stream exceeds its size limit, and says
+		// so in its reply
+		if reply, readErr := readClamAVReply(conn); readErr == nil && reply != "" {
+			return reply, nil
+		}
+		return "", fmt.Errorf("clamav: %w", err)
+	}
+
+	reply, err := readClamAVReply(conn)
+	if err != nil {
+		return "", fmt.Errorf("clamav: %w", err)
+	}
+	return reply, nil
+}
+
+// readClamAVReply reads a reply terminated by a null byte, as clamd sends to z-prefixed commands
+func readClamAVReply(r io.Reader) (string, error) {
+	reply, err := bufio.NewReader(r).ReadBytes(0)
+	if err != nil && !(err == io.EOF && len(reply) > 0) {
+		return "", err
+	}
+	return string(bytes.TrimRight(reply, "\x00\n")), nil
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/timchuks/monieverse/internal/config"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/logger"
+	"github.com/timchuks/monieverse/internal/uploader"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// stubClamd answers INSTREAM and PING commands like clamd, reporting streams containing
+// EICAR as infected. It returns the address to give NewClamAVScanner.
+func stubClamd(t *testing.T, network, address string, received chan<- []byte) string {
+	listener, err := net.Listen(network, address)
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveClamd(conn, received)
+		}
+	}()
+
+	if network == "unix" {
+		return "unix:" + listener.Addr().String()
+	}
+	return listener.Addr().String()
+}
+
+func serveClamd(conn net.Conn, received chan<- []byte) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	command, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+	switch command {
+	case "zPING\x00":
+		conn.Write([]byte("PONG\x00"))
+		return
+	case "zINSTREAM\x00":
+	default:
+		conn.Write([]byte("UNKNOWN COMMAND\x00"))
+		return
+	}
+
+	var data []byte
+	size := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, size); err != nil {
+			return
+		}
+		n := binary.BigEndian.Uint32(size)
+		if n == 0 {
+			break
+		}
+		if len(data)+int(n) > 1<<20 {
+			conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+			io.Copy(io.Discard, r)
+			return
+		}
+		chunk := make([]byte, n)
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return
+		}
+		data = append(data, chunk...)
+	}
+	if received != nil {
+		received <- data
+	}
+
+	if bytes.Contains(data, []byte("EICAR")) {
+		conn.Write([]byte("stream: Win.Test.EICAR_HDB-1 FOUND\x00"))
+		return
+	}
+	conn.Write([]byte("stream: OK\x00"))
+}
+
+// scanTestFile is an in-memory multipart.File
+type scanTestFile struct {
+	*bytes.Reader
+}
+
+func (scanTestFile) Close() error { return nil }
+
+func newScanTestFile(content string) scanTestFile {
+	return scanTestFile{bytes.NewReader([]byte(content))}
+}
+
+func TestClamAVScanner(t *testing.T) {
+	received := make(chan []byte, 1)
+	scanner := NewClamAVScanner(stubClamd(t, "tcp", "127.0.0.1:0", received), time.Second)
+	require.NoError(t, scanner.Ping())
+
+	// Larger files are streamed in several chunks
+	content := strings.Repeat("passport scan ", 20000)
+	result, err := scanner.ScanFile(newScanTestFile(content))
+	require.NoError(t, err)
+	require.Equal(t, ScanStatusClean, result.Status)
+	require.False(t, result.ScannedAt.IsZero())
+	require.Equal(t, content, string(<-received))
+
+	result, err = scanner.ScanFile(newScanTestFile(`X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`))
+	require.NoError(t, err)
+	require.Equal(t, ScanStatusInfected, result.Status)
+	require.Equal(t, "Win.Test.EICAR_HDB-1", result.Signature)
+	<-received
+
+	// clamd refuses streams over its size limit
+	_, err = scanner.ScanFile(newScanTestFile(strings.Repeat("a", 2<<20)))
+	require.ErrorContains(t, err, "size limit exceeded")
+}
+
+func TestClamAVScannerUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "clamd.sock")
+	address := stubClamd(t, "unix", socket, nil)
+	require.Equal(t, "unix:"+socket, address)
+
+	result, err := NewClamAVScanner(address, time.Second).ScanFile(newScanTestFile("utility bill"))
+	require.NoError(t, err)
+	require.Equal(t, ScanStatusClean, result.Status)
+}
+
+func TestClamAVScannerTimeout(t *testing.T) {
+	// A daemon that accepts connections and never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(io.Discard, conn)
+				conn.Close()
+			}()
+		}
+	}()
+
+	started := time.Now()
+	_, err = NewClamAVScanner(listener.Addr().String(), 100*time.Millisecond).ScanFile(newScanTestFile("selfie"))
+	require.Error(t, err)
+	require.Less(t, time.Since(started), 2*time.Second)
+
+	// Nothing listening
+	address := listener.Addr().String()
+	listener.Close()
+	_, err = NewClamAVScanner(address, 100*time.Millisecond).ScanFile(newScanTestFile("selfie"))
+	require.Error(t, err)
+}
+
+// quarantineUploader records where files are uploaded
+type quarantineUploader struct {
+	uploads map[string]string
+}
+
+func (u *quarantineUploader) Upload(file io.Reader, bucket string, path string) error {
+	data, err := io.ReadAll(file)
+	u.uploads[bucket+"/"+path] = string(data)
+	return err
+}
+
+func (u *quarantineUploader) Info(string, string) (*uploader.FileInfo, error) { return nil, nil }
+
+func (u *quarantineUploader) Delete(string, string) error { return nil }
+
+func (u *quarantineUploader) GetTempURL(string, string) (string, error) { return "", nil }
+
+func (u *quarantineUploader) Download(string, string) (io.ReadCloser, error) { return nil, nil }
+
+func TestScanUpload(t *testing.T) {
+	cfg := &config.Config{FileBucket: "uploads", QuarantineBucket: "quarantine-bucket"}
+	uploads := &quarantineUploader{uploads: map[string]string{}}
+	address := stubClamd(t, "tcp", "127.0.0.1:0", nil)
+	s := &FormService{
+		config:        cfg,
+		uploader:      uploads,
+		logger:        logger.NewZeroLogger(io.Discard, logger.LevelOff, nil),
+		fileValidator: NewFileValidator(cfg, NewClamAVScanner(address, time.Second), nil),
+	}
+	userID := uuid.New()
+
+	// Clean files are left for the caller to upload, rewound
+	file := newScanTestFile("passport")
+	result, err := s.scanUpload(file, userID, "passport", "passport.jpg", "forms/u/passport/a.jpg")
+	require.NoError(t, err)
+	require.Equal(t, ScanStatusClean, result.Status)
+	require.Empty(t, uploads.uploads)
+	data, _ := io.ReadAll(file)
+	require.Equal(t, "passport", string(data))
+
+	recorded := withScanResult(db.FormSubmissionFileInput{FieldName: "passport"}, result)
+	require.Equal(t, ScanStatusClean, recorded.ScanStatus)
+	require.True(t, recorded.ScannedAt.Valid)
+
+	// Infected files go to quarantine and fail validation on their field
+	_, err = s.scanUpload(newScanTestFile("EICAR"), userID, "passport", "passport.jpg", "forms/u/passport/b.jpg")
+	var validationErr *validator.ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	require.Contains(t, validationErr.Fields["passport"], "failed virus scan")
+	require.Equal(t, "EICAR", uploads.uploads["quarantine-bucket/"+uploader.QuarantinePath("forms/u/passport/b.jpg")])
+
+	// A scanner that is down rejects uploads, unless the policy fails open
+	s.fileValidator = NewFileValidator(cfg, NewClamAVScanner("127.0.0.1:1", 100*time.Millisecond), nil)
+	_, err = s.scanUpload(newScanTestFile("passport"), userID, "passport", "passport.jpg", "forms/u/passport/c.jpg")
+	require.ErrorIs(t, err, ErrVirusScanUnavailable)
+
+	cfg.VirusScanFailOpen = true
+	result, err = s.scanUpload(newScanTestFile("passport"), userID, "passport", "passport.jpg", "forms/u/passport/c.jpg")
+	require.NoError(t, err)
+	require.Equal(t, ScanStatusFailed, result.Status)
+	require.NotEmpty(t, result.Signature)
+
+	// Without a scanner nothing is scanned
+	s.fileValidator = NewFileValidator(cfg, NewNullVirusScanner(), nil)
+	result, err = s.scanUpload(newScanTestFile("EICAR"), userID, "passport", "passport.jpg", "forms/u/passport/d.jpg")
+	require.NoError(t, err)
+	require.Equal(t, ScanStatusNotScanned, result.Status)
+	require.False(t, withScanResult(db.FormSubmissionFileInput{}, result).ScannedAt.Valid)
+}
+
+// countingScanner counts the scans of an inner scanner
+type countingScanner struct {
+	VirusScanner
+	scans int
+}
+
+func (c *countingScanner) ScanFile(file multipart.File) (ScanResult, error) {
+	c.scans++
+	return c.VirusScanner.ScanFile(file)
+}
+
+func newScanTestFileHeader(t *testing.T, field, name, content string) *multipart.FileHeader {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile(field, name)
+	require.NoError(t, err)
+	part.Write([]byte(content))
+	require.NoError(t, w.Close())
+
+	form, err := multipart.NewReader(&body, w.Boundary()).ReadForm(1 << 20)
+	require.NoError(t, err)
+	return form.File[field][0]
+}
+
+func TestValidatedUploadsScanOnce(t *testing.T) {
+	cfg := &config.Config{FileBucket: "uploads"}
+	uploads := &quarantineUploader{uploads: map[string]string{}}
+	scanner := &countingScanner{VirusScanner: NewClamAVScanner(stubClamd(t, "tcp", "127.0.0.1:0", nil), time.Second)}
+	s := &FormService{
+		config:        cfg,
+		uploader:      uploads,
+		logger:        logger.NewZeroLogger(io.Discard, logger.LevelOff, nil),
+		fileValidator: NewFileValidator(cfg, scanner, nil),
+	}
+	fields := []db.FormField{{FieldName: "passport", FieldType: "file", FileConfig: []byte(`{"scan_for_virus":true}`)}}
+	files := map[string][]*multipart.FileHeader{"passport": {newScanTestFileHeader(t, "passport", "passport.jpg", "passport")}}
+
+	scans := FileScans{}
+	step := int32(1)
+	validationCtx := ValidationContext{Mode: ValidationModeStep, StepNumber: &step, FileScans: scans}
+	require.NoError(t, s.ValidateSubmissionWithFiles(fields, map[string]interface{}{}, files, validationCtx))
+	require.Equal(t, 1, scanner.scans)
+	require.Equal(t, ScanStatusClean, scans[files["passport"][0]].Status)
+
+	// The upload keeps the verdict of validation
+	uploaded, err := s.handleValidatedFileUploads(context.Background(), fields, uuid.New(), files, scans)
+	require.NoError(t, err)
+	require.Len(t, uploaded, 1)
+	require.Equal(t, 1, scanner.scans)
+	require.Equal(t, ScanStatusClean, uploaded[0].ScanStatus)
+	require.Len(t, uploads.uploads, 1)
+
+	// Files that weren't scanned during validation are scanned on upload
+	_, err = s.handleValidatedFileUploads(context.Background(), fields, uuid.New(), files, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, scanner.scans)
+}
@@ -0,0 +1,375 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"strconv"
+	"strings"
+
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+)
+
+// Conditional logic actions
+const (
+	ConditionActionShow    = "show"
+	ConditionActionHide    = "hide"
+	ConditionActionRequire = "require"
+)
+
+// Conditional logic operators. The same set is implemented by the React FormRenderer
+// (frontend/src/components/FormRenderer/conditionalLogic.ts) and both must stay in sync.
+const (
+	OperatorEquals     = "equals"
+	OperatorNotEquals  = "not_equals"
+	OperatorIn         = "in"
+	OperatorNotIn      = "not_in"
+	OperatorGt         = "gt"
+	OperatorGte        = "gte"
+	OperatorLt         = "lt"
+	OperatorLte        = "lte"
+	OperatorContains   = "contains"
+	OperatorIsEmpty    = "is_empty"
+	OperatorIsNotEmpty = "is_not_empty"
+)
+
+// Condition compares the value of another field against Value
+type Condition struct {
+	Field    string      `json:"field"`
+	Operator string      `json:"operator"`
+	Value    interface{} `json:"value"`
+}
+
+// ConditionGroup combines conditions and nested groups with "all" (and) or "any" (or)
+type ConditionGroup struct {
+	Logic      string           `json:"logic"`
+	Conditions []Condition      `json:"conditions"`
+	Groups     []ConditionGroup `json:"groups,omitempty"`
+}
+
+// ConditionalLogic is the rule stored in FormField.ConditionalLogic. When the group matches,
+// the field is shown, hidden or made required depending on Action.
+type ConditionalLogic struct {
+	Action string `json:"action"`
+	ConditionGroup
+}
+
+// FieldState is the outcome of a field's conditional logic for a set of submitted values
+type FieldState struct {
+	Visible  bool `json:"visible"`
+	Required bool `json:"required"`
+}
+
+// ParseConditionalLogic decodes a field's conditional logic. It returns nil when the field has no rule.
+func ParseConditionalLogic(raw json.RawMessage) (*ConditionalLogic, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var logic ConditionalLogic
+	if err := json.Unmarshal(raw, &logic); err != nil {
+		return nil, fmt.Errorf("invalid conditional logic: %w", err)
+	}
+
+	if logic.isEmpty() {
+		return nil, nil
+	}
+
+	if logic.Action == "" {
+		logic.Action = ConditionActionShow
+	}
+
+	return &logic, nil
+}
+
+func (g ConditionGroup) isEmpty() bool {
+	return len(g.Conditions) == 0 && len(g.Groups) == 0
+}
+
+// ResolveFieldStates evaluates the conditional logic of every field against data. A field hidden
+// by its rule counts as unanswered for the rules of other fields, so states are re-evaluated until
+// the set of visible fields settles. Fields with malformed rules keep their static settings.
+func (s *FormService) ResolveFieldStates(fields []db.FormField, data map[string]interface{}) map[string]FieldState {
+	rules := make(map[string]*ConditionalLogic, len(fields))
+	for _, field := range fields {
+		logic, err := ParseConditionalLogic(field.ConditionalLogic)
+		if err == nil && logic != nil {
+			rules[field.FieldName] = logic
+		}
+	}
+
+	states := make(map[string]FieldState, len(fields))
+	for _, field := range fields {
+		states[field.FieldName] = FieldState{Visible: true, Required: field.IsRequired}
+	}
+
+	// Each pass can only hide or reveal fields that depend on a field changed in the previous
+	// pass, so the visible set settles within len(fields) passes for any acyclic form.
+	for pass := 0; pass <= len(fields); pass++ {
+		values := s.visibleValues(data, states)
+
+		changed := false
+		for _, field := range fields {
+			state := FieldState{Visible: true, Required: field.IsRequired}
+
+			if logic, ok := rules[field.FieldName]; ok {
+				matched := s.evaluateGroup(logic.ConditionGroup, values)
+				switch logic.Action {
+				case ConditionActionHide:
+					state.Visible = !matched
+				case ConditionActionRequire:
+					state.Required = field.IsRequired || matched
+				default:
+					state.Visible = matched
+				}
+			}
+
+			if !state.Visible {
+				state.Required = false
+			}
+
+			if states[field.FieldName] != state {
+				changed = true
+			}
+			states[field.FieldName] = state
+		}
+
+		if !changed {
+			break
+		}
+	}
+
+	return states
+}
+
+// StripHiddenValues returns a copy of data without the values of hidden fields
+func (s *FormService) StripHiddenValues(data map[string]interface{}, states map[string]FieldState) map[string]interface{} {
+	return s.visibleValues(data, states)
+}
+
+// stripHiddenFiles drops uploads for file fields hidden by conditional logic
+func (s *FormService) stripHiddenFiles(files map[string][]*multipart.FileHeader, states map[string]FieldState) map[string][]*multipart.FileHeader {
+	visible := make(map[string][]*multipart.FileHeader, len(files))
+	for key, headers := range files {
+		// Files of a group item are hidden with their group
+		name := key
+		if group, _, _, ok := parseGroupItemFieldName(key); ok {
+			name = group
+		}
+		if state, ok := states[name]; ok && !state.Visible {
+			continue
+		}
+		visible[key] = headers
+	}
+	return visible
+}
+
+// mergeConditionData overlays data on the values already saved for a submission, so rules
+// referencing fields that are not part of a partial update still see their answers.
+func (s *FormService) mergeConditionData(existing json.RawMessage, data map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{})
+	if existing != nil {
+		_ = json.Unmarshal(existing, &merged)
+		if merged == nil {
+			merged = make(map[string]interface{})
+		}
+	}
+	for key, value := range data {
+		merged[key] = value
+	}
+	return merged
+}
+
+// mergeStepProgressData copies the data saved for each step into merged, skipping skipStep
+func (s *FormService) mergeStepProgressData(merged map[string]interface{}, progress []db.FormStepProgress, skipStep int32) {
+	for _, p := range progress {
+		if p.StepNumber == skipStep {
+			continue
+		}
+		var stepData map[string]interface{}
+		if err := json.Unmarshal(p.Data.RawMessage, &stepData); err == nil {
+			for key, value := range stepData {
+				merged[key] = value
+			}
+		}
+	}
+}
+
+// hiddenFields returns the names of the fields hidden by their conditional logic
+func (s *FormService) hiddenFields(states map[string]FieldState) map[string]bool {
+	hidden := make(map[string]bool)
+	for name, state := range states {
+		if !state.Visible {
+			hidden[name] = true
+		}
+	}
+	return hidden
+}
+
+func (s *FormService) visibleValues(data map[string]interface{}, states map[string]FieldState) map[string]interface{} {
+	values := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if state, ok := states[key]; ok && !state.Visible {
+			continue
+		}
+		values[key] = value
+	}
+	return values
+}
+
+func (s *FormService) evaluateGroup(group ConditionGroup, values map[string]interface{}) bool {
+	if group.isEmpty() {
+		return true
+	}
+
+	results := make([]bool, 0, len(group.Conditions)+len(group.Groups))
+	for _, condition := range group.Conditions {
+		results = append(results, s.evaluateCondition(condition, values))
+	}
+	for _, nested := range group.Groups {
+		results = append(results, s.evaluateGroup(nested, values))
+	}
+
+	if group.Logic == "any" {
+		for _, result := range results {
+			if result {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, result := range results {
+		if !result {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *FormService) evaluateCondition(condition Condition, values map[string]interface{}) bool {
+	value := values[condition.Field]
+
+	switch condition.Operator {
+	case OperatorEquals:
+		return s.conditionValuesEqual(value, condition.Value)
+	case OperatorNotEquals:
+		return !s.conditionValuesEqual(value, condition.Value)
+	case OperatorIn:
+		list, ok := condition.Value.([]interface{})
+		return ok && s.conditionListContains(list, value)
+	case OperatorNotIn:
+		list, ok := condition.Value.([]interface{})
+		return ok && !s.conditionListContains(list, value)
+	case OperatorGt, OperatorGte, OperatorLt, OperatorLte:
+		cmp, ok := s.compareConditionValues(value, condition.Value)
+		if !ok {
+			return false
+		}
+		switch condition.Operator {
+		case OperatorGt:
+			return cmp > 0
+		case OperatorGte:
+			return cmp >= 0
+		case OperatorLt:
+			return cmp < 0
+		default:
+			return cmp <= 0
+		}
+	case OperatorContains:
+		switch v := value.(type) {
+		case string:
+			str, ok := condition.Value.(string)
+			return ok && strings.Contains(strings.ToLower(v), strings.ToLower(str))
+		case []interface{}:
+			return s.conditionListContains(v, condition.Value)
+		default:
+			return false
+		}
+	case OperatorIsEmpty:
+		return s.isBlank(value)
+	case OperatorIsNotEmpty:
+		return !s.isBlank(value)
+	default:
+		// Unknown operators never block a rule, matching the FormRenderer
+		return true
+	}
+}
+
+// conditionValuesEqual is strict equality: a string never equals a number, but numbers of
+// different Go types compare by value.
+func (s *FormService) conditionValuesEqual(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	switch av := a.(type) {
+	case string:
+		bv, ok := b.(string)
+		return ok && av == bv
+	case bool:
+		bv, ok := b.(bool)
+		return ok && av == bv
+	}
+
+	an, aok := s.conditionNumber(a)
+	bn, bok := s.conditionNumber(b)
+	return aok && bok && an == bn
+}
+
+func (s *FormService) conditionListContains(list []interface{}, value interface{}) bool {
+	for _, item := range list {
+		if s.conditionValuesEqual(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareConditionValues orders two values numerically, accepting numeric strings as submitted by
+// text inputs, and falls back to comparing strings so that ISO dates can be compared.
+func (s *FormService) compareConditionValues(a, b interface{}) (int, bool) {
+	an, aok := s.orderNumber(a)
+	bn, bok := s.orderNumber(b)
+	if aok && bok {
+		switch {
+		case an < bn:
+			return -1, true
+		case an > bn:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+
+	as, aok := a.(string)
+	bs, bok := b.(string)
+	if aok && bok {
+		return strings.Compare(as, bs), true
+	}
+
+	return 0, false
+}
+
+func (s *FormService) conditionNumber(value interface{}) (float64, bool) {
+	if _, ok := value.(string); ok {
+		return 0, false
+	}
+	return s.toFloat64(value)
+}
+
+func (s *FormService) orderNumber(value interface{}) (float64, bool) {
+	if str, ok := value.(string); ok {
+		f, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+		return f, err == nil
+	}
+	return s.toFloat64(value)
+}
+
+// isBlank extends isEmpty to treat whitespace-only strings as empty
+func (s *FormService) isBlank(value interface{}) bool {
+	if str, ok := value.(string); ok {
+		return strings.TrimSpace(str) == ""
+	}
+	return s.isEmpty(value)
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+)
+
+func conditionalField(name string, required bool, logic string) db.FormField {
+	field := db.FormField{FieldName: name, FieldType: "text", IsRequired: required}
+	if logic != "" {
+		field.ConditionalLogic = json.RawMessage(logic)
+	}
+	return field
+}
+
+func kybFields() []db.FormField {
+	return []db.FormField{
+		conditionalField("entity_type", true, "{}"),
+		conditionalField("company_number", true, `{"action":"show","logic":"all","conditions":[{"field":"entity_type","operator":"in","value":["llc","plc"]}]}`),
+		conditionalField("partners", true, `{"action":"show","conditions":[{"field":"entity_type","operator":"equals","value":"partnership"}]}`),
+		conditionalField("partner_count", false, `{"action":"show","conditions":[{"field":"partners","operator":"is_not_empty"}]}`),
+		conditionalField("annual_turnover", false, ""),
+		conditionalField("audited_accounts", false, `{"action":"require","logic":"any","conditions":[{"field":"annual_turnover","operator":"gte","value":1000000}],"groups":[{"logic":"all","conditions":[{"field":"entity_type","operator":"equals","value":"plc"},{"field":"annual_turnover","operator":"is_not_empty"}]}]}`),
+	}
+}
+
+func TestResolveFieldStates(t *testing.T) {
+	s := &FormService{}
+
+	states := s.ResolveFieldStates(kybFields(), map[string]interface{}{
+		"entity_type":     "llc",
+		"partners":        "Jane Doe",
+		"annual_turnover": "2500000",
+	})
+
+	require.Equal(t, FieldState{Visible: true, Required: true}, states["company_number"])
+	require.Equal(t, FieldState{Visible: false, Required: false}, states["partners"])
+	// partners is hidden, so its stale answer must not reveal partner_count
+	require.False(t, states["partner_count"].Visible)
+	require.Equal(t, FieldState{Visible: true, Required: true}, states["audited_accounts"])
+
+	states = s.ResolveFieldStates(kybFields(), map[string]interface{}{
+		"entity_type":     "plc",
+		"annual_turnover": float64(10),
+	})
+	require.True(t, states["audited_accounts"].Required)
+
+	states = s.ResolveFieldStates(kybFields(), map[string]interface{}{
+		"entity_type":     "partnership",
+		"annual_turnover": float64(10),
+	})
+	require.False(t, states["company_number"].Visible)
+	require.True(t, states["partners"].Required)
+	require.False(t, states["audited_accounts"].Required)
+}
+
+func TestStripHiddenValues(t *testing.T) {
+	s := &FormService{}
+
+	data := map[string]interface{}{
+		"entity_type":    "partnership",
+		"company_number": "RC123",
+		"partners":       "Jane Doe",
+		"other_step":     "kept",
+	}
+
+	stripped := s.StripHiddenValues(data, s.ResolveFieldStates(kybFields(), data))
+	require.NotContains(t, stripped, "company_number")
+	require.Equal(t, "Jane Doe", stripped["partners"])
+	require.Equal(t, "kept", stripped["other_step"])
+	require.Contains(t, data, "company_number")
+}
+
+func TestValidateSubmissionSkipsHiddenFields(t *testing.T) {
+	s := &FormService{}
+
+	err := s.ValidateSubmission(kybFields(), map[string]interface{}{
+		"entity_type": "partnership",
+		"partners":    "Jane Doe",
+	}, ValidationContext{Mode: ValidationModeFull})
+	require.NoError(t, err)
+
+	err = s.ValidateSubmission(kybFields(), map[string]interface{}{
+		"entity_type": "llc",
+	}, ValidationContext{Mode: ValidationModeFull})
+	require.Error(t, err)
+}
+
+func TestEvaluateCondition(t *testing.T) {
+	s := &FormService{}
+
+	values := map[string]interface{}{
+		"age":       float64(21),
+		"name":      "Acme Holdings",
+		"countries": []interface{}{"NG", "GH"},
+		"blank":     "  ",
+		"dob":       "1990-05-01",
+	}
+
+	cases := []struct {
+		condition Condition
+		want      bool
+	}{
+		{Condition{Field: "age", Operator: OperatorEquals, Value: float64(21)}, true},
+		{Condition{Field: "age", Operator: OperatorEquals, Value: "21"}, false},
+		{Condition{Field: "age", Operator: OperatorGt, Value: "18"}, true},
+		{Condition{Field: "age", Operator: OperatorLt, Value: float64(21)}, false},
+		{Condition{Field: "age", Operator: OperatorLte, Value: float64(21)}, true},
+		{Condition{Field: "dob", Operator: OperatorLt, Value: "2000-01-01"}, true},
+		{Condition{Field: "name", Operator: OperatorContains, Value: "holdings"}, true},
+		{Condition{Field: "countries", Operator: OperatorContains, Value: "GH"}, true},
+		{Condition{Field: "countries", Operator: OperatorNotIn, Value: []interface{}{"US"}}, true},
+		{Condition{Field: "blank", Operator: OperatorIsEmpty}, true},
+		{Condition{Field: "missing", Operator: OperatorIsEmpty}, true},
+		{Condition{Field: "missing", Operator: OperatorEquals, Value: nil}, true},
+		{Condition{Field: "name", Operator: "unknown"}, true},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.want, s.evaluateCondition(c.condition, values), c.condition)
+	}
+}
@@ -0,0 +1,686 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// Types of custom rules
+const (
+	// CustomRuleCompare compares a field to another, like end_date after start_date or
+	// confirm_email matching email
+	CustomRuleCompare = "compare"
+	// CustomRuleSum checks fields, or a field of the items of a group, add up to a total
+	CustomRuleSum = "sum"
+	// CustomRuleUnique checks no other user registered a value, like a business name
+	CustomRuleUnique = "unique"
+	// CustomRuleRemote checks a value with a registered validation hook
+	CustomRuleRemote = "remote"
+)
+
+// Operators of compare rules
+const (
+	CompareEquals       = "eq"
+	CompareNotEquals    = "ne"
+	CompareGreater      = "gt"
+	CompareGreaterEqual = "gte"
+	CompareLess         = "lt"
+	CompareLessEqual    = "lte"
+)
+
+var compareOperators = []string{CompareEquals, CompareNotEquals, CompareGreater, CompareGreaterEqual, CompareLess, CompareLessEqual}
+
+// defaultHookTimeout bounds a remote rule whose timeout isn't set
+const defaultHookTimeout = 5 * time.Second
+
+// CustomRule is a rule of the custom_rules of a field's or a step's validation rules, keyed by
+// a name. Its errors are keyed to Field, which is the field a rule is declared on by default;
+// rules declared on steps must name it.
+type CustomRule struct {
+	Type  string `json:"type"`
+	Field string `json:"field,omitempty"`
+	// Operator compares Field to Other. Dates and numbers compare by value, anything else as
+	// text, which can only be equal or not.
+	Operator string `json:"operator,omitempty"`
+	Other    string `json:"other,omitempty"`
+	// Fields are added up by sum rules. ItemField adds up a field of the items of the group
+	// Field instead.
+	Fields    []string         `json:"fields,omitempty"`
+	ItemField string           `json:"item_field,omitempty"`
+	Total     *decimal.Decimal `json:"total,omitempty"`
+	// Check is the uniqueness check of unique rules, one of UniqueChecks
+	Check string `json:"check,omitempty"`
+	// Hook is the validation hook of remote rules, which is passed Params
+	Hook           string                 `json:"hook,omitempty"`
+	Params         map[string]interface{} `json:"params,omitempty"`
+	TimeoutSeconds int                    `json:"timeout_seconds,omitempty"`
+	// Message replaces the error message of the rule
+	Message string `json:"message,omitempty"`
+}
+
+// uniqueChecks are the checks of unique rules. They run on a validator backed by the store and
+// fail when the value belongs to another user.
+var uniqueChecks = map[string]func(v *validator.Validator, value string, userID uuid.UUID){
+	"business_name":       (*validator.Validator).BusinessNameExistsForOthers,
+	"registration_number": (*validator.Validator).RegistrationNumberExistsForOthers,
+	"email":               (*validator.Validator).EmailExistsForOthers,
+	"phone":               (*validator.Validator).PhoneExistsForOthers,
+}
+
+// UniqueChecks lists the checks unique rules can use
+func UniqueChecks() []string {
+	checks := make([]string, 0, len(uniqueChecks))
+	for check := range uniqueChecks {
+		checks = append(checks, check)
+	}
+	sort.Strings(checks)
+	return checks
+}
+
+// ValidationHook checks a value with a remote service, like a registry lookup or sanctions
+// screening. Hooks are called concurrently and within the timeout of their rule.
+type ValidationHook interface {
+	Validate(ctx context.Context, request ValidationHookRequest) (*ValidationHookResult, error)
+}
+
+// ValidationHookFunc adapts a function to ValidationHook
+type ValidationHookFunc func(ctx context.Context, request ValidationHookRequest) (*ValidationHookResult, error)
+
+func (f ValidationHookFunc) Validate(ctx context.Context, request ValidationHookRequest) (*ValidationHookResult, error) {
+	return f(ctx, request)
+}
+
+// ValidationHookRequest is the value a remote rule checks, with the answers it was given with
+type ValidationHookRequest struct {
+	FormID uuid.UUID              `json:"form_id"`
+	UserID uuid.UUID              `json:"user_id"`
+	Field  string                 `json:"field"`
+	Value  interface{}            `json:"value"`
+	Data   map[string]interface{} `json:"data"`
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+// ValidationHookResult rejects a value with a message, and can reject other fields with
+// messages keyed by field name
+type ValidationHookResult struct {
+	Valid   bool              `json:"valid"`
+	Message string            `json:"message,omitempty"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// RegisterValidationHook adds or replaces the hook remote rules call by name
+func (s *FormService) RegisterValidationHook(name string, hook ValidationHook) {
+	s.registry.Lock()
+	defer s.registry.Unlock()
+	s.validationHooks[name] = hook
+}
+
+// validationHook returns the hook remote rules call by name
+func (s *FormService) validationHook(name string) (ValidationHook, bool) {
+	s.registry.RLock()
+	defer s.registry.RUnlock()
+	hook, ok := s.validationHooks[name]
+	return hook, ok
+}
+
+// ValidationHooks lists the hooks remote rules can call
+func (s *FormService) ValidationHooks() []string {
+	s.registry.RLock()
+	defer s.registry.RUnlock()
+	hooks := make([]string, 0, len(s.validationHooks))
+	for name := range s.validationHooks {
+		hooks = append(hooks, name)
+	}
+	sort.Strings(hooks)
+	return hooks
+}
+
+// CustomRuleOptions are the rule types, operators, uniqueness checks and hooks custom rules
+// can use
+type CustomRuleOptions struct {
+	Types           []string `json:"types"`
+	Operators       []string `json:"operators"`
+	UniqueChecks    []string `json:"unique_checks"`
+	ValidationHooks []string `json:"validation_hooks"`
+}
+
+// GetCustomRuleOptions lists what custom rules can use
+func (s *FormService) GetCustomRuleOptions() CustomRuleOptions {
+	return CustomRuleOptions{
+		Types:           []string{CustomRuleCompare, CustomRuleSum, CustomRuleUnique, CustomRuleRemote},
+		Operators:       compareOperators,
+		UniqueChecks:    UniqueChecks(),
+		ValidationHooks: s.ValidationHooks(),
+	}
+}
+
+// ParseCustomRules reads the custom rules of validation rules by name
+func ParseCustomRules(raw map[string]interface{}) (map[string]CustomRule, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var rules map[string]CustomRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid custom rules: %w", err)
+	}
+	return rules, nil
+}
+
+// CheckCustomRules checks the custom rules declared on a field, or on a step when fieldName is
+// empty, against the fields of the form by name and type
+func (s *FormService) CheckCustomRules(v *validator.Validator, key, fieldName string, raw map[string]interface{}, fieldTypes map[string]string) {
+	rules, err := ParseCustomRules(raw)
+	if err != nil {
+		v.AddError(key, err.Error())
+		return
+	}
+
+	known := func(ruleKey, name string) bool {
+		if _, ok := fieldTypes[name]; !ok {
+			v.AddError(ruleKey, fmt.Sprintf("references unknown field %q", name))
+			return false
+		}
+		return true
+	}
+
+	for _, name := range sortedRuleNames(rules) {
+		rule := rules[name]
+		ruleKey := key + "." + name
+		if rule.Field == "" {
+			rule.Field = fieldName
+		}
+		if rule.Field == "" {
+			v.AddError(ruleKey, "rules of steps must name a field")
+			continue
+		}
+		if !known(ruleKey, rule.Field) {
+			continue
+		}
+
+		switch rule.Type {
+		case CustomRuleCompare:
+			v.Check(validator.In(rule.Operator, compareOperators...), ruleKey, fmt.Sprintf("unsupported operator %q", rule.Operator))
+			if rule.Other == "" {
+				v.AddError(ruleKey, "must name the other field")
+			} else if known(ruleKey, rule.Other) {
+				v.Check(rule.Other != rule.Field, ruleKey, "must not compare a field to itself")
+			}
+
+		case CustomRuleSum:
+			v.Check(rule.Total != nil, ruleKey, "must have a total")
+			if rule.ItemField != "" {
+				v.Check(IsGroupFieldType(fieldTypes[rule.Field]), ruleKey, "item fields can only be added up over groups")
+				v.Check(len(rule.Fields) == 0, ruleKey, "must add up either fields or an item field")
+				continue
+			}
+			v.Check(len(rule.Fields) > 1, ruleKey, "must add up at least two fields")
+			for _, summed := range rule.Fields {
+				known(ruleKey, summed)
+			}
+
+		case CustomRuleUnique:
+			_, ok := uniqueChecks[rule.Check]
+			v.Check(ok, ruleKey, fmt.Sprintf("unknown check %q", rule.Check))
+
+		case CustomRuleRemote:
+			_, ok := s.validationHook(rule.Hook)
+			v.Check(ok, ruleKey, fmt.Sprintf("unknown hook %q", rule.Hook))
+			v.Check(rule.TimeoutSeconds >= 0, ruleKey, "timeout must not be negative")
+
+		default:
+			v.AddError(ruleKey, fmt.Sprintf("unknown rule type %q", rule.Type))
+		}
+	}
+}
+
+// customRuleScope is what custom rules are checked against
+type customRuleScope struct {
+	FormID uuid.UUID
+	UserID uuid.UUID
+	// Fields and Steps are those whose rules are checked
+	Fields []db.FormField
+	Steps  []db.FormStep
+	// Data holds the answers rules read, which can include those of other steps
+	Data   map[string]interface{}
+	States map[string]FieldState
+	// Provided are the answers of a draft. Only the rules of fields it holds are checked, and
+	// remote hooks aren't called.
+	Provided map[string]interface{}
+}
+
+// scopedCustomRule is a rule with the field it checks resolved
+type scopedCustomRule struct {
+	name string
+	CustomRule
+}
+
+// validateCustomRules checks the custom rules of a scope after the built-in validation, whose
+// error it is passed, and returns one validation error with the errors of both. Uniqueness
+// and remote checks only run when everything else passed, to spare the database and remote
+// services invalid answers.
+func (s *FormService) validateCustomRules(ctx context.Context, scope customRuleScope, err error) error {
+	v := validator.New()
+	message := "validation failed"
+
+	var validationErr *validator.ValidationError
+	if err != nil {
+		if !errors.As(err, &validationErr) {
+			return err
+		}
+		message = validationErr.Message
+		for key, fieldErr := range validationErr.Fields {
+			if msg, ok := validationErr.Messages[key]; ok {
+				v.AddMessage(key, msg)
+				continue
+			}
+			v.AddError(key, fieldErr)
+		}
+	}
+
+	rules := scope.rules()
+	var deferred []scopedCustomRule
+	for _, rule := range rules {
+		switch rule.Type {
+		case CustomRuleCompare:
+			scope.compare(v, rule)
+		case CustomRuleSum:
+			scope.sum(v, rule)
+		default:
+			deferred = append(deferred, rule)
+		}
+	}
+
+	if v.Valid() {
+		s.checkUnique(ctx, v, scope, deferred)
+	}
+	if v.Valid() && scope.Provided == nil {
+		s.callValidationHooks(ctx, v, scope, deferred)
+	}
+
+	if !v.Valid() {
+		return v.ValidationError(message)
+	}
+	return nil
+}
+
+// rules collects the rules of the fields and steps of the scope whose field is visible
+func (scope customRuleScope) rules() []scopedCustomRule {
+	var rules []scopedCustomRule
+	add := func(raw json.RawMessage, fieldName string) {
+		var validationRules ValidationRules
+		if len(raw) == 0 || json.Unmarshal(raw, &validationRules) != nil {
+			return
+		}
+		parsed, err := ParseCustomRules(validationRules.CustomRules)
+		if err != nil {
+			return
+		}
+		for _, name := range sortedRuleNames(parsed) {
+			rule := parsed[name]
+			if rule.Field == "" {
+				rule.Field = fieldName
+			}
+			if rule.Field == "" || scope.hidden(rule.Field) {
+				continue
+			}
+			if _, provided := scope.Provided[rule.Field]; scope.Provided != nil && !provided {
+				continue
+			}
+			rules = append(rules, scopedCustomRule{name: name, CustomRule: rule})
+		}
+	}
+
+	for _, field := range scope.Fields {
+		add(field.ValidationRules, field.FieldName)
+	}
+	for _, step := range scope.Steps {
+		add(step.ValidationRules, "")
+	}
+	return rules
+}
+
+func (scope customRuleScope) hidden(name string) bool {
+	state, ok := scope.States[name]
+	return ok && !state.Visible
+}
+
+// value returns the answer of a visible field, if it has one
+func (scope customRuleScope) value(name string) (interface{}, bool) {
+	if scope.hidden(name) {
+		return nil, false
+	}
+	value, ok := scope.Data[name]
+	if !ok || value == nil {
+		return nil, false
+	}
+	if str, isString := value.(string); isString && strings.TrimSpace(str) == "" {
+		return nil, false
+	}
+	return value, true
+}
+
+// ruleMessage is the message of a failed rule: its own message when it has one, or the catalog
+// message with args
+func ruleMessage(rule scopedCustomRule, message string, args ...interface{}) validator.Message {
+	if rule.Message != "" {
+		return validator.Msg(rule.Message)
+	}
+	return validator.Msg(message, args...)
+}
+
+// compare checks a compare rule when both fields are answered
+func (scope customRuleScope) compare(v *validator.Validator, rule scopedCustomRule) {
+	value, ok := scope.value(rule.Field)
+	if !ok {
+		return
+	}
+	other, ok := scope.value(rule.Other)
+	if !ok {
+		return
+	}
+
+	cmp, ordered, dates := compareRuleValues(value, other)
+	switch rule.Operator {
+	case CompareEquals:
+		v.CheckMessage(cmp == 0, rule.Field, ruleMessage(rule, "must match %s", rule.Other))
+	case CompareNotEquals:
+		v.CheckMessage(cmp != 0, rule.Field, ruleMessage(rule, "must be different from %s", rule.Other))
+	case CompareGreater:
+		if ordered {
+			v.CheckMessage(cmp > 0, rule.Field, ruleMessage(rule, pick(dates, "must be after %s", "must be greater than %s"), rule.Other))
+		}
+	case CompareGreaterEqual:
+		if ordered {
+			v.CheckMessage(cmp >= 0, rule.Field, ruleMessage(rule, pick(dates, "must be on or after %s", "must be at least %v"), rule.Other))
+		}
+	case CompareLess:
+		if ordered {
+			v.CheckMessage(cmp < 0, rule.Field, ruleMessage(rule, pick(dates, "must be before %s", "must be less than %s"), rule.Other))
+		}
+	case CompareLessEqual:
+		if ordered {
+			v.CheckMessage(cmp <= 0, rule.Field, ruleMessage(rule, pick(dates, "must be on or before %s", "must be at most %v"), rule.Other))
+		}
+	}
+}
+
+func pick(cond bool, a, b string) string {
+	if cond {
+		return a
+	}
+	return b
+}
+
+// compareRuleValues compares two answers as dates, then as numbers and otherwise as text.
+// Text is not ordered, so only its equality is meaningful.
+func compareRuleValues(a, b interface{}) (cmp int, ordered bool, dates bool) {
+	aText, aIsText := a.(string)
+	bText, bIsText := b.(string)
+	if aIsText && bIsText {
+		if at, ok := parseRuleDate(aText); ok {
+			if bt, ok := parseRuleDate(bText); ok {
+				return at.Compare(bt), true, true
+			}
+		}
+	}
+
+	if an, ok := ruleNumber(a); ok {
+		if bn, ok := ruleNumber(b); ok {
+			return an.Cmp(bn), true, false
+		}
+	}
+
+	if fmt.Sprint(a) == fmt.Sprint(b) {
+		return 0, false, false
+	}
+	return 1, false, false
+}
+
+// parseRuleDate reads an answer of a date or datetime field
+func parseRuleDate(value string) (time.Time, bool) {
+	if t, err := time.Parse(dateLayout, strings.TrimSpace(value)); err == nil {
+		return t, true
+	}
+	t, err := parseDateTime(value)
+	return t, err == nil
+}
+
+// ruleNumber reads an answer as a number. Unlike toFloat64, text must be a number as a whole,
+// so dates and phone numbers aren't taken for numbers.
+func ruleNumber(value interface{}) (decimal.Decimal, bool) {
+	switch v := value.(type) {
+	case float64:
+		return decimal.NewFromFloat(v), true
+	case int:
+		return decimal.NewFromInt(int64(v)), true
+	case int64:
+		return decimal.NewFromInt(v), true
+	case json.Number:
+		d, err := decimal.NewFromString(v.String())
+		return d, err == nil
+	case string:
+		if _, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err != nil {
+			return decimal.Decimal{}, false
+		}
+		d, err := decimal.NewFromString(strings.TrimSpace(v))
+		return d, err == nil
+	}
+	return decimal.Decimal{}, false
+}
+
+// sum checks a sum rule when any of its fields is answered. Fields left empty count as zero,
+// and drafts aren't checked, as they may not be finished.
+func (scope customRuleScope) sum(v *validator.Validator, rule scopedCustomRule) {
+	if rule.Total == nil || scope.Provided != nil {
+		return
+	}
+
+	total := decimal.Zero
+	answered := false
+	if rule.ItemField != "" {
+		value, ok := scope.value(rule.Field)
+		if !ok {
+			return
+		}
+		items, ok := groupItems(value)
+		if !ok {
+			return
+		}
+		for _, item := range items {
+			if n, ok := ruleNumber(item[rule.ItemField]); ok {
+				total = total.Add(n)
+				answered = true
+			}
+		}
+		if answered && !total.Equal(*rule.Total) {
+			v.AddMessage(rule.Field, ruleMessage(rule, "%s of all items must total %s", rule.ItemField, rule.Total.String()))
+		}
+		return
+	}
+
+	for _, name := range rule.Fields {
+		value, ok := scope.value(name)
+		if !ok {
+			continue
+		}
+		if n, ok := ruleNumber(value); ok {
+			total = total.Add(n)
+			answered = true
+		}
+	}
+	if answered && !total.Equal(*rule.Total) {
+		v.AddMessage(rule.Field, ruleMessage(rule, "must total %s", rule.Total.String()))
+	}
+}
+
+// checkUnique runs the unique rules of answered fields against the store
+func (s *FormService) checkUnique(ctx context.Context, v *validator.Validator, scope customRuleScope, rules []scopedCustomRule) {
+	for _, rule := range rules {
+		if rule.Type != CustomRuleUnique {
+			continue
+		}
+		check, ok := uniqueChecks[rule.Check]
+		if !ok {
+			continue
+		}
+		value, ok := scope.value(rule.Field)
+		if !ok {
+			continue
+		}
+
+		unique := validator.NewWithStore(ctx, s.store)
+		check(unique, strings.TrimSpace(fmt.Sprint(value)), scope.UserID)
+		if unique.Valid() {
+			continue
+		}
+		message := ruleMessage(rule, "is already registered")
+		for _, err := range unique.Errors {
+			if err == validator.MessageNotVerified {
+				message = validator.Msg(err)
+			}
+		}
+		v.AddMessage(rule.Field, message)
+	}
+}
+
+// callValidationHooks calls the hooks of the remote rules of answered fields concurrently.
+// A hook that fails or times out rejects the value, since it couldn't be checked.
+func (s *FormService) callValidationHooks(ctx context.Context, v *validator.Validator, scope customRuleScope, rules []scopedCustomRule) {
+	type call struct {
+		rule   scopedCustomRule
+		result *ValidationHookResult
+		err    error
+	}
+
+	var calls []*call
+	for _, rule := range rules {
+		if rule.Type != CustomRuleRemote {
+			continue
+		}
+		if _, ok := scope.value(rule.Field); ok {
+			calls = append(calls, &call{rule: rule})
+		}
+	}
+	if len(calls) == 0 {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range calls {
+		wg.Add(1)
+		go func(c *call) {
+			defer wg.Done()
+
+			hook, ok := s.validationHook(c.rule.Hook)
+			if !ok {
+				c.err = fmt.Errorf("unknown validation hook %q", c.rule.Hook)
+				return
+			}
+			timeout := defaultHookTimeout
+			if c.rule.TimeoutSeconds > 0 {
+				timeout = time.Duration(c.rule.TimeoutSeconds) * time.Second
+			}
+			hookCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			value, _ := scope.value(c.rule.Field)
+			c.result, c.err = hook.Validate(hookCtx, ValidationHookRequest{
+				FormID: scope.FormID,
+				UserID: scope.UserID,
+				Field:  c.rule.Field,
+				Value:  value,
+				Data:   scope.Data,
+				Params: c.rule.Params,
+			})
+			if c.err == nil && c.result == nil {
+				c.err = fmt.Errorf("validation hook %q returned no result", c.rule.Hook)
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	for _, c := range calls {
+		if c.err != nil {
+			s.logger.Error(c.err, map[string]interface{}{
+				"message": "validation hook failed",
+				"form_id": scope.FormID,
+				"hook":    c.rule.Hook,
+				"field":   c.rule.Field,
+			})
+			v.AddError(c.rule.Field, validator.MessageNotVerified)
+			continue
+		}
+		if c.result.Valid {
+			continue
+		}
+		message := validator.Msg(c.result.Message)
+		if c.result.Message == "" {
+			message = ruleMessage(c.rule, "is not valid")
+		}
+		v.AddMessage(c.rule.Field, message)
+		for name, fieldErr := range c.result.Fields {
+			v.AddError(name, fieldErr)
+		}
+	}
+}
+
+func sortedRuleNames(rules map[string]CustomRule) []string {
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// stepRuleScope narrows the fields and steps of a form to those of a step
+func stepRuleScope(steps []db.FormStep, fields []db.FormField, stepNumber int32) ([]db.FormStep, []db.FormField) {
+	var stepSteps []db.FormStep
+	var stepFields []db.FormField
+	for _, step := range steps {
+		if step.StepNumber != stepNumber {
+			continue
+		}
+		stepSteps = append(stepSteps, step)
+		for _, field := range fields {
+			if field.FormStepID == step.ID {
+				stepFields = append(stepFields, field)
+			}
+		}
+	}
+	return stepSteps, stepFields
+}
+
+// validationRuleScope is the custom rules scope of a validation context: the rules of the step
+// validated, or of the whole form, and only those of the answers provided for drafts
+func validationRuleScope(steps []db.FormStep, fields []db.FormField, data map[string]interface{}, ctx ValidationContext) customRuleScope {
+	scope := customRuleScope{Fields: fields, Steps: steps, Data: data, States: ctx.FieldStates}
+	switch ctx.Mode {
+	case ValidationModePartial:
+		scope.Provided = ctx.ProvidedFields
+		if scope.Provided == nil {
+			scope.Provided = map[string]interface{}{}
+		}
+	case ValidationModeStep:
+		if ctx.StepNumber != nil {
+			scope.Steps, scope.Fields = stepRuleScope(steps, fields, *ctx.StepNumber)
+		}
+	}
+	return scope
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/logger"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// businessStore serves businesses by name; other store methods are not used. Lookups of
+// unavailableBusinessName fail as if the database were down.
+type businessStore struct {
+	db.Store
+	businesses map[string]db.Business
+}
+
+const unavailableBusinessName = "unavailable"
+
+func (s businessStore) GetBusinessByName(_ context.Context, name string) (db.Business, error) {
+	if name == unavailableBusinessName {
+		return db.Business{}, errors.New("connection reset by peer")
+	}
+	business, ok := s.businesses[strings.ToLower(name)]
+	if !ok {
+		return db.Business{}, sql.ErrNoRows
+	}
+	return business, nil
+}
+
+func newCustomRuleTestService() *FormService {
+	return &FormService{
+		store:           businessStore{businesses: map[string]db.Business{}},
+		logger:          logger.NewZeroLogger(io.Discard, logger.LevelOff, nil),
+		validationHooks: map[string]ValidationHook{},
+	}
+}
+
+func ruleField(name, fieldType, rules string) db.FormField {
+	return db.FormField{FieldName: name, FieldType: fieldType, ValidationRules: json.RawMessage(rules)}
+}
+
+func customRuleErrors(t *testing.T, err error) map[string]string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var validationErr *validator.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	return validationErr.Fields
+}
+
+func TestCompareRules(t *testing.T) {
+	s := newCustomRuleTestService()
+	fields := []db.FormField{
+		{FieldName: "start_date", FieldType: FieldTypeDate},
+		ruleField("end_date", FieldTypeDate, `{"custom_rules":{"after_start":{"type":"compare","operator":"gt","other":"start_date"}}}`),
+		{FieldName: "email", FieldType: "email"},
+		ruleField("confirm_email", "email", `{"custom_rules":{"matches":{"type":"compare","operator":"eq","other":"email","message":"emails don't match"}}}`),
+		{FieldName: "min_amount", FieldType: "number"},
+		ruleField("max_amount", "number", `{"custom_rules":{"above_min":{"type":"compare","operator":"gte","other":"min_amount"}}}`),
+	}
+
+	validate := func(data map[string]interface{}) map[string]string {
+		return customRuleErrors(t, s.validateCustomRules(context.Background(), customRuleScope{Fields: fields, Data: data}, nil))
+	}
+
+	require.Nil(t, validate(map[string]interface{}{
+		"start_date":    "2026-01-01",
+		"end_date":      "2026-02-01",
+		"email":         "ada@example.com",
+		"confirm_email": "ada@example.com",
+		"min_amount":    "100",
+		"max_amount":    250.5,
+	}))
+
+	require.Equal(t, map[string]string{
+		"end_date":      "must be after start_date",
+		"confirm_email": "emails don't match",
+		"max_amount":    "must be at least min_amount",
+	}, validate(map[string]interface{}{
+		"start_date":    "2026-02-01",
+		"end_date":      "2026-01-01",
+		"email":         "ada@example.com",
+		"confirm_email": "obi@example.com",
+		"min_amount":    "100",
+		"max_amount":    "99.99",
+	}))
+
+	// Rules are checked once both fields are answered
+	require.Nil(t, validate(map[string]interface{}{"end_date": "2026-01-01", "confirm_email": ""}))
+}
+
+func TestSumRules(t *testing.T) {
+	s := newCustomRuleTestService()
+	fields := []db.FormField{
+		ruleField("shareholders", "group", `{"custom_rules":{"total":{"type":"sum","item_field":"percentage","total":100}}}`),
+		{FieldName: "cash", FieldType: "number"},
+		ruleField("equity", "number", `{"custom_rules":{"split":{"type":"sum","fields":["cash","equity"],"total":"1"}}}`),
+	}
+
+	validate := func(data map[string]interface{}) map[string]string {
+		return customRuleErrors(t, s.validateCustomRules(context.Background(), customRuleScope{Fields: fields, Data: data}, nil))
+	}
+
+	require.Nil(t, validate(map[string]interface{}{
+		"shareholders": []interface{}{
+			map[string]interface{}{"name": "Ada", "percentage": 60.5},
+			map[string]interface{}{"name": "Obi", "percentage": "39.5"},
+		},
+		"cash":   0.1,
+		"equity": 0.9,
+	}))
+
+	require.Equal(t, map[string]string{
+		"shareholders": "percentage of all items must total 100",
+		"equity":       "must total 1",
+	}, validate(map[string]interface{}{
+		"shareholders": []interface{}{
+			map[string]interface{}{"name": "Ada", "percentage": 60},
+			map[string]interface{}{"name": "Obi", "percentage": 30},
+		},
+		"cash": 0.5,
+	}))
+
+	// Drafts may not be finished, so their sums aren't checked
+	require.Nil(t, customRuleErrors(t, s.validateCustomRules(context.Background(), customRuleScope{
+		Fields:   fields,
+		Data:     map[string]interface{}{"cash": 0.5},
+		Provided: map[string]interface{}{"cash": 0.5},
+	}, nil)))
+}
+
+func TestStepRules(t *testing.T) {
+	s := newCustomRuleTestService()
+	stepID := uuid.New()
+	steps := []db.FormStep{
+		{ID: stepID, StepNumber: 2, ValidationRules: json.RawMessage(`{"custom_rules":{"dates":{"type":"compare","field":"end_date","operator":"gt","other":"start_date"}}}`)},
+		{ID: uuid.New(), StepNumber: 3},
+	}
+	fields := []db.FormField{
+		{FieldName: "start_date", FieldType: FieldTypeDate},
+		{FieldName: "end_date", FieldType: FieldTypeDate, FormStepID: stepID},
+	}
+	data := map[string]interface{}{"start_date": "2026-02-01", "end_date": "2026-01-01"}
+
+	stepSteps, stepFields := stepRuleScope(steps, fields, 2)
+	require.Len(t, stepSteps, 1)
+	require.Equal(t, []db.FormField{fields[1]}, stepFields)
+
+	err := s.validateCustomRules(context.Background(), customRuleScope{Steps: stepSteps, Fields: stepFields, Data: data}, nil)
+	require.Equal(t, map[string]string{"end_date": "must be after start_date"}, customRuleErrors(t, err))
+
+	// Rules of hidden fields aren't checked
+	states := map[string]FieldState{"end_date": {Visible: false}}
+	require.NoError(t, s.validateCustomRules(context.Background(), customRuleScope{Steps: steps, Data: data, States: states}, nil))
+
+	// Errors of the built-in validation are kept, with its message
+	err = s.validateCustomRules(context.Background(), customRuleScope{Steps: steps, Data: data},
+		validator.NewValidationError("step validation failed", map[string]string{"start_date": "field is required"}))
+	var validationErr *validator.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Equal(t, "step validation failed", validationErr.Message)
+	require.Equal(t, map[string]string{"start_date": "field is required", "end_date": "must be after start_date"}, validationErr.Fields)
+}
+
+func TestUniqueRules(t *testing.T) {
+	s := newCustomRuleTestService()
+	owner := uuid.New()
+	s.store.(businessStore).businesses["acme ltd"] = db.Business{Name: "Acme Ltd", CreatedBy: owner}
+	fields := []db.FormField{ruleField("company", "text", `{"custom_rules":{"unique":{"type":"unique","check":"business_name"}}}`)}
+
+	validate := func(userID uuid.UUID, name string) error {
+		return s.validateCustomRules(context.Background(), customRuleScope{
+			UserID: userID,
+			Fields: fields,
+			Data:   map[string]interface{}{"company": name},
+		}, nil)
+	}
+
+	require.NoError(t, validate(owner, "Acme Ltd"))
+	require.NoError(t, validate(uuid.New(), "Globex"))
+	require.Equal(t, map[string]string{"company": "is already registered"}, customRuleErrors(t, validate(uuid.New(), "Acme Ltd")))
+
+	// A failed lookup isn't taken as a free name
+	require.Equal(t, map[string]string{"company": validator.MessageNotVerified}, customRuleErrors(t, validate(uuid.New(), unavailableBusinessName)))
+}
+
+func TestRemoteRules(t *testing.T) {
+	s := newCustomRuleTestService()
+	var requests []ValidationHookRequest
+	s.RegisterValidationHook("registry", ValidationHookFunc(func(_ context.Context, request ValidationHookRequest) (*ValidationHookResult, error) {
+		requests = append(requests, request)
+		if request.Value == "RC000" {
+			return &ValidationHookResult{Message: "not found in the registry", Fields: map[string]string{"company": "does not match the registry"}}, nil
+		}
+		return &ValidationHookResult{Valid: true}, nil
+	}))
+	s.RegisterValidationHook("down", ValidationHookFunc(func(context.Context, ValidationHookRequest) (*ValidationHookResult, error) {
+		return nil, errors.New("connection refused")
+	}))
+
+	formID := uuid.New()
+	fields := []db.FormField{
+		{FieldName: "company", FieldType: "text"},
+		ruleField("registration_number", "text", `{"custom_rules":{"registry":{"type":"remote","hook":"registry","params":{"country":"NG"}}}}`),
+	}
+	validate := func(number string) error {
+		return s.validateCustomRules(context.Background(), customRuleScope{
+			FormID: formID,
+			Fields: fields,
+			Data:   map[string]interface{}{"company": "Acme Ltd", "registration_number": number},
+		}, nil)
+	}
+
+	require.NoError(t, validate("RC123"))
+	require.Len(t, requests, 1)
+	require.Equal(t, formID, requests[0].FormID)
+	require.Equal(t, "registration_number", requests[0].Field)
+	require.Equal(t, map[string]interface{}{"country": "NG"}, requests[0].Params)
+
+	require.Equal(t, map[string]string{
+		"registration_number": "not found in the registry",
+		"company":             "does not match the registry",
+	}, customRuleErrors(t, validate("RC000")))
+
+	// Drafts don't call hooks
+	err := s.validateCustomRules(context.Background(), customRuleScope{
+		Fields:   fields,
+		Data:     map[string]interface{}{"registration_number": "RC000"},
+		Provided: map[string]interface{}{"registration_number": "RC000"},
+	}, nil)
+	require.NoError(t, err)
+	require.Len(t, requests, 2)
+
+	// Hooks that fail reject the value
+	fields[1] = ruleField("registration_number", "text", `{"custom_rules":{"registry":{"type":"remote","hook":"down"}}}`)
+	require.Equal(t, map[string]string{"registration_number": "could not be verified, try again later"}, customRuleErrors(t, validate("RC123")))
+}
+
+func TestCheckCustomRules(t *testing.T) {
+	s := newCustomRuleTestService()
+	s.RegisterValidationHook("registry", ValidationHookFunc(func(context.Context, ValidationHookRequest) (*ValidationHookResult, error) {
+		return &ValidationHookResult{Valid: true}, nil
+	}))
+	fieldTypes := map[string]string{"start_date": FieldTypeDate, "end_date": FieldTypeDate, "shareholders": "group", "company": "text"}
+
+	check := func(fieldName, rules string) map[string]string {
+		var raw map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(rules), &raw))
+		v := validator.New()
+		s.CheckCustomRules(v, "rules", fieldName, raw, fieldTypes)
+		return v.Errors
+	}
+
+	require.Empty(t, check("end_date", `{"dates":{"type":"compare","operator":"gt","other":"start_date"}}`))
+	require.Empty(t, check("", `{"total":{"type":"sum","field":"shareholders","item_field":"percentage","total":100}}`))
+	require.Empty(t, check("company", `{"unique":{"type":"unique","check":"business_name"},"registry":{"type":"remote","hook":"registry"}}`))
+
+	require.Equal(t, map[string]string{"rules.dates": `unsupported operator "after"`},
+		check("end_date", `{"dates":{"type":"compare","operator":"after","other":"start_date"}}`))
+	require.Equal(t, map[string]string{"rules.dates": `references unknown field "begin_date"`},
+		check("end_date", `{"dates":{"type":"compare","operator":"gt","other":"begin_date"}}`))
+	require.Equal(t, map[string]string{"rules.dates": "rules of steps must name a field"},
+		check("", `{"dates":{"type":"compare","operator":"gt","other":"start_date"}}`))
+	require.Equal(t, map[string]string{"rules.total": "item fields can only be added up over groups"},
+		check("company", `{"total":{"type":"sum","item_field":"percentage","total":100}}`))
+	require.Equal(t, map[string]string{"rules.unique": `unknown check "tax_id"`},
+		check("company", `{"unique":{"type":"unique","check":"tax_id"}}`))
+	require.Equal(t, map[string]string{"rules.registry": `unknown hook "sanctions"`},
+		check("company", `{"registry":{"type":"remote","hook":"sanctions"}}`))
+	require.Equal(t, map[string]string{"rules.regex": `unknown rule type "regex"`},
+		check("company", `{"regex":{"type":"regex"}}`))
+}
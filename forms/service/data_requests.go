@@ -0,0 +1,367 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// DataExportTTL is how long a data export can be downloaded before it is deleted
+const DataExportTTL = 7 * 24 * time.Hour
+
+// maxUserRecordsRead bounds the legal holds and data requests read for a user when erasing their data
+const maxUserRecordsRead = 1000
+
+var (
+	ErrDataRequestProcessed  = errors.New("data request has already been processed")
+	ErrDataExportUnavailable = errors.New("data export is not available, it is pending or has expired")
+)
+
+// UserDataExport is the copy of their data a user gets for an export request
+type UserDataExport struct {
+	UserID      uuid.UUID `json:"user_id"`
+	Email       string    `json:"email"`
+	GeneratedAt time.Time `json:"generated_at"`
+	db.UserPersonalData
+}
+
+// DataExportSummary counts the records in an export
+type DataExportSummary struct {
+	FormSubmissions       int `json:"form_submissions"`
+	FormSubmissionFiles   int `json:"form_submission_files"`
+	PublicFormSubmissions int `json:"public_form_submissions"`
+	KYCRequirements       int `json:"kyc_requirements"`
+	Documents             int `json:"documents"`
+	IdentityDocuments     int `json:"identity_documents"`
+	IdentityVerifications int `json:"identity_verifications"`
+	UserMeta              int `json:"user_meta"`
+}
+
+// ErasureSummary is what an erasure deleted, and the forms whose submissions legal holds kept
+type ErasureSummary struct {
+	Erased       db.EraseUserDataResult `json:"erased"`
+	FilesDeleted int                    `json:"files_deleted"`
+	KeptFormIDs  []uuid.UUID            `json:"kept_form_ids"`
+}
+
+// RequestUserData opens an export, erasure or closure request for a user, or returns their
+// pending one
+func (s *FormService) RequestUserData(ctx context.Context, params db.CreateDataSubjectRequestParams) (*db.DataSubjectRequest, error) {
+	v := validator.New()
+	v.Check(validator.In(params.Type, db.DataRequestTypeExport, db.DataRequestTypeErasure, db.DataRequestTypeClosure), "type",
+		fmt.Sprintf("must be %s, %s or %s", db.DataRequestTypeExport, db.DataRequestTypeErasure, db.DataRequestTypeClosure))
+	if !v.Valid() {
+		return nil, validator.NewValidationError("validation failed", v.Errors)
+	}
+
+	if _, err := s.store.GetUser(ctx, params.UserID); err != nil {
+		return nil, err
+	}
+
+	request, err := s.store.CreateDataSubjectRequest(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// ListDataRequests returns data requests, pending ones first
+func (s *FormService) ListDataRequests(ctx context.Context, params db.ListDataSubjectRequestsParams) ([]db.DataSubjectRequest, error) {
+	return s.store.ListDataSubjectRequests(ctx, params)
+}
+
+// GetDataExportURL returns a temporary link to the export of one of the user's requests
+func (s *FormService) GetDataExportURL(ctx context.Context, requestID, userID uuid.UUID) (string, error) {
+	request, err := s.store.GetDataSubjectRequest(ctx, requestID)
+	if err != nil {
+		return "", err
+	}
+	if request.UserID != userID {
+		return "", db.ErrDataRequestNotFound
+	}
+	if request.Type != db.DataRequestTypeExport || request.ExportPath == "" ||
+		!request.ExportExpiresAt.Valid || time.Now().After(request.ExportExpiresAt.Time) {
+		return "", ErrDataExportUnavailable
+	}
+	return s.uploader.GetTempURL(request.ExportBucket, request.ExportPath)
+}
+
+// ProcessDataRequest carries out a pending request. Exports gather the user's forms, KYC
+// results, identity documents and user_meta into a JSON file they can download for
+// DataExportTTL. Erasures delete the same data and its files, except what legal holds keep.
+// Closures close the user's account. Requests that fail stay pending so they can be processed
+// again.
+func (s *FormService) ProcessDataRequest(ctx context.Context, requestID, processedBy uuid.UUID) (*db.DataSubjectRequest, error) {
+	request, err := s.store.GetDataSubjectRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != db.DataRequestStatusPending {
+		return nil, ErrDataRequestProcessed
+	}
+
+	user, err := s.store.GetUser(ctx, request.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var params db.CompleteDataSubjectRequestParams
+	switch request.Type {
+	case db.DataRequestTypeExport:
+		params, err = s.exportUserData(ctx, user, request)
+	case db.DataRequestTypeErasure:
+		params, err = s.eraseUserData(ctx, user)
+	case db.DataRequestTypeClosure:
+		params, err = s.closeUserAccount(ctx, user)
+	default:
+		err = fmt.Errorf("unknown data request type %q", request.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	params.ID = request.ID
+	params.ProcessedBy = processedBy
+	completed, err := s.store.CompleteDataSubjectRequest(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("data request processed", map[string]interface{}{
+		"request_id":   completed.ID,
+		"user_id":      completed.UserID,
+		"type":         completed.Type,
+		"status":       completed.Status,
+		"processed_by": processedBy,
+	})
+	return &completed, nil
+}
+
+func (s *FormService) exportUserData(ctx context.Context, user db.User, request db.DataSubjectRequest) (db.CompleteDataSubjectRequestParams, error) {
+	data, err := s.store.GetUserPersonalData(ctx, user.ID, user.Email)
+	if err != nil {
+		return db.CompleteDataSubjectRequestParams{}, err
+	}
+	decryptUserMeta(data.UserMeta)
+
+	now := time.Now()
+	body, err := json.MarshalIndent(UserDataExport{
+		UserID:           user.ID,
+		Email:            user.Email,
+		GeneratedAt:      now,
+		UserPersonalData: data,
+	}, "", "  ")
+	if err != nil {
+		return db.CompleteDataSubjectRequestParams{}, err
+	}
+
+	path := fmt.Sprintf("data-exports/%s/%s.json", user.ID, request.ID)
+	if err := s.uploader.Upload(bytes.NewReader(body), s.config.FileBucket, path); err != nil {
+		return db.CompleteDataSubjectRequestParams{}, fmt.Errorf("failed to upload data export: %w", err)
+	}
+
+	summary, err := json.Marshal(DataExportSummary{
+		FormSubmissions:       len(data.FormSubmissions),
+		FormSubmissionFiles:   len(data.FormSubmissionFiles),
+		PublicFormSubmissions: len(data.PublicFormSubmissions),
+		KYCRequirements:       len(data.KYCRequirements),
+		Documents:             len(data.Documents),
+		IdentityDocuments:     len(data.IdentityDocuments),
+		IdentityVerifications: len(data.IdentityVerifications),
+		UserMeta:              len(data.UserMeta),
+	})
+	if err != nil {
+		return db.CompleteDataSubjectRequestParams{}, err
+	}
+
+	return db.CompleteDataSubjectRequestParams{
+		Status:          db.DataRequestStatusCompleted,
+		Result:          summary,
+		ExportBucket:    s.config.FileBucket,
+		ExportPath:      path,
+		ExportExpiresAt: now.Add(DataExportTTL),
+	}, nil
+}
+
+// decryptUserMeta decrypts the user_meta values persisted from encrypted form fields. Values
+// that can't be decrypted are exported as stored.
+func decryptUserMeta(meta []db.UserMetum) {
+	for i, m := range meta {
+		if !m.Value.Valid || !db.IsEncryptedFieldValue(m.Value.String) {
+			continue
+		}
+		if plaintext, err := db.DecryptFieldValue(m.Value.String); err == nil {
+			meta[i].Value.String = plaintext
+		}
+	}
+}
+
+func (s *FormService) eraseUserData(ctx context.Context, user db.User) (db.CompleteDataSubjectRequestParams, error) {
+	holds, err := s.store.ListLegalHolds(ctx, db.ListLegalHoldsParams{
+		UserID:     db.NewNullUUID(user.ID),
+		ActiveOnly: true,
+		Limit:      maxUserRecordsRead,
+	})
+	if err != nil {
+		return db.CompleteDataSubjectRequestParams{}, err
+	}
+	keep, all := heldForms(holds)
+	if all {
+		return db.CompleteDataSubjectRequestParams{
+			Status: db.DataRequestStatusHeld,
+			Error:  "all of the user's data is under a legal hold",
+		}, nil
+	}
+
+	data, err := s.store.GetUserPersonalData(ctx, user.ID, user.Email)
+	if err != nil {
+		return db.CompleteDataSubjectRequestParams{}, err
+	}
+
+	// Files go first; if one can't be deleted nothing is erased and the request can be retried
+	files := erasableFiles(data, keep)
+	for _, file := range files {
+		if err := s.uploader.Delete(file.bucket, file.path); err != nil {
+			return db.CompleteDataSubjectRequestParams{}, fmt.Errorf("unable to delete %s: %w", file.path, err)
+		}
+	}
+	if err := s.deleteUserDataExports(ctx, user.ID); err != nil {
+		return db.CompleteDataSubjectRequestParams{}, err
+	}
+
+	erased, err := s.store.EraseUserDataTx(ctx, db.EraseUserDataInput{
+		UserID:      user.ID,
+		Email:       user.Email,
+		KeepFormIDs: keep,
+	})
+	if errors.Is(err, db.ErrUserDataHeld) {
+		return db.CompleteDataSubjectRequestParams{}, fmt.Errorf("a legal hold was placed while erasing, process the request again: %w", err)
+	}
+	if err != nil {
+		return db.CompleteDataSubjectRequestParams{}, err
+	}
+
+	summary, err := json.Marshal(ErasureSummary{
+		Erased:       erased,
+		FilesDeleted: len(files),
+		KeptFormIDs:  keep,
+	})
+	if err != nil {
+		return db.CompleteDataSubjectRequestParams{}, err
+	}
+	return db.CompleteDataSubjectRequestParams{
+		Status: db.DataRequestStatusCompleted,
+		Result: summary,
+	}, nil
+}
+
+// AccountClosure is the result of a closure request
+type AccountClosure struct {
+	ClosedAt time.Time `json:"closed_at"`
+}
+
+func (s *FormService) closeUserAccount(ctx context.Context, user db.User) (db.CompleteDataSubjectRequestParams, error) {
+	closedAt, err := s.store.CloseUserAccount(ctx, user.ID)
+	if err != nil {
+		return db.CompleteDataSubjectRequestParams{}, err
+	}
+
+	result, err := json.Marshal(AccountClosure{ClosedAt: closedAt})
+	if err != nil {
+		return db.CompleteDataSubjectRequestParams{}, err
+	}
+	return db.CompleteDataSubjectRequestParams{
+		Status: db.DataRequestStatusCompleted,
+		Result: result,
+	}, nil
+}
+
+type storedFile struct {
+	bucket string
+	path   string
+}
+
+// erasableFiles returns the files in storage of a user's data, less the uploads of the
+// submissions of forms to keep. Documents aren't tied to one form, so while any form is kept
+// they are kept too, as EraseUserDataTx keeps their records.
+func erasableFiles(data db.UserPersonalData, keepFormIDs []uuid.UUID) []storedFile {
+	kept := make(map[uuid.UUID]bool, len(keepFormIDs))
+	for _, id := range keepFormIDs {
+		kept[id] = true
+	}
+	submissionForms := make(map[uuid.UUID]uuid.UUID, len(data.FormSubmissions))
+	for _, submission := range data.FormSubmissions {
+		submissionForms[submission.ID] = submission.FormDefinitionID
+	}
+
+	files := []storedFile{}
+	for _, file := range data.FormSubmissionFiles {
+		if !kept[submissionForms[file.FormSubmissionID]] {
+			files = append(files, storedFile{file.Bucket, file.FilePath})
+		}
+	}
+	if len(keepFormIDs) > 0 {
+		return files
+	}
+	for _, document := range data.Documents {
+		files = append(files, storedFile{document.Bucket, document.DocumentPath})
+	}
+	for _, document := range data.IdentityDocuments {
+		files = append(files, storedFile{document.Bucket, document.DocumentPath})
+	}
+	return files
+}
+
+// deleteUserDataExports deletes the exports made for a user, which hold the data being erased
+func (s *FormService) deleteUserDataExports(ctx context.Context, userID uuid.UUID) error {
+	requests, err := s.store.ListDataSubjectRequests(ctx, db.ListDataSubjectRequestsParams{
+		UserID: db.NewNullUUID(userID),
+		Status: db.DataRequestStatusCompleted,
+		Limit:  maxUserRecordsRead,
+	})
+	if err != nil {
+		return err
+	}
+	for _, request := range requests {
+		if request.ExportPath == "" {
+			continue
+		}
+		if err := s.uploader.Delete(request.ExportBucket, request.ExportPath); err != nil {
+			return fmt.Errorf("unable to delete %s: %w", request.ExportPath, err)
+		}
+		if err := s.store.ClearDataSubjectExport(ctx, request.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteExpiredDataExports deletes exports past their expiry from storage, returning how many
+func (s *FormService) deleteExpiredDataExports(ctx context.Context, now time.Time) (int, error) {
+	requests, err := s.store.ListExpiredDataExports(ctx, now, retentionPurgeBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, request := range requests {
+		if err := s.uploader.Delete(request.ExportBucket, request.ExportPath); err != nil {
+			s.logger.Error(err, map[string]interface{}{
+				"request_id": request.ID,
+			})
+			continue
+		}
+		if err := s.store.ClearDataSubjectExport(ctx, request.ID); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
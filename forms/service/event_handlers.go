@@ -0,0 +1,298 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/mailer"
+	"github.com/timchuks/monieverse/internal/worker"
+)
+
+// Handler types of form events
+const (
+	HandlerTypeWebhook      = "webhook"
+	HandlerTypeEmail        = "email"
+	HandlerTypeTask         = "task"
+	HandlerTypeSetUserField = "set_user_field"
+)
+
+// Headers sent with webhook deliveries
+const (
+	WebhookHeaderEvent     = "X-Form-Event"
+	WebhookHeaderDelivery  = "X-Form-Delivery"
+	WebhookHeaderTimestamp = "X-Form-Timestamp"
+	WebhookHeaderSignature = "X-Form-Signature"
+)
+
+// RecipientSubmitter in an email handler's recipients is replaced by the submitter's email; public
+// submitters are only mailed at an address they verified
+const RecipientSubmitter = "submitter"
+
+// WebhookConfig is the handler_config of a webhook event
+type WebhookConfig struct {
+	URL            string            `json:"url"`
+	Secret         string            `json:"secret"`
+	Headers        map[string]string `json:"headers"`
+	TimeoutSeconds int               `json:"timeout_seconds"`
+}
+
+// EmailConfig is the handler_config of an email event
+type EmailConfig struct {
+	To       []string `json:"to"`
+	Subject  string   `json:"subject"`
+	Template string   `json:"template"`
+}
+
+// TaskConfig is the handler_config of a task event
+type TaskConfig struct {
+	Task string `json:"task"`
+}
+
+// SetUserFieldConfig is the handler_config of a set_user_field event. The value is either
+// fixed or copied from a field of the submission.
+type SetUserFieldConfig struct {
+	// Target is "user" (default) for a column of the user or "meta" for a user_meta key
+	Target    string      `json:"target"`
+	Field     string      `json:"field"`
+	Value     interface{} `json:"value"`
+	ValueFrom string      `json:"value_from"`
+}
+
+// Mailer sends templated email
+type Mailer interface {
+	Send(msg mailer.Message) error
+}
+
+func decodeHandlerConfig(delivery db.FormEventOutbox, config interface{}) error {
+	if err := json.Unmarshal(delivery.HandlerConfig, config); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidHandlerConfig, err)
+	}
+	return nil
+}
+
+// SignWebhookPayload is the hex HMAC-SHA256 of "<timestamp>.<body>" with the webhook secret.
+// Receivers should recompute it and reject stale timestamps.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewWebhookHandler posts the event payload to the configured URL, signed with the secret
+func NewWebhookHandler(client *http.Client) EventHandler {
+	return EventHandlerFunc(func(ctx context.Context, delivery db.FormEventOutbox) error {
+		var config WebhookConfig
+		if err := decodeHandlerConfig(delivery, &config); err != nil {
+			return err
+		}
+		if config.URL == "" {
+			return fmt.Errorf("%w: webhook url is required", ErrInvalidHandlerConfig)
+		}
+
+		if config.TimeoutSeconds > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(config.TimeoutSeconds)*time.Second)
+			defer cancel()
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.URL, bytes.NewReader(delivery.Payload))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidHandlerConfig, err)
+		}
+
+		timestamp := time.Now().Unix()
+		for key, value := range config.Headers {
+			req.Header.Set(key, value)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(WebhookHeaderEvent, delivery.EventType)
+		req.Header.Set(WebhookHeaderDelivery, delivery.ID.String())
+		req.Header.Set(WebhookHeaderTimestamp, strconv.FormatInt(timestamp, 10))
+		if config.Secret != "" {
+			req.Header.Set(WebhookHeaderSignature, "sha256="+SignWebhookPayload(config.Secret, timestamp, delivery.Payload))
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("webhook responded with %s", resp.Status)
+		}
+		return nil
+	})
+}
+
+// NewEmailHandler sends the configured mail template to each recipient with the event payload as data
+func NewEmailHandler(mail Mailer, store db.Store) EventHandler {
+	return EventHandlerFunc(func(ctx context.Context, delivery db.FormEventOutbox) error {
+		var config EmailConfig
+		if err := decodeHandlerConfig(delivery, &config); err != nil {
+			return err
+		}
+		if len(config.To) == 0 || config.Template == "" {
+			return fmt.Errorf("%w: email needs recipients and a template", ErrInvalidHandlerConfig)
+		}
+
+		var data map[string]interface{}
+		if err := json.Unmarshal(delivery.Payload, &data); err != nil {
+			return err
+		}
+
+		for _, to := range config.To {
+			if to == RecipientSubmitter {
+				email, err := submitterEmail(ctx, store, delivery)
+				if err != nil {
+					return err
+				}
+				to = email
+			}
+
+			if err := mail.Send(mailer.Message{
+				To:       to,
+				Subject:  config.Subject,
+				Template: config.Template,
+				Data:     data,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// submitterEmail is the email of the user who made the submission of an event, or the email an
+// anonymous visitor gave with a public submission once they have verified it. Addresses typed
+// into the answers are never mailed.
+func submitterEmail(ctx context.Context, store db.Store, delivery db.FormEventOutbox) (string, error) {
+	if delivery.UserID.Valid {
+		user, err := store.GetUser(ctx, delivery.UserID.UUID)
+		if err != nil {
+			return "", err
+		}
+		return user.Email, nil
+	}
+
+	var payload EventPayload
+	if err := json.Unmarshal(delivery.Payload, &payload); err != nil {
+		return "", fmt.Errorf("%w: invalid payload: %v", ErrInvalidHandlerConfig, err)
+	}
+	if payload.PublicSubmissionID == nil {
+		return "", fmt.Errorf("%w: event %s has no submitter", ErrInvalidHandlerConfig, delivery.EventType)
+	}
+
+	submission, err := store.GetPublicFormSubmission(ctx, *payload.PublicSubmissionID)
+	if err != nil {
+		return "", err
+	}
+	if !submission.VerifiedAt.Valid || submission.Email == "" {
+		return "", fmt.Errorf("%w: event %s has no verified submitter email", ErrInvalidHandlerConfig, delivery.EventType)
+	}
+	return submission.Email, nil
+}
+
+// NewTaskHandler fires a background job with the event payload
+func NewTaskHandler(distributor worker.TaskDistributor) EventHandler {
+	return EventHandlerFunc(func(ctx context.Context, delivery db.FormEventOutbox) error {
+		var config TaskConfig
+		if err := decodeHandlerConfig(delivery, &config); err != nil {
+			return err
+		}
+		if config.Task == "" {
+			return fmt.Errorf("%w: task is required", ErrInvalidHandlerConfig)
+		}
+
+		return distributor.Fire(ctx, config.Task, delivery.Payload)
+	})
+}
+
+// userFieldSetters are the user columns a set_user_field event may change
+var userFieldSetters = map[string]func(params *db.UpdateUserParams, value string){
+	"first_name":    func(p *db.UpdateUserParams, v string) { p.FirstName = db.NewNullString(v) },
+	"middle_name":   func(p *db.UpdateUserParams, v string) { p.MiddleName = db.NewNullString(v) },
+	"last_name":     func(p *db.UpdateUserParams, v string) { p.LastName = db.NewNullString(v) },
+	"business_name": func(p *db.UpdateUserParams, v string) { p.BusinessName = db.NewNullString(v) },
+	"address":       func(p *db.UpdateUserParams, v string) { p.Address = db.NewNullString(v) },
+	"city":          func(p *db.UpdateUserParams, v string) { p.City = db.NewNullString(v) },
+	"state":         func(p *db.UpdateUserParams, v string) { p.State = db.NewNullString(v) },
+	"zipcode":       func(p *db.UpdateUserParams, v string) { p.Zipcode = db.NewNullString(v) },
+	"kyc_verified": func(p *db.UpdateUserParams, v string) {
+		p.KycVerified = db.NewNullString(v)
+		p.KycVerifiedAt = db.NewNullTime(time.Now())
+	},
+}
+
+// NewSetUserFieldHandler sets a column or meta key of the submitting user
+func NewSetUserFieldHandler(store db.Store) EventHandler {
+	return EventHandlerFunc(func(ctx context.Context, delivery db.FormEventOutbox) error {
+		var config SetUserFieldConfig
+		if err := decodeHandlerConfig(delivery, &config); err != nil {
+			return err
+		}
+		if config.Field == "" {
+			return fmt.Errorf("%w: field is required", ErrInvalidHandlerConfig)
+		}
+		if !delivery.UserID.Valid {
+			return fmt.Errorf("%w: event %s has no user", ErrInvalidHandlerConfig, delivery.EventType)
+		}
+
+		value := config.Value
+		if config.ValueFrom != "" {
+			if !delivery.FormSubmissionID.Valid {
+				return fmt.Errorf("%w: event %s has no submission", ErrInvalidHandlerConfig, delivery.EventType)
+			}
+			submission, err := store.GetFormSubmission(ctx, delivery.FormSubmissionID.UUID)
+			if err != nil {
+				return err
+			}
+			var data map[string]interface{}
+			if err := json.Unmarshal(submission.SubmissionData, &data); err != nil {
+				return err
+			}
+			value = data[config.ValueFrom]
+		}
+		if value == nil {
+			return fmt.Errorf("%w: no value for %s", ErrInvalidHandlerConfig, config.Field)
+		}
+
+		switch config.Target {
+		case "", "user":
+			set, ok := userFieldSetters[config.Field]
+			if !ok {
+				return fmt.Errorf("%w: user field %q cannot be set", ErrInvalidHandlerConfig, config.Field)
+			}
+			params := db.UpdateUserParams{ID: delivery.UserID.UUID}
+			set(&params, fmt.Sprint(value))
+			_, err := store.UpdateUser(ctx, params)
+			return err
+
+		case "meta":
+			datatype := db.DatatypeString
+			if _, ok := value.(bool); ok {
+				datatype = db.DatatypeBoolean
+			}
+			return store.SetUserMeta(ctx, db.UserMetaCreateParams{
+				UserID:   delivery.UserID.UUID,
+				Key:      config.Field,
+				Value:    fmt.Sprint(value),
+				Datatype: datatype,
+			})
+
+		default:
+			return fmt.Errorf("%w: unknown target %q", ErrInvalidHandlerConfig, config.Target)
+		}
+	})
+}
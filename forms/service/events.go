@@ -0,0 +1,361 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+)
+
+// Lifecycle events of a submission. Handlers are attached to these, or to the before_/after_
+// hooks, with form_events rows. Every event is written with the change that fires it and
+// delivered once that change has committed, so before_ hooks can't veto a change: they carry
+// the input of the change where after_ hooks carry its result.
+const (
+	EventDraftSaved    = "draft_saved"
+	EventStepCompleted = "step_completed"
+	EventSubmitted     = "submitted"
+	EventApproved      = "approved"
+	EventRejected      = "rejected"
+)
+
+// MaxEventDeliveryAttempts is how many times a delivery is tried before it is marked failed
+const MaxEventDeliveryAttempts = 10
+
+const (
+	eventDeliveryBatch = 50
+	eventDeliveryLease = 2 * time.Minute
+	eventRetryBase     = 30 * time.Second
+	eventRetryMax      = 6 * time.Hour
+)
+
+// ErrInvalidHandlerConfig is returned by handlers whose configuration can never succeed. Such
+// deliveries are not retried.
+var ErrInvalidHandlerConfig = errors.New("invalid event handler config")
+
+// EventPayload is the body delivered to event handlers. The outbox only keeps what an event is
+// about: the answers of its submission are left out of Data and loaded into Answers when the
+// event is delivered, so they are only ever stored with the submission, encrypted and erased
+// with it.
+type EventPayload struct {
+	Event              string                 `json:"event"`
+	FormID             uuid.UUID              `json:"form_id"`
+	SubmissionID       *uuid.UUID             `json:"submission_id,omitempty"`
+	PublicSubmissionID *uuid.UUID             `json:"public_submission_id,omitempty"`
+	UserID             *uuid.UUID             `json:"user_id,omitempty"`
+	OccurredAt         time.Time              `json:"occurred_at"`
+	Data               interface{}            `json:"data"`
+	Answers            map[string]interface{} `json:"answers,omitempty"`
+}
+
+// EventHandler performs the action of a form event. Deliveries are at least once, so handlers
+// may see the same delivery more than once.
+type EventHandler interface {
+	Handle(ctx context.Context, delivery db.FormEventOutbox) error
+}
+
+// EventHandlerFunc adapts a function to EventHandler
+type EventHandlerFunc func(ctx context.Context, delivery db.FormEventOutbox) error
+
+func (f EventHandlerFunc) Handle(ctx context.Context, delivery db.FormEventOutbox) error {
+	return f(ctx, delivery)
+}
+
+// RegisterEventHandler adds or replaces the handler for a form event handler type
+func (s *FormService) RegisterEventHandler(handlerType string, handler EventHandler) {
+	s.registry.Lock()
+	defer s.registry.Unlock()
+	s.eventHandlers[handlerType] = handler
+}
+
+// eventHandler returns the handler of a form event handler type
+func (s *FormService) eventHandler(handlerType string) (EventHandler, bool) {
+	s.registry.RLock()
+	defer s.registry.RUnlock()
+	handler, ok := s.eventHandlers[handlerType]
+	return handler, ok
+}
+
+// formEvent is an event fired by a change, with the data delivered to its handlers. The
+// submission is the one the event is about, for events whose data doesn't identify it.
+type formEvent struct {
+	eventType  string
+	data       interface{}
+	submission *db.FormSubmission
+}
+
+// outboxEvents builds the outbox rows of events fired by a change to a form. They are written in
+// the transaction of the change, so an event is delivered if and only if its change commits.
+// Events without a type are skipped.
+func outboxEvents(formID uuid.UUID, events ...formEvent) ([]db.EnqueueFormEventsParams, error) {
+	occurredAt := time.Now()
+
+	params := make([]db.EnqueueFormEventsParams, 0, len(events))
+	for _, event := range events {
+		if event.eventType == "" {
+			continue
+		}
+
+		submissionID, userID := eventSubject(event.data)
+		if event.submission != nil {
+			submissionID, userID = db.NewNullUUID(event.submission.ID), db.NewNullUUID(event.submission.UserID)
+		}
+
+		payload := EventPayload{
+			Event:      event.eventType,
+			FormID:     formID,
+			OccurredAt: occurredAt,
+			Data:       withoutAnswers(event.data),
+		}
+		if submissionID.Valid {
+			payload.SubmissionID = &submissionID.UUID
+		}
+		if public, ok := event.data.(*db.PublicFormSubmission); ok && public != nil {
+			payload.PublicSubmissionID = &public.ID
+		}
+		if userID.Valid {
+			payload.UserID = &userID.UUID
+		}
+
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s event: %w", event.eventType, err)
+		}
+
+		params = append(params, db.EnqueueFormEventsParams{
+			FormDefinitionID: formID,
+			EventType:        event.eventType,
+			FormSubmissionID: submissionID,
+			UserID:           userID,
+			Payload:          body,
+		})
+	}
+
+	return params, nil
+}
+
+// savedEvent returns the lifecycle event of a saved submission: draft_saved for drafts and
+// submitted when it leaves draft. Other saves fire no lifecycle event.
+func savedEvent(previousStatus string, submission *db.FormSubmission) formEvent {
+	switch {
+	case submission.Status == "draft":
+		return formEvent{eventType: EventDraftSaved, data: submission}
+	case submission.Status == "submitted" && previousStatus != "submitted":
+		return formEvent{eventType: EventSubmitted, data: submission}
+	}
+	return formEvent{}
+}
+
+// eventsEnqueued has the worker dispatch events, so that events written with a change are
+// delivered straight away instead of at the next scheduled dispatch
+func (s *FormService) eventsEnqueued(ctx context.Context) {
+	if s.taskDistributor == nil {
+		return
+	}
+	if err := s.taskDistributor.Fire(ctx, TaskFormEventDispatch, nil); err != nil {
+		s.logger.Error(err, map[string]interface{}{"task": TaskFormEventDispatch})
+	}
+}
+
+// DispatchPendingEvents delivers the outbox rows that are due, including retries and rows
+// abandoned by a crashed worker. It returns the number of deliveries attempted.
+func (s *FormService) DispatchPendingEvents(ctx context.Context) (int, error) {
+	deliveries, err := s.store.ClaimFormEventOutbox(ctx, eventDeliveryBatch, eventDeliveryLease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range deliveries {
+		s.deliverEvent(ctx, delivery)
+	}
+
+	return len(deliveries), nil
+}
+
+// dispatchAllPendingEvents dispatches batches of pending events until none are left
+func (s *FormService) dispatchAllPendingEvents(ctx context.Context) error {
+	for {
+		n, err := s.DispatchPendingEvents(ctx)
+		if err != nil || n < eventDeliveryBatch {
+			return err
+		}
+	}
+}
+
+func (s *FormService) deliverEvent(ctx context.Context, delivery db.FormEventOutbox) {
+	var err error
+	if handler, ok := s.eventHandler(delivery.HandlerType); !ok {
+		err = fmt.Errorf("%w: unknown handler type %q", ErrInvalidHandlerConfig, delivery.HandlerType)
+	} else if delivery.Payload, err = s.withAnswers(ctx, delivery.Payload); err == nil {
+		err = handler.Handle(ctx, delivery)
+	}
+
+	if err == nil {
+		if err := s.store.MarkFormEventOutboxDelivered(ctx, delivery.ID); err != nil {
+			s.logger.Error(err, map[string]interface{}{
+				"delivery_id": delivery.ID,
+			})
+		}
+		return
+	}
+
+	giveUp := errors.Is(err, ErrInvalidHandlerConfig) || delivery.Attempts >= MaxEventDeliveryAttempts
+	s.logger.Error(err, map[string]interface{}{
+		"delivery_id":  delivery.ID,
+		"form_id":      delivery.FormDefinitionID,
+		"event":        delivery.EventType,
+		"handler_type": delivery.HandlerType,
+		"attempts":     delivery.Attempts,
+		"gave_up":      giveUp,
+	})
+
+	if err := s.store.FailFormEventOutbox(ctx, db.FailFormEventOutboxParams{
+		ID:            delivery.ID,
+		LastError:     err.Error(),
+		NextAttemptAt: time.Now().Add(EventRetryDelay(delivery.Attempts)),
+		GiveUp:        giveUp,
+	}); err != nil {
+		s.logger.Error(err, map[string]interface{}{
+			"delivery_id": delivery.ID,
+		})
+	}
+}
+
+// withAnswers adds the answers of the submission an event is about to its payload, as they are
+// stored when the event is delivered. Events of erased submissions are delivered without them.
+func (s *FormService) withAnswers(ctx context.Context, body json.RawMessage) (json.RawMessage, error) {
+	var payload struct {
+		EventPayload
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("%w: invalid payload: %v", ErrInvalidHandlerConfig, err)
+	}
+
+	var answers json.RawMessage
+	switch {
+	case payload.SubmissionID != nil:
+		submission, err := s.store.GetFormSubmission(ctx, *payload.SubmissionID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return body, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		answers = submission.SubmissionData
+	case payload.PublicSubmissionID != nil:
+		submission, err := s.store.GetPublicFormSubmission(ctx, *payload.PublicSubmissionID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return body, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		answers = submission.SubmissionData
+	default:
+		return body, nil
+	}
+
+	if len(answers) > 0 {
+		if err := json.Unmarshal(answers, &payload.Answers); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(payload)
+}
+
+// withoutAnswers returns a copy of event data without the answers of the submission, and without
+// the email a public submitter gave
+func withoutAnswers(data interface{}) interface{} {
+	switch d := data.(type) {
+	case *db.FormSubmission:
+		if d != nil {
+			submission := *d
+			submission.SubmissionData = nil
+			return submission
+		}
+	case db.FormSubmission:
+		d.SubmissionData = nil
+		return d
+	case *db.ApprovalDecisionResult:
+		if d != nil {
+			result := *d
+			result.Submission.SubmissionData = nil
+			return result
+		}
+	case *db.PublicFormSubmission:
+		if d != nil {
+			submission := *d
+			submission.SubmissionData = nil
+			submission.Email = ""
+			return submission
+		}
+	case SubmitFormInput:
+		d.Data = nil
+		return d
+	case CreateSubmissionInput:
+		d.Data = nil
+		return d
+	case UpdateSubmissionInput:
+		d.Data = nil
+		return d
+	case SaveStepProgressInput:
+		d.Data = nil
+		return d
+	}
+	return data
+}
+
+// EventRetryDelay is the backoff before the next attempt of a delivery, doubling from 30
+// seconds up to 6 hours
+func EventRetryDelay(attempts int32) time.Duration {
+	delay := eventRetryBase
+	for i := int32(1); i < attempts; i++ {
+		delay *= 2
+		if delay >= eventRetryMax {
+			return eventRetryMax
+		}
+	}
+	return delay
+}
+
+// eventSubject finds the submission and user an event is about
+func eventSubject(data interface{}) (submissionID, userID uuid.NullUUID) {
+	switch d := data.(type) {
+	case *db.FormSubmission:
+		if d != nil {
+			return db.NewNullUUID(d.ID), db.NewNullUUID(d.UserID)
+		}
+	case db.FormSubmission:
+		return db.NewNullUUID(d.ID), db.NewNullUUID(d.UserID)
+	case *db.ApprovalDecisionResult:
+		if d != nil {
+			return db.NewNullUUID(d.Submission.ID), db.NewNullUUID(d.Submission.UserID)
+		}
+	case *db.PublicFormSubmission:
+		// Public submissions aren't form_submissions rows; their ID is in the payload
+		if d != nil {
+			return uuid.NullUUID{}, d.UserID
+		}
+	case db.FormApprovalTask:
+		return db.NewNullUUID(d.FormSubmissionID), uuid.NullUUID{}
+	case []db.FormApprovalTask:
+		if len(d) > 0 {
+			return db.NewNullUUID(d[0].FormSubmissionID), uuid.NullUUID{}
+		}
+	case SubmitFormInput:
+		return uuid.NullUUID{}, db.NewNullUUID(d.UserID)
+	case CreateSubmissionInput:
+		return uuid.NullUUID{}, db.NewNullUUID(d.UserID)
+	case UpdateSubmissionInput:
+		return db.NewNullUUID(d.SubmissionID), db.NewNullUUID(d.UserID)
+	case SaveStepProgressInput:
+		return db.NewNullUUID(d.SubmissionID), db.NewNullUUID(d.UserID)
+	}
+	return uuid.NullUUID{}, uuid.NullUUID{}
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+)
+
+func TestWebhookHandlerSignsPayload(t *testing.T) {
+	var received *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	payload := json.RawMessage(`{"event":"submitted"}`)
+	delivery := db.FormEventOutbox{
+		ID:            uuid.New(),
+		EventType:     EventSubmitted,
+		HandlerConfig: json.RawMessage(`{"url":"` + server.URL + `","secret":"s3cret","headers":{"X-Tenant":"monieverse"}}`),
+		Payload:       payload,
+	}
+
+	handler := NewWebhookHandler(server.Client())
+	require.NoError(t, handler.Handle(context.Background(), delivery))
+
+	require.Equal(t, http.MethodPost, received.Method)
+	require.JSONEq(t, string(payload), string(body))
+	require.Equal(t, EventSubmitted, received.Header.Get(WebhookHeaderEvent))
+	require.Equal(t, delivery.ID.String(), received.Header.Get(WebhookHeaderDelivery))
+	require.Equal(t, "monieverse", received.Header.Get("X-Tenant"))
+
+	timestamp, err := strconv.ParseInt(received.Header.Get(WebhookHeaderTimestamp), 10, 64)
+	require.NoError(t, err)
+	require.Equal(t, "sha256="+SignWebhookPayload("s3cret", timestamp, body), received.Header.Get(WebhookHeaderSignature))
+}
+
+func TestWebhookHandlerErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	handler := NewWebhookHandler(server.Client())
+
+	// A failing endpoint is retried
+	err := handler.Handle(context.Background(), db.FormEventOutbox{
+		HandlerConfig: json.RawMessage(`{"url":"` + server.URL + `"}`),
+		Payload:       json.RawMessage(`{}`),
+	})
+	require.Error(t, err)
+	require.False(t, errors.Is(err, ErrInvalidHandlerConfig))
+
+	// A webhook without a URL never will be
+	err = handler.Handle(context.Background(), db.FormEventOutbox{
+		HandlerConfig: json.RawMessage(`{}`),
+		Payload:       json.RawMessage(`{}`),
+	})
+	require.ErrorIs(t, err, ErrInvalidHandlerConfig)
+}
+
+func TestEventRetryDelay(t *testing.T) {
+	require.Equal(t, 30*time.Second, EventRetryDelay(1))
+	require.Equal(t, time.Minute, EventRetryDelay(2))
+	require.Equal(t, 4*time.Minute, EventRetryDelay(4))
+	require.Equal(t, 6*time.Hour, EventRetryDelay(MaxEventDeliveryAttempts+5))
+}
+
+// submissionStore serves submissions by ID; other store methods are not used
+type submissionStore struct {
+	db.Store
+	submissions map[uuid.UUID]db.FormSubmission
+}
+
+func (s submissionStore) GetFormSubmission(_ context.Context, id uuid.UUID) (db.FormSubmission, error) {
+	submission, ok := s.submissions[id]
+	if !ok {
+		return db.FormSubmission{}, sql.ErrNoRows
+	}
+	return submission, nil
+}
+
+func TestOutboxEventsLeaveAnswersOut(t *testing.T) {
+	submission := db.FormSubmission{
+		ID:             uuid.New(),
+		UserID:         uuid.New(),
+		Status:         "submitted",
+		SubmissionData: json.RawMessage(`{"bvn":"22212345678"}`),
+	}
+	input := SubmitFormInput{UserID: submission.UserID, Data: map[string]interface{}{"bvn": "22212345678"}}
+
+	params, err := outboxEvents(uuid.New(), formEvent{eventType: EventSubmitted, data: &submission}, formEvent{eventType: "after_submit", data: input, submission: &submission})
+	require.NoError(t, err)
+	require.Len(t, params, 2)
+	for _, p := range params {
+		require.NotContains(t, string(p.Payload), "22212345678")
+	}
+	require.Equal(t, db.NewNullUUID(submission.ID), params[0].FormSubmissionID)
+
+	// Events fired with the input of a change are about the submission it made
+	before, err := outboxEvents(uuid.New(), formEvent{eventType: "before_submit", data: input, submission: &submission})
+	require.NoError(t, err)
+	require.Equal(t, db.NewNullUUID(submission.ID), before[0].FormSubmissionID)
+	require.Equal(t, db.NewNullUUID(submission.UserID), before[0].UserID)
+
+	// Answers are loaded when the event is delivered
+	s := &FormService{store: submissionStore{submissions: map[uuid.UUID]db.FormSubmission{submission.ID: submission}}}
+	body, err := s.withAnswers(context.Background(), params[0].Payload)
+	require.NoError(t, err)
+
+	var payload EventPayload
+	require.NoError(t, json.Unmarshal(body, &payload))
+	require.Equal(t, map[string]interface{}{"bvn": "22212345678"}, payload.Answers)
+	require.Equal(t, submission.ID, *payload.SubmissionID)
+
+	// Erased submissions are delivered without them
+	s.store = submissionStore{submissions: map[uuid.UUID]db.FormSubmission{}}
+	body, err = s.withAnswers(context.Background(), params[0].Payload)
+	require.NoError(t, err)
+	require.JSONEq(t, string(params[0].Payload), string(body))
+}
+
+// publicSubmissionStore serves public submissions by ID; other store methods are not used
+type publicSubmissionStore struct {
+	db.Store
+	submissions map[uuid.UUID]db.PublicFormSubmission
+}
+
+func (s publicSubmissionStore) GetPublicFormSubmission(_ context.Context, id uuid.UUID) (db.PublicFormSubmission, error) {
+	submission, ok := s.submissions[id]
+	if !ok {
+		return db.PublicFormSubmission{}, sql.ErrNoRows
+	}
+	return submission, nil
+}
+
+func TestSubmitterEmailOfPublicSubmission(t *testing.T) {
+	verified := db.PublicFormSubmission{ID: uuid.New(), Email: "ada@example.com", VerifiedAt: sql.NullTime{Time: time.Now(), Valid: true}}
+	unverified := db.PublicFormSubmission{ID: uuid.New(), Email: "eve@example.com"}
+	store := publicSubmissionStore{submissions: map[uuid.UUID]db.PublicFormSubmission{
+		verified.ID:   verified,
+		unverified.ID: unverified,
+	}}
+
+	delivery := func(payload string) db.FormEventOutbox {
+		return db.FormEventOutbox{EventType: EventSubmitted, Payload: json.RawMessage(payload)}
+	}
+
+	email, err := submitterEmail(context.Background(), store, delivery(`{"public_submission_id":"`+verified.ID.String()+`"}`))
+	require.NoError(t, err)
+	require.Equal(t, "ada@example.com", email)
+
+	_, err = submitterEmail(context.Background(), store, delivery(`{"public_submission_id":"`+unverified.ID.String()+`"}`))
+	require.ErrorIs(t, err, ErrInvalidHandlerConfig)
+
+	// An email among the answers is not an address the submitter verified
+	_, err = submitterEmail(context.Background(), store, delivery(`{"data":{"email":"victim@example.com"},"answers":{"email":"victim@example.com"}}`))
+	require.ErrorIs(t, err, ErrInvalidHandlerConfig)
+}
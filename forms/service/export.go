@@ -0,0 +1,521 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+)
+
+// DefaultExportLocale labels exports that don't ask for a locale, and is the fallback for
+// labels missing in the one asked for
+const DefaultExportLocale = DefaultLanguage
+
+// UserExportFormTypes are the form types whose submitters may export their own submissions.
+// Admins can export any submission.
+var UserExportFormTypes = map[string]bool{
+	"kyc": true,
+	"kyb": true,
+}
+
+var ErrExportNotAllowed = errors.New("export of this submission is not allowed")
+
+// Thumbnails of image uploads in exported PDFs
+const (
+	exportThumbnailPixels   = 320
+	exportThumbnailPoints   = 160
+	exportThumbnailMaxBytes = 20 << 20
+)
+
+// Paths of the entries of a submission archive
+const (
+	ExportArchivePDF      = "submission.pdf"
+	ExportArchiveManifest = "manifest.json"
+)
+
+// ExportSubmissionInput selects a submission to export. Users can only export their own,
+// submitted, submissions of a form type in UserExportFormTypes.
+type ExportSubmissionInput struct {
+	SubmissionID uuid.UUID
+	UserID       uuid.UUID
+	Admin        bool
+	Locale       string
+}
+
+// ExportManifest is the manifest.json of a submission archive
+type ExportManifest struct {
+	SubmissionID   uuid.UUID             `json:"submission_id"`
+	FormID         uuid.UUID             `json:"form_id"`
+	FormName       string                `json:"form_name"`
+	FormVersion    int32                 `json:"form_version"`
+	Status         string                `json:"status"`
+	ApprovalStatus string                `json:"approval_status"`
+	ExportedAt     time.Time             `json:"exported_at"`
+	ExportedBy     uuid.UUID             `json:"exported_by"`
+	Entries        []ExportManifestEntry `json:"entries"`
+}
+
+// ExportManifestEntry records the SHA-256 of an entry of a submission archive
+type ExportManifestEntry struct {
+	Path      string     `json:"path"`
+	FileID    *uuid.UUID `json:"file_id,omitempty"`
+	FieldName string     `json:"field_name,omitempty"`
+	FileName  string     `json:"file_name,omitempty"`
+	MimeType  string     `json:"mime_type,omitempty"`
+	Size      int64      `json:"size"`
+	SHA256    string     `json:"sha256"`
+}
+
+// submissionExport is everything an export shows of a submission
+type submissionExport struct {
+	form       db.FormDefinition
+	submission db.FormSubmission
+	steps      []db.FormStep
+	fields     []db.FormField
+	files      []db.FormSubmissionFile
+	history    *ApprovalHistory
+	data       map[string]interface{}
+	locale     string
+	exportedBy uuid.UUID
+	exportedAt time.Time
+}
+
+// unsafeArchiveName matches characters kept out of archive paths
+var unsafeArchiveName = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+func (s *FormService) loadSubmissionExport(ctx context.Context, input ExportSubmissionInput) (*submissionExport, error) {
+	submission, err := s.store.GetFormSubmission(ctx, input.SubmissionID)
+	if err != nil {
+		return nil, fmt.Errorf("submission not found: %w", err)
+	}
+
+	form, err := s.store.GetFormDefinition(ctx, submission.FormDefinitionID)
+	if err != nil {
+		return nil, fmt.Errorf("form not found: %w", err)
+	}
+
+	if !input.Admin {
+		if submission.UserID != input.UserID || !UserExportFormTypes[form.FormType] || submission.Status == "draft" {
+			return nil, ErrExportNotAllowed
+		}
+	}
+
+	steps, fields, err := s.formStructure(ctx, form, submission.FormVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := s.store.GetFormSubmissionFiles(ctx, submission.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get submission files: %w", err)
+	}
+
+	history, err := s.GetApprovalHistory(ctx, submission.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get approval history: %w", err)
+	}
+
+	var data map[string]interface{}
+	if len(submission.SubmissionData) > 0 {
+		if err := json.Unmarshal(submission.SubmissionData, &data); err != nil {
+			return nil, fmt.Errorf("failed to parse submission data: %w", err)
+		}
+	}
+
+	locale := input.Locale
+	if locale == "" {
+		locale = DefaultExportLocale
+	}
+
+	return &submissionExport{
+		form:       form,
+		submission: submission,
+		steps:      steps,
+		fields:     fields,
+		files:      files,
+		history:    history,
+		data:       data,
+		locale:     locale,
+		exportedBy: input.UserID,
+		exportedAt: time.Now().UTC(),
+	}, nil
+}
+
+// ExportSubmissionPDF renders a submission, its uploads and its approval history as a PDF
+func (s *FormService) ExportSubmissionPDF(ctx context.Context, input ExportSubmissionInput) ([]byte, error) {
+	export, err := s.loadSubmissionExport(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return s.renderSubmissionPDF(ctx, export), nil
+}
+
+// ExportSubmissionArchive writes a ZIP archive of the submission PDF, the original uploads and
+// a manifest with the SHA-256 of each
+func (s *FormService) ExportSubmissionArchive(ctx context.Context, input ExportSubmissionInput, w io.Writer) (*ExportManifest, error) {
+	export, err := s.loadSubmissionExport(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return s.writeSubmissionArchive(w, export, s.renderSubmissionPDF(ctx, export))
+}
+
+func (s *FormService) writeSubmissionArchive(w io.Writer, export *submissionExport, pdf []byte) (*ExportManifest, error) {
+	manifest := &ExportManifest{
+		SubmissionID:   export.submission.ID,
+		FormID:         export.form.ID,
+		FormName:       export.form.Name,
+		FormVersion:    export.submission.FormVersion,
+		Status:         export.submission.Status,
+		ApprovalStatus: export.submission.ApprovalStatus,
+		ExportedAt:     export.exportedAt,
+		ExportedBy:     export.exportedBy,
+		Entries:        []ExportManifestEntry{},
+	}
+
+	archive := zip.NewWriter(w)
+	addEntry := func(entry ExportManifestEntry, content io.Reader) error {
+		writer, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     entry.Path,
+			Method:   zip.Deflate,
+			Modified: export.exportedAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		hash := sha256.New()
+		size, err := io.Copy(io.MultiWriter(writer, hash), content)
+		if err != nil {
+			return err
+		}
+
+		entry.Size = size
+		entry.SHA256 = hex.EncodeToString(hash.Sum(nil))
+		manifest.Entries = append(manifest.Entries, entry)
+		return nil
+	}
+
+	if err := addEntry(ExportManifestEntry{Path: ExportArchivePDF, MimeType: "application/pdf"}, bytes.NewReader(pdf)); err != nil {
+		return nil, err
+	}
+
+	for _, file := range export.files {
+		fileID := file.ID
+		entry := ExportManifestEntry{
+			Path:      path.Join("files", archiveName(file.FieldName), archiveName(file.ID.String()+"-"+file.FileName)),
+			FileID:    &fileID,
+			FieldName: file.FieldName,
+			FileName:  file.FileName,
+			MimeType:  file.MimeType,
+		}
+
+		content, err := s.uploader.Download(file.Bucket, file.FilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download %s: %w", file.FileName, err)
+		}
+		err = addEntry(entry, content)
+		content.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The manifest is written last and so doesn't list itself
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	writer, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     ExportArchiveManifest,
+		Method:   zip.Deflate,
+		Modified: export.exportedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(manifestJSON); err != nil {
+		return nil, err
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func archiveName(name string) string {
+	name = unsafeArchiveName.ReplaceAllString(name, "_")
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
+
+func (s *FormService) renderSubmissionPDF(ctx context.Context, export *submissionExport) []byte {
+	doc := newPDFDocument()
+	submission := export.submission
+
+	doc.Text(export.form.Name, 18, true, 0)
+	doc.Space(4)
+
+	submittedBy := submission.UserID.String()
+	if user, err := s.store.GetUser(ctx, submission.UserID); err == nil {
+		submittedBy = user.Email
+	}
+
+	for _, line := range []string{
+		"Submission: " + submission.ID.String(),
+		"Submitted by: " + submittedBy,
+		fmt.Sprintf("Form version: %d", submission.FormVersion),
+		"Status: " + submission.Status,
+		"Approval status: " + submission.ApprovalStatus,
+		"Created: " + formatExportTime(submission.CreatedAt),
+		"Last updated: " + formatExportTime(submission.UpdatedAt),
+		"Exported: " + formatExportTime(export.exportedAt),
+	} {
+		doc.Text(line, 9, false, 0)
+	}
+	doc.Rule()
+
+	files := make(map[string][]db.FormSubmissionFile)
+	for _, file := range export.files {
+		files[file.FieldName] = append(files[file.FieldName], file)
+	}
+
+	fields := append([]db.FormField(nil), export.fields...)
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].DisplayOrder < fields[j].DisplayOrder
+	})
+
+	steps := append([]db.FormStep(nil), export.steps...)
+	sort.SliceStable(steps, func(i, j int) bool {
+		return steps[i].StepNumber < steps[j].StepNumber
+	})
+
+	rendered := make(map[uuid.UUID]bool, len(steps))
+	for _, step := range steps {
+		rendered[step.ID] = true
+		doc.Space(6)
+		doc.Text(fmt.Sprintf("Step %d: %s", step.StepNumber, step.Name), 14, true, 0)
+		if step.Description != "" {
+			doc.Text(step.Description, 9, false, 0)
+		}
+		for _, field := range fields {
+			if field.FormStepID == step.ID {
+				s.renderExportValue(doc, export, field, field.FieldName, export.data[field.FieldName], files, 0)
+			}
+		}
+	}
+
+	// Fields outside any step, as on single step forms
+	for _, field := range fields {
+		if !rendered[field.FormStepID] {
+			s.renderExportValue(doc, export, field, field.FieldName, export.data[field.FieldName], files, 0)
+		}
+	}
+
+	doc.Rule()
+	s.renderApprovalHistory(ctx, doc, export)
+
+	return doc.Bytes()
+}
+
+// renderExportValue writes the label and value of a field; key is where its uploads are found
+func (s *FormService) renderExportValue(doc *pdfDocument, export *submissionExport, field db.FormField, key string, value interface{}, files map[string][]db.FormSubmissionFile, indent float64) {
+	doc.Space(4)
+	doc.Text(localizedLabel(field.Label, export.locale, field.FieldName), 10, true, indent)
+
+	switch {
+	case IsUploadFieldType(field.FieldType):
+		uploads := files[key]
+		if len(uploads) == 0 {
+			doc.Text("-", 10, false, indent+12)
+		}
+		for _, file := range uploads {
+			doc.Text(fmt.Sprintf("%s (%s, %d bytes)", file.FileName, file.MimeType, file.FileSize), 10, false, indent+12)
+			s.renderThumbnail(doc, file, indent+12)
+		}
+
+	case IsGroupFieldType(field.FieldType):
+		items, _ := groupItems(value)
+		if len(items) == 0 {
+			doc.Text("-", 10, false, indent+12)
+			return
+		}
+		itemFields, err := s.groupItemFields(field)
+		if err != nil {
+			doc.Text(formatExportValue(field, value, export.locale), 10, false, indent+12)
+			return
+		}
+		for i, item := range items {
+			doc.Text(fmt.Sprintf("Item %d", i+1), 10, true, indent+12)
+			for _, itemField := range itemFields {
+				itemKey := GroupItemFieldName(field.FieldName, i, itemField.FieldName)
+				s.renderExportValue(doc, export, itemField, itemKey, item[itemField.FieldName], files, indent+24)
+			}
+		}
+
+	default:
+		doc.Text(formatExportValue(field, value, export.locale), 10, false, indent+12)
+	}
+}
+
+// renderThumbnail embeds a preview of an image upload. Uploads that can't be previewed are
+// still listed, and included in the archive.
+func (s *FormService) renderThumbnail(doc *pdfDocument, file db.FormSubmissionFile, indent float64) {
+	switch file.MimeType {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return
+	}
+
+	content, err := s.uploader.Download(file.Bucket, file.FilePath)
+	if err != nil {
+		doc.Text("(preview unavailable)", 9, false, indent)
+		return
+	}
+	defer content.Close()
+
+	thumbnail, width, height, err := readThumbnail(content, exportThumbnailMaxBytes, exportThumbnailPixels)
+	if err != nil {
+		doc.Text("(preview unavailable)", 9, false, indent)
+		return
+	}
+	doc.Image(thumbnail, width, height, exportThumbnailPoints, indent)
+}
+
+func (s *FormService) renderApprovalHistory(ctx context.Context, doc *pdfDocument, export *submissionExport) {
+	doc.Text("Approval history", 14, true, 0)
+
+	history := export.history
+	if history == nil || (len(history.Tasks) == 0 && len(history.Events) == 0) {
+		doc.Text("No approval activity", 10, false, 0)
+		return
+	}
+
+	for _, task := range history.Tasks {
+		line := fmt.Sprintf("Round %d, stage %q: %s (%d approval(s) required)", task.Round, task.StageName, task.Status, task.RequiredApprovals)
+		if task.CompletedAt.Valid {
+			line += ", completed " + formatExportTime(task.CompletedAt.Time)
+		}
+		doc.Text(line, 10, false, 0)
+	}
+
+	actors := make(map[uuid.UUID]string)
+	actorName := func(id uuid.NullUUID) string {
+		if !id.Valid {
+			return "system"
+		}
+		if name, ok := actors[id.UUID]; ok {
+			return name
+		}
+		name := id.UUID.String()
+		if user, err := s.store.GetUser(ctx, id.UUID); err == nil {
+			name = user.Email
+		}
+		actors[id.UUID] = name
+		return name
+	}
+
+	doc.Space(4)
+	for _, event := range history.Events {
+		doc.Text(fmt.Sprintf("%s  %s by %s", formatExportTime(event.CreatedAt), event.Action, actorName(event.ActorID)), 10, false, 0)
+		if event.Comment != "" {
+			doc.Text(event.Comment, 9, false, 12)
+		}
+
+		var comments map[string]string
+		if len(event.FieldComments) > 0 && json.Unmarshal(event.FieldComments, &comments) == nil {
+			names := make([]string, 0, len(comments))
+			for name := range comments {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				doc.Text(fmt.Sprintf("%s: %s", name, comments[name]), 9, false, 12)
+			}
+		}
+	}
+}
+
+// localizedLabel picks the label of a locale, falling back to DefaultExportLocale, any
+// translation and then fallback
+func localizedLabel(raw json.RawMessage, locale, fallback string) string {
+	if label := i18nText(raw).Text(locale); label != "" {
+		return label
+	}
+	return fallback
+}
+
+// formatExportValue formats a field value for the PDF, showing the labels of static options
+func formatExportValue(field db.FormField, value interface{}, locale string) string {
+	switch v := value.(type) {
+	case nil:
+		return "-"
+	case bool:
+		if v {
+			return "Yes"
+		}
+		return "No"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		if v == "" {
+			return "-"
+		}
+		return optionLabel(field, v, locale)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = formatExportValue(field, item, locale)
+		}
+		return strings.Join(parts, ", ")
+	case map[string]interface{}:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(encoded)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func optionLabel(field db.FormField, value, locale string) string {
+	if len(field.Options) == 0 {
+		return value
+	}
+	var options FieldOptions
+	if err := json.Unmarshal(field.Options, &options); err != nil {
+		return value
+	}
+	for _, option := range options.Static {
+		if option.Value == value {
+			labels, _ := json.Marshal(option.Label)
+			return localizedLabel(labels, locale, value)
+		}
+	}
+	return value
+}
+
+func formatExportTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format("2006-01-02 15:04:05 MST")
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/uploader"
+)
+
+// exportUploader serves downloads from memory
+type exportUploader struct {
+	files map[string][]byte
+}
+
+func (u *exportUploader) Upload(io.Reader, string, string) error { return nil }
+
+func (u *exportUploader) Info(string, string) (*uploader.FileInfo, error) { return nil, nil }
+
+func (u *exportUploader) Delete(string, string) error { return nil }
+
+func (u *exportUploader) GetTempURL(string, string) (string, error) { return "", nil }
+
+func (u *exportUploader) Download(_ string, path string) (io.ReadCloser, error) {
+	data, ok := u.files[path]
+	if !ok {
+		return nil, fmt.Errorf("%s not found", path)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func TestPDFDocument(t *testing.T) {
+	doc := newPDFDocument()
+	doc.Text("Know Your Business (résumé)", 18, true, 0)
+	for i := 0; i < 120; i++ {
+		doc.Text(fmt.Sprintf("Line %d of a submission that is long enough to need a second page", i), 10, false, 0)
+	}
+	require.Greater(t, len(doc.pages), 1)
+
+	pdf := doc.Bytes()
+	require.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")))
+	require.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+	require.Contains(t, string(pdf), `(Know Your Business \(r`+"\xe9"+`sum`+"\xe9"+`\))`)
+
+	// The xref table points at each object
+	xref := bytes.LastIndex(pdf, []byte("\nxref\n")) + 1
+	require.Contains(t, string(pdf[xref:]), fmt.Sprintf("startxref\n%d\n", xref))
+	entries := strings.Split(string(pdf[xref:]), "\n")[3:]
+	for id := 1; id <= 4; id++ {
+		var offset int
+		_, err := fmt.Sscanf(entries[id-1], "%010d", &offset)
+		require.NoError(t, err)
+		require.True(t, bytes.HasPrefix(pdf[offset:], []byte(fmt.Sprintf("%d 0 obj\n", id))))
+	}
+}
+
+func TestWrapPDFText(t *testing.T) {
+	require.Equal(t, []string{"one two", "three"}, wrapPDFText("one two three", 8))
+	require.Equal(t, []string{"abcd", "efgh", "ij"}, wrapPDFText("abcdefghij", 4))
+	require.Equal(t, []string{"a", "", "b"}, wrapPDFText("a\n\nb", 10))
+}
+
+func TestPDFThumbnail(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 1000, 500))
+	for y := 0; y < 500; y++ {
+		for x := 0; x < 1000; x++ {
+			src.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, src))
+
+	thumbnail, width, height, err := pdfThumbnail(buf.Bytes(), 320)
+	require.NoError(t, err)
+	require.Equal(t, 320, width)
+	require.Equal(t, 160, height)
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(thumbnail))
+	require.NoError(t, err)
+	require.Equal(t, "jpeg", format)
+	require.Equal(t, 320, config.Width)
+
+	_, _, _, err = pdfThumbnail([]byte("not an image"), 320)
+	require.Error(t, err)
+}
+
+func TestWriteSubmissionArchive(t *testing.T) {
+	passport := []byte("passport scan")
+	s := &FormService{uploader: &exportUploader{files: map[string][]byte{
+		"forms/passport.pdf": passport,
+	}}}
+
+	export := &submissionExport{
+		form: db.FormDefinition{ID: uuid.New(), Name: "KYB"},
+		submission: db.FormSubmission{
+			ID:          uuid.New(),
+			Status:      "submitted",
+			FormVersion: 2,
+		},
+		files: []db.FormSubmissionFile{{
+			ID:        uuid.New(),
+			FieldName: "owners[0].id_document",
+			FileName:  "../passport.pdf",
+			FilePath:  "forms/passport.pdf",
+			MimeType:  "application/pdf",
+		}},
+		exportedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	pdf := []byte("%PDF-1.4 test")
+
+	var archive bytes.Buffer
+	manifest, err := s.writeSubmissionArchive(&archive, export, pdf)
+	require.NoError(t, err)
+	require.Len(t, manifest.Entries, 2)
+
+	reader, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	require.NoError(t, err)
+	require.Len(t, reader.File, 3)
+
+	contents := make(map[string][]byte)
+	for _, file := range reader.File {
+		r, err := file.Open()
+		require.NoError(t, err)
+		contents[file.Name], err = io.ReadAll(r)
+		require.NoError(t, err)
+		r.Close()
+	}
+
+	var written ExportManifest
+	require.NoError(t, json.Unmarshal(contents[ExportArchiveManifest], &written))
+	require.Equal(t, export.submission.ID, written.SubmissionID)
+	require.Equal(t, int32(2), written.FormVersion)
+
+	// Uploads are stored under their field, with names that can't escape the archive
+	filePath := "files/owners_0_.id_document/" + export.files[0].ID.String() + "-.._passport.pdf"
+	require.Equal(t, ExportArchivePDF, written.Entries[0].Path)
+	require.Equal(t, filePath, written.Entries[1].Path)
+	require.Equal(t, passport, contents[filePath])
+
+	for _, entry := range written.Entries {
+		sum := sha256.Sum256(contents[entry.Path])
+		require.Equal(t, hex.EncodeToString(sum[:]), entry.SHA256, entry.Path)
+		require.Equal(t, int64(len(contents[entry.Path])), entry.Size)
+	}
+
+	// A missing upload fails the export rather than producing an incomplete copy
+	export.files[0].FilePath = "forms/missing.pdf"
+	_, err = s.writeSubmissionArchive(io.Discard, export, pdf)
+	require.Error(t, err)
+}
@@ -0,0 +1,794 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// Rich field types. Their values are normalized before they are validated and stored: dates as
+// 2006-01-02, datetimes as RFC 3339 in UTC, phone numbers as E.164 and choices as lists.
+const (
+	FieldTypeDate          = "date"
+	FieldTypeDateTime      = "datetime"
+	FieldTypePhone         = "phone"
+	FieldTypeAddress       = "address"
+	FieldTypeSignature     = "signature"
+	FieldTypeNationalID    = "national_id"
+	FieldTypeCheckboxGroup = "checkbox_group"
+	FieldTypeMultiSelect   = "multi_select"
+)
+
+const (
+	dateLayout           = "2006-01-02"
+	maxAddressPartLength = 200
+	signatureMaxSize     = 1 << 20
+)
+
+var (
+	// dateTimeLayouts are accepted for datetime fields; values without a zone are UTC
+	dateTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04"}
+	dateOffset      = regexp.MustCompile(`^([+-])(\d+)([dwmy])$`)
+
+	// AddressParts are the parts of an address value, in display order
+	AddressParts               = []string{"line1", "line2", "city", "state", "postal_code", "country"}
+	defaultAddressRequiredPart = []string{"line1", "city", "country"}
+	postalCodePatterns         = map[string]*regexp.Regexp{
+		"US": regexp.MustCompile(`^[0-9]{5}(-[0-9]{4})?$`),
+		"CA": regexp.MustCompile(`^[A-Z][0-9][A-Z] ?[0-9][A-Z][0-9]$`),
+		"GB": regexp.MustCompile(`^[A-Z]{1,2}[0-9][A-Z0-9]? ?[0-9][A-Z]{2}$`),
+		"NG": regexp.MustCompile(`^[0-9]{6}$`),
+		"KE": regexp.MustCompile(`^[0-9]{5}$`),
+		"ZA": regexp.MustCompile(`^[0-9]{4}$`),
+	}
+
+	signatureMimeTypes = []string{"image/png", "image/jpeg"}
+)
+
+// IsUploadFieldType reports whether values of the type are uploaded files
+func IsUploadFieldType(fieldType string) bool {
+	return fieldType == "file" || fieldType == "files" || fieldType == FieldTypeSignature
+}
+
+// IsChoiceListFieldType reports whether values of the type are lists of options
+func IsChoiceListFieldType(fieldType string) bool {
+	return fieldType == FieldTypeCheckboxGroup || fieldType == FieldTypeMultiSelect
+}
+
+// applySignatureFileDefaults limits signatures to a single PNG or JPEG image of up to 1MB,
+// unless the field's file config says otherwise
+func applySignatureFileDefaults(fieldType string, allowedTypes *[]string, maxFiles *int, maxSize *int64) {
+	if fieldType != FieldTypeSignature {
+		return
+	}
+	if len(*allowedTypes) == 0 {
+		*allowedTypes = signatureMimeTypes
+	}
+	if *maxFiles == 0 {
+		*maxFiles = 1
+	}
+	if *maxSize == 0 {
+		*maxSize = signatureMaxSize
+	}
+}
+
+// FieldTypeInfo describes a rich field type to form renderers
+type FieldTypeInfo struct {
+	Type  string `json:"type"`
+	Input string `json:"input"` // the widget to render
+	Value string `json:"value"` // shape of the submitted value: string, list, object or file
+	// Format of string values, after normalization
+	Format string `json:"format,omitempty"`
+	// Rules are the validation rules the type supports
+	Rules []string `json:"rules,omitempty"`
+}
+
+// FieldTypeCatalog is what renderers need to know about rich field types: the types and the
+// country specific phone plans and identity numbers they validate against
+type FieldTypeCatalog struct {
+	Types        []FieldTypeInfo                      `json:"types"`
+	AddressParts []string                             `json:"address_parts"`
+	PhonePlans   map[string]validator.PhoneNumberPlan `json:"phone_plans"`
+	NationalIDs  []validator.NationalIDFormat         `json:"national_ids"`
+}
+
+// FieldTypes lists the rich field types
+var FieldTypes = []FieldTypeInfo{
+	{Type: FieldTypeDate, Input: "date", Value: "string", Format: dateLayout, Rules: []string{"min_date", "max_date", "min_age", "max_age"}},
+	{Type: FieldTypeDateTime, Input: "datetime-local", Value: "string", Format: time.RFC3339, Rules: []string{"min_date", "max_date"}},
+	{Type: FieldTypePhone, Input: "tel", Value: "string", Format: "E.164", Rules: []string{"country", "countries"}},
+	{Type: FieldTypeAddress, Input: "address", Value: "object", Rules: []string{"required_parts", "countries"}},
+	{Type: FieldTypeSignature, Input: "signature", Value: "file"},
+	{Type: FieldTypeNationalID, Input: "national_id", Value: "string", Rules: []string{"country", "countries", "id_types"}},
+	{Type: FieldTypeCheckboxGroup, Input: "checkbox_group", Value: "list", Rules: []string{"min_items", "max_items", "all_required"}},
+	{Type: FieldTypeMultiSelect, Input: "multi_select", Value: "list", Rules: []string{"min_items", "max_items"}},
+}
+
+// GetFieldTypeCatalog returns the rich field types with their country data
+func GetFieldTypeCatalog() FieldTypeCatalog {
+	return FieldTypeCatalog{
+		Types:        FieldTypes,
+		AddressParts: AddressParts,
+		PhonePlans:   validator.PhoneNumberPlans,
+		NationalIDs:  validator.NationalIDFormats,
+	}
+}
+
+func fieldTypeInfo(fieldType string) (FieldTypeInfo, bool) {
+	for _, info := range FieldTypes {
+		if info.Type == fieldType {
+			return info, true
+		}
+	}
+	return FieldTypeInfo{}, false
+}
+
+// FieldMetadata is how a renderer should present a rich field: its widget with the rules of
+// the field resolved, e.g. date bounds as dates and the phone plan of its country
+type FieldMetadata struct {
+	Input         string                       `json:"input"`
+	Format        string                       `json:"format,omitempty"`
+	Min           string                       `json:"min,omitempty"`
+	Max           string                       `json:"max,omitempty"`
+	Country       string                       `json:"country,omitempty"`
+	Countries     []string                     `json:"countries,omitempty"`
+	PhonePlan     *validator.PhoneNumberPlan   `json:"phone_plan,omitempty"`
+	IDFormats     []validator.NationalIDFormat `json:"id_formats,omitempty"`
+	Parts         []string                     `json:"parts,omitempty"`
+	RequiredParts []string                     `json:"required_parts,omitempty"`
+	MinItems      *int                         `json:"min_items,omitempty"`
+	MaxItems      *int                         `json:"max_items,omitempty"`
+	AllRequired   bool                         `json:"all_required,omitempty"`
+	Accept        []string                     `json:"accept,omitempty"`
+	MaxFiles      int                          `json:"max_files,omitempty"`
+}
+
+// fieldMetadata returns the metadata of the rich fields, keyed by field name
+func (s *FormService) fieldMetadata(fields []db.FormField, now time.Time) map[string]FieldMetadata {
+	meta := make(map[string]FieldMetadata)
+	for _, field := range fields {
+		info, ok := fieldTypeInfo(field.FieldType)
+		if !ok {
+			continue
+		}
+
+		var rules ValidationRules
+		if field.ValidationRules != nil {
+			_ = json.Unmarshal(field.ValidationRules, &rules)
+		}
+
+		m := FieldMetadata{Input: info.Input, Format: info.Format}
+		switch field.FieldType {
+		case FieldTypeDate, FieldTypeDateTime:
+			min, max := dateBounds(field.FieldType, rules, now)
+			layout := dateLayout
+			if field.FieldType == FieldTypeDateTime {
+				layout = time.RFC3339
+			}
+			if !min.IsZero() {
+				m.Min = min.Format(layout)
+			}
+			if !max.IsZero() {
+				m.Max = max.Format(layout)
+			}
+
+		case FieldTypePhone:
+			m.Country, m.Countries = strings.ToUpper(rules.Country), rules.Countries
+			if plan, ok := validator.PhoneNumberPlans[m.Country]; ok {
+				m.PhonePlan = &plan
+			}
+
+		case FieldTypeNationalID:
+			m.Country, m.Countries = strings.ToUpper(rules.Country), rules.Countries
+			countries := rules.Countries
+			if len(countries) == 0 && rules.Country != "" {
+				countries = []string{rules.Country}
+			}
+			for _, country := range countries {
+				m.IDFormats = append(m.IDFormats, validator.NationalIDFormatsFor(country, rules.IDTypes...)...)
+			}
+
+		case FieldTypeAddress:
+			m.Countries = rules.Countries
+			m.Parts = AddressParts
+			m.RequiredParts = addressRequiredParts(rules)
+
+		case FieldTypeCheckboxGroup, FieldTypeMultiSelect:
+			m.MinItems, m.MaxItems, m.AllRequired = rules.MinItems, rules.MaxItems, rules.AllRequired
+
+		case FieldTypeSignature:
+			var config FileConfig
+			if field.FileConfig != nil {
+				_ = json.Unmarshal(field.FileConfig, &config)
+			}
+			applySignatureFileDefaults(field.FieldType, &config.AllowedTypes, &config.MaxFiles, &config.MaxSize)
+			m.Accept, m.MaxFiles = config.AllowedTypes, config.MaxFiles
+		}
+		meta[field.FieldName] = m
+	}
+	return meta
+}
+
+// normalizeFieldValues puts the values of rich fields, including those of group items, in their
+// canonical form. Values that can't be normalized are left for validation to reject.
+func (s *FormService) normalizeFieldValues(fields []db.FormField, data map[string]interface{}) map[string]interface{} {
+	if data == nil {
+		return data
+	}
+
+	for _, field := range fields {
+		if field.FieldType == FieldTypeAddress {
+			foldAddressParts(field.FieldName, data)
+		}
+
+		value, exists := data[field.FieldName]
+		if !exists || value == nil {
+			continue
+		}
+
+		if IsGroupFieldType(field.FieldType) {
+			items, ok := groupItems(value)
+			if !ok {
+				continue
+			}
+			itemFields, err := s.groupItemFields(field)
+			if err != nil {
+				continue
+			}
+			for _, item := range items {
+				s.normalizeFieldValues(itemFields, item)
+			}
+			continue
+		}
+
+		data[field.FieldName] = normalizeFieldValue(field, value)
+	}
+	return data
+}
+
+func normalizeFieldValue(field db.FormField, value interface{}) interface{} {
+	var rules ValidationRules
+	if field.ValidationRules != nil {
+		_ = json.Unmarshal(field.ValidationRules, &rules)
+	}
+
+	// Multipart values that look like numbers are parsed as numbers by the handlers
+	if field.FieldType == FieldTypePhone || field.FieldType == FieldTypeNationalID {
+		switch num := value.(type) {
+		case float64:
+			value = strconv.FormatFloat(num, 'f', -1, 64)
+		case int64:
+			value = strconv.FormatInt(num, 10)
+		}
+	}
+
+	switch field.FieldType {
+	case FieldTypeDate:
+		if str, ok := value.(string); ok {
+			if t, err := time.Parse(dateLayout, strings.TrimSpace(str)); err == nil {
+				return t.Format(dateLayout)
+			}
+		}
+
+	case FieldTypeDateTime:
+		if str, ok := value.(string); ok {
+			if t, err := parseDateTime(str); err == nil {
+				return t.UTC().Format(time.RFC3339)
+			}
+		}
+
+	case FieldTypePhone:
+		number, country := phoneValue(value, rules)
+		if normalized, err := validator.NormalizePhoneNumber(number, country); err == nil {
+			return normalized
+		}
+
+	case FieldTypeNationalID:
+		switch v := value.(type) {
+		case string:
+			return validator.NormalizeNationalID(v)
+		case map[string]interface{}:
+			id := make(map[string]interface{}, len(v))
+			for key, part := range v {
+				id[key] = part
+			}
+			if number, ok := v["number"].(string); ok {
+				id["number"] = validator.NormalizeNationalID(number)
+			}
+			if country, ok := v["country"].(string); ok {
+				id["country"] = strings.ToUpper(strings.TrimSpace(country))
+			}
+			return id
+		}
+
+	case FieldTypeAddress:
+		address, ok := addressValue(value)
+		if !ok {
+			return value
+		}
+		for part, partValue := range address {
+			if str, ok := partValue.(string); ok {
+				str = strings.TrimSpace(str)
+				if part == "country" || part == "postal_code" {
+					str = strings.ToUpper(str)
+				}
+				address[part] = str
+			}
+		}
+		return address
+
+	case FieldTypeCheckboxGroup, FieldTypeMultiSelect:
+		if choices, ok := choiceValues(value); ok {
+			return choices
+		}
+	}
+
+	return value
+}
+
+// phoneValue returns the number of a phone value and the country it is national to. Values are
+// either the number or an object with the number and the country picked with it.
+func phoneValue(value interface{}, rules ValidationRules) (string, string) {
+	switch v := value.(type) {
+	case string:
+		return v, rules.Country
+	case map[string]interface{}:
+		number, _ := v["number"].(string)
+		country, _ := v["country"].(string)
+		if country == "" {
+			country = rules.Country
+		}
+		return number, country
+	}
+	return "", rules.Country
+}
+
+// foldAddressParts moves address parts posted as flat keys, e.g. address.city from a multipart
+// form, into the address value
+func foldAddressParts(name string, data map[string]interface{}) {
+	prefix := name + "."
+	var address map[string]interface{}
+	for key, value := range data {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if address == nil {
+			address, _ = addressValue(data[name])
+			if address == nil {
+				address = make(map[string]interface{})
+			}
+		}
+		address[strings.TrimPrefix(key, prefix)] = value
+		delete(data, key)
+	}
+	if address != nil {
+		data[name] = address
+	}
+}
+
+// addressValue returns the parts of an address value, which may be posted as a JSON string
+func addressValue(value interface{}) (map[string]interface{}, bool) {
+	if str, ok := value.(string); ok {
+		var address map[string]interface{}
+		if err := json.Unmarshal([]byte(str), &address); err != nil {
+			return nil, false
+		}
+		return address, true
+	}
+	address, ok := value.(map[string]interface{})
+	return address, ok
+}
+
+// choiceValues returns the options chosen in a list value. Lists may be posted as repeated form
+// values, a JSON array or a single value.
+func choiceValues(value interface{}) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, true
+	case []string:
+		choices := make([]interface{}, len(v))
+		for i, choice := range v {
+			choices[i] = choice
+		}
+		return choices, true
+	case string:
+		if strings.HasPrefix(strings.TrimSpace(v), "[") {
+			var choices []interface{}
+			if err := json.Unmarshal([]byte(v), &choices); err != nil {
+				return nil, false
+			}
+			return choices, true
+		}
+		if v == "" {
+			return []interface{}{}, true
+		}
+		return []interface{}{v}, true
+	}
+	return nil, false
+}
+
+func parseDateTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date and time", value)
+}
+
+// resolveDateBound returns the time of a min_date or max_date rule. Offsets are from the start of
+// today for dates and from now for datetimes.
+func resolveDateBound(bound string, fieldType string, now time.Time) (time.Time, error) {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	base := today
+	if fieldType == FieldTypeDateTime {
+		base = now
+	}
+
+	bound = strings.TrimSpace(bound)
+	switch bound {
+	case "today":
+		return today, nil
+	case "now":
+		return base, nil
+	}
+
+	if match := dateOffset.FindStringSubmatch(bound); match != nil {
+		n, err := strconv.Atoi(match[2])
+		if err != nil {
+			return time.Time{}, err
+		}
+		if match[1] == "-" {
+			n = -n
+		}
+		switch match[3] {
+		case "d":
+			return base.AddDate(0, 0, n), nil
+		case "w":
+			return base.AddDate(0, 0, 7*n), nil
+		case "m":
+			return base.AddDate(0, n, 0), nil
+		default:
+			return base.AddDate(n, 0, 0), nil
+		}
+	}
+
+	if t, err := time.Parse(dateLayout, bound); err == nil {
+		return t, nil
+	}
+	if t, err := parseDateTime(bound); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date, today, now or an offset like -18y", bound)
+}
+
+// ageBounds returns the earliest and latest dates of birth the age rules accept, zero when
+// unbounded. At least 18 years old means born on or before 18 years ago today.
+func ageBounds(rules ValidationRules, now time.Time) (earliest, latest time.Time) {
+	today, _ := resolveDateBound("today", FieldTypeDate, now)
+	if rules.MinAge != nil {
+		latest = today.AddDate(-*rules.MinAge, 0, 0)
+	}
+	if rules.MaxAge != nil {
+		earliest = today.AddDate(-*rules.MaxAge-1, 0, 1)
+	}
+	return earliest, latest
+}
+
+// dateBounds returns the earliest and latest dates a field accepts, zero when unbounded,
+// combining min_date and max_date with the age rules of date fields
+func dateBounds(fieldType string, rules ValidationRules, now time.Time) (min, max time.Time) {
+	if rules.MinDate != nil {
+		min, _ = resolveDateBound(*rules.MinDate, fieldType, now)
+	}
+	if rules.MaxDate != nil {
+		max, _ = resolveDateBound(*rules.MaxDate, fieldType, now)
+	}
+	if fieldType != FieldTypeDate {
+		return min, max
+	}
+
+	earliest, latest := ageBounds(rules, now)
+	if !earliest.IsZero() && (min.IsZero() || earliest.After(min)) {
+		min = earliest
+	}
+	if !latest.IsZero() && (max.IsZero() || latest.Before(max)) {
+		max = latest
+	}
+	return min, max
+}
+
+// validateDate checks a date or datetime against its bounds and age rules
+func validateDate(v *validator.Validator, field db.FormField, rules ValidationRules, value interface{}, now time.Time) {
+	str, _ := value.(string)
+
+	var t time.Time
+	var err error
+	layout := dateLayout
+	if field.FieldType == FieldTypeDateTime {
+		layout = time.RFC3339
+		t, err = parseDateTime(str)
+	} else {
+		t, err = time.Parse(dateLayout, str)
+	}
+	if err != nil {
+		v.AddMessage(field.FieldName, validator.Msg("must be a date like %s", time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC).Format(layout)))
+		return
+	}
+
+	if rules.MinDate != nil {
+		min, _ := resolveDateBound(*rules.MinDate, field.FieldType, now)
+		v.CheckMessage(!t.Before(min), field.FieldName, validator.Msg("must be on or after %s", min.Format(layout)))
+	}
+	if rules.MaxDate != nil {
+		max, _ := resolveDateBound(*rules.MaxDate, field.FieldType, now)
+		v.CheckMessage(!t.After(max), field.FieldName, validator.Msg("must be on or before %s", max.Format(layout)))
+	}
+
+	if field.FieldType != FieldTypeDate {
+		return
+	}
+	earliest, latest := ageBounds(rules, now)
+	if rules.MinAge != nil {
+		v.CheckMessage(!t.After(latest), field.FieldName, validator.Msg("must be at least %d years old", *rules.MinAge))
+	}
+	if rules.MaxAge != nil {
+		v.CheckMessage(!t.Before(earliest), field.FieldName, validator.Msg("must be at most %d years old", *rules.MaxAge))
+	}
+}
+
+// validatePhone checks that a phone number was normalized to E.164 and comes from an accepted country
+func validatePhone(v *validator.Validator, field db.FormField, rules ValidationRules, value interface{}) {
+	number, country := phoneValue(value, rules)
+	normalized, err := validator.NormalizePhoneNumber(number, country)
+	if err != nil {
+		v.AddError(field.FieldName, "must be a valid phone number")
+		return
+	}
+
+	if len(rules.Countries) > 0 {
+		accepted := false
+		for _, from := range validator.PhoneNumberCountries(normalized) {
+			accepted = accepted || validator.In(from, rules.Countries...)
+		}
+		v.CheckMessage(accepted, field.FieldName, validator.Msg("must be a phone number from %s", strings.Join(rules.Countries, ", ")))
+	}
+}
+
+// validateNationalID checks an identity number against the formats of its country. Values are
+// either the number, of the field's country, or an object with the country, type and number.
+func validateNationalID(v *validator.Validator, field db.FormField, rules ValidationRules, value interface{}) {
+	country, idType, number := rules.Country, "", ""
+	switch id := value.(type) {
+	case string:
+		number = id
+	case map[string]interface{}:
+		number, _ = id["number"].(string)
+		idType, _ = id["type"].(string)
+		if c, ok := id["country"].(string); ok && c != "" {
+			country = c
+		}
+	default:
+		v.AddError(field.FieldName, "must be an identity number")
+		return
+	}
+
+	country = strings.ToUpper(country)
+	if len(rules.Countries) > 0 && !validator.In(country, rules.Countries...) {
+		v.AddMessage(field.FieldName, validator.Msg("must be an identity number from %s", strings.Join(rules.Countries, ", ")))
+		return
+	}
+
+	types := rules.IDTypes
+	if idType != "" {
+		if len(types) > 0 && !validator.In(idType, types...) {
+			v.AddMessage(field.FieldName, validator.Msg("%s is not accepted", idType))
+			return
+		}
+		types = []string{idType}
+	}
+
+	formats := validator.NationalIDFormatsFor(country, types...)
+	if len(formats) == 0 {
+		v.AddMessage(field.FieldName, validator.Msg("identity numbers from %q are not supported", country))
+		return
+	}
+
+	names := make([]string, len(formats))
+	for i, format := range formats {
+		if format.Matches(number) {
+			return
+		}
+		names[i] = format.Name
+	}
+	v.AddMessage(field.FieldName, validator.Msg("must be a valid %s", strings.Join(names, " or ")))
+}
+
+func addressRequiredParts(rules ValidationRules) []string {
+	if len(rules.RequiredParts) > 0 {
+		return rules.RequiredParts
+	}
+	return defaultAddressRequiredPart
+}
+
+// validateAddress checks the parts of an address. Errors of a part are keyed like address.city.
+func (s *FormService) validateAddress(v *validator.Validator, field db.FormField, rules ValidationRules, value interface{}) {
+	address, ok := addressValue(value)
+	if !ok {
+		v.AddError(field.FieldName, "must be an address")
+		return
+	}
+
+	parts := make([]string, 0, len(address))
+	for part := range address {
+		parts = append(parts, part)
+	}
+	sort.Strings(parts)
+
+	for _, part := range parts {
+		key := field.FieldName + "." + part
+		if !validator.In(part, AddressParts...) {
+			v.AddError(key, "unknown address part")
+			continue
+		}
+		str, ok := address[part].(string)
+		if !ok && address[part] != nil {
+			v.AddError(key, "must be text")
+			continue
+		}
+		v.CheckMessage(len(str) <= maxAddressPartLength, key, validator.Msg("must be at most %d characters", maxAddressPartLength))
+	}
+
+	for _, part := range addressRequiredParts(rules) {
+		if s.isEmpty(address[part]) {
+			v.AddError(field.FieldName+"."+part, "field is required")
+		}
+	}
+
+	country, _ := address["country"].(string)
+	if country == "" {
+		return
+	}
+	if !validator.IsCountryCode(country) {
+		v.AddError(field.FieldName+".country", "must be a country code like NG")
+		return
+	}
+	if len(rules.Countries) > 0 && !validator.In(country, rules.Countries...) {
+		v.AddMessage(field.FieldName+".country", validator.Msg("must be one of %s", strings.Join(rules.Countries, ", ")))
+	}
+
+	postalCode, _ := address["postal_code"].(string)
+	if pattern, ok := postalCodePatterns[country]; ok && postalCode != "" {
+		v.Check(pattern.MatchString(postalCode), field.FieldName+".postal_code", "invalid postal code")
+	}
+}
+
+// validateChoices validates a choice list. Complete validation, for submissions and steps, also
+// enforces min_items and all_required.
+func (s *FormService) validateChoices(v *validator.Validator, field db.FormField, value interface{}, complete bool) error {
+	var rules ValidationRules
+	if field.ValidationRules != nil {
+		if err := json.Unmarshal(field.ValidationRules, &rules); err != nil {
+			return fmt.Errorf("failed to parse validation rules for field %s: %w", field.FieldName, err)
+		}
+	}
+	s.validateChoiceList(v, field, rules, value, complete)
+	return nil
+}
+
+func (s *FormService) validateChoiceList(v *validator.Validator, field db.FormField, rules ValidationRules, value interface{}, complete bool) {
+	choices, ok := choiceValues(value)
+	if !ok {
+		v.AddError(field.FieldName, "must be a list of options")
+		return
+	}
+
+	var options FieldOptions
+	if field.Options != nil {
+		_ = json.Unmarshal(field.Options, &options)
+	}
+	validOptions := make([]string, len(options.Static))
+	for i, opt := range options.Static {
+		validOptions[i] = opt.Value
+	}
+
+	chosen := make([]string, 0, len(choices))
+	for _, choice := range choices {
+		str, ok := choice.(string)
+		if !ok {
+			v.AddError(field.FieldName, "must be a list of options")
+			return
+		}
+		chosen = append(chosen, str)
+	}
+
+	if options.Type == "static" && len(validOptions) > 0 {
+		v.Check(validator.AllIn(chosen, validOptions...), field.FieldName, "invalid option")
+	}
+	v.Check(validator.NoDuplicates(chosen), field.FieldName, "options can only be chosen once")
+
+	if rules.MaxItems != nil {
+		v.CheckMessage(len(chosen) <= *rules.MaxItems, field.FieldName,
+			validator.Msg("must have at most %d options", *rules.MaxItems))
+	}
+	if !complete {
+		return
+	}
+	if rules.MinItems != nil {
+		v.CheckMessage(len(chosen) >= *rules.MinItems, field.FieldName,
+			validator.Msg("must have at least %d options", *rules.MinItems))
+	}
+	if rules.AllRequired && len(validOptions) > 0 {
+		v.Check(validator.AllIn(validOptions, chosen...), field.FieldName, "all options must be chosen")
+	}
+}
+
+// ValidateFieldTypeRules checks the validation rules of a rich field type when a form is defined
+func ValidateFieldTypeRules(fieldType string, rules ValidationRules) error {
+	for _, country := range append([]string{rules.Country}, rules.Countries...) {
+		if country != "" && !validator.IsCountryCode(country) {
+			return fmt.Errorf("%q is not a country code", country)
+		}
+	}
+
+	switch fieldType {
+	case FieldTypeDate, FieldTypeDateTime:
+		for _, bound := range []*string{rules.MinDate, rules.MaxDate} {
+			if bound == nil {
+				continue
+			}
+			if _, err := resolveDateBound(*bound, fieldType, time.Now()); err != nil {
+				return err
+			}
+		}
+		if fieldType == FieldTypeDateTime && (rules.MinAge != nil || rules.MaxAge != nil) {
+			return fmt.Errorf("age rules only apply to date fields")
+		}
+		if rules.MinAge != nil && rules.MaxAge != nil && *rules.MinAge > *rules.MaxAge {
+			return fmt.Errorf("min_age is greater than max_age")
+		}
+
+	case FieldTypePhone:
+		if rules.Country != "" {
+			if _, ok := validator.PhoneNumberPlans[strings.ToUpper(rules.Country)]; !ok {
+				return fmt.Errorf("phone numbers of %s are not supported", rules.Country)
+			}
+		}
+
+	case FieldTypeNationalID:
+		countries := rules.Countries
+		if rules.Country != "" {
+			countries = append(countries, rules.Country)
+		}
+		if len(countries) == 0 {
+			return fmt.Errorf("national_id fields need a country or countries")
+		}
+		for _, country := range countries {
+			if len(validator.NationalIDFormatsFor(country, rules.IDTypes...)) == 0 {
+				return fmt.Errorf("no identity numbers of %s match id_types", country)
+			}
+		}
+
+	case FieldTypeAddress:
+		for _, part := range rules.RequiredParts {
+			if !validator.In(part, AddressParts...) {
+				return fmt.Errorf("unknown address part %q", part)
+			}
+		}
+
+	case FieldTypeCheckboxGroup, FieldTypeMultiSelect:
+		if rules.MinItems != nil && rules.MaxItems != nil && *rules.MinItems > *rules.MaxItems {
+			return fmt.Errorf("min_items is greater than max_items")
+		}
+	}
+	return nil
+}
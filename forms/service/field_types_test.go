@@ -0,0 +1,196 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+func TestValidateDate(t *testing.T) {
+	now := time.Date(2026, 3, 15, 10, 30, 0, 0, time.UTC)
+	dob := db.FormField{FieldName: "dob", FieldType: FieldTypeDate}
+	adult := ValidationRules{MinAge: intPtr(18), MaxAge: intPtr(100)}
+
+	v := validator.New()
+	validateDate(v, dob, adult, "2008-03-15", now)
+	require.True(t, v.Valid(), "turns 18 today")
+
+	v = validator.New()
+	validateDate(v, dob, adult, "2008-03-16", now)
+	require.Equal(t, "must be at least 18 years old", v.Errors["dob"])
+
+	v = validator.New()
+	validateDate(v, dob, adult, "1925-03-15", now)
+	require.Equal(t, "must be at most 100 years old", v.Errors["dob"])
+
+	v = validator.New()
+	validateDate(v, dob, adult, "15/03/1990", now)
+	require.Equal(t, "must be a date like 2006-01-02", v.Errors["dob"])
+
+	// Bounds are dates or offsets from today
+	startsOn := db.FormField{FieldName: "starts_on", FieldType: FieldTypeDate}
+	v = validator.New()
+	validateDate(v, startsOn, ValidationRules{MinDate: strPtr("today"), MaxDate: strPtr("+30d")}, "2026-04-15", now)
+	require.Equal(t, "must be on or before 2026-04-14", v.Errors["starts_on"])
+
+	// Datetime offsets are from now
+	meeting := db.FormField{FieldName: "meeting", FieldType: FieldTypeDateTime}
+	v = validator.New()
+	validateDate(v, meeting, ValidationRules{MinDate: strPtr("now")}, "2026-03-15T10:00:00Z", now)
+	require.Equal(t, "must be on or after 2026-03-15T10:30:00Z", v.Errors["meeting"])
+
+	min, max := dateBounds(FieldTypeDate, ValidationRules{MaxDate: strPtr("2030-01-01"), MinAge: intPtr(18)}, now)
+	require.True(t, min.IsZero())
+	require.Equal(t, "2008-03-15", max.Format(dateLayout))
+}
+
+func TestNormalizeFieldValues(t *testing.T) {
+	s := &FormService{}
+	fields := []db.FormField{
+		{FieldName: "dob", FieldType: FieldTypeDate},
+		{FieldName: "meeting", FieldType: FieldTypeDateTime},
+		{FieldName: "phone", FieldType: FieldTypePhone, ValidationRules: json.RawMessage(`{"country":"NG"}`)},
+		{FieldName: "nin", FieldType: FieldTypeNationalID},
+		{FieldName: "address", FieldType: FieldTypeAddress},
+		{FieldName: "services", FieldType: FieldTypeCheckboxGroup},
+	}
+
+	data := s.normalizeFieldValues(fields, map[string]interface{}{
+		"dob":             " 1990-05-01 ",
+		"meeting":         "2026-03-15T11:00:00+01:00",
+		"phone":           "0803 123 4567",
+		"nin":             "123-456-789 01",
+		"address.city":    " Lagos ",
+		"address.country": "ng",
+		"services":        "payments",
+	})
+
+	require.Equal(t, map[string]interface{}{
+		"dob":      "1990-05-01",
+		"meeting":  "2026-03-15T10:00:00Z",
+		"phone":    "+2348031234567",
+		"nin":      "12345678901",
+		"address":  map[string]interface{}{"city": "Lagos", "country": "NG"},
+		"services": []interface{}{"payments"},
+	}, data)
+
+	// Phone numbers may carry the country picked with them
+	data = s.normalizeFieldValues(fields, map[string]interface{}{
+		"phone": map[string]interface{}{"country": "KE", "number": "0712 345678"},
+	})
+	require.Equal(t, "+254712345678", data["phone"])
+
+	// Numbers parsed from multipart forms lose their trunk 0, which phone numbers don't need
+	data = s.normalizeFieldValues(fields, map[string]interface{}{"phone": float64(8031234567), "nin": float64(12345678901)})
+	require.Equal(t, "+2348031234567", data["phone"])
+	require.Equal(t, "12345678901", data["nin"])
+	data = s.normalizeFieldValues(fields, map[string]interface{}{"phone": int64(8031234567)})
+	require.Equal(t, "+2348031234567", data["phone"])
+}
+
+func TestValidateRichFields(t *testing.T) {
+	s := &FormService{}
+
+	phone := db.FormField{FieldName: "phone", FieldType: FieldTypePhone, ValidationRules: json.RawMessage(`{"countries":["NG","GH"]}`)}
+	v := validator.New()
+	require.NoError(t, s.validateFieldValue(v, phone, "+254712345678"))
+	require.Equal(t, "must be a phone number from NG, GH", v.Errors["phone"])
+	v = validator.New()
+	require.NoError(t, s.validateFieldValue(v, phone, "+2348031234567"))
+	require.True(t, v.Valid())
+
+	nationalID := db.FormField{FieldName: "id_number", FieldType: FieldTypeNationalID, ValidationRules: json.RawMessage(`{"country":"NG","id_types":["nin","bvn"]}`)}
+	v = validator.New()
+	require.NoError(t, s.validateFieldValue(v, nationalID, "22123456789"))
+	require.True(t, v.Valid())
+	v = validator.New()
+	require.NoError(t, s.validateFieldValue(v, nationalID, "1234"))
+	require.Equal(t, "must be a valid National Identification Number or Bank Verification Number", v.Errors["id_number"])
+	v = validator.New()
+	require.NoError(t, s.validateFieldValue(v, nationalID, map[string]interface{}{"country": "NG", "type": "ssn", "number": "123456789"}))
+	require.Equal(t, "ssn is not accepted", v.Errors["id_number"])
+
+	address := db.FormField{FieldName: "address", FieldType: FieldTypeAddress, ValidationRules: json.RawMessage(`{"countries":["US","NG"]}`)}
+	v = validator.New()
+	require.NoError(t, s.validateFieldValue(v, address, map[string]interface{}{
+		"line1": "1 Main St", "country": "US", "postal_code": "ABC", "suburb": "x",
+	}))
+	require.Equal(t, "field is required", v.Errors["address.city"])
+	require.Equal(t, "invalid postal code", v.Errors["address.postal_code"])
+	require.Equal(t, "unknown address part", v.Errors["address.suburb"])
+	v = validator.New()
+	require.NoError(t, s.validateFieldValue(v, address, map[string]interface{}{"line1": "1 Marina", "city": "Nairobi", "country": "KE"}))
+	require.Equal(t, "must be one of US, NG", v.Errors["address.country"])
+}
+
+func TestValidateChoices(t *testing.T) {
+	s := &FormService{}
+	services := db.FormField{
+		FieldName:       "services",
+		FieldType:       FieldTypeCheckboxGroup,
+		ValidationRules: json.RawMessage(`{"min_items":2,"max_items":3}`),
+		Options:         json.RawMessage(`{"type":"static","static":[{"value":"payments"},{"value":"cards"},{"value":"fx"},{"value":"lending"}]}`),
+	}
+
+	// Drafts may have fewer than min_items
+	v := validator.New()
+	require.NoError(t, s.validateFieldValue(v, services, []interface{}{"payments"}))
+	require.True(t, v.Valid())
+
+	v = validator.New()
+	require.NoError(t, s.validateChoices(v, services, []interface{}{"payments"}, true))
+	require.Equal(t, "must have at least 2 options", v.Errors["services"])
+
+	v = validator.New()
+	require.NoError(t, s.validateFieldValue(v, services, []interface{}{"payments", "cards", "fx", "lending"}))
+	require.Equal(t, "must have at most 3 options", v.Errors["services"])
+
+	v = validator.New()
+	require.NoError(t, s.validateFieldValue(v, services, []interface{}{"payments", "crypto"}))
+	require.Equal(t, "invalid option", v.Errors["services"])
+
+	terms := db.FormField{
+		FieldName:       "terms",
+		FieldType:       FieldTypeCheckboxGroup,
+		ValidationRules: json.RawMessage(`{"all_required":true}`),
+		Options:         json.RawMessage(`{"type":"static","static":[{"value":"privacy"},{"value":"terms"}]}`),
+	}
+	v = validator.New()
+	require.NoError(t, s.validateChoices(v, terms, []interface{}{"terms"}, true))
+	require.Equal(t, "all options must be chosen", v.Errors["terms"])
+}
+
+func TestValidateFieldTypeRules(t *testing.T) {
+	require.NoError(t, ValidateFieldTypeRules(FieldTypeDate, ValidationRules{MinDate: strPtr("-100y"), MaxDate: strPtr("today"), MinAge: intPtr(18)}))
+	require.Error(t, ValidateFieldTypeRules(FieldTypeDate, ValidationRules{MinDate: strPtr("yesterday")}))
+	require.Error(t, ValidateFieldTypeRules(FieldTypeDateTime, ValidationRules{MinAge: intPtr(18)}))
+	require.Error(t, ValidateFieldTypeRules(FieldTypeNationalID, ValidationRules{Country: "NG", IDTypes: []string{"ssn"}}))
+	require.Error(t, ValidateFieldTypeRules(FieldTypeAddress, ValidationRules{RequiredParts: []string{"street"}}))
+	require.Error(t, ValidateFieldTypeRules(FieldTypePhone, ValidationRules{Countries: []string{"Nigeria"}}))
+}
+
+func TestFieldMetadata(t *testing.T) {
+	s := &FormService{}
+	now := time.Date(2026, 3, 15, 10, 30, 0, 0, time.UTC)
+
+	meta := s.fieldMetadata([]db.FormField{
+		{FieldName: "business_name", FieldType: "text"},
+		{FieldName: "dob", FieldType: FieldTypeDate, ValidationRules: json.RawMessage(`{"min_age":18}`)},
+		{FieldName: "phone", FieldType: FieldTypePhone, ValidationRules: json.RawMessage(`{"country":"ng"}`)},
+		{FieldName: "signature", FieldType: FieldTypeSignature},
+	}, now)
+
+	require.NotContains(t, meta, "business_name")
+	require.Equal(t, "2008-03-15", meta["dob"].Max)
+	require.Equal(t, "234", meta["phone"].PhonePlan.CallingCode)
+	require.Equal(t, []string{"image/png", "image/jpeg"}, meta["signature"].Accept)
+	require.Equal(t, 1, meta["signature"].MaxFiles)
+}
+
+func intPtr(n int) *int { return &n }
+
+func strPtr(s string) *string { return &s }
@@ -32,6 +32,9 @@ type FileValidationContext struct {
 	StepNumber     *int32
 	AllowedFields  map[string]bool // Which file fields are allowed in this context
 	RequiredFields map[string]bool // Which file fields are required in this context
+	HiddenFields   map[string]bool // File fields hidden by conditional logic, skipped entirely
+	GroupItems     map[string]int  // Items submitted per group field, whose file fields are required per item
+	Scans          FileScans       // Collects the virus scan verdicts, when set
 }
 
 // FileValidationConfig represents file validation rules from form field config
@@ -73,7 +76,7 @@ type FileValidator struct {
 
 // VirusScanner interface for virus scanning implementations
 type VirusScanner interface {
-	ScanFile(file multipart.File) (bool, error)
+	ScanFile(file multipart.File) (ScanResult, error)
 }
 
 // ContentValidator interface for file content validation
@@ -101,7 +104,16 @@ func (s *FormService) ValidateFiles(
 
 	// Validate each file field
 	for _, field := range fields {
-		if field.FieldType != "file" && field.FieldType != "files" {
+		if ctx.HiddenFields[field.FieldName] {
+			continue
+		}
+
+		if IsGroupFieldType(field.FieldType) {
+			s.validateGroupFiles(field, files, ctx, results)
+			continue
+		}
+
+		if !IsUploadFieldType(field.FieldType) {
 			continue
 		}
 
@@ -121,6 +133,7 @@ func (s *FormService) ValidateFiles(
 		}
 
 		// Apply default configuration if not specified
+		applySignatureFileDefaults(field.FieldType, &fileConfig.AllowedTypes, &fileConfig.MaxFiles, &fileConfig.MaxSize)
 		s.applyDefaultFileConfig(&fileConfig)
 
 		// Validate field-level requirements
@@ -263,20 +276,24 @@ func (s *FormService) validateIndividualFile(
 
 	// Virus scanning (only for final submissions or if specifically required)
 	if (ctx.Mode == FileValidationModeFinal || config.ScanForVirus) && s.fileValidator != nil && s.fileValidator.virusScanner != nil {
-		// Reset file pointer
-		if seeker, ok := file.(io.Seeker); ok {
-			seeker.Seek(0, io.SeekStart)
-		}
-
-		isClean, err := s.fileValidator.virusScanner.ScanFile(file)
+		scan, err := s.scanFile(file)
 		if err != nil {
-			result.Warnings = append(result.Warnings, fmt.Sprintf("virus scan failed: %v", err))
-		} else if !isClean {
+			result.Valid = false
+			result.Errors = append(result.Errors, "file could not be scanned for viruses")
+			return result
+		}
+		switch scan.Status {
+		case ScanStatusInfected:
 			result.Valid = false
 			result.Errors = append(result.Errors, "file failed virus scan")
 			return result
+		case ScanStatusFailed:
+			result.Warnings = append(result.Warnings, fmt.Sprintf("virus scan failed: %s", scan.Signature))
+		}
+		fileInfo.IsClean = scan.Status == ScanStatusClean
+		if ctx.Scans != nil {
+			ctx.Scans[fileHeader] = scan
 		}
-		fileInfo.IsClean = isClean
 	}
 
 	// Content validation (only for final submissions or if specifically required)
@@ -286,7 +303,7 @@ func (s *FormService) validateIndividualFile(
 			seeker.Seek(0, io.SeekStart)
 		}
 
-		isValid, err := s.fileValidator.virusScanner.ScanFile(file)
+		isValid, err := s.fileValidator.contentValidator.ValidateContent(file, mimeType)
 		if err != nil {
 			result.Warnings = append(result.Warnings, fmt.Sprintf("content validation failed: %v", err))
 		} else if !isValid {
@@ -318,11 +335,11 @@ func (s *FormService) isFileFieldRequired(field db.FormField, config FileValidat
 		}
 		return field.IsRequired
 
-	case FileValidationModeFinal:
-		// In final mode, use the field's required setting
-		return field.IsRequired
-
 	default:
+		// In final mode, use the field's required setting as adjusted by conditional logic
+		if ctx.RequiredFields != nil {
+			return ctx.RequiredFields[field.FieldName]
+		}
 		return field.IsRequired
 	}
 }
@@ -399,6 +416,10 @@ func (s *FormService) ValidateSubmissionWithFiles(
 	ctx ValidationContext,
 ) error {
 
+	if ctx.FieldStates == nil {
+		ctx.FieldStates = s.ResolveFieldStates(fields, data)
+	}
+
 	// First validate regular fields
 	if err := s.ValidateSubmission(fields, data, ctx); err != nil {
 		return err
@@ -406,8 +427,19 @@ func (s *FormService) ValidateSubmissionWithFiles(
 
 	// Then validate files
 	fileCtx := FileValidationContext{
-		Mode:       FileValidationMode(ctx.Mode), // Convert validation mode
-		StepNumber: ctx.StepNumber,
+		Mode:           FileValidationMode(ctx.Mode), // Convert validation mode
+		StepNumber:     ctx.StepNumber,
+		RequiredFields: make(map[string]bool),
+		HiddenFields:   s.hiddenFields(ctx.FieldStates),
+		GroupItems:     make(map[string]int),
+		Scans:          ctx.FileScans,
+	}
+	for _, field := range fields {
+		fileCtx.RequiredFields[field.FieldName] = s.fieldState(field, ctx.FieldStates).Required
+		if IsGroupFieldType(field.FieldType) {
+			items, _ := groupItems(data[field.FieldName])
+			fileCtx.GroupItems[field.FieldName] = len(items)
+		}
 	}
 
 	fileResults := s.ValidateFiles(fields, files, fileCtx)
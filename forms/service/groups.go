@@ -0,0 +1,313 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"regexp"
+	"strconv"
+
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// Group field types. The fields of each item are declared in options.fields and the value of
+// the group is a list of items, each an object of item field values.
+const (
+	FieldTypeGroup    = "group"
+	FieldTypeRepeater = "repeater"
+)
+
+// maxGroupItems caps the items of a group without max_items, so an index in a submission key
+// cannot allocate an arbitrarily long list
+const maxGroupItems = 100
+
+// groupItemKey matches the submission key of a field of a group item, e.g. owners[0].first_name
+var groupItemKey = regexp.MustCompile(`^([a-zA-Z0-9_]+)\[(\d+)\]\.([a-zA-Z0-9_]+)$`)
+
+// IsGroupFieldType reports whether fields of the type repeat a set of nested fields
+func IsGroupFieldType(fieldType string) bool {
+	return fieldType == FieldTypeGroup || fieldType == FieldTypeRepeater
+}
+
+// GroupItemFieldName is the key of a field of a group item in multipart submissions, uploaded
+// files and validation errors, e.g. owners[0].id_document
+func GroupItemFieldName(group string, index int, field string) string {
+	return fmt.Sprintf("%s[%d].%s", group, index, field)
+}
+
+func parseGroupItemFieldName(name string) (group string, index int, field string, ok bool) {
+	match := groupItemKey.FindStringSubmatch(name)
+	if match == nil {
+		return "", 0, "", false
+	}
+	index, err := strconv.Atoi(match[2])
+	if err != nil {
+		return "", 0, "", false
+	}
+	return match[1], index, match[3], true
+}
+
+// groupItemFields returns the fields of each item of a group field
+func (s *FormService) groupItemFields(field db.FormField) ([]db.FormField, error) {
+	var options FieldOptions
+	if len(field.Options) > 0 {
+		if err := json.Unmarshal(field.Options, &options); err != nil {
+			return nil, fmt.Errorf("failed to parse options for field %s: %w", field.FieldName, err)
+		}
+	}
+
+	fields := make([]db.FormField, len(options.Fields))
+	for i, input := range options.Fields {
+		item := db.FormField{
+			FormDefinitionID: field.FormDefinitionID,
+			FormStepID:       field.FormStepID,
+			FieldName:        input.FieldName,
+			FieldType:        input.FieldType,
+			DisplayOrder:     int32(input.DisplayOrder),
+			IsRequired:       input.IsRequired,
+			IsReadonly:       input.IsReadonly,
+			Version:          field.Version,
+		}
+		item.Label, _ = json.Marshal(input.Label)
+		if input.ValidationRules != nil {
+			item.ValidationRules, _ = json.Marshal(input.ValidationRules)
+		}
+		if input.Options != nil {
+			item.Options, _ = json.Marshal(input.Options)
+		}
+		if input.FileConfig != nil {
+			item.FileConfig, _ = json.Marshal(input.FileConfig)
+		}
+		if input.DefaultValue != nil {
+			item.DefaultValue = *input.DefaultValue
+		}
+		fields[i] = item
+	}
+	return fields, nil
+}
+
+// groupItems returns the items of a group value. It reports false if the value is not a list
+// of objects.
+func groupItems(value interface{}) ([]map[string]interface{}, bool) {
+	if str, ok := value.(string); ok {
+		if err := json.Unmarshal([]byte(str), &value); err != nil {
+			return nil, false
+		}
+	}
+
+	list, ok := value.([]interface{})
+	if !ok {
+		if items, ok := value.([]map[string]interface{}); ok {
+			return items, true
+		}
+		return nil, false
+	}
+
+	items := make([]map[string]interface{}, len(list))
+	for i, entry := range list {
+		if entry == nil {
+			items[i] = map[string]interface{}{}
+			continue
+		}
+		item, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		items[i] = item
+	}
+	return items, true
+}
+
+// nestGroupValues folds item values posted as flat keys, e.g. owners[0].first_name from a
+// multipart form, into the list of their group. Groups posted as a JSON string are decoded.
+func (s *FormService) nestGroupValues(fields []db.FormField, data map[string]interface{}) map[string]interface{} {
+	groups := make(map[string]bool)
+	for _, field := range fields {
+		if IsGroupFieldType(field.FieldType) {
+			groups[field.FieldName] = true
+		}
+	}
+	if len(groups) == 0 || data == nil {
+		return data
+	}
+
+	nested := make(map[string][]map[string]interface{})
+	for group := range groups {
+		if items, ok := groupItems(data[group]); ok {
+			nested[group] = items
+		}
+	}
+
+	for key, value := range data {
+		group, index, field, ok := parseGroupItemFieldName(key)
+		if !ok || !groups[group] || index >= maxGroupItems {
+			continue
+		}
+
+		items := nested[group]
+		for len(items) <= index {
+			items = append(items, map[string]interface{}{})
+		}
+		items[index][field] = value
+		nested[group] = items
+		delete(data, key)
+	}
+
+	for group, items := range nested {
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			list[i] = item
+		}
+		data[group] = list
+	}
+	return data
+}
+
+// validateGroup validates a group value. Complete validation, for submissions and steps, also
+// enforces min_items and the required fields of each item.
+func (s *FormService) validateGroup(v *validator.Validator, field db.FormField, value interface{}, complete bool) error {
+	var rules ValidationRules
+	if field.ValidationRules != nil {
+		if err := json.Unmarshal(field.ValidationRules, &rules); err != nil {
+			return fmt.Errorf("failed to parse validation rules for field %s: %w", field.FieldName, err)
+		}
+	}
+	return s.validateGroupItems(v, field, value, rules, complete)
+}
+
+func (s *FormService) validateGroupItems(v *validator.Validator, field db.FormField, value interface{}, rules ValidationRules, complete bool) error {
+	items, ok := groupItems(value)
+	if !ok {
+		v.AddError(field.FieldName, "must be a list of items")
+		return nil
+	}
+
+	if complete && rules.MinItems != nil {
+		v.CheckMessage(len(items) >= *rules.MinItems, field.FieldName,
+			validator.Msg("must have at least %d items", *rules.MinItems))
+	}
+	maxItems := maxGroupItems
+	if rules.MaxItems != nil && *rules.MaxItems < maxItems {
+		maxItems = *rules.MaxItems
+	}
+	if len(items) > maxItems {
+		v.AddMessage(field.FieldName, validator.Msg("must have at most %d items", maxItems))
+		return nil
+	}
+
+	itemFields, err := s.groupItemFields(field)
+	if err != nil {
+		return err
+	}
+
+	for i, item := range items {
+		for _, itemField := range itemFields {
+			// Uploads are validated with the other files of the submission
+			if IsUploadFieldType(itemField.FieldType) {
+				continue
+			}
+
+			key := GroupItemFieldName(field.FieldName, i, itemField.FieldName)
+			itemValue, exists := item[itemField.FieldName]
+
+			if complete && itemField.IsRequired && (!exists || s.isEmpty(itemValue)) {
+				v.AddError(key, "field is required")
+				continue
+			}
+			if !exists || itemValue == nil {
+				continue
+			}
+
+			itemField.FieldName = key
+			if err := s.validateFieldValue(v, itemField, itemValue); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// findUploadField finds the field of an upload key, which is either a field name or the key of
+// a file field of a group item
+func (s *FormService) findUploadField(fields []db.FormField, name string) *db.FormField {
+	if field := s.findField(fields, name); field != nil {
+		return field
+	}
+
+	group, _, fieldName, ok := parseGroupItemFieldName(name)
+	if !ok {
+		return nil
+	}
+	groupField := s.findField(fields, group)
+	if groupField == nil || !IsGroupFieldType(groupField.FieldType) {
+		return nil
+	}
+
+	itemFields, err := s.groupItemFields(*groupField)
+	if err != nil {
+		return nil
+	}
+	for _, itemField := range itemFields {
+		if itemField.FieldName == fieldName && IsUploadFieldType(itemField.FieldType) {
+			itemField.FieldName = name
+			return &itemField
+		}
+	}
+	return nil
+}
+
+// validateGroupFiles validates the uploads of the file fields of each group item. Item files
+// are required for the items counted in ctx.GroupItems.
+func (s *FormService) validateGroupFiles(
+	field db.FormField,
+	files map[string][]*multipart.FileHeader,
+	ctx FileValidationContext,
+	results map[string]FileValidationResult,
+) {
+	itemFields, err := s.groupItemFields(field)
+	if err != nil {
+		results[field.FieldName] = FileValidationResult{
+			Valid:  false,
+			Errors: []string{err.Error()},
+		}
+		return
+	}
+
+	items := ctx.GroupItems[field.FieldName]
+	for key := range files {
+		if group, index, _, ok := parseGroupItemFieldName(key); ok && group == field.FieldName && index >= items {
+			items = index + 1
+		}
+	}
+
+	for i := 0; i < items && i < maxGroupItems; i++ {
+		for _, itemField := range itemFields {
+			if !IsUploadFieldType(itemField.FieldType) {
+				continue
+			}
+
+			key := GroupItemFieldName(field.FieldName, i, itemField.FieldName)
+			itemField.FieldName = key
+
+			var fileConfig FileValidationConfig
+			if itemField.FileConfig != nil {
+				if err := json.Unmarshal(itemField.FileConfig, &fileConfig); err != nil {
+					results[key] = FileValidationResult{
+						Valid:  false,
+						Errors: []string{fmt.Sprintf("Invalid file configuration: %v", err)},
+					}
+					continue
+				}
+			}
+			applySignatureFileDefaults(itemField.FieldType, &fileConfig.AllowedTypes, &fileConfig.MaxFiles, &fileConfig.MaxSize)
+			s.applyDefaultFileConfig(&fileConfig)
+
+			itemCtx := ctx
+			itemCtx.RequiredFields = map[string]bool{
+				key: i < ctx.GroupItems[field.FieldName] && itemField.IsRequired,
+			}
+			results[key] = s.validateFileField(itemField, files[key], fileConfig, itemCtx)
+		}
+	}
+}
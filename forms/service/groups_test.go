@@ -0,0 +1,100 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+func ownersField() db.FormField {
+	return db.FormField{
+		FieldName:       "owners",
+		FieldType:       FieldTypeGroup,
+		IsRequired:      true,
+		ValidationRules: json.RawMessage(`{"min_items":1,"max_items":2}`),
+		Options: json.RawMessage(`{"type":"group","fields":[
+			{"field_name":"first_name","field_type":"text","is_required":true,"validation_rules":{"max_length":5}},
+			{"field_name":"email","field_type":"email"},
+			{"field_name":"id_document","field_type":"file","is_required":true}
+		]}`),
+	}
+}
+
+func TestNestGroupValues(t *testing.T) {
+	s := &FormService{}
+
+	data := s.nestGroupValues([]db.FormField{ownersField()}, map[string]interface{}{
+		"owners[1].first_name": "Ada",
+		"owners[0].first_name": "Jane",
+		"owners[0].email":      "jane@example.com",
+		"business_name":        "Acme",
+		"other[0].first_name":  "kept",
+	})
+
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"first_name": "Jane", "email": "jane@example.com"},
+		map[string]interface{}{"first_name": "Ada"},
+	}, data["owners"])
+	require.Equal(t, "Acme", data["business_name"])
+	require.Equal(t, "kept", data["other[0].first_name"])
+	require.NotContains(t, data, "owners[0].first_name")
+
+	// Groups posted as JSON are decoded
+	data = s.nestGroupValues([]db.FormField{ownersField()}, map[string]interface{}{
+		"owners": `[{"first_name":"Jane"}]`,
+	})
+	require.Equal(t, []interface{}{map[string]interface{}{"first_name": "Jane"}}, data["owners"])
+}
+
+func TestValidateGroup(t *testing.T) {
+	s := &FormService{}
+
+	v := validator.New()
+	require.NoError(t, s.validateGroup(v, ownersField(), []interface{}{
+		map[string]interface{}{"first_name": "Jane"},
+		map[string]interface{}{"first_name": "Adaeze", "email": "ada@example.com"},
+	}, true))
+	require.Equal(t, "must be at most 5 characters", v.Errors["owners[1].first_name"])
+	require.Len(t, v.Errors, 1)
+
+	// Required item fields and min_items are only enforced on complete validation
+	v = validator.New()
+	require.NoError(t, s.validateGroup(v, ownersField(), []interface{}{map[string]interface{}{}}, false))
+	require.True(t, v.Valid())
+
+	v = validator.New()
+	require.NoError(t, s.validateGroup(v, ownersField(), []interface{}{map[string]interface{}{}}, true))
+	require.Equal(t, "field is required", v.Errors["owners[0].first_name"])
+
+	v = validator.New()
+	require.NoError(t, s.validateGroup(v, ownersField(), []interface{}{}, true))
+	require.Equal(t, "must have at least 1 items", v.Errors["owners"])
+
+	v = validator.New()
+	require.NoError(t, s.validateGroup(v, ownersField(), []interface{}{
+		map[string]interface{}{}, map[string]interface{}{}, map[string]interface{}{},
+	}, false))
+	require.Equal(t, "must have at most 2 items", v.Errors["owners"])
+
+	v = validator.New()
+	require.NoError(t, s.validateGroup(v, ownersField(), "not a list", false))
+	require.Equal(t, "must be a list of items", v.Errors["owners"])
+}
+
+func TestFindUploadField(t *testing.T) {
+	s := &FormService{}
+	fields := []db.FormField{ownersField(), {FieldName: "logo", FieldType: "file"}}
+
+	require.Equal(t, "logo", s.findUploadField(fields, "logo").FieldName)
+
+	field := s.findUploadField(fields, "owners[1].id_document")
+	require.NotNil(t, field)
+	require.Equal(t, "owners[1].id_document", field.FieldName)
+	require.True(t, field.IsRequired)
+
+	require.Nil(t, s.findUploadField(fields, "owners[1].first_name"))
+	require.Nil(t, s.findUploadField(fields, "owners[1].unknown"))
+}
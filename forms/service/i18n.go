@@ -0,0 +1,192 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// Languages forms are translated into, one for each corridor we operate in
+const (
+	LanguageEnglish = "en"
+	LanguageFrench  = "fr"
+	LanguageChinese = "zh"
+
+	// DefaultLanguage is served when a client accepts none of the supported languages, and
+	// is the fallback for text missing in the language served
+	DefaultLanguage = LanguageEnglish
+)
+
+// SupportedLanguages are the languages clients can negotiate with Accept-Language
+var SupportedLanguages = []string{LanguageEnglish, LanguageFrench, LanguageChinese}
+
+// NegotiateLanguage picks the supported language a client prefers most from an
+// Accept-Language header. Regional tags match their base language, so fr-CA gets fr and
+// zh-Hans-CN gets zh.
+func NegotiateLanguage(acceptLanguage string) string {
+	type preference struct {
+		tag     string
+		quality float64
+	}
+
+	var preferences []preference
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(param, "=")
+			if !ok || strings.TrimSpace(name) != "q" {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				q = 0
+			}
+			quality = q
+		}
+		if quality <= 0 {
+			continue
+		}
+		preferences = append(preferences, preference{tag, quality})
+	}
+
+	sort.SliceStable(preferences, func(i, j int) bool {
+		return preferences[i].quality > preferences[j].quality
+	})
+	for _, p := range preferences {
+		if p.tag == "*" {
+			return DefaultLanguage
+		}
+		if lang, ok := SupportedLanguage(p.tag); ok {
+			return lang
+		}
+	}
+	return DefaultLanguage
+}
+
+// SupportedLanguage returns the supported language of a tag like fr, fr-CA or zh_Hans
+func SupportedLanguage(tag string) (string, bool) {
+	base := strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(base, "-_"); i >= 0 {
+		base = base[:i]
+	}
+	for _, lang := range SupportedLanguages {
+		if base == lang {
+			return lang, true
+		}
+	}
+	return "", false
+}
+
+// Text returns the text in a language, falling back to DefaultLanguage and then to any
+// translation there is
+func (t I18nText) Text(lang string) string {
+	if text := t[lang]; text != "" {
+		return text
+	}
+	if text := t[DefaultLanguage]; text != "" {
+		return text
+	}
+
+	langs := make([]string, 0, len(t))
+	for l := range t {
+		langs = append(langs, l)
+	}
+	sort.Strings(langs)
+	for _, l := range langs {
+		if t[l] != "" {
+			return t[l]
+		}
+	}
+	return ""
+}
+
+// Localize keeps only the language served of the fields of the form
+func (f *FormDefinitionWithData) Localize(lang string) {
+	f.Language = lang
+	f.Fields = LocalizeFields(f.Fields, lang)
+}
+
+// Localize keeps only the language served of the fields of the form
+func (f *PublicForm) Localize(lang string) {
+	f.Language = lang
+	f.Fields = LocalizeFields(f.Fields, lang)
+}
+
+// LocalizeFields returns copies of fields whose labels, placeholders, help text and option
+// labels only hold their text in one language. The text stays a map keyed by the language,
+// with missing translations filled from the fallbacks of I18nText.Text, so clients read it
+// the same way whether or not a language was negotiated.
+func LocalizeFields(fields []db.FormField, lang string) []db.FormField {
+	localized := make([]db.FormField, len(fields))
+	for i, field := range fields {
+		field.Label = localizeText(field.Label, lang)
+		field.Placeholder = localizeText(field.Placeholder, lang)
+		field.HelpText = localizeText(field.HelpText, lang)
+
+		var options FieldOptions
+		if len(field.Options) > 0 && json.Unmarshal(field.Options, &options) == nil {
+			for j, option := range options.Static {
+				options.Static[j].Label = localizeMap(option.Label, lang)
+			}
+			for j, item := range options.Fields {
+				options.Fields[j].Label = localizeMap(item.Label, lang)
+				options.Fields[j].Placeholder = localizeMap(item.Placeholder, lang)
+				options.Fields[j].HelpText = localizeMap(item.HelpText, lang)
+			}
+			if encoded, err := json.Marshal(options); err == nil {
+				field.Options = encoded
+			}
+		}
+		localized[i] = field
+	}
+	return localized
+}
+
+func localizeText(raw json.RawMessage, lang string) json.RawMessage {
+	text := i18nText(raw)
+	if text == nil {
+		return raw
+	}
+	encoded, err := json.Marshal(localizeMap(text, lang))
+	if err != nil {
+		return raw
+	}
+	return encoded
+}
+
+func localizeMap(text map[string]string, lang string) map[string]string {
+	if len(text) == 0 {
+		return text
+	}
+	return map[string]string{lang: I18nText(text).Text(lang)}
+}
+
+// LocalizeError translates the messages of a validation error with the message catalog.
+// Other errors, and messages the catalog doesn't know, are returned as they are.
+func LocalizeError(err error, lang string) error {
+	var validationErr *validator.ValidationError
+	if lang == DefaultLanguage || !errors.As(err, &validationErr) {
+		return err
+	}
+
+	fields := make(validator.ErrorFields, len(validationErr.Fields))
+	for key, message := range validationErr.Fields {
+		msg, ok := validationErr.Messages[key]
+		if !ok {
+			msg = validator.Msg(message)
+		}
+		fields[key] = TranslateMessage(msg, lang)
+	}
+	return validator.NewValidationError(TranslateMessage(validator.Msg(validationErr.Message), lang), fields)
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+func TestNegotiateLanguage(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                          LanguageEnglish,
+		"fr":                        LanguageFrench,
+		"fr-CA,fr;q=0.9,en;q=0.8":   LanguageFrench,
+		"zh-Hans-CN":                LanguageChinese,
+		"de-DE,de;q=0.9,zh;q=0.5":   LanguageChinese,
+		"en;q=0.4, fr;q=0.7":        LanguageFrench,
+		"fr;q=0, zh_TW":             LanguageChinese,
+		"de, *;q=0.1":               LanguageEnglish,
+		"pt-BR;q=garbage, fr;q=0.2": LanguageFrench,
+	} {
+		require.Equal(t, want, NegotiateLanguage(header), header)
+	}
+}
+
+func TestI18nTextFallback(t *testing.T) {
+	text := I18nText{"en": "First name", "fr": "Prénom"}
+	require.Equal(t, "Prénom", text.Text(LanguageFrench))
+	require.Equal(t, "First name", text.Text(LanguageChinese))
+	require.Equal(t, "名字", I18nText{"zh": "名字"}.Text(LanguageFrench))
+	require.Equal(t, "", I18nText(nil).Text(LanguageFrench))
+}
+
+func TestLocalizeFields(t *testing.T) {
+	options, _ := json.Marshal(FieldOptions{
+		Type: "static",
+		Static: []Option{
+			{Value: "ng", Label: map[string]string{"en": "Nigeria", "fr": "Nigéria"}},
+			{Value: "gh", Label: map[string]string{"en": "Ghana"}},
+		},
+	})
+	fields := []db.FormField{{
+		FieldName: "country",
+		Label:     json.RawMessage(`{"en":"Country","fr":"Pays","zh":"国家"}`),
+		HelpText:  json.RawMessage(`{"en":"Where you live"}`),
+		Options:   options,
+	}}
+
+	localized := LocalizeFields(fields, LanguageFrench)
+	require.JSONEq(t, `{"fr":"Pays"}`, string(localized[0].Label))
+	require.JSONEq(t, `{"fr":"Where you live"}`, string(localized[0].HelpText))
+	require.Nil(t, localized[0].Placeholder)
+
+	var localizedOptions FieldOptions
+	require.NoError(t, json.Unmarshal(localized[0].Options, &localizedOptions))
+	require.Equal(t, map[string]string{"fr": "Nigéria"}, localizedOptions.Static[0].Label)
+	require.Equal(t, map[string]string{"fr": "Ghana"}, localizedOptions.Static[1].Label)
+
+	require.JSONEq(t, `{"en":"Country","fr":"Pays","zh":"国家"}`, string(fields[0].Label), "fields are copied")
+}
+
+func TestTranslateMessage(t *testing.T) {
+	require.Equal(t, "ce champ est obligatoire", TranslateMessage(validator.Msg("field is required"), LanguageFrench))
+	require.Equal(t, "至少需要 3 个字符", TranslateMessage(validator.Msg("must be at least %d characters", 3), LanguageChinese))
+	require.Equal(t, "doit être au moins 2.5", TranslateMessage(validator.Msg("must be at least %v", 2.5), LanguageFrench))
+	require.Equal(t, "doit avoir au moins 18 ans", TranslateMessage(validator.Msg("must be at least %d years old", 18), LanguageFrench))
+	require.Equal(t, `不支持来自 "XX" 的身份证件号码`, TranslateMessage(validator.Msg("identity numbers from %q are not supported", "XX"), LanguageChinese))
+	require.Equal(t, "doit être un numéro de téléphone valide", TranslateMessage(validator.Msg("must be a valid phone number"), LanguageFrench))
+	require.Equal(t, "doit être un nin or bvn valide", TranslateMessage(validator.Msg("must be a valid %s", "nin or bvn"), LanguageFrench))
+	require.Equal(t, "not in the catalog", TranslateMessage(validator.Msg("not in the catalog"), LanguageFrench))
+	require.Equal(t, "must be at least 3 characters", TranslateMessage(validator.Msg("must be at least %d characters", 3), LanguageEnglish))
+
+	// Rendered text isn't parsed back into a catalog message
+	require.Equal(t, "must be at least 3 characters", TranslateMessage(validator.Msg("must be at least 3 characters"), LanguageFrench))
+
+	err := LocalizeError(validator.NewValidationError("validation failed", validator.ErrorFields{
+		"email": "invalid email format",
+	}), LanguageChinese)
+	var validationErr *validator.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Equal(t, "验证失败", validationErr.Message)
+	require.Equal(t, "电子邮件格式无效", validationErr.Fields["email"])
+}
+
+func TestLocalizeValidationMessages(t *testing.T) {
+	s := &FormService{}
+	fields := []db.FormField{
+		{FieldName: "name", FieldType: "text", ValidationRules: json.RawMessage(`{"min_length":3}`)},
+		{FieldName: "age", FieldType: "number", ValidationRules: json.RawMessage(`{"min":18}`)},
+	}
+	err := s.ValidateSubmission(fields, map[string]interface{}{"name": "Al", "age": 16}, ValidationContext{Mode: ValidationModeFull})
+	var validationErr *validator.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Equal(t, "must be at least 3 characters", validationErr.Fields["name"])
+
+	require.ErrorAs(t, LocalizeError(err, LanguageFrench), &validationErr)
+	require.Equal(t, map[string]string{
+		"name": "doit contenir au moins 3 caractères",
+		"age":  "doit être au moins 18",
+	}, validationErr.Fields)
+}
+
+var messageVerb = regexp.MustCompile(`%[dvsq]`)
+
+func TestMessageCatalogVerbs(t *testing.T) {
+	for message, translations := range validationMessages {
+		verbs := strings.Join(messageVerb.FindAllString(message, -1), "")
+		for _, lang := range []string{LanguageFrench, LanguageChinese} {
+			translation, ok := translations[lang]
+			require.True(t, ok, "%q has no %s translation", message, lang)
+			require.Equal(t, verbs, strings.Join(messageVerb.FindAllString(translation, -1), ""), "%q in %s", message, lang)
+		}
+	}
+}
+
+func translationTestFields(t *testing.T) []db.FormField {
+	options, err := json.Marshal(FieldOptions{
+		Type:   "static",
+		Static: []Option{{Value: "yes", Label: map[string]string{"en": "Yes", "fr": "Oui"}}},
+	})
+	require.NoError(t, err)
+	group, err := json.Marshal(FieldOptions{
+		Fields: []db.FieldInput{{FieldName: "name", Label: map[string]string{"en": "Name"}}},
+	})
+	require.NoError(t, err)
+
+	return []db.FormField{
+		{ID: uuid.New(), FieldName: "consent", Label: json.RawMessage(`{"en":"I agree","fr":"J'accepte"}`), Options: options},
+		{ID: uuid.New(), FieldName: "owners", Label: json.RawMessage(`{"en":"Owners"}`), Options: group},
+	}
+}
+
+func TestMissingTranslations(t *testing.T) {
+	var translations []FieldTranslation
+	for _, field := range translationTestFields(t) {
+		translations = append(translations, fieldTranslations(field)...)
+	}
+
+	require.Equal(t, []MissingTranslation{
+		{FieldName: "consent", Key: "label", Languages: []string{"zh"}, Fallback: "I agree"},
+		{FieldName: "consent", Key: "options.yes", Languages: []string{"zh"}, Fallback: "Yes"},
+		{FieldName: "owners", Key: "label", Languages: []string{"fr", "zh"}, Fallback: "Owners"},
+		{FieldName: "owners", Key: "fields.name.label", Languages: []string{"fr", "zh"}, Fallback: "Name"},
+	}, missingTranslations(translations, []string{LanguageFrench, LanguageChinese}))
+
+	_, err := translationLanguages([]string{"fr", "de"})
+	var validationErr *validator.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+}
+
+func TestTranslationsCSV(t *testing.T) {
+	fields := translationTestFields(t)
+	var translations []FieldTranslation
+	for _, field := range fields {
+		translations = append(translations, fieldTranslations(field)...)
+	}
+
+	var file strings.Builder
+	require.NoError(t, WriteTranslationsCSV(&file, translations, SupportedLanguages))
+	require.True(t, strings.HasPrefix(file.String(), "field_name,key,en,fr,zh\nconsent,label,I agree,J'accepte,\n"))
+
+	// A translator fills in the Chinese column
+	filled := strings.NewReplacer(
+		"consent,label,I agree,J'accepte,\n", "consent,label,I agree,J'accepte,我同意\n",
+		"owners,fields.name.label,Name,,\n", "owners,fields.name.label,Name,Nom,姓名\n",
+	).Replace(file.String())
+
+	imported, err := ReadTranslationsCSV(strings.NewReader("\ufeff" + filled))
+	require.NoError(t, err)
+	require.Len(t, imported, len(translations))
+
+	updates, applied, err := applyTranslations(fields, imported)
+	require.NoError(t, err)
+	require.Equal(t, 9, applied)
+	require.Len(t, updates, 2)
+	require.Equal(t, fields[0].ID, updates[0].ID)
+	require.JSONEq(t, `{"en":"I agree","fr":"J'accepte","zh":"我同意"}`, string(updates[0].Label))
+	require.Equal(t, fields[0].Options, updates[0].Options)
+
+	var group FieldOptions
+	require.NoError(t, json.Unmarshal(updates[1].Options, &group))
+	require.Equal(t, map[string]string{"en": "Name", "fr": "Nom", "zh": "姓名"}, group.Fields[0].Label)
+
+	_, _, err = applyTranslations(fields, []FieldTranslation{
+		{FieldName: "missing", Key: "label", Text: I18nText{"fr": "x"}},
+		{FieldName: "consent", Key: "options.no", Text: I18nText{"fr": "Non"}},
+	})
+	var validationErr *validator.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Equal(t, map[string]string{
+		"missing.label":      "unknown field",
+		"consent.options.no": "unknown text",
+	}, validationErr.Fields)
+
+	_, err = ReadTranslationsCSV(strings.NewReader("name,en\nconsent,I agree\n"))
+	require.ErrorIs(t, err, ErrInvalidTranslations)
+	_, err = ReadTranslationsCSV(strings.NewReader("field_name,key,de\nconsent,label,Ich stimme zu\n"))
+	require.ErrorAs(t, err, &validationErr)
+}
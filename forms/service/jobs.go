@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Tasks of the periodic form jobs. The jobs run in the worker: its scheduler enqueues each task
+// every Interval of FormJobs and its processor hands them to RunFormJob, so they stop with the
+// worker when the server shuts down.
+const (
+	TaskFormEventDispatch      = "form:event_dispatch"
+	TaskFormRetentionPurge     = "form:retention_purge"
+	TaskFormApprovalEscalation = "form:approval_escalation"
+)
+
+var ErrUnknownFormJob = errors.New("unknown form job")
+
+// FormJob is a periodic job of the forms module
+type FormJob struct {
+	Task     string
+	Interval time.Duration
+}
+
+// FormJobs are the jobs the worker's scheduler enqueues. Event dispatch is also enqueued
+// whenever a change writes events to the outbox.
+var FormJobs = []FormJob{
+	// Retries form event deliveries that failed or were interrupted
+	{Task: TaskFormEventDispatch, Interval: 30 * time.Second},
+	// Deletes or anonymizes submissions expired by retention policies, and expired data exports
+	{Task: TaskFormRetentionPurge, Interval: time.Hour},
+	// Reassigns approval stages that have passed their SLA
+	{Task: TaskFormApprovalEscalation, Interval: 5 * time.Minute},
+}
+
+// RunFormJob runs the job of a task once. A run that finds another instance running the same
+// job does nothing, as the next one will pick up what is left.
+func (s *FormService) RunFormJob(ctx context.Context, task string) error {
+	var err error
+	switch task {
+	case TaskFormEventDispatch:
+		err = s.dispatchAllPendingEvents(ctx)
+	case TaskFormRetentionPurge:
+		_, err = s.PurgeExpiredSubmissions(ctx)
+	case TaskFormApprovalEscalation:
+		_, err = s.EscalateOverdueApprovals(ctx)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownFormJob, task)
+	}
+
+	if errors.Is(err, ErrRetentionPurgeRunning) || errors.Is(err, ErrApprovalEscalationRunning) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+)
+
+// lockedStore reports the advisory locks of names in held as taken by another instance
+type lockedStore struct {
+	db.Store
+	held  map[string]bool
+	tried []string
+}
+
+func (s *lockedStore) TryAdvisoryLock(ctx context.Context, name string) (func(), bool, error) {
+	s.tried = append(s.tried, name)
+	return func() {}, !s.held[name], nil
+}
+
+func TestRunFormJob(t *testing.T) {
+	store := &lockedStore{held: map[string]bool{retentionPurgeLock: true, approvalEscalationLock: true}}
+	s := &FormService{store: store}
+
+	// Jobs that another instance is running are skipped without an error
+	require.NoError(t, s.RunFormJob(context.Background(), TaskFormRetentionPurge))
+	require.NoError(t, s.RunFormJob(context.Background(), TaskFormApprovalEscalation))
+	require.Equal(t, []string{retentionPurgeLock, approvalEscalationLock}, store.tried)
+
+	_, err := s.EscalateOverdueApprovals(context.Background())
+	require.ErrorIs(t, err, ErrApprovalEscalationRunning)
+
+	require.ErrorIs(t, s.RunFormJob(context.Background(), "form:unknown"), ErrUnknownFormJob)
+
+	for _, job := range FormJobs {
+		require.Positive(t, job.Interval, job.Task)
+	}
+}
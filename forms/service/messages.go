@@ -0,0 +1,219 @@
+package service
+
+import "github.com/timchuks/monieverse/internal/validator"
+
+// validationMessages translates the validation messages of submissions. Keys are the English
+// messages, with the fmt verbs of the messages that take arguments, as validators emit them with
+// validator.Msg; translations use the same verbs in the same order.
+var validationMessages = map[string]map[string]string{
+	"validation failed": {
+		LanguageFrench:  "la validation a échoué",
+		LanguageChinese: "验证失败",
+	},
+	"step validation failed": {
+		LanguageFrench:  "la validation de l'étape a échoué",
+		LanguageChinese: "步骤验证失败",
+	},
+	"field is required": {
+		LanguageFrench:  "ce champ est obligatoire",
+		LanguageChinese: "此字段为必填项",
+	},
+	"field is required for this step": {
+		LanguageFrench:  "ce champ est obligatoire pour cette étape",
+		LanguageChinese: "此步骤必须填写此字段",
+	},
+	"invalid email format": {
+		LanguageFrench:  "format d'adresse e-mail invalide",
+		LanguageChinese: "电子邮件格式无效",
+	},
+	"this email address is not accepted, use your work or personal email": {
+		LanguageFrench:  "cette adresse e-mail n'est pas acceptée, utilisez votre adresse professionnelle ou personnelle",
+		LanguageChinese: "不接受此电子邮件地址，请使用您的工作或个人邮箱",
+	},
+	"invalid format": {
+		LanguageFrench:  "format invalide",
+		LanguageChinese: "格式无效",
+	},
+	"invalid option": {
+		LanguageFrench:  "option invalide",
+		LanguageChinese: "选项无效",
+	},
+	"must be at least %d characters": {
+		LanguageFrench:  "doit contenir au moins %d caractères",
+		LanguageChinese: "至少需要 %d 个字符",
+	},
+	"must be at most %d characters": {
+		LanguageFrench:  "doit contenir au plus %d caractères",
+		LanguageChinese: "最多 %d 个字符",
+	},
+	"must be at least %v": {
+		LanguageFrench:  "doit être au moins %v",
+		LanguageChinese: "不能小于 %v",
+	},
+	"must be at most %v": {
+		LanguageFrench:  "doit être au plus %v",
+		LanguageChinese: "不能大于 %v",
+	},
+	"must be a date like %s": {
+		LanguageFrench:  "doit être une date comme %s",
+		LanguageChinese: "必须是类似 %s 的日期",
+	},
+	"must be on or after %s": {
+		LanguageFrench:  "doit être le %s ou après",
+		LanguageChinese: "必须在 %s 或之后",
+	},
+	"must be on or before %s": {
+		LanguageFrench:  "doit être le %s ou avant",
+		LanguageChinese: "必须在 %s 或之前",
+	},
+	"must be at least %d years old": {
+		LanguageFrench:  "doit avoir au moins %d ans",
+		LanguageChinese: "年龄必须至少为 %d 岁",
+	},
+	"must be at most %d years old": {
+		LanguageFrench:  "doit avoir au plus %d ans",
+		LanguageChinese: "年龄不能超过 %d 岁",
+	},
+	"must be a valid phone number": {
+		LanguageFrench:  "doit être un numéro de téléphone valide",
+		LanguageChinese: "必须是有效的电话号码",
+	},
+	"must be a phone number from %s": {
+		LanguageFrench:  "doit être un numéro de téléphone de %s",
+		LanguageChinese: "必须是来自 %s 的电话号码",
+	},
+	"must be an identity number": {
+		LanguageFrench:  "doit être un numéro d'identité",
+		LanguageChinese: "必须是身份证件号码",
+	},
+	"must be an identity number from %s": {
+		LanguageFrench:  "doit être un numéro d'identité de %s",
+		LanguageChinese: "必须是来自 %s 的身份证件号码",
+	},
+	"%s is not accepted": {
+		LanguageFrench:  "%s n'est pas accepté",
+		LanguageChinese: "不接受 %s",
+	},
+	"identity numbers from %q are not supported": {
+		LanguageFrench:  "les numéros d'identité de %q ne sont pas pris en charge",
+		LanguageChinese: "不支持来自 %q 的身份证件号码",
+	},
+	"must be a valid %s": {
+		LanguageFrench:  "doit être un %s valide",
+		LanguageChinese: "必须是有效的 %s",
+	},
+	"must be an address": {
+		LanguageFrench:  "doit être une adresse",
+		LanguageChinese: "必须是地址",
+	},
+	"unknown address part": {
+		LanguageFrench:  "partie d'adresse inconnue",
+		LanguageChinese: "未知的地址部分",
+	},
+	"must be text": {
+		LanguageFrench:  "doit être du texte",
+		LanguageChinese: "必须是文本",
+	},
+	"must be a country code like NG": {
+		LanguageFrench:  "doit être un code pays comme NG",
+		LanguageChinese: "必须是国家代码，例如 NG",
+	},
+	"must be one of %s": {
+		LanguageFrench:  "doit être l'un de %s",
+		LanguageChinese: "必须是以下之一：%s",
+	},
+	"invalid postal code": {
+		LanguageFrench:  "code postal invalide",
+		LanguageChinese: "邮政编码无效",
+	},
+	"must be a list of options": {
+		LanguageFrench:  "doit être une liste d'options",
+		LanguageChinese: "必须是选项列表",
+	},
+	"options can only be chosen once": {
+		LanguageFrench:  "chaque option ne peut être choisie qu'une fois",
+		LanguageChinese: "每个选项只能选择一次",
+	},
+	"must have at least %d options": {
+		LanguageFrench:  "doit avoir au moins %d options",
+		LanguageChinese: "至少选择 %d 个选项",
+	},
+	"must have at most %d options": {
+		LanguageFrench:  "doit avoir au plus %d options",
+		LanguageChinese: "最多选择 %d 个选项",
+	},
+	"all options must be chosen": {
+		LanguageFrench:  "toutes les options doivent être choisies",
+		LanguageChinese: "必须选择所有选项",
+	},
+	"must be a list of items": {
+		LanguageFrench:  "doit être une liste d'éléments",
+		LanguageChinese: "必须是项目列表",
+	},
+	"must have at least %d items": {
+		LanguageFrench:  "doit avoir au moins %d éléments",
+		LanguageChinese: "至少需要 %d 项",
+	},
+	"must have at most %d items": {
+		LanguageFrench:  "doit avoir au plus %d éléments",
+		LanguageChinese: "最多 %d 项",
+	},
+	"must match %s": {
+		LanguageFrench:  "doit correspondre à %s",
+		LanguageChinese: "必须与 %s 一致",
+	},
+	"must be different from %s": {
+		LanguageFrench:  "doit être différent de %s",
+		LanguageChinese: "必须与 %s 不同",
+	},
+	"must be greater than %s": {
+		LanguageFrench:  "doit être supérieur à %s",
+		LanguageChinese: "必须大于 %s",
+	},
+	"must be less than %s": {
+		LanguageFrench:  "doit être inférieur à %s",
+		LanguageChinese: "必须小于 %s",
+	},
+	"must be after %s": {
+		LanguageFrench:  "doit être après %s",
+		LanguageChinese: "必须晚于 %s",
+	},
+	"must be before %s": {
+		LanguageFrench:  "doit être avant %s",
+		LanguageChinese: "必须早于 %s",
+	},
+	"must total %s": {
+		LanguageFrench:  "le total doit être de %s",
+		LanguageChinese: "总和必须为 %s",
+	},
+	"%s of all items must total %s": {
+		LanguageFrench:  "le total de %s de tous les éléments doit être de %s",
+		LanguageChinese: "所有项目的 %s 总和必须为 %s",
+	},
+	"is already registered": {
+		LanguageFrench:  "est déjà enregistré",
+		LanguageChinese: "已被注册",
+	},
+	"is not valid": {
+		LanguageFrench:  "n'est pas valide",
+		LanguageChinese: "无效",
+	},
+	"could not be verified, try again later": {
+		LanguageFrench:  "n'a pas pu être vérifié, réessayez plus tard",
+		LanguageChinese: "无法验证，请稍后重试",
+	},
+}
+
+// TranslateMessage translates a validation message into a language with the message catalog.
+// The translation of its key is rendered with its arguments; messages the catalog doesn't know
+// are returned in English.
+func TranslateMessage(message validator.Message, lang string) string {
+	if lang == DefaultLanguage {
+		return message.String()
+	}
+	translation := validationMessages[message.Key][lang]
+	if translation == "" {
+		return message.String()
+	}
+	return validator.Msg(translation, message.Args...).String()
+}
@@ -0,0 +1,395 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// OpenAPIVersion is the OpenAPI version form documents are written in. 3.1 schemas are JSON
+// Schema 2020-12, so the form schema is used as is.
+const OpenAPIVersion = "3.1.0"
+
+// OpenAPIDocument describes the submission endpoints of one form version
+type OpenAPIDocument struct {
+	OpenAPI           string                                  `json:"openapi"`
+	Info              OpenAPIInfo                             `json:"info"`
+	JSONSchemaDialect string                                  `json:"jsonSchemaDialect"`
+	Servers           []OpenAPIServer                         `json:"servers,omitempty"`
+	Paths             map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components        OpenAPIComponents                       `json:"components"`
+	Security          []map[string][]string                   `json:"security"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary"`
+	Description string                      `json:"description,omitempty"`
+	Parameters  []OpenAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+type OpenAPIParameter struct {
+	Name        string      `json:"name"`
+	In          string      `json:"in"`
+	Description string      `json:"description,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Schema      *JSONSchema `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIMediaType struct {
+	Schema   *JSONSchema                 `json:"schema"`
+	Encoding map[string]*OpenAPIEncoding `json:"encoding,omitempty"`
+}
+
+type OpenAPIEncoding struct {
+	ContentType string `json:"contentType"`
+}
+
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Headers     map[string]*OpenAPIHeader    `json:"headers,omitempty"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIHeader struct {
+	Description string      `json:"description,omitempty"`
+	Schema      *JSONSchema `json:"schema"`
+}
+
+type OpenAPIComponents struct {
+	Schemas         map[string]*JSONSchema           `json:"schemas"`
+	SecuritySchemes map[string]OpenAPISecurityScheme `json:"securitySchemes"`
+}
+
+type OpenAPISecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme"`
+}
+
+// GetFormOpenAPI returns the OpenAPI document of a form version, the current one when version
+// is 0. serverURL is where the form routes are mounted, e.g. /api/v1.
+func (s *FormService) GetFormOpenAPI(ctx context.Context, formID uuid.UUID, version int32, activeOnly bool, serverURL string) (*OpenAPIDocument, error) {
+	schema, err := s.GetFormJSONSchema(ctx, formID, version, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	return BuildFormOpenAPI(schema, serverURL), nil
+}
+
+// BuildFormOpenAPI describes the endpoints that create, update and complete submissions of the
+// form a schema was built for. Submissions are posted as multipart forms; each step of a multi
+// step form gets its own path with the schema of the step.
+func BuildFormOpenAPI(schema *JSONSchema, serverURL string) *OpenAPIDocument {
+	form := schema.Form
+	submission := *schema
+	submission.Schema, submission.Defs = "", nil
+
+	draft := submission
+	draft.Required, draft.AllOf = nil, nil
+	draft.Description = "Any subset of the fields of the form. Drafts are only checked against the rules of the fields they contain."
+
+	doc := &OpenAPIDocument{
+		OpenAPI: OpenAPIVersion,
+		Info: OpenAPIInfo{
+			Title:       fmt.Sprintf("%s submissions", schema.Title),
+			Description: schema.Description,
+			Version:     strconv.Itoa(int(form.Version)),
+		},
+		JSONSchemaDialect: JSONSchemaDialect,
+		Paths:             make(map[string]map[string]*OpenAPIOperation),
+		Components: OpenAPIComponents{
+			Schemas: map[string]*JSONSchema{
+				"Submission":       &submission,
+				"SubmissionDraft":  &draft,
+				"SubmissionFields": formControlFields(),
+				"FormSubmission":   formSubmissionSchema(),
+				"StepProgress":     stepProgressSchema(),
+				"AutosaveRequest":  autosaveRequestSchema(schema),
+				"Response":         responseSchema(&JSONSchema{}),
+				"Error":            responseSchema(nil),
+				"RevisionConflict": responseSchema(revisionConflictSchema()),
+			},
+			SecuritySchemes: map[string]OpenAPISecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer"},
+			},
+		},
+		Security: []map[string][]string{{"bearerAuth": {}}},
+	}
+	if serverURL != "" {
+		doc.Servers = []OpenAPIServer{{URL: serverURL}}
+	}
+
+	formPath := "/forms/" + form.ID.String()
+	submissionPath := "/forms/submissions/{submission_id}"
+	submissionID := OpenAPIParameter{Name: "submission_id", In: "path", Required: true, Schema: &JSONSchema{Type: "string", Format: "uuid"}}
+	ifMatch := OpenAPIParameter{
+		Name:        "If-Match",
+		In:          "header",
+		Description: `The revision the change is based on, e.g. "3", from the ETag of the last save. A stale revision gets a 409 with the fields changed since.`,
+		Schema:      &JSONSchema{Type: "string"},
+	}
+	saved := map[string]*OpenAPIResponse{
+		"400": jsonResponse("The submission is invalid", "Error"),
+		"409": jsonResponse("The submission was changed since the revision in If-Match", "RevisionConflict"),
+	}
+
+	doc.addOperation(formPath+"/draft", http.MethodPost, &OpenAPIOperation{
+		OperationID: "createDraft",
+		Summary:     "Start a draft submission",
+		RequestBody: multipartBody(schema, "SubmissionDraft"),
+		Responses:   submissionResponses(http.StatusCreated, "FormSubmission", nil),
+	})
+	doc.addOperation(formPath+"/submit", http.MethodPost, &OpenAPIOperation{
+		OperationID: "submitForm",
+		Summary:     "Submit the form in one request",
+		RequestBody: multipartBody(schema, "Submission"),
+		Responses:   submissionResponses(http.StatusCreated, "FormSubmission", nil),
+	})
+	doc.addOperation(formPath+"/schema", http.MethodGet, &OpenAPIOperation{
+		OperationID: "getFormSchema",
+		Summary:     "Get the JSON Schema of the form",
+		Responses: map[string]*OpenAPIResponse{
+			"200": {
+				Description: "The JSON Schema of the current version",
+				Content:     map[string]*OpenAPIMediaType{"application/schema+json": {Schema: &JSONSchema{Type: "object"}}},
+			},
+		},
+	})
+	doc.addOperation(submissionPath+"/edit", http.MethodGet, &OpenAPIOperation{
+		OperationID: "getSubmissionForEdit",
+		Summary:     "Get a submission with the form it was started on",
+		Parameters:  []OpenAPIParameter{submissionID},
+		Responses:   map[string]*OpenAPIResponse{"200": jsonResponse("The form and the data saved so far", "Response")},
+	})
+	doc.addOperation(submissionPath, http.MethodPut, &OpenAPIOperation{
+		OperationID: "updateSubmission",
+		Summary:     "Update a submission",
+		Description: "With _partial=true only the fields sent are validated; submitting validates the whole form.",
+		Parameters:  []OpenAPIParameter{submissionID, ifMatch},
+		RequestBody: multipartBody(schema, "SubmissionDraft"),
+		Responses:   submissionResponses(http.StatusOK, "FormSubmission", saved),
+	})
+	doc.addOperation(submissionPath+"/autosave", http.MethodPatch, &OpenAPIOperation{
+		OperationID: "autosaveSubmission",
+		Summary:     "Autosave a draft with a field-level patch",
+		Parameters:  []OpenAPIParameter{submissionID, ifMatch},
+		RequestBody: &OpenAPIRequestBody{
+			Required: true,
+			Content:  map[string]*OpenAPIMediaType{"application/json": {Schema: componentRef("AutosaveRequest")}},
+		},
+		Responses: submissionResponses(http.StatusOK, "FormSubmission", saved),
+	})
+	for _, step := range schema.Steps {
+		name := StepSchemaName(step.StepNumber)
+		doc.Components.Schemas[name] = schema.Defs[name]
+		doc.addOperation(fmt.Sprintf("%s/steps/%d", submissionPath, step.StepNumber), http.MethodPut, &OpenAPIOperation{
+			OperationID: fmt.Sprintf("saveStep%d", step.StepNumber),
+			Summary:     fmt.Sprintf("Save step %d: %s", step.StepNumber, step.Name),
+			Description: "With _status=completed the step's required fields must be filled.",
+			Parameters:  []OpenAPIParameter{submissionID, ifMatch},
+			RequestBody: multipartBody(schema.Defs[name], name),
+			Responses:   submissionResponses(http.StatusOK, "StepProgress", saved),
+		})
+	}
+	doc.addOperation(submissionPath+"/complete", http.MethodPost, &OpenAPIOperation{
+		OperationID: "completeSubmission",
+		Summary:     "Submit a draft once every step is saved",
+		Parameters:  []OpenAPIParameter{submissionID},
+		Responses:   submissionResponses(http.StatusOK, "FormSubmission", map[string]*OpenAPIResponse{"400": saved["400"]}),
+	})
+
+	return doc
+}
+
+func (doc *OpenAPIDocument) addOperation(path, method string, op *OpenAPIOperation) {
+	if doc.Paths[path] == nil {
+		doc.Paths[path] = make(map[string]*OpenAPIOperation)
+	}
+	doc.Paths[path][strings.ToLower(method)] = op
+}
+
+func componentRef(name string) *JSONSchema {
+	return &JSONSchema{Ref: "#/components/schemas/" + name}
+}
+
+// multipartBody posts the fields of a schema with the control fields handlers read, encoding
+// uploads with the types their fields allow
+func multipartBody(schema *JSONSchema, component string) *OpenAPIRequestBody {
+	media := &OpenAPIMediaType{
+		Schema: &JSONSchema{AllOf: []*JSONSchema{componentRef(component), componentRef("SubmissionFields")}},
+	}
+
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		property := schema.Properties[name]
+		if !IsUploadFieldType(property.FieldType) || property.File == nil || len(property.File.AllowedTypes) == 0 {
+			continue
+		}
+		if media.Encoding == nil {
+			media.Encoding = make(map[string]*OpenAPIEncoding)
+		}
+		media.Encoding[name] = &OpenAPIEncoding{ContentType: strings.Join(property.File.AllowedTypes, ", ")}
+	}
+
+	return &OpenAPIRequestBody{
+		Required: true,
+		Content:  map[string]*OpenAPIMediaType{"multipart/form-data": media},
+	}
+}
+
+// formControlFields are the underscored fields handlers read besides the form's own
+func formControlFields() *JSONSchema {
+	return &JSONSchema{
+		Type: "object",
+		Properties: map[string]*JSONSchema{
+			"_status":   {Type: "string", Enum: []interface{}{"draft", "submitted", "in_progress", "completed"}},
+			"_partial":  {Type: "boolean", Description: "Only validate the fields sent"},
+			"_revision": {Type: "integer", Description: "The revision the change is based on, for clients that can't set If-Match"},
+		},
+	}
+}
+
+func formSubmissionSchema() *JSONSchema {
+	uuidSchema := &JSONSchema{Type: "string", Format: "uuid"}
+	timeSchema := &JSONSchema{Type: "string", Format: "date-time"}
+	return &JSONSchema{
+		Type: "object",
+		Properties: map[string]*JSONSchema{
+			"id":                 uuidSchema,
+			"form_definition_id": uuidSchema,
+			"user_id":            uuidSchema,
+			"submission_data":    componentRef("SubmissionDraft"),
+			"status":             {Type: "string"},
+			"approval_status":    {Type: "string"},
+			"form_version":       {Type: "integer"},
+			"revision":           {Type: "integer"},
+			"created_at":         timeSchema,
+			"updated_at":         timeSchema,
+		},
+	}
+}
+
+func stepProgressSchema() *JSONSchema {
+	return &JSONSchema{
+		Type: "object",
+		Properties: map[string]*JSONSchema{
+			"success":               {Type: "boolean"},
+			"current_step":          {Type: "integer"},
+			"completion_percentage": {Type: "integer"},
+			"all_steps_completed":   {Type: "boolean"},
+			"revision":              {Type: "integer", Description: "The revision of the saved step"},
+		},
+	}
+}
+
+func autosaveRequestSchema(schema *JSONSchema) *JSONSchema {
+	names := make([]interface{}, 0, len(schema.Properties))
+	for name, property := range schema.Properties {
+		if !IsUploadFieldType(property.FieldType) {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i].(string) < names[j].(string) })
+
+	return &JSONSchema{
+		Type: "object",
+		Properties: map[string]*JSONSchema{
+			"set":      componentRef("SubmissionDraft"),
+			"unset":    {Type: "array", Items: &JSONSchema{Type: "string", Enum: names}},
+			"revision": {Type: "integer", Description: "The revision the patch is based on, unless If-Match is set"},
+		},
+	}
+}
+
+func revisionConflictSchema() *JSONSchema {
+	return &JSONSchema{
+		Type: "object",
+		Properties: map[string]*JSONSchema{
+			"submission_id":     {Type: "string", Format: "uuid"},
+			"step_number":       {Type: "integer"},
+			"expected_revision": {Type: "integer"},
+			"current_revision":  {Type: "integer"},
+			"changes": {Type: "array", Items: &JSONSchema{
+				Type: "object",
+				Properties: map[string]*JSONSchema{
+					"field":   {Type: "string"},
+					"base":    {},
+					"current": {},
+					"yours":   {},
+				},
+			}},
+		},
+	}
+}
+
+// responseSchema is the envelope of every response, with the data of successful ones
+func responseSchema(data *JSONSchema) *JSONSchema {
+	schema := &JSONSchema{
+		Type: "object",
+		Properties: map[string]*JSONSchema{
+			"status":  {Type: "string"},
+			"message": {Type: "string"},
+		},
+		Required: []string{"status", "message"},
+	}
+	if data != nil {
+		schema.Properties["data"] = data
+	}
+	return schema
+}
+
+func jsonResponse(description, component string) *OpenAPIResponse {
+	return &OpenAPIResponse{
+		Description: description,
+		Content:     map[string]*OpenAPIMediaType{"application/json": {Schema: componentRef(component)}},
+	}
+}
+
+// submissionResponses are the responses of endpoints that save a submission, with data of the
+// given component. Saves return the new revision in the ETag header.
+func submissionResponses(status int, data string, errors map[string]*OpenAPIResponse) map[string]*OpenAPIResponse {
+	saved := &OpenAPIResponse{
+		Description: http.StatusText(status),
+		Headers: map[string]*OpenAPIHeader{
+			"ETag": {Description: "The revision to send in If-Match with the next save", Schema: &JSONSchema{Type: "string"}},
+		},
+		Content: map[string]*OpenAPIMediaType{"application/json": {Schema: &JSONSchema{
+			AllOf: []*JSONSchema{componentRef("Response"), {Properties: map[string]*JSONSchema{"data": componentRef(data)}}},
+		}}},
+	}
+
+	responses := map[string]*OpenAPIResponse{
+		strconv.Itoa(status): saved,
+		"400":                jsonResponse("The submission is invalid", "Error"),
+	}
+	for code, response := range errors {
+		responses[code] = response
+	}
+	return responses
+}
@@ -0,0 +1,603 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+)
+
+// Source types of a FormDynamicOption
+const (
+	OptionSourceCurrencies = "currencies"
+	OptionSourceBanks      = "banks"
+	OptionSourceSchemes    = "schemes"
+	OptionSourceCountries  = "countries"
+	OptionSourceStatic     = "static"
+	OptionSourceQuery      = "query"
+	// OptionSourceTable is the original source type, configured as {"table": "countries"}.
+	// Tables with their own source use it, other tables are read as a query source.
+	OptionSourceTable = "table"
+)
+
+const (
+	// maxOptionCacheEntries caps the option cache; the least recently used entries are evicted
+	maxOptionCacheEntries = 1000
+	// optionCacheSweepInterval is how often adding to the option cache also drops its expired
+	// entries
+	optionCacheSweepInterval = 10 * time.Minute
+)
+
+var ErrUnknownOptionSource = errors.New("unknown dynamic option source")
+
+// filterParamReference matches "{{field_name}}" in a filter param, which is replaced with the
+// value of that field so dropdowns can depend on each other
+var filterParamReference = regexp.MustCompile(`^\{\{\s*([a-zA-Z0-9_]+)\s*\}\}$`)
+
+// OptionSource lists the options of a dynamic field. Params are the field's filter params
+// with references to other fields resolved.
+type OptionSource interface {
+	Options(ctx context.Context, config json.RawMessage, params map[string]string) ([]Option, error)
+}
+
+// OptionSourceFunc adapts a function to OptionSource
+type OptionSourceFunc func(ctx context.Context, config json.RawMessage, params map[string]string) ([]Option, error)
+
+func (f OptionSourceFunc) Options(ctx context.Context, config json.RawMessage, params map[string]string) ([]Option, error) {
+	return f(ctx, config, params)
+}
+
+// OptionSourceParams is implemented by sources that read only some filter params. Their options
+// are fetched and cached with those params alone, so params a source ignores don't add cache
+// entries. Sources that don't implement it are given every filter param of the field.
+type OptionSourceParams interface {
+	Params(config json.RawMessage) []string
+}
+
+// paramOptionSource is an OptionSource that declares the filter params it reads
+type paramOptionSource struct {
+	OptionSource
+	params func(config json.RawMessage) []string
+}
+
+func (s paramOptionSource) Params(config json.RawMessage) []string {
+	return s.params(config)
+}
+
+// withParams declares the filter params a source reads
+func withParams(source OptionSource, params func(config json.RawMessage) []string) OptionSource {
+	return paramOptionSource{OptionSource: source, params: params}
+}
+
+func fixedParams(names ...string) func(json.RawMessage) []string {
+	return func(json.RawMessage) []string {
+		return names
+	}
+}
+
+// sourceParams keeps the filter params the source reads
+func sourceParams(source OptionSource, config json.RawMessage, params map[string]string) map[string]string {
+	declared, ok := source.(OptionSourceParams)
+	if !ok {
+		return params
+	}
+
+	kept := map[string]string{}
+	for _, name := range declared.Params(config) {
+		if value, ok := params[name]; ok {
+			kept[name] = value
+		}
+	}
+	return kept
+}
+
+// RegisterOptionSource adds or replaces the source for a dynamic option source type
+func (s *FormService) RegisterOptionSource(sourceType string, source OptionSource) {
+	s.registry.Lock()
+	defer s.registry.Unlock()
+	s.optionSources[sourceType] = source
+}
+
+// optionSource returns the source of a dynamic option source type
+func (s *FormService) optionSource(sourceType string) (OptionSource, bool) {
+	s.registry.RLock()
+	defer s.registry.RUnlock()
+	source, ok := s.optionSources[sourceType]
+	return source, ok
+}
+
+// defaultOptionSources are the built-in option sources. The table source reads the source of
+// a table through lookup, so it uses sources registered in place of the built-in ones.
+func defaultOptionSources(store db.Store, lookup func(string) (OptionSource, bool)) map[string]OptionSource {
+	sources := map[string]OptionSource{
+		OptionSourceCurrencies: withParams(currencyOptionSource(store), fixedParams("can_have_wallet")),
+		OptionSourceBanks:      withParams(bankOptionSource(store), fixedParams("country", "currency", "type")),
+		OptionSourceSchemes:    withParams(schemeOptionSource(store), fixedParams("currency")),
+		OptionSourceCountries:  withParams(countryOptionSource(store), fixedParams()),
+		OptionSourceStatic:     withParams(OptionSourceFunc(staticOptions), fixedParams()),
+		OptionSourceQuery:      withParams(queryOptionSource(store), queryOptionParams),
+	}
+	sources[OptionSourceTable] = withParams(tableOptionSource(lookup), tableOptionParams(lookup))
+	return sources
+}
+
+// currencyOptionSource lists active currencies. The can_have_wallet param limits it to wallet currencies.
+func currencyOptionSource(store db.Store) OptionSource {
+	return OptionSourceFunc(func(ctx context.Context, _ json.RawMessage, params map[string]string) ([]Option, error) {
+		currencies, err := store.GetActiveCurrencies(ctx, sql.NullBool{Bool: true, Valid: true})
+		if err != nil {
+			return nil, err
+		}
+
+		options := []Option{}
+		for _, currency := range currencies {
+			if params["can_have_wallet"] == "true" && !currency.CanHaveWallet {
+				continue
+			}
+			options = append(options, Option{Value: currency.Code, Label: I18nText{"en": currency.Name}})
+		}
+		return options, nil
+	})
+}
+
+// bankOptionSource lists active banks, optionally filtered by the country, currency and type params
+func bankOptionSource(store db.Store) OptionSource {
+	return OptionSourceFunc(func(ctx context.Context, _ json.RawMessage, params map[string]string) ([]Option, error) {
+		banks, err := store.GetAllBanks(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		options := []Option{}
+		for _, bank := range banks {
+			if !bank.Active || bank.IsDeleted {
+				continue
+			}
+			if !paramMatches(params, "country", bank.Country) ||
+				!paramMatches(params, "currency", bank.Currency) ||
+				!paramMatches(params, "type", bank.Type) {
+				continue
+			}
+			options = append(options, Option{Value: bank.Code, Label: I18nText{"en": bank.Name}})
+		}
+
+		sort.SliceStable(options, func(i, j int) bool {
+			return options[i].Label["en"] < options[j].Label["en"]
+		})
+		return options, nil
+	})
+}
+
+// schemeOptionSource lists the payment schemes supported by the currency param, or by any
+// active currency
+func schemeOptionSource(store db.Store) OptionSource {
+	return OptionSourceFunc(func(ctx context.Context, _ json.RawMessage, params map[string]string) ([]Option, error) {
+		var currencies []db.Currency
+		if code := params["currency"]; code != "" {
+			currency, err := store.GetCurrencyByCode(ctx, strings.ToUpper(code))
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return []Option{}, nil
+				}
+				return nil, err
+			}
+			currencies = []db.Currency{currency}
+		} else {
+			var err error
+			if currencies, err = store.GetActiveCurrencies(ctx, sql.NullBool{Bool: true, Valid: true}); err != nil {
+				return nil, err
+			}
+		}
+
+		seen := map[string]bool{}
+		options := []Option{}
+		for _, currency := range currencies {
+			for _, scheme := range currency.GetSupportedPaymentSchemes() {
+				if scheme == "" || seen[scheme] {
+					continue
+				}
+				seen[scheme] = true
+				options = append(options, Option{Value: scheme, Label: I18nText{"en": scheme}})
+			}
+		}
+		return options, nil
+	})
+}
+
+// countryOptionSource lists enabled countries by code
+func countryOptionSource(store db.Store) OptionSource {
+	return OptionSourceFunc(func(ctx context.Context, _ json.RawMessage, _ map[string]string) ([]Option, error) {
+		countries, err := store.GetEnabledCountries(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		options := make([]Option, len(countries))
+		for i, country := range countries {
+			options[i] = Option{Value: country.Code, Label: I18nText{"en": country.Name}}
+		}
+		return options, nil
+	})
+}
+
+// staticOptions returns the options listed in the source config: {"options": [...]}
+func staticOptions(_ context.Context, config json.RawMessage, _ map[string]string) ([]Option, error) {
+	var static struct {
+		Options []Option `json:"options"`
+	}
+	if err := json.Unmarshal(config, &static); err != nil {
+		return nil, fmt.Errorf("invalid static option config: %w", err)
+	}
+	if static.Options == nil {
+		return []Option{}, nil
+	}
+	return static.Options, nil
+}
+
+// queryOptionConfig is the config of a query source. ParamColumns maps filter params to the
+// columns they filter.
+type queryOptionConfig struct {
+	db.OptionQuery
+	ParamColumns map[string]string `json:"param_columns"`
+}
+
+// queryOptionSource reads an allow-listed table, see db.OptionQueryTables
+func queryOptionSource(store db.Store) OptionSource {
+	return OptionSourceFunc(func(ctx context.Context, config json.RawMessage, params map[string]string) ([]Option, error) {
+		var query queryOptionConfig
+		if err := json.Unmarshal(config, &query); err != nil {
+			return nil, fmt.Errorf("invalid query option config: %w", err)
+		}
+
+		filters := make(map[string]string, len(query.Filters)+len(params))
+		for column, value := range query.Filters {
+			filters[column] = value
+		}
+		for param, value := range params {
+			if column, ok := query.ParamColumns[param]; ok {
+				filters[column] = value
+			}
+		}
+		query.Filters = filters
+
+		rows, err := store.QueryOptionRows(ctx, query.OptionQuery)
+		if err != nil {
+			return nil, err
+		}
+
+		options := make([]Option, len(rows))
+		for i, row := range rows {
+			options[i] = Option{Value: row.Value, Label: I18nText{"en": row.Label}}
+		}
+		return options, nil
+	})
+}
+
+// queryOptionParams are the params of a query source, those with a column in ParamColumns
+func queryOptionParams(config json.RawMessage) []string {
+	var query queryOptionConfig
+	if err := json.Unmarshal(config, &query); err != nil {
+		return nil
+	}
+
+	params := make([]string, 0, len(query.ParamColumns))
+	for param := range query.ParamColumns {
+		params = append(params, param)
+	}
+	return params
+}
+
+// tableOptionParams are the params of the source a table config is read with. Other tables
+// are read as a query source without param columns.
+func tableOptionParams(lookup func(string) (OptionSource, bool)) func(json.RawMessage) []string {
+	return func(config json.RawMessage) []string {
+		var table struct {
+			Table string `json:"table"`
+		}
+		if err := json.Unmarshal(config, &table); err != nil {
+			return nil
+		}
+
+		switch table.Table {
+		case "countries", "banks", "currencies":
+			source, _ := lookup(table.Table)
+			if declared, ok := source.(OptionSourceParams); ok {
+				return declared.Params(config)
+			}
+		}
+		return nil
+	}
+}
+
+// tableOptionSource keeps sources configured as {"table": ...} working
+func tableOptionSource(lookup func(string) (OptionSource, bool)) OptionSource {
+	return OptionSourceFunc(func(ctx context.Context, config json.RawMessage, params map[string]string) ([]Option, error) {
+		var table struct {
+			Table      string `json:"table"`
+			ValueField string `json:"value_field"`
+			LabelField string `json:"label_field"`
+		}
+		if err := json.Unmarshal(config, &table); err != nil {
+			return nil, fmt.Errorf("invalid table option config: %w", err)
+		}
+
+		sourceType := OptionSourceQuery
+		switch table.Table {
+		case "countries", "banks", "currencies":
+			sourceType = table.Table
+		}
+		source, ok := lookup(sourceType)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownOptionSource, sourceType)
+		}
+		if sourceType != OptionSourceQuery {
+			return source.Options(ctx, config, params)
+		}
+
+		query, err := json.Marshal(db.OptionQuery{
+			Table:       table.Table,
+			ValueColumn: table.ValueField,
+			LabelColumn: table.LabelField,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return source.Options(ctx, query, params)
+	})
+}
+
+func paramMatches(params map[string]string, name, value string) bool {
+	want, ok := params[name]
+	return !ok || want == "" || strings.EqualFold(want, value)
+}
+
+// DependsOnFields lists the fields referenced by the filter params
+func (d *DynamicSource) DependsOnFields() []string {
+	var fields []string
+	for _, value := range d.FilterParams {
+		if match := filterParamReference.FindStringSubmatch(value); match != nil {
+			fields = append(fields, match[1])
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// resolveFilterParams fills field references in the filter params from the form values. It
+// reports false if a referenced field has no value yet.
+func resolveFilterParams(params map[string]string, values map[string]interface{}) (map[string]string, bool) {
+	resolved := make(map[string]string, len(params))
+	for name, value := range params {
+		match := filterParamReference.FindStringSubmatch(value)
+		if match == nil {
+			resolved[name] = value
+			continue
+		}
+
+		fieldValue, ok := values[match[1]]
+		if !ok || fieldValue == nil || fmt.Sprint(fieldValue) == "" {
+			return nil, false
+		}
+		resolved[name] = fmt.Sprint(fieldValue)
+	}
+	return resolved, true
+}
+
+type cachedOptions struct {
+	key     string
+	options []Option
+	expires time.Time
+}
+
+// optionCache keeps the options of each source and params for the source's CacheDuration. It
+// holds at most size entries, evicting the least recently used.
+type optionCache struct {
+	mu      sync.Mutex
+	size    int
+	swept   time.Time
+	order   *list.List // of *cachedOptions, most recently used first
+	entries map[string]*list.Element
+}
+
+func newOptionCache() *optionCache {
+	return &optionCache{
+		size:    maxOptionCacheEntries,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+func optionCacheKey(sourceID uuid.UUID, params map[string]string) string {
+	values := url.Values{}
+	for name, value := range params {
+		values.Set(name, value)
+	}
+	return sourceID.String() + "?" + values.Encode()
+}
+
+func (c *optionCache) get(key string, now time.Time) ([]Option, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*cachedOptions)
+	if now.After(entry.expires) {
+		c.remove(element)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry.options, true
+}
+
+func (c *optionCache) set(key string, options []Option, ttl time.Duration, now time.Time) {
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.swept) >= optionCacheSweepInterval {
+		c.removeExpired(now)
+		c.swept = now
+	}
+
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cachedOptions)
+		entry.options, entry.expires = options, now.Add(ttl)
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cachedOptions{key: key, options: options, expires: now.Add(ttl)})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// sweep removes the expired entries and returns how many there were
+func (c *optionCache) sweep(now time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.removeExpired(now)
+}
+
+func (c *optionCache) removeExpired(now time.Time) int {
+	removed := 0
+	for element := c.order.Front(); element != nil; {
+		next := element.Next()
+		if now.After(element.Value.(*cachedOptions).expires) {
+			c.remove(element)
+			removed++
+		}
+		element = next
+	}
+	return removed
+}
+
+func (c *optionCache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*cachedOptions).key)
+}
+
+// getDynamicOptions lists the options of a dynamic source for the given form values
+func (s *FormService) getDynamicOptions(ctx context.Context, source *DynamicSource, values map[string]interface{}) ([]Option, error) {
+	params, ready := resolveFilterParams(source.FilterParams, values)
+	if !ready {
+		// A dependent dropdown has no options until the fields it depends on are answered
+		return []Option{}, nil
+	}
+
+	var dynamicOption db.FormDynamicOption
+	var err error
+	if source.SourceID != uuid.Nil {
+		dynamicOption, err = s.store.GetDynamicOption(ctx, source.SourceID)
+	} else {
+		dynamicOption, err = s.store.GetDynamicOptionByName(ctx, source.SourceName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	optionSource, ok := s.optionSource(dynamicOption.SourceType)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownOptionSource, dynamicOption.SourceType)
+	}
+
+	params = sourceParams(optionSource, dynamicOption.SourceConfig, params)
+	key := optionCacheKey(dynamicOption.ID, params)
+	if options, ok := s.optionCache.get(key, time.Now()); ok {
+		return options, nil
+	}
+
+	options, err := optionSource.Options(ctx, dynamicOption.SourceConfig, params)
+	if err != nil {
+		return nil, err
+	}
+
+	s.optionCache.set(key, options, time.Duration(dynamicOption.CacheDuration)*time.Second, time.Now())
+	return options, nil
+}
+
+// resolveFieldOptions fills the options of dynamic fields from their sources, using the
+// submission data for dependent dropdowns
+func (s *FormService) resolveFieldOptions(ctx context.Context, fields []db.FormField, data json.RawMessage) {
+	values := map[string]interface{}{}
+	if len(data) > 0 {
+		_ = json.Unmarshal(data, &values)
+	}
+
+	for i, field := range fields {
+		if field.Options == nil {
+			continue
+		}
+
+		var options FieldOptions
+		if err := json.Unmarshal(field.Options, &options); err != nil || options.Type != "dynamic" || options.Dynamic == nil {
+			continue
+		}
+
+		dynamicOptions, err := s.getDynamicOptions(ctx, options.Dynamic, values)
+		if err != nil {
+			s.logger.Error(err, map[string]interface{}{
+				"field":  field.FieldName,
+				"source": options.Dynamic.SourceName,
+			})
+			continue
+		}
+
+		options.Static = dynamicOptions
+		options.Dynamic.DependsOn = options.Dynamic.DependsOnFields()
+		optionsJSON, _ := json.Marshal(options)
+		fields[i].Options = optionsJSON
+	}
+}
+
+// GetFieldOptions lists the options of a field of a form version, the current one when version
+// is 0. Values answer the fields a dependent dropdown depends on, e.g. the country of a bank field.
+func (s *FormService) GetFieldOptions(ctx context.Context, formID uuid.UUID, version int32, fieldName string, values map[string]interface{}) ([]Option, error) {
+	form, err := s.store.GetFormDefinition(ctx, formID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, fields, err := s.formStructure(ctx, form, version)
+	if err != nil {
+		return nil, err
+	}
+
+	field := s.findField(fields, fieldName)
+	if field == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	var options FieldOptions
+	if len(field.Options) > 0 {
+		if err := json.Unmarshal(field.Options, &options); err != nil {
+			return nil, fmt.Errorf("invalid options of field %s: %w", fieldName, err)
+		}
+	}
+
+	if options.Type != "dynamic" || options.Dynamic == nil {
+		if options.Static == nil {
+			return []Option{}, nil
+		}
+		return options.Static, nil
+	}
+
+	return s.getDynamicOptions(ctx, options.Dynamic, values)
+}
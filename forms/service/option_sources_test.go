@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+)
+
+// optionStore serves dynamic option definitions; other store methods are not used
+type optionStore struct {
+	db.Store
+	options map[string]db.FormDynamicOption
+}
+
+func (s optionStore) GetDynamicOptionByName(_ context.Context, name string) (db.FormDynamicOption, error) {
+	return s.options[name], nil
+}
+
+func newOptionTestService(options ...db.FormDynamicOption) *FormService {
+	store := optionStore{options: map[string]db.FormDynamicOption{}}
+	for _, option := range options {
+		store.options[option.Name] = option
+	}
+	return &FormService{
+		store:         store,
+		optionSources: map[string]OptionSource{OptionSourceStatic: OptionSourceFunc(staticOptions)},
+		optionCache:   newOptionCache(),
+	}
+}
+
+func TestStaticOptionSource(t *testing.T) {
+	s := newOptionTestService(db.FormDynamicOption{
+		ID:           uuid.New(),
+		Name:         "relationships",
+		SourceType:   OptionSourceStatic,
+		SourceConfig: json.RawMessage(`{"options":[{"value":"family","label":{"en":"Family"}}]}`),
+	})
+
+	options, err := s.getDynamicOptions(context.Background(), &DynamicSource{SourceName: "relationships"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, []Option{{Value: "family", Label: I18nText{"en": "Family"}}}, options)
+}
+
+func TestDependentOptionSource(t *testing.T) {
+	var calls []map[string]string
+	s := newOptionTestService(db.FormDynamicOption{
+		ID:            uuid.New(),
+		Name:          "banks",
+		SourceType:    "banks",
+		CacheDuration: 60,
+	})
+	s.RegisterOptionSource("banks", OptionSourceFunc(func(_ context.Context, _ json.RawMessage, params map[string]string) ([]Option, error) {
+		calls = append(calls, params)
+		return []Option{{Value: params["country"] + "-BANK"}}, nil
+	}))
+
+	source := &DynamicSource{
+		SourceName:   "banks",
+		FilterParams: map[string]string{"country": "{{bank_country}}", "type": "commercial"},
+	}
+	require.Equal(t, []string{"bank_country"}, source.DependsOnFields())
+
+	// No options until the country is chosen
+	options, err := s.getDynamicOptions(context.Background(), source, map[string]interface{}{})
+	require.NoError(t, err)
+	require.Empty(t, options)
+	require.Empty(t, calls)
+
+	options, err = s.getDynamicOptions(context.Background(), source, map[string]interface{}{"bank_country": "NG"})
+	require.NoError(t, err)
+	require.Equal(t, "NG-BANK", options[0].Value)
+	require.Equal(t, []map[string]string{{"country": "NG", "type": "commercial"}}, calls)
+
+	// Cached per country
+	_, err = s.getDynamicOptions(context.Background(), source, map[string]interface{}{"bank_country": "NG"})
+	require.NoError(t, err)
+	require.Len(t, calls, 1)
+
+	options, err = s.getDynamicOptions(context.Background(), source, map[string]interface{}{"bank_country": "GH"})
+	require.NoError(t, err)
+	require.Equal(t, "GH-BANK", options[0].Value)
+	require.Len(t, calls, 2)
+}
+
+func TestUnknownOptionSource(t *testing.T) {
+	s := newOptionTestService(db.FormDynamicOption{ID: uuid.New(), Name: "industries", SourceType: "api"})
+
+	_, err := s.getDynamicOptions(context.Background(), &DynamicSource{SourceName: "industries"}, nil)
+	require.ErrorIs(t, err, ErrUnknownOptionSource)
+}
+
+func TestOptionCacheKeysOnDeclaredParams(t *testing.T) {
+	var calls []map[string]string
+	s := newOptionTestService(db.FormDynamicOption{
+		ID:            uuid.New(),
+		Name:          "currencies",
+		SourceType:    OptionSourceCurrencies,
+		CacheDuration: 60,
+	})
+	s.RegisterOptionSource(OptionSourceCurrencies, withParams(OptionSourceFunc(func(_ context.Context, _ json.RawMessage, params map[string]string) ([]Option, error) {
+		calls = append(calls, params)
+		return []Option{{Value: "NGN"}}, nil
+	}), fixedParams("can_have_wallet")))
+
+	source := &DynamicSource{
+		SourceName:   "currencies",
+		FilterParams: map[string]string{"can_have_wallet": "true", "country": "{{country}}"},
+	}
+
+	// The source doesn't read the country, so each country shares one entry
+	for _, country := range []string{"NG", "GH", "KE"} {
+		_, err := s.getDynamicOptions(context.Background(), source, map[string]interface{}{"country": country})
+		require.NoError(t, err)
+	}
+	require.Equal(t, []map[string]string{{"can_have_wallet": "true"}}, calls)
+}
+
+func TestOptionCacheEviction(t *testing.T) {
+	now := time.Now()
+	cache := newOptionCache()
+	cache.size = 2
+
+	cache.set("a", []Option{{Value: "a"}}, time.Minute, now)
+	cache.set("b", []Option{{Value: "b"}}, time.Hour, now)
+	_, ok := cache.get("a", now)
+	require.True(t, ok)
+
+	// b is the least recently used
+	cache.set("c", []Option{{Value: "c"}}, time.Hour, now)
+	_, ok = cache.get("b", now)
+	require.False(t, ok)
+
+	require.Equal(t, 1, cache.sweep(now.Add(2*time.Minute)))
+	_, ok = cache.get("c", now)
+	require.True(t, ok)
+	require.Len(t, cache.entries, 1)
+
+	// Adding entries drops the expired ones once the sweep interval has passed
+	later := now.Add(2 * time.Hour)
+	cache.set("d", []Option{{Value: "d"}}, time.Hour, later)
+	require.Len(t, cache.entries, 1)
+	_, ok = cache.get("d", later)
+	require.True(t, ok)
+}
+
+// Sources, handlers and hooks can be registered while requests use them
+func TestRegistryConcurrentUse(t *testing.T) {
+	s := &FormService{
+		eventHandlers:   map[string]EventHandler{},
+		optionSources:   map[string]OptionSource{},
+		validationHooks: map[string]ValidationHook{},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("source_%d", i)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			s.RegisterOptionSource(name, OptionSourceFunc(staticOptions))
+			s.RegisterEventHandler(name, nil)
+			s.RegisterValidationHook(name, nil)
+		}()
+		go func() {
+			defer wg.Done()
+			s.optionSource(name)
+			s.eventHandler(name)
+			s.ValidationHooks()
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, s.ValidationHooks(), 10)
+	_, ok := s.optionSource("source_3")
+	require.True(t, ok)
+}
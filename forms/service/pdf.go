@@ -0,0 +1,293 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // thumbnails of gif uploads
+	"image/jpeg"
+	_ "image/png" // thumbnails of png uploads
+	"io"
+	"math"
+	"strings"
+)
+
+// pdfDocument is a minimal PDF writer for submission exports: A4 pages of wrapped Helvetica
+// text and JPEG images. Text is WinAnsi encoded, so characters it lacks print as "?".
+type pdfDocument struct {
+	pages  []*pdfPage
+	images []pdfImage
+	y      float64
+}
+
+type pdfPage struct {
+	content bytes.Buffer
+	images  []int
+}
+
+type pdfImage struct {
+	data          []byte
+	width, height int
+}
+
+const (
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+	pdfMargin     = 50.0
+)
+
+// maxThumbnailPixels refuses to decode images that would take too much memory
+const maxThumbnailPixels = 40_000_000
+
+func newPDFDocument() *pdfDocument {
+	d := &pdfDocument{}
+	d.addPage()
+	return d
+}
+
+func (d *pdfDocument) addPage() {
+	d.pages = append(d.pages, &pdfPage{})
+	d.y = pdfPageHeight - pdfMargin
+}
+
+func (d *pdfDocument) page() *pdfPage {
+	return d.pages[len(d.pages)-1]
+}
+
+// ensureSpace starts a new page unless height fits above the bottom margin
+func (d *pdfDocument) ensureSpace(height float64) {
+	if d.y-height < pdfMargin {
+		d.addPage()
+	}
+}
+
+// Text writes a paragraph wrapped to the page width
+func (d *pdfDocument) Text(text string, size float64, bold bool, indent float64) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+
+	// Helvetica averages about half an em per character
+	maxChars := int((pdfPageWidth - 2*pdfMargin - indent) / (size * 0.5))
+	lineHeight := size * 1.4
+
+	for _, line := range wrapPDFText(text, maxChars) {
+		d.ensureSpace(lineHeight)
+		d.y -= lineHeight
+		fmt.Fprintf(&d.page().content, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n",
+			font, size, pdfMargin+indent, d.y, escapePDFText(line))
+	}
+}
+
+// Space leaves a vertical gap
+func (d *pdfDocument) Space(height float64) {
+	d.y -= height
+}
+
+// Rule draws a line across the page
+func (d *pdfDocument) Rule() {
+	d.ensureSpace(10)
+	d.y -= 5
+	fmt.Fprintf(&d.page().content, "0.5 w %.2f %.2f m %.2f %.2f l S\n", pdfMargin, d.y, pdfPageWidth-pdfMargin, d.y)
+	d.y -= 5
+}
+
+// Image draws a JPEG scaled down to fit a square of maxSize points
+func (d *pdfDocument) Image(data []byte, width, height int, maxSize, indent float64) {
+	w, h := float64(width), float64(height)
+	if scale := maxSize / math.Max(w, h); scale < 1 {
+		w, h = w*scale, h*scale
+	}
+
+	d.ensureSpace(h + 6)
+	d.y -= h + 6
+
+	d.images = append(d.images, pdfImage{data: data, width: width, height: height})
+	index := len(d.images) - 1
+	page := d.page()
+	page.images = append(page.images, index)
+	fmt.Fprintf(&page.content, "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", w, h, pdfMargin+indent, d.y, index)
+}
+
+// Bytes renders the document. Objects are the catalog, the page tree, the two fonts, the
+// images and then each page followed by its content stream.
+func (d *pdfDocument) Bytes() []byte {
+	const firstImage = 5
+	firstPage := firstImage + len(d.images)
+	total := firstPage + 2*len(d.pages)
+
+	var buf bytes.Buffer
+	offsets := make([]int, total)
+	begin := func(id int) {
+		offsets[id] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n", id)
+	}
+	stream := func(dict string, data []byte) {
+		fmt.Fprintf(&buf, "<< %s/Length %d >>\nstream\n", dict, len(data))
+		buf.Write(data)
+		buf.WriteString("\nendstream\nendobj\n")
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	begin(1)
+	buf.WriteString("<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	begin(2)
+	fmt.Fprintf(&buf, "<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", strings.Join(kids, " "), len(d.pages))
+
+	for i, font := range []string{"Helvetica", "Helvetica-Bold"} {
+		begin(3 + i)
+		fmt.Fprintf(&buf, "<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>\nendobj\n", font)
+	}
+
+	for i, img := range d.images {
+		begin(firstImage + i)
+		stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode ",
+			img.width, img.height), img.data)
+	}
+
+	for i, page := range d.pages {
+		id := firstPage + 2*i
+
+		var xobjects strings.Builder
+		for _, index := range page.images {
+			fmt.Fprintf(&xobjects, "/Im%d %d 0 R ", index, firstImage+index)
+		}
+
+		begin(id)
+		fmt.Fprintf(&buf, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> /XObject << %s>> >> /Contents %d 0 R >>\nendobj\n",
+			pdfPageWidth, pdfPageHeight, xobjects.String(), id+1)
+
+		begin(id + 1)
+		stream("", page.content.Bytes())
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", total)
+	for _, offset := range offsets[1:] {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", total, xref)
+
+	return buf.Bytes()
+}
+
+// wrapPDFText splits text into lines of at most maxChars characters, breaking between words
+// where it can
+func wrapPDFText(text string, maxChars int) []string {
+	if maxChars < 1 {
+		maxChars = 1
+	}
+
+	var lines []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for len([]rune(word)) > maxChars {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				runes := []rune(word)
+				lines = append(lines, string(runes[:maxChars]))
+				word = string(runes[maxChars:])
+			}
+
+			switch {
+			case line == "":
+				line = word
+			case len([]rune(line))+1+len([]rune(word)) <= maxChars:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// escapePDFText encodes text as a WinAnsi PDF string body
+func escapePDFText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\t':
+			b.WriteByte(' ')
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			// Latin-1 matches WinAnsi outside 0x80-0x9f
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// pdfThumbnail decodes a jpeg, png or gif image and re-encodes it as an RGB JPEG no larger
+// than size pixels on either side
+func pdfThumbnail(data []byte, size int) ([]byte, int, int, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxThumbnailPixels {
+		return nil, 0, 0, errors.New("image dimensions are not supported")
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, int(math.Max(1, float64(height*size)/float64(bounds.Dx())))
+		} else {
+			width, height = int(math.Max(1, float64(width*size)/float64(bounds.Dy()))), size
+		}
+	}
+
+	// Nearest neighbour is plenty for a preview; transparency is flattened onto white
+	thumbnail := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(thumbnail, thumbnail.Bounds(), image.White, image.Point{}, draw.Src)
+	scaled := image.NewRGBA(thumbnail.Bounds())
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			scaled.Set(x, y, src.At(bounds.Min.X+x*bounds.Dx()/width, bounds.Min.Y+y*bounds.Dy()/height))
+		}
+	}
+	draw.Draw(thumbnail, thumbnail.Bounds(), scaled, image.Point{}, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumbnail, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, 0, 0, err
+	}
+	return buf.Bytes(), width, height, nil
+}
+
+// readThumbnail reads an upload and returns its thumbnail, reading at most limit bytes
+func readThumbnail(r io.Reader, limit int64, size int) ([]byte, int, int, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if int64(len(data)) > limit {
+		return nil, 0, 0, errors.New("image is too large to preview")
+	}
+	return pdfThumbnail(data, size)
+}
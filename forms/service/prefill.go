@@ -0,0 +1,442 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+)
+
+// Prefill sources, the data a field can be prefilled from, named like user.first_name
+const (
+	PrefillSourceUser     = "user"
+	PrefillSourceBusiness = "business"
+	PrefillSourceIdentity = "identity_verification_data"
+	PrefillSourceUserMeta = "user_meta"
+)
+
+// PrefillConfig is where a field takes its initial value from. Fields locking verified values
+// show them read-only, and submissions can't change them.
+type PrefillConfig struct {
+	Source       string `json:"source"`
+	LockVerified bool   `json:"lock_verified,omitempty"`
+}
+
+// PrefilledField tells renderers where the value of a field came from
+type PrefilledField struct {
+	Source   string      `json:"source"`
+	Verified bool        `json:"verified"`
+	Locked   bool        `json:"locked"`
+	Value    interface{} `json:"-"`
+}
+
+// PrefillSubject is the data of a user fields are prefilled from. Sources that weren't loaded,
+// or that the user doesn't have, are nil.
+type PrefillSubject struct {
+	User     *db.User
+	Business *db.Business
+	Identity *db.IdentityVerificationDatum
+	// IdentityVerified is whether Veriff approved the identity verification data
+	IdentityVerified bool
+	Meta             map[string]string
+}
+
+// prefillAttribute reads a value of a source. Verifiable values count as verified when their
+// source is, e.g. names once KYC is verified but not the phone number.
+type prefillAttribute struct {
+	value      func(PrefillSubject) string
+	verifiable bool
+}
+
+type prefillSource struct {
+	attributes map[string]prefillAttribute
+	verified   func(PrefillSubject) bool
+}
+
+var prefillSources = map[string]prefillSource{
+	PrefillSourceUser: {
+		attributes: map[string]prefillAttribute{
+			"email":         {value: func(s PrefillSubject) string { return s.User.Email }},
+			"phone":         {value: func(s PrefillSubject) string { return s.User.Phone }},
+			"country_code":  {value: func(s PrefillSubject) string { return s.User.CountryCode }},
+			"account_type":  {value: func(s PrefillSubject) string { return s.User.AccountType }},
+			"first_name":    {value: func(s PrefillSubject) string { return s.User.FirstName }, verifiable: true},
+			"middle_name":   {value: func(s PrefillSubject) string { return s.User.MiddleName }, verifiable: true},
+			"last_name":     {value: func(s PrefillSubject) string { return s.User.LastName }, verifiable: true},
+			"bvn":           {value: func(s PrefillSubject) string { return s.User.Bvn }, verifiable: true},
+			"business_name": {value: func(s PrefillSubject) string { return s.User.BusinessName }},
+			"address":       {value: func(s PrefillSubject) string { return s.User.Address }},
+			"city":          {value: func(s PrefillSubject) string { return s.User.City }},
+			"state":         {value: func(s PrefillSubject) string { return s.User.State }},
+			"zipcode":       {value: func(s PrefillSubject) string { return s.User.Zipcode }},
+		},
+		verified: func(s PrefillSubject) bool { return s.User.KycVerified == "verified" },
+	},
+	PrefillSourceBusiness: {
+		attributes: map[string]prefillAttribute{
+			"name":                {value: func(s PrefillSubject) string { return s.Business.Name }, verifiable: true},
+			"registration_number": {value: func(s PrefillSubject) string { return s.Business.RegistrationNumber }, verifiable: true},
+			"registration_date":   {value: func(s PrefillSubject) string { return s.Business.RegistrationDate }, verifiable: true},
+			"country":             {value: func(s PrefillSubject) string { return s.Business.Country }, verifiable: true},
+			"address1":            {value: func(s PrefillSubject) string { return s.Business.Address1 }, verifiable: true},
+			"address2":            {value: func(s PrefillSubject) string { return s.Business.Address2 }, verifiable: true},
+			"city":                {value: func(s PrefillSubject) string { return s.Business.City }, verifiable: true},
+			"state":               {value: func(s PrefillSubject) string { return s.Business.State }, verifiable: true},
+			"post_code":           {value: func(s PrefillSubject) string { return s.Business.PostCode }, verifiable: true},
+			"business_nature":     {value: func(s PrefillSubject) string { return s.Business.BusinessNature }},
+			"business_category":   {value: func(s PrefillSubject) string { return s.Business.BusinessCategory }},
+			"trading_address":     {value: func(s PrefillSubject) string { return s.Business.TradingAddress }},
+			"website":             {value: func(s PrefillSubject) string { return s.Business.Website }},
+			"phone":               {value: func(s PrefillSubject) string { return s.Business.Phone }},
+			"email":               {value: func(s PrefillSubject) string { return s.Business.Email }},
+			"contact_name":        {value: func(s PrefillSubject) string { return s.Business.ContactName }},
+		},
+		verified: func(s PrefillSubject) bool {
+			return s.Business.ApprovalStatus.String == string(db.BusinessStatusApproved)
+		},
+	},
+	PrefillSourceIdentity: {
+		attributes: map[string]prefillAttribute{
+			"first_name":           {value: func(s PrefillSubject) string { return s.Identity.FirstName.String }, verifiable: true},
+			"last_name":            {value: func(s PrefillSubject) string { return s.Identity.LastName.String }, verifiable: true},
+			"gender":               {value: func(s PrefillSubject) string { return s.Identity.Gender.String }, verifiable: true},
+			"date_of_birth":        {value: func(s PrefillSubject) string { return prefillDate(s.Identity.DateOfBirth) }, verifiable: true},
+			"address":              {value: func(s PrefillSubject) string { return s.Identity.Address.String }, verifiable: true},
+			"city":                 {value: func(s PrefillSubject) string { return s.Identity.City.String }, verifiable: true},
+			"house_no":             {value: func(s PrefillSubject) string { return s.Identity.HouseNo.String }, verifiable: true},
+			"document_type":        {value: func(s PrefillSubject) string { return s.Identity.DocumentType.String }, verifiable: true},
+			"document_number":      {value: func(s PrefillSubject) string { return s.Identity.DocumentNumber.String }, verifiable: true},
+			"document_country":     {value: func(s PrefillSubject) string { return s.Identity.DocumentCountry.String }, verifiable: true},
+			"document_valid_from":  {value: func(s PrefillSubject) string { return prefillDate(s.Identity.DocumentValidFrom) }, verifiable: true},
+			"document_valid_until": {value: func(s PrefillSubject) string { return prefillDate(s.Identity.DocumentValidUntil) }, verifiable: true},
+		},
+		verified: func(s PrefillSubject) bool { return s.IdentityVerified },
+	},
+}
+
+// userMetaKey matches the keys of user_meta, which are set by users and forms and never verified
+var userMetaKey = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+func prefillDate(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.Format(dateLayout)
+}
+
+// PrefillSourceInfo describes a source for form builders
+type PrefillSourceInfo struct {
+	Source     string   `json:"source"`
+	Attributes []string `json:"attributes,omitempty"`
+	// Verifiable are the attributes that can be locked once their source is verified
+	Verifiable []string `json:"verifiable,omitempty"`
+}
+
+// GetPrefillSources lists the sources fields can be prefilled from. Any key of user_meta can
+// be used, as user_meta.<key>.
+func GetPrefillSources() []PrefillSourceInfo {
+	names := make([]string, 0, len(prefillSources))
+	for name := range prefillSources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	infos := make([]PrefillSourceInfo, 0, len(names)+1)
+	for _, name := range names {
+		info := PrefillSourceInfo{Source: name}
+		for attribute, a := range prefillSources[name].attributes {
+			info.Attributes = append(info.Attributes, attribute)
+			if a.verifiable {
+				info.Verifiable = append(info.Verifiable, attribute)
+			}
+		}
+		sort.Strings(info.Attributes)
+		sort.Strings(info.Verifiable)
+		infos = append(infos, info)
+	}
+	return append(infos, PrefillSourceInfo{Source: PrefillSourceUserMeta})
+}
+
+// ParsePrefillConfig parses the prefill of a field, returning nil when it has none
+func ParsePrefillConfig(raw json.RawMessage) (*PrefillConfig, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var config PrefillConfig
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil, fmt.Errorf("invalid prefill: %w", err)
+	}
+	if config.Source == "" {
+		return nil, nil
+	}
+	return &config, nil
+}
+
+// ValidatePrefillConfig checks that a field can be prefilled from a known source, and only
+// locks values that can be verified
+func ValidatePrefillConfig(fieldType string, config PrefillConfig) error {
+	if IsUploadFieldType(fieldType) || IsGroupFieldType(fieldType) {
+		return fmt.Errorf("%s fields can't be prefilled", fieldType)
+	}
+
+	name, attribute, _ := strings.Cut(config.Source, ".")
+	if name == PrefillSourceUserMeta {
+		if !userMetaKey.MatchString(attribute) {
+			return fmt.Errorf("source must be like user_meta.<key>")
+		}
+		if config.LockVerified {
+			return fmt.Errorf("user_meta values are never verified and can't be locked")
+		}
+		return nil
+	}
+
+	source, ok := prefillSources[name]
+	if !ok {
+		return fmt.Errorf("unknown source %q", name)
+	}
+	a, ok := source.attributes[attribute]
+	if !ok {
+		return fmt.Errorf("unknown attribute %q of %s", attribute, name)
+	}
+	if config.LockVerified && !a.verifiable {
+		return fmt.Errorf("%s is never verified and can't be locked", config.Source)
+	}
+	return nil
+}
+
+// prefillConfigs returns the prefill of the fields that have one, by field name. Fields whose
+// prefill doesn't parse aren't prefilled.
+func prefillConfigs(fields []db.FormField) map[string]PrefillConfig {
+	configs := make(map[string]PrefillConfig)
+	for _, field := range fields {
+		config, err := ParsePrefillConfig(field.Prefill)
+		if err != nil || config == nil {
+			continue
+		}
+		configs[field.FieldName] = *config
+	}
+	return configs
+}
+
+// resolvePrefill reads the values of prefilled fields from a subject. Fields whose source
+// wasn't loaded or is empty are left out.
+func resolvePrefill(fields []db.FormField, subject PrefillSubject) map[string]PrefilledField {
+	configs := prefillConfigs(fields)
+	prefilled := make(map[string]PrefilledField, len(configs))
+	for _, field := range fields {
+		config, ok := configs[field.FieldName]
+		if !ok {
+			continue
+		}
+
+		value, verified := prefillValue(config.Source, subject)
+		if value == "" {
+			continue
+		}
+		prefilled[field.FieldName] = PrefilledField{
+			Source:   config.Source,
+			Verified: verified,
+			Locked:   verified && config.LockVerified,
+			Value:    normalizeFieldValue(field, value),
+		}
+	}
+	return prefilled
+}
+
+// prefillValue reads a source of a subject and whether the value is verified
+func prefillValue(sourceName string, subject PrefillSubject) (string, bool) {
+	name, attribute, _ := strings.Cut(sourceName, ".")
+	if name == PrefillSourceUserMeta {
+		return subject.Meta[attribute], false
+	}
+
+	source, ok := prefillSources[name]
+	if !ok || !prefillLoaded(name, subject) {
+		return "", false
+	}
+	a, ok := source.attributes[attribute]
+	if !ok {
+		return "", false
+	}
+	return a.value(subject), a.verifiable && source.verified(subject)
+}
+
+func prefillLoaded(name string, subject PrefillSubject) bool {
+	switch name {
+	case PrefillSourceUser:
+		return subject.User != nil
+	case PrefillSourceBusiness:
+		return subject.Business != nil
+	case PrefillSourceIdentity:
+		return subject.Identity != nil
+	}
+	return false
+}
+
+// prefillSubject loads the sources the configs read from
+func (s *FormService) prefillSubject(ctx context.Context, userID uuid.UUID, configs map[string]PrefillConfig) (PrefillSubject, error) {
+	needed := make(map[string]bool)
+	for _, config := range configs {
+		name, _, _ := strings.Cut(config.Source, ".")
+		needed[name] = true
+	}
+
+	var subject PrefillSubject
+	if needed[PrefillSourceUser] {
+		user, err := s.store.GetUser(ctx, userID)
+		if err != nil {
+			return subject, fmt.Errorf("failed to get user: %w", err)
+		}
+		subject.User = &user
+	}
+
+	if needed[PrefillSourceBusiness] {
+		business, err := s.store.GetBusinessCreatedByUser(ctx, userID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return subject, fmt.Errorf("failed to get business: %w", err)
+		}
+		if err == nil {
+			subject.Business = &business
+		}
+	}
+
+	if needed[PrefillSourceIdentity] {
+		identity, err := s.store.GetUserVerificationDataByProvider(ctx, db.GetUserVerificationDataByProviderParams{
+			UserID:   userID,
+			Provider: db.IdentityVerifiedTypeVeriff,
+		})
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return subject, fmt.Errorf("failed to get identity verification data: %w", err)
+		}
+		if err == nil {
+			subject.Identity = &identity
+
+			meta, err := s.store.GetUserMetas(ctx, userID)
+			if err != nil {
+				return subject, fmt.Errorf("failed to get identity verification status: %w", err)
+			}
+			subject.IdentityVerified = meta.IdentityVerified &&
+				meta.IdentityVerificationStatus == db.IdentityVerificationStatusApproved
+		}
+	}
+
+	if needed[PrefillSourceUserMeta] {
+		meta, err := s.store.ListUserMetaValues(ctx, userID)
+		if err != nil {
+			return subject, fmt.Errorf("failed to get user meta: %w", err)
+		}
+		// Values persisted from encrypted form fields are prefilled in plaintext
+		for key, value := range meta {
+			if !db.IsEncryptedFieldValue(value) {
+				continue
+			}
+			if plaintext, err := db.DecryptFieldValue(value); err == nil {
+				meta[key] = plaintext
+			} else {
+				delete(meta, key)
+			}
+		}
+		subject.Meta = meta
+	}
+
+	return subject, nil
+}
+
+// prefillForm resolves the prefilled fields of a form for a user. Prefill is a convenience,
+// so a source that fails to load leaves the fields empty rather than failing the form.
+func (s *FormService) prefillForm(ctx context.Context, userID uuid.UUID, fields []db.FormField) map[string]PrefilledField {
+	configs := prefillConfigs(fields)
+	if len(configs) == 0 {
+		return nil
+	}
+
+	subject, err := s.prefillSubject(ctx, userID, configs)
+	if err != nil {
+		s.logger.Error(err, map[string]interface{}{
+			"message": "failed to load prefill data",
+			"user_id": userID,
+		})
+	}
+
+	prefilled := resolvePrefill(fields, subject)
+	for i, field := range fields {
+		if prefilled[field.FieldName].Locked {
+			fields[i].IsReadonly = true
+		}
+	}
+	return prefilled
+}
+
+// mergePrefill fills answers with prefilled values. Answers the user gave are kept, except
+// for locked fields, which always hold the verified value.
+func mergePrefill(data map[string]interface{}, prefilled map[string]PrefilledField) map[string]interface{} {
+	if len(prefilled) == 0 {
+		return data
+	}
+	if data == nil {
+		data = make(map[string]interface{}, len(prefilled))
+	}
+	for name, field := range prefilled {
+		if current, ok := data[name]; ok && current != nil && current != "" && !field.Locked {
+			continue
+		}
+		data[name] = field.Value
+	}
+	return data
+}
+
+// prefillData merges prefilled values into the saved answers of a form
+func prefillData(raw json.RawMessage, prefilled map[string]PrefilledField) json.RawMessage {
+	if len(prefilled) == 0 {
+		return raw
+	}
+	var data map[string]interface{}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return raw
+		}
+	}
+	encoded, err := json.Marshal(mergePrefill(data, prefilled))
+	if err != nil {
+		return raw
+	}
+	return encoded
+}
+
+// lockPrefilledValues overwrites the answers of locked fields with the verified values, so
+// they can't be changed by editing the request. Unlike prefillForm, it fails when the data
+// can't be loaded, since the values couldn't be checked.
+func (s *FormService) lockPrefilledValues(ctx context.Context, userID uuid.UUID, fields []db.FormField, data map[string]interface{}) (map[string]interface{}, error) {
+	configs := prefillConfigs(fields)
+	for name, config := range configs {
+		if !config.LockVerified {
+			delete(configs, name)
+		}
+	}
+	if len(configs) == 0 {
+		return data, nil
+	}
+
+	subject, err := s.prefillSubject(ctx, userID, configs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prefill data: %w", err)
+	}
+
+	for name, field := range resolvePrefill(fields, subject) {
+		if !field.Locked {
+			continue
+		}
+		if data == nil {
+			data = make(map[string]interface{})
+		}
+		data[name] = field.Value
+	}
+	return data, nil
+}
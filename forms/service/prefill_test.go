@@ -0,0 +1,109 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+)
+
+func prefilledField(name, fieldType, prefill string) db.FormField {
+	return db.FormField{FieldName: name, FieldType: fieldType, Prefill: json.RawMessage(prefill)}
+}
+
+func testPrefillSubject() PrefillSubject {
+	return PrefillSubject{
+		User: &db.User{
+			FirstName:   "Ada",
+			LastName:    "Obi",
+			Phone:       "+2348012345678",
+			KycVerified: "verified",
+		},
+		Business: &db.Business{
+			Name:               "Acme Ltd",
+			RegistrationNumber: "RC123456",
+			ApprovalStatus:     sql.NullString{String: "pending", Valid: true},
+		},
+		Identity: &db.IdentityVerificationDatum{
+			FirstName:   sql.NullString{String: "Ada", Valid: true},
+			DateOfBirth: sql.NullTime{Time: time.Date(1990, 4, 12, 0, 0, 0, 0, time.UTC), Valid: true},
+		},
+		IdentityVerified: true,
+		Meta:             map[string]string{"industry": "fintech"},
+	}
+}
+
+func TestResolvePrefill(t *testing.T) {
+	fields := []db.FormField{
+		prefilledField("first_name", "text", `{"source":"user.first_name","lock_verified":true}`),
+		prefilledField("phone", "text", `{"source":"user.phone"}`),
+		prefilledField("company", "text", `{"source":"business.name","lock_verified":true}`),
+		prefilledField("dob", FieldTypeDate, `{"source":"identity_verification_data.date_of_birth","lock_verified":true}`),
+		prefilledField("industry", "text", `{"source":"user_meta.industry"}`),
+		prefilledField("middle_name", "text", `{"source":"user.middle_name"}`),
+		prefilledField("notes", "text", `{}`),
+		{FieldName: "website", FieldType: "text"},
+	}
+
+	prefilled := resolvePrefill(fields, testPrefillSubject())
+	require.Equal(t, map[string]PrefilledField{
+		"first_name": {Source: "user.first_name", Verified: true, Locked: true, Value: "Ada"},
+		"phone":      {Source: "user.phone", Value: "+2348012345678"},
+		"company":    {Source: "business.name", Value: "Acme Ltd"},
+		"dob":        {Source: "identity_verification_data.date_of_birth", Verified: true, Locked: true, Value: "1990-04-12"},
+		"industry":   {Source: "user_meta.industry", Value: "fintech"},
+	}, prefilled)
+
+	// Sources that weren't loaded prefill nothing
+	require.Empty(t, resolvePrefill(fields[2:4], PrefillSubject{}))
+}
+
+func TestMergePrefill(t *testing.T) {
+	prefilled := map[string]PrefilledField{
+		"first_name": {Locked: true, Value: "Ada"},
+		"phone":      {Value: "+2348012345678"},
+		"industry":   {Value: "fintech"},
+	}
+
+	data := mergePrefill(map[string]interface{}{
+		"first_name": "Adaa",
+		"phone":      "+2348099999999",
+		"industry":   "",
+	}, prefilled)
+	require.Equal(t, map[string]interface{}{
+		"first_name": "Ada",
+		"phone":      "+2348099999999",
+		"industry":   "fintech",
+	}, data)
+
+	require.JSONEq(t, `{"first_name":"Ada","phone":"+2348012345678","industry":"fintech"}`, string(prefillData(nil, prefilled)))
+	require.JSONEq(t, `{"a":1}`, string(prefillData(json.RawMessage(`{"a":1}`), nil)))
+}
+
+func TestValidatePrefillConfig(t *testing.T) {
+	require.NoError(t, ValidatePrefillConfig("text", PrefillConfig{Source: "user.first_name", LockVerified: true}))
+	require.NoError(t, ValidatePrefillConfig(FieldTypeDate, PrefillConfig{Source: "identity_verification_data.date_of_birth", LockVerified: true}))
+	require.NoError(t, ValidatePrefillConfig("text", PrefillConfig{Source: "user_meta.industry"}))
+
+	for _, tc := range []struct {
+		fieldType string
+		config    PrefillConfig
+	}{
+		{"text", PrefillConfig{Source: "wallet.balance"}},
+		{"text", PrefillConfig{Source: "user.password"}},
+		{"text", PrefillConfig{Source: "user"}},
+		{"text", PrefillConfig{Source: "user.phone", LockVerified: true}},
+		{"text", PrefillConfig{Source: "user_meta.Industry"}},
+		{"text", PrefillConfig{Source: "user_meta.industry", LockVerified: true}},
+		{"file", PrefillConfig{Source: "user.first_name"}},
+	} {
+		require.Error(t, ValidatePrefillConfig(tc.fieldType, tc.config), tc.config.Source)
+	}
+
+	config, err := ParsePrefillConfig(json.RawMessage(`{}`))
+	require.NoError(t, err)
+	require.Nil(t, config)
+}
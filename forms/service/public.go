@@ -0,0 +1,597 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/bits"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/mailer"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// Defaults of public forms
+const (
+	DefaultPublicEmailField = "email"
+	DefaultHoneypotField    = "website"
+
+	// MaxChallengeDifficulty keeps proofs of work solvable by phones in a few seconds
+	MaxChallengeDifficulty = 22
+
+	// PublicVerificationTemplate is the email with the link that verifies a public submission
+	PublicVerificationTemplate = "public_form_verification"
+)
+
+const (
+	publicChallengeTTL = 30 * time.Minute
+	// Submissions made sooner after the challenge was issued than a person could fill a form are bots
+	publicChallengeMinAge = 3 * time.Second
+	publicVerificationTTL = 48 * time.Hour
+	publicRateWindow      = time.Hour
+)
+
+var (
+	ErrInvalidChallenge    = errors.New("invalid or expired challenge")
+	ErrPublicRateLimited   = errors.New("too many submissions, try again later")
+	ErrPublicFormNotPublic = errors.New("form is not public")
+	// ErrPublicFormsDisabled is returned for every public form while FORM_CHALLENGE_KEY is unset
+	ErrPublicFormsDisabled = errors.New("public forms are disabled, FORM_CHALLENGE_KEY is not set")
+)
+
+// PublicForm is what anonymous visitors get to render a public form. Challenge must be solved and
+// sent back with the submission; HoneypotField must be rendered hidden and left empty.
+type PublicForm struct {
+	ID            uuid.UUID                `json:"id"`
+	Slug          string                   `json:"slug"`
+	Name          string                   `json:"name"`
+	Description   string                   `json:"description"`
+	Version       int32                    `json:"version"`
+	IsMultiStep   bool                     `json:"is_multi_step"`
+	Steps         []db.FormStep            `json:"steps"`
+	Fields        []db.FormField           `json:"fields"`
+	FieldMeta     map[string]FieldMetadata `json:"field_meta,omitempty"`
+	HoneypotField string                   `json:"honeypot_field"`
+	Challenge     PublicChallenge          `json:"challenge"`
+	Language      string                   `json:"language,omitempty"`
+}
+
+// PublicChallenge is a signed token issued with a public form. With a difficulty, the solution is
+// a string such that the SHA-256 of "<token>:<solution>" starts with that many zero bits.
+type PublicChallenge struct {
+	Token      string    `json:"token"`
+	Difficulty int32     `json:"difficulty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// PublicSubmissionInput is an anonymous submission of a public form, referenced by ID or slug
+type PublicSubmissionInput struct {
+	FormRef   string                 `json:"form_ref"`
+	Data      map[string]interface{} `json:"data"`
+	Challenge string                 `json:"challenge"`
+	Solution  string                 `json:"solution"`
+	IPAddress string                 `json:"ip_address"`
+	UserAgent string                 `json:"user_agent"`
+}
+
+// PublicSubmissionResult tells visitors whether they need to check their email
+type PublicSubmissionResult struct {
+	ID                   uuid.UUID `json:"id"`
+	Status               string    `json:"status"`
+	VerificationRequired bool      `json:"verification_required"`
+}
+
+// publicForm returns a form referenced by ID or slug with its public access settings, as long as
+// it is active and public
+func (s *FormService) publicForm(ctx context.Context, ref string) (db.FormDefinition, db.FormPublicAccess, error) {
+	var form db.FormDefinition
+	if !s.publicFormsEnabled() {
+		return form, db.FormPublicAccess{}, ErrPublicFormsDisabled
+	}
+
+	var err error
+	if id, parseErr := uuid.Parse(ref); parseErr == nil {
+		form, err = s.store.GetFormDefinition(ctx, id)
+	} else {
+		form, err = s.store.GetFormDefinitionBySlug(ctx, ref)
+	}
+	if err != nil {
+		return form, db.FormPublicAccess{}, err
+	}
+	if !form.IsActive {
+		return form, db.FormPublicAccess{}, ErrFormNotActive
+	}
+
+	access, err := s.store.GetFormPublicAccess(ctx, form.ID)
+	if errors.Is(err, db.ErrFormPublicAccessNotFound) || (err == nil && !access.Enabled) {
+		return form, access, ErrPublicFormNotPublic
+	}
+	return form, access, err
+}
+
+// GetPublicForm returns a public form for rendering, with a fresh challenge
+func (s *FormService) GetPublicForm(ctx context.Context, ref string) (*PublicForm, error) {
+	form, access, err := s.publicForm(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	steps, fields, err := s.formStructure(ctx, form, form.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	challenge, err := s.issueChallenge(form.ID, access.ChallengeDifficulty, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PublicForm{
+		ID:            form.ID,
+		Slug:          form.Slug,
+		Name:          form.Name,
+		Description:   form.Description,
+		Version:       form.Version,
+		IsMultiStep:   form.IsMultiStep,
+		Steps:         steps,
+		Fields:        fields,
+		FieldMeta:     s.fieldMetadata(fields, now),
+		HoneypotField: honeypotField(access),
+		Challenge:     challenge,
+	}, nil
+}
+
+// SubmitPublicForm saves an anonymous submission once it passes the honeypot, the challenge, the
+// rate limits and the checks of its email. Unless the form skips verification, the submission
+// only counts, and fires the submitted event, once the link emailed to the visitor is opened.
+func (s *FormService) SubmitPublicForm(ctx context.Context, input PublicSubmissionInput) (*PublicSubmissionResult, error) {
+	form, access, err := s.publicForm(ctx, input.FormRef)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bots that fill the honeypot are told they succeeded so they don't adapt
+	honeypot := honeypotField(access)
+	if !s.isEmpty(input.Data[honeypot]) {
+		s.logger.Info("public form honeypot filled", map[string]interface{}{
+			"form_id":    form.ID,
+			"ip_address": input.IPAddress,
+		})
+		return &PublicSubmissionResult{
+			ID:                   uuid.New(),
+			Status:               publicSubmissionStatus(access),
+			VerificationRequired: access.RequireEmailVerification,
+		}, nil
+	}
+	delete(input.Data, honeypot)
+
+	now := time.Now()
+	challengeID, err := s.verifyChallenge(form.ID, input.Challenge, input.Solution, access.ChallengeDifficulty, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkPublicRateLimits(ctx, form.ID, access, input.IPAddress, now); err != nil {
+		return nil, err
+	}
+
+	steps, fields, err := s.formStructure(ctx, form, form.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	data := s.normalizeFieldValues(fields, s.nestGroupValues(fields, input.Data))
+	states := s.ResolveFieldStates(fields, data)
+	err = s.validateCustomRules(ctx, customRuleScope{
+		FormID: form.ID,
+		Fields: fields,
+		Steps:  steps,
+		Data:   data,
+		States: states,
+	}, s.ValidateSubmission(fields, data, ValidationContext{Mode: ValidationModeFull, FieldStates: states}))
+	if err != nil {
+		s.recordValidationFailures(ctx, form.ID, form.Version, uuid.NullUUID{}, uuid.Nil, nil, err)
+		return nil, err
+	}
+	data = publicFieldValues(fields, s.StripHiddenValues(data, states))
+
+	email, err := publicSubmissionEmail(access, data)
+	if err != nil {
+		return nil, err
+	}
+
+	params := db.CreatePublicFormSubmissionParams{
+		FormDefinitionID: form.ID,
+		FormVersion:      form.Version,
+		Email:            email,
+		ChallengeID:      challengeID,
+		IPAddress:        input.IPAddress,
+		UserAgent:        input.UserAgent,
+	}
+	params.SubmissionData, err = json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var token string
+	if access.RequireEmailVerification {
+		token, params.VerificationTokenHash, err = newVerificationToken()
+		if err != nil {
+			return nil, err
+		}
+		params.VerificationExpiresAt = now.Add(publicVerificationTTL)
+	}
+
+	submission, err := s.store.CreatePublicFormSubmissionTx(ctx, params, func(submission db.PublicFormSubmission) ([]db.EnqueueFormEventsParams, error) {
+		// Submissions awaiting verification fire their event once verified
+		if access.RequireEmailVerification {
+			return nil, nil
+		}
+		return outboxEvents(form.ID, formEvent{eventType: EventSubmitted, data: &submission})
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrChallengeUsed) {
+			return nil, ErrInvalidChallenge
+		}
+		return nil, err
+	}
+
+	if access.RequireEmailVerification {
+		if err := s.sendPublicVerification(form, submission, token); err != nil {
+			return nil, err
+		}
+	} else {
+		s.eventsEnqueued(ctx)
+	}
+
+	return &PublicSubmissionResult{
+		ID:                   submission.ID,
+		Status:               submission.Status,
+		VerificationRequired: access.RequireEmailVerification,
+	}, nil
+}
+
+// VerifyPublicSubmission verifies the submission a verification link was sent for and fires its
+// submitted event
+func (s *FormService) VerifyPublicSubmission(ctx context.Context, token string) (*db.PublicFormSubmission, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) == 0 {
+		return nil, db.ErrInvalidVerificationToken
+	}
+	hash := sha256.Sum256(raw)
+
+	submission, err := s.store.VerifyPublicFormSubmissionTx(ctx, hash[:], time.Now(), func(submission db.PublicFormSubmission) ([]db.EnqueueFormEventsParams, error) {
+		return outboxEvents(submission.FormDefinitionID, formEvent{eventType: EventSubmitted, data: &submission})
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.eventsEnqueued(ctx)
+
+	return &submission, nil
+}
+
+// checkPublicRateLimits counts the recent submissions of the form, overall and from the IP address
+func (s *FormService) checkPublicRateLimits(ctx context.Context, formID uuid.UUID, access db.FormPublicAccess, ipAddress string, now time.Time) error {
+	since := now.Add(-publicRateWindow)
+
+	if access.HourlyLimitPerIP > 0 {
+		count, err := s.store.CountPublicFormSubmissions(ctx, formID, ipAddress, since)
+		if err != nil {
+			return err
+		}
+		if count >= int64(access.HourlyLimitPerIP) {
+			return ErrPublicRateLimited
+		}
+	}
+
+	if access.HourlyLimit > 0 {
+		count, err := s.store.CountPublicFormSubmissions(ctx, formID, "", since)
+		if err != nil {
+			return err
+		}
+		if count >= int64(access.HourlyLimit) {
+			return ErrPublicRateLimited
+		}
+	}
+	return nil
+}
+
+func (s *FormService) sendPublicVerification(form db.FormDefinition, submission db.PublicFormSubmission, token string) error {
+	link := fmt.Sprintf("%s/forms/verify?token=%s", strings.TrimRight(s.config.AppBaseURL, "/"), url.QueryEscape(token))
+
+	err := s.mailer.Send(mailer.Message{
+		To:       submission.Email,
+		Subject:  fmt.Sprintf("Confirm your %s submission", form.Name),
+		Template: PublicVerificationTemplate,
+		Data: map[string]interface{}{
+			"form_name":  form.Name,
+			"verify_url": link,
+			"expires_at": submission.VerificationExpiresAt.Time,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to send verification email: %w", err)
+	}
+	return nil
+}
+
+func honeypotField(access db.FormPublicAccess) string {
+	if access.HoneypotField != "" {
+		return access.HoneypotField
+	}
+	return DefaultHoneypotField
+}
+
+func publicEmailField(access db.FormPublicAccess) string {
+	if access.EmailField != "" {
+		return access.EmailField
+	}
+	return DefaultPublicEmailField
+}
+
+func publicSubmissionStatus(access db.FormPublicAccess) string {
+	if access.RequireEmailVerification {
+		return db.PublicSubmissionStatusPending
+	}
+	return db.PublicSubmissionStatusUnverified
+}
+
+// publicFieldValues drops values that aren't fields of the form. Anonymous visitors don't get to
+// store anything else.
+func publicFieldValues(fields []db.FormField, data map[string]interface{}) map[string]interface{} {
+	values := make(map[string]interface{}, len(data))
+	for _, field := range fields {
+		if value, ok := data[field.FieldName]; ok {
+			values[field.FieldName] = value
+		}
+	}
+	return values
+}
+
+// publicSubmissionEmail returns the email of a submission, checked against the form's blocked
+// domains and, when enabled, disposable email providers
+func publicSubmissionEmail(access db.FormPublicAccess, data map[string]interface{}) (string, error) {
+	name := publicEmailField(access)
+	email, _ := data[name].(string)
+	email = strings.TrimSpace(email)
+
+	v := validator.New()
+	switch {
+	case email == "":
+		v.AddError(name, "field is required")
+	case !validator.IsEmail(email):
+		v.AddError(name, "invalid email format")
+	case access.BlockDisposableEmails && validator.IsDisposableEmail(email),
+		validator.IsEmailAtDomain(email, access.BlockedEmailDomains...):
+		v.AddError(name, "this email address is not accepted, use your work or personal email")
+	}
+	if !v.Valid() {
+		return "", validator.NewValidationError("validation failed", v.Errors)
+	}
+	return email, nil
+}
+
+// newVerificationToken returns a random token for a verification link and the hash kept of it
+func newVerificationToken() (string, []byte, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, err
+	}
+	hash := sha256.Sum256(raw)
+	return base64.RawURLEncoding.EncodeToString(raw), hash[:], nil
+}
+
+// publicFormsEnabled reports whether a key to sign public form challenges is configured. It is
+// never borrowed from another secret, so public forms are off without one.
+func (s *FormService) publicFormsEnabled() bool {
+	return s.config.FormChallengeKey != ""
+}
+
+// issueChallenge signs a challenge for the form, "<form id>.<issued at>.<nonce>.<difficulty>.<signature>"
+func (s *FormService) issueChallenge(formID uuid.UUID, difficulty int32, now time.Time) (PublicChallenge, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return PublicChallenge{}, err
+	}
+
+	claims := strings.Join([]string{
+		formID.String(),
+		strconv.FormatInt(now.Unix(), 10),
+		hex.EncodeToString(nonce),
+		strconv.Itoa(int(difficulty)),
+	}, ".")
+
+	return PublicChallenge{
+		Token:      claims + "." + s.signChallenge(claims),
+		Difficulty: difficulty,
+		ExpiresAt:  now.Add(publicChallengeTTL),
+	}, nil
+}
+
+func (s *FormService) signChallenge(claims string) string {
+	mac := hmac.New(sha256.New, []byte(s.config.FormChallengeKey))
+	mac.Write([]byte(claims))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyChallenge checks the signature, form, age and proof of work of a challenge and returns
+// its nonce, which identifies it so it is only used once. The difficulty is the one the challenge
+// was issued with, or the form's current one if that is higher.
+func (s *FormService) verifyChallenge(formID uuid.UUID, token, solution string, difficulty int32, now time.Time) (string, error) {
+	if !s.publicFormsEnabled() {
+		return "", ErrPublicFormsDisabled
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return "", ErrInvalidChallenge
+	}
+	claims := strings.Join(parts[:4], ".")
+	if !hmac.Equal([]byte(parts[4]), []byte(s.signChallenge(claims))) {
+		return "", ErrInvalidChallenge
+	}
+
+	if parts[0] != formID.String() {
+		return "", ErrInvalidChallenge
+	}
+	issuedUnix, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", ErrInvalidChallenge
+	}
+	age := now.Sub(time.Unix(issuedUnix, 0))
+	if age < publicChallengeMinAge || age > publicChallengeTTL {
+		return "", ErrInvalidChallenge
+	}
+
+	issuedDifficulty, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return "", ErrInvalidChallenge
+	}
+	if int32(issuedDifficulty) > difficulty {
+		difficulty = int32(issuedDifficulty)
+	}
+	if !solvesChallenge(token, solution, difficulty) {
+		return "", ErrInvalidChallenge
+	}
+
+	return parts[2], nil
+}
+
+// solvesChallenge reports whether the SHA-256 of "<token>:<solution>" starts with difficulty zero bits
+func solvesChallenge(token, solution string, difficulty int32) bool {
+	if difficulty <= 0 {
+		return true
+	}
+	if len(solution) > 64 {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(token + ":" + solution))
+	zeros := int32(0)
+	for _, b := range sum {
+		if b != 0 {
+			zeros += int32(bits.LeadingZeros8(b))
+			break
+		}
+		zeros += 8
+	}
+	return zeros >= difficulty
+}
+
+// GetPublicFormAccess returns the public access settings of a form, the defaults of a private
+// form if it was never made public
+func (s *FormService) GetPublicFormAccess(ctx context.Context, formID uuid.UUID) (*db.FormPublicAccess, error) {
+	access, err := s.store.GetFormPublicAccess(ctx, formID)
+	if errors.Is(err, db.ErrFormPublicAccessNotFound) {
+		if _, err := s.store.GetFormDefinition(ctx, formID); err != nil {
+			return nil, err
+		}
+		return &db.FormPublicAccess{
+			FormDefinitionID:         formID,
+			EmailField:               DefaultPublicEmailField,
+			RequireEmailVerification: true,
+			BlockDisposableEmails:    true,
+			BlockedEmailDomains:      []string{},
+			HoneypotField:            DefaultHoneypotField,
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &access, nil
+}
+
+// UpdatePublicFormAccess sets the public access settings of a form. Public forms can't have
+// uploads or dynamic options, which anonymous visitors shouldn't reach, and the email field must
+// be a field of the form.
+func (s *FormService) UpdatePublicFormAccess(ctx context.Context, params db.UpsertFormPublicAccessParams) (*db.FormPublicAccess, error) {
+	form, err := s.store.GetFormDefinition(ctx, params.FormDefinitionID)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := s.store.GetFormFields(ctx, form.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.EmailField == "" {
+		params.EmailField = DefaultPublicEmailField
+	}
+	if params.HoneypotField == "" {
+		params.HoneypotField = DefaultHoneypotField
+	}
+	for i, domain := range params.BlockedEmailDomains {
+		params.BlockedEmailDomains[i] = strings.ToLower(strings.TrimSpace(domain))
+	}
+
+	v := validator.New()
+	v.Check(validator.Between(params.ChallengeDifficulty, 0, MaxChallengeDifficulty), "challenge_difficulty", fmt.Sprintf("must be between 0 and %d", MaxChallengeDifficulty))
+	v.Check(params.HourlyLimit >= 0, "hourly_limit", "must not be negative")
+	v.Check(params.HourlyLimitPerIP >= 0, "hourly_limit_per_ip", "must not be negative")
+	for _, domain := range params.BlockedEmailDomains {
+		v.Check(validator.IsEmail("x@"+domain), "blocked_email_domains", fmt.Sprintf("%q is not a domain", domain))
+	}
+
+	v.Check(!params.Enabled || s.publicFormsEnabled(), "enabled", "public forms are disabled until FORM_CHALLENGE_KEY is set")
+	s.checkPublicFields(v, fields, params.EmailField, params.HoneypotField, params.Enabled)
+
+	if !v.Valid() {
+		return nil, validator.NewValidationError("validation failed", v.Errors)
+	}
+
+	access, err := s.store.UpsertFormPublicAccess(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return &access, nil
+}
+
+// checkPublicFields checks that the fields of a public form fit its access settings: the email
+// field must be a required field of the form and the honeypot field must not be, and an enabled
+// form can't have uploads or dynamic options
+func (s *FormService) checkPublicFields(v *validator.Validator, fields []db.FormField, emailField, honeypotField string, enabled bool) {
+	email := s.findField(fields, emailField)
+	switch {
+	case email == nil:
+		v.AddError("email_field", "must be a field of the form")
+	case email.FieldType != "email" && email.FieldType != "text":
+		v.AddError("email_field", "must be an email or text field")
+	case !email.IsRequired:
+		v.AddError("email_field", "must be a required field")
+	}
+	if s.findField(fields, honeypotField) != nil {
+		v.AddError("honeypot_field", "must not be a field of the form")
+	}
+
+	if enabled {
+		for _, field := range fields {
+			if IsUploadFieldType(field.FieldType) {
+				v.AddError("enabled", fmt.Sprintf("public forms can't have uploads, %s is a %s field", field.FieldName, field.FieldType))
+				break
+			}
+			var options FieldOptions
+			if field.Options != nil && json.Unmarshal(field.Options, &options) == nil && options.Dynamic != nil {
+				v.AddError("enabled", fmt.Sprintf("public forms can't have dynamic options, %s has them", field.FieldName))
+				break
+			}
+		}
+	}
+}
+
+// ListPublicSubmissions returns the public submissions of a form, newest first
+func (s *FormService) ListPublicSubmissions(ctx context.Context, params db.ListPublicFormSubmissionsParams) ([]db.PublicFormSubmission, error) {
+	return s.store.ListPublicFormSubmissions(ctx, params)
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/timchuks/monieverse/internal/config"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+func solveChallenge(t *testing.T, token string, difficulty int32) string {
+	for i := 0; i < 1<<20; i++ {
+		solution := strconv.Itoa(i)
+		if solvesChallenge(token, solution, difficulty) {
+			return solution
+		}
+	}
+	t.Fatalf("no solution found for difficulty %d", difficulty)
+	return ""
+}
+
+func TestPublicChallenge(t *testing.T) {
+	s := &FormService{config: &config.Config{FormChallengeKey: "challenge-key"}}
+	formID := uuid.New()
+	issuedAt := time.Now()
+
+	challenge, err := s.issueChallenge(formID, 8, issuedAt)
+	require.NoError(t, err)
+	require.Equal(t, issuedAt.Add(publicChallengeTTL), challenge.ExpiresAt)
+	solution := solveChallenge(t, challenge.Token, 8)
+
+	nonce, err := s.verifyChallenge(formID, challenge.Token, solution, 8, issuedAt.Add(10*time.Second))
+	require.NoError(t, err)
+	require.Len(t, nonce, 32)
+
+	// Submitted too fast, too late, or to another form
+	_, err = s.verifyChallenge(formID, challenge.Token, solution, 8, issuedAt.Add(time.Second))
+	require.ErrorIs(t, err, ErrInvalidChallenge)
+	_, err = s.verifyChallenge(formID, challenge.Token, solution, 8, issuedAt.Add(publicChallengeTTL+time.Minute))
+	require.ErrorIs(t, err, ErrInvalidChallenge)
+	_, err = s.verifyChallenge(uuid.New(), challenge.Token, solution, 8, issuedAt.Add(10*time.Second))
+	require.ErrorIs(t, err, ErrInvalidChallenge)
+
+	// Lowering the difficulty in the token breaks its signature
+	tampered := challenge.Token[:len(challenge.Token)-66] + "0" + challenge.Token[len(challenge.Token)-65:]
+	_, err = s.verifyChallenge(formID, tampered, solution, 0, issuedAt.Add(10*time.Second))
+	require.ErrorIs(t, err, ErrInvalidChallenge)
+
+	// Challenges signed with another key are rejected
+	other := &FormService{config: &config.Config{FormChallengeKey: "another-key"}}
+	_, err = other.verifyChallenge(formID, challenge.Token, solution, 8, issuedAt.Add(10*time.Second))
+	require.ErrorIs(t, err, ErrInvalidChallenge)
+
+	require.True(t, solvesChallenge(challenge.Token, "", 0))
+	require.False(t, solvesChallenge(challenge.Token, string(make([]byte, 65)), 1))
+}
+
+func TestPublicFormsNeedChallengeKey(t *testing.T) {
+	s := &FormService{config: &config.Config{TokenSymmetricKey: "token-key"}}
+
+	_, _, err := s.publicForm(context.Background(), "contact-us")
+	require.ErrorIs(t, err, ErrPublicFormsDisabled)
+
+	// Challenges can't be signed with another secret instead
+	signed := &FormService{config: &config.Config{FormChallengeKey: "token-key"}}
+	challenge, err := signed.issueChallenge(uuid.New(), 0, time.Now())
+	require.NoError(t, err)
+	_, err = s.verifyChallenge(uuid.New(), challenge.Token, "", 0, time.Now().Add(10*time.Second))
+	require.ErrorIs(t, err, ErrPublicFormsDisabled)
+}
+
+func TestPublicSubmissionEmail(t *testing.T) {
+	access := db.FormPublicAccess{
+		BlockDisposableEmails: true,
+		BlockedEmailDomains:   []string{"competitor.com"},
+	}
+
+	email, err := publicSubmissionEmail(access, map[string]interface{}{"email": " lead@acme.ng "})
+	require.NoError(t, err)
+	require.Equal(t, "lead@acme.ng", email)
+
+	for _, value := range []interface{}{nil, "not-an-email", "lead@mailinator.com", "lead@sales.competitor.com"} {
+		_, err := publicSubmissionEmail(access, map[string]interface{}{"email": value})
+		var validationErr *validator.ValidationError
+		require.ErrorAs(t, err, &validationErr, "email %v", value)
+		require.Contains(t, validationErr.Fields, "email")
+	}
+
+	access.BlockDisposableEmails = false
+	access.EmailField = "work_email"
+	email, err = publicSubmissionEmail(access, map[string]interface{}{"work_email": "lead@mailinator.com"})
+	require.NoError(t, err)
+	require.Equal(t, "lead@mailinator.com", email)
+}
+
+func TestPublicFieldValues(t *testing.T) {
+	fields := []db.FormField{{FieldName: "name"}, {FieldName: "email"}}
+	values := publicFieldValues(fields, map[string]interface{}{
+		"name":    "Ada",
+		"email":   "ada@acme.ng",
+		"user_id": "someone-else",
+	})
+	require.Equal(t, map[string]interface{}{"name": "Ada", "email": "ada@acme.ng"}, values)
+}
@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+const (
+	// retentionPurgeBatch is how many submissions of a policy are purged at a time
+	retentionPurgeBatch = 100
+	// MaxRetentionDays keeps policies to periods that fit in a date, about 100 years
+	MaxRetentionDays = 36500
+	// retentionPurgeLock is the advisory lock held while retention policies are applied
+	retentionPurgeLock = "forms.retention_purge"
+)
+
+// ErrRetentionPurgeRunning is returned when another instance is applying the retention policies
+var ErrRetentionPurgeRunning = errors.New("retention policies are being applied by another run")
+
+// RetentionPolicyResult is what one policy purged in a run
+type RetentionPolicyResult struct {
+	PolicyID         uuid.UUID `json:"policy_id"`
+	FormDefinitionID uuid.UUID `json:"form_definition_id"`
+	Action           string    `json:"action"`
+	Purged           int       `json:"purged"`
+	Failed           int       `json:"failed"`
+}
+
+// RetentionRunResult is what a run of the retention policies purged
+type RetentionRunResult struct {
+	RanAt          time.Time               `json:"ran_at"`
+	Policies       []RetentionPolicyResult `json:"policies"`
+	ExpiredExports int                     `json:"expired_exports"`
+}
+
+// CreateRetentionPolicy adds a retention policy to a form
+func (s *FormService) CreateRetentionPolicy(ctx context.Context, params db.CreateFormRetentionPolicyParams) (*db.FormRetentionPolicy, error) {
+	if _, err := s.store.GetFormDefinition(ctx, params.FormDefinitionID); err != nil {
+		return nil, err
+	}
+
+	params.Statuses = normalizeRetentionStatuses(params.Statuses)
+	if err := validateRetentionPolicy(params.Name, params.Trigger, params.Action, params.AfterDays); err != nil {
+		return nil, err
+	}
+
+	policy, err := s.store.CreateFormRetentionPolicy(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// UpdateRetentionPolicy changes a retention policy
+func (s *FormService) UpdateRetentionPolicy(ctx context.Context, params db.UpdateFormRetentionPolicyParams) (*db.FormRetentionPolicy, error) {
+	params.Statuses = normalizeRetentionStatuses(params.Statuses)
+	if err := validateRetentionPolicy(params.Name, params.Trigger, params.Action, params.AfterDays); err != nil {
+		return nil, err
+	}
+
+	policy, err := s.store.UpdateFormRetentionPolicy(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// DeleteRetentionPolicy removes a retention policy; what it purged stays purged
+func (s *FormService) DeleteRetentionPolicy(ctx context.Context, policyID uuid.UUID) error {
+	return s.store.DeleteFormRetentionPolicy(ctx, policyID)
+}
+
+// ListRetentionPolicies returns the retention policies of a form
+func (s *FormService) ListRetentionPolicies(ctx context.Context, formID uuid.UUID) ([]db.FormRetentionPolicy, error) {
+	if _, err := s.store.GetFormDefinition(ctx, formID); err != nil {
+		return nil, err
+	}
+	return s.store.ListFormRetentionPolicies(ctx, db.NewNullUUID(formID), false)
+}
+
+func normalizeRetentionStatuses(statuses []string) []string {
+	normalized := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		if status = strings.ToLower(strings.TrimSpace(status)); status != "" {
+			normalized = append(normalized, status)
+		}
+	}
+	return normalized
+}
+
+func validateRetentionPolicy(name, trigger, action string, afterDays int32) error {
+	v := validator.New()
+	v.Check(strings.TrimSpace(name) != "", "name", "must be provided")
+	v.Check(validator.In(trigger, db.RetentionTriggerAge, db.RetentionTriggerAccountClosed), "trigger",
+		fmt.Sprintf("must be %s or %s", db.RetentionTriggerAge, db.RetentionTriggerAccountClosed))
+	v.Check(validator.In(action, db.RetentionActionDelete, db.RetentionActionAnonymize), "action",
+		fmt.Sprintf("must be %s or %s", db.RetentionActionDelete, db.RetentionActionAnonymize))
+	v.Check(validator.Between(afterDays, 1, MaxRetentionDays), "after_days", fmt.Sprintf("must be between 1 and %d", MaxRetentionDays))
+	if !v.Valid() {
+		return validator.NewValidationError("validation failed", v.Errors)
+	}
+	return nil
+}
+
+// PurgeExpiredSubmissions applies the enabled retention policies of all forms, deleting or
+// anonymizing the submissions they have expired along with their uploads, and deletes data
+// exports past their expiry. Submissions whose files can't be deleted are retried next run.
+// Only one run at a time, across instances, is allowed; others get ErrRetentionPurgeRunning.
+func (s *FormService) PurgeExpiredSubmissions(ctx context.Context) (*RetentionRunResult, error) {
+	unlock, ok, err := s.store.TryAdvisoryLock(ctx, retentionPurgeLock)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrRetentionPurgeRunning
+	}
+	defer unlock()
+
+	now := time.Now()
+	result := &RetentionRunResult{RanAt: now, Policies: []RetentionPolicyResult{}}
+
+	policies, err := s.store.ListFormRetentionPolicies(ctx, uuid.NullUUID{}, true)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, policy := range policies {
+		policyResult := RetentionPolicyResult{
+			PolicyID:         policy.ID,
+			FormDefinitionID: policy.FormDefinitionID,
+			Action:           policy.Action,
+		}
+
+		for {
+			submissions, err := s.store.ListExpiredFormSubmissions(ctx, policy, now, retentionPurgeBatch)
+			if err != nil {
+				return result, err
+			}
+			for _, submission := range submissions {
+				if err := s.purgeSubmission(ctx, policy.Action, submission, now); err != nil {
+					policyResult.Failed++
+					s.logger.Error(err, map[string]interface{}{
+						"policy_id":     policy.ID,
+						"submission_id": submission.ID,
+					})
+					continue
+				}
+				policyResult.Purged++
+			}
+			// Failed submissions would come back in the next batch
+			if len(submissions) < retentionPurgeBatch || policyResult.Failed > 0 {
+				break
+			}
+		}
+
+		if err := s.store.MarkFormRetentionPolicyRun(ctx, policy.ID, now); err != nil {
+			return result, err
+		}
+		if policyResult.Purged > 0 || policyResult.Failed > 0 {
+			s.logger.Info("retention policy applied", map[string]interface{}{
+				"policy_id": policy.ID,
+				"form_id":   policy.FormDefinitionID,
+				"action":    policy.Action,
+				"purged":    policyResult.Purged,
+				"failed":    policyResult.Failed,
+			})
+		}
+		result.Policies = append(result.Policies, policyResult)
+	}
+
+	result.ExpiredExports, err = s.deleteExpiredDataExports(ctx, now)
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// purgeSubmission deletes the uploads of a submission from storage, then deletes or
+// anonymizes the submission
+func (s *FormService) purgeSubmission(ctx context.Context, action string, submission db.FormSubmission, now time.Time) error {
+	files, err := s.store.GetFormSubmissionFiles(ctx, submission.ID)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := s.uploader.Delete(file.Bucket, file.FilePath); err != nil {
+			return fmt.Errorf("unable to delete %s: %w", file.FilePath, err)
+		}
+	}
+
+	if action == db.RetentionActionAnonymize {
+		return s.store.RedactFormSubmissionTx(ctx, submission.ID, now)
+	}
+	return s.store.DeleteFormSubmissionTx(ctx, submission.ID)
+}
+
+// PlaceLegalHold keeps a user's data, or their submissions of one form, from being purged or
+// erased until the hold is released
+func (s *FormService) PlaceLegalHold(ctx context.Context, params db.CreateLegalHoldParams) (*db.LegalHold, error) {
+	v := validator.New()
+	v.Check(strings.TrimSpace(params.Reason) != "", "reason", "must be provided")
+	if !v.Valid() {
+		return nil, validator.NewValidationError("validation failed", v.Errors)
+	}
+
+	if _, err := s.store.GetUser(ctx, params.UserID); err != nil {
+		return nil, err
+	}
+	if params.FormDefinitionID.Valid {
+		if _, err := s.store.GetFormDefinition(ctx, params.FormDefinitionID.UUID); err != nil {
+			return nil, err
+		}
+	}
+
+	hold, err := s.store.CreateLegalHold(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// ReleaseLegalHold releases a legal hold; the data it kept is purged by the next run that
+// expires it
+func (s *FormService) ReleaseLegalHold(ctx context.Context, holdID, releasedBy uuid.UUID) (*db.LegalHold, error) {
+	hold, err := s.store.ReleaseLegalHold(ctx, holdID, releasedBy)
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// ListLegalHolds returns legal holds, newest first
+func (s *FormService) ListLegalHolds(ctx context.Context, params db.ListLegalHoldsParams) ([]db.LegalHold, error) {
+	return s.store.ListLegalHolds(ctx, params)
+}
+
+// heldForms returns the forms whose submissions a user's active holds keep, and whether a hold
+// keeps all of their data
+func heldForms(holds []db.LegalHold) ([]uuid.UUID, bool) {
+	forms := []uuid.UUID{}
+	seen := map[uuid.UUID]bool{}
+	for _, hold := range holds {
+		if hold.ReleasedAt.Valid {
+			continue
+		}
+		if !hold.FormDefinitionID.Valid {
+			return nil, true
+		}
+		if !seen[hold.FormDefinitionID.UUID] {
+			seen[hold.FormDefinitionID.UUID] = true
+			forms = append(forms, hold.FormDefinitionID.UUID)
+		}
+	}
+	return forms, false
+}
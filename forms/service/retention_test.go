@@ -0,0 +1,92 @@
+package service
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+func TestValidateRetentionPolicy(t *testing.T) {
+	require.NoError(t, validateRetentionPolicy("Rejected drafts", db.RetentionTriggerAge, db.RetentionActionDelete, 90))
+	require.NoError(t, validateRetentionPolicy("Closed accounts", db.RetentionTriggerAccountClosed, db.RetentionActionAnonymize, 30))
+
+	err := validateRetentionPolicy(" ", "forever", "archive", 0)
+	var validationErr *validator.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	for _, field := range []string{"name", "trigger", "action", "after_days"} {
+		require.Contains(t, validationErr.Fields, field)
+	}
+
+	require.Equal(t, []string{"draft", "rejected"}, normalizeRetentionStatuses([]string{" Draft", "", "REJECTED "}))
+}
+
+func TestHeldForms(t *testing.T) {
+	formA, formB := uuid.New(), uuid.New()
+
+	keep, all := heldForms(nil)
+	require.False(t, all)
+	require.Empty(t, keep)
+
+	keep, all = heldForms([]db.LegalHold{
+		{FormDefinitionID: db.NewNullUUID(formA)},
+		{FormDefinitionID: db.NewNullUUID(formA)},
+		{FormDefinitionID: db.NewNullUUID(formB)},
+		{ReleasedAt: sql.NullTime{Valid: true}},
+	})
+	require.False(t, all, "released holds don't count")
+	require.Equal(t, []uuid.UUID{formA, formB}, keep)
+
+	_, all = heldForms([]db.LegalHold{{FormDefinitionID: db.NewNullUUID(formA)}, {}})
+	require.True(t, all)
+}
+
+func TestErasableFiles(t *testing.T) {
+	kept, erased := uuid.New(), uuid.New()
+	keptSubmission, erasedSubmission := uuid.New(), uuid.New()
+
+	data := db.UserPersonalData{
+		FormSubmissions: []db.FormSubmission{
+			{ID: keptSubmission, FormDefinitionID: kept},
+			{ID: erasedSubmission, FormDefinitionID: erased},
+		},
+		FormSubmissionFiles: []db.FormSubmissionFile{
+			{FormSubmissionID: keptSubmission, Bucket: "uploads", FilePath: "forms/kept.pdf"},
+			{FormSubmissionID: erasedSubmission, Bucket: "uploads", FilePath: "forms/erased.pdf"},
+		},
+		Documents:         []db.Document{{Bucket: "kyc", DocumentPath: "kyc/utility-bill.pdf"}},
+		IdentityDocuments: []db.UserIdentityDocument{{Bucket: "kyc", DocumentPath: "kyc/passport.jpg"}},
+	}
+
+	// A held form keeps the documents, which aren't tied to one form
+	require.Equal(t, []storedFile{
+		{"uploads", "forms/erased.pdf"},
+	}, erasableFiles(data, []uuid.UUID{kept}))
+
+	require.Equal(t, []storedFile{
+		{"uploads", "forms/kept.pdf"},
+		{"uploads", "forms/erased.pdf"},
+		{"kyc", "kyc/utility-bill.pdf"},
+		{"kyc", "kyc/passport.jpg"},
+	}, erasableFiles(data, nil))
+}
+
+func TestDecryptUserMeta(t *testing.T) {
+	require.NoError(t, db.SetFieldEncryptionKey("retention-test", make([]byte, 32)))
+	encrypted, err := db.EncryptFieldValue("12345678901")
+	require.NoError(t, err)
+
+	meta := []db.UserMetum{
+		{Key: "bvn", Value: sql.NullString{String: encrypted, Valid: true}},
+		{Key: "nickname", Value: sql.NullString{String: "Ada", Valid: true}},
+		{Key: "corrupt", Value: sql.NullString{String: "enc:v1:missing:key:value", Valid: true}},
+	}
+	decryptUserMeta(meta)
+
+	require.Equal(t, "12345678901", meta[0].Value.String)
+	require.Equal(t, "Ada", meta[1].Value.String)
+	require.Equal(t, "enc:v1:missing:key:value", meta[2].Value.String)
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+)
+
+// AutosaveInput is a field-level patch of a draft, based on the revision the client last saw
+type AutosaveInput struct {
+	SubmissionID uuid.UUID              `json:"submission_id"`
+	UserID       uuid.UUID              `json:"user_id"`
+	BaseRevision int32                  `json:"base_revision"`
+	Set          map[string]interface{} `json:"set"`
+	Unset        []string               `json:"unset"`
+}
+
+// RestoreRevisionInput restores the data of an earlier revision of a submission
+type RestoreRevisionInput struct {
+	SubmissionID     uuid.UUID `json:"submission_id"`
+	UserID           uuid.UUID `json:"user_id"`
+	Revision         int32     `json:"revision"`
+	ExpectedRevision int32     `json:"expected_revision"`
+}
+
+// authorizeSubmission returns the submission when the user owns it or administers forms
+func (s *FormService) authorizeSubmission(ctx context.Context, submissionID, userID uuid.UUID) (db.FormSubmission, error) {
+	submission, err := s.store.GetFormSubmission(ctx, submissionID)
+	if err != nil {
+		return submission, fmt.Errorf("submission not found: %w", err)
+	}
+
+	if submission.UserID != userID {
+		user, _ := s.store.GetUser(ctx, userID)
+		if !s.store.HasPermission(ctx, user, "admin.forms") {
+			return submission, fmt.Errorf("access denied")
+		}
+	}
+	return submission, nil
+}
+
+// AutosaveSubmission saves a patch of a draft without validating it. Only fields of the version
+// the draft is pinned to can be patched; files are uploaded through the step and update endpoints.
+func (s *FormService) AutosaveSubmission(ctx context.Context, input AutosaveInput) (*db.FormSubmission, error) {
+	if len(input.Set) == 0 && len(input.Unset) == 0 {
+		return nil, fmt.Errorf("nothing to save")
+	}
+
+	submission, err := s.authorizeSubmission(ctx, input.SubmissionID, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if submission.Status != "draft" {
+		return nil, fmt.Errorf("only drafts can be autosaved")
+	}
+
+	form, err := s.store.GetFormDefinition(ctx, submission.FormDefinitionID)
+	if err != nil {
+		return nil, fmt.Errorf("form not found: %w", err)
+	}
+
+	_, fields, err := s.formStructure(ctx, form, submission.FormVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get form fields: %w", err)
+	}
+
+	input.Set = s.normalizeFieldValues(fields, s.nestGroupValues(fields, input.Set))
+	if err := autosaveFields(fields, input.Set, input.Unset); err != nil {
+		return nil, err
+	}
+
+	// Locked fields keep their verified values
+	input.Set, err = s.lockPrefilledValues(ctx, submission.UserID, fields, input.Set)
+	if err != nil {
+		return nil, err
+	}
+	input.Unset = withoutLockedFields(input.Unset, input.Set)
+
+	saved, err := s.store.AutosaveFormSubmissionTx(ctx, &db.AutosaveInput{
+		SubmissionID: input.SubmissionID,
+		UserID:       input.UserID,
+		BaseRevision: input.BaseRevision,
+		Set:          input.Set,
+		Unset:        input.Unset,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to autosave submission: %w", err)
+	}
+
+	return saved, nil
+}
+
+// autosaveFields checks that a patch only names fields of the form that hold answers
+func autosaveFields(fields []db.FormField, set map[string]interface{}, unset []string) error {
+	types := make(map[string]string, len(fields))
+	for _, field := range fields {
+		types[field.FieldName] = field.FieldType
+	}
+
+	check := func(name string) error {
+		fieldType, ok := types[name]
+		if !ok {
+			return fmt.Errorf("unknown field %q", name)
+		}
+		if IsUploadFieldType(fieldType) {
+			return fmt.Errorf("field %q takes uploads and can't be autosaved", name)
+		}
+		return nil
+	}
+
+	for name := range set {
+		if err := check(name); err != nil {
+			return err
+		}
+	}
+	for _, name := range unset {
+		if _, ok := set[name]; ok {
+			return fmt.Errorf("field %q is both set and unset", name)
+		}
+		if err := check(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListSubmissionRevisions returns the revision history of a submission, newest first
+func (s *FormService) ListSubmissionRevisions(ctx context.Context, submissionID, userID uuid.UUID) ([]db.FormSubmissionRevision, error) {
+	if _, err := s.authorizeSubmission(ctx, submissionID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.store.ListFormSubmissionRevisions(ctx, submissionID)
+}
+
+// RestoreSubmissionRevision makes the data of an earlier revision current again. The data is
+// saved as an update of the whole submission, so it is validated and has locked and hidden
+// fields applied like any other, and restoring a submission under review restarts its approval.
+// Revisions saved against another version of the form can't be restored.
+func (s *FormService) RestoreSubmissionRevision(ctx context.Context, input RestoreRevisionInput) (*db.FormSubmission, error) {
+	submission, err := s.authorizeSubmission(ctx, input.SubmissionID, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := s.store.GetFormSubmissionRevision(ctx, submission.ID, input.Revision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore revision: %w", err)
+	}
+	if revision.FormVersion != submission.FormVersion {
+		return nil, db.ErrRevisionFormMismatch
+	}
+
+	data := make(map[string]interface{})
+	if len(revision.SubmissionData) > 0 {
+		if err := json.Unmarshal(revision.SubmissionData, &data); err != nil {
+			return nil, fmt.Errorf("failed to restore revision: %w", err)
+		}
+	}
+	var metadata map[string]interface{}
+	if len(submission.Metadata) > 0 {
+		_ = json.Unmarshal(submission.Metadata, &metadata)
+	}
+
+	return s.UpdateFormSubmission(ctx, UpdateSubmissionInput{
+		SubmissionID:     submission.ID,
+		UserID:           input.UserID,
+		Data:             data,
+		Status:           submission.Status,
+		Metadata:         metadata,
+		ExpectedRevision: input.ExpectedRevision,
+		revisionSource:   db.RevisionSourceRestore,
+	})
+}
+
+// withoutLockedFields drops the locked fields, which were just set to their verified values,
+// from the fields a patch unsets
+func withoutLockedFields(unset []string, set map[string]interface{}) []string {
+	kept := unset[:0]
+	for _, name := range unset {
+		if _, ok := set[name]; !ok {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/logger"
+)
+
+// revisionStore serves one draft of a form and its revisions, and records the saves made to
+// it; other store methods are not used. A non-nil saveErr fails every save.
+type revisionStore struct {
+	db.Store
+	form       db.FormDefinition
+	fields     []db.FormField
+	submission db.FormSubmission
+	revisions  map[int32]db.FormSubmissionRevision
+	saveErr    error
+
+	autosaved *db.AutosaveInput
+	updated   *db.FormSubmissionUpdateInput
+}
+
+func (s *revisionStore) GetFormSubmission(_ context.Context, id uuid.UUID) (db.FormSubmission, error) {
+	if id != s.submission.ID {
+		return db.FormSubmission{}, sql.ErrNoRows
+	}
+	return s.submission, nil
+}
+
+func (s *revisionStore) GetFormDefinition(_ context.Context, _ uuid.UUID) (db.FormDefinition, error) {
+	return s.form, nil
+}
+
+func (s *revisionStore) GetFormSteps(_ context.Context, _ uuid.UUID) ([]db.FormStep, error) {
+	return nil, nil
+}
+
+func (s *revisionStore) GetFormFields(_ context.Context, _ uuid.UUID) ([]db.FormField, error) {
+	return s.fields, nil
+}
+
+func (s *revisionStore) GetFormSubmissionRevision(_ context.Context, _ uuid.UUID, revision int32) (db.FormSubmissionRevision, error) {
+	r, ok := s.revisions[revision]
+	if !ok {
+		return r, db.ErrRevisionNotFound
+	}
+	return r, nil
+}
+
+func (s *revisionStore) AutosaveFormSubmissionTx(_ context.Context, input *db.AutosaveInput) (*db.FormSubmission, error) {
+	s.autosaved = input
+	if s.saveErr != nil {
+		return nil, s.saveErr
+	}
+	saved := s.submission
+	saved.Revision++
+	return &saved, nil
+}
+
+func (s *revisionStore) UpdateFormSubmissionTx(_ context.Context, input *db.FormSubmissionUpdateInput, _ db.FormEventsFunc[*db.FormSubmission]) (*db.FormSubmission, error) {
+	s.updated = input
+	if s.saveErr != nil {
+		return nil, s.saveErr
+	}
+	saved := s.submission
+	saved.Revision++
+	saved.SubmissionData, _ = json.Marshal(input.Data)
+	return &saved, nil
+}
+
+func newRevisionTestService() (*FormService, *revisionStore) {
+	form := db.FormDefinition{ID: uuid.New(), Version: 2}
+	store := &revisionStore{
+		form: form,
+		fields: []db.FormField{
+			{FieldName: "business_name", FieldType: "text"},
+			{FieldName: "website", FieldType: "text"},
+		},
+		submission: db.FormSubmission{
+			ID:               uuid.New(),
+			FormDefinitionID: form.ID,
+			UserID:           uuid.New(),
+			Status:           "draft",
+			SubmissionData:   json.RawMessage(`{"business_name":"Acme Ltd","website":"acme.ng"}`),
+			FormVersion:      2,
+			Revision:         5,
+		},
+	}
+	store.revisions = map[int32]db.FormSubmissionRevision{
+		3: {FormSubmissionID: store.submission.ID, Revision: 3, FormVersion: 2, SubmissionData: json.RawMessage(`{"business_name":"Acme"}`)},
+		1: {FormSubmissionID: store.submission.ID, Revision: 1, FormVersion: 1, SubmissionData: json.RawMessage(`{"company":"Acme"}`)},
+	}
+
+	return &FormService{
+		store:           store,
+		logger:          logger.NewZeroLogger(io.Discard, logger.LevelOff, nil),
+		validationHooks: map[string]ValidationHook{},
+	}, store
+}
+
+func TestAutosaveFields(t *testing.T) {
+	fields := []db.FormField{
+		{FieldName: "business_name", FieldType: "text"},
+		{FieldName: "owners", FieldType: "group"},
+		{FieldName: "certificate", FieldType: "file"},
+	}
+
+	require.NoError(t, autosaveFields(fields, map[string]interface{}{
+		"business_name": "Acme",
+		"owners":        []map[string]interface{}{{"name": "Ada"}},
+	}, nil))
+	require.NoError(t, autosaveFields(fields, nil, []string{"business_name"}))
+
+	require.ErrorContains(t, autosaveFields(fields, map[string]interface{}{"busines_name": "Acme"}, nil), "busines_name")
+	require.ErrorContains(t, autosaveFields(fields, nil, []string{"certificate"}), "uploads")
+	require.ErrorContains(t, autosaveFields(fields, map[string]interface{}{"business_name": "Acme"}, []string{"business_name"}), "both")
+}
+
+func TestAutosaveSubmission(t *testing.T) {
+	s, store := newRevisionTestService()
+	input := AutosaveInput{
+		SubmissionID: store.submission.ID,
+		UserID:       store.submission.UserID,
+		BaseRevision: 3,
+		Set:          map[string]interface{}{"website": "acme.com.ng"},
+		Unset:        []string{"business_name"},
+	}
+
+	saved, err := s.AutosaveSubmission(context.Background(), input)
+	require.NoError(t, err)
+	require.Equal(t, int32(6), saved.Revision)
+	require.Equal(t, int32(3), store.autosaved.BaseRevision)
+	require.Equal(t, map[string]interface{}{"website": "acme.com.ng"}, store.autosaved.Set)
+	require.Equal(t, []string{"business_name"}, store.autosaved.Unset)
+
+	// A conflicting patch surfaces the fields changed since its base revision
+	store.saveErr = &db.RevisionConflictError{
+		SubmissionID:     store.submission.ID,
+		ExpectedRevision: 3,
+		CurrentRevision:  5,
+		Changes:          []db.FieldChange{{Field: "website", Current: "acme.ng", Yours: "acme.com.ng"}},
+	}
+	_, err = s.AutosaveSubmission(context.Background(), input)
+	require.ErrorIs(t, err, db.ErrRevisionConflict)
+	var conflict *db.RevisionConflictError
+	require.ErrorAs(t, err, &conflict)
+	require.Equal(t, "website", conflict.Changes[0].Field)
+
+	// Submitted answers go through validation, so only drafts are autosaved
+	store.submission.Status = "submitted"
+	_, err = s.AutosaveSubmission(context.Background(), input)
+	require.ErrorContains(t, err, "only drafts")
+}
+
+func TestRestoreSubmissionRevision(t *testing.T) {
+	s, store := newRevisionTestService()
+	input := RestoreRevisionInput{
+		SubmissionID:     store.submission.ID,
+		UserID:           store.submission.UserID,
+		Revision:         3,
+		ExpectedRevision: 5,
+	}
+
+	restored, err := s.RestoreSubmissionRevision(context.Background(), input)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"business_name":"Acme"}`, string(restored.SubmissionData))
+	require.Equal(t, map[string]interface{}{"business_name": "Acme"}, store.updated.Data)
+	require.Equal(t, "draft", store.updated.Status)
+	require.Equal(t, int32(5), store.updated.ExpectedRevision)
+	require.Equal(t, db.RevisionSourceRestore, store.updated.RevisionSource)
+	require.False(t, store.updated.IsPartialUpdate)
+
+	// The restore is checked against the revision the client last saw
+	store.saveErr = &db.RevisionConflictError{SubmissionID: store.submission.ID, ExpectedRevision: 4, CurrentRevision: 5}
+	_, err = s.RestoreSubmissionRevision(context.Background(), RestoreRevisionInput{
+		SubmissionID:     store.submission.ID,
+		UserID:           store.submission.UserID,
+		Revision:         3,
+		ExpectedRevision: 4,
+	})
+	require.ErrorIs(t, err, db.ErrRevisionConflict)
+	store.saveErr = nil
+
+	// Revisions of another form version and unknown revisions can't be restored
+	store.updated = nil
+	input.Revision = 1
+	_, err = s.RestoreSubmissionRevision(context.Background(), input)
+	require.ErrorIs(t, err, db.ErrRevisionFormMismatch)
+	input.Revision = 4
+	_, err = s.RestoreSubmissionRevision(context.Background(), input)
+	require.ErrorIs(t, err, db.ErrRevisionNotFound)
+	require.Nil(t, store.updated)
+}
@@ -0,0 +1,566 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+)
+
+// JSONSchemaDialect is the JSON Schema version form schemas are written in
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+var ErrFormNotActive = errors.New("form is not active")
+
+// JSONSchema is the subset of JSON Schema 2020-12 form schemas use. Keywords prefixed with x-
+// are annotations for renderers; validators ignore them.
+type JSONSchema struct {
+	Schema      string `json:"$schema,omitempty"`
+	Comment     string `json:"$comment,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Format      string `json:"format,omitempty"`
+	ReadOnly    bool   `json:"readOnly,omitempty"`
+
+	Const interface{}   `json:"const,omitempty"`
+	Enum  []interface{} `json:"enum,omitempty"`
+
+	MinLength        *int        `json:"minLength,omitempty"`
+	MaxLength        *int        `json:"maxLength,omitempty"`
+	Pattern          string      `json:"pattern,omitempty"`
+	ContentMediaType string      `json:"contentMediaType,omitempty"`
+	Minimum          interface{} `json:"minimum,omitempty"`
+	Maximum          interface{} `json:"maximum,omitempty"`
+	ExclusiveMinimum interface{} `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum interface{} `json:"exclusiveMaximum,omitempty"`
+
+	Items       *JSONSchema `json:"items,omitempty"`
+	Contains    *JSONSchema `json:"contains,omitempty"`
+	MinItems    *int        `json:"minItems,omitempty"`
+	MaxItems    *int        `json:"maxItems,omitempty"`
+	UniqueItems bool        `json:"uniqueItems,omitempty"`
+
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	MaxProperties        *int                   `json:"maxProperties,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+
+	Ref   string        `json:"$ref,omitempty"`
+	AllOf []*JSONSchema `json:"allOf,omitempty"`
+	AnyOf []*JSONSchema `json:"anyOf,omitempty"`
+	OneOf []*JSONSchema `json:"oneOf,omitempty"`
+	Not   *JSONSchema   `json:"not,omitempty"`
+	If    *JSONSchema   `json:"if,omitempty"`
+	Then  *JSONSchema   `json:"then,omitempty"`
+	Else  *JSONSchema   `json:"else,omitempty"`
+
+	Defs map[string]*JSONSchema `json:"$defs,omitempty"`
+
+	Form          *SchemaForm           `json:"x-form,omitempty"`
+	Steps         []SchemaStep          `json:"x-steps,omitempty"`
+	FieldType     string                `json:"x-field-type,omitempty"`
+	Step          int32                 `json:"x-step,omitempty"`
+	DisplayOrder  int32                 `json:"x-display-order,omitempty"`
+	Labels        I18nText              `json:"x-labels,omitempty"`
+	Placeholder   I18nText              `json:"x-placeholder,omitempty"`
+	HelpText      I18nText              `json:"x-help-text,omitempty"`
+	OptionLabels  map[string]I18nText   `json:"x-option-labels,omitempty"`
+	OptionsSource *DynamicSource        `json:"x-options-source,omitempty"`
+	File          *FileValidationConfig `json:"x-file,omitempty"`
+	FieldMeta     *FieldMetadata        `json:"x-field-meta,omitempty"`
+}
+
+// SchemaForm identifies the form version a schema was generated from
+type SchemaForm struct {
+	ID          uuid.UUID `json:"id"`
+	Slug        string    `json:"slug"`
+	FormType    string    `json:"form_type"`
+	Version     int32     `json:"version"`
+	IsMultiStep bool      `json:"is_multi_step"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// SchemaStep groups the fields of a step. Its schema, for saving the step on its own, is
+// $defs/step_<number>.
+type SchemaStep struct {
+	StepNumber  int32    `json:"step_number"`
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	IsOptional  bool     `json:"is_optional,omitempty"`
+	Fields      []string `json:"fields"`
+}
+
+// StepSchemaName is the name of a step's schema in $defs
+func StepSchemaName(stepNumber int32) string {
+	return fmt.Sprintf("step_%d", stepNumber)
+}
+
+// GetFormJSONSchema returns the JSON Schema of a form version, the current one when version is
+// 0. With activeOnly, inactive forms are not found, which is what users and integrators get.
+func (s *FormService) GetFormJSONSchema(ctx context.Context, formID uuid.UUID, version int32, activeOnly bool) (*JSONSchema, error) {
+	form, err := s.store.GetFormDefinition(ctx, formID)
+	if err != nil {
+		return nil, err
+	}
+	if activeOnly && !form.IsActive {
+		return nil, ErrFormNotActive
+	}
+	if version == 0 {
+		version = form.Version
+	} else if version != form.Version {
+		if _, err := s.store.GetFormVersion(ctx, formID, version); err != nil {
+			return nil, err
+		}
+	}
+
+	steps, fields, err := s.formStructure(ctx, form, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get form structure: %w", err)
+	}
+
+	return s.BuildFormJSONSchema(form, version, steps, fields, time.Now()), nil
+}
+
+// BuildFormJSONSchema describes the data of a submission of a form version. Required fields
+// without conditional logic are required outright; conditional logic becomes if/then rules.
+// Rules that reference fields hidden by other rules are evaluated on the submitted values, so
+// the server, which treats hidden fields as unanswered, remains the final word on chained rules.
+// Date bounds relative to today are resolved at now.
+func (s *FormService) BuildFormJSONSchema(form db.FormDefinition, version int32, steps []db.FormStep, fields []db.FormField, now time.Time) *JSONSchema {
+	fields = append([]db.FormField(nil), fields...)
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].DisplayOrder < fields[j].DisplayOrder })
+
+	stepNumbers := make(map[uuid.UUID]int32, len(steps))
+	for _, step := range steps {
+		stepNumbers[step.ID] = step.StepNumber
+	}
+
+	schema := &JSONSchema{
+		Schema:      JSONSchemaDialect,
+		Title:       form.Name,
+		Description: form.Description,
+		Type:        "object",
+		Properties:  make(map[string]*JSONSchema, len(fields)),
+		Form: &SchemaForm{
+			ID:          form.ID,
+			Slug:        form.Slug,
+			FormType:    form.FormType,
+			Version:     version,
+			IsMultiStep: form.IsMultiStep,
+			GeneratedAt: now.UTC(),
+		},
+	}
+
+	stepFields := make(map[int32][]db.FormField)
+	for _, field := range fields {
+		property := s.fieldSchema(field, now)
+		property.Step = stepNumbers[field.FormStepID]
+		schema.Properties[field.FieldName] = property
+		if property.Step > 0 {
+			stepFields[property.Step] = append(stepFields[property.Step], field)
+		}
+
+		logic, err := ParseConditionalLogic(field.ConditionalLogic)
+		if err != nil || logic == nil {
+			if field.IsRequired {
+				schema.Required = append(schema.Required, field.FieldName)
+			}
+			continue
+		}
+		if rule := conditionalRuleSchema(field, logic); rule != nil {
+			schema.AllOf = append(schema.AllOf, rule)
+		}
+	}
+
+	for _, step := range steps {
+		names := make([]string, 0, len(stepFields[step.StepNumber]))
+		stepSchema := &JSONSchema{
+			Title:       step.Name,
+			Description: step.Description,
+			Type:        "object",
+			Properties:  make(map[string]*JSONSchema),
+		}
+		for _, field := range stepFields[step.StepNumber] {
+			names = append(names, field.FieldName)
+			stepSchema.Properties[field.FieldName] = schema.Properties[field.FieldName]
+			if logic, _ := ParseConditionalLogic(field.ConditionalLogic); logic == nil && field.IsRequired {
+				stepSchema.Required = append(stepSchema.Required, field.FieldName)
+			}
+		}
+
+		schema.Steps = append(schema.Steps, SchemaStep{
+			StepNumber:  step.StepNumber,
+			Name:        step.Name,
+			Description: step.Description,
+			IsOptional:  step.IsOptional,
+			Fields:      names,
+		})
+		if schema.Defs == nil {
+			schema.Defs = make(map[string]*JSONSchema, len(steps))
+		}
+		schema.Defs[StepSchemaName(step.StepNumber)] = stepSchema
+	}
+	sort.Slice(schema.Steps, func(i, j int) bool { return schema.Steps[i].StepNumber < schema.Steps[j].StepNumber })
+
+	return schema
+}
+
+// fieldSchema describes the value of a field with its validation rules, labels and file constraints
+func (s *FormService) fieldSchema(field db.FormField, now time.Time) *JSONSchema {
+	var rules ValidationRules
+	if field.ValidationRules != nil {
+		_ = json.Unmarshal(field.ValidationRules, &rules)
+	}
+	var options FieldOptions
+	if field.Options != nil {
+		_ = json.Unmarshal(field.Options, &options)
+	}
+
+	schema := &JSONSchema{
+		Title:        localizedLabel(field.Label, DefaultExportLocale, field.FieldName),
+		Description:  localizedLabel(field.HelpText, DefaultExportLocale, ""),
+		ReadOnly:     field.IsReadonly,
+		FieldType:    field.FieldType,
+		DisplayOrder: field.DisplayOrder,
+		Labels:       i18nText(field.Label),
+		Placeholder:  i18nText(field.Placeholder),
+		HelpText:     i18nText(field.HelpText),
+	}
+	if meta, ok := s.fieldMetadata([]db.FormField{field}, now)[field.FieldName]; ok {
+		schema.FieldMeta = &meta
+	}
+
+	switch field.FieldType {
+	case "text", "textarea", "email":
+		schema.Type = "string"
+		schema.MinLength, schema.MaxLength = rules.MinLength, rules.MaxLength
+		if rules.Pattern != nil {
+			schema.Pattern = *rules.Pattern
+		}
+		if field.FieldType == "email" || rules.Email {
+			schema.Format = "email"
+		}
+
+	case "number", "currency":
+		schema.Type = "number"
+		if rules.Min != nil {
+			schema.Minimum = json.Number(rules.Min.String())
+		}
+		if rules.Max != nil {
+			schema.Maximum = json.Number(rules.Max.String())
+		}
+
+	case "select", "radio":
+		schema.Type = "string"
+		schema.Enum, schema.OptionLabels = optionsEnum(options)
+		schema.OptionsSource = options.Dynamic
+
+	case "checkbox", FieldTypeCheckboxGroup, FieldTypeMultiSelect:
+		enum, labels := optionsEnum(options)
+		if field.FieldType == "checkbox" && len(enum) == 0 && options.Dynamic == nil {
+			schema.Type = "boolean"
+			break
+		}
+		schema.Type = "array"
+		schema.Items = &JSONSchema{Type: "string", Enum: enum}
+		schema.UniqueItems = true
+		schema.OptionLabels, schema.OptionsSource = labels, options.Dynamic
+		schema.MinItems, schema.MaxItems = rules.MinItems, rules.MaxItems
+		if rules.AllRequired && len(enum) > 0 {
+			all := len(enum)
+			schema.MinItems = &all
+		}
+
+	case FieldTypeDate:
+		schema.Type, schema.Format = "string", "date"
+
+	case FieldTypeDateTime:
+		schema.Type, schema.Format = "string", "date-time"
+
+	case FieldTypePhone:
+		schema.OneOf = []*JSONSchema{
+			{Type: "string"},
+			{
+				Type: "object",
+				Properties: map[string]*JSONSchema{
+					"country": countryCodeSchema(),
+					"number":  {Type: "string"},
+				},
+				Required: []string{"number"},
+			},
+		}
+
+	case FieldTypeNationalID:
+		schema.OneOf = []*JSONSchema{
+			{Type: "string"},
+			{
+				Type: "object",
+				Properties: map[string]*JSONSchema{
+					"country": countryCodeSchema(),
+					"type":    {Type: "string"},
+					"number":  {Type: "string"},
+				},
+				Required: []string{"number"},
+			},
+		}
+
+	case FieldTypeAddress:
+		maxLength := maxAddressPartLength
+		schema.Type = "object"
+		schema.Properties = make(map[string]*JSONSchema, len(AddressParts))
+		for _, part := range AddressParts {
+			schema.Properties[part] = &JSONSchema{Type: "string", MaxLength: &maxLength}
+		}
+		schema.Properties["country"] = countryCodeSchema()
+		schema.Required = addressRequiredParts(rules)
+		closed := false
+		schema.AdditionalProperties = &closed
+
+	case "file", "files", FieldTypeSignature:
+		config := s.fieldFileConfig(field)
+		file := &JSONSchema{Type: "string", ContentMediaType: "application/octet-stream"}
+		if len(config.AllowedTypes) == 1 {
+			file.ContentMediaType = config.AllowedTypes[0]
+		}
+		schema.File = &config
+		if field.FieldType != "files" {
+			schema.Type, schema.ContentMediaType = file.Type, file.ContentMediaType
+			break
+		}
+		schema.Type, schema.Items = "array", file
+		if config.MinFiles > 0 {
+			schema.MinItems = &config.MinFiles
+		}
+		if config.MaxFiles > 0 {
+			schema.MaxItems = &config.MaxFiles
+		}
+
+	case FieldTypeGroup, FieldTypeRepeater:
+		item := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema)}
+		itemFields, _ := s.groupItemFields(field)
+		for _, itemField := range itemFields {
+			item.Properties[itemField.FieldName] = s.fieldSchema(itemField, now)
+			if itemField.IsRequired {
+				item.Required = append(item.Required, itemField.FieldName)
+			}
+		}
+		maxItems := maxGroupItems
+		if rules.MaxItems != nil && *rules.MaxItems < maxItems {
+			maxItems = *rules.MaxItems
+		}
+		schema.Type, schema.Items = "array", item
+		schema.MinItems, schema.MaxItems = rules.MinItems, &maxItems
+	}
+
+	return schema
+}
+
+// fieldFileConfig returns the upload limits of a file field as they are enforced
+func (s *FormService) fieldFileConfig(field db.FormField) FileValidationConfig {
+	var config FileValidationConfig
+	if field.FileConfig != nil {
+		_ = json.Unmarshal(field.FileConfig, &config)
+	}
+	applySignatureFileDefaults(field.FieldType, &config.AllowedTypes, &config.MaxFiles, &config.MaxSize)
+	s.applyDefaultFileConfig(&config)
+	return config
+}
+
+func optionsEnum(options FieldOptions) ([]interface{}, map[string]I18nText) {
+	if len(options.Static) == 0 {
+		return nil, nil
+	}
+	enum := make([]interface{}, len(options.Static))
+	labels := make(map[string]I18nText, len(options.Static))
+	for i, option := range options.Static {
+		enum[i] = option.Value
+		labels[option.Value] = option.Label
+	}
+	return enum, labels
+}
+
+func countryCodeSchema() *JSONSchema {
+	return &JSONSchema{Type: "string", Pattern: "^[A-Za-z]{2}$"}
+}
+
+func i18nText(raw json.RawMessage) I18nText {
+	var text I18nText
+	if len(raw) == 0 || json.Unmarshal(raw, &text) != nil || len(text) == 0 {
+		return nil
+	}
+	return text
+}
+
+// conditionalRuleSchema turns the conditional logic of a field into an if/then rule requiring
+// the field when it is visible and required. Fields that are never required need no rule.
+func conditionalRuleSchema(field db.FormField, logic *ConditionalLogic) *JSONSchema {
+	required := &JSONSchema{Required: []string{field.FieldName}}
+	matched := conditionGroupSchema(logic.ConditionGroup)
+
+	switch logic.Action {
+	case ConditionActionHide:
+		if !field.IsRequired {
+			return nil
+		}
+		return &JSONSchema{If: matched, Else: required}
+	case ConditionActionRequire:
+		if field.IsRequired {
+			return required
+		}
+		return &JSONSchema{If: matched, Then: required}
+	default:
+		if !field.IsRequired {
+			return nil
+		}
+		return &JSONSchema{If: matched, Then: required}
+	}
+}
+
+func conditionGroupSchema(group ConditionGroup) *JSONSchema {
+	parts := make([]*JSONSchema, 0, len(group.Conditions)+len(group.Groups))
+	for _, condition := range group.Conditions {
+		parts = append(parts, conditionSchema(condition))
+	}
+	for _, nested := range group.Groups {
+		parts = append(parts, conditionGroupSchema(nested))
+	}
+
+	switch {
+	case len(parts) == 0:
+		return &JSONSchema{}
+	case len(parts) == 1:
+		return parts[0]
+	case group.Logic == "any":
+		return &JSONSchema{AnyOf: parts}
+	default:
+		return &JSONSchema{AllOf: parts}
+	}
+}
+
+// conditionSchema matches the submissions a condition holds for. Comparisons of values that
+// aren't numbers, like ISO dates, can't be expressed and always match.
+func conditionSchema(condition Condition) *JSONSchema {
+	has := func(value *JSONSchema) *JSONSchema {
+		return &JSONSchema{
+			Properties: map[string]*JSONSchema{condition.Field: value},
+			Required:   []string{condition.Field},
+		}
+	}
+	blank := &JSONSchema{AnyOf: []*JSONSchema{
+		{Not: &JSONSchema{Required: []string{condition.Field}}},
+		has(&JSONSchema{AnyOf: []*JSONSchema{
+			{Type: "null"},
+			{Type: "string", Pattern: `^\s*$`},
+			{Type: "array", MaxItems: intValue(0)},
+			{Type: "object", MaxProperties: intValue(0)},
+		}}),
+	}}
+	never := &JSONSchema{Not: &JSONSchema{}}
+
+	switch condition.Operator {
+	case OperatorEquals, OperatorNotEquals:
+		equals := has(&JSONSchema{Const: condition.Value})
+		if condition.Value == nil {
+			equals = &JSONSchema{AnyOf: []*JSONSchema{
+				{Not: &JSONSchema{Required: []string{condition.Field}}},
+				has(&JSONSchema{Type: "null"}),
+			}}
+		}
+		if condition.Operator == OperatorNotEquals {
+			return &JSONSchema{Not: equals}
+		}
+		return equals
+
+	case OperatorIn, OperatorNotIn:
+		list, ok := condition.Value.([]interface{})
+		if !ok {
+			return never
+		}
+		in := has(&JSONSchema{Enum: list})
+		if condition.Operator == OperatorNotIn {
+			return &JSONSchema{Not: in}
+		}
+		return in
+
+	case OperatorGt, OperatorGte, OperatorLt, OperatorLte:
+		bound, ok := conditionBound(condition.Value)
+		if !ok {
+			return &JSONSchema{Comment: fmt.Sprintf("%s %s %v is checked by the server", condition.Field, condition.Operator, condition.Value)}
+		}
+		value := &JSONSchema{Type: "number"}
+		switch condition.Operator {
+		case OperatorGt:
+			value.ExclusiveMinimum = bound
+		case OperatorGte:
+			value.Minimum = bound
+		case OperatorLt:
+			value.ExclusiveMaximum = bound
+		default:
+			value.Maximum = bound
+		}
+		return has(value)
+
+	case OperatorContains:
+		inList := &JSONSchema{Type: "array", Contains: &JSONSchema{Const: condition.Value}}
+		str, ok := condition.Value.(string)
+		if !ok {
+			return has(inList)
+		}
+		return has(&JSONSchema{AnyOf: []*JSONSchema{
+			{Type: "string", Pattern: caseInsensitivePattern(str)},
+			inList,
+		}})
+
+	case OperatorIsEmpty:
+		return blank
+	case OperatorIsNotEmpty:
+		return &JSONSchema{Not: blank}
+	default:
+		// Unknown operators never block a rule
+		return &JSONSchema{}
+	}
+}
+
+// conditionBound returns the number a comparison is against, accepting numeric strings
+func conditionBound(value interface{}) (json.Number, bool) {
+	switch v := value.(type) {
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'f', -1, 64)), true
+	case string:
+		if _, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return json.Number(strings.TrimSpace(v)), true
+		}
+	}
+	return "", false
+}
+
+// caseInsensitivePattern matches strings containing value, ignoring case. ECMA 262 patterns
+// have no inline flags, so letters become classes like [aA].
+func caseInsensitivePattern(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		lower, upper := unicode.ToLower(r), unicode.ToUpper(r)
+		switch {
+		case lower != upper:
+			b.WriteString("[" + string(lower) + string(upper) + "]")
+		case strings.ContainsRune(`\^$.|?*+()[]{}/-`, r):
+			b.WriteString(`\` + string(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func intValue(n int) *int {
+	return &n
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+)
+
+func schemaTestForm() (db.FormDefinition, []db.FormStep, []db.FormField) {
+	form := db.FormDefinition{ID: uuid.New(), Name: "Business KYB", Slug: "kyb", FormType: "kyb", Version: 3, IsMultiStep: true}
+	business := db.FormStep{ID: uuid.New(), StepNumber: 1, Name: "Business"}
+	documents := db.FormStep{ID: uuid.New(), StepNumber: 2, Name: "Documents"}
+
+	fields := []db.FormField{
+		{
+			FormStepID:      business.ID,
+			FieldName:       "business_name",
+			FieldType:       "text",
+			Label:           json.RawMessage(`{"en":"Business name","fr":"Nom de l'entreprise"}`),
+			ValidationRules: json.RawMessage(`{"min_length":2,"max_length":100}`),
+			DisplayOrder:    1,
+			IsRequired:      true,
+		},
+		{
+			FormStepID:   business.ID,
+			FieldName:    "business_type",
+			FieldType:    "select",
+			Options:      json.RawMessage(`{"type":"static","static":[{"value":"llc","label":{"en":"LLC"}},{"value":"sole","label":{"en":"Sole proprietor"}}]}`),
+			DisplayOrder: 2,
+			IsRequired:   true,
+		},
+		{
+			FormStepID:       business.ID,
+			FieldName:        "rc_number",
+			FieldType:        "text",
+			DisplayOrder:     3,
+			IsRequired:       true,
+			ConditionalLogic: json.RawMessage(`{"action":"show","logic":"all","conditions":[{"field":"business_type","operator":"equals","value":"llc"}]}`),
+		},
+		{
+			FormStepID:      business.ID,
+			FieldName:       "annual_revenue",
+			FieldType:       "currency",
+			ValidationRules: json.RawMessage(`{"min":0,"max":1000000000}`),
+			DisplayOrder:    4,
+		},
+		{
+			FormStepID:   documents.ID,
+			FieldName:    "cac_document",
+			FieldType:    "files",
+			FileConfig:   json.RawMessage(`{"max_size":5242880,"allowed_types":["application/pdf"],"max_files":2}`),
+			DisplayOrder: 5,
+			IsRequired:   true,
+		},
+	}
+	return form, []db.FormStep{documents, business}, fields
+}
+
+func TestBuildFormJSONSchema(t *testing.T) {
+	s := &FormService{}
+	form, steps, fields := schemaTestForm()
+	now := time.Date(2026, 3, 15, 10, 30, 0, 0, time.UTC)
+
+	schema := s.BuildFormJSONSchema(form, 3, steps, fields, now)
+
+	require.Equal(t, JSONSchemaDialect, schema.Schema)
+	require.Equal(t, int32(3), schema.Form.Version)
+	require.Equal(t, []string{"business_name", "business_type", "cac_document"}, schema.Required)
+
+	name := schema.Properties["business_name"]
+	require.Equal(t, "string", name.Type)
+	require.Equal(t, "Business name", name.Title)
+	require.Equal(t, "Nom de l'entreprise", name.Labels["fr"])
+	require.Equal(t, 100, *name.MaxLength)
+	require.Equal(t, int32(1), name.Step)
+
+	require.Equal(t, []interface{}{"llc", "sole"}, schema.Properties["business_type"].Enum)
+	require.Equal(t, json.Number("1000000000"), schema.Properties["annual_revenue"].Maximum)
+
+	documents := schema.Properties["cac_document"]
+	require.Equal(t, "array", documents.Type)
+	require.Equal(t, "application/pdf", documents.Items.ContentMediaType)
+	require.Equal(t, 2, *documents.MaxItems)
+	require.Equal(t, int64(5242880), documents.File.MaxSize)
+
+	// The RC number is only required when it is shown
+	require.Len(t, schema.AllOf, 1)
+	require.Equal(t, []string{"rc_number"}, schema.AllOf[0].Then.Required)
+	require.Equal(t, "llc", schema.AllOf[0].If.Properties["business_type"].Const)
+
+	require.Equal(t, []SchemaStep{
+		{StepNumber: 1, Name: "Business", Fields: []string{"business_name", "business_type", "rc_number", "annual_revenue"}},
+		{StepNumber: 2, Name: "Documents", Fields: []string{"cac_document"}},
+	}, schema.Steps)
+	require.Equal(t, []string{"business_name", "business_type"}, schema.Defs["step_1"].Required)
+
+	_, err := json.Marshal(schema)
+	require.NoError(t, err)
+}
+
+func TestConditionSchema(t *testing.T) {
+	gte := conditionSchema(Condition{Field: "employees", Operator: OperatorGte, Value: "10"})
+	require.Equal(t, json.Number("10"), gte.Properties["employees"].Minimum)
+	require.Equal(t, []string{"employees"}, gte.Required)
+
+	notIn := conditionSchema(Condition{Field: "country", Operator: OperatorNotIn, Value: []interface{}{"NG", "GH"}})
+	require.Equal(t, []interface{}{"NG", "GH"}, notIn.Not.Properties["country"].Enum)
+
+	contains := conditionSchema(Condition{Field: "name", Operator: OperatorContains, Value: "Ltd."})
+	require.Equal(t, `[lL][tT][dD]\.`, contains.Properties["name"].AnyOf[0].Pattern)
+
+	// Dates can't be compared by JSON Schema, so the condition is left to the server
+	after := conditionSchema(Condition{Field: "incorporated_on", Operator: OperatorGt, Value: "2020-01-01"})
+	require.Nil(t, after.Properties)
+	require.NotEmpty(t, after.Comment)
+
+	group := conditionGroupSchema(ConditionGroup{Logic: "any", Conditions: []Condition{
+		{Field: "a", Operator: OperatorIsEmpty},
+		{Field: "b", Operator: OperatorIsNotEmpty},
+	}})
+	require.Len(t, group.AnyOf, 2)
+	require.NotNil(t, group.AnyOf[1].Not)
+}
+
+func TestBuildFormOpenAPI(t *testing.T) {
+	s := &FormService{}
+	form, steps, fields := schemaTestForm()
+
+	doc := BuildFormOpenAPI(s.BuildFormJSONSchema(form, 3, steps, fields, time.Now()), "/api/v1")
+
+	require.Equal(t, OpenAPIVersion, doc.OpenAPI)
+	require.Equal(t, "3", doc.Info.Version)
+	require.Equal(t, "/api/v1", doc.Servers[0].URL)
+
+	submit := doc.Paths["/forms/"+form.ID.String()+"/submit"]["post"]
+	require.NotNil(t, submit)
+	media := submit.RequestBody.Content["multipart/form-data"]
+	require.Equal(t, "#/components/schemas/Submission", media.Schema.AllOf[0].Ref)
+	require.Equal(t, "application/pdf", media.Encoding["cac_document"].ContentType)
+
+	step := doc.Paths["/forms/submissions/{submission_id}/steps/2"]["put"]
+	require.NotNil(t, step)
+	require.Equal(t, "#/components/schemas/step_2", step.RequestBody.Content["multipart/form-data"].Schema.AllOf[0].Ref)
+	require.Contains(t, step.Responses, "409")
+
+	require.Empty(t, doc.Components.Schemas["SubmissionDraft"].Required)
+	require.Empty(t, doc.Components.Schemas["Submission"].Defs)
+	require.NotEmpty(t, doc.Components.Schemas["Submission"].Required)
+
+	_, err := json.Marshal(doc)
+	require.NoError(t, err)
+}
@@ -7,14 +7,16 @@ import (
 	"fmt"
 	"github.com/timchuks/monieverse/internal/config"
 	"github.com/timchuks/monieverse/internal/logger"
-	"log"
 	"mime/multipart"
+	"net/http"
 	"path/filepath"
 	"regexp"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/mailer"
 	"github.com/timchuks/monieverse/internal/notifier"
 	"github.com/timchuks/monieverse/internal/uploader"
 	"github.com/timchuks/monieverse/internal/validator"
@@ -26,9 +28,17 @@ type FormService struct {
 	uploader        uploader.FileUploader
 	notifier        notifier.Notifier
 	taskDistributor worker.TaskDistributor
+	mailer          Mailer
 	config          *config.Config
 	logger          logger.Logger
 	fileValidator   *FileValidator
+	// registry guards eventHandlers, optionSources and validationHooks, which can be
+	// registered while the service is in use
+	registry        sync.RWMutex
+	eventHandlers   map[string]EventHandler
+	optionSources   map[string]OptionSource
+	optionCache     *optionCache
+	validationHooks map[string]ValidationHook
 }
 
 type CreateSubmissionInput struct {
@@ -51,6 +61,10 @@ type UpdateSubmissionInput struct {
 	IsPartialUpdate bool                               `json:"is_partial_update"`
 	Metadata        map[string]interface{}             `json:"metadata"`
 	StepNumber      *int32                             `json:"step_number"`
+	// ExpectedRevision is the revision the update was based on; 0 skips the check
+	ExpectedRevision int32 `json:"expected_revision"`
+	// revisionSource is recorded with the revision the update makes
+	revisionSource string
 }
 
 type SaveStepProgressInput struct {
@@ -61,6 +75,8 @@ type SaveStepProgressInput struct {
 	Data         map[string]interface{}             `json:"data"`
 	Files        map[string][]*multipart.FileHeader `json:"-"`
 	Metadata     map[string]interface{}             `json:"metadata"`
+	// ExpectedRevision is the revision of the step the save was based on; 0 skips the check
+	ExpectedRevision int32 `json:"expected_revision"`
 }
 
 type StepProgressResult struct {
@@ -68,6 +84,7 @@ type StepProgressResult struct {
 	CurrentStep          int32 `json:"current_step"`
 	CompletionPercentage int32 `json:"completion_percentage"`
 	AllStepsCompleted    bool  `json:"all_steps_completed"`
+	Revision             int32 `json:"revision"` // of the saved step
 }
 
 func NewFormService(
@@ -82,94 +99,42 @@ func NewFormService(
 ) *FormService {
 
 	fileValidator := NewFileValidator(config, virusScanner, contentValidator)
-	return &FormService{
+	mail := mailer.New(*config)
+	s := &FormService{
 		store:           store,
 		uploader:        uploader,
 		notifier:        notifier,
 		taskDistributor: taskDistributor,
+		mailer:          mail,
 		fileValidator:   fileValidator,
 		config:          config,
+		logger:          logger,
+		eventHandlers: map[string]EventHandler{
+			HandlerTypeWebhook:      NewWebhookHandler(&http.Client{Timeout: 30 * time.Second}),
+			HandlerTypeEmail:        NewEmailHandler(mail, store),
+			HandlerTypeTask:         NewTaskHandler(taskDistributor),
+			HandlerTypeSetUserField: NewSetUserFieldHandler(store),
+		},
+		optionCache:     newOptionCache(),
+		validationHooks: map[string]ValidationHook{},
 	}
+	s.optionSources = defaultOptionSources(store, s.optionSource)
+	return s
 }
 
 // GetFormForUser retrieves the appropriate form based on assignments
 func (s *FormService) GetFormForUser(ctx context.Context, userID uuid.UUID, formType string) (*FormDefinitionWithData, error) {
-	user, err := s.store.GetUser(ctx, userID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get user: %w", err)
-	}
-
-	// Get assignments
-	assignments, err := s.store.GetFormAssignments(ctx, db.GetFormAssignmentsParams{
-		AssignmentValue:   userID.String(),
-		AssignmentValue_2: user.AccountType, // user_type
-		AssignmentValue_3: user.CountryCode, // country
-		AssignmentValue_4: user.State,       // state
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to get form assignments: %w", err)
-	}
-
-	// Find best matching assignment
-	var bestAssignment *db.GetFormAssignmentsRow
-	for _, assignment := range assignments {
-		if assignment.FormType != formType {
-			continue
-		}
-
-		// Check conditions
-		var conditions map[string]interface{}
-		if err := json.Unmarshal(assignment.Conditions.RawMessage, &conditions); err == nil {
-			if !s.checkConditions(&user, conditions) {
-				continue
-			}
-		}
-
-		if bestAssignment == nil || assignment.Priority > bestAssignment.Priority {
-			bestAssignment = &assignment
-		}
-	}
-
-	if bestAssignment == nil {
-		return nil, fmt.Errorf("no form found for user")
-	}
-
-	// Get form definition
-	form, err := s.store.GetFormDefinition(ctx, bestAssignment.FormDefinitionID)
+	assignment, err := s.ResolveFormAssignment(ctx, userID, formType)
 	if err != nil {
 		return nil, err
 	}
 
-	// Get steps
-	steps, err := s.store.GetFormSteps(ctx, form.ID)
-	if err != nil {
-		return nil, err
-	}
-
-	// Get fields
-	fields, err := s.store.GetFormFields(ctx, form.ID)
+	// Get form definition
+	form, err := s.store.GetFormDefinition(ctx, assignment.FormDefinitionID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Process dynamic options
-	for i, field := range fields {
-		if field.Options != nil {
-			var options FieldOptions
-			if err = json.Unmarshal(field.Options, &options); err == nil {
-				if options.Type == "dynamic" && options.Dynamic != nil {
-					dynamicOptions, err := s.getDynamicOptions(ctx, options.Dynamic)
-					if err == nil {
-						options.Static = dynamicOptions
-						optionsJSON, _ := json.Marshal(options)
-						fields[i].Options = optionsJSON
-					}
-				}
-			}
-		}
-	}
-
 	// Get existing submission if any
 	submission, _ := s.store.GetFormSubmissionByUserAndForm(ctx, db.GetFormSubmissionByUserAndFormParams{
 		UserID:           userID,
@@ -177,11 +142,27 @@ func (s *FormService) GetFormForUser(ctx context.Context, userID uuid.UUID, form
 		Status:           "draft",
 	})
 
+	// A draft keeps the steps and fields of the version it was started on
+	steps, fields, err := s.formStructure(ctx, form, submission.FormVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill answers from the user's profile, business and KYC data
+	prefilled := s.prefillForm(ctx, userID, fields)
+	existingData := prefillData(submission.SubmissionData, prefilled)
+
+	// Process dynamic options
+	s.resolveFieldOptions(ctx, fields, existingData)
+
 	return &FormDefinitionWithData{
 		FormDefinition: form,
 		Steps:          steps,
 		Fields:         fields,
-		ExistingData:   submission.SubmissionData,
+		ExistingData:   existingData,
+		ReviewComments: s.reviewComments(ctx, submission),
+		FieldMeta:      s.fieldMetadata(fields, time.Now()),
+		Prefilled:      prefilled,
 	}, nil
 }
 
@@ -197,24 +178,40 @@ func (s *FormService) SubmitForm(ctx context.Context, input SubmitFormInput) (*d
 		return nil, fmt.Errorf("form is not active")
 	}
 
-	// Get fields for validation
-	fields, err := s.store.GetFormFields(ctx, form.ID)
+	// Get steps and fields for validation
+	steps, fields, err := s.formStructure(ctx, form, form.Version)
 	if err != nil {
 		return nil, err
 	}
 
 	// Validate submission
-	if err := s.validateSubmission(fields, input.Data); err != nil {
+	input.Data = s.normalizeFieldValues(fields, s.nestGroupValues(fields, input.Data))
+	input.Data, err = s.lockPrefilledValues(ctx, input.UserID, fields, input.Data)
+	if err != nil {
+		return nil, err
+	}
+	states := s.ResolveFieldStates(fields, input.Data)
+	err = s.validateCustomRules(ctx, customRuleScope{
+		FormID: form.ID,
+		UserID: input.UserID,
+		Fields: fields,
+		Steps:  steps,
+		Data:   input.Data,
+		States: states,
+	}, s.validateSubmission(fields, input.Data, states))
+	if err != nil {
+		s.recordValidationFailures(ctx, form.ID, form.Version, uuid.NullUUID{}, input.UserID, nil, err)
 		return nil, err
 	}
 
-	// Trigger before_submit events
-	s.triggerEvents(ctx, form.ID, "before_submit", input)
+	// Hidden fields are never persisted
+	input.Data = s.StripHiddenValues(input.Data, states)
+	input.Files = s.stripHiddenFiles(input.Files, states)
 
 	// Handle file uploads
 	var submissionFiles []db.FormSubmissionFileInput
 	for fieldName, fileHeaders := range input.Files {
-		field := s.findField(fields, fieldName)
+		field := s.findUploadField(fields, fieldName)
 		if field == nil {
 			continue
 		}
@@ -227,6 +224,7 @@ func (s *FormService) SubmitForm(ctx context.Context, input SubmitFormInput) (*d
 				return nil, err
 			}
 		}
+		applySignatureFileDefaults(field.FieldType, &fileConfig.AllowedTypes, &fileConfig.MaxFiles, &fileConfig.MaxSize)
 
 		uploadedFiles, err := s.handleFileUploads(ctx, input.UserID, fieldName, fileHeaders, &fileConfig)
 		if err != nil {
@@ -244,34 +242,40 @@ func (s *FormService) SubmitForm(ctx context.Context, input SubmitFormInput) (*d
 		Data:             input.Data,
 		Files:            submissionFiles,
 		Metadata:         input.Metadata,
+		FormVersion:      form.Version,
 	}
 
-	submission, err := s.store.ProcessFormSubmissionTx(ctx, submissionInput)
+	submission, err := s.store.ProcessFormSubmissionTx(ctx, submissionInput, func(submission *db.FormSubmission) ([]db.EnqueueFormEventsParams, error) {
+		return outboxEvents(form.ID,
+			formEvent{eventType: "before_submit", data: input, submission: submission},
+			formEvent{eventType: "after_submit", data: submission},
+			savedEvent("", submission),
+		)
+	})
 	if err != nil {
 		return nil, err
 	}
+	s.eventsEnqueued(ctx)
 
-	// Trigger after_submit events
-	s.triggerEvents(ctx, form.ID, "after_submit", submission)
-
-	// Send notifications
-	if form.RequiresApproval {
-		//s.sendApprovalNotification(ctx, &user, form, submission)
-		log.Printf("form approved")
-	}
+	s.startApproval(ctx, form, submission, input.UserID)
 
 	return submission, nil
 }
 
 // validateSubmission validates form data
-func (s *FormService) validateSubmission(fields []db.FormField, data map[string]interface{}) error {
+func (s *FormService) validateSubmission(fields []db.FormField, data map[string]interface{}, states map[string]FieldState) error {
 	v := validator.New()
 
 	for _, field := range fields {
+		state := s.fieldState(field, states)
+		if !state.Visible {
+			continue
+		}
+
 		value, exists := data[field.FieldName]
 
 		// Check required
-		if field.IsRequired && (!exists || s.isEmpty(value)) {
+		if state.Required && (!exists || s.isEmpty(value)) {
 			v.AddError(field.FieldName, "field is required")
 			continue
 		}
@@ -280,6 +284,19 @@ func (s *FormService) validateSubmission(fields []db.FormField, data map[string]
 			continue
 		}
 
+		if IsGroupFieldType(field.FieldType) {
+			if err := s.validateGroup(v, field, value, true); err != nil {
+				return err
+			}
+			continue
+		}
+		if IsChoiceListFieldType(field.FieldType) {
+			if err := s.validateChoices(v, field, value, true); err != nil {
+				return err
+			}
+			continue
+		}
+
 		// Parse validation rules
 		var rules ValidationRules
 		if field.ValidationRules != nil {
@@ -296,12 +313,12 @@ func (s *FormService) validateSubmission(fields []db.FormField, data map[string]
 		case "text", "textarea":
 			if str, ok := value.(string); ok {
 				if rules.MinLength != nil {
-					v.Check(len(str) >= *rules.MinLength, field.FieldName,
-						fmt.Sprintf("must be at least %d characters", *rules.MinLength))
+					v.CheckMessage(len(str) >= *rules.MinLength, field.FieldName,
+						validator.Msg("must be at least %d characters", *rules.MinLength))
 				}
 				if rules.MaxLength != nil {
-					v.Check(len(str) <= *rules.MaxLength, field.FieldName,
-						fmt.Sprintf("must be at most %d characters", *rules.MaxLength))
+					v.CheckMessage(len(str) <= *rules.MaxLength, field.FieldName,
+						validator.Msg("must be at most %d characters", *rules.MaxLength))
 				}
 				if rules.Pattern != nil {
 					// Pattern validation
@@ -314,13 +331,13 @@ func (s *FormService) validateSubmission(fields []db.FormField, data map[string]
 			if num, ok := s.toFloat64(value); ok {
 				if rules.Min != nil {
 					minVal, _ := rules.Min.Float64()
-					v.Check(num >= minVal, field.FieldName,
-						fmt.Sprintf("must be at least %v", minVal))
+					v.CheckMessage(num >= minVal, field.FieldName,
+						validator.Msg("must be at least %v", minVal))
 				}
 				if rules.Max != nil {
 					maxVal, _ := rules.Max.Float64()
-					v.Check(num <= maxVal, field.FieldName,
-						fmt.Sprintf("must be at most %v", maxVal))
+					v.CheckMessage(num <= maxVal, field.FieldName,
+						validator.Msg("must be at most %v", maxVal))
 				}
 			}
 
@@ -337,11 +354,16 @@ func (s *FormService) validateSubmission(fields []db.FormField, data map[string]
 				}
 				v.Check(validator.In(value.(string), validOptions...), field.FieldName, "invalid option")
 			}
+
+		default:
+			if err := s.validateFieldValue(v, field, value); err != nil {
+				return err
+			}
 		}
 	}
 
 	if !v.Valid() {
-		return validator.NewValidationError("validation failed", v.Errors)
+		return v.ValidationError("validation failed")
 	}
 
 	return nil
@@ -386,12 +408,18 @@ func (s *FormService) handleFileUploads(
 		filename := fmt.Sprintf("%s_%d_%s%s", fieldName, time.Now().Unix(), uuid.New().String()[:8], ext)
 		path := fmt.Sprintf("forms/%s/%s/%s", userID.String(), fieldName, filename)
 
+		// Infected files are quarantined instead of uploaded
+		scan, err := s.scanUpload(file, userID, fieldName, fileHeader.Filename, path)
+		if err != nil {
+			return nil, err
+		}
+
 		// Upload
 		if err := s.uploader.Upload(file, s.config.FileBucket, path); err != nil {
 			return nil, fmt.Errorf("failed to upload file: %w", err)
 		}
 
-		result = append(result, db.FormSubmissionFileInput{
+		result = append(result, withScanResult(db.FormSubmissionFileInput{
 			FieldName:       fieldName,
 			FileName:        fileHeader.Filename,
 			FilePath:        path,
@@ -399,101 +427,13 @@ func (s *FormService) handleFileUploads(
 			MimeType:        fileHeader.Header.Get("Content-Type"),
 			Bucket:          s.config.FileBucket,
 			StorageProvider: s.config.FileUploadProvider,
-		})
+		}, scan))
 	}
 
 	return result, nil
 }
 
-// getDynamicOptions fetches options from dynamic source
-func (s *FormService) getDynamicOptions(ctx context.Context, source *DynamicSource) ([]Option, error) {
-	// Get dynamic option config
-	dynamicOption, err := s.store.GetDynamicOptionByName(ctx, source.SourceName)
-	if err != nil {
-		return nil, err
-	}
-
-	var sourceConfig map[string]interface{}
-	json.Unmarshal(dynamicOption.SourceConfig, &sourceConfig)
-
-	switch dynamicOption.SourceType {
-	case "table":
-		tableName := sourceConfig["table"].(string)
-
-		switch tableName {
-		case "countries":
-			rows, err := s.store.GetCountries(ctx, db.CountryQueryFilter{
-				ActiveOnly: true,
-			}, db.Filter{
-				PageSize: 250,
-			})
-			if err != nil {
-				return nil, err
-			}
-
-			options := make([]Option, len(rows))
-			for i, row := range rows {
-				options[i] = Option{
-					Value: row.Code,
-					Label: I18nText{"en": row.Name},
-				}
-			}
-			return options, nil
-
-		}
-
-	}
-
-	return nil, fmt.Errorf("unknown dynamic source")
-}
-
-// triggerEvents processes form events
-func (s *FormService) triggerEvents(ctx context.Context, formID uuid.UUID, eventType string, data interface{}) {
-	events, err := s.store.GetFormEvents(ctx, db.GetFormEventsParams{
-		FormDefinitionID: formID,
-		EventType:        eventType,
-	})
-
-	if err != nil {
-		return
-	}
-
-	for _, event := range events {
-		if !event.IsActive {
-			continue
-		}
-		var config map[string]interface{}
-		json.Unmarshal(event.HandlerConfig, &config)
-
-		log.Printf("event %s triggered for form %s", eventType, formID)
-	}
-}
-
 // Helper methods
-func (s *FormService) checkConditions(user *db.User, conditions map[string]interface{}) bool {
-	for key, value := range conditions {
-		switch key {
-		case "new_customers_only":
-			if value.(bool) {
-				// Check if user registered within last 30 days
-				thirtyDaysAgo := time.Now().AddDate(0, 0, -30)
-				if user.CreatedAt.Before(thirtyDaysAgo) {
-					return false
-				}
-			}
-		case "user_type":
-			if user.AccountType != value.(string) {
-				return false
-			}
-		case "kyc_verified":
-			if value.(bool) && user.KycVerified != "verified" {
-				return false
-			}
-		}
-	}
-	return true
-}
-
 func (s *FormService) findField(fields []db.FormField, fieldName string) *db.FormField {
 	for _, field := range fields {
 		if field.FieldName == fieldName {
@@ -552,78 +492,17 @@ func (s *FormService) sendApprovalNotification(ctx context.Context, user *db.Use
 
 }
 
-// ApproveSubmission approves a form submission
+// ApproveSubmission approves the active stage of a submission's approval workflow that the
+// approver is permitted to decide. The submission is approved once its last stage is.
 func (s *FormService) ApproveSubmission(ctx context.Context, submissionID uuid.UUID, approverID uuid.UUID, notes string) error {
-	submission, err := s.store.GetFormSubmission(ctx, submissionID)
-	if err != nil {
-		return err
-	}
-
-	form, err := s.store.GetFormDefinition(ctx, submission.FormDefinitionID)
-	if err != nil {
-		return err
-	}
-
-	// Trigger before_approve events
-	s.triggerEvents(ctx, form.ID, "before_approve", submission)
-
-	// Update submission
-	_, err = s.store.UpdateFormSubmission(ctx, db.UpdateFormSubmissionParams{
-		ID:             submissionID,
-		SubmissionData: submission.SubmissionData,
-		Status:         "approved",
-		ApprovalStatus: "approved",
-		ApprovalNotes:  notes,
-		ApprovedBy:     db.NewNullUUID(approverID),
-		ApprovedAt:     time.Now(),
-		Metadata:       submission.Metadata,
-	})
-
-	if err != nil {
-		return err
-	}
-
-	// Trigger after_approve events
-	s.triggerEvents(ctx, form.ID, "after_approve", submission)
-
-	return nil
+	_, err := s.decideApproval(ctx, submissionID, approverID, db.ApprovalActionApproved, notes, nil)
+	return err
 }
 
-// RejectSubmission rejects a form submission
+// RejectSubmission rejects a form submission, ending its approval workflow
 func (s *FormService) RejectSubmission(ctx context.Context, submissionID uuid.UUID, approverID uuid.UUID, reason string) error {
-	submission, err := s.store.GetFormSubmission(ctx, submissionID)
-	if err != nil {
-		return err
-	}
-
-	form, err := s.store.GetFormDefinition(ctx, submission.FormDefinitionID)
-	if err != nil {
-		return err
-	}
-
-	// Trigger before_reject events
-	s.triggerEvents(ctx, form.ID, "before_reject", submission)
-
-	// Update submission
-	_, err = s.store.UpdateFormSubmission(ctx, db.UpdateFormSubmissionParams{
-		ID:             submissionID,
-		SubmissionData: submission.SubmissionData,
-		Status:         "rejected",
-		ApprovalStatus: "rejected",
-		ApprovalNotes:  reason,
-		ApprovedBy:     db.NewNullUUID(approverID),
-		ApprovedAt:     time.Now(),
-		Metadata:       submission.Metadata,
-	})
-
-	if err != nil {
-		return err
-	}
-
-	// Trigger after_reject events
-	s.triggerEvents(ctx, form.ID, "after_reject", submission)
-
-	return nil
+	_, err := s.decideApproval(ctx, submissionID, approverID, db.ApprovalActionRejected, reason, nil)
+	return err
 }
 
 // GetFormForEdit retrieves a form with existing submission data for editing
@@ -659,14 +538,8 @@ func (s *FormService) GetFormForEdit(ctx context.Context, submissionID uuid.UUID
 		return nil, fmt.Errorf("cannot edit %s submission", submission.ApprovalStatus)
 	}
 
-	// Get steps
-	steps, err := s.store.GetFormSteps(ctx, form.ID)
-	if err != nil {
-		return nil, err
-	}
-
-	// Get fields
-	fields, err := s.store.GetFormFields(ctx, form.ID)
+	// Get the steps and fields of the version the submission is pinned to
+	steps, fields, err := s.formStructure(ctx, form, submission.FormVersion)
 	if err != nil {
 		return nil, err
 	}
@@ -677,6 +550,11 @@ func (s *FormService) GetFormForEdit(ctx context.Context, submissionID uuid.UUID
 		return nil, fmt.Errorf("failed to parse submission data: %w", err)
 	}
 
+	// Fill answers from the submitter's data; locked fields show the verified values
+	prefilled := s.prefillForm(ctx, submission.UserID, fields)
+	submissionData = mergePrefill(submissionData, prefilled)
+	existingData := prefillData(submission.SubmissionData, prefilled)
+
 	// Get files
 	files, _ := s.store.GetFormSubmissionFiles(ctx, submissionID)
 	fileMap := make(map[string][]map[string]interface{})
@@ -691,12 +569,20 @@ func (s *FormService) GetFormForEdit(ctx context.Context, submissionID uuid.UUID
 		fileMap[file.FieldName] = append(fileMap[file.FieldName], fileInfo)
 	}
 
+	// Files of group items are keyed like owners[0].id_document
+	groupFiles := make(map[string][]map[string]interface{})
+	for key, files := range fileMap {
+		if _, _, _, ok := parseGroupItemFieldName(key); ok {
+			groupFiles[key] = files
+		}
+	}
+
 	// Set default values from submission data
 	for i, field := range fields {
 		fieldName := field.FieldName
 
 		// Check for file fields
-		if field.FieldType == "file" || field.FieldType == "files" {
+		if IsUploadFieldType(field.FieldType) {
 			if files, ok := fileMap[fieldName]; ok {
 				fileData, _ := json.Marshal(files)
 				fields[i].DefaultValue = string(fileData)
@@ -722,29 +608,21 @@ func (s *FormService) GetFormForEdit(ctx context.Context, submissionID uuid.UUID
 
 			fields[i].DefaultValue = defaultValue
 		}
-
-		// Process dynamic options
-		if field.Options != nil {
-			var options FieldOptions
-			if err := json.Unmarshal(field.Options, &options); err == nil {
-				if options.Type == "dynamic" && options.Dynamic != nil {
-					dynamicOptions, err := s.getDynamicOptions(ctx, options.Dynamic)
-					if err == nil {
-						options.Static = dynamicOptions
-						optionsJSON, _ := json.Marshal(options)
-						fields[i].Options = optionsJSON
-					}
-				}
-			}
-		}
 	}
 
+	// Process dynamic options
+	s.resolveFieldOptions(ctx, fields, existingData)
+
 	return &FormDefinitionWithData{
 		FormDefinition: form,
 		Steps:          steps,
 		Fields:         fields,
-		ExistingData:   submission.SubmissionData,
+		ExistingData:   existingData,
 		SubmissionID:   &submission.ID,
+		ReviewComments: s.reviewComments(ctx, submission),
+		GroupFiles:     groupFiles,
+		FieldMeta:      s.fieldMetadata(fields, time.Now()),
+		Prefilled:      prefilled,
 	}, nil
 }
 
@@ -781,15 +659,31 @@ func (s *FormService) UpdateFormSubmission(ctx context.Context, input UpdateSubm
 		return nil, fmt.Errorf("cannot edit %s submission", submission.ApprovalStatus)
 	}
 
-	// Get fields for validation
-	fields, err := s.store.GetFormFields(ctx, form.ID)
+	// Get fields for validation from the version the submission is pinned to
+	steps, fields, err := s.formStructure(ctx, form, submission.FormVersion)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get form fields: %w", err)
 	}
 
+	input.Data = s.normalizeFieldValues(fields, s.nestGroupValues(fields, input.Data))
+
+	// Locked fields hold the verified data of the submitter, whoever edits the submission
+	input.Data, err = s.lockPrefilledValues(ctx, submission.UserID, fields, input.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve conditional logic against the saved answers the update builds on
+	conditionData := input.Data
+	if input.IsPartialUpdate {
+		conditionData = s.mergeConditionData(submission.SubmissionData, input.Data)
+	}
+	states := s.ResolveFieldStates(fields, conditionData)
+
 	// Determine validation context
 	validationCtx := ValidationContext{
 		ProvidedFields: input.Data,
+		FieldStates:    states,
 	}
 
 	switch input.Status {
@@ -811,18 +705,27 @@ func (s *FormService) UpdateFormSubmission(ctx context.Context, input UpdateSubm
 		validationCtx.StepNumber = input.StepNumber
 	}
 
-	// Validate the updated data
-	if err := s.ValidateSubmission(fields, input.Data, validationCtx); err != nil {
+	// Validate the updated data, checking custom rules against the saved answers too
+	scope := validationRuleScope(steps, fields, conditionData, validationCtx)
+	scope.FormID, scope.UserID = form.ID, submission.UserID
+	if err := s.validateCustomRules(ctx, scope, s.ValidateSubmission(fields, input.Data, validationCtx)); err != nil {
+		s.recordValidationFailures(ctx, form.ID, submission.FormVersion, db.NewNullUUID(submission.ID), input.UserID, input.StepNumber, err)
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
 
-	// Trigger before_update events
-	s.triggerEvents(ctx, form.ID, "before_update", input)
+	// Hidden fields are never persisted, including answers saved before they were hidden
+	input.Data = s.StripHiddenValues(input.Data, states)
+	input.Files = s.stripHiddenFiles(input.Files, states)
+
+	existingData := submission.SubmissionData
+	if input.IsPartialUpdate && existingData != nil {
+		existingData, _ = json.Marshal(s.StripHiddenValues(s.mergeConditionData(existingData, nil), states))
+	}
 
 	// Handle file uploads
 	var newFiles []db.FormSubmissionFileInput
 	for fieldName, fileHeaders := range input.Files {
-		field := s.findField(fields, fieldName)
+		field := s.findUploadField(fields, fieldName)
 		if field == nil {
 			continue
 		}
@@ -833,6 +736,7 @@ func (s *FormService) UpdateFormSubmission(ctx context.Context, input UpdateSubm
 				return nil, fmt.Errorf("failed to parse file config for field %s: %w", fieldName, err)
 			}
 		}
+		applySignatureFileDefaults(field.FieldType, &fileConfig.AllowedTypes, &fileConfig.MaxFiles, &fileConfig.MaxSize)
 
 		uploadedFiles, err := s.handleFileUploads(ctx, input.UserID, fieldName, fileHeaders, &fileConfig)
 		if err != nil {
@@ -852,16 +756,27 @@ func (s *FormService) UpdateFormSubmission(ctx context.Context, input UpdateSubm
 		Files:            newFiles,
 		Metadata:         input.Metadata,
 		IsPartialUpdate:  input.IsPartialUpdate,
-		ExistingData:     submission.SubmissionData,
+		ExistingData:     existingData,
+		ExpectedRevision: input.ExpectedRevision,
+		RevisionSource:   input.revisionSource,
 	}
 
-	updatedSubmission, err := s.store.UpdateFormSubmissionTx(ctx, updateTxInput)
+	updatedSubmission, err := s.store.UpdateFormSubmissionTx(ctx, updateTxInput, func(updated *db.FormSubmission) ([]db.EnqueueFormEventsParams, error) {
+		return outboxEvents(form.ID,
+			formEvent{eventType: "before_update", data: input, submission: updated},
+			formEvent{eventType: "after_update", data: updated},
+			savedEvent(submission.Status, updated),
+		)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to update submission: %w", err)
 	}
+	s.eventsEnqueued(ctx)
+
+	s.recordEdit(ctx, submission, input.UserID)
 
-	// Trigger after_update events
-	s.triggerEvents(ctx, form.ID, "after_update", updatedSubmission)
+	// Submitting, or editing a submission under review, starts a new approval round
+	s.startApproval(ctx, form, updatedSubmission, input.UserID)
 
 	return updatedSubmission, nil
 }
@@ -895,7 +810,7 @@ func (s *FormService) SaveStepProgress(ctx context.Context, input SaveStepProgre
 		return nil, fmt.Errorf("form not found: %w", err)
 	}
 
-	steps, err := s.store.GetFormSteps(ctx, form.ID)
+	steps, formFields, err := s.formStructure(ctx, form, submission.FormVersion)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get form steps: %w", err)
 	}
@@ -919,23 +834,59 @@ func (s *FormService) SaveStepProgress(ctx context.Context, input SaveStepProgre
 		return nil, fmt.Errorf("failed to get step fields: %w", err)
 	}
 
+	input.Data = s.normalizeFieldValues(fields, s.nestGroupValues(fields, input.Data))
+	input.Data, err = s.lockPrefilledValues(ctx, submission.UserID, fields, input.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve conditional logic against the whole form, so rules can reference fields answered in other steps
+	savedProgress, err := s.store.GetAllStepProgress(ctx, db.NewNullUUID(input.SubmissionID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get progress: %w", err)
+	}
+
+	conditionData := s.mergeConditionData(submission.SubmissionData, nil)
+	s.mergeStepProgressData(conditionData, savedProgress, input.StepNumber)
+	for key, value := range input.Data {
+		conditionData[key] = value
+	}
+	states := s.ResolveFieldStates(formFields, conditionData)
+
 	// Validate step data if completing
+	scans := FileScans{}
 	if input.Status == "completed" {
 		validationCtx := ValidationContext{
 			Mode:           ValidationModeStep,
 			StepNumber:     &input.StepNumber,
 			ProvidedFields: input.Data,
+			FieldStates:    states,
+			FileScans:      scans,
 		}
 
-		if err := s.ValidateSubmissionWithFiles(fields, input.Data, input.Files, validationCtx); err != nil {
+		ruleSteps, ruleFields := stepRuleScope(steps, formFields, input.StepNumber)
+		err := s.validateCustomRules(ctx, customRuleScope{
+			FormID: form.ID,
+			UserID: submission.UserID,
+			Fields: ruleFields,
+			Steps:  ruleSteps,
+			Data:   conditionData,
+			States: states,
+		}, s.ValidateSubmissionWithFiles(fields, input.Data, input.Files, validationCtx))
+		if err != nil {
+			s.recordValidationFailures(ctx, form.ID, submission.FormVersion, db.NewNullUUID(submission.ID), input.UserID, &input.StepNumber, err)
 			return nil, fmt.Errorf("step validation failed: %w", err)
 		}
 	}
 
+	// Hidden fields are never persisted
+	input.Data = s.StripHiddenValues(input.Data, states)
+	input.Files = s.stripHiddenFiles(input.Files, states)
+
 	// Handle file uploads for this step
 	var submissionFiles []db.FormSubmissionFileInput
 	if len(input.Files) > 0 {
-		uploadedFiles, err := s.handleValidatedFileUploads(ctx, fields, input.UserID, input.Files)
+		uploadedFiles, err := s.handleValidatedFileUploads(ctx, fields, input.UserID, input.Files, scans)
 		if err != nil {
 			return nil, fmt.Errorf("failed to upload step files: %w", err)
 		}
@@ -944,19 +895,26 @@ func (s *FormService) SaveStepProgress(ctx context.Context, input SaveStepProgre
 
 	// Save step progress
 	saveInput := &db.SaveStepProgressInput{
-		SubmissionID: input.SubmissionID,
-		StepID:       currentStep.ID,
-		StepNumber:   input.StepNumber,
-		Status:       input.Status,
-		Data:         input.Data,
-		UserID:       input.UserID,
-		Files:        submissionFiles,
+		SubmissionID:     input.SubmissionID,
+		StepID:           currentStep.ID,
+		StepNumber:       input.StepNumber,
+		Status:           input.Status,
+		Data:             input.Data,
+		UserID:           input.UserID,
+		Files:            submissionFiles,
+		ExpectedRevision: input.ExpectedRevision,
 	}
 
-	err = s.store.SaveStepProgressTx(ctx, saveInput)
+	err = s.store.SaveStepProgressTx(ctx, saveInput, func(db.FormStepProgress) ([]db.EnqueueFormEventsParams, error) {
+		if input.Status != "completed" {
+			return nil, nil
+		}
+		return outboxEvents(form.ID, formEvent{eventType: EventStepCompleted, data: input})
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to save step progress: %w", err)
 	}
+	s.eventsEnqueued(ctx)
 
 	// Calculate overall progress
 	allProgress, err := s.store.GetAllStepProgress(ctx, db.NewNullUUID(input.SubmissionID))
@@ -966,11 +924,15 @@ func (s *FormService) SaveStepProgress(ctx context.Context, input SaveStepProgre
 
 	completedSteps := 0
 	currentStepNumber := int32(1)
+	var revision int32
 
 	for _, progress := range allProgress {
 		if progress.Status.String == "completed" {
 			completedSteps++
 		}
+		if progress.FormStepID.UUID == currentStep.ID {
+			revision = progress.Revision
+		}
 	}
 
 	// Find next incomplete step
@@ -1006,6 +968,7 @@ func (s *FormService) SaveStepProgress(ctx context.Context, input SaveStepProgre
 		CurrentStep:          currentStepNumber,
 		CompletionPercentage: completionPercentage,
 		AllStepsCompleted:    completedSteps == len(steps),
+		Revision:             revision,
 	}, nil
 }
 
@@ -1049,6 +1012,9 @@ func (s *FormService) GetFormWithProgress(ctx context.Context, userID uuid.UUID,
 				}
 			}
 
+			// Saved step answers don't replace locked values
+			allData = mergePrefill(allData, formData.Prefilled)
+
 			// Update existing data
 			if len(allData) > 0 {
 				mergedData, _ := json.Marshal(allData)
@@ -1090,7 +1056,7 @@ func (s *FormService) validateStepData(fields []db.FormField, data map[string]in
 	}
 
 	if !v.Valid() {
-		return validator.NewValidationError("validation failed", v.Errors)
+		return v.ValidationError("validation failed")
 	}
 
 	return nil
@@ -1111,7 +1077,10 @@ func (s *FormService) CompleteForm(ctx context.Context, submissionID uuid.UUID,
 
 	// Check all steps are completed
 	form, _ := s.store.GetFormDefinition(ctx, submission.FormDefinitionID)
-	steps, _ := s.store.GetFormSteps(ctx, form.ID)
+	steps, fields, err := s.formStructure(ctx, form, submission.FormVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get form fields: %w", err)
+	}
 	stepProgress, _ := s.store.GetAllStepProgress(ctx, db.NewNullUUID(submissionID))
 
 	if len(stepProgress) < len(steps) {
@@ -1126,17 +1095,28 @@ func (s *FormService) CompleteForm(ctx context.Context, submissionID uuid.UUID,
 
 	// Merge all step data
 	allData := make(map[string]interface{})
-	for _, progress := range stepProgress {
-		var stepData map[string]interface{}
-		if err := json.Unmarshal(progress.Data.RawMessage, &stepData); err == nil {
-			for key, value := range stepData {
-				allData[key] = value
-			}
-		}
+	s.mergeStepProgressData(allData, stepProgress, 0)
+
+	// Rules across steps can only be checked once every step is saved
+	states := s.ResolveFieldStates(fields, allData)
+	err = s.validateCustomRules(ctx, customRuleScope{
+		FormID: form.ID,
+		UserID: userID,
+		Fields: fields,
+		Steps:  steps,
+		Data:   allData,
+		States: states,
+	}, nil)
+	if err != nil {
+		s.recordValidationFailures(ctx, form.ID, submission.FormVersion, db.NewNullUUID(submission.ID), userID, nil, err)
+		return nil, err
 	}
 
+	// Steps saved before a later answer hid their fields may still hold those values
+	allData = s.StripHiddenValues(allData, states)
+
 	// Process final submission
-	return s.store.ProcessFormSubmissionTx(ctx, &db.FormSubmissionInput{
+	completed, err := s.store.ProcessFormSubmissionTx(ctx, &db.FormSubmissionInput{
 		FormDefinitionID: submission.FormDefinitionID,
 		UserID:           userID,
 		Status:           "submitted",
@@ -1145,7 +1125,18 @@ func (s *FormService) CompleteForm(ctx context.Context, submissionID uuid.UUID,
 		Metadata: map[string]interface{}{
 			"completed_at": time.Now(),
 		},
+		FormVersion: submission.FormVersion,
+	}, func(completed *db.FormSubmission) ([]db.EnqueueFormEventsParams, error) {
+		return outboxEvents(form.ID, savedEvent(submission.Status, completed))
 	})
+	if err != nil {
+		return nil, err
+	}
+	s.eventsEnqueued(ctx)
+
+	s.startApproval(ctx, form, completed, userID)
+
+	return completed, nil
 }
 
 // CreateSubmission creates a new form submission (replaces SubmitForm)
@@ -1182,15 +1173,23 @@ func (s *FormService) CreateSubmission(ctx context.Context, input CreateSubmissi
 		return s.UpdateFormSubmission(ctx, updateInput)
 	}
 
-	// Get fields for validation
-	fields, err := s.store.GetFormFields(ctx, form.ID)
+	// Get steps and fields for validation
+	steps, fields, err := s.formStructure(ctx, form, form.Version)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get form fields: %w", err)
 	}
 
+	input.Data = s.normalizeFieldValues(fields, s.nestGroupValues(fields, input.Data))
+	input.Data, err = s.lockPrefilledValues(ctx, input.UserID, fields, input.Data)
+	if err != nil {
+		return nil, err
+	}
+	states := s.ResolveFieldStates(fields, input.Data)
+
 	// Determine validation context
 	validationCtx := ValidationContext{
 		ProvidedFields: input.Data,
+		FieldStates:    states,
 	}
 
 	switch input.Status {
@@ -1213,21 +1212,21 @@ func (s *FormService) CreateSubmission(ctx context.Context, input CreateSubmissi
 	}
 
 	// Validate submission
-	if err = s.ValidateSubmission(fields, input.Data, validationCtx); err != nil {
+	scope := validationRuleScope(steps, fields, input.Data, validationCtx)
+	scope.FormID, scope.UserID = form.ID, input.UserID
+	if err = s.validateCustomRules(ctx, scope, s.ValidateSubmission(fields, input.Data, validationCtx)); err != nil {
+		s.recordValidationFailures(ctx, form.ID, form.Version, uuid.NullUUID{}, input.UserID, input.StepNumber, err)
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
 
-	// Trigger before_submit events
-	eventType := "before_submit"
-	if input.Status == "draft" {
-		eventType = "before_draft_create"
-	}
-	s.triggerEvents(ctx, form.ID, eventType, input)
+	// Hidden fields are never persisted
+	input.Data = s.StripHiddenValues(input.Data, states)
+	input.Files = s.stripHiddenFiles(input.Files, states)
 
 	// Handle file uploads with validated files
 	var submissionFiles []db.FormSubmissionFileInput
 	if len(input.Files) > 0 {
-		uploadedFiles, err := s.handleValidatedFileUploads(ctx, fields, input.UserID, input.Files)
+		uploadedFiles, err := s.handleValidatedFileUploads(ctx, fields, input.UserID, input.Files, nil)
 		if err != nil {
 			return nil, fmt.Errorf("failed to upload files: %w", err)
 		}
@@ -1242,45 +1241,45 @@ func (s *FormService) CreateSubmission(ctx context.Context, input CreateSubmissi
 		Data:             input.Data,
 		Files:            submissionFiles,
 		Metadata:         input.Metadata,
+		FormVersion:      form.Version,
 	}
 
-	submission, err := s.store.ProcessFormSubmissionTx(ctx, submissionInput)
-	if err != nil {
-		return nil, fmt.Errorf("failed to process submission: %w", err)
-	}
-
-	// Trigger after events
-	afterEventType := "after_submit"
+	beforeEvent, afterEvent := "before_submit", "after_submit"
 	if input.Status == "draft" {
-		afterEventType = "after_draft_create"
+		beforeEvent, afterEvent = "before_draft_create", "after_draft_create"
 	}
-	s.triggerEvents(ctx, form.ID, afterEventType, submission)
 
-	// Send notifications for final submissions
-	if form.RequiresApproval && input.Status == "submitted" {
-		// TODO: Implement notification logic
-		s.logger.Info("Form submitted for approval", map[string]interface{}{
-			"form_id":       form.ID,
-			"submission_id": submission.ID,
-			"user_id":       input.UserID,
-		})
+	submission, err := s.store.ProcessFormSubmissionTx(ctx, submissionInput, func(submission *db.FormSubmission) ([]db.EnqueueFormEventsParams, error) {
+		return outboxEvents(form.ID,
+			formEvent{eventType: beforeEvent, data: input, submission: submission},
+			formEvent{eventType: afterEvent, data: submission},
+			savedEvent("", submission),
+		)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to process submission: %w", err)
 	}
+	s.eventsEnqueued(ctx)
+
+	s.startApproval(ctx, form, submission, input.UserID)
 
 	return submission, nil
 }
 
-// handleValidatedFileUploads processes file uploads after validation
+// handleValidatedFileUploads processes file uploads after validation. Files already scanned
+// during validation keep that verdict instead of being scanned again.
 func (s *FormService) handleValidatedFileUploads(
 	ctx context.Context,
 	fields []db.FormField,
 	userID uuid.UUID,
 	files map[string][]*multipart.FileHeader,
+	scans FileScans,
 ) ([]db.FormSubmissionFileInput, error) {
 
 	var result []db.FormSubmissionFileInput
 
 	for fieldName, fileHeaders := range files {
-		field := s.findField(fields, fieldName)
+		field := s.findUploadField(fields, fieldName)
 		if field == nil {
 			continue
 		}
@@ -1305,13 +1304,22 @@ func (s *FormService) handleValidatedFileUploads(
 			filename := s.generateSecureFilename(fileHeader.Filename, userID, fieldName, i)
 			path := fmt.Sprintf("forms/%s/%s/%s", userID.String(), fieldName, filename)
 
+			// Infected files are quarantined instead of uploaded
+			scan, scanned := scans[fileHeader]
+			if !scanned {
+				scan, err = s.scanUpload(file, userID, fieldName, fileHeader.Filename, path)
+				if err != nil {
+					return nil, err
+				}
+			}
+
 			// Upload file
 			if err := s.uploader.Upload(file, s.config.FileBucket, path); err != nil {
 				return nil, fmt.Errorf("failed to upload file %s: %w", fileHeader.Filename, err)
 			}
 
 			// Create file record
-			result = append(result, db.FormSubmissionFileInput{
+			result = append(result, withScanResult(db.FormSubmissionFileInput{
 				FieldName:       fieldName,
 				FileName:        fileHeader.Filename,
 				FilePath:        path,
@@ -1319,7 +1327,7 @@ func (s *FormService) handleValidatedFileUploads(
 				MimeType:        fileHeader.Header.Get("Content-Type"),
 				Bucket:          s.config.FileBucket,
 				StorageProvider: s.config.FileUploadProvider,
-			})
+			}, scan))
 
 			s.logger.Info("File uploaded successfully", map[string]interface{}{
 				"user_id":    userID,
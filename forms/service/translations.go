@@ -0,0 +1,447 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// Keys of the translatable text of a field. The text of static options is keyed
+// options.<value> and the text of the fields of a group fields.<name>.<key>.
+const (
+	TranslationKeyLabel       = "label"
+	TranslationKeyPlaceholder = "placeholder"
+	TranslationKeyHelpText    = "help_text"
+)
+
+// translationColumns are the columns of a translations CSV before the language columns
+var translationColumns = []string{"field_name", "key"}
+
+var ErrInvalidTranslations = errors.New("invalid translations file")
+
+// FieldTranslation is a piece of translatable text of a form field, in each language it has
+type FieldTranslation struct {
+	FieldName string   `json:"field_name"`
+	Key       string   `json:"key"`
+	Text      I18nText `json:"text"`
+}
+
+// MissingTranslation is a piece of text of a field lacking translations, with the text they
+// fall back to meanwhile
+type MissingTranslation struct {
+	FieldName string   `json:"field_name"`
+	Key       string   `json:"key"`
+	Languages []string `json:"languages"`
+	Fallback  string   `json:"fallback"`
+}
+
+// TranslationImportResult counts the fields and translations an import changed
+type TranslationImportResult struct {
+	Version      int32 `json:"version"`
+	Fields       int   `json:"fields"`
+	Translations int   `json:"translations"`
+}
+
+// FormTranslations returns the translatable text of the fields of a form version, the
+// current one when version is 0
+func (s *FormService) FormTranslations(ctx context.Context, formID uuid.UUID, version int32) ([]FieldTranslation, error) {
+	_, fields, err := s.translatableFields(ctx, formID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	translations := []FieldTranslation{}
+	for _, field := range fields {
+		translations = append(translations, fieldTranslations(field)...)
+	}
+	return translations, nil
+}
+
+// MissingTranslations lists the text of a form version lacking a translation in any of
+// languages, all supported languages when none are given
+func (s *FormService) MissingTranslations(ctx context.Context, formID uuid.UUID, version int32, languages []string) ([]MissingTranslation, error) {
+	languages, err := translationLanguages(languages)
+	if err != nil {
+		return nil, err
+	}
+	translations, err := s.FormTranslations(ctx, formID, version)
+	if err != nil {
+		return nil, err
+	}
+	return missingTranslations(translations, languages), nil
+}
+
+// ExportTranslations writes the text of the fields of a form version as CSV with a column for
+// each of languages, all supported languages when none are given
+func (s *FormService) ExportTranslations(ctx context.Context, formID uuid.UUID, version int32, languages []string, w io.Writer) error {
+	languages, err := translationLanguages(languages)
+	if err != nil {
+		return err
+	}
+	translations, err := s.FormTranslations(ctx, formID, version)
+	if err != nil {
+		return err
+	}
+	return WriteTranslationsCSV(w, translations, languages)
+}
+
+// ImportTranslations merges translations into the text of the fields of a form version, the
+// current one when version is 0. Translations only add or replace the languages they have;
+// text they don't mention is kept. Translations take effect without a new version since
+// they don't change what submissions hold.
+func (s *FormService) ImportTranslations(ctx context.Context, formID uuid.UUID, version int32, translations []FieldTranslation) (*TranslationImportResult, error) {
+	form, fields, err := s.translatableFields(ctx, formID, version)
+	if err != nil {
+		return nil, err
+	}
+	if version == 0 {
+		version = form.Version
+	}
+
+	updates, applied, err := applyTranslations(fields, translations)
+	if err != nil {
+		return nil, err
+	}
+	if len(updates) > 0 {
+		if err := s.store.UpdateFormFieldTextsTx(ctx, updates); err != nil {
+			return nil, err
+		}
+	}
+
+	s.logger.Info("form translations imported", map[string]interface{}{
+		"form_id":      formID,
+		"version":      version,
+		"fields":       len(updates),
+		"translations": applied,
+	})
+	return &TranslationImportResult{
+		Version:      version,
+		Fields:       len(updates),
+		Translations: applied,
+	}, nil
+}
+
+func (s *FormService) translatableFields(ctx context.Context, formID uuid.UUID, version int32) (db.FormDefinition, []db.FormField, error) {
+	form, err := s.store.GetFormDefinition(ctx, formID)
+	if err != nil {
+		return db.FormDefinition{}, nil, err
+	}
+	if version != 0 && version != form.Version {
+		if _, err := s.store.GetFormVersion(ctx, formID, version); err != nil {
+			return db.FormDefinition{}, nil, err
+		}
+	}
+	_, fields, err := s.formStructure(ctx, form, version)
+	if err != nil {
+		return db.FormDefinition{}, nil, err
+	}
+	return form, fields, nil
+}
+
+// translationLanguages checks languages are supported, defaulting to all of them
+func translationLanguages(languages []string) ([]string, error) {
+	if len(languages) == 0 {
+		return SupportedLanguages, nil
+	}
+
+	v := validator.New()
+	checked := make([]string, 0, len(languages))
+	for _, language := range languages {
+		lang, ok := SupportedLanguage(language)
+		if !ok || lang != strings.ToLower(strings.TrimSpace(language)) {
+			v.AddError("languages", fmt.Sprintf("%q is not one of %s", language, strings.Join(SupportedLanguages, ", ")))
+			continue
+		}
+		if !validator.In(lang, checked...) {
+			checked = append(checked, lang)
+		}
+	}
+	if !v.Valid() {
+		return nil, validator.NewValidationError("validation failed", v.Errors)
+	}
+	return checked, nil
+}
+
+// fieldTranslations returns the text of a field that has any, in the order of translation keys
+func fieldTranslations(field db.FormField) []FieldTranslation {
+	var translations []FieldTranslation
+	add := func(key string, text I18nText) {
+		if len(text) > 0 {
+			translations = append(translations, FieldTranslation{FieldName: field.FieldName, Key: key, Text: text})
+		}
+	}
+
+	add(TranslationKeyLabel, i18nText(field.Label))
+	add(TranslationKeyPlaceholder, i18nText(field.Placeholder))
+	add(TranslationKeyHelpText, i18nText(field.HelpText))
+
+	var options FieldOptions
+	if len(field.Options) > 0 && json.Unmarshal(field.Options, &options) == nil {
+		for _, option := range options.Static {
+			add("options."+option.Value, option.Label)
+		}
+		for _, item := range options.Fields {
+			prefix := "fields." + item.FieldName + "."
+			add(prefix+TranslationKeyLabel, item.Label)
+			add(prefix+TranslationKeyPlaceholder, item.Placeholder)
+			add(prefix+TranslationKeyHelpText, item.HelpText)
+		}
+	}
+	return translations
+}
+
+func missingTranslations(translations []FieldTranslation, languages []string) []MissingTranslation {
+	missing := []MissingTranslation{}
+	for _, translation := range translations {
+		var lacking []string
+		for _, lang := range languages {
+			if strings.TrimSpace(translation.Text[lang]) == "" {
+				lacking = append(lacking, lang)
+			}
+		}
+		if len(lacking) > 0 {
+			missing = append(missing, MissingTranslation{
+				FieldName: translation.FieldName,
+				Key:       translation.Key,
+				Languages: lacking,
+				Fallback:  translation.Text.Text(DefaultLanguage),
+			})
+		}
+	}
+	return missing
+}
+
+// fieldText is the translatable text of a field being imported into
+type fieldText struct {
+	label, placeholder, helpText map[string]string
+	options                      FieldOptions
+	changed, optionsChanged      bool
+}
+
+func newFieldText(field db.FormField) *fieldText {
+	text := &fieldText{
+		label:       i18nText(field.Label),
+		placeholder: i18nText(field.Placeholder),
+		helpText:    i18nText(field.HelpText),
+	}
+	if len(field.Options) > 0 {
+		_ = json.Unmarshal(field.Options, &text.options)
+	}
+	return text
+}
+
+// target returns the text a translation key refers to
+func (t *fieldText) target(key string) (*map[string]string, bool) {
+	switch key {
+	case TranslationKeyLabel:
+		return &t.label, true
+	case TranslationKeyPlaceholder:
+		return &t.placeholder, true
+	case TranslationKeyHelpText:
+		return &t.helpText, true
+	}
+
+	if value, ok := strings.CutPrefix(key, "options."); ok {
+		for i, option := range t.options.Static {
+			if option.Value == value {
+				return &t.options.Static[i].Label, true
+			}
+		}
+		return nil, false
+	}
+
+	if rest, ok := strings.CutPrefix(key, "fields."); ok {
+		i := strings.LastIndex(rest, ".")
+		if i < 0 {
+			return nil, false
+		}
+		name, itemKey := rest[:i], rest[i+1:]
+		for j, item := range t.options.Fields {
+			if item.FieldName != name {
+				continue
+			}
+			switch itemKey {
+			case TranslationKeyLabel:
+				return &t.options.Fields[j].Label, true
+			case TranslationKeyPlaceholder:
+				return &t.options.Fields[j].Placeholder, true
+			case TranslationKeyHelpText:
+				return &t.options.Fields[j].HelpText, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// applyTranslations merges translations into the text of fields, returning the updates of the
+// fields that changed and how many translations were applied. Translations for fields or
+// text the form doesn't have fail validation, keyed by field name and key.
+func applyTranslations(fields []db.FormField, translations []FieldTranslation) ([]db.UpdateFormFieldTextParams, int, error) {
+	texts := make(map[string]*fieldText, len(fields))
+	for _, field := range fields {
+		texts[field.FieldName] = newFieldText(field)
+	}
+
+	v := validator.New()
+	applied := 0
+	for _, translation := range translations {
+		errKey := translation.FieldName + "." + translation.Key
+		text, ok := texts[translation.FieldName]
+		if !ok {
+			v.AddError(errKey, "unknown field")
+			continue
+		}
+		target, ok := text.target(translation.Key)
+		if !ok {
+			v.AddError(errKey, "unknown text")
+			continue
+		}
+
+		for lang, value := range translation.Text {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			if supported, ok := SupportedLanguage(lang); !ok || supported != lang {
+				v.AddError(errKey, fmt.Sprintf("%q is not one of %s", lang, strings.Join(SupportedLanguages, ", ")))
+				continue
+			}
+			applied++
+			if (*target)[lang] == value {
+				continue
+			}
+			if *target == nil {
+				*target = map[string]string{}
+			}
+			(*target)[lang] = value
+			text.changed = true
+			if strings.HasPrefix(translation.Key, "options.") || strings.HasPrefix(translation.Key, "fields.") {
+				text.optionsChanged = true
+			}
+		}
+	}
+	if !v.Valid() {
+		return nil, 0, validator.NewValidationError("validation failed", v.Errors)
+	}
+
+	updates := []db.UpdateFormFieldTextParams{}
+	for _, field := range fields {
+		text := texts[field.FieldName]
+		if !text.changed {
+			continue
+		}
+		update := db.UpdateFormFieldTextParams{
+			ID:          field.ID,
+			Label:       encodeText(field.Label, text.label),
+			Placeholder: encodeText(field.Placeholder, text.placeholder),
+			HelpText:    encodeText(field.HelpText, text.helpText),
+			Options:     field.Options,
+		}
+		if text.optionsChanged {
+			options, err := json.Marshal(text.options)
+			if err != nil {
+				return nil, 0, err
+			}
+			update.Options = options
+		}
+		updates = append(updates, update)
+	}
+	return updates, applied, nil
+}
+
+// encodeText encodes text that may have changed, keeping the stored value when there is none
+func encodeText(stored json.RawMessage, text map[string]string) json.RawMessage {
+	if len(text) == 0 {
+		return stored
+	}
+	encoded, err := json.Marshal(text)
+	if err != nil {
+		return stored
+	}
+	return encoded
+}
+
+// WriteTranslationsCSV writes translations as CSV, a row for each piece of text and a column
+// for each language, so translators can fill in the empty cells
+func WriteTranslationsCSV(w io.Writer, translations []FieldTranslation, languages []string) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(append(append([]string{}, translationColumns...), languages...)); err != nil {
+		return err
+	}
+	for _, translation := range translations {
+		row := []string{translation.FieldName, translation.Key}
+		for _, lang := range languages {
+			row = append(row, translation.Text[lang])
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ReadTranslationsCSV reads translations written by WriteTranslationsCSV. Empty cells are
+// left out, so they don't clear existing translations when imported.
+func ReadTranslationsCSV(r io.Reader) ([]FieldTranslation, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTranslations, err)
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	if len(header) <= len(translationColumns) ||
+		strings.TrimSpace(header[0]) != translationColumns[0] || strings.TrimSpace(header[1]) != translationColumns[1] {
+		return nil, fmt.Errorf("%w: the header must be %s followed by languages", ErrInvalidTranslations, strings.Join(translationColumns, ","))
+	}
+	languages := make([]string, 0, len(header)-len(translationColumns))
+	for _, column := range header[len(translationColumns):] {
+		languages = append(languages, strings.ToLower(strings.TrimSpace(column)))
+	}
+	if _, err := translationLanguages(languages); err != nil {
+		return nil, err
+	}
+
+	translations := []FieldTranslation{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTranslations, err)
+		}
+		if len(record) < len(translationColumns) || strings.TrimSpace(record[0]) == "" || strings.TrimSpace(record[1]) == "" {
+			return nil, fmt.Errorf("%w: line %d must have a field name and a key", ErrInvalidTranslations, line)
+		}
+
+		text := I18nText{}
+		for i, lang := range languages {
+			column := len(translationColumns) + i
+			if column < len(record) && strings.TrimSpace(record[column]) != "" {
+				text[lang] = strings.TrimSpace(record[column])
+			}
+		}
+		if len(text) == 0 {
+			continue
+		}
+		translations = append(translations, FieldTranslation{
+			FieldName: strings.TrimSpace(record[0]),
+			Key:       strings.TrimSpace(record[1]),
+			Text:      text,
+		})
+	}
+	return translations, nil
+}
@@ -18,6 +18,15 @@ type FormDefinitionWithData struct {
 	StepProgress         []db.FormStepProgress `json:"step_progress,omitempty"`
 	CurrentStep          int32                 `json:"current_step"`
 	CompletionPercentage int32                 `json:"completion_percentage"`
+	ReviewComments       map[string]string     `json:"review_comments,omitempty"` // per-field comments when changes were requested
+	// GroupFiles are the uploaded files of group items, keyed like owners[0].id_document
+	GroupFiles map[string][]map[string]interface{} `json:"group_files,omitempty"`
+	// FieldMeta tells renderers how to present rich fields like dates and phone numbers
+	FieldMeta map[string]FieldMetadata `json:"field_meta,omitempty"`
+	// Language is the language the text of the fields was localized to, if it was
+	Language string `json:"language,omitempty"`
+	// Prefilled are the fields whose values were filled from the user's data, by field name
+	Prefilled map[string]PrefilledField `json:"prefilled,omitempty"`
 }
 
 // FieldOptions for select/radio/checkbox fields
@@ -25,6 +34,8 @@ type FieldOptions struct {
 	Type    string         `json:"type"`
 	Static  []Option       `json:"static,omitempty"`
 	Dynamic *DynamicSource `json:"dynamic,omitempty"`
+	// Fields are the fields of each item of a group field
+	Fields []db.FieldInput `json:"fields,omitempty"`
 }
 
 // Option for dropdown/radio/checkbox
@@ -38,6 +49,9 @@ type DynamicSource struct {
 	SourceID     uuid.UUID         `json:"source_id,omitempty"`
 	SourceName   string            `json:"source_name"`
 	FilterParams map[string]string `json:"filter_params,omitempty"`
+	// DependsOn is filled when the form is rendered with the fields referenced by FilterParams,
+	// so clients know when to reload the options
+	DependsOn []string `json:"depends_on,omitempty"`
 }
 
 // SubmitFormInput for form submission
@@ -69,6 +83,21 @@ type ValidationRules struct {
 	MaxItems    *int                   `json:"max_items,omitempty"`
 	AllRequired bool                   `json:"all_required,omitempty"`
 	CustomRules map[string]interface{} `json:"custom_rules,omitempty"`
+	// MinDate and MaxDate bound date and datetime fields. They are dates like 2006-01-02,
+	// "today", or offsets from today like -18y, +30d, -6m and +2w.
+	MinDate *string `json:"min_date,omitempty"`
+	MaxDate *string `json:"max_date,omitempty"`
+	// MinAge and MaxAge bound the age in years of a date of birth
+	MinAge *int `json:"min_age,omitempty"`
+	MaxAge *int `json:"max_age,omitempty"`
+	// Country is the ISO code national phone numbers and identity numbers belong to when the
+	// value doesn't carry its own; Countries limits the countries accepted
+	Country   string   `json:"country,omitempty"`
+	Countries []string `json:"countries,omitempty"`
+	// IDTypes are the identity numbers a national_id field accepts, e.g. nin and bvn
+	IDTypes []string `json:"id_types,omitempty"`
+	// RequiredParts are the parts of an address that must be filled
+	RequiredParts []string `json:"required_parts,omitempty"`
 }
 
 // I18nText for internationalization
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/google/uuid"
 	"regexp"
+	"time"
 
 	db "github.com/timchuks/monieverse/internal/db/sqlc"
 	"github.com/timchuks/monieverse/internal/validator"
@@ -24,31 +25,56 @@ type ValidationContext struct {
 	Mode           ValidationMode
 	StepNumber     *int32                 // For step validation
 	ProvidedFields map[string]interface{} // Fields being validated
+	// FieldStates is the outcome of conditional logic per field. When nil it is resolved
+	// from the fields and data being validated; step validation passes states resolved
+	// against the whole form so rules can reference fields from other steps.
+	FieldStates map[string]FieldState
+	// FileScans, when set, collects the verdicts of the uploads scanned during validation so
+	// they are not scanned again when stored.
+	FileScans FileScans
 }
 
 // ValidateSubmission validates form data based on context
 func (s *FormService) ValidateSubmission(fields []db.FormField, data map[string]interface{}, ctx ValidationContext) error {
 	v := validator.New()
 
+	states := ctx.FieldStates
+	if states == nil {
+		states = s.ResolveFieldStates(fields, data)
+	}
+
 	switch ctx.Mode {
 	case ValidationModeFull:
-		return s.validateFull(v, fields, data)
+		return s.validateFull(v, fields, data, states)
 	case ValidationModePartial:
-		return s.validatePartial(v, fields, data)
+		return s.validatePartial(v, fields, data, states)
 	case ValidationModeStep:
-		return s.validateStep(v, fields, data, *ctx.StepNumber)
+		return s.validateStep(v, fields, data, states, *ctx.StepNumber)
 	default:
-		return s.validateFull(v, fields, data)
+		return s.validateFull(v, fields, data, states)
+	}
+}
+
+// fieldState returns the conditional logic outcome of a field, defaulting to its static settings
+func (s *FormService) fieldState(field db.FormField, states map[string]FieldState) FieldState {
+	if state, ok := states[field.FieldName]; ok {
+		return state
 	}
+	return FieldState{Visible: true, Required: field.IsRequired}
 }
 
 // validateFull performs complete validation (original logic)
-func (s *FormService) validateFull(v *validator.Validator, fields []db.FormField, data map[string]interface{}) error {
+func (s *FormService) validateFull(v *validator.Validator, fields []db.FormField, data map[string]interface{}, states map[string]FieldState) error {
 	for _, field := range fields {
+		state := s.fieldState(field, states)
+		if !state.Visible {
+			continue
+		}
+
 		value, exists := data[field.FieldName]
 
 		// Check required fields
-		if field.IsRequired && (!exists || s.isEmpty(value)) {
+		if state.Required && (!exists || s.isEmpty(value)) {
 			v.AddError(field.FieldName, "field is required")
 			continue
 		}
@@ -57,6 +83,20 @@ func (s *FormService) validateFull(v *validator.Validator, fields []db.FormField
 			continue
 		}
 
+		// Groups also need their minimum items and the required fields of each item
+		if IsGroupFieldType(field.FieldType) {
+			if err := s.validateGroup(v, field, value, true); err != nil {
+				return err
+			}
+			continue
+		}
+		if IsChoiceListFieldType(field.FieldType) {
+			if err := s.validateChoices(v, field, value, true); err != nil {
+				return err
+			}
+			continue
+		}
+
 		// Validate field content
 		if err := s.validateFieldValue(v, field, value); err != nil {
 			return err
@@ -64,14 +104,18 @@ func (s *FormService) validateFull(v *validator.Validator, fields []db.FormField
 	}
 
 	if !v.Valid() {
-		return validator.NewValidationError("validation failed", v.Errors)
+		return v.ValidationError("validation failed")
 	}
 	return nil
 }
 
 // validatePartial only validates provided fields (for drafts)
-func (s *FormService) validatePartial(v *validator.Validator, fields []db.FormField, data map[string]interface{}) error {
+func (s *FormService) validatePartial(v *validator.Validator, fields []db.FormField, data map[string]interface{}, states map[string]FieldState) error {
 	for _, field := range fields {
+		if !s.fieldState(field, states).Visible {
+			continue
+		}
+
 		value, exists := data[field.FieldName]
 
 		// Skip missing fields in partial validation
@@ -86,13 +130,13 @@ func (s *FormService) validatePartial(v *validator.Validator, fields []db.FormFi
 	}
 
 	if !v.Valid() {
-		return validator.NewValidationError("validation failed", v.Errors)
+		return v.ValidationError("validation failed")
 	}
 	return nil
 }
 
 // validateStep validates fields for a specific step
-func (s *FormService) validateStep(v *validator.Validator, fields []db.FormField, data map[string]interface{}, stepNumber int32) error {
+func (s *FormService) validateStep(v *validator.Validator, fields []db.FormField, data map[string]interface{}, states map[string]FieldState, stepNumber int32) error {
 	stepFields := make([]db.FormField, 0)
 	for _, field := range fields {
 		if field.FormStepID != uuid.Nil {
@@ -101,14 +145,27 @@ func (s *FormService) validateStep(v *validator.Validator, fields []db.FormField
 	}
 
 	for _, field := range stepFields {
+		state := s.fieldState(field, states)
+		if !state.Visible {
+			continue
+		}
+
 		value, exists := data[field.FieldName]
 
-		if field.IsRequired && (!exists || s.isEmpty(value)) {
+		if state.Required && (!exists || s.isEmpty(value)) {
 			v.AddError(field.FieldName, "field is required for this step")
 			continue
 		}
 
-		if exists && value != nil {
+		if exists && value != nil && IsGroupFieldType(field.FieldType) {
+			if err := s.validateGroup(v, field, value, true); err != nil {
+				return err
+			}
+		} else if exists && value != nil && IsChoiceListFieldType(field.FieldType) {
+			if err := s.validateChoices(v, field, value, true); err != nil {
+				return err
+			}
+		} else if exists && value != nil {
 			if err := s.validateFieldValue(v, field, value); err != nil {
 				return err
 			}
@@ -116,7 +173,7 @@ func (s *FormService) validateStep(v *validator.Validator, fields []db.FormField
 	}
 
 	if !v.Valid() {
-		return validator.NewValidationError("step validation failed", v.Errors)
+		return v.ValidationError("step validation failed")
 	}
 	return nil
 }
@@ -141,12 +198,12 @@ func (s *FormService) validateFieldValue(v *validator.Validator, field db.FormFi
 	case "text", "textarea":
 		if str, ok := value.(string); ok {
 			if rules.MinLength != nil {
-				v.Check(len(str) >= *rules.MinLength, field.FieldName,
-					fmt.Sprintf("must be at least %d characters", *rules.MinLength))
+				v.CheckMessage(len(str) >= *rules.MinLength, field.FieldName,
+					validator.Msg("must be at least %d characters", *rules.MinLength))
 			}
 			if rules.MaxLength != nil {
-				v.Check(len(str) <= *rules.MaxLength, field.FieldName,
-					fmt.Sprintf("must be at most %d characters", *rules.MaxLength))
+				v.CheckMessage(len(str) <= *rules.MaxLength, field.FieldName,
+					validator.Msg("must be at most %d characters", *rules.MaxLength))
 			}
 			if rules.Pattern != nil {
 				matched, _ := regexp.MatchString(*rules.Pattern, str)
@@ -158,13 +215,13 @@ func (s *FormService) validateFieldValue(v *validator.Validator, field db.FormFi
 		if num, ok := s.toFloat64(value); ok {
 			if rules.Min != nil {
 				minVal, _ := rules.Min.Float64()
-				v.Check(num >= minVal, field.FieldName,
-					fmt.Sprintf("must be at least %v", minVal))
+				v.CheckMessage(num >= minVal, field.FieldName,
+					validator.Msg("must be at least %v", minVal))
 			}
 			if rules.Max != nil {
 				maxVal, _ := rules.Max.Float64()
-				v.Check(num <= maxVal, field.FieldName,
-					fmt.Sprintf("must be at most %v", maxVal))
+				v.CheckMessage(num <= maxVal, field.FieldName,
+					validator.Msg("must be at most %v", maxVal))
 			}
 		}
 
@@ -184,7 +241,25 @@ func (s *FormService) validateFieldValue(v *validator.Validator, field db.FormFi
 			}
 		}
 
-	case "file", "files":
+	case FieldTypeGroup, FieldTypeRepeater:
+		return s.validateGroupItems(v, field, value, rules, false)
+
+	case FieldTypeDate, FieldTypeDateTime:
+		validateDate(v, field, rules, value, time.Now())
+
+	case FieldTypePhone:
+		validatePhone(v, field, rules, value)
+
+	case FieldTypeNationalID:
+		validateNationalID(v, field, rules, value)
+
+	case FieldTypeAddress:
+		s.validateAddress(v, field, rules, value)
+
+	case FieldTypeCheckboxGroup, FieldTypeMultiSelect:
+		s.validateChoiceList(v, field, rules, value, false)
+
+	case "file", "files", FieldTypeSignature:
 		// File validation would be handled separately during upload
 		// Here we might just check if file references are valid
 		break
@@ -0,0 +1,323 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// FormVersionDetail is a version of a form together with its steps and fields
+type FormVersionDetail struct {
+	db.FormVersion
+	Steps  []db.FormStep  `json:"steps"`
+	Fields []db.FormField `json:"fields"`
+}
+
+// FieldChange lists the attributes of a field that differ between two versions
+type FieldChange struct {
+	FieldName  string   `json:"field_name"`
+	Attributes []string `json:"attributes"`
+}
+
+// StepChange lists the attributes of a step that differ between two versions
+type StepChange struct {
+	StepNumber int32    `json:"step_number"`
+	Attributes []string `json:"attributes"`
+}
+
+// FormVersionDiff describes how a form changed between two versions. Steps are matched by
+// step number and fields by field name.
+type FormVersionDiff struct {
+	FromVersion     int32         `json:"from_version"`
+	ToVersion       int32         `json:"to_version"`
+	ChangedMetadata []string      `json:"changed_metadata"`
+	AddedSteps      []int32       `json:"added_steps"`
+	RemovedSteps    []int32       `json:"removed_steps"`
+	ChangedSteps    []StepChange  `json:"changed_steps"`
+	AddedFields     []string      `json:"added_fields"`
+	RemovedFields   []string      `json:"removed_fields"`
+	ChangedFields   []FieldChange `json:"changed_fields"`
+}
+
+// formStructure returns the steps and fields of the version a submission is pinned to.
+// Version 0 (no submission yet) and the current version are read from the live form.
+func (s *FormService) formStructure(ctx context.Context, form db.FormDefinition, version int32) ([]db.FormStep, []db.FormField, error) {
+	if version == 0 || version == form.Version {
+		steps, err := s.store.GetFormSteps(ctx, form.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		fields, err := s.store.GetFormFields(ctx, form.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return steps, fields, nil
+	}
+
+	steps, err := s.store.GetFormStepsByVersion(ctx, form.ID, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	fields, err := s.store.GetFormFieldsByVersion(ctx, form.ID, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	return steps, fields, nil
+}
+
+// GetFormVersion returns a version of a form with its steps and fields
+func (s *FormService) GetFormVersion(ctx context.Context, formID uuid.UUID, version int32) (*FormVersionDetail, error) {
+	v, err := s.store.GetFormVersion(ctx, formID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	steps, err := s.store.GetFormStepsByVersion(ctx, formID, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get form steps: %w", err)
+	}
+
+	fields, err := s.store.GetFormFieldsByVersion(ctx, formID, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get form fields: %w", err)
+	}
+
+	return &FormVersionDetail{FormVersion: v, Steps: steps, Fields: fields}, nil
+}
+
+// DiffFormVersions compares two versions of a form
+func (s *FormService) DiffFormVersions(ctx context.Context, formID uuid.UUID, from, to int32) (*FormVersionDiff, error) {
+	fromVersion, err := s.GetFormVersion(ctx, formID, from)
+	if err != nil {
+		return nil, err
+	}
+
+	toVersion, err := s.GetFormVersion(ctx, formID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := DiffFormVersions(fromVersion, toVersion)
+	return &diff, nil
+}
+
+// PublishFormVersion makes a draft version the current version of its form. A public form keeps
+// its public access rules, so a version whose fields break them can't be published until public
+// access is turned off.
+func (s *FormService) PublishFormVersion(ctx context.Context, formID uuid.UUID, version int32, publishedBy uuid.UUID) (*db.FormDefinition, error) {
+	access, err := s.store.GetFormPublicAccess(ctx, formID)
+	if err != nil && !errors.Is(err, db.ErrFormPublicAccessNotFound) {
+		return nil, err
+	}
+
+	if err == nil && access.Enabled {
+		fields, err := s.store.GetFormFieldsByVersion(ctx, formID, version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get form fields: %w", err)
+		}
+
+		v := validator.New()
+		s.checkPublicFields(v, fields, access.EmailField, access.HoneypotField, access.Enabled)
+		if !v.Valid() {
+			return nil, validator.NewValidationError("version breaks the public access rules of the form", v.Errors)
+		}
+	}
+
+	return s.store.PublishFormVersionTx(ctx, formID, version, publishedBy)
+}
+
+// MigrateFormSubmissions moves the draft submissions of a form from one version to the
+// current version. Every mapping target must be a field of the current version.
+func (s *FormService) MigrateFormSubmissions(ctx context.Context, input db.FormSubmissionMigrationInput) (int, error) {
+	form, err := s.store.GetFormDefinition(ctx, input.FormDefinitionID)
+	if err != nil {
+		return 0, fmt.Errorf("form not found: %w", err)
+	}
+
+	if input.ToVersion != form.Version {
+		return 0, fmt.Errorf("submissions can only be migrated to the published version %d", form.Version)
+	}
+	if input.FromVersion == input.ToVersion {
+		return 0, fmt.Errorf("submissions are already on version %d", input.ToVersion)
+	}
+
+	fields, err := s.store.GetFormFields(ctx, form.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get form fields: %w", err)
+	}
+
+	for from, to := range input.FieldMappings {
+		if to != "" && s.findField(fields, to) == nil {
+			return 0, fmt.Errorf("field %q is mapped to %q, which is not in version %d", from, to, form.Version)
+		}
+	}
+
+	return s.store.MigrateFormSubmissionsTx(ctx, &input)
+}
+
+// DiffFormVersions compares the metadata, steps and fields of two form versions
+func DiffFormVersions(from, to *FormVersionDetail) FormVersionDiff {
+	diff := FormVersionDiff{
+		FromVersion:     from.Version,
+		ToVersion:       to.Version,
+		ChangedMetadata: []string{},
+		AddedSteps:      []int32{},
+		RemovedSteps:    []int32{},
+		ChangedSteps:    []StepChange{},
+		AddedFields:     []string{},
+		RemovedFields:   []string{},
+		ChangedFields:   []FieldChange{},
+	}
+
+	diff.ChangedMetadata = changedAttributes([]attributePair{
+		{"name", from.Name, to.Name},
+		{"description", from.Description, to.Description},
+		{"is_multi_step", from.IsMultiStep, to.IsMultiStep},
+		{"requires_approval", from.RequiresApproval, to.RequiresApproval},
+		{"is_editable_after_submission", from.IsEditableAfterSubmission, to.IsEditableAfterSubmission},
+		{"approval_workflow", from.ApprovalWorkflow, to.ApprovalWorkflow},
+		{"persistence_config", from.PersistenceConfig.RawMessage, to.PersistenceConfig.RawMessage},
+	})
+
+	fromSteps := make(map[int32]db.FormStep, len(from.Steps))
+	fromStepNumbers := make(map[uuid.UUID]int32, len(from.Steps))
+	for _, step := range from.Steps {
+		fromSteps[step.StepNumber] = step
+		fromStepNumbers[step.ID] = step.StepNumber
+	}
+
+	toSteps := make(map[int32]db.FormStep, len(to.Steps))
+	toStepNumbers := make(map[uuid.UUID]int32, len(to.Steps))
+	for _, step := range to.Steps {
+		toSteps[step.StepNumber] = step
+		toStepNumbers[step.ID] = step.StepNumber
+
+		old, ok := fromSteps[step.StepNumber]
+		if !ok {
+			diff.AddedSteps = append(diff.AddedSteps, step.StepNumber)
+			continue
+		}
+
+		attributes := changedAttributes([]attributePair{
+			{"name", old.Name, step.Name},
+			{"description", old.Description, step.Description},
+			{"is_optional", old.IsOptional, step.IsOptional},
+			{"validation_rules", old.ValidationRules, step.ValidationRules},
+		})
+		if len(attributes) > 0 {
+			diff.ChangedSteps = append(diff.ChangedSteps, StepChange{StepNumber: step.StepNumber, Attributes: attributes})
+		}
+	}
+
+	for _, step := range from.Steps {
+		if _, ok := toSteps[step.StepNumber]; !ok {
+			diff.RemovedSteps = append(diff.RemovedSteps, step.StepNumber)
+		}
+	}
+
+	fromFields := make(map[string]db.FormField, len(from.Fields))
+	for _, field := range from.Fields {
+		fromFields[field.FieldName] = field
+	}
+
+	toFields := make(map[string]bool, len(to.Fields))
+	for _, field := range to.Fields {
+		toFields[field.FieldName] = true
+
+		old, ok := fromFields[field.FieldName]
+		if !ok {
+			diff.AddedFields = append(diff.AddedFields, field.FieldName)
+			continue
+		}
+
+		attributes := changedAttributes([]attributePair{
+			{"field_type", old.FieldType, field.FieldType},
+			{"step_number", fromStepNumbers[old.FormStepID], toStepNumbers[field.FormStepID]},
+			{"label", old.Label, field.Label},
+			{"placeholder", old.Placeholder, field.Placeholder},
+			{"help_text", old.HelpText, field.HelpText},
+			{"validation_rules", old.ValidationRules, field.ValidationRules},
+			{"options", old.Options, field.Options},
+			{"display_order", old.DisplayOrder, field.DisplayOrder},
+			{"is_required", old.IsRequired, field.IsRequired},
+			{"is_readonly", old.IsReadonly, field.IsReadonly},
+			{"default_value", old.DefaultValue, field.DefaultValue},
+			{"conditional_logic", old.ConditionalLogic, field.ConditionalLogic},
+			{"file_config", old.FileConfig, field.FileConfig},
+			{"prefill", old.Prefill, field.Prefill},
+		})
+		if len(attributes) > 0 {
+			diff.ChangedFields = append(diff.ChangedFields, FieldChange{FieldName: field.FieldName, Attributes: attributes})
+		}
+	}
+
+	for _, field := range from.Fields {
+		if !toFields[field.FieldName] {
+			diff.RemovedFields = append(diff.RemovedFields, field.FieldName)
+		}
+	}
+
+	sort.Slice(diff.AddedSteps, func(i, j int) bool { return diff.AddedSteps[i] < diff.AddedSteps[j] })
+	sort.Slice(diff.RemovedSteps, func(i, j int) bool { return diff.RemovedSteps[i] < diff.RemovedSteps[j] })
+
+	return diff
+}
+
+type attributePair struct {
+	name     string
+	from, to interface{}
+}
+
+func changedAttributes(pairs []attributePair) []string {
+	changed := []string{}
+	for _, pair := range pairs {
+		if !attributeEqual(pair.from, pair.to) {
+			changed = append(changed, pair.name)
+		}
+	}
+	return changed
+}
+
+// attributeEqual compares JSON columns by content, so formatting and key order don't count as changes
+func attributeEqual(a, b interface{}) bool {
+	ar, aok := a.(json.RawMessage)
+	br, bok := b.(json.RawMessage)
+	if !aok || !bok {
+		return reflect.DeepEqual(a, b)
+	}
+
+	if bytes.Equal(ar, br) {
+		return true
+	}
+
+	av, aerr := decodeAttributeJSON(ar)
+	bv, berr := decodeAttributeJSON(br)
+	if aerr != nil || berr != nil {
+		return false
+	}
+	return reflect.DeepEqual(av, bv)
+}
+
+// decodeAttributeJSON treats a missing value, null and {} as the same, as unset JSON columns are stored as {}
+func decodeAttributeJSON(raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	if m, ok := v.(map[string]interface{}); ok && len(m) == 0 {
+		return nil, nil
+	}
+	return v, nil
+}
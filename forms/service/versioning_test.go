@@ -0,0 +1,59 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+)
+
+func versionDetail(version int32, name string, steps []db.FormStep, fields []db.FormField) *FormVersionDetail {
+	return &FormVersionDetail{
+		FormVersion: db.FormVersion{Version: version, Name: name, ApprovalWorkflow: json.RawMessage(`{}`)},
+		Steps:       steps,
+		Fields:      fields,
+	}
+}
+
+func TestDiffFormVersions(t *testing.T) {
+	v1Step1, v1Step2 := uuid.New(), uuid.New()
+	v2Step1, v2Step2 := uuid.New(), uuid.New()
+
+	from := versionDetail(1, "KYC", []db.FormStep{
+		{ID: v1Step1, StepNumber: 1, Name: "Personal"},
+		{ID: v1Step2, StepNumber: 2, Name: "Address"},
+	}, []db.FormField{
+		{FormStepID: v1Step1, FieldName: "first_name", FieldType: "text", Label: json.RawMessage(`{"en":"First name","fr":"Prénom"}`)},
+		{FormStepID: v1Step1, FieldName: "middle_name", FieldType: "text"},
+		{FormStepID: v1Step2, FieldName: "address", FieldType: "text", ValidationRules: json.RawMessage(`{}`)},
+		{FormStepID: v1Step2, FieldName: "city", FieldType: "text"},
+	})
+
+	to := versionDetail(2, "KYC (individuals)", []db.FormStep{
+		{ID: v2Step1, StepNumber: 1, Name: "Personal"},
+		{ID: v2Step2, StepNumber: 2, Name: "Residential address"},
+	}, []db.FormField{
+		// Reformatted JSON with the same content is not a change
+		{FormStepID: v2Step1, FieldName: "first_name", FieldType: "text", Label: json.RawMessage(`{"fr": "Prénom", "en": "First name"}`)},
+		{FormStepID: v2Step2, FieldName: "address", FieldType: "textarea", IsRequired: true},
+		{FormStepID: v2Step1, FieldName: "city", FieldType: "text"},
+		{FormStepID: v2Step2, FieldName: "postcode", FieldType: "text"},
+	})
+
+	diff := DiffFormVersions(from, to)
+
+	require.Equal(t, int32(1), diff.FromVersion)
+	require.Equal(t, int32(2), diff.ToVersion)
+	require.Equal(t, []string{"name"}, diff.ChangedMetadata)
+	require.Empty(t, diff.AddedSteps)
+	require.Empty(t, diff.RemovedSteps)
+	require.Equal(t, []StepChange{{StepNumber: 2, Attributes: []string{"name"}}}, diff.ChangedSteps)
+	require.Equal(t, []string{"postcode"}, diff.AddedFields)
+	require.Equal(t, []string{"middle_name"}, diff.RemovedFields)
+	require.Equal(t, []FieldChange{
+		{FieldName: "address", Attributes: []string{"field_type", "is_required"}},
+		{FieldName: "city", Attributes: []string{"step_number"}},
+	}, diff.ChangedFields)
+}
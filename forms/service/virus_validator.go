@@ -1,13 +1,119 @@
 package service
 
-import "mime/multipart"
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"time"
+
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/uploader"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// Scan statuses recorded on submission files
+const (
+	ScanStatusNotScanned = "not_scanned"
+	ScanStatusClean      = "clean"
+	ScanStatusInfected   = "infected"
+	ScanStatusFailed     = "failed" // the scanner was unavailable and the file was accepted anyway
+)
+
+// ErrVirusScanUnavailable is returned for uploads that could not be scanned when scanning fails closed
+var ErrVirusScanUnavailable = errors.New("uploads can't be scanned for viruses right now, please try again later")
+
+// ScanResult is the verdict of a virus scan
+type ScanResult struct {
+	Status    string    `json:"status"`
+	Signature string    `json:"signature,omitempty"` // the virus found, or why the scan failed
+	ScannedAt time.Time `json:"scanned_at"`
+}
+
+// FileScans holds the verdicts of the uploads of a request that were scanned during validation,
+// so each upload is scanned once and its verdict recorded when it is stored
+type FileScans map[*multipart.FileHeader]ScanResult
 
 type NullVirusScanner struct{}
 
-func (NullVirusScanner) ScanFile(file multipart.File) (bool, error) {
-	return file != nil, nil
+func (NullVirusScanner) ScanFile(file multipart.File) (ScanResult, error) {
+	return ScanResult{Status: ScanStatusNotScanned}, nil
 }
 
 func NewNullVirusScanner() *NullVirusScanner {
 	return &NullVirusScanner{}
 }
+
+// scanFile scans a file and applies the configured failure policy. When the scanner can't be
+// reached the file is rejected with ErrVirusScanUnavailable, unless VirusScanFailOpen is set,
+// in which case it is accepted with the failed status so it can be rescanned later.
+func (s *FormService) scanFile(file multipart.File) (ScanResult, error) {
+	if s.fileValidator == nil || s.fileValidator.virusScanner == nil {
+		return ScanResult{Status: ScanStatusNotScanned}, nil
+	}
+
+	file.Seek(0, io.SeekStart)
+	result, err := s.fileValidator.virusScanner.ScanFile(file)
+	file.Seek(0, io.SeekStart)
+	if err == nil {
+		return result, nil
+	}
+
+	result = ScanResult{Status: ScanStatusFailed, Signature: err.Error(), ScannedAt: time.Now()}
+	if s.config != nil && s.config.VirusScanFailOpen {
+		s.logger.Error(err, map[string]interface{}{
+			"message": "virus scan failed, accepting the file unscanned",
+		})
+		return result, nil
+	}
+	return result, fmt.Errorf("%w: %v", ErrVirusScanUnavailable, err)
+}
+
+// scanUpload scans a file before it is uploaded to path. Infected files are moved to the
+// quarantine location instead and rejected with a validation error on the field.
+func (s *FormService) scanUpload(file multipart.File, userID uuid.UUID, fieldName, fileName, path string) (ScanResult, error) {
+	result, err := s.scanFile(file)
+	if err != nil {
+		return result, err
+	}
+	if result.Status != ScanStatusInfected {
+		return result, nil
+	}
+
+	bucket := s.config.QuarantineBucket
+	if bucket == "" {
+		bucket = s.config.FileBucket
+	}
+	quarantinePath := uploader.QuarantinePath(path)
+	if err := s.uploader.Upload(file, bucket, quarantinePath); err != nil {
+		s.logger.Error(fmt.Errorf("failed to quarantine infected file: %w", err), map[string]interface{}{
+			"user_id":   userID,
+			"field":     fieldName,
+			"signature": result.Signature,
+		})
+	} else {
+		s.logger.Error(errors.New("infected file uploaded"), map[string]interface{}{
+			"user_id":    userID,
+			"field":      fieldName,
+			"filename":   fileName,
+			"signature":  result.Signature,
+			"quarantine": bucket + "/" + quarantinePath,
+		})
+	}
+
+	return result, validator.NewValidationError("file validation failed", map[string]string{
+		fieldName: fmt.Sprintf("%s failed virus scan", fileName),
+	})
+}
+
+// withScanResult records a scan result on an uploaded file
+func withScanResult(file db.FormSubmissionFileInput, result ScanResult) db.FormSubmissionFileInput {
+	file.ScanStatus = result.Status
+	file.ScanSignature = result.Signature
+	if !result.ScannedAt.IsZero() {
+		file.ScannedAt = sql.NullTime{Time: result.ScannedAt, Valid: true}
+	}
+	return file
+}
@@ -398,6 +398,19 @@ function App() {
     [formData, selectedSubmission, apiConfig]
   );
 
+  // Load the options of a dependent dropdown
+  const loadFieldOptions = useCallback(
+    async (fieldName: string, values: Record<string, string>) => {
+      if (!formData) return [];
+      const query = new URLSearchParams(values).toString();
+      const result = await makeApiCall(
+        `/forms/${formData.form_definition.id}/fields/${fieldName}/options?${query}`
+      );
+      return result.data || [];
+    },
+    [formData, makeApiCall]
+  );
+
   // Auto-load submissions on component mount
   useEffect(() => {
     if (apiConfig.jwt) {
@@ -721,6 +734,7 @@ function App() {
             onSubmit={handleFormSubmit}
             onStepSubmit={handleStepSubmit}
             isLoading={loading}
+            loadOptions={loadFieldOptions}
           />
         )}
 
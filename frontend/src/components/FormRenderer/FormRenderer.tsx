@@ -17,8 +17,10 @@ import {
   CheckCircle,
   Download,
   Trash2,
+  Plus,
 } from "lucide-react";
 import type { FormRendererProps, FormField, FormStep, FormData } from "./types";
+import { resolveFieldStates, stripHiddenValues } from "./conditionalLogic";
 
 // ==================== Utility Functions ====================
 const getLocalizedText = (
@@ -29,10 +31,69 @@ const getLocalizedText = (
   return textObj[locale] || textObj["en"] || Object.values(textObj)[0] || "";
 };
 
+const isGroupField = (field: FormField) =>
+  field.field_type === "group" || field.field_type === "repeater";
+
+// Fields of each item of a group field, as declared in its options
+const groupItemFields = (field: FormField): FormField[] =>
+  (field.options?.fields || []).map((item: any, index: number) => ({
+    id: `${field.id}-${item.field_name}`,
+    form_step_id: field.form_step_id,
+    field_name: item.field_name,
+    field_type: item.field_type,
+    label: item.label || {},
+    placeholder: item.placeholder,
+    help_text: item.help_text,
+    validation_rules: item.validation_rules || {},
+    options: item.options,
+    display_order: item.display_order ?? index,
+    is_required: item.is_required,
+    is_readonly: item.is_readonly,
+    default_value: item.default_value || "",
+    file_config: item.file_config,
+  }));
+
+// Group items are submitted as owners[0].first_name keys, which the server folds back into a list
+const flattenGroupValues = (
+  fields: FormField[],
+  values: Record<string, any>
+): Record<string, any> => {
+  const flat: Record<string, any> = { ...values };
+  fields.filter(isGroupField).forEach((field) => {
+    const items = values[field.field_name];
+    if (!Array.isArray(items)) return;
+    delete flat[field.field_name];
+    items.forEach((item: Record<string, any>, index: number) => {
+      Object.entries(item || {}).forEach(([key, value]) => {
+        if (value !== undefined && value !== null && value !== "") {
+          flat[`${field.field_name}[${index}].${key}`] = value;
+        }
+      });
+    });
+  });
+  return flat;
+};
+
 const validateField = (field: FormField, value: any): string[] => {
   const errors: string[] = [];
   const rules = field.validation_rules || {};
 
+  if (isGroupField(field)) {
+    const items: Record<string, any>[] = Array.isArray(value) ? value : [];
+    const min = rules.min_items ?? (field.is_required ? 1 : 0);
+    if (items.length < min) {
+      errors.push(`Add at least ${min} ${min === 1 ? "entry" : "entries"}`);
+    }
+    items.forEach((item, index) => {
+      groupItemFields(field).forEach((itemField) => {
+        validateField(itemField, item?.[itemField.field_name]).forEach(
+          (error) => errors.push(`Entry ${index + 1}: ${error}`)
+        );
+      });
+    });
+    return errors;
+  }
+
   // Required validation
   if (
     field.is_required &&
@@ -102,42 +163,6 @@ const validateField = (field: FormField, value: any): string[] => {
   return errors;
 };
 
-const checkConditionalLogic = (
-  field: FormField,
-  formValues: Record<string, any>
-): boolean => {
-  const logic = field.conditional_logic;
-  if (!logic || !logic.conditions) return true;
-
-  const { conditions, logic: logicType = "all", action = "show" } = logic;
-
-  const results = conditions.map((condition: any) => {
-    const fieldValue = formValues[condition.field];
-    const conditionValue = condition.value;
-
-    switch (condition.operator) {
-      case "equals":
-        return fieldValue === conditionValue;
-      case "not_equals":
-        return fieldValue !== conditionValue;
-      case "in":
-        return (
-          Array.isArray(conditionValue) && conditionValue.includes(fieldValue)
-        );
-      case "not_in":
-        return (
-          Array.isArray(conditionValue) && !conditionValue.includes(fieldValue)
-        );
-      default:
-        return true;
-    }
-  });
-
-  const shouldShow =
-    logicType === "all" ? results.every(Boolean) : results.some(Boolean);
-  return action === "show" ? shouldShow : !shouldShow;
-};
-
 // ==================== Existing File Component ====================
 const ExistingFileDisplay: React.FC<{
   files: any[];
@@ -683,6 +708,93 @@ const DateInput: React.FC<{
   );
 };
 
+const GroupInput: React.FC<{
+  field: FormField;
+  value: Record<string, any>[];
+  onChange: (value: Record<string, any>[]) => void;
+  errors: string[];
+  groupFiles?: Record<string, any[]>;
+  isLoading?: boolean;
+}> = ({ field, value, onChange, errors, groupFiles = {}, isLoading }) => {
+  const items = Array.isArray(value) ? value : [];
+  const itemFields = groupItemFields(field).sort(
+    (a, b) => a.display_order - b.display_order
+  );
+  const maxItems = field.validation_rules?.max_items;
+  const minItems = field.validation_rules?.min_items || 0;
+
+  const updateItem = (index: number, name: string, itemValue: any) =>
+    onChange(
+      items.map((item, i) =>
+        i === index ? { ...item, [name]: itemValue } : item
+      )
+    );
+
+  return (
+    <div className="mb-4 w-full">
+      <label className="block text-sm font-medium text-gray-700 mb-2">
+        {getLocalizedText(field.label)}
+        {field.is_required && <span className="text-red-500 ml-1">*</span>}
+      </label>
+      <div className="space-y-4">
+        {items.map((item, index) => (
+          <div
+            key={index}
+            className="p-4 border border-gray-200 rounded-md bg-gray-50"
+          >
+            <div className="flex items-center justify-between mb-3">
+              <span className="text-sm font-medium text-gray-700">
+                {getLocalizedText(field.label)} {index + 1}
+              </span>
+              {!field.is_readonly && items.length > minItems && (
+                <button
+                  type="button"
+                  onClick={() => onChange(items.filter((_, i) => i !== index))}
+                  className="text-red-600 hover:text-red-800"
+                >
+                  <Trash2 className="w-4 h-4" />
+                </button>
+              )}
+            </div>
+            {itemFields.map((itemField) => (
+              <FieldRenderer
+                key={itemField.id}
+                field={itemField}
+                value={item?.[itemField.field_name]}
+                onChange={(v) => updateItem(index, itemField.field_name, v)}
+                errors={[]}
+                formValues={item || {}}
+                existingFiles={
+                  groupFiles[
+                    `${field.field_name}[${index}].${itemField.field_name}`
+                  ]
+                }
+                isLoading={isLoading}
+              />
+            ))}
+          </div>
+        ))}
+      </div>
+      {!field.is_readonly && (!maxItems || items.length < maxItems) && (
+        <button
+          type="button"
+          onClick={() => onChange([...items, {}])}
+          className="mt-3 inline-flex items-center text-sm text-blue-600 hover:text-blue-800"
+        >
+          <Plus className="w-4 h-4 mr-1" />
+          Add {getLocalizedText(field.label)}
+        </button>
+      )}
+      {errors.map((error, index) => (
+        <p key={index} className="mt-1 text-sm text-red-600 flex items-center">
+          <AlertCircle className="w-4 h-4 mr-1 flex-shrink-0" />
+          {error}
+        </p>
+      ))}
+    </div>
+  );
+};
+
 // ==================== Field Renderer ====================
 const FieldRenderer: React.FC<{
   field: FormField;
@@ -693,22 +805,31 @@ const FieldRenderer: React.FC<{
   existingFiles?: any[];
   onDeleteExistingFile?: (fileId: string) => void;
   isLoading?: boolean;
+  groupFiles?: Record<string, any[]>;
 }> = ({
   field,
   value,
   onChange,
   errors,
-  formValues,
   existingFiles,
   onDeleteExistingFile,
   isLoading,
+  groupFiles,
 }) => {
-  // Check conditional logic
-  const shouldShow = checkConditionalLogic(field, formValues);
-
-  if (!shouldShow) return null;
-
   switch (field.field_type) {
+    case "group":
+    case "repeater":
+      return (
+        <GroupInput
+          field={field}
+          value={value}
+          onChange={onChange}
+          errors={errors}
+          groupFiles={groupFiles}
+          isLoading={isLoading}
+        />
+      );
+
     case "text":
     case "email":
       return (
@@ -892,12 +1013,15 @@ const FormRenderer: React.FC<FormRendererProps> = ({
   onSubmit,
   onStepSubmit,
   isLoading = false,
+  loadOptions,
 }) => {
   const {
     form_definition,
     steps,
     fields,
     existing_data,
+    review_comments,
+    group_files,
     current_step,
     completion_percentage,
   } = formData;
@@ -949,6 +1073,63 @@ const FormRenderer: React.FC<FormRendererProps> = ({
   const [errors, setErrors] = useState<Record<string, string[]>>({});
   const [completedSteps, setCompletedSteps] = useState<Set<number>>(new Set());
 
+  // Options of dependent dropdowns, reloaded when the fields they depend on change
+  const [dynamicOptions, setDynamicOptions] = useState<Record<string, any[]>>(
+    {}
+  );
+
+  const dependentFields = useMemo(
+    () =>
+      fields.filter(
+        (field) => (field.options?.dynamic?.depends_on || []).length > 0
+      ),
+    [fields]
+  );
+
+  const dependencyKey = JSON.stringify(
+    dependentFields.map((field) =>
+      field.options!.dynamic.depends_on.map(
+        (name: string) => formValues[name] ?? ""
+      )
+    )
+  );
+
+  useEffect(() => {
+    if (!loadOptions) return;
+    let cancelled = false;
+
+    dependentFields.forEach((field) => {
+      const dependsOn: string[] = field.options!.dynamic.depends_on;
+      const values: Record<string, string> = {};
+      dependsOn.forEach((name) => {
+        if (formValues[name] !== undefined && formValues[name] !== "") {
+          values[name] = String(formValues[name]);
+        }
+      });
+
+      if (Object.keys(values).length < dependsOn.length) {
+        setDynamicOptions((prev) => ({ ...prev, [field.field_name]: [] }));
+        return;
+      }
+
+      loadOptions(field.field_name, values)
+        .then((options) => {
+          if (!cancelled) {
+            setDynamicOptions((prev) => ({
+              ...prev,
+              [field.field_name]: options,
+            }));
+          }
+        })
+        .catch(() => {});
+    });
+
+    return () => {
+      cancelled = true;
+    };
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [dependencyKey, dependentFields, loadOptions]);
+
   // Get current step fields
   const currentStepFields = useMemo(() => {
     const currentStepData = steps.find(
@@ -961,14 +1142,35 @@ const FormRenderer: React.FC<FormRendererProps> = ({
       .sort((a, b) => a.display_order - b.display_order);
   }, [fields, steps, currentStepNumber]);
 
+  // Resolve show/hide/require rules across the whole form, as the server does
+  const fieldStates = useMemo(
+    () => resolveFieldStates(fields, formValues),
+    [fields, formValues]
+  );
+
+  // Fields of the current step that are visible, with their required flag resolved
+  const visibleStepFields = useMemo(
+    () =>
+      currentStepFields
+        .filter((field) => fieldStates[field.field_name]?.visible !== false)
+        .map((field) => ({
+          ...field,
+          is_required:
+            fieldStates[field.field_name]?.required ?? field.is_required,
+          options:
+            dynamicOptions[field.field_name] !== undefined
+              ? { ...field.options, static: dynamicOptions[field.field_name] }
+              : field.options,
+        })),
+    [currentStepFields, fieldStates, dynamicOptions]
+  );
+
   // Validate current step
   const validateCurrentStep = useCallback(() => {
     const stepErrors: Record<string, string[]> = {};
     let hasErrors = false;
 
-    currentStepFields.forEach((field) => {
-      if (!checkConditionalLogic(field, formValues)) return;
-
+    visibleStepFields.forEach((field) => {
       const fieldErrors = validateField(field, formValues[field.field_name]);
       if (fieldErrors.length > 0) {
         stepErrors[field.field_name] = fieldErrors;
@@ -978,7 +1180,7 @@ const FormRenderer: React.FC<FormRendererProps> = ({
 
     setErrors(stepErrors);
     return !hasErrors;
-  }, [currentStepFields, formValues]);
+  }, [visibleStepFields, formValues]);
 
   // Handle field value change
   const handleFieldChange = useCallback((fieldName: string, value: any) => {
@@ -1030,14 +1232,14 @@ const FormRenderer: React.FC<FormRendererProps> = ({
 
     // Save step progress if callback provided
     if (onStepSubmit) {
-      const stepData = currentStepFields.reduce((acc, field) => {
+      const stepData = visibleStepFields.reduce((acc, field) => {
         if (formValues[field.field_name] !== undefined) {
           acc[field.field_name] = formValues[field.field_name];
         }
         return acc;
       }, {} as Record<string, any>);
 
-      onStepSubmit(currentStepNumber, stepData);
+      onStepSubmit(currentStepNumber, flattenGroupValues(fields, stepData));
     }
 
     // Move to next step
@@ -1048,10 +1250,11 @@ const FormRenderer: React.FC<FormRendererProps> = ({
   }, [
     validateCurrentStep,
     currentStepNumber,
-    currentStepFields,
+    visibleStepFields,
     formValues,
     onStepSubmit,
     steps.length,
+    fields,
   ]);
 
   // Handle previous step
@@ -1085,7 +1288,10 @@ const FormRenderer: React.FC<FormRendererProps> = ({
         }
       }
 
-      onSubmit(formValues, false); // false = not a draft
+      onSubmit(
+        flattenGroupValues(fields, stripHiddenValues(formValues, fieldStates)),
+        false
+      ); // false = not a draft
     },
     [
       validateCurrentStep,
@@ -1093,7 +1299,9 @@ const FormRenderer: React.FC<FormRendererProps> = ({
       steps,
       completedSteps,
       currentStepNumber,
+      fields,
       formValues,
+      fieldStates,
       onSubmit,
     ]
   );
@@ -1105,9 +1313,12 @@ const FormRenderer: React.FC<FormRendererProps> = ({
       event.preventDefault();
       event.stopPropagation();
 
-      onSubmit(formValues, true); // true = save as draft
+      onSubmit(
+        flattenGroupValues(fields, stripHiddenValues(formValues, fieldStates)),
+        true
+      ); // true = save as draft
     },
-    [formValues, onSubmit]
+    [fields, formValues, fieldStates, onSubmit]
   );
 
   const isFirstStep = currentStepNumber === 1;
@@ -1167,20 +1378,28 @@ const FormRenderer: React.FC<FormRendererProps> = ({
       >
         {/* Form Fields */}
         <div className="space-y-4 md:space-y-6 mb-6 md:mb-8">
-          {currentStepFields.map((field) => (
-            <FieldRenderer
-              key={field.id}
-              field={field}
-              value={formValues[field.field_name]}
-              onChange={(value) => handleFieldChange(field.field_name, value)}
-              errors={errors[field.field_name] || []}
-              formValues={formValues}
-              existingFiles={existingFiles[field.field_name]}
-              onDeleteExistingFile={(fileId) =>
-                handleDeleteExistingFile(field.field_name, fileId)
-              }
-              isLoading={isLoading}
-            />
+          {visibleStepFields.map((field) => (
+            <React.Fragment key={field.id}>
+              {review_comments?.[field.field_name] && (
+                <p className="text-sm text-amber-700 bg-amber-50 border border-amber-200 rounded-md px-3 py-2 flex items-center">
+                  <AlertCircle className="w-4 h-4 mr-1 flex-shrink-0" />
+                  {review_comments[field.field_name]}
+                </p>
+              )}
+              <FieldRenderer
+                field={field}
+                value={formValues[field.field_name]}
+                onChange={(value) => handleFieldChange(field.field_name, value)}
+                errors={errors[field.field_name] || []}
+                formValues={formValues}
+                existingFiles={existingFiles[field.field_name]}
+                onDeleteExistingFile={(fileId) =>
+                  handleDeleteExistingFile(field.field_name, fileId)
+                }
+                isLoading={isLoading}
+                groupFiles={group_files}
+              />
+            </React.Fragment>
           ))}
         </div>
 
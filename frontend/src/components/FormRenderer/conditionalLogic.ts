@@ -0,0 +1,182 @@
+// src/components/FormRenderer/conditionalLogic.ts
+//
+// Mirrors the server-side engine in internal/forms/service/conditional_logic.go.
+// Both must evaluate rules the same way, so keep them in sync.
+
+import type {
+  FormField,
+  ConditionalLogic,
+  ConditionGroup,
+  Condition,
+  FieldState,
+} from "./types";
+
+const isBlank = (value: any): boolean => {
+  if (value === null || value === undefined) return true;
+  if (typeof value === "string") return value.trim() === "";
+  if (Array.isArray(value)) return value.length === 0;
+  if (typeof value === "object") return Object.keys(value).length === 0;
+  return false;
+};
+
+// Strict equality, treating a missing answer and null as the same value
+const valuesEqual = (a: any, b: any): boolean => {
+  if (a === null || a === undefined || b === null || b === undefined) {
+    return (a === null || a === undefined) && (b === null || b === undefined);
+  }
+  return a === b;
+};
+
+const listContains = (list: any[], value: any): boolean =>
+  list.some((item) => valuesEqual(item, value));
+
+const toOrderNumber = (value: any): number | null => {
+  if (typeof value === "number") return value;
+  if (typeof value === "string" && value.trim() !== "") {
+    const n = Number(value.trim());
+    return Number.isNaN(n) ? null : n;
+  }
+  return null;
+};
+
+// Numeric comparison, accepting numeric strings; falls back to string order for ISO dates
+const compareValues = (a: any, b: any): number | null => {
+  const an = toOrderNumber(a);
+  const bn = toOrderNumber(b);
+  if (an !== null && bn !== null) {
+    return an < bn ? -1 : an > bn ? 1 : 0;
+  }
+  if (typeof a === "string" && typeof b === "string") {
+    return a < b ? -1 : a > b ? 1 : 0;
+  }
+  return null;
+};
+
+const evaluateCondition = (
+  condition: Condition,
+  values: Record<string, any>
+): boolean => {
+  const value = values[condition.field];
+  const expected = condition.value;
+
+  switch (condition.operator) {
+    case "equals":
+      return valuesEqual(value, expected);
+    case "not_equals":
+      return !valuesEqual(value, expected);
+    case "in":
+      return Array.isArray(expected) && listContains(expected, value);
+    case "not_in":
+      return Array.isArray(expected) && !listContains(expected, value);
+    case "gt":
+    case "gte":
+    case "lt":
+    case "lte": {
+      const cmp = compareValues(value, expected);
+      if (cmp === null) return false;
+      if (condition.operator === "gt") return cmp > 0;
+      if (condition.operator === "gte") return cmp >= 0;
+      if (condition.operator === "lt") return cmp < 0;
+      return cmp <= 0;
+    }
+    case "contains":
+      if (typeof value === "string") {
+        return (
+          typeof expected === "string" &&
+          value.toLowerCase().includes(expected.toLowerCase())
+        );
+      }
+      return Array.isArray(value) && listContains(value, expected);
+    case "is_empty":
+      return isBlank(value);
+    case "is_not_empty":
+      return !isBlank(value);
+    default:
+      // Unknown operators never block a rule
+      return true;
+  }
+};
+
+const isEmptyGroup = (group?: ConditionGroup): boolean =>
+  !group ||
+  ((!group.conditions || group.conditions.length === 0) &&
+    (!group.groups || group.groups.length === 0));
+
+const evaluateGroup = (
+  group: ConditionGroup,
+  values: Record<string, any>
+): boolean => {
+  if (isEmptyGroup(group)) return true;
+
+  const results = [
+    ...(group.conditions || []).map((c) => evaluateCondition(c, values)),
+    ...(group.groups || []).map((g) => evaluateGroup(g, values)),
+  ];
+
+  return group.logic === "any"
+    ? results.some(Boolean)
+    : results.every(Boolean);
+};
+
+const visibleValues = (
+  values: Record<string, any>,
+  states: Record<string, FieldState>
+): Record<string, any> =>
+  Object.keys(values).reduce((acc, key) => {
+    if (!states[key] || states[key].visible) acc[key] = values[key];
+    return acc;
+  }, {} as Record<string, any>);
+
+// resolveFieldStates evaluates every field's conditional logic. A hidden field counts as
+// unanswered for the rules of other fields, so states are re-evaluated until they settle.
+export const resolveFieldStates = (
+  fields: FormField[],
+  values: Record<string, any>
+): Record<string, FieldState> => {
+  const states: Record<string, FieldState> = {};
+  fields.forEach((field) => {
+    states[field.field_name] = { visible: true, required: field.is_required };
+  });
+
+  for (let pass = 0; pass <= fields.length; pass++) {
+    const current = visibleValues(values, states);
+    let changed = false;
+
+    fields.forEach((field) => {
+      const logic = field.conditional_logic as ConditionalLogic | undefined;
+      const state: FieldState = { visible: true, required: field.is_required };
+
+      if (logic && !isEmptyGroup(logic)) {
+        const matched = evaluateGroup(logic, current);
+        switch (logic.action) {
+          case "hide":
+            state.visible = !matched;
+            break;
+          case "require":
+            state.required = field.is_required || matched;
+            break;
+          default:
+            state.visible = matched;
+        }
+      }
+
+      if (!state.visible) state.required = false;
+
+      const previous = states[field.field_name];
+      if (
+        previous.visible !== state.visible ||
+        previous.required !== state.required
+      ) {
+        changed = true;
+      }
+      states[field.field_name] = state;
+    });
+
+    if (!changed) break;
+  }
+
+  return states;
+};
+
+// stripHiddenValues drops the answers of fields hidden by conditional logic
+export const stripHiddenValues = visibleValues;
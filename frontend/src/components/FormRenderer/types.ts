@@ -42,6 +42,10 @@ export interface FormData {
   fields: FormField[];
   existing_data?: Record<string, any>;
   submission_id?: string;
+  // Reviewer comments per field when changes were requested
+  review_comments?: Record<string, string>;
+  // Uploaded files of group items, keyed like owners[0].id_document
+  group_files?: Record<string, any[]>;
   current_step: number;
   completion_percentage: number;
 }
@@ -51,6 +55,11 @@ export interface FormRendererProps {
   onSubmit: (data: Record<string, any>, isDraft?: boolean) => void;
   onStepSubmit?: (stepNumber: number, data: Record<string, any>) => void;
   isLoading?: boolean;
+  // Reloads the options of a dynamic field when a field it depends on changes
+  loadOptions?: (
+    fieldName: string,
+    values: Record<string, string>
+  ) => Promise<OptionValue[]>;
 }
 
 export interface FieldProps {
@@ -87,12 +96,36 @@ export type FileConfig = {
   max_files?: number;
 };
 
-export type ConditionalLogic = {
-  action: "show" | "hide";
+export type ConditionOperator =
+  | "equals"
+  | "not_equals"
+  | "in"
+  | "not_in"
+  | "gt"
+  | "gte"
+  | "lt"
+  | "lte"
+  | "contains"
+  | "is_empty"
+  | "is_not_empty";
+
+export type Condition = {
+  field: string;
+  operator: ConditionOperator;
+  value?: any;
+};
+
+export type ConditionGroup = {
   logic: "all" | "any";
-  conditions: Array<{
-    field: string;
-    operator: "equals" | "not_equals" | "in" | "not_in";
-    value: any;
-  }>;
+  conditions: Condition[];
+  groups?: ConditionGroup[];
+};
+
+export type ConditionalLogic = ConditionGroup & {
+  action: "show" | "hide" | "require";
+};
+
+export type FieldState = {
+  visible: boolean;
+  required: boolean;
 };
@@ -0,0 +1,156 @@
+package invoice
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/uploader"
+)
+
+// TaskCheckTransferInvoice is the background task that checks an uploaded transfer invoice. Its
+// payload is a CheckTask, and the worker runs it with Checker.Run.
+const TaskCheckTransferInvoice = "invoice:check_transfer"
+
+var errDocumentReplaced = errors.New("invoice document was replaced")
+
+// CheckTask identifies the uploaded invoice a pending check was recorded for.
+type CheckTask struct {
+	TransactionID uuid.UUID `json:"transaction_id"`
+	DocumentID    uuid.UUID `json:"document_id"`
+	ContentType   string    `json:"content_type"`
+}
+
+// FlaggedFunc is called with the checks that need compliance review.
+type FlaggedFunc func(ctx context.Context, transfer db.Transaction, check db.TransferInvoiceCheck)
+
+// Checker extracts uploaded transfer invoices, matches them against their transfers and stores
+// the result on their pending checks.
+type Checker struct {
+	store    db.Store
+	files    uploader.FileUploader
+	pipeline *Pipeline
+	flagged  FlaggedFunc
+}
+
+// NewChecker creates a checker extracting invoices with pipeline. flagged may be nil.
+func NewChecker(store db.Store, files uploader.FileUploader, pipeline *Pipeline, flagged FlaggedFunc) *Checker {
+	return &Checker{
+		store:    store,
+		files:    files,
+		pipeline: pipeline,
+		flagged:  flagged,
+	}
+}
+
+// Run checks the invoice of task. Extraction failures are stored on the check for manual
+// review; only failures to load the transfer or store the check are returned so the task is
+// retried. A check that is no longer pending, because a newer invoice was uploaded, is left as is.
+func (c *Checker) Run(ctx context.Context, task CheckTask) error {
+	transfer, err := c.store.GetTransaction(ctx, task.TransactionID)
+	if err != nil {
+		return err
+	}
+
+	arg := db.CreateTransferInvoiceCheckParams{
+		TransactionID: task.TransactionID,
+		DocumentID:    task.DocumentID,
+		Status:        StatusUnprocessed,
+	}
+
+	extracted, err := c.extract(ctx, transfer, task)
+	if err != nil {
+		arg.Error = err.Error()
+	} else {
+		currency, err := c.store.GetCurrency(ctx, transfer.CurrencyID)
+		if err != nil {
+			return err
+		}
+		mismatches := Match(extracted, ExpectationFor(transfer, currency.Code))
+		arg.Status = Status(mismatches)
+		arg.Mismatches, _ = json.Marshal(mismatches)
+		arg.Extracted, _ = json.Marshal(extracted)
+	}
+
+	check, err := c.store.CompleteTransferInvoiceCheck(ctx, arg)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if check.Status == StatusMatched {
+		return nil
+	}
+
+	_ = c.store.CreateTransactionHistory(ctx, db.CreateTransactionHistoryParams{
+		TransactionID: transfer.ID,
+		UserID:        transfer.UserID,
+		Reason:        "invoice flagged for compliance review",
+		Amount:        transfer.Amount,
+		OldStatus:     transfer.Status,
+		NewStatus:     transfer.Status,
+	})
+
+	if c.flagged != nil {
+		c.flagged(ctx, transfer, check)
+	}
+	return nil
+}
+
+func (c *Checker) extract(ctx context.Context, transfer db.Transaction, task CheckTask) (*Extracted, error) {
+	if !c.pipeline.Supports(task.ContentType) {
+		return nil, ErrUnsupportedContentType
+	}
+
+	doc, err := c.store.GetDocumentByModel(ctx, db.GetDocumentByModelParams{
+		Model:   db.DocumentModelTransferInvoice,
+		ModelID: transfer.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if doc.ID != task.DocumentID {
+		return nil, errDocumentReplaced
+	}
+
+	file, err := c.files.Download(doc.Bucket, doc.DocumentPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return c.pipeline.Extract(ctx, task.ContentType, file)
+}
+
+// ExpectationFor is what the invoice of an external transfer debited in currency must show.
+func ExpectationFor(transfer db.Transaction, currency string) Expectation {
+	var payload struct {
+		Recipient *struct {
+			Currency string `json:"currency"`
+			Data     struct {
+				AccountHolderFullname string `json:"account_holder_fullname"`
+				BusinessName          string `json:"business_name"`
+			} `json:"data"`
+		} `json:"recipient"`
+	}
+	_ = json.Unmarshal(transfer.Payload, &payload)
+
+	expectation := Expectation{
+		Amount:       transfer.Amount.Sub(transfer.FeesAmount),
+		Currency:     currency,
+		TransferDate: transfer.CreatedAt,
+	}
+	if r := payload.Recipient; r != nil {
+		expectation.PayoutCurrency = r.Currency
+		expectation.BeneficiaryName = r.Data.AccountHolderFullname
+		if expectation.BeneficiaryName == "" {
+			expectation.BeneficiaryName = r.Data.BusinessName
+		}
+	}
+	return expectation
+}
@@ -0,0 +1,153 @@
+package invoice
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/uploader"
+)
+
+// checkStore keeps one transfer, its invoice document and its invoice check; other store
+// methods are not used
+type checkStore struct {
+	db.Store
+	transfer db.Transaction
+	document db.Document
+	check    db.TransferInvoiceCheck
+	history  int
+}
+
+func (s *checkStore) GetTransaction(_ context.Context, id uuid.UUID) (db.Transaction, error) {
+	if id != s.transfer.ID {
+		return db.Transaction{}, sql.ErrNoRows
+	}
+	return s.transfer, nil
+}
+
+func (s *checkStore) GetCurrency(_ context.Context, id int32) (db.Currency, error) {
+	return db.Currency{ID: id, Code: "USD"}, nil
+}
+
+func (s *checkStore) GetDocumentByModel(_ context.Context, arg db.GetDocumentByModelParams) (db.Document, error) {
+	return s.document, nil
+}
+
+func (s *checkStore) CompleteTransferInvoiceCheck(_ context.Context, arg db.CreateTransferInvoiceCheckParams) (db.TransferInvoiceCheck, error) {
+	if s.check.TransactionID != arg.TransactionID || s.check.DocumentID != arg.DocumentID || s.check.Status != StatusPending {
+		return db.TransferInvoiceCheck{}, sql.ErrNoRows
+	}
+	s.check.Status = arg.Status
+	s.check.Extracted = arg.Extracted
+	s.check.Mismatches = arg.Mismatches
+	s.check.Error = arg.Error
+	return s.check, nil
+}
+
+func (s *checkStore) CreateTransactionHistory(context.Context, db.CreateTransactionHistoryParams) error {
+	s.history++
+	return nil
+}
+
+// fileStore serves uploaded files by path; other uploader methods are not used
+type fileStore struct {
+	uploader.FileUploader
+	files map[string][]byte
+}
+
+func (f fileStore) Download(_, path string) (io.ReadCloser, error) {
+	file, ok := f.files[path]
+	if !ok {
+		return nil, errors.New("file not found")
+	}
+	return io.NopCloser(bytes.NewReader(file)), nil
+}
+
+func TestCheckerRun(t *testing.T) {
+	content := `BT /F1 12 Tf 72 720 Td (Invoice No: INV-77) Tj T* (Date: 2024-03-05) Tj
+T* (Pay to: Jane Doe) Tj T* (Total: USD 500.00) Tj ET`
+
+	newStore := func() *checkStore {
+		transfer := db.Transaction{
+			ID:        uuid.New(),
+			Amount:    decimal.NewFromInt(505),
+			Status:    db.TransactionStatusPending,
+			Payload:   json.RawMessage(`{"recipient":{"currency":"CNY","data":{"account_holder_fullname":"Jane Doe"}}}`),
+			CreatedAt: time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC),
+		}
+		transfer.FeesAmount = decimal.NewFromInt(5)
+		document := db.Document{ID: uuid.New(), ModelID: transfer.ID, DocumentPath: "transfer/invoice/" + transfer.ID.String()}
+		return &checkStore{
+			transfer: transfer,
+			document: document,
+			check:    db.TransferInvoiceCheck{TransactionID: transfer.ID, DocumentID: document.ID, Status: StatusPending},
+		}
+	}
+	task := func(s *checkStore, contentType string) CheckTask {
+		return CheckTask{TransactionID: s.transfer.ID, DocumentID: s.document.ID, ContentType: contentType}
+	}
+
+	t.Run("matched", func(t *testing.T) {
+		store := newStore()
+		files := fileStore{files: map[string][]byte{store.document.DocumentPath: buildPDF(t, content, true)}}
+		var flagged int
+		checker := NewChecker(store, files, NewDefaultPipeline(), func(context.Context, db.Transaction, db.TransferInvoiceCheck) { flagged++ })
+
+		require.NoError(t, checker.Run(context.Background(), task(store, "application/pdf")))
+		require.Equal(t, StatusMatched, store.check.Status)
+		require.Contains(t, string(store.check.Extracted), "INV-77")
+		require.Zero(t, flagged)
+		require.Zero(t, store.history)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		store := newStore()
+		var flagged int
+		checker := NewChecker(store, fileStore{}, NewDefaultPipeline(), func(context.Context, db.Transaction, db.TransferInvoiceCheck) { flagged++ })
+
+		require.NoError(t, checker.Run(context.Background(), task(store, "image/png")))
+		require.Equal(t, StatusUnprocessed, store.check.Status)
+		require.Equal(t, ErrUnsupportedContentType.Error(), store.check.Error)
+		require.Equal(t, 1, flagged)
+		require.Equal(t, 1, store.history)
+	})
+
+	t.Run("superseded", func(t *testing.T) {
+		store := newStore()
+		files := fileStore{files: map[string][]byte{store.document.DocumentPath: buildPDF(t, content, true)}}
+		checker := NewChecker(store, files, NewDefaultPipeline(), nil)
+
+		stale := task(store, "application/pdf")
+		stale.DocumentID = uuid.New()
+		require.NoError(t, checker.Run(context.Background(), stale))
+		require.Equal(t, StatusPending, store.check.Status)
+	})
+}
+
+func TestPipelineConcurrentRegister(t *testing.T) {
+	p := NewDefaultPipeline()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			p.Register(NewPDFTextExtractor())
+		}()
+		go func() {
+			defer wg.Done()
+			require.True(t, p.Supports("application/pdf"))
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,91 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrUnsupportedContentType = errors.New("unsupported invoice content type")
+
+// Extracted is the invoice metadata read from an uploaded document. Fields that could not be
+// found are left at their zero value.
+type Extracted struct {
+	InvoiceNumber   string          `json:"invoice_number"`
+	Amount          decimal.Decimal `json:"amount"`
+	Currency        string          `json:"currency"`
+	BeneficiaryName string          `json:"beneficiary_name"`
+	Date            *time.Time      `json:"date"`
+	Extractor       string          `json:"extractor"`
+}
+
+// Extractor is an interface that defines the methods that must be implemented by an invoice extractor
+type Extractor interface {
+	Name() string
+	Supports(contentType string) bool
+	Extract(ctx context.Context, file io.Reader) (*Extracted, error)
+}
+
+// Pipeline runs the first registered extractor that supports the uploaded content type.
+type Pipeline struct {
+	mu         sync.RWMutex
+	extractors []Extractor
+}
+
+// Default is the pipeline transfer invoices are checked with. Extractors that need external
+// services, such as OCR for scanned invoices, are added to it at startup with Register.
+var Default = NewDefaultPipeline()
+
+// Register adds an extractor to the Default pipeline.
+func Register(e Extractor) {
+	Default.Register(e)
+}
+
+// NewPipeline creates a new extraction pipeline
+func NewPipeline(extractors ...Extractor) *Pipeline {
+	return &Pipeline{extractors: extractors}
+}
+
+// NewDefaultPipeline returns a pipeline with the extractors that run without external services.
+// Images need an OCR extractor to be registered by the caller.
+func NewDefaultPipeline() *Pipeline {
+	return NewPipeline(NewPDFTextExtractor())
+}
+
+// Register adds an extractor to the pipeline. Extractors registered first take precedence.
+func (p *Pipeline) Register(e Extractor) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.extractors = append(p.extractors, e)
+}
+
+// Supports returns true if any registered extractor supports contentType.
+func (p *Pipeline) Supports(contentType string) bool {
+	return p.extractor(contentType) != nil
+}
+
+// Extract runs the extractor that supports contentType.
+func (p *Pipeline) Extract(ctx context.Context, contentType string, file io.Reader) (*Extracted, error) {
+	e := p.extractor(contentType)
+	if e == nil {
+		return nil, ErrUnsupportedContentType
+	}
+	return e.Extract(ctx, file)
+}
+
+func (p *Pipeline) extractor(contentType string) Extractor {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, e := range p.extractors {
+		if e.Supports(contentType) {
+			return e
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package invoice
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleInvoiceText = `ACME Trading Co Ltd
+Invoice No: INV-2024-0042
+Invoice Date: 2024-03-05
+Beneficiary: Acme Trading Company Limited
+Subtotal: 1,150.00
+Total Due: USD 1,200.50
+`
+
+func buildPDF(t *testing.T, content string, compress bool) []byte {
+	t.Helper()
+
+	stream := []byte(content)
+	dict := fmt.Sprintf("<< /Length %d >>", len(stream))
+	if compress {
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		_, err := w.Write(stream)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		stream = buf.Bytes()
+		dict = fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>", len(stream))
+	}
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n1 0 obj\n")
+	pdf.WriteString(dict)
+	pdf.WriteString("\nstream\n")
+	pdf.Write(stream)
+	pdf.WriteString("\nendstream\nendobj\n%%EOF\n")
+	return pdf.Bytes()
+}
+
+func TestParseText(t *testing.T) {
+	res := ParseText(sampleInvoiceText)
+
+	require.Equal(t, "INV-2024-0042", res.InvoiceNumber)
+	require.True(t, decimal.RequireFromString("1200.50").Equal(res.Amount))
+	require.Equal(t, "USD", res.Currency)
+	require.Equal(t, "Acme Trading Company Limited", res.BeneficiaryName)
+	require.NotNil(t, res.Date)
+	require.Equal(t, "2024-03-05", res.Date.Format("2006-01-02"))
+}
+
+func TestParseText_CurrencySymbol(t *testing.T) {
+	res := ParseText("Invoice #A1234\nDate: 5 March 2024\nTotal £99.99")
+
+	require.Equal(t, "A1234", res.InvoiceNumber)
+	require.Equal(t, "GBP", res.Currency)
+	require.True(t, decimal.RequireFromString("99.99").Equal(res.Amount))
+	require.Equal(t, "2024-03-05", res.Date.Format("2006-01-02"))
+}
+
+func TestPDFTextExtractor(t *testing.T) {
+	content := `BT /F1 12 Tf 72 720 Td (Invoice No: INV-77) Tj T* (Date: 2024-03-05) Tj
+T* [(Pay to: Jane ) -250 (Doe)] TJ T* (Total: EUR 500.00) Tj ET`
+
+	for _, compress := range []bool{false, true} {
+		e := NewPDFTextExtractor()
+		require.True(t, e.Supports("application/pdf"))
+
+		res, err := e.Extract(context.Background(), bytes.NewReader(buildPDF(t, content, compress)))
+		require.NoError(t, err)
+		require.Equal(t, "INV-77", res.InvoiceNumber)
+		require.Equal(t, "Jane Doe", res.BeneficiaryName)
+		require.Equal(t, "EUR", res.Currency)
+		require.True(t, decimal.NewFromInt(500).Equal(res.Amount))
+		require.Equal(t, "pdf_text", res.Extractor)
+	}
+}
+
+func TestPipeline_Unsupported(t *testing.T) {
+	p := NewDefaultPipeline()
+	require.False(t, p.Supports("image/png"))
+
+	_, err := p.Extract(context.Background(), "image/png", bytes.NewReader(nil))
+	require.ErrorIs(t, err, ErrUnsupportedContentType)
+}
+
+func TestMatch(t *testing.T) {
+	ext := ParseText(sampleInvoiceText)
+	exp := Expectation{
+		Amount:          decimal.RequireFromString("1200"),
+		Currency:        "USD",
+		PayoutCurrency:  "CNY",
+		BeneficiaryName: "ACME Trading Ltd",
+		TransferDate:    time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC),
+	}
+
+	require.Empty(t, Match(ext, exp))
+	require.Equal(t, StatusMatched, Status(Match(ext, exp)))
+
+	exp.Amount = decimal.NewFromInt(2000)
+	exp.BeneficiaryName = "John Smith"
+	exp.TransferDate = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	mismatches := Match(ext, exp)
+	require.Equal(t, StatusFlagged, Status(mismatches))
+
+	fields := map[string]bool{}
+	for _, m := range mismatches {
+		fields[m.Field] = true
+	}
+	require.True(t, fields[FieldAmount])
+	require.True(t, fields[FieldBeneficiary])
+	require.True(t, fields[FieldDate])
+	require.False(t, fields[FieldCurrency])
+}
+
+func TestNamesMatch(t *testing.T) {
+	require.True(t, NamesMatch("Acme Trading Co. Ltd", "ACME TRADING"))
+	require.True(t, NamesMatch("Doe, Jane", "jane doe"))
+	require.False(t, NamesMatch("Jane Doe", "John Doe"))
+	require.False(t, NamesMatch("", "John Doe"))
+}
+
+func TestPDFText_TooLarge(t *testing.T) {
+	// Each stream inflates to maxPDFSize, together more than maxPDFContentSize
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	_, err := w.Write(make([]byte, maxPDFSize))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	for i := 0; i < 3; i++ {
+		fmt.Fprintf(&pdf, "%d 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", i+1, buf.Len())
+		pdf.Write(buf.Bytes())
+		pdf.WriteString("\nendstream\nendobj\n")
+	}
+	pdf.WriteString("%%EOF\n")
+
+	_, err = PDFText(pdf.Bytes())
+	require.ErrorIs(t, err, ErrPDFTooLarge)
+}
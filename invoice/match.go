@@ -0,0 +1,155 @@
+package invoice
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	StatusPending     = "pending"
+	StatusMatched     = "matched"
+	StatusFlagged     = "flagged"
+	StatusUnprocessed = "unprocessed"
+	StatusApproved    = "approved"
+	StatusRejected    = "rejected"
+
+	FieldInvoiceNumber = "invoice_number"
+	FieldAmount        = "amount"
+	FieldCurrency      = "currency"
+	FieldBeneficiary   = "beneficiary_name"
+	FieldDate          = "date"
+
+	// maxInvoiceAge is how long before the transfer an invoice may be dated.
+	maxInvoiceAge = 180 * 24 * time.Hour
+)
+
+var (
+	// amountTolerance is the relative difference allowed between the invoice and transfer amounts.
+	amountTolerance = decimal.NewFromFloat(0.01)
+
+	companySuffixes = map[string]bool{
+		"ltd": true, "limited": true, "llc": true, "inc": true, "co": true, "company": true,
+		"corp": true, "corporation": true, "plc": true, "gmbh": true, "sa": true, "the": true,
+	}
+)
+
+// Expectation is what the invoice of a transfer is checked against.
+type Expectation struct {
+	Amount          decimal.Decimal
+	Currency        string
+	PayoutCurrency  string
+	BeneficiaryName string
+	TransferDate    time.Time
+}
+
+// Mismatch is a field of the invoice that did not agree with the transfer.
+type Mismatch struct {
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Found    string `json:"found"`
+	Reason   string `json:"reason"`
+}
+
+// Match cross-checks extracted invoice values against the transfer and returns the mismatches.
+func Match(ext *Extracted, exp Expectation) []Mismatch {
+	var mismatches []Mismatch
+
+	add := func(field, expected, found, reason string) {
+		mismatches = append(mismatches, Mismatch{Field: field, Expected: expected, Found: found, Reason: reason})
+	}
+
+	if ext.InvoiceNumber == "" {
+		add(FieldInvoiceNumber, "", "", "invoice number not found")
+	}
+
+	currency := strings.ToUpper(ext.Currency)
+	switch {
+	case currency == "":
+		add(FieldCurrency, exp.Currency, "", "currency not found")
+	case !strings.EqualFold(currency, exp.Currency) && !strings.EqualFold(currency, exp.PayoutCurrency):
+		add(FieldCurrency, exp.Currency, currency, "currency does not match transfer")
+	}
+
+	switch {
+	case ext.Amount.IsZero():
+		add(FieldAmount, exp.Amount.String(), "", "amount not found")
+	case currency == "" || strings.EqualFold(currency, exp.Currency):
+		// amounts in the payout currency depend on the applied rate and are left to review
+		if !amountWithinTolerance(ext.Amount, exp.Amount) {
+			add(FieldAmount, exp.Amount.String(), ext.Amount.String(), "amount does not match transfer")
+		}
+	}
+
+	switch {
+	case ext.BeneficiaryName == "":
+		add(FieldBeneficiary, exp.BeneficiaryName, "", "beneficiary name not found")
+	case !NamesMatch(ext.BeneficiaryName, exp.BeneficiaryName):
+		add(FieldBeneficiary, exp.BeneficiaryName, ext.BeneficiaryName, "beneficiary name does not match recipient")
+	}
+
+	if ext.Date == nil {
+		add(FieldDate, "", "", "invoice date not found")
+	} else if !exp.TransferDate.IsZero() {
+		found := ext.Date.Format("2006-01-02")
+		switch {
+		case ext.Date.After(exp.TransferDate.AddDate(0, 0, 1)):
+			add(FieldDate, exp.TransferDate.Format("2006-01-02"), found, "invoice is dated after the transfer")
+		case exp.TransferDate.Sub(*ext.Date) > maxInvoiceAge:
+			add(FieldDate, exp.TransferDate.Format("2006-01-02"), found, "invoice is older than 180 days")
+		}
+	}
+
+	return mismatches
+}
+
+// Status returns the review status for a set of mismatches.
+func Status(mismatches []Mismatch) string {
+	if len(mismatches) == 0 {
+		return StatusMatched
+	}
+	return StatusFlagged
+}
+
+func amountWithinTolerance(found, expected decimal.Decimal) bool {
+	if expected.IsZero() {
+		return found.IsZero()
+	}
+	diff := found.Sub(expected).Abs().Div(expected.Abs())
+	return diff.LessThanOrEqual(amountTolerance)
+}
+
+// NamesMatch returns true if the significant words of one name are all contained in the other,
+// ignoring case, punctuation and company suffixes.
+func NamesMatch(a, b string) bool {
+	ta, tb := nameTokens(a), nameTokens(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return false
+	}
+	if len(ta) > len(tb) {
+		ta, tb = tb, ta
+	}
+
+	for token := range ta {
+		if !tb[token] {
+			return false
+		}
+	}
+	return true
+}
+
+func nameTokens(name string) map[string]bool {
+	fields := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		if !companySuffixes[f] {
+			tokens[f] = true
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,151 @@
+package invoice
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	invoiceNumberRgx = regexp.MustCompile(`(?i)invoice\s*(?:no\.?|number|num\.?|#|id)?\s*[:#]?\s*([A-Z0-9][A-Z0-9\-/]{2,})`)
+	totalLineRgx     = regexp.MustCompile(`(?i)\b(grand\s+total|total\s+due|amount\s+due|balance\s+due|total\s+amount|amount\s+payable|total)\b`)
+	subtotalRgx      = regexp.MustCompile(`(?i)sub\s*-?\s*total`)
+	moneyRgx         = regexp.MustCompile(`(?i)(\b[A-Z]{3}\b|[$€£¥₹])?\s*([0-9]{1,3}(?:[,\s][0-9]{3})+(?:\.[0-9]{1,2})?|[0-9]+(?:\.[0-9]{1,2})?)\s*(\b[A-Z]{3}\b)?`)
+	beneficiaryRgx   = regexp.MustCompile(`(?im)^\s*(?:beneficiary(?:\s+name)?|payee|account\s+name|pay\s+to|remit\s+to|bill\s+from|from|supplier|seller)\s*[:\-]\s*(.+)$`)
+	dateLineRgx      = regexp.MustCompile(`(?i)(?:invoice\s+date|date\s+of\s+issue|issue\s+date|date)\s*[:\-]?\s*([0-9A-Za-z,./\- ]{6,30})`)
+
+	currencySymbols = map[string]string{
+		"$": "USD",
+		"€": "EUR",
+		"£": "GBP",
+		"¥": "CNY",
+		"₹": "INR",
+	}
+
+	knownCurrencies = []string{"USD", "EUR", "GBP", "CNY", "RMB", "INR", "CAD", "NGN", "AUD", "JPY", "HKD", "AED"}
+	currencyCodeRgx = regexp.MustCompile(`\b(` + strings.Join(knownCurrencies, "|") + `)\b`)
+
+	dateLayouts = []string{
+		"2006-01-02",
+		"2006/01/02",
+		"02/01/2006",
+		"02-01-2006",
+		"02.01.2006",
+		"2 Jan 2006",
+		"2 January 2006",
+		"Jan 2, 2006",
+		"January 2, 2006",
+		"Jan 2 2006",
+		"January 2 2006",
+	}
+)
+
+// ParseText reads invoice metadata from the plain text of an invoice.
+func ParseText(text string) *Extracted {
+	res := &Extracted{}
+
+	for _, m := range invoiceNumberRgx.FindAllStringSubmatch(text, -1) {
+		if strings.ContainsAny(m[1], "0123456789") {
+			res.InvoiceNumber = strings.ToUpper(m[1])
+			break
+		}
+	}
+
+	res.Amount, res.Currency = parseTotal(text)
+	if res.Currency == "" {
+		res.Currency = findCurrency(text)
+	}
+
+	if m := beneficiaryRgx.FindStringSubmatch(text); m != nil {
+		res.BeneficiaryName = strings.Join(strings.Fields(m[1]), " ")
+	}
+
+	for _, m := range dateLineRgx.FindAllStringSubmatch(text, -1) {
+		if d, ok := parseDate(m[1]); ok {
+			res.Date = &d
+			break
+		}
+	}
+
+	return res
+}
+
+// parseTotal returns the amount on the last total line of the invoice, ignoring subtotals.
+func parseTotal(text string) (decimal.Decimal, string) {
+	amount := decimal.Zero
+	currency := ""
+
+	for _, line := range strings.Split(text, "\n") {
+		loc := totalLineRgx.FindStringIndex(line)
+		if loc == nil || subtotalRgx.MatchString(line) {
+			continue
+		}
+
+		for _, m := range moneyRgx.FindAllStringSubmatch(line[loc[1]:], -1) {
+			value, err := decimal.NewFromString(strings.NewReplacer(",", "", " ", "").Replace(m[2]))
+			if err != nil || value.IsZero() {
+				continue
+			}
+
+			amount = value
+			currency = normalizeCurrency(m[1])
+			if currency == "" {
+				currency = normalizeCurrency(m[3])
+			}
+		}
+	}
+
+	return amount, currency
+}
+
+func normalizeCurrency(value string) string {
+	if value == "" {
+		return ""
+	}
+	if code, ok := currencySymbols[value]; ok {
+		return code
+	}
+
+	code := strings.ToUpper(value)
+	if code == "RMB" {
+		return "CNY"
+	}
+	for _, c := range knownCurrencies {
+		if c == code {
+			return code
+		}
+	}
+	return ""
+}
+
+func findCurrency(text string) string {
+	if m := currencyCodeRgx.FindString(strings.ToUpper(text)); m != "" {
+		return normalizeCurrency(m)
+	}
+	if i := strings.IndexAny(text, "$€£¥₹"); i >= 0 {
+		for symbol, code := range currencySymbols {
+			if strings.HasPrefix(text[i:], symbol) {
+				return code
+			}
+		}
+	}
+	return ""
+}
+
+func parseDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		n := len(strings.Fields(layout))
+		fields := strings.Fields(value)
+		if len(fields) < n {
+			continue
+		}
+		candidate := strings.Join(fields[:n], " ")
+		if d, err := time.Parse(layout, candidate); err == nil {
+			return d, true
+		}
+	}
+	return time.Time{}, false
+}
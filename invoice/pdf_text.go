@@ -0,0 +1,306 @@
+package invoice
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	pdfContentType = "application/pdf"
+
+	// maxPDFSize caps how much of an upload is read into memory for text extraction.
+	maxPDFSize = 20 << 20
+
+	// maxPDFContentSize caps the decoded size of all the streams of a document together, so
+	// many small compressed streams can't inflate into more memory than one large one.
+	maxPDFContentSize = 40 << 20
+
+	// tjSpaceThreshold is the TJ kerning adjustment, in thousandths of an em, treated as a word gap.
+	tjSpaceThreshold = -200
+)
+
+// ErrPDFTooLarge is returned for documents whose streams decode to more than maxPDFContentSize
+var ErrPDFTooLarge = errors.New("pdf content is too large")
+
+var (
+	pdfStreamRgx = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+	pdfNumberRgx = regexp.MustCompile(`^-?[0-9]*\.?[0-9]+$`)
+)
+
+// PDFTextExtractor reads invoice metadata from the text layer of a PDF. Scanned PDFs without
+// a text layer yield an empty result and need an OCR extractor.
+type PDFTextExtractor struct{}
+
+// NewPDFTextExtractor creates a new PDF text layer extractor
+func NewPDFTextExtractor() *PDFTextExtractor {
+	return &PDFTextExtractor{}
+}
+
+func (e *PDFTextExtractor) Name() string {
+	return "pdf_text"
+}
+
+func (e *PDFTextExtractor) Supports(contentType string) bool {
+	return strings.HasPrefix(strings.ToLower(contentType), pdfContentType)
+}
+
+func (e *PDFTextExtractor) Extract(ctx context.Context, file io.Reader) (*Extracted, error) {
+	data, err := io.ReadAll(io.LimitReader(file, maxPDFSize))
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := PDFText(data)
+	if err != nil {
+		return nil, err
+	}
+
+	res := ParseText(text)
+	res.Extractor = e.Name()
+	return res, nil
+}
+
+// PDFText returns the text shown by the content streams of a PDF document. Only uncompressed
+// and FlateDecode streams are read; other streams, such as embedded images, are skipped.
+func PDFText(data []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("%PDF")) {
+		return "", ErrUnsupportedContentType
+	}
+
+	remaining := int64(maxPDFContentSize)
+
+	var out strings.Builder
+	for _, loc := range pdfStreamRgx.FindAllSubmatchIndex(data, -1) {
+		dict := string(data[loc[2]:loc[3]])
+		start := loc[1]
+
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		raw := data[start : start+end]
+
+		content, ok := decodePDFStream(dict, raw, remaining)
+		if !ok {
+			continue
+		}
+		if int64(len(content)) > remaining {
+			return "", ErrPDFTooLarge
+		}
+		remaining -= int64(len(content))
+
+		out.WriteString(contentStreamText(content))
+	}
+
+	return out.String(), nil
+}
+
+// decodePDFStream decodes a stream. Compressed streams are read up to maxPDFSize, or one byte
+// past limit when that is smaller, so callers can tell when limit is exceeded.
+func decodePDFStream(dict string, raw []byte, limit int64) ([]byte, bool) {
+	if strings.Contains(dict, "/Subtype/Image") || strings.Contains(dict, "/Subtype /Image") {
+		return nil, false
+	}
+
+	if !strings.Contains(dict, "/Filter") {
+		return raw, true
+	}
+
+	if !strings.Contains(dict, "/FlateDecode") || strings.Count(dict, "Decode") > 1 {
+		return nil, false
+	}
+
+	r, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, false
+	}
+	defer r.Close()
+
+	// streams are often padded after the compressed data, so a partial read is still useful
+	size := int64(maxPDFSize)
+	if limit < size {
+		size = limit + 1
+	}
+	decoded, err := io.ReadAll(io.LimitReader(r, size))
+	if err != nil && len(decoded) == 0 {
+		return nil, false
+	}
+
+	return decoded, true
+}
+
+// contentStreamText walks a content stream and collects the strings passed to the text
+// showing operators, starting a new line on text positioning operators.
+func contentStreamText(content []byte) string {
+	var out strings.Builder
+	var operands []string
+	inText := false
+
+	newline := func() {
+		s := out.String()
+		if len(s) > 0 && !strings.HasSuffix(s, "\n") {
+			out.WriteString("\n")
+		}
+	}
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '(':
+			s, next := readPDFLiteral(content, i)
+			operands = append(operands, s)
+			i = next
+		case c == '[':
+			operands = append(operands, "[")
+			i++
+		case c == ']':
+			operands = append(operands, "]")
+			i++
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case isPDFSpace(c):
+			i++
+		default:
+			j := i
+			for j < len(content) && !isPDFSpace(content[j]) && !bytes.ContainsRune([]byte("()[]<>/%"), rune(content[j])) {
+				j++
+			}
+			if j == i {
+				j++
+			}
+			token := string(content[i:j])
+			i = j
+
+			if pdfNumberRgx.MatchString(token) {
+				operands = append(operands, token)
+				continue
+			}
+
+			switch token {
+			case "BT":
+				inText = true
+			case "ET":
+				inText = false
+				newline()
+			case "Td", "TD", "T*", "Tm":
+				if inText {
+					newline()
+				}
+			case "Tj":
+				if inText {
+					out.WriteString(lastString(operands))
+				}
+			case "'", "\"":
+				if inText {
+					newline()
+					out.WriteString(lastString(operands))
+				}
+			case "TJ":
+				if inText {
+					out.WriteString(tjText(operands))
+				}
+			}
+			operands = operands[:0]
+		}
+	}
+
+	newline()
+	return out.String()
+}
+
+func tjText(operands []string) string {
+	var b strings.Builder
+	inArray := false
+	for _, op := range operands {
+		switch {
+		case op == "[":
+			inArray = true
+		case op == "]":
+			inArray = false
+		case !inArray:
+		case strings.HasPrefix(op, "\x00"):
+			b.WriteString(op[1:])
+		default:
+			if n, err := strconv.ParseFloat(op, 64); err == nil && n <= tjSpaceThreshold && !strings.HasSuffix(b.String(), " ") {
+				b.WriteString(" ")
+			}
+		}
+	}
+	return b.String()
+}
+
+func lastString(operands []string) string {
+	for i := len(operands) - 1; i >= 0; i-- {
+		if strings.HasPrefix(operands[i], "\x00") {
+			return operands[i][1:]
+		}
+	}
+	return ""
+}
+
+// readPDFLiteral reads a (string) starting at content[start]. The result is prefixed with a
+// NUL byte so it can be told apart from numeric operands.
+func readPDFLiteral(content []byte, start int) (string, int) {
+	var b strings.Builder
+	b.WriteByte(0)
+
+	depth := 0
+	i := start
+	for i < len(content) {
+		c := content[i]
+		switch {
+		case c == '\\' && i+1 < len(content):
+			i++
+			switch e := content[i]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'b', 'f':
+			case '\r', '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					j := i
+					for j < len(content) && j < i+3 && content[j] >= '0' && content[j] <= '7' {
+						j++
+					}
+					n, _ := strconv.ParseUint(string(content[i:j]), 8, 8)
+					b.WriteByte(byte(n))
+					i = j - 1
+				} else {
+					b.WriteByte(e)
+				}
+			}
+		case c == '(':
+			if depth > 0 {
+				b.WriteByte(c)
+			}
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return b.String(), i + 1
+			}
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+		i++
+	}
+
+	return b.String(), i
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
@@ -0,0 +1,19 @@
+ALTER TABLE form_submission_files
+    DROP COLUMN IF EXISTS scanned_at,
+    DROP COLUMN IF EXISTS scan_signature,
+    DROP COLUMN IF EXISTS scan_status;
+
+ALTER TABLE form_step_progress
+    DROP COLUMN IF EXISTS revision;
+
+ALTER TABLE form_submissions
+    DROP COLUMN IF EXISTS revision,
+    DROP COLUMN IF EXISTS form_version;
+
+ALTER TABLE form_steps
+    DROP COLUMN IF EXISTS validation_rules,
+    DROP COLUMN IF EXISTS version;
+
+ALTER TABLE form_fields
+    DROP COLUMN IF EXISTS prefill,
+    DROP COLUMN IF EXISTS version;
@@ -0,0 +1,21 @@
+-- Columns read and written by the form queries in query/form.sql
+
+ALTER TABLE form_fields
+    ADD COLUMN version INT NOT NULL DEFAULT 1,
+    ADD COLUMN prefill JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE form_steps
+    ADD COLUMN version INT NOT NULL DEFAULT 1,
+    ADD COLUMN validation_rules JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE form_submissions
+    ADD COLUMN form_version INT NOT NULL DEFAULT 1,
+    ADD COLUMN revision INT NOT NULL DEFAULT 1;
+
+ALTER TABLE form_step_progress
+    ADD COLUMN revision INT NOT NULL DEFAULT 1;
+
+ALTER TABLE form_submission_files
+    ADD COLUMN scan_status VARCHAR(20) NOT NULL DEFAULT 'not_scanned',
+    ADD COLUMN scan_signature VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN scanned_at TIMESTAMPTZ;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS business_owners_business_id_id_number_key;
+DROP INDEX IF EXISTS businesses_created_by_registration_number_key;
+DROP INDEX IF EXISTS businesses_created_by_key;
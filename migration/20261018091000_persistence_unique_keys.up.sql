@@ -0,0 +1,11 @@
+-- Unique indexes backing the UniqueKeys of db.PersistenceTables, which form submissions
+-- upsert persisted rows on with ON CONFLICT
+
+CREATE UNIQUE INDEX IF NOT EXISTS businesses_created_by_key
+    ON businesses (created_by);
+
+CREATE UNIQUE INDEX IF NOT EXISTS businesses_created_by_registration_number_key
+    ON businesses (created_by, registration_number);
+
+CREATE UNIQUE INDEX IF NOT EXISTS business_owners_business_id_id_number_key
+    ON business_owners (business_id, id_number);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS transactions_ext_transfer_recipient_idx;
+DROP TABLE IF EXISTS recipient_book_members;
+DROP TABLE IF EXISTS recipient_profiles;
@@ -0,0 +1,31 @@
+-- Tables of the recipient address book, and the index db.GetRecipientBook and
+-- db.GetRecipientUsageStats look up the transfers sent to a recipient with
+
+CREATE TABLE IF NOT EXISTS recipient_profiles (
+    recipient_id UUID PRIMARY KEY REFERENCES recipients (id) ON DELETE CASCADE,
+    nickname VARCHAR(255) NOT NULL DEFAULT '',
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    is_favourite BOOLEAN NOT NULL DEFAULT FALSE,
+    business_id UUID REFERENCES businesses (id) ON DELETE SET NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS recipient_profiles_business_id_idx
+    ON recipient_profiles (business_id)
+    WHERE business_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS recipient_book_members (
+    business_id UUID NOT NULL REFERENCES businesses (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('editor', 'viewer')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (business_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS recipient_book_members_user_id_idx
+    ON recipient_book_members (user_id);
+
+-- The expression and predicate must stay the same as in recipientUsageJoin
+CREATE INDEX IF NOT EXISTS transactions_ext_transfer_recipient_idx
+    ON transactions ((payload->'recipient'->>'id'))
+    WHERE action = 'ext-transfer';
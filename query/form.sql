@@ -0,0 +1,220 @@
+-- name: CreateDynamicOption :one
+INSERT INTO form_dynamic_options (
+    id, name, source_type, source_config, cache_duration
+) VALUES (
+             $1, $2, $3, $4, $5
+         ) RETURNING id, name, source_type, source_config, cache_duration, created_at;
+
+-- name: CreateFormAssignment :one
+INSERT INTO form_assignments (
+    id, form_definition_id, assignment_type, assignment_value,
+    conditions, priority, valid_from, valid_until, created_by
+) VALUES (
+             $1, $2, $3, $4, $5, $6, $7, $8, $9
+         ) RETURNING id, form_definition_id, assignment_type, assignment_value, conditions, priority, valid_from, valid_until, created_at, created_by;
+
+-- name: CreateFormDefinition :one
+INSERT INTO form_definitions (
+    id, name, slug, description, form_type, version, is_active,
+    is_multi_step, requires_approval, approval_workflow, is_editable_after_submission, created_by
+) VALUES (
+             $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+         ) RETURNING id, name, slug, description, form_type, version, is_active, is_multi_step, requires_approval, is_editable_after_submission, approval_workflow, created_at, updated_at, created_by;
+
+-- name: CreateFormEvent :one
+INSERT INTO form_events (
+    id, form_definition_id, event_type, handler_type, handler_config, is_active
+) VALUES (
+             $1, $2, $3, $4, $5, $6
+         ) RETURNING id, form_definition_id, event_type, handler_type, handler_config, is_active, created_at;
+
+-- name: CreateFormField :one
+INSERT INTO form_fields (
+    id, form_definition_id, form_step_id, field_name, field_type,
+    label, placeholder, help_text, validation_rules, options,
+    display_order, is_required, is_readonly, default_value,
+    conditional_logic, file_config, version, prefill
+) VALUES (
+             $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18
+         ) RETURNING id, form_definition_id, form_step_id, field_name, field_type, label, placeholder, help_text, validation_rules, options, display_order, is_required, is_readonly, default_value, conditional_logic, file_config, created_at, updated_at, version, prefill;
+
+-- name: CreateFormStep :one
+INSERT INTO form_steps (
+    id, form_definition_id, step_number, name, description, is_optional, version, validation_rules
+) VALUES (
+             $1, $2, $3, $4, $5, $6, $7, $8
+         ) RETURNING id, form_definition_id, step_number, name, description, is_optional, created_at, version, validation_rules;
+
+-- name: CreateFormSubmission :one
+INSERT INTO form_submissions (
+    id, form_definition_id, user_id, submission_data, status,
+    approval_status, approval_notes, approved_by, approved_at, metadata, form_version
+) VALUES (
+             $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+         ) RETURNING id, form_definition_id, user_id, submission_data, status, approval_status, approval_notes, approved_by, approved_at, metadata, created_at, updated_at, current_step_number, completion_percentage, form_version, revision;
+
+-- name: CreateFormSubmissionFile :one
+INSERT INTO form_submission_files (
+    id, form_submission_id, field_name, file_name, file_path,
+    file_size, mime_type, bucket, storage_provider,
+    scan_status, scan_signature, scanned_at
+) VALUES (
+             $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+         ) RETURNING id, form_submission_id, field_name, file_name, file_path, file_size, mime_type, bucket, storage_provider, uploaded_at, scan_status, scan_signature, scanned_at;
+
+-- name: CreatePersistenceConfig :one
+INSERT INTO form_persistence_configs (
+    id, form_definition_id, persistence_mode, target_configs,
+    field_mappings, transformation_rules, validation_hooks
+) VALUES (
+             $1, $2, $3, $4, $5, $6, $7
+         ) RETURNING id, form_definition_id, persistence_mode, target_configs, field_mappings, transformation_rules, validation_hooks, created_at, updated_at;
+
+-- name: CreateStepProgress :one
+INSERT INTO form_step_progress (
+    id, form_submission_id, form_step_id, step_number, status, data
+) VALUES (
+             $1, $2, $3, $4, $5, $6
+         ) RETURNING id, form_submission_id, form_step_id, step_number, status, completed_at, data, created_at, updated_at, revision;
+
+-- name: DeleteFormSubmissionFile :exec
+DELETE FROM form_submission_files WHERE id = $1;
+
+-- name: GetAllStepProgress :many
+SELECT id, form_submission_id, form_step_id, step_number, status, completed_at, data, created_at, updated_at, revision FROM form_step_progress
+WHERE form_submission_id = $1
+ORDER BY step_number;
+
+-- name: GetDynamicOption :one
+SELECT id, name, source_type, source_config, cache_duration, created_at FROM form_dynamic_options WHERE id = $1;
+
+-- name: GetDynamicOptionByName :one
+SELECT id, name, source_type, source_config, cache_duration, created_at FROM form_dynamic_options WHERE name = $1;
+
+-- name: GetFormAssignments :many
+SELECT fa.id, fa.form_definition_id, fa.assignment_type, fa.assignment_value, fa.conditions, fa.priority, fa.valid_from, fa.valid_until, fa.created_at, fa.created_by, fd.form_type
+FROM form_assignments fa
+         JOIN form_definitions fd ON fd.id = fa.form_definition_id
+WHERE fd.is_active = true
+  AND (fa.valid_from IS NULL OR fa.valid_from <= CURRENT_TIMESTAMP)
+  AND (fa.valid_until IS NULL OR fa.valid_until > CURRENT_TIMESTAMP)
+  AND (
+    (fa.assignment_type = 'user_id' AND fa.assignment_value = $1) OR
+    (fa.assignment_type = 'user_type' AND fa.assignment_value = $2) OR
+    (fa.assignment_type = 'country' AND fa.assignment_value = $3) OR
+    (fa.assignment_type = 'state' AND fa.assignment_value = $4) OR
+    fa.assignment_type = 'custom'
+    )
+ORDER BY fa.priority DESC;
+
+-- name: GetFormDefinition :one
+SELECT id, name, slug, description, form_type, version, is_active, is_multi_step, requires_approval, is_editable_after_submission, approval_workflow, created_at, updated_at, created_by FROM form_definitions WHERE id = $1;
+
+-- name: GetFormDefinitionBySlug :one
+SELECT id, name, slug, description, form_type, version, is_active, is_multi_step, requires_approval, is_editable_after_submission, approval_workflow, created_at, updated_at, created_by FROM form_definitions WHERE slug = $1 AND is_active = true;
+
+-- name: GetFormEvents :many
+SELECT id, form_definition_id, event_type, handler_type, handler_config, is_active, created_at FROM form_events
+WHERE form_definition_id = $1
+  AND event_type = $2
+  AND is_active = true;
+
+-- name: GetFormFields :many
+SELECT id, form_definition_id, form_step_id, field_name, field_type, label, placeholder, help_text, validation_rules, options, display_order, is_required, is_readonly, default_value, conditional_logic, file_config, created_at, updated_at, version, prefill FROM form_fields
+WHERE form_definition_id = $1
+  AND version = (SELECT fd.version FROM form_definitions fd WHERE fd.id = $1)
+ORDER BY display_order;
+
+-- name: GetFormFieldsByStep :many
+SELECT id, form_definition_id, form_step_id, field_name, field_type, label, placeholder, help_text, validation_rules, options, display_order, is_required, is_readonly, default_value, conditional_logic, file_config, created_at, updated_at, version, prefill FROM form_fields
+WHERE form_step_id = $1
+ORDER BY display_order;
+
+-- name: GetFormSteps :many
+SELECT id, form_definition_id, step_number, name, description, is_optional, created_at, version, validation_rules FROM form_steps
+WHERE form_definition_id = $1
+  AND version = (SELECT fd.version FROM form_definitions fd WHERE fd.id = $1)
+ORDER BY step_number;
+
+-- name: GetFormSubmission :one
+SELECT id, form_definition_id, user_id, submission_data, status, approval_status, approval_notes, approved_by, approved_at, metadata, created_at, updated_at, current_step_number, completion_percentage, form_version, revision FROM form_submissions WHERE id = $1;
+
+-- name: GetFormSubmissionByUserAndForm :one
+SELECT id, form_definition_id, user_id, submission_data, status, approval_status, approval_notes, approved_by, approved_at, metadata, created_at, updated_at, current_step_number, completion_percentage, form_version, revision FROM form_submissions
+WHERE user_id = $1 AND form_definition_id = $2 AND status = $3
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: GetFormSubmissionFiles :many
+SELECT id, form_submission_id, field_name, file_name, file_path, file_size, mime_type, bucket, storage_provider, uploaded_at, scan_status, scan_signature, scanned_at FROM form_submission_files
+WHERE form_submission_id = $1
+ORDER BY uploaded_at;
+
+-- name: GetPersistenceConfig :one
+SELECT id, form_definition_id, persistence_mode, target_configs, field_mappings, transformation_rules, validation_hooks, created_at, updated_at FROM form_persistence_configs
+WHERE form_definition_id = $1;
+
+-- name: GetStepProgress :one
+SELECT id, form_submission_id, form_step_id, step_number, status, completed_at, data, created_at, updated_at, revision FROM form_step_progress
+WHERE form_submission_id = $1 AND form_step_id = $2;
+
+-- name: GetStepProgressBySubmissionAndNumber :one
+SELECT id, form_submission_id, form_step_id, step_number, status, completed_at, data, created_at, updated_at, revision FROM form_step_progress
+WHERE form_submission_id = $1 AND step_number = $2;
+
+-- name: ListFormDefinitions :many
+SELECT id, name, slug, description, form_type, version, is_active, is_multi_step, requires_approval, is_editable_after_submission, approval_workflow, created_at, updated_at, created_by FROM form_definitions
+WHERE ($1::varchar IS NULL OR form_type = $1)
+  AND ($2::boolean IS NULL OR is_active = $2)
+ORDER BY created_at DESC
+LIMIT $3 OFFSET $4;
+
+-- name: ListFormSubmissions :many
+SELECT id, form_definition_id, user_id, submission_data, status, approval_status, approval_notes, approved_by, approved_at, metadata, created_at, updated_at, current_step_number, completion_percentage, form_version, revision FROM form_submissions
+WHERE ($1::uuid IS NULL OR user_id = $1)
+  AND ($2::uuid IS NULL OR form_definition_id = $2)
+  AND ($3::varchar IS NULL OR status = $3)
+ORDER BY created_at DESC
+LIMIT $4 OFFSET $5;
+
+-- name: UpdateFormDefinition :one
+UPDATE form_definitions SET
+                            name = $2, description = $3, is_active = $4,
+                            approval_workflow = $5, is_editable_after_submission = $6, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING id, name, slug, description, form_type, version, is_active, is_multi_step, requires_approval, is_editable_after_submission, approval_workflow, created_at, updated_at, created_by;
+
+-- name: UpdateFormSubmission :one
+UPDATE form_submissions SET
+                            submission_data = $2, status = $3, approval_status = $4,
+                            approval_notes = $5, approved_by = $6, approved_at = $7,
+                            metadata = $8, updated_at = CURRENT_TIMESTAMP,
+                            revision = revision + 1
+WHERE id = $1
+RETURNING id, form_definition_id, user_id, submission_data, status, approval_status, approval_notes, approved_by, approved_at, metadata, created_at, updated_at, current_step_number, completion_percentage, form_version, revision;
+
+-- name: UpdatePersistenceConfig :one
+UPDATE form_persistence_configs SET
+                                    persistence_mode = $2, target_configs = $3, field_mappings = $4,
+                                    transformation_rules = $5, validation_hooks = $6,
+                                    updated_at = CURRENT_TIMESTAMP
+WHERE form_definition_id = $1
+RETURNING id, form_definition_id, persistence_mode, target_configs, field_mappings, transformation_rules, validation_hooks, created_at, updated_at;
+
+-- name: UpdateStepProgress :one
+UPDATE form_step_progress SET
+                              status = $2,
+                              data = $3,
+                              completed_at = CASE WHEN $2 = 'completed' THEN CURRENT_TIMESTAMP ELSE completed_at END,
+                              updated_at = CURRENT_TIMESTAMP,
+                              revision = revision + 1
+WHERE id = $1
+RETURNING id, form_submission_id, form_step_id, step_number, status, completed_at, data, created_at, updated_at, revision;
+
+-- name: UpdateSubmissionProgress :one
+UPDATE form_submissions SET
+                            current_step_number = $2,
+                            completion_percentage = $3,
+                            updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING id, form_definition_id, user_id, submission_data, status, approval_status, approval_notes, approved_by, approved_at, metadata, created_at, updated_at, current_step_number, completion_percentage, form_version, revision;
@@ -0,0 +1,177 @@
+package schemes
+
+import (
+	"encoding/json"
+	"strings"
+
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// ComplianceInfo is the structured regulatory data captured on an external transfer.
+type ComplianceInfo struct {
+	PurposeCode   string `json:"purpose_code" form:"purpose_code"`
+	SourceOfFunds string `json:"source_of_funds" form:"source_of_funds"`
+	Relationship  string `json:"relationship" form:"relationship"`
+}
+
+// IsEmpty returns true if none of the compliance fields were provided.
+func (c ComplianceInfo) IsEmpty() bool {
+	return c.PurposeCode == "" && c.SourceOfFunds == "" && c.Relationship == ""
+}
+
+// CorridorRequirement describes the compliance data a payout corridor expects.
+type CorridorRequirement struct {
+	Corridor             string            `json:"corridor"`
+	PurposeCodes         map[string]string `json:"purpose_codes"`
+	RequireSourceOfFunds bool              `json:"require_source_of_funds"`
+	RequireRelationship  bool              `json:"require_relationship"`
+}
+
+var (
+	SourcesOfFunds = []string{
+		"salary",
+		"business_income",
+		"savings",
+		"investment",
+		"loan",
+		"gift",
+		"inheritance",
+		"sale_of_property",
+		"other",
+	}
+
+	Relationships = []string{
+		"self",
+		"family",
+		"friend",
+		"employee",
+		"employer",
+		"supplier",
+		"customer",
+		"business_partner",
+		"other",
+	}
+
+	cnyPurposeCodes = map[string]string{
+		"GOODS_TRADE":     "Payment for goods",
+		"SERVICE_TRADE":   "Payment for services",
+		"FREIGHT":         "Freight and logistics",
+		"SALARY":          "Salary and wages",
+		"FAMILY_SUPPORT":  "Family support",
+		"EDUCATION":       "Tuition and education fees",
+		"MEDICAL":         "Medical expenses",
+		"TRAVEL":          "Travel and accommodation",
+		"CAPITAL_ACCOUNT": "Capital account transfer",
+	}
+
+	inrPurposeCodes = map[string]string{
+		"P0001": "Repatriation of Indian portfolio investment abroad in equity capital",
+		"P0103": "Advance receipts against export contracts",
+		"P0802": "Software services",
+		"P0806": "Business and management consultancy services",
+		"P1301": "Inward remittance from non-residents towards family maintenance and savings",
+		"P1302": "Personal gifts and donations",
+		"P1303": "Donations to religious and charitable institutions",
+	}
+
+	// corridorRequirements is keyed by corridor, see CorridorRequirementFor
+	corridorRequirements = map[string]CorridorRequirement{
+		"CNY": {
+			Corridor:             "CNY",
+			PurposeCodes:         cnyPurposeCodes,
+			RequireSourceOfFunds: true,
+			RequireRelationship:  true,
+		},
+		"INR": {
+			Corridor:             "INR",
+			PurposeCodes:         inrPurposeCodes,
+			RequireSourceOfFunds: true,
+			RequireRelationship:  false,
+		},
+	}
+
+	// schemeCorridors maps local payout schemes to the corridor they pay out in
+	schemeCorridors = map[string]string{
+		CNYLabelUnionPay: "CNY",
+		CNYLabelAliPay:   "CNY",
+	}
+
+	// countryCorridors maps destination countries to the corridor of SWIFT transfers sent there
+	countryCorridors = map[string]string{
+		"CN":    "CNY",
+		"CHINA": "CNY",
+		"IN":    "INR",
+		"INDIA": "INR",
+	}
+)
+
+// CorridorRequirementFor returns the compliance requirement of the corridor a transfer is sent
+// through with the payout scheme. Local schemes pay out in a single corridor; SWIFT transfers go
+// through the corridor of the destination country. Other schemes are not regulated.
+func CorridorRequirementFor(scheme, country string) (CorridorRequirement, bool) {
+	scheme = strings.ToUpper(strings.TrimSpace(scheme))
+
+	corridor, ok := schemeCorridors[scheme]
+	if !ok && scheme == SwiftLabel {
+		corridor, ok = countryCorridors[strings.ToUpper(strings.TrimSpace(country))]
+	}
+	if !ok {
+		return CorridorRequirement{}, false
+	}
+
+	req, ok := corridorRequirements[corridor]
+	return req, ok
+}
+
+// ValidateCompliance checks info against the corridor of the transfer's scheme. Transfers
+// outside a regulated corridor may omit the fields, but provided values must still be known.
+func ValidateCompliance(v *validator.Validator, scheme, country string, info ComplianceInfo) {
+	req, regulated := CorridorRequirementFor(scheme, country)
+
+	if regulated {
+		v.Check(info.PurposeCode != "", "purpose_code", "must be provided for this corridor")
+		if info.PurposeCode != "" {
+			_, ok := req.PurposeCodes[strings.ToUpper(info.PurposeCode)]
+			v.Check(ok, "purpose_code", "invalid purpose code for this corridor")
+		}
+		v.Check(!req.RequireSourceOfFunds || info.SourceOfFunds != "", "source_of_funds", "must be provided for this corridor")
+		v.Check(!req.RequireRelationship || info.Relationship != "", "relationship", "must be provided for this corridor")
+	} else {
+		v.Check(len(info.PurposeCode) <= 50, "purpose_code", "must not be more than 50 characters")
+	}
+
+	if info.SourceOfFunds != "" {
+		v.Check(validator.In(info.SourceOfFunds, SourcesOfFunds...), "source_of_funds", "invalid source of funds")
+	}
+
+	if info.Relationship != "" {
+		v.Check(validator.In(info.Relationship, Relationships...), "relationship", "invalid relationship to beneficiary")
+	}
+}
+
+// ValidateTransferCompliance checks the compliance data of a transfer against the corridor its
+// recipient is paid out through.
+func ValidateTransferCompliance(v *validator.Validator, recipient *db.Recipient, info ComplianceInfo) {
+	ValidateCompliance(v, recipient.Scheme, RecipientCountry(recipient.Data), info)
+}
+
+// Normalize returns info with the purpose code upper-cased and all values trimmed.
+func (c ComplianceInfo) Normalize() ComplianceInfo {
+	return ComplianceInfo{
+		PurposeCode:   strings.ToUpper(strings.TrimSpace(c.PurposeCode)),
+		SourceOfFunds: strings.ToLower(strings.TrimSpace(c.SourceOfFunds)),
+		Relationship:  strings.ToLower(strings.TrimSpace(c.Relationship)),
+	}
+}
+
+// RecipientCountry returns the destination country stored in a recipient's scheme data.
+func RecipientCountry(data json.RawMessage) string {
+	var values struct {
+		Country string `json:"countries"`
+	}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return ""
+	}
+	return values.Country
+}
@@ -0,0 +1,91 @@
+package schemes
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+func TestCorridorRequirementFor(t *testing.T) {
+	req, regulated := CorridorRequirementFor(" cny_unionpay ", "")
+	require.True(t, regulated)
+	require.Equal(t, "CNY", req.Corridor)
+
+	req, regulated = CorridorRequirementFor(CNYLabelAliPay, "US")
+	require.True(t, regulated)
+	require.Equal(t, "CNY", req.Corridor)
+
+	// SWIFT transfers go through the corridor of the destination country
+	req, regulated = CorridorRequirementFor(SwiftLabel, "india")
+	require.True(t, regulated)
+	require.Equal(t, "INR", req.Corridor)
+	require.False(t, req.RequireRelationship)
+
+	_, regulated = CorridorRequirementFor(SwiftLabel, "US")
+	require.False(t, regulated)
+
+	// The destination country only matters for SWIFT
+	_, regulated = CorridorRequirementFor("NGN_NIP", "CN")
+	require.False(t, regulated)
+}
+
+func TestValidateTransferCompliance(t *testing.T) {
+	unionPay := &db.Recipient{Scheme: CNYLabelUnionPay, Data: json.RawMessage(`{}`)}
+	swiftToIndia := &db.Recipient{Scheme: SwiftLabel, Data: json.RawMessage(`{"countries":"IN"}`)}
+	swiftToUS := &db.Recipient{Scheme: SwiftLabel, Data: json.RawMessage(`{"countries":"US"}`)}
+
+	tests := []struct {
+		name      string
+		recipient *db.Recipient
+		info      ComplianceInfo
+		errors    []string
+	}{
+		{
+			name:      "regulated corridor without compliance data",
+			recipient: unionPay,
+			errors:    []string{"purpose_code", "source_of_funds", "relationship"},
+		},
+		{
+			name:      "regulated corridor with compliance data",
+			recipient: unionPay,
+			info:      ComplianceInfo{PurposeCode: "goods_trade", SourceOfFunds: "business_income", Relationship: "supplier"}.Normalize(),
+		},
+		{
+			name:      "purpose code of another corridor",
+			recipient: swiftToIndia,
+			info:      ComplianceInfo{PurposeCode: "GOODS_TRADE", SourceOfFunds: "salary"},
+			errors:    []string{"purpose_code"},
+		},
+		{
+			name:      "corridor not needing a relationship",
+			recipient: swiftToIndia,
+			info:      ComplianceInfo{PurposeCode: "P1301", SourceOfFunds: "salary"},
+		},
+		{
+			name:      "unregulated corridor",
+			recipient: swiftToUS,
+		},
+		{
+			name:      "unregulated corridor with unknown values",
+			recipient: swiftToUS,
+			info:      ComplianceInfo{SourceOfFunds: "lottery", Relationship: "stranger"},
+			errors:    []string{"source_of_funds", "relationship"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateTransferCompliance(v, tt.recipient, tt.info)
+
+			fields := make([]string, 0, len(v.Errors))
+			for field := range v.Errors {
+				fields = append(fields, field)
+			}
+			require.ElementsMatch(t, tt.errors, fields)
+		})
+	}
+}
@@ -1,12 +1,15 @@
 package schemes
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/docker/distribution/context"
 	db "github.com/timchuks/monieverse/internal/db/sqlc"
 	"github.com/timchuks/monieverse/internal/domain"
 	"github.com/timchuks/monieverse/internal/fields"
+	"github.com/timchuks/monieverse/internal/validator"
 )
 
 const (
@@ -35,17 +38,24 @@ const (
 	savingAccountType = "saving"
 )
 
+// ruleField defines a text field whose values are checked with a validator rule
+func ruleField(name, label, scope, rule string) fields.Field {
+	field := fields.NewTextField(name, label, scope)
+	field.Rule = rule
+	return field
+}
+
 var (
 	businessName     = fields.NewTextField("business_name", "Business name / organization", fieldScopeBusiness)
-	achRoutingNumber = fields.NewTextField("ach_routing_number", "ACH Routing Number", fieldScopeFull)
+	achRoutingNumber = ruleField("ach_routing_number", "ACH Routing Number", fieldScopeFull, validator.RuleABARoutingNumber)
 
 	accountNumber = fields.NewTextField("account_number", "Account number", fieldScopeBusiness)
 
 	accountType = fields.NewDropdownField("account_type", "Account type", fieldScopeFull, fmt.Sprintf(`["%s","%s"]`, checkingAccountType, savingAccountType))
 
-	swiftCode = fields.NewTextField("swift_code", "SWIFT Code or BIC", fieldScopeFull)
+	swiftCode = ruleField("swift_code", "SWIFT Code or BIC", fieldScopeFull, validator.RuleBIC)
 
-	iban = fields.NewTextField("iban", "IBAN", fieldScopeFull)
+	iban = ruleField("iban", "IBAN", fieldScopeFull, validator.RuleIBAN)
 
 	accountHolderFullname = fields.NewTextField("account_holder_fullname", "Full name of the account holder", fieldScopeFull)
 
@@ -63,15 +73,17 @@ var (
 
 	state = fields.NewTextField("state", "State", fieldScopeFull)
 
-	wireRoutingNumber = fields.NewTextField("wire_routing_number", "Fedwire routing number", fieldScopeBusiness)
+	wireRoutingNumber = ruleField("wire_routing_number", "Fedwire routing number", fieldScopeBusiness, validator.RuleABARoutingNumber)
+
+	ukSortCode = ruleField("uk_sort_code", "UK Sort Code", fieldScopeFull, validator.RuleUKSortCode)
 
-	ukSortCode = fields.NewTextField("uk_sort_code", "UK Sort Code", fieldScopeFull)
+	institutionNumber = ruleField("institution_number", "Institution Number", fieldScopeFull, validator.RuleCAInstitution)
 
-	institutionNumber = fields.NewTextField("institution_number", "Institution Number", fieldScopeFull)
+	transitNumber = ruleField("transit_number", "Transit Number", fieldScopeFull, validator.RuleCATransit)
 
-	transitNumber = fields.NewTextField("transit_number", "Transit Number", fieldScopeFull)
+	cardNumber = ruleField("card_number", "Card Number", fieldScopeFull, validator.RuleUnionPayCard)
 
-	cardNumber = fields.NewTextField("card_number", "Card Number", fieldScopeFull)
+	cnapsCode = ruleField("cnaps_code", "CNAPS Code", fieldScopeFull, validator.RuleCNAPS)
 
 	aliPayID = fields.NewTextField("ali_pay_id", "AliPay ID", fieldScopeFull)
 
@@ -156,6 +168,7 @@ var (
 	CNYUNIONPAY = []fields.Field{
 		accountHolderFullname,
 		cardNumber,
+		cnapsCode,
 
 		countries,
 		city,
@@ -212,3 +225,29 @@ func GetSchemes(store db.Store) (map[string][]fields.Field, error) {
 	paymentSchemes[NGNLabel] = ngnFields
 	return paymentSchemes, nil
 }
+
+// ValidateRecipientData runs the validator rules of the scheme's fields on their values in data.
+func ValidateRecipientData(v *validator.Validator, scheme string, data json.RawMessage) {
+	schemeFields, ok := paymentSchemes[strings.ToUpper(scheme)]
+	if !ok {
+		return
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return
+	}
+
+	for _, field := range schemeFields {
+		if field.Rule == "" {
+			continue
+		}
+
+		str, ok := values[field.Name].(string)
+		if !ok || !validator.NotBlank(str) {
+			continue
+		}
+
+		v.CheckRule(field.Rule, field.Name, str)
+	}
+}
@@ -0,0 +1,28 @@
+package schemes
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+func TestValidateRecipientData(t *testing.T) {
+	validate := func(scheme, data string) validator.ErrorFields {
+		v := validator.New()
+		ValidateRecipientData(v, scheme, json.RawMessage(data))
+		return v.Errors
+	}
+
+	require.Empty(t, validate("cny_unionpay", `{"cnaps_code":"102100099996"}`))
+	require.Contains(t, validate(CNYLabelUnionPay, `{"cnaps_code":"1021"}`), "cnaps_code")
+
+	// Rules come from the fields of the scheme, not from the name of any field in the data
+	require.Contains(t, validate(IbanLabel, `{"iban":"GB00"}`), "iban")
+	require.Empty(t, validate(AchLabel, `{"iban":"GB00"}`))
+	require.Empty(t, validate("UNKNOWN", `{"cnaps_code":"1021"}`))
+
+	// Blank values are left to the required checks
+	require.Empty(t, validate(CNYLabelUnionPay, `{"cnaps_code":" "}`))
+}
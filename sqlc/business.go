@@ -79,3 +79,43 @@ func (q *Queries) PaginatedBusinesses(ctx context.Context, filter BusinessListFi
 
 	return items, nil
 }
+
+const getBusinessByRegistrationNumber = `SELECT id, name, registration_number, business_nature, business_category, address1, address2, city, post_code, state, country, website, product_description, registration_date, trading_address, trading_level, primary_contact_type, phone, email, contact_name, created_at, updated_at, created_by, incorporation_region, approval_status, approval_status_updated_at, approval_status_updated_by, approval_status_reason FROM businesses
+WHERE upper(registration_number) = upper($1) LIMIT 1`
+
+// GetBusinessByRegistrationNumber returns the business registered under a number, ignoring case
+func (q *Queries) GetBusinessByRegistrationNumber(ctx context.Context, registrationNumber string) (Business, error) {
+	row := q.db.QueryRowContext(ctx, getBusinessByRegistrationNumber, registrationNumber)
+	var i Business
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.RegistrationNumber,
+		&i.BusinessNature,
+		&i.BusinessCategory,
+		&i.Address1,
+		&i.Address2,
+		&i.City,
+		&i.PostCode,
+		&i.State,
+		&i.Country,
+		&i.Website,
+		&i.ProductDescription,
+		&i.RegistrationDate,
+		&i.TradingAddress,
+		&i.TradingLevel,
+		&i.PrimaryContactType,
+		&i.Phone,
+		&i.Email,
+		&i.ContactName,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.CreatedBy,
+		&i.IncorporationRegion,
+		&i.ApprovalStatus,
+		&i.ApprovalStatusUpdatedAt,
+		&i.ApprovalStatusUpdatedBy,
+		&i.ApprovalStatusReason,
+	)
+	return i, err
+}
@@ -1,6 +1,10 @@
 package db
 
-import "context"
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
 
 type AfterCreateUserFunc func(user User) error
 
@@ -32,3 +36,23 @@ func (store *SQLStore) CreateUserTx(ctx context.Context, arg CreateUserTxParams,
 
 	return result, err
 }
+
+// ActivateUserTx activates a user once they have verified their email. Public form submissions
+// verified with that email before they signed up become the user's.
+func (store *SQLStore) ActivateUserTx(ctx context.Context, userID uuid.UUID) (User, error) {
+	var user User
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		user, err = q.ActivateUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = q.LinkPublicFormSubmissions(ctx, user.ID)
+		return err
+	})
+
+	return user, err
+}
@@ -0,0 +1,512 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	DataRequestTypeExport  = "export"
+	DataRequestTypeErasure = "erasure"
+	// DataRequestTypeClosure closes the user's account, which starts the retention policies
+	// triggered by account closure
+	DataRequestTypeClosure = "closure"
+
+	DataRequestStatusPending   = "pending"
+	DataRequestStatusCompleted = "completed"
+	// DataRequestStatusHeld is an erasure refused because all of the user's data is under a legal hold
+	DataRequestStatusHeld = "held"
+)
+
+var (
+	ErrDataRequestNotFound = errors.New("data request not found")
+	// ErrUserDataHeld is an erasure stopped by a legal hold placed after it started
+	ErrUserDataHeld = errors.New("user data is under a legal hold")
+)
+
+// DataSubjectRequest is a user's request for a copy of their data, for it to be erased, or for
+// their account to be closed.
+// Exports are kept in storage until ExportExpiresAt.
+type DataSubjectRequest struct {
+	ID              uuid.UUID       `json:"id"`
+	UserID          uuid.UUID       `json:"user_id"`
+	Type            string          `json:"type"`
+	Status          string          `json:"status"`
+	RequestedBy     uuid.UUID       `json:"requested_by"`
+	ProcessedBy     uuid.NullUUID   `json:"processed_by"`
+	Result          json.RawMessage `json:"result"`
+	ExportBucket    string          `json:"-"`
+	ExportPath      string          `json:"-"`
+	ExportExpiresAt sql.NullTime    `json:"export_expires_at"`
+	Error           string          `json:"error"`
+	CreatedAt       time.Time       `json:"created_at"`
+	ProcessedAt     sql.NullTime    `json:"processed_at"`
+}
+
+type CreateDataSubjectRequestParams struct {
+	UserID      uuid.UUID `json:"user_id"`
+	Type        string    `json:"type"`
+	RequestedBy uuid.UUID `json:"requested_by"`
+}
+
+type CompleteDataSubjectRequestParams struct {
+	ID              uuid.UUID       `json:"id"`
+	Status          string          `json:"status"`
+	ProcessedBy     uuid.UUID       `json:"processed_by"`
+	Result          json.RawMessage `json:"result"`
+	ExportBucket    string          `json:"export_bucket"`
+	ExportPath      string          `json:"export_path"`
+	ExportExpiresAt time.Time       `json:"export_expires_at"`
+	Error           string          `json:"error"`
+}
+
+type ListDataSubjectRequestsParams struct {
+	UserID uuid.NullUUID `json:"user_id"` // all users when not set
+	Status string        `json:"status"`  // all statuses when empty
+	Limit  int32         `json:"limit"`
+	Offset int32         `json:"offset"`
+}
+
+// UserPersonalData is what the forms, KYC and identity records hold about a user
+type UserPersonalData struct {
+	FormSubmissions       []FormSubmission            `json:"form_submissions"`
+	FormSubmissionFiles   []FormSubmissionFile        `json:"form_submission_files"`
+	PublicFormSubmissions []PublicFormSubmission      `json:"public_form_submissions"`
+	KYCRequirements       []KycRequirementsUser       `json:"kyc_requirements"`
+	KYCResults            []DynamicKycResult          `json:"kyc_results"`
+	Documents             []Document                  `json:"documents"`
+	IdentityDocuments     []UserIdentityDocument      `json:"identity_documents"`
+	IdentityVerifications []IdentityVerificationDatum `json:"identity_verifications"`
+	UserMeta              []UserMetum                 `json:"user_meta"`
+}
+
+// EraseUserDataInput erases the personal data of a user, except the submissions of KeepFormIDs.
+// KeepFormIDs must cover the forms of the user's active legal holds.
+type EraseUserDataInput struct {
+	UserID      uuid.UUID   `json:"user_id"`
+	Email       string      `json:"email"`
+	KeepFormIDs []uuid.UUID `json:"keep_form_ids"`
+}
+
+// EraseUserDataResult counts the records erased
+type EraseUserDataResult struct {
+	FormSubmissions       int64 `json:"form_submissions"`
+	PublicFormSubmissions int64 `json:"public_form_submissions"`
+	KYCRequirements       int64 `json:"kyc_requirements"`
+	Documents             int64 `json:"documents"`
+	IdentityDocuments     int64 `json:"identity_documents"`
+	IdentityVerifications int64 `json:"identity_verifications"`
+	UserMeta              int64 `json:"user_meta"`
+	Businesses            int64 `json:"businesses"`
+	BusinessOwners        int64 `json:"business_owners"`
+	// SharedRecordsKept is set when legal holds kept the records that aren't tied to one form:
+	// KYC results, documents, identity records, user_meta and businesses
+	SharedRecordsKept bool `json:"shared_records_kept"`
+}
+
+const dataSubjectRequestColumns = `id, user_id, type, status, requested_by, processed_by, result, export_bucket, export_path,
+    export_expires_at, error, created_at, processed_at`
+
+func scanDataSubjectRequest(row interface{ Scan(...interface{}) error }) (DataSubjectRequest, error) {
+	var r DataSubjectRequest
+	err := row.Scan(
+		&r.ID,
+		&r.UserID,
+		&r.Type,
+		&r.Status,
+		&r.RequestedBy,
+		&r.ProcessedBy,
+		&r.Result,
+		&r.ExportBucket,
+		&r.ExportPath,
+		&r.ExportExpiresAt,
+		&r.Error,
+		&r.CreatedAt,
+		&r.ProcessedAt,
+	)
+	return r, err
+}
+
+// queryRows runs a query and scans each of its rows with scan
+func queryRows[T any](ctx context.Context, q *Queries, scan func(interface{ Scan(...interface{}) error }) (T, error), query string, args ...interface{}) ([]T, error) {
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []T{}
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// CreateDataSubjectRequest opens a request, or returns the user's pending request of the same
+// type if there is one
+func (q *Queries) CreateDataSubjectRequest(ctx context.Context, arg CreateDataSubjectRequestParams) (DataSubjectRequest, error) {
+	return scanDataSubjectRequest(q.db.QueryRowContext(ctx, `WITH pending AS (
+    SELECT `+dataSubjectRequestColumns+` FROM data_subject_requests
+    WHERE user_id = $2 AND type = $3 AND status = '`+DataRequestStatusPending+`'
+    LIMIT 1
+), created AS (
+    INSERT INTO data_subject_requests (id, user_id, type, status, requested_by, result, export_bucket, export_path, error)
+    SELECT $1, $2, $3, '`+DataRequestStatusPending+`', $4, '{}', '', '', ''
+    WHERE NOT EXISTS (SELECT 1 FROM pending)
+    RETURNING `+dataSubjectRequestColumns+`
+)
+SELECT * FROM created
+UNION ALL
+SELECT * FROM pending`, uuid.New(), arg.UserID, arg.Type, arg.RequestedBy))
+}
+
+func (q *Queries) GetDataSubjectRequest(ctx context.Context, id uuid.UUID) (DataSubjectRequest, error) {
+	r, err := scanDataSubjectRequest(q.db.QueryRowContext(ctx, `SELECT `+dataSubjectRequestColumns+`
+FROM data_subject_requests WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return r, ErrDataRequestNotFound
+	}
+	return r, err
+}
+
+// ListDataSubjectRequests returns requests, oldest pending first and then newest first
+func (q *Queries) ListDataSubjectRequests(ctx context.Context, arg ListDataSubjectRequestsParams) ([]DataSubjectRequest, error) {
+	return queryRows(ctx, q, scanDataSubjectRequest, `SELECT `+dataSubjectRequestColumns+`
+FROM data_subject_requests
+WHERE ($1::uuid IS NULL OR user_id = $1)
+  AND ($2::text = '' OR status = $2::text)
+ORDER BY status = '`+DataRequestStatusPending+`' DESC,
+         CASE WHEN status = '`+DataRequestStatusPending+`' THEN created_at END,
+         created_at DESC
+LIMIT $3 OFFSET $4`, arg.UserID, arg.Status, arg.Limit, arg.Offset)
+}
+
+// CompleteDataSubjectRequest records the outcome of a pending request. Requests that were
+// processed already are left alone and ErrDataRequestNotFound returned.
+func (q *Queries) CompleteDataSubjectRequest(ctx context.Context, arg CompleteDataSubjectRequestParams) (DataSubjectRequest, error) {
+	result := arg.Result
+	if len(result) == 0 {
+		result = json.RawMessage(`{}`)
+	}
+	expiresAt := sql.NullTime{}
+	if !arg.ExportExpiresAt.IsZero() {
+		expiresAt = NewNullTime(arg.ExportExpiresAt)
+	}
+
+	r, err := scanDataSubjectRequest(q.db.QueryRowContext(ctx, `UPDATE data_subject_requests SET
+    status = $2,
+    processed_by = $3,
+    result = $4,
+    export_bucket = $5,
+    export_path = $6,
+    export_expires_at = $7,
+    error = $8,
+    processed_at = now()
+WHERE id = $1 AND status = '`+DataRequestStatusPending+`'
+RETURNING `+dataSubjectRequestColumns,
+		arg.ID,
+		arg.Status,
+		NewNullUUID(arg.ProcessedBy),
+		result,
+		arg.ExportBucket,
+		arg.ExportPath,
+		expiresAt,
+		arg.Error,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return r, ErrDataRequestNotFound
+	}
+	return r, err
+}
+
+// ListExpiredDataExports returns up to limit requests whose exports are still in storage past
+// their expiry
+func (q *Queries) ListExpiredDataExports(ctx context.Context, now time.Time, limit int32) ([]DataSubjectRequest, error) {
+	return queryRows(ctx, q, scanDataSubjectRequest, `SELECT `+dataSubjectRequestColumns+`
+FROM data_subject_requests
+WHERE export_path <> '' AND export_expires_at < $1
+ORDER BY export_expires_at
+LIMIT $2`, now, limit)
+}
+
+// ClearDataSubjectExport forgets the export of a request once it is deleted from storage
+func (q *Queries) ClearDataSubjectExport(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, `UPDATE data_subject_requests SET export_bucket = '', export_path = '' WHERE id = $1`, id)
+	return err
+}
+
+// CloseUserAccount closes a user's account and deactivates it, returning when it was closed.
+// An account closed before keeps its original closing time. users.closed_at is only ever set
+// here, and is what account_closed retention policies count from.
+func (q *Queries) CloseUserAccount(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	var closedAt time.Time
+	err := q.db.QueryRowContext(ctx, `UPDATE users SET closed_at = COALESCE(closed_at, now()), active = false
+WHERE id = $1
+RETURNING closed_at`, userID).Scan(&closedAt)
+	return closedAt, err
+}
+
+func scanFormSubmissionFile(row interface{ Scan(...interface{}) error }) (FormSubmissionFile, error) {
+	var f FormSubmissionFile
+	err := row.Scan(
+		&f.ID,
+		&f.FormSubmissionID,
+		&f.FieldName,
+		&f.FileName,
+		&f.FilePath,
+		&f.FileSize,
+		&f.MimeType,
+		&f.Bucket,
+		&f.StorageProvider,
+		&f.UploadedAt,
+		&f.ScanStatus,
+		&f.ScanSignature,
+		&f.ScannedAt,
+	)
+	return f, err
+}
+
+func scanKycRequirementsUser(row interface{ Scan(...interface{}) error }) (KycRequirementsUser, error) {
+	var k KycRequirementsUser
+	err := row.Scan(&k.ID, &k.KycRequirementID, &k.UserID, &k.Payload, &k.Status, &k.CreatedAt)
+	return k, err
+}
+
+func scanDynamicKycResult(row interface{ Scan(...interface{}) error }) (DynamicKycResult, error) {
+	var r DynamicKycResult
+	err := row.Scan(&r.ID, &r.KycRequirementID, &r.Field, &r.Value, &r.CreatedAt, &r.UpdatedAt)
+	return r, err
+}
+
+func scanDocument(row interface{ Scan(...interface{}) error }) (Document, error) {
+	var d Document
+	err := row.Scan(
+		&d.ID,
+		&d.Model,
+		&d.ModelID,
+		&d.UserID,
+		&d.DocumentType,
+		&d.DocumentNumber,
+		&d.Bucket,
+		&d.DocumentPath,
+		&d.Storage,
+		&d.CreatedAt,
+		&d.UpdatedAt,
+	)
+	return d, err
+}
+
+func scanIdentityVerificationDatum(row interface{ Scan(...interface{}) error }) (IdentityVerificationDatum, error) {
+	var i IdentityVerificationDatum
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Gender,
+		&i.Address,
+		&i.City,
+		&i.HouseNo,
+		&i.LastName,
+		&i.FirstName,
+		&i.DateOfBirth,
+		&i.DocumentType,
+		&i.DocumentNumber,
+		&i.DocumentCountry,
+		&i.DocumentValidFrom,
+		&i.DocumentValidUntil,
+		&i.Provider,
+		&i.Scope,
+	)
+	return i, err
+}
+
+func scanUserMetum(row interface{ Scan(...interface{}) error }) (UserMetum, error) {
+	var m UserMetum
+	err := row.Scan(&m.UserID, &m.Key, &m.Value, &m.Datatype, &m.LastUpdated)
+	return m, err
+}
+
+// GetUserPersonalData gathers the form submissions and their files, the public submissions made
+// with the user's email, and the KYC results, documents, identity verifications and user_meta
+// of a user
+func (q *Queries) GetUserPersonalData(ctx context.Context, userID uuid.UUID, email string) (UserPersonalData, error) {
+	var data UserPersonalData
+	var err error
+
+	if data.FormSubmissions, err = queryFormSubmissions(ctx, q, `SELECT `+formSubmissionColumns+`
+FROM form_submissions s WHERE s.user_id = $1 ORDER BY s.created_at`, userID); err != nil {
+		return data, err
+	}
+	if data.FormSubmissionFiles, err = queryRows(ctx, q, scanFormSubmissionFile, `SELECT f.id, f.form_submission_id, f.field_name,
+    f.file_name, f.file_path, f.file_size, f.mime_type, f.bucket, f.storage_provider, f.uploaded_at, f.scan_status,
+    f.scan_signature, f.scanned_at
+FROM form_submission_files f
+JOIN form_submissions s ON s.id = f.form_submission_id
+WHERE s.user_id = $1
+ORDER BY f.uploaded_at`, userID); err != nil {
+		return data, err
+	}
+	if data.PublicFormSubmissions, err = queryRows(ctx, q, scanPublicFormSubmission, `SELECT `+publicFormSubmissionColumns+`
+FROM public_form_submissions
+WHERE user_id = $1 OR ($2::text <> '' AND lower(email) = lower($2::text))
+ORDER BY created_at`, userID, email); err != nil {
+		return data, err
+	}
+	if data.KYCRequirements, err = queryRows(ctx, q, scanKycRequirementsUser, `SELECT id, kyc_requirement_id, user_id, payload, status, created_at
+FROM kyc_requirements_users WHERE user_id = $1 ORDER BY created_at`, userID); err != nil {
+		return data, err
+	}
+	if data.KYCResults, err = queryRows(ctx, q, scanDynamicKycResult, `SELECT r.id, r.kyc_requirement_id, r.field, r.value, r.created_at, r.updated_at
+FROM dynamic_kyc_results r
+JOIN kyc_requirements_users k ON k.id = r.kyc_requirement_id
+WHERE k.user_id = $1
+ORDER BY r.created_at`, userID); err != nil {
+		return data, err
+	}
+	if data.Documents, err = queryRows(ctx, q, scanDocument, `SELECT id, model, model_id, user_id, document_type, document_number,
+    bucket, document_path, storage, created_at, updated_at
+FROM documents WHERE user_id = $1 ORDER BY created_at`, userID); err != nil {
+		return data, err
+	}
+	if data.IdentityDocuments, err = q.GetUserIdentityDocuments(ctx, userID); err != nil {
+		return data, err
+	}
+	if data.IdentityVerifications, err = queryRows(ctx, q, scanIdentityVerificationDatum, `SELECT id, user_id, created_at, updated_at,
+    gender, address, city, house_no, last_name, first_name, date_of_birth, document_type, document_number,
+    document_country, document_valid_from, document_valid_until, provider, scope
+FROM identity_verification_data WHERE user_id = $1 ORDER BY created_at`, userID); err != nil {
+		return data, err
+	}
+	if data.UserMeta, err = queryRows(ctx, q, scanUserMetum, `SELECT user_id, key, value, datatype, last_updated
+FROM user_meta WHERE user_id = $1 ORDER BY key`, userID); err != nil {
+		return data, err
+	}
+	return data, nil
+}
+
+// EraseUserDataTx deletes the personal data GetUserPersonalData gathers, except the submissions
+// of the forms to keep, and erases the personal columns of the user's businesses and their
+// owners. Records that aren't tied to one form, and so may back a held form, are kept while the
+// user has any legal hold. ErrUserDataHeld is returned when the holds no longer match the forms
+// to keep. Files are the caller's to delete from storage first; the user record itself is kept,
+// as financial records refer to it.
+func (store *SQLStore) EraseUserDataTx(ctx context.Context, input EraseUserDataInput) (EraseUserDataResult, error) {
+	var result EraseUserDataResult
+	keep := pq.Array(input.KeepFormIDs)
+	if input.KeepFormIDs == nil {
+		keep = pq.Array([]uuid.UUID{})
+	}
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		heldForms, heldAll, err := activeLegalHolds(ctx, q, input.UserID)
+		if err != nil {
+			return err
+		}
+		if heldAll {
+			return ErrUserDataHeld
+		}
+		for _, formID := range heldForms {
+			if !containsUUID(input.KeepFormIDs, formID) {
+				return ErrUserDataHeld
+			}
+		}
+
+		rows, err := q.db.QueryContext(ctx, `SELECT id FROM form_submissions
+WHERE user_id = $1 AND NOT (form_definition_id = ANY($2::uuid[]))
+FOR UPDATE`, input.UserID, keep)
+		if err != nil {
+			return err
+		}
+		var submissionIDs []uuid.UUID
+		for rows.Next() {
+			var id uuid.UUID
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			submissionIDs = append(submissionIDs, id)
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if err := deleteFormSubmissions(ctx, q, submissionIDs); err != nil {
+			return err
+		}
+		result.FormSubmissions = int64(len(submissionIDs))
+
+		type deletion struct {
+			count *int64
+			query string
+			args  []interface{}
+		}
+		deletes := []deletion{
+			{&result.PublicFormSubmissions, `DELETE FROM public_form_submissions
+WHERE (user_id = $1 OR ($2::text <> '' AND lower(email) = lower($2::text)))
+  AND NOT (form_definition_id = ANY($3::uuid[]))`, []interface{}{input.UserID, input.Email, keep}},
+		}
+
+		result.SharedRecordsKept = len(heldForms) > 0
+		if !result.SharedRecordsKept {
+			var discarded int64
+			deletes = append(deletes,
+				deletion{&discarded, `DELETE FROM dynamic_kyc_results
+WHERE kyc_requirement_id IN (SELECT id FROM kyc_requirements_users WHERE user_id = $1)`, []interface{}{input.UserID}},
+				deletion{&result.KYCRequirements, `DELETE FROM kyc_requirements_users WHERE user_id = $1`, []interface{}{input.UserID}},
+				deletion{&result.Documents, `DELETE FROM documents WHERE user_id = $1`, []interface{}{input.UserID}},
+				deletion{&result.IdentityDocuments, `DELETE FROM user_identity_documents WHERE user_id = $1`, []interface{}{input.UserID}},
+				deletion{&result.IdentityVerifications, `DELETE FROM identity_verification_data WHERE user_id = $1`, []interface{}{input.UserID}},
+				deletion{&result.UserMeta, `DELETE FROM user_meta WHERE user_id = $1`, []interface{}{input.UserID}},
+			)
+		}
+		for _, d := range deletes {
+			res, err := q.db.ExecContext(ctx, d.query, d.args...)
+			if err != nil {
+				return err
+			}
+			if *d.count, err = res.RowsAffected(); err != nil {
+				return err
+			}
+		}
+
+		if result.SharedRecordsKept {
+			return nil
+		}
+		erased, err := erasePersonalColumns(ctx, q, input.UserID, nil)
+		if err != nil {
+			return err
+		}
+		result.Businesses = erased["businesses"]
+		result.BusinessOwners = erased["business_owners"]
+		return nil
+	})
+	return result, err
+}
+
+func containsUUID(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
@@ -203,10 +203,10 @@ INSERT INTO form_fields (
     id, form_definition_id, form_step_id, field_name, field_type,
     label, placeholder, help_text, validation_rules, options,
     display_order, is_required, is_readonly, default_value,
-    conditional_logic, file_config
+    conditional_logic, file_config, version, prefill
 ) VALUES (
-             $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16
-         ) RETURNING id, form_definition_id, form_step_id, field_name, field_type, label, placeholder, help_text, validation_rules, options, display_order, is_required, is_readonly, default_value, conditional_logic, file_config, created_at, updated_at
+             $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18
+         ) RETURNING id, form_definition_id, form_step_id, field_name, field_type, label, placeholder, help_text, validation_rules, options, display_order, is_required, is_readonly, default_value, conditional_logic, file_config, created_at, updated_at, version, prefill
 `
 
 type CreateFormFieldParams struct {
@@ -226,6 +226,8 @@ type CreateFormFieldParams struct {
 	DefaultValue     string          `json:"default_value"`
 	ConditionalLogic json.RawMessage `json:"conditional_logic"`
 	FileConfig       json.RawMessage `json:"file_config"`
+	Version          int32           `json:"version"`
+	Prefill          json.RawMessage `json:"prefill"`
 }
 
 func (q *Queries) CreateFormField(ctx context.Context, arg CreateFormFieldParams) (FormField, error) {
@@ -246,6 +248,8 @@ func (q *Queries) CreateFormField(ctx context.Context, arg CreateFormFieldParams
 		arg.DefaultValue,
 		arg.ConditionalLogic,
 		arg.FileConfig,
+		arg.Version,
+		arg.Prefill,
 	)
 	var i FormField
 	err := row.Scan(
@@ -267,25 +271,29 @@ func (q *Queries) CreateFormField(ctx context.Context, arg CreateFormFieldParams
 		&i.FileConfig,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Version,
+		&i.Prefill,
 	)
 	return i, err
 }
 
 const createFormStep = `-- name: CreateFormStep :one
 INSERT INTO form_steps (
-    id, form_definition_id, step_number, name, description, is_optional
+    id, form_definition_id, step_number, name, description, is_optional, version, validation_rules
 ) VALUES (
-             $1, $2, $3, $4, $5, $6
-         ) RETURNING id, form_definition_id, step_number, name, description, is_optional, created_at
+             $1, $2, $3, $4, $5, $6, $7, $8
+         ) RETURNING id, form_definition_id, step_number, name, description, is_optional, created_at, version, validation_rules
 `
 
 type CreateFormStepParams struct {
-	ID               uuid.UUID `json:"id"`
-	FormDefinitionID uuid.UUID `json:"form_definition_id"`
-	StepNumber       int32     `json:"step_number"`
-	Name             string    `json:"name"`
-	Description      string    `json:"description"`
-	IsOptional       bool      `json:"is_optional"`
+	ID               uuid.UUID       `json:"id"`
+	FormDefinitionID uuid.UUID       `json:"form_definition_id"`
+	StepNumber       int32           `json:"step_number"`
+	Name             string          `json:"name"`
+	Description      string          `json:"description"`
+	IsOptional       bool            `json:"is_optional"`
+	Version          int32           `json:"version"`
+	ValidationRules  json.RawMessage `json:"validation_rules"`
 }
 
 func (q *Queries) CreateFormStep(ctx context.Context, arg CreateFormStepParams) (FormStep, error) {
@@ -296,6 +304,8 @@ func (q *Queries) CreateFormStep(ctx context.Context, arg CreateFormStepParams)
 		arg.Name,
 		arg.Description,
 		arg.IsOptional,
+		arg.Version,
+		arg.ValidationRules,
 	)
 	var i FormStep
 	err := row.Scan(
@@ -306,6 +316,8 @@ func (q *Queries) CreateFormStep(ctx context.Context, arg CreateFormStepParams)
 		&i.Description,
 		&i.IsOptional,
 		&i.CreatedAt,
+		&i.Version,
+		&i.ValidationRules,
 	)
 	return i, err
 }
@@ -313,10 +325,10 @@ func (q *Queries) CreateFormStep(ctx context.Context, arg CreateFormStepParams)
 const createFormSubmission = `-- name: CreateFormSubmission :one
 INSERT INTO form_submissions (
     id, form_definition_id, user_id, submission_data, status,
-    approval_status, approval_notes, approved_by, approved_at, metadata
+    approval_status, approval_notes, approved_by, approved_at, metadata, form_version
 ) VALUES (
-             $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
-         ) RETURNING id, form_definition_id, user_id, submission_data, status, approval_status, approval_notes, approved_by, approved_at, metadata, created_at, updated_at, current_step_number, completion_percentage
+             $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+         ) RETURNING id, form_definition_id, user_id, submission_data, status, approval_status, approval_notes, approved_by, approved_at, metadata, created_at, updated_at, current_step_number, completion_percentage, form_version, revision
 `
 
 type CreateFormSubmissionParams struct {
@@ -330,6 +342,7 @@ type CreateFormSubmissionParams struct {
 	ApprovedBy       uuid.NullUUID   `json:"approved_by"`
 	ApprovedAt       time.Time       `json:"approved_at"`
 	Metadata         json.RawMessage `json:"metadata"`
+	FormVersion      int32           `json:"form_version"`
 }
 
 func (q *Queries) CreateFormSubmission(ctx context.Context, arg CreateFormSubmissionParams) (FormSubmission, error) {
@@ -344,6 +357,7 @@ func (q *Queries) CreateFormSubmission(ctx context.Context, arg CreateFormSubmis
 		arg.ApprovedBy,
 		arg.ApprovedAt,
 		arg.Metadata,
+		arg.FormVersion,
 	)
 	var i FormSubmission
 	err := row.Scan(
@@ -361,6 +375,8 @@ func (q *Queries) CreateFormSubmission(ctx context.Context, arg CreateFormSubmis
 		&i.UpdatedAt,
 		&i.CurrentStepNumber,
 		&i.CompletionPercentage,
+		&i.FormVersion,
+		&i.Revision,
 	)
 	return i, err
 }
@@ -368,22 +384,26 @@ func (q *Queries) CreateFormSubmission(ctx context.Context, arg CreateFormSubmis
 const createFormSubmissionFile = `-- name: CreateFormSubmissionFile :one
 INSERT INTO form_submission_files (
     id, form_submission_id, field_name, file_name, file_path,
-    file_size, mime_type, bucket, storage_provider
+    file_size, mime_type, bucket, storage_provider,
+    scan_status, scan_signature, scanned_at
 ) VALUES (
-             $1, $2, $3, $4, $5, $6, $7, $8, $9
-         ) RETURNING id, form_submission_id, field_name, file_name, file_path, file_size, mime_type, bucket, storage_provider, uploaded_at
+             $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+         ) RETURNING id, form_submission_id, field_name, file_name, file_path, file_size, mime_type, bucket, storage_provider, uploaded_at, scan_status, scan_signature, scanned_at
 `
 
 type CreateFormSubmissionFileParams struct {
-	ID               uuid.UUID `json:"id"`
-	FormSubmissionID uuid.UUID `json:"form_submission_id"`
-	FieldName        string    `json:"field_name"`
-	FileName         string    `json:"file_name"`
-	FilePath         string    `json:"file_path"`
-	FileSize         int64     `json:"file_size"`
-	MimeType         string    `json:"mime_type"`
-	Bucket           string    `json:"bucket"`
-	StorageProvider  string    `json:"storage_provider"`
+	ID               uuid.UUID    `json:"id"`
+	FormSubmissionID uuid.UUID    `json:"form_submission_id"`
+	FieldName        string       `json:"field_name"`
+	FileName         string       `json:"file_name"`
+	FilePath         string       `json:"file_path"`
+	FileSize         int64        `json:"file_size"`
+	MimeType         string       `json:"mime_type"`
+	Bucket           string       `json:"bucket"`
+	StorageProvider  string       `json:"storage_provider"`
+	ScanStatus       string       `json:"scan_status"`
+	ScanSignature    string       `json:"scan_signature"`
+	ScannedAt        sql.NullTime `json:"scanned_at"`
 }
 
 func (q *Queries) CreateFormSubmissionFile(ctx context.Context, arg CreateFormSubmissionFileParams) (FormSubmissionFile, error) {
@@ -397,6 +417,9 @@ func (q *Queries) CreateFormSubmissionFile(ctx context.Context, arg CreateFormSu
 		arg.MimeType,
 		arg.Bucket,
 		arg.StorageProvider,
+		arg.ScanStatus,
+		arg.ScanSignature,
+		arg.ScannedAt,
 	)
 	var i FormSubmissionFile
 	err := row.Scan(
@@ -410,6 +433,9 @@ func (q *Queries) CreateFormSubmissionFile(ctx context.Context, arg CreateFormSu
 		&i.Bucket,
 		&i.StorageProvider,
 		&i.UploadedAt,
+		&i.ScanStatus,
+		&i.ScanSignature,
+		&i.ScannedAt,
 	)
 	return i, err
 }
@@ -463,7 +489,7 @@ INSERT INTO form_step_progress (
     id, form_submission_id, form_step_id, step_number, status, data
 ) VALUES (
              $1, $2, $3, $4, $5, $6
-         ) RETURNING id, form_submission_id, form_step_id, step_number, status, completed_at, data, created_at, updated_at
+         ) RETURNING id, form_submission_id, form_step_id, step_number, status, completed_at, data, created_at, updated_at, revision
 `
 
 type CreateStepProgressParams struct {
@@ -495,6 +521,7 @@ func (q *Queries) CreateStepProgress(ctx context.Context, arg CreateStepProgress
 		&i.Data,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Revision,
 	)
 	return i, err
 }
@@ -509,7 +536,7 @@ func (q *Queries) DeleteFormSubmissionFile(ctx context.Context, id uuid.UUID) er
 }
 
 const getAllStepProgress = `-- name: GetAllStepProgress :many
-SELECT id, form_submission_id, form_step_id, step_number, status, completed_at, data, created_at, updated_at FROM form_step_progress
+SELECT id, form_submission_id, form_step_id, step_number, status, completed_at, data, created_at, updated_at, revision FROM form_step_progress
 WHERE form_submission_id = $1
 ORDER BY step_number
 `
@@ -533,6 +560,7 @@ func (q *Queries) GetAllStepProgress(ctx context.Context, formSubmissionID uuid.
 			&i.Data,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Revision,
 		); err != nil {
 			return nil, err
 		}
@@ -757,8 +785,9 @@ func (q *Queries) GetFormEvents(ctx context.Context, arg GetFormEventsParams) ([
 }
 
 const getFormFields = `-- name: GetFormFields :many
-SELECT id, form_definition_id, form_step_id, field_name, field_type, label, placeholder, help_text, validation_rules, options, display_order, is_required, is_readonly, default_value, conditional_logic, file_config, created_at, updated_at FROM form_fields
+SELECT id, form_definition_id, form_step_id, field_name, field_type, label, placeholder, help_text, validation_rules, options, display_order, is_required, is_readonly, default_value, conditional_logic, file_config, created_at, updated_at, version, prefill FROM form_fields
 WHERE form_definition_id = $1
+  AND version = (SELECT fd.version FROM form_definitions fd WHERE fd.id = $1)
 ORDER BY display_order
 `
 
@@ -790,6 +819,8 @@ func (q *Queries) GetFormFields(ctx context.Context, formDefinitionID uuid.UUID)
 			&i.FileConfig,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Version,
+			&i.Prefill,
 		); err != nil {
 			return nil, err
 		}
@@ -805,7 +836,7 @@ func (q *Queries) GetFormFields(ctx context.Context, formDefinitionID uuid.UUID)
 }
 
 const getFormFieldsByStep = `-- name: GetFormFieldsByStep :many
-SELECT id, form_definition_id, form_step_id, field_name, field_type, label, placeholder, help_text, validation_rules, options, display_order, is_required, is_readonly, default_value, conditional_logic, file_config, created_at, updated_at FROM form_fields
+SELECT id, form_definition_id, form_step_id, field_name, field_type, label, placeholder, help_text, validation_rules, options, display_order, is_required, is_readonly, default_value, conditional_logic, file_config, created_at, updated_at, version, prefill FROM form_fields
 WHERE form_step_id = $1
 ORDER BY display_order
 `
@@ -838,6 +869,8 @@ func (q *Queries) GetFormFieldsByStep(ctx context.Context, formStepID uuid.UUID)
 			&i.FileConfig,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Version,
+			&i.Prefill,
 		); err != nil {
 			return nil, err
 		}
@@ -853,8 +886,9 @@ func (q *Queries) GetFormFieldsByStep(ctx context.Context, formStepID uuid.UUID)
 }
 
 const getFormSteps = `-- name: GetFormSteps :many
-SELECT id, form_definition_id, step_number, name, description, is_optional, created_at FROM form_steps
+SELECT id, form_definition_id, step_number, name, description, is_optional, created_at, version, validation_rules FROM form_steps
 WHERE form_definition_id = $1
+  AND version = (SELECT fd.version FROM form_definitions fd WHERE fd.id = $1)
 ORDER BY step_number
 `
 
@@ -875,6 +909,8 @@ func (q *Queries) GetFormSteps(ctx context.Context, formDefinitionID uuid.UUID)
 			&i.Description,
 			&i.IsOptional,
 			&i.CreatedAt,
+			&i.Version,
+			&i.ValidationRules,
 		); err != nil {
 			return nil, err
 		}
@@ -890,7 +926,7 @@ func (q *Queries) GetFormSteps(ctx context.Context, formDefinitionID uuid.UUID)
 }
 
 const getFormSubmission = `-- name: GetFormSubmission :one
-SELECT id, form_definition_id, user_id, submission_data, status, approval_status, approval_notes, approved_by, approved_at, metadata, created_at, updated_at, current_step_number, completion_percentage FROM form_submissions WHERE id = $1
+SELECT id, form_definition_id, user_id, submission_data, status, approval_status, approval_notes, approved_by, approved_at, metadata, created_at, updated_at, current_step_number, completion_percentage, form_version, revision FROM form_submissions WHERE id = $1
 `
 
 func (q *Queries) GetFormSubmission(ctx context.Context, id uuid.UUID) (FormSubmission, error) {
@@ -911,12 +947,14 @@ func (q *Queries) GetFormSubmission(ctx context.Context, id uuid.UUID) (FormSubm
 		&i.UpdatedAt,
 		&i.CurrentStepNumber,
 		&i.CompletionPercentage,
+		&i.FormVersion,
+		&i.Revision,
 	)
 	return i, err
 }
 
 const getFormSubmissionByUserAndForm = `-- name: GetFormSubmissionByUserAndForm :one
-SELECT id, form_definition_id, user_id, submission_data, status, approval_status, approval_notes, approved_by, approved_at, metadata, created_at, updated_at, current_step_number, completion_percentage FROM form_submissions
+SELECT id, form_definition_id, user_id, submission_data, status, approval_status, approval_notes, approved_by, approved_at, metadata, created_at, updated_at, current_step_number, completion_percentage, form_version, revision FROM form_submissions
 WHERE user_id = $1 AND form_definition_id = $2 AND status = $3
 ORDER BY created_at DESC
 LIMIT 1
@@ -946,12 +984,14 @@ func (q *Queries) GetFormSubmissionByUserAndForm(ctx context.Context, arg GetFor
 		&i.UpdatedAt,
 		&i.CurrentStepNumber,
 		&i.CompletionPercentage,
+		&i.FormVersion,
+		&i.Revision,
 	)
 	return i, err
 }
 
 const getFormSubmissionFiles = `-- name: GetFormSubmissionFiles :many
-SELECT id, form_submission_id, field_name, file_name, file_path, file_size, mime_type, bucket, storage_provider, uploaded_at FROM form_submission_files
+SELECT id, form_submission_id, field_name, file_name, file_path, file_size, mime_type, bucket, storage_provider, uploaded_at, scan_status, scan_signature, scanned_at FROM form_submission_files
 WHERE form_submission_id = $1
 ORDER BY uploaded_at
 `
@@ -976,6 +1016,9 @@ func (q *Queries) GetFormSubmissionFiles(ctx context.Context, formSubmissionID u
 			&i.Bucket,
 			&i.StorageProvider,
 			&i.UploadedAt,
+			&i.ScanStatus,
+			&i.ScanSignature,
+			&i.ScannedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -1013,7 +1056,7 @@ func (q *Queries) GetPersistenceConfig(ctx context.Context, formDefinitionID uui
 }
 
 const getStepProgress = `-- name: GetStepProgress :one
-SELECT id, form_submission_id, form_step_id, step_number, status, completed_at, data, created_at, updated_at FROM form_step_progress
+SELECT id, form_submission_id, form_step_id, step_number, status, completed_at, data, created_at, updated_at, revision FROM form_step_progress
 WHERE form_submission_id = $1 AND form_step_id = $2
 `
 
@@ -1035,12 +1078,13 @@ func (q *Queries) GetStepProgress(ctx context.Context, arg GetStepProgressParams
 		&i.Data,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Revision,
 	)
 	return i, err
 }
 
 const getStepProgressBySubmissionAndNumber = `-- name: GetStepProgressBySubmissionAndNumber :one
-SELECT id, form_submission_id, form_step_id, step_number, status, completed_at, data, created_at, updated_at FROM form_step_progress
+SELECT id, form_submission_id, form_step_id, step_number, status, completed_at, data, created_at, updated_at, revision FROM form_step_progress
 WHERE form_submission_id = $1 AND step_number = $2
 `
 
@@ -1062,6 +1106,7 @@ func (q *Queries) GetStepProgressBySubmissionAndNumber(ctx context.Context, arg
 		&i.Data,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Revision,
 	)
 	return i, err
 }
@@ -1125,7 +1170,7 @@ func (q *Queries) ListFormDefinitions(ctx context.Context, arg ListFormDefinitio
 }
 
 const listFormSubmissions = `-- name: ListFormSubmissions :many
-SELECT id, form_definition_id, user_id, submission_data, status, approval_status, approval_notes, approved_by, approved_at, metadata, created_at, updated_at, current_step_number, completion_percentage FROM form_submissions
+SELECT id, form_definition_id, user_id, submission_data, status, approval_status, approval_notes, approved_by, approved_at, metadata, created_at, updated_at, current_step_number, completion_percentage, form_version, revision FROM form_submissions
 WHERE ($1::uuid IS NULL OR user_id = $1)
   AND ($2::uuid IS NULL OR form_definition_id = $2)
   AND ($3::varchar IS NULL OR status = $3)
@@ -1171,6 +1216,8 @@ func (q *Queries) ListFormSubmissions(ctx context.Context, arg ListFormSubmissio
 			&i.UpdatedAt,
 			&i.CurrentStepNumber,
 			&i.CompletionPercentage,
+			&i.FormVersion,
+			&i.Revision,
 		); err != nil {
 			return nil, err
 		}
@@ -1235,9 +1282,10 @@ const updateFormSubmission = `-- name: UpdateFormSubmission :one
 UPDATE form_submissions SET
                             submission_data = $2, status = $3, approval_status = $4,
                             approval_notes = $5, approved_by = $6, approved_at = $7,
-                            metadata = $8, updated_at = CURRENT_TIMESTAMP
+                            metadata = $8, updated_at = CURRENT_TIMESTAMP,
+                            revision = revision + 1
 WHERE id = $1
-RETURNING id, form_definition_id, user_id, submission_data, status, approval_status, approval_notes, approved_by, approved_at, metadata, created_at, updated_at, current_step_number, completion_percentage
+RETURNING id, form_definition_id, user_id, submission_data, status, approval_status, approval_notes, approved_by, approved_at, metadata, created_at, updated_at, current_step_number, completion_percentage, form_version, revision
 `
 
 type UpdateFormSubmissionParams struct {
@@ -1278,6 +1326,8 @@ func (q *Queries) UpdateFormSubmission(ctx context.Context, arg UpdateFormSubmis
 		&i.UpdatedAt,
 		&i.CurrentStepNumber,
 		&i.CompletionPercentage,
+		&i.FormVersion,
+		&i.Revision,
 	)
 	return i, err
 }
@@ -1329,9 +1379,10 @@ UPDATE form_step_progress SET
                               status = $2,
                               data = $3,
                               completed_at = CASE WHEN $2 = 'completed' THEN CURRENT_TIMESTAMP ELSE completed_at END,
-                              updated_at = CURRENT_TIMESTAMP
+                              updated_at = CURRENT_TIMESTAMP,
+                              revision = revision + 1
 WHERE id = $1
-RETURNING id, form_submission_id, form_step_id, step_number, status, completed_at, data, created_at, updated_at
+RETURNING id, form_submission_id, form_step_id, step_number, status, completed_at, data, created_at, updated_at, revision
 `
 
 type UpdateStepProgressParams struct {
@@ -1353,6 +1404,7 @@ func (q *Queries) UpdateStepProgress(ctx context.Context, arg UpdateStepProgress
 		&i.Data,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Revision,
 	)
 	return i, err
 }
@@ -1363,7 +1415,7 @@ UPDATE form_submissions SET
                             completion_percentage = $3,
                             updated_at = CURRENT_TIMESTAMP
 WHERE id = $1
-RETURNING id, form_definition_id, user_id, submission_data, status, approval_status, approval_notes, approved_by, approved_at, metadata, created_at, updated_at, current_step_number, completion_percentage
+RETURNING id, form_definition_id, user_id, submission_data, status, approval_status, approval_notes, approved_by, approved_at, metadata, created_at, updated_at, current_step_number, completion_percentage, form_version, revision
 `
 
 type UpdateSubmissionProgressParams struct {
@@ -1390,6 +1442,8 @@ func (q *Queries) UpdateSubmissionProgress(ctx context.Context, arg UpdateSubmis
 		&i.UpdatedAt,
 		&i.CurrentStepNumber,
 		&i.CompletionPercentage,
+		&i.FormVersion,
+		&i.Revision,
 	)
 	return i, err
 }
@@ -0,0 +1,306 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// FormValidationFailure is a field that failed validation when a submission was saved. They are
+// kept for analytics only; the user sees the errors in the response.
+type FormValidationFailure struct {
+	ID               uuid.UUID     `json:"id"`
+	FormDefinitionID uuid.UUID     `json:"form_definition_id"`
+	FormVersion      int32         `json:"form_version"`
+	FormSubmissionID uuid.NullUUID `json:"form_submission_id"`
+	UserID           uuid.UUID     `json:"user_id"`
+	StepNumber       sql.NullInt32 `json:"step_number"`
+	FieldName        string        `json:"field_name"`
+	Message          string        `json:"message"`
+	CreatedAt        time.Time     `json:"created_at"`
+}
+
+type RecordFormValidationFailuresParams struct {
+	FormDefinitionID uuid.UUID     `json:"form_definition_id"`
+	FormVersion      int32         `json:"form_version"`
+	FormSubmissionID uuid.NullUUID `json:"form_submission_id"`
+	UserID           uuid.UUID     `json:"user_id"`
+	StepNumber       sql.NullInt32 `json:"step_number"`
+	// Errors maps each failing field to its message
+	Errors map[string]string `json:"errors"`
+}
+
+// FormAnalyticsFilter selects the submissions analytics are computed over: those of a form
+// created in [From, To). A zero Version covers every version.
+type FormAnalyticsFilter struct {
+	FormDefinitionID uuid.UUID `json:"form_definition_id"`
+	Version          int32     `json:"version"`
+	From             time.Time `json:"from"`
+	To               time.Time `json:"to"`
+}
+
+// FormSubmissionTotals counts the submissions started and submitted
+type FormSubmissionTotals struct {
+	Started   int64 `json:"started"`
+	Submitted int64 `json:"submitted"`
+}
+
+// FormStepFunnelRow is the progress of submissions through a step. Durations are measured from
+// the completion of the previous step, or the start of the submission for the first.
+type FormStepFunnelRow struct {
+	StepNumber    int32   `json:"step_number"`
+	Started       int64   `json:"started"`
+	Completed     int64   `json:"completed"`
+	MedianSeconds float64 `json:"median_seconds"`
+	P90Seconds    float64 `json:"p90_seconds"`
+}
+
+// FormAbandonmentRow counts unsubmitted submissions idle since before a cutoff by the step
+// they stopped at
+type FormAbandonmentRow struct {
+	StepNumber int32 `json:"step_number"`
+	Abandoned  int64 `json:"abandoned"`
+}
+
+// FormFieldErrorRow counts the failures of a field with one message, or of the field in total
+// when Message is empty
+type FormFieldErrorRow struct {
+	FieldName string `json:"field_name"`
+	Message   string `json:"message"`
+	Failures  int64  `json:"failures"`
+	Users     int64  `json:"users"`
+}
+
+// FormApprovalStageRow is the turnaround of a stage of approval. An empty StageName is the
+// whole round, from its first stage starting to the decision.
+type FormApprovalStageRow struct {
+	StageName   string  `json:"stage_name"`
+	StageOrder  int32   `json:"stage_order"`
+	Decided     int64   `json:"decided"`
+	Pending     int64   `json:"pending"`
+	MedianHours float64 `json:"median_hours"`
+	P90Hours    float64 `json:"p90_hours"`
+}
+
+// formAnalyticsSubmissions restricts a query to the submissions of a FormAnalyticsFilter,
+// bound as $1 to $4
+const formAnalyticsSubmissions = `s.form_definition_id = $1
+  AND ($2::int = 0 OR s.form_version = $2)
+  AND s.created_at >= $3
+  AND s.created_at < $4`
+
+func formAnalyticsArgs(filter FormAnalyticsFilter, args ...interface{}) []interface{} {
+	return append([]interface{}{filter.FormDefinitionID, filter.Version, filter.From, filter.To}, args...)
+}
+
+// RecordFormValidationFailures stores a row for each field of a failed validation
+func (store *SQLStore) RecordFormValidationFailures(ctx context.Context, arg RecordFormValidationFailuresParams) error {
+	if len(arg.Errors) == 0 {
+		return nil
+	}
+
+	fields := make([]string, 0, len(arg.Errors))
+	for field := range arg.Errors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = arg.Errors[field]
+	}
+
+	_, err := store.db.ExecContext(ctx, `INSERT INTO form_validation_failures (
+    form_definition_id, form_version, form_submission_id, user_id, step_number, field_name, message
+)
+SELECT $1, $2, $3, $4, $5, field_name, message
+FROM unnest($6::text[], $7::text[]) AS failure(field_name, message)`,
+		arg.FormDefinitionID,
+		arg.FormVersion,
+		arg.FormSubmissionID,
+		arg.UserID,
+		arg.StepNumber,
+		pq.Array(fields),
+		pq.Array(messages),
+	)
+	return err
+}
+
+// GetFormSubmissionTotals counts the submissions of the filter and those that were submitted
+func (store *SQLStore) GetFormSubmissionTotals(ctx context.Context, filter FormAnalyticsFilter) (FormSubmissionTotals, error) {
+	var totals FormSubmissionTotals
+	err := store.db.QueryRowContext(ctx, `SELECT COUNT(*), COUNT(*) FILTER (WHERE s.status <> 'draft')
+FROM form_submissions s
+WHERE `+formAnalyticsSubmissions, formAnalyticsArgs(filter)...).Scan(&totals.Started, &totals.Submitted)
+	return totals, err
+}
+
+// GetFormStepFunnel returns, for each step, how many submissions reached and completed it and
+// how long completing it took
+func (store *SQLStore) GetFormStepFunnel(ctx context.Context, filter FormAnalyticsFilter) ([]FormStepFunnelRow, error) {
+	rows, err := store.db.QueryContext(ctx, `WITH progress AS (
+    SELECT p.form_submission_id, p.step_number, p.status, p.completed_at,
+           COALESCE(
+               LAG(p.completed_at) OVER (PARTITION BY p.form_submission_id ORDER BY p.step_number),
+               s.created_at
+           ) AS started_at
+    FROM form_step_progress p
+    JOIN form_submissions s ON s.id = p.form_submission_id
+    WHERE `+formAnalyticsSubmissions+`
+), durations AS (
+    SELECT *, EXTRACT(EPOCH FROM completed_at - started_at)::float8 AS seconds
+    FROM progress
+)
+SELECT step_number,
+       COUNT(DISTINCT form_submission_id),
+       COUNT(DISTINCT form_submission_id) FILTER (WHERE status = 'completed'),
+       COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY seconds) FILTER (WHERE status = 'completed' AND seconds >= 0), 0),
+       COALESCE(percentile_cont(0.9) WITHIN GROUP (ORDER BY seconds) FILTER (WHERE status = 'completed' AND seconds >= 0), 0)
+FROM durations
+GROUP BY step_number
+ORDER BY step_number`, formAnalyticsArgs(filter)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []FormStepFunnelRow{}
+	for rows.Next() {
+		var i FormStepFunnelRow
+		if err := rows.Scan(&i.StepNumber, &i.Started, &i.Completed, &i.MedianSeconds, &i.P90Seconds); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// GetFormAbandonment counts the drafts untouched since idleSince by the step they stopped at
+func (store *SQLStore) GetFormAbandonment(ctx context.Context, filter FormAnalyticsFilter, idleSince time.Time) ([]FormAbandonmentRow, error) {
+	rows, err := store.db.QueryContext(ctx, `SELECT COALESCE(s.current_step_number, 1) AS step_number, COUNT(*)
+FROM form_submissions s
+WHERE `+formAnalyticsSubmissions+`
+  AND s.status = 'draft'
+  AND s.updated_at < $5
+GROUP BY 1
+ORDER BY 1`, formAnalyticsArgs(filter, idleSince)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []FormAbandonmentRow{}
+	for rows.Next() {
+		var i FormAbandonmentRow
+		if err := rows.Scan(&i.StepNumber, &i.Abandoned); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// GetFormFieldErrors counts validation failures by field and message, and by field alone with
+// an empty Message. Fields of group items are counted together across items, e.g. as
+// owners[].first_name. Failures are filtered by when they happened rather than when the
+// submission was started.
+func (store *SQLStore) GetFormFieldErrors(ctx context.Context, filter FormAnalyticsFilter) ([]FormFieldErrorRow, error) {
+	rows, err := store.db.QueryContext(ctx, `WITH failures AS (
+    SELECT regexp_replace(f.field_name, '\[[0-9]+\]', '[]', 'g') AS field_name, f.message, f.user_id
+    FROM form_validation_failures f
+    WHERE f.form_definition_id = $1
+      AND ($2::int = 0 OR f.form_version = $2)
+      AND f.created_at >= $3
+      AND f.created_at < $4
+)
+SELECT field_name, COALESCE(message, ''), COUNT(*), COUNT(DISTINCT user_id)
+FROM failures
+GROUP BY GROUPING SETS ((field_name, message), (field_name))
+ORDER BY COUNT(*) DESC, field_name, message NULLS FIRST`, formAnalyticsArgs(filter)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []FormFieldErrorRow{}
+	for rows.Next() {
+		var i FormFieldErrorRow
+		if err := rows.Scan(&i.FieldName, &i.Message, &i.Failures, &i.Users); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// GetFormApprovalTurnaround measures how long each approval stage, and each round as a whole,
+// took to decide. A round is decided once a stage rejects or requests changes, or every stage
+// approves; rounds superseded by a resubmission are left out.
+func (store *SQLStore) GetFormApprovalTurnaround(ctx context.Context, filter FormAnalyticsFilter) ([]FormApprovalStageRow, error) {
+	rows, err := store.db.QueryContext(ctx, `WITH tasks AS (
+    SELECT t.form_submission_id, t.round, t.stage_name, t.stage_order, t.status,
+           t.activated_at, t.completed_at,
+           EXTRACT(EPOCH FROM t.completed_at - t.activated_at)::float8 / 3600 AS hours
+    FROM form_approval_tasks t
+    JOIN form_submissions s ON s.id = t.form_submission_id
+    WHERE `+formAnalyticsSubmissions+`
+), rounds AS (
+    SELECT bool_or(status IN ($6, $7)) OR bool_and(status = $8) AS decided,
+           bool_or(status IN ($9, $10)) AS pending,
+           EXTRACT(EPOCH FROM MAX(completed_at) FILTER (WHERE status <> $5) - MIN(activated_at))::float8 / 3600 AS hours
+    FROM tasks
+    GROUP BY form_submission_id, round
+)
+SELECT stage_name, stage_order,
+       COUNT(*) FILTER (WHERE completed_at IS NOT NULL),
+       COUNT(*) FILTER (WHERE completed_at IS NULL),
+       COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY hours), 0),
+       COALESCE(percentile_cont(0.9) WITHIN GROUP (ORDER BY hours), 0)
+FROM tasks
+WHERE status <> $5
+GROUP BY stage_name, stage_order
+UNION ALL
+SELECT '', 0,
+       COUNT(*) FILTER (WHERE decided),
+       COUNT(*) FILTER (WHERE pending),
+       COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY hours) FILTER (WHERE decided), 0),
+       COALESCE(percentile_cont(0.9) WITHIN GROUP (ORDER BY hours) FILTER (WHERE decided), 0)
+FROM rounds
+ORDER BY 2, 1`, formAnalyticsArgs(filter,
+		ApprovalTaskStatusCancelled,
+		ApprovalTaskStatusRejected,
+		ApprovalTaskStatusChangesRequested,
+		ApprovalTaskStatusApproved,
+		ApprovalTaskStatusWaiting,
+		ApprovalTaskStatusActive,
+	)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []FormApprovalStageRow{}
+	for rows.Next() {
+		var i FormApprovalStageRow
+		if err := rows.Scan(&i.StageName, &i.StageOrder, &i.Decided, &i.Pending, &i.MedianHours, &i.P90Hours); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,560 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ApprovalTaskStatusWaiting          = "waiting"
+	ApprovalTaskStatusActive           = "active"
+	ApprovalTaskStatusApproved         = "approved"
+	ApprovalTaskStatusRejected         = "rejected"
+	ApprovalTaskStatusChangesRequested = "changes_requested"
+	ApprovalTaskStatusCancelled        = "cancelled"
+
+	ApprovalActionSubmitted        = "submitted"
+	ApprovalActionApproved         = "approved"
+	ApprovalActionRejected         = "rejected"
+	ApprovalActionChangesRequested = "changes_requested"
+	ApprovalActionEscalated        = "escalated"
+	ApprovalActionEdited           = "edited"
+)
+
+var (
+	ErrApprovalTaskNotActive = errors.New("approval stage is not awaiting a decision")
+	ErrMakerChecker          = errors.New("approvers cannot decide on a submission they submitted or edited")
+	ErrDuplicateApprover     = errors.New("approver has already approved this submission")
+)
+
+// FormApprovalTask is one stage of a submission's approval workflow. Each time a submission
+// is (re)submitted a new round of tasks is created. Tasks sharing a stage order run in
+// parallel; the next order becomes active once every task of the current order is approved.
+type FormApprovalTask struct {
+	ID                uuid.UUID    `json:"id"`
+	FormSubmissionID  uuid.UUID    `json:"form_submission_id"`
+	Round             int32        `json:"round"`
+	StageName         string       `json:"stage_name"`
+	StageOrder        int32        `json:"stage_order"`
+	Permission        string       `json:"permission"`
+	RequiredApprovals int32        `json:"required_approvals"`
+	SLAHours          int32        `json:"sla_hours"`
+	EscalateTo        string       `json:"escalate_to"`
+	Status            string       `json:"status"`
+	ActivatedAt       sql.NullTime `json:"activated_at"`
+	DueAt             sql.NullTime `json:"due_at"`
+	EscalatedAt       sql.NullTime `json:"escalated_at"`
+	CompletedAt       sql.NullTime `json:"completed_at"`
+	CreatedAt         time.Time    `json:"created_at"`
+}
+
+// FormApprovalEvent is an entry in the audit trail of a submission's approval
+type FormApprovalEvent struct {
+	ID               uuid.UUID       `json:"id"`
+	FormSubmissionID uuid.UUID       `json:"form_submission_id"`
+	TaskID           uuid.NullUUID   `json:"task_id"`
+	Round            int32           `json:"round"`
+	Action           string          `json:"action"`
+	ActorID          uuid.NullUUID   `json:"actor_id"`
+	Comment          string          `json:"comment"`
+	FieldComments    json.RawMessage `json:"field_comments"`
+	CreatedAt        time.Time       `json:"created_at"`
+}
+
+type ApprovalStageInput struct {
+	Name              string `json:"name"`
+	Order             int32  `json:"order"`
+	Permission        string `json:"permission"`
+	RequiredApprovals int32  `json:"required_approvals"`
+	SLAHours          int32  `json:"sla_hours"`
+	EscalateTo        string `json:"escalate_to"`
+}
+
+// StartApprovalRoundInput starts a new approval round, superseding any open one
+type StartApprovalRoundInput struct {
+	SubmissionID uuid.UUID            `json:"submission_id"`
+	ActorID      uuid.UUID            `json:"actor_id"`
+	Stages       []ApprovalStageInput `json:"stages"`
+}
+
+// ApprovalDecisionInput records an approver's decision on an active task
+type ApprovalDecisionInput struct {
+	TaskID        uuid.UUID         `json:"task_id"`
+	ActorID       uuid.UUID         `json:"actor_id"`
+	Action        string            `json:"action"` // approved, rejected or changes_requested
+	Comment       string            `json:"comment"`
+	FieldComments map[string]string `json:"field_comments"`
+}
+
+type ApprovalDecisionResult struct {
+	Submission FormSubmission   `json:"submission"`
+	Task       FormApprovalTask `json:"task"`
+	// ActivatedTasks are the stages that became active as a result of the decision
+	ActivatedTasks []FormApprovalTask `json:"activated_tasks"`
+	// Completed is set when the decision finished the workflow
+	Completed bool `json:"completed"`
+}
+
+type CreateFormApprovalEventParams struct {
+	FormSubmissionID uuid.UUID       `json:"form_submission_id"`
+	TaskID           uuid.NullUUID   `json:"task_id"`
+	Action           string          `json:"action"`
+	ActorID          uuid.NullUUID   `json:"actor_id"`
+	Comment          string          `json:"comment"`
+	FieldComments    json.RawMessage `json:"field_comments"`
+}
+
+const formApprovalTaskColumns = `id, form_submission_id, round, stage_name, stage_order, permission,
+    required_approvals, sla_hours, escalate_to, status, activated_at, due_at, escalated_at,
+    completed_at, created_at`
+
+func scanFormApprovalTask(row interface{ Scan(...interface{}) error }) (FormApprovalTask, error) {
+	var i FormApprovalTask
+	err := row.Scan(
+		&i.ID,
+		&i.FormSubmissionID,
+		&i.Round,
+		&i.StageName,
+		&i.StageOrder,
+		&i.Permission,
+		&i.RequiredApprovals,
+		&i.SLAHours,
+		&i.EscalateTo,
+		&i.Status,
+		&i.ActivatedAt,
+		&i.DueAt,
+		&i.EscalatedAt,
+		&i.CompletedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+func queryFormApprovalTasks(ctx context.Context, q *Queries, query string, args ...interface{}) ([]FormApprovalTask, error) {
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []FormApprovalTask{}
+	for rows.Next() {
+		i, err := scanFormApprovalTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// GetCurrentApprovalTasks returns the tasks of the latest approval round of a submission
+func (store *SQLStore) GetCurrentApprovalTasks(ctx context.Context, submissionID uuid.UUID) ([]FormApprovalTask, error) {
+	return queryFormApprovalTasks(ctx, store.Queries, `SELECT `+formApprovalTaskColumns+` FROM form_approval_tasks
+WHERE form_submission_id = $1
+  AND round = (SELECT MAX(round) FROM form_approval_tasks WHERE form_submission_id = $1)
+ORDER BY stage_order, stage_name`, submissionID)
+}
+
+// ListActiveApprovalTasks returns the tasks awaiting a decision, the most urgent first
+func (store *SQLStore) ListActiveApprovalTasks(ctx context.Context) ([]FormApprovalTask, error) {
+	return queryFormApprovalTasks(ctx, store.Queries, `SELECT `+formApprovalTaskColumns+` FROM form_approval_tasks
+WHERE status = $1
+ORDER BY due_at NULLS LAST, activated_at`, ApprovalTaskStatusActive)
+}
+
+// ListFormApprovalEvents returns the approval audit trail of a submission, oldest first
+func (store *SQLStore) ListFormApprovalEvents(ctx context.Context, submissionID uuid.UUID) ([]FormApprovalEvent, error) {
+	rows, err := store.db.QueryContext(ctx, `SELECT id, form_submission_id, task_id, round, action, actor_id, comment, field_comments, created_at
+FROM form_approval_events
+WHERE form_submission_id = $1
+ORDER BY created_at, id`, submissionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []FormApprovalEvent{}
+	for rows.Next() {
+		var i FormApprovalEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.FormSubmissionID,
+			&i.TaskID,
+			&i.Round,
+			&i.Action,
+			&i.ActorID,
+			&i.Comment,
+			&i.FieldComments,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// CreateFormApprovalEvent adds an entry to a submission's approval audit trail in its latest round
+func (store *SQLStore) CreateFormApprovalEvent(ctx context.Context, arg CreateFormApprovalEventParams) error {
+	return createFormApprovalEvent(ctx, store.Queries, arg)
+}
+
+func createFormApprovalEvent(ctx context.Context, q *Queries, arg CreateFormApprovalEventParams) error {
+	fieldComments := arg.FieldComments
+	if fieldComments == nil {
+		fieldComments = json.RawMessage("{}")
+	}
+
+	_, err := q.db.ExecContext(ctx, `
+INSERT INTO form_approval_events (id, form_submission_id, task_id, round, action, actor_id, comment, field_comments)
+VALUES ($1, $2, $3,
+        (SELECT COALESCE(MAX(round), 0) FROM form_approval_tasks WHERE form_submission_id = $2),
+        $4, $5, $6, $7)`,
+		uuid.New(),
+		arg.FormSubmissionID,
+		arg.TaskID,
+		arg.Action,
+		arg.ActorID,
+		arg.Comment,
+		fieldComments,
+	)
+	return err
+}
+
+func lockFormSubmission(ctx context.Context, q *Queries, submissionID uuid.UUID) (FormSubmission, error) {
+	if _, err := q.db.ExecContext(ctx, `SELECT id FROM form_submissions WHERE id = $1 FOR UPDATE`, submissionID); err != nil {
+		return FormSubmission{}, err
+	}
+	submission, err := q.GetFormSubmission(ctx, submissionID)
+	return openFormSubmission(submission), err
+}
+
+// closeOpenApprovalTasks cancels the tasks of a submission that are still waiting or active
+func closeOpenApprovalTasks(ctx context.Context, q *Queries, submissionID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, `UPDATE form_approval_tasks SET status = $2, completed_at = CURRENT_TIMESTAMP
+WHERE form_submission_id = $1 AND status IN ($3, $4)`,
+		submissionID, ApprovalTaskStatusCancelled, ApprovalTaskStatusWaiting, ApprovalTaskStatusActive)
+	return err
+}
+
+// activateNextApprovalStage activates the waiting tasks with the lowest stage order in a round
+func activateNextApprovalStage(ctx context.Context, q *Queries, submissionID uuid.UUID, round int32) ([]FormApprovalTask, error) {
+	return queryFormApprovalTasks(ctx, q, `UPDATE form_approval_tasks SET
+    status = $3,
+    activated_at = CURRENT_TIMESTAMP,
+    due_at = CASE WHEN sla_hours > 0 THEN CURRENT_TIMESTAMP + sla_hours * INTERVAL '1 hour' END
+WHERE form_submission_id = $1 AND round = $2 AND status = $4
+  AND stage_order = (
+      SELECT MIN(stage_order) FROM form_approval_tasks
+      WHERE form_submission_id = $1 AND round = $2 AND status = $4
+  )
+RETURNING `+formApprovalTaskColumns,
+		submissionID, round, ApprovalTaskStatusActive, ApprovalTaskStatusWaiting)
+}
+
+func setSubmissionApproval(ctx context.Context, q *Queries, submission FormSubmission, status, approvalStatus, notes string, actorID uuid.UUID) (FormSubmission, error) {
+	data, err := sealSubmissionJSON(ctx, q, submission.FormDefinitionID, submission.SubmissionData)
+	if err != nil {
+		return FormSubmission{}, err
+	}
+
+	updated, err := q.UpdateFormSubmission(ctx, UpdateFormSubmissionParams{
+		ID:             submission.ID,
+		SubmissionData: data,
+		Status:         status,
+		ApprovalStatus: approvalStatus,
+		ApprovalNotes:  notes,
+		ApprovedBy:     NewNullUUID(actorID),
+		ApprovedAt:     time.Now(),
+		Metadata:       submission.Metadata,
+	})
+	return openFormSubmission(updated), err
+}
+
+// StartApprovalRoundTx creates the tasks of a new approval round and activates its first stage.
+// Open tasks from an earlier round are cancelled, so approvals given before the submission was
+// changed do not count.
+func (store *SQLStore) StartApprovalRoundTx(ctx context.Context, input *StartApprovalRoundInput, events FormEventsFunc[[]FormApprovalTask]) ([]FormApprovalTask, error) {
+	var active []FormApprovalTask
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		submission, err := lockFormSubmission(ctx, q, input.SubmissionID)
+		if err != nil {
+			return err
+		}
+
+		if err := closeOpenApprovalTasks(ctx, q, submission.ID); err != nil {
+			return err
+		}
+
+		var round int32
+		if err := q.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(round), 0) + 1 FROM form_approval_tasks WHERE form_submission_id = $1`, submission.ID).Scan(&round); err != nil {
+			return err
+		}
+
+		for _, stage := range input.Stages {
+			_, err := q.db.ExecContext(ctx, `
+INSERT INTO form_approval_tasks (
+    id, form_submission_id, round, stage_name, stage_order, permission,
+    required_approvals, sla_hours, escalate_to, status
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+				uuid.New(),
+				submission.ID,
+				round,
+				stage.Name,
+				stage.Order,
+				stage.Permission,
+				stage.RequiredApprovals,
+				stage.SLAHours,
+				stage.EscalateTo,
+				ApprovalTaskStatusWaiting,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		active, err = activateNextApprovalStage(ctx, q, submission.ID, round)
+		if err != nil {
+			return err
+		}
+
+		if err := createFormApprovalEvent(ctx, q, CreateFormApprovalEventParams{
+			FormSubmissionID: submission.ID,
+			Action:           ApprovalActionSubmitted,
+			ActorID:          NewNullUUID(input.ActorID),
+		}); err != nil {
+			return err
+		}
+
+		if _, err := q.db.ExecContext(ctx, `UPDATE form_submissions SET approval_status = 'pending', updated_at = CURRENT_TIMESTAMP WHERE id = $1`, submission.ID); err != nil {
+			return err
+		}
+
+		return enqueueFormEvents(ctx, q, events, active)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return active, nil
+}
+
+// DecideApprovalTaskTx records an approver's decision and advances the workflow. Approvals
+// enforce maker-checker: the submitter, anyone who edited the submission, and anyone who has
+// already approved in the same round cannot approve.
+func (store *SQLStore) DecideApprovalTaskTx(ctx context.Context, input *ApprovalDecisionInput, events FormEventsFunc[*ApprovalDecisionResult]) (*ApprovalDecisionResult, error) {
+	var result ApprovalDecisionResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var submissionID uuid.UUID
+		if err := q.db.QueryRowContext(ctx, `SELECT form_submission_id FROM form_approval_tasks WHERE id = $1`, input.TaskID).Scan(&submissionID); err != nil {
+			return err
+		}
+
+		submission, err := lockFormSubmission(ctx, q, submissionID)
+		if err != nil {
+			return err
+		}
+
+		task, err := scanFormApprovalTask(q.db.QueryRowContext(ctx, `SELECT `+formApprovalTaskColumns+` FROM form_approval_tasks WHERE id = $1`, input.TaskID))
+		if err != nil {
+			return err
+		}
+
+		if task.Status != ApprovalTaskStatusActive {
+			return ErrApprovalTaskNotActive
+		}
+
+		if submission.UserID == input.ActorID {
+			return ErrMakerChecker
+		}
+
+		if input.Action == ApprovalActionApproved {
+			var edited, approved bool
+			if err := q.db.QueryRowContext(ctx, `SELECT
+    EXISTS (SELECT 1 FROM form_approval_events WHERE form_submission_id = $1 AND actor_id = $2 AND action = $3),
+    EXISTS (SELECT 1 FROM form_approval_events WHERE form_submission_id = $1 AND actor_id = $2 AND action = $4 AND round = $5)`,
+				submission.ID, input.ActorID, ApprovalActionEdited, ApprovalActionApproved, task.Round,
+			).Scan(&edited, &approved); err != nil {
+				return err
+			}
+			if edited {
+				return ErrMakerChecker
+			}
+			if approved {
+				return ErrDuplicateApprover
+			}
+		}
+
+		fieldComments := json.RawMessage("{}")
+		if len(input.FieldComments) > 0 {
+			fieldComments, err = json.Marshal(input.FieldComments)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := createFormApprovalEvent(ctx, q, CreateFormApprovalEventParams{
+			FormSubmissionID: submission.ID,
+			TaskID:           NewNullUUID(task.ID),
+			Action:           input.Action,
+			ActorID:          NewNullUUID(input.ActorID),
+			Comment:          input.Comment,
+			FieldComments:    fieldComments,
+		}); err != nil {
+			return err
+		}
+
+		switch input.Action {
+		case ApprovalActionApproved:
+			var approvals int32
+			if err := q.db.QueryRowContext(ctx, `SELECT COUNT(DISTINCT actor_id) FROM form_approval_events WHERE task_id = $1 AND action = $2`,
+				task.ID, ApprovalActionApproved).Scan(&approvals); err != nil {
+				return err
+			}
+
+			if approvals >= task.RequiredApprovals {
+				task, err = scanFormApprovalTask(q.db.QueryRowContext(ctx, `UPDATE form_approval_tasks SET status = $2, completed_at = CURRENT_TIMESTAMP
+WHERE id = $1 RETURNING `+formApprovalTaskColumns, task.ID, ApprovalTaskStatusApproved))
+				if err != nil {
+					return err
+				}
+
+				var pending int
+				if err := q.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM form_approval_tasks
+WHERE form_submission_id = $1 AND round = $2 AND stage_order = $3 AND status <> $4`,
+					submission.ID, task.Round, task.StageOrder, ApprovalTaskStatusApproved).Scan(&pending); err != nil {
+					return err
+				}
+
+				if pending == 0 {
+					result.ActivatedTasks, err = activateNextApprovalStage(ctx, q, submission.ID, task.Round)
+					if err != nil {
+						return err
+					}
+
+					if len(result.ActivatedTasks) == 0 {
+						result.Completed = true
+						submission, err = setSubmissionApproval(ctx, q, submission, "approved", "approved", input.Comment, input.ActorID)
+						if err != nil {
+							return err
+						}
+					}
+				}
+			}
+
+		case ApprovalActionRejected, ApprovalActionChangesRequested:
+			task, err = scanFormApprovalTask(q.db.QueryRowContext(ctx, `UPDATE form_approval_tasks SET status = $2, completed_at = CURRENT_TIMESTAMP
+WHERE id = $1 RETURNING `+formApprovalTaskColumns, task.ID, input.Action))
+			if err != nil {
+				return err
+			}
+
+			if err := closeOpenApprovalTasks(ctx, q, submission.ID); err != nil {
+				return err
+			}
+
+			result.Completed = true
+
+			// Requesting changes sends the submission back to the user as a draft
+			status := "rejected"
+			if input.Action == ApprovalActionChangesRequested {
+				status = "draft"
+			}
+
+			submission, err = setSubmissionApproval(ctx, q, submission, status, input.Action, input.Comment, input.ActorID)
+			if err != nil {
+				return err
+			}
+
+		default:
+			return errors.New("unknown approval action")
+		}
+
+		result.Submission = submission
+		result.Task = task
+		return enqueueFormEvents(ctx, q, events, &result)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// EscalatedApprovalTask is a task escalated by EscalateOverdueApprovalTasksTx, with the form of
+// its submission
+type EscalatedApprovalTask struct {
+	FormApprovalTask
+	FormDefinitionID uuid.UUID `json:"form_definition_id"`
+}
+
+// EscalateOverdueApprovalTasksTx marks active tasks past their SLA as escalated, reassigns them
+// to the permission of their EscalateTo, and records the escalation in the audit trail. Each
+// task is escalated once; tasks without EscalateTo keep their permission.
+func (store *SQLStore) EscalateOverdueApprovalTasksTx(ctx context.Context, events FormEventsFunc[EscalatedApprovalTask]) ([]FormApprovalTask, error) {
+	var escalated []FormApprovalTask
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		escalated, err = queryFormApprovalTasks(ctx, q, `UPDATE form_approval_tasks SET
+    escalated_at = CURRENT_TIMESTAMP,
+    permission = CASE WHEN escalate_to <> '' THEN escalate_to ELSE permission END
+WHERE status = $1 AND due_at < CURRENT_TIMESTAMP AND escalated_at IS NULL
+RETURNING `+formApprovalTaskColumns, ApprovalTaskStatusActive)
+		if err != nil {
+			return err
+		}
+
+		for _, task := range escalated {
+			comment := "approval is overdue"
+			if task.EscalateTo != "" {
+				comment = "approval is overdue, reassigned to " + task.EscalateTo
+			}
+
+			if err := createFormApprovalEvent(ctx, q, CreateFormApprovalEventParams{
+				FormSubmissionID: task.FormSubmissionID,
+				TaskID:           NewNullUUID(task.ID),
+				Action:           ApprovalActionEscalated,
+				Comment:          comment,
+			}); err != nil {
+				return err
+			}
+
+			if events == nil {
+				continue
+			}
+
+			escalation := EscalatedApprovalTask{FormApprovalTask: task}
+			if err := q.db.QueryRowContext(ctx, `SELECT form_definition_id FROM form_submissions WHERE id = $1`, task.FormSubmissionID).Scan(&escalation.FormDefinitionID); err != nil {
+				return err
+			}
+			if err := enqueueFormEvents(ctx, q, events, escalation); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return escalated, nil
+}
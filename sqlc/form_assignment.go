@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const listFormAssignmentsByType = `
+SELECT fa.id, fa.form_definition_id, fa.assignment_type, fa.assignment_value, fa.conditions, fa.priority, fa.valid_from, fa.valid_until, fa.created_at, fa.created_by, fd.form_type
+FROM form_assignments fa
+         JOIN form_definitions fd ON fd.id = fa.form_definition_id
+WHERE fd.is_active = true
+  AND fd.form_type = $1
+ORDER BY fa.priority DESC, fa.created_at
+`
+
+// ListFormAssignmentsByType returns every assignment of the active forms of a type, in
+// priority order, including those outside their time window, so the resolver can say why
+// they don't apply
+func (q *Queries) ListFormAssignmentsByType(ctx context.Context, formType string) ([]GetFormAssignmentsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listFormAssignmentsByType, formType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []GetFormAssignmentsRow{}
+	for rows.Next() {
+		var i GetFormAssignmentsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.FormDefinitionID,
+			&i.AssignmentType,
+			&i.AssignmentValue,
+			&i.Conditions,
+			&i.Priority,
+			&i.ValidFrom,
+			&i.ValidUntil,
+			&i.CreatedAt,
+			&i.CreatedBy,
+			&i.FormType,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// TransactionVolumeFilter selects the completed transactions of a user in one currency created
+// since a time
+type TransactionVolumeFilter struct {
+	UserID       uuid.UUID `json:"user_id"`
+	Since        time.Time `json:"since"`
+	CurrencyCode string    `json:"currency_code"`
+}
+
+const getUserTransactionVolume = `
+SELECT COALESCE(SUM(t.amount), 0)
+FROM transactions t
+         JOIN currencies c ON c.id = t.currency_id
+WHERE t.user_id = $1
+  AND t.status = $2
+  AND t.created_at >= $3
+  AND c.code = upper($4::text)
+`
+
+// GetUserTransactionVolume sums the amounts of a user's completed transactions in a currency.
+// Amounts in different currencies can't be added, so a filter without one is an error.
+func (q *Queries) GetUserTransactionVolume(ctx context.Context, filter TransactionVolumeFilter) (decimal.Decimal, error) {
+	var volume decimal.Decimal
+	if filter.CurrencyCode == "" {
+		return volume, errors.New("transaction volume needs a currency")
+	}
+	err := q.db.QueryRowContext(ctx, getUserTransactionVolume,
+		filter.UserID,
+		TransactionStatusCompleted,
+		filter.Since,
+		filter.CurrencyCode,
+	).Scan(&volume)
+	return volume, err
+}
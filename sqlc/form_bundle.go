@@ -0,0 +1,366 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sqlc-dev/pqtype"
+)
+
+// Conflict strategies of a form import, for when a form with the same slug already exists
+const (
+	FormImportConflictFail       = "fail"
+	FormImportConflictSkip       = "skip"
+	FormImportConflictNewVersion = "new_version"
+)
+
+// Outcomes of a form import
+const (
+	FormImportCreated = "created"
+	FormImportSkipped = "skipped"
+	FormImportUpdated = "updated"
+)
+
+var ErrFormSlugExists = errors.New("a form with this slug already exists")
+
+// FormEventInput is an event handler of an imported form
+type FormEventInput struct {
+	EventType     string          `json:"event_type"`
+	HandlerType   string          `json:"handler_type"`
+	HandlerConfig json.RawMessage `json:"handler_config"`
+	IsActive      bool            `json:"is_active"`
+}
+
+// FormAssignmentInput is an assignment of an imported form
+type FormAssignmentInput struct {
+	AssignmentType  string          `json:"assignment_type"`
+	AssignmentValue string          `json:"assignment_value"`
+	Conditions      json.RawMessage `json:"conditions,omitempty"`
+	Priority        int32           `json:"priority"`
+	ValidFrom       *time.Time      `json:"valid_from,omitempty"`
+	ValidUntil      *time.Time      `json:"valid_until,omitempty"`
+}
+
+// FormOptionSourceInput is a dynamic option source used by an imported form
+type FormOptionSourceInput struct {
+	Name          string          `json:"name"`
+	SourceType    string          `json:"source_type"`
+	SourceConfig  json.RawMessage `json:"source_config"`
+	CacheDuration int32           `json:"cache_duration"`
+}
+
+// FormImportInput creates a form, or a new version of the form with the same slug, together
+// with its events, assignments and the option sources its fields use. Events and assignments
+// the form already has are kept and not duplicated, so importing the same definition twice is
+// harmless. Option sources are shared between forms and matched by name; existing sources are
+// never changed.
+type FormImportInput struct {
+	Definition    *FormDefinitionInput    `json:"definition"`
+	OptionSources []FormOptionSourceInput `json:"option_sources"`
+	Events        []FormEventInput        `json:"events"`
+	Assignments   []FormAssignmentInput   `json:"assignments"`
+	OnConflict    string                  `json:"on_conflict"`
+	ChangeNote    string                  `json:"change_note"`
+}
+
+type FormImportResult struct {
+	Form                 FormDefinition `json:"form"`
+	Action               string         `json:"action"`
+	OptionSourcesCreated int            `json:"option_sources_created"`
+	EventsCreated        int            `json:"events_created"`
+	AssignmentsCreated   int            `json:"assignments_created"`
+}
+
+const formEventColumns = `id, form_definition_id, event_type, handler_type, handler_config, is_active, created_at`
+
+const formAssignmentColumns = `id, form_definition_id, assignment_type, assignment_value, conditions, priority,
+    valid_from, valid_until, created_at, created_by`
+
+// ListFormEvents returns every event handler of a form, including inactive ones
+func (store *SQLStore) ListFormEvents(ctx context.Context, formID uuid.UUID) ([]FormEvent, error) {
+	return listFormEvents(ctx, store.Queries, formID)
+}
+
+func listFormEvents(ctx context.Context, q *Queries, formID uuid.UUID) ([]FormEvent, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT `+formEventColumns+` FROM form_events
+WHERE form_definition_id = $1
+ORDER BY created_at, id`, formID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []FormEvent{}
+	for rows.Next() {
+		var i FormEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.FormDefinitionID,
+			&i.EventType,
+			&i.HandlerType,
+			&i.HandlerConfig,
+			&i.IsActive,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// ListFormAssignments returns every assignment of a form, including expired ones
+func (store *SQLStore) ListFormAssignments(ctx context.Context, formID uuid.UUID) ([]FormAssignment, error) {
+	return listFormAssignments(ctx, store.Queries, formID)
+}
+
+func listFormAssignments(ctx context.Context, q *Queries, formID uuid.UUID) ([]FormAssignment, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT `+formAssignmentColumns+` FROM form_assignments
+WHERE form_definition_id = $1
+ORDER BY priority DESC, created_at, id`, formID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []FormAssignment{}
+	for rows.Next() {
+		var i FormAssignment
+		if err := rows.Scan(
+			&i.ID,
+			&i.FormDefinitionID,
+			&i.AssignmentType,
+			&i.AssignmentValue,
+			&i.Conditions,
+			&i.Priority,
+			&i.ValidFrom,
+			&i.ValidUntil,
+			&i.CreatedAt,
+			&i.CreatedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// ImportFormDefinitionTx creates a form from an exported definition. When a form with the same
+// slug exists, OnConflict decides: fail (the default) returns ErrFormSlugExists, skip leaves the
+// form as it is, and new_version publishes the definition as the form's next version, replacing
+// any unpublished draft.
+func (store *SQLStore) ImportFormDefinitionTx(ctx context.Context, input *FormImportInput) (*FormImportResult, error) {
+	var result FormImportResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		def := input.Definition
+
+		var existingID uuid.UUID
+		err := q.db.QueryRowContext(ctx, `SELECT id FROM form_definitions WHERE slug = $1 FOR UPDATE`, def.Slug).Scan(&existingID)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			result.Form, err = insertFormDefinition(ctx, q, def)
+			if err != nil {
+				return err
+			}
+			result.Action = FormImportCreated
+
+		case err != nil:
+			return err
+
+		case input.OnConflict == FormImportConflictSkip:
+			result.Form, err = q.GetFormDefinition(ctx, existingID)
+			result.Action = FormImportSkipped
+			return err
+
+		case input.OnConflict == FormImportConflictNewVersion:
+			draft, err := saveFormDraftVersion(ctx, q, &FormDraftVersionInput{
+				FormDefinitionID: existingID,
+				Definition:       def,
+				ChangeNote:       input.ChangeNote,
+			})
+			if err != nil {
+				return err
+			}
+			result.Form, err = publishFormVersion(ctx, q, existingID, draft.Version, def.CreatedBy)
+			if err != nil {
+				return err
+			}
+			result.Action = FormImportUpdated
+
+		default:
+			return ErrFormSlugExists
+		}
+
+		if result.OptionSourcesCreated, err = importFormOptionSources(ctx, q, input.OptionSources); err != nil {
+			return err
+		}
+		if result.EventsCreated, err = importFormEvents(ctx, q, result.Form.ID, input.Events); err != nil {
+			return err
+		}
+		result.AssignmentsCreated, err = importFormAssignments(ctx, q, result.Form.ID, def.CreatedBy, input.Assignments)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// importFormOptionSources creates the option sources that don't exist yet
+func importFormOptionSources(ctx context.Context, q *Queries, sources []FormOptionSourceInput) (int, error) {
+	created := 0
+	for _, source := range sources {
+		_, err := q.GetDynamicOptionByName(ctx, source.Name)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return created, err
+		}
+
+		config := source.SourceConfig
+		if len(config) == 0 {
+			config = json.RawMessage("{}")
+		}
+		if _, err := q.CreateDynamicOption(ctx, CreateDynamicOptionParams{
+			ID:            uuid.New(),
+			Name:          source.Name,
+			SourceType:    source.SourceType,
+			SourceConfig:  config,
+			CacheDuration: source.CacheDuration,
+		}); err != nil {
+			return created, err
+		}
+		created++
+	}
+
+	return created, nil
+}
+
+// importFormEvents creates the events a form does not have yet
+func importFormEvents(ctx context.Context, q *Queries, formID uuid.UUID, events []FormEventInput) (int, error) {
+	existing, err := listFormEvents(ctx, q, formID)
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, event := range events {
+		duplicate := false
+		for _, e := range existing {
+			if e.EventType == event.EventType && e.HandlerType == event.HandlerType && sameJSON(e.HandlerConfig, event.HandlerConfig) {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+
+		config := event.HandlerConfig
+		if len(config) == 0 {
+			config = json.RawMessage("{}")
+		}
+		e, err := q.CreateFormEvent(ctx, CreateFormEventParams{
+			ID:               uuid.New(),
+			FormDefinitionID: formID,
+			EventType:        event.EventType,
+			HandlerType:      event.HandlerType,
+			HandlerConfig:    config,
+			IsActive:         event.IsActive,
+		})
+		if err != nil {
+			return created, err
+		}
+		existing = append(existing, e)
+		created++
+	}
+
+	return created, nil
+}
+
+// importFormAssignments creates the assignments a form does not have yet. An assignment is
+// identified by its type and value.
+func importFormAssignments(ctx context.Context, q *Queries, formID, createdBy uuid.UUID, assignments []FormAssignmentInput) (int, error) {
+	existing, err := listFormAssignments(ctx, q, formID)
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, assignment := range assignments {
+		duplicate := false
+		for _, a := range existing {
+			if a.AssignmentType == assignment.AssignmentType && a.AssignmentValue == assignment.AssignmentValue {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+
+		params := CreateFormAssignmentParams{
+			ID:               uuid.New(),
+			FormDefinitionID: formID,
+			AssignmentType:   assignment.AssignmentType,
+			AssignmentValue:  assignment.AssignmentValue,
+			Priority:         assignment.Priority,
+			CreatedBy:        NewNullUUID(createdBy),
+		}
+		if len(assignment.Conditions) > 0 && string(assignment.Conditions) != "null" {
+			params.Conditions = pqtype.NullRawMessage{RawMessage: assignment.Conditions, Valid: true}
+		}
+		if assignment.ValidFrom != nil {
+			params.ValidFrom = sql.NullTime{Time: *assignment.ValidFrom, Valid: true}
+		}
+		if assignment.ValidUntil != nil {
+			params.ValidUntil = sql.NullTime{Time: *assignment.ValidUntil, Valid: true}
+		}
+
+		a, err := q.CreateFormAssignment(ctx, params)
+		if err != nil {
+			return created, err
+		}
+		existing = append(existing, a)
+		created++
+	}
+
+	return created, nil
+}
+
+// sameJSON reports whether two JSON documents hold the same value, ignoring formatting and
+// key order. An empty document equals an empty object.
+func sameJSON(a, b json.RawMessage) bool {
+	decode := func(raw json.RawMessage) (interface{}, bool) {
+		if len(raw) == 0 {
+			raw = json.RawMessage("{}")
+		}
+		var v interface{}
+		return v, json.Unmarshal(raw, &v) == nil
+	}
+
+	va, ok := decode(a)
+	if !ok {
+		return false
+	}
+	vb, ok := decode(b)
+	if !ok {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,392 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// encryptedValuePrefix marks envelope encrypted form values, which are written as
+// enc:v1:<key id>:<wrapped data key>:<ciphertext>
+const encryptedValuePrefix = "enc:v1:"
+
+var (
+	ErrNoFieldEncryptionKey  = errors.New("no form field encryption key is configured")
+	ErrInvalidEncryptedValue = errors.New("invalid encrypted form value")
+)
+
+// fieldKeys holds the key encryption keys of form fields marked is_encrypted, by ID. The last
+// key added encrypts new values; the others are kept to decrypt values written with them.
+var fieldKeys = struct {
+	sync.RWMutex
+	current string
+	keys    map[string][]byte
+}{keys: map[string][]byte{}}
+
+// SetFieldEncryptionKey adds a 32 byte key encryption key and makes it the one new values are
+// encrypted with
+func SetFieldEncryptionKey(id string, key []byte) error {
+	if id == "" || strings.Contains(id, ":") {
+		return fmt.Errorf("invalid encryption key id %q", id)
+	}
+	if len(key) != 32 {
+		return fmt.Errorf("encryption key %s must be 32 bytes, got %d", id, len(key))
+	}
+
+	fieldKeys.Lock()
+	defer fieldKeys.Unlock()
+	fieldKeys.keys[id] = append([]byte(nil), key...)
+	fieldKeys.current = id
+	return nil
+}
+
+// LoadFieldEncryptionKeys adds the keys of a comma separated list of id:base64 pairs, the
+// current key last, as kept in the FORM_ENCRYPTION_KEYS setting
+func LoadFieldEncryptionKeys(spec string) error {
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(pair, ":")
+		if !ok {
+			return errors.New("invalid encryption key, expected id:base64")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("encryption key %s: %w", id, err)
+		}
+		if err := SetFieldEncryptionKey(id, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncryptFieldValue encrypts a value with a fresh data key, which is itself encrypted with the
+// current key encryption key and stored alongside it
+func EncryptFieldValue(plaintext string) (string, error) {
+	fieldKeys.RLock()
+	id, kek := fieldKeys.current, fieldKeys.keys[fieldKeys.current]
+	fieldKeys.RUnlock()
+	if kek == nil {
+		return "", ErrNoFieldEncryptionKey
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	wrappedKey, err := sealAESGCM(kek, dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := sealAESGCM(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	return encryptedValuePrefix + id + ":" +
+		base64.RawStdEncoding.EncodeToString(wrappedKey) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+// DecryptFieldValue decrypts a value written by EncryptFieldValue
+func DecryptFieldValue(value string) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(value, encryptedValuePrefix), ":")
+	if !strings.HasPrefix(value, encryptedValuePrefix) || len(parts) != 3 {
+		return "", ErrInvalidEncryptedValue
+	}
+
+	fieldKeys.RLock()
+	kek := fieldKeys.keys[parts[0]]
+	fieldKeys.RUnlock()
+	if kek == nil {
+		return "", fmt.Errorf("%w: unknown key %s", ErrNoFieldEncryptionKey, parts[0])
+	}
+
+	wrappedKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrInvalidEncryptedValue
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrInvalidEncryptedValue
+	}
+
+	dataKey, err := openAESGCM(kek, wrappedKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := openAESGCM(dataKey, ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// IsEncryptedFieldValue reports whether a stored value was written by EncryptFieldValue
+func IsEncryptedFieldValue(value string) bool {
+	return strings.HasPrefix(value, encryptedValuePrefix)
+}
+
+// sealAESGCM encrypts with AES-256-GCM, prefixing the random nonce
+func sealAESGCM(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func openAESGCM(key, sealed []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrInvalidEncryptedValue
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrInvalidEncryptedValue
+	}
+	return plaintext, nil
+}
+
+// encryptedFormFields returns the fields a form's persistence config marks is_encrypted. Their
+// answers are encrypted in the submission JSON too, so the plaintext is never stored.
+func encryptedFormFields(ctx context.Context, q *Queries, formID uuid.UUID) (map[string]bool, error) {
+	config, err := q.GetPersistenceConfig(ctx, formID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get persistence config: %w", err)
+	}
+
+	var mappings map[string]FieldMapping
+	if len(config.FieldMappings) > 0 {
+		if err := json.Unmarshal(config.FieldMappings, &mappings); err != nil {
+			return nil, fmt.Errorf("failed to parse field mappings: %w", err)
+		}
+	}
+
+	fields := make(map[string]bool)
+	for formField, mapping := range mappings {
+		if mapping.IsEncrypted && !mapping.isGroupMapping() {
+			fields[formField] = true
+		}
+	}
+	return fields, nil
+}
+
+// sealSubmissionData encodes the answers of a form's submission for storage, with the answers
+// of encrypted fields encrypted
+func sealSubmissionData(ctx context.Context, q *Queries, formID uuid.UUID, data map[string]interface{}) (json.RawMessage, error) {
+	fields, err := encryptedFormFields(ctx, q, formID)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := sealAnswers(fields, data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(sealed)
+}
+
+// sealSubmissionJSON is sealSubmissionData for answers that are already encoded
+func sealSubmissionJSON(ctx context.Context, q *Queries, formID uuid.UUID, raw json.RawMessage) (json.RawMessage, error) {
+	if len(raw) == 0 {
+		return raw, nil
+	}
+	return sealSubmissionData(ctx, q, formID, decodeSubmissionData(raw))
+}
+
+// sealAnswers returns a copy of data with the answers of fields encrypted. Answers are encrypted
+// as JSON, so they open to the value they had; those already encrypted are kept.
+func sealAnswers(fields map[string]bool, data map[string]interface{}) (map[string]interface{}, error) {
+	if len(fields) == 0 {
+		return data, nil
+	}
+
+	sealed := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		sealed[key] = value
+		if !fields[key] || value == nil {
+			continue
+		}
+		if text, ok := value.(string); ok && IsEncryptedFieldValue(text) {
+			continue
+		}
+
+		plaintext, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if sealed[key], err = EncryptFieldValue(string(plaintext)); err != nil {
+			return nil, fmt.Errorf("field %s: %w", key, err)
+		}
+	}
+	return sealed, nil
+}
+
+// openSubmissionData decrypts the encrypted answers of stored submission JSON. Answers that
+// can't be decrypted, because their key isn't loaded, are left as they are.
+func openSubmissionData(raw json.RawMessage) json.RawMessage {
+	if !bytes.Contains(raw, []byte(encryptedValuePrefix)) {
+		return raw
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return raw
+	}
+	for key, value := range data {
+		text, ok := value.(string)
+		if !ok || !IsEncryptedFieldValue(text) {
+			continue
+		}
+		plaintext, err := DecryptFieldValue(text)
+		if err != nil {
+			continue
+		}
+		var answer interface{}
+		if err := json.Unmarshal([]byte(plaintext), &answer); err == nil {
+			data[key] = answer
+		}
+	}
+
+	opened, err := json.Marshal(data)
+	if err != nil {
+		return raw
+	}
+	return opened
+}
+
+// storedSubmissionData selects the JSON columns holding the answers of a form's submissions,
+// with the id of their row, to encrypt answers stored before a field was marked is_encrypted
+var storedSubmissionData = []struct {
+	table, column, query string
+}{
+	{"form_submissions", "submission_data",
+		`SELECT id, submission_data FROM form_submissions WHERE form_definition_id = $1 FOR UPDATE`},
+	{"form_submission_revisions", "submission_data",
+		`SELECT r.id, r.submission_data FROM form_submission_revisions r
+JOIN form_submissions s ON s.id = r.form_submission_id
+WHERE s.form_definition_id = $1 FOR UPDATE OF r`},
+	{"form_step_progress", "data",
+		`SELECT p.id, p.data FROM form_step_progress p
+JOIN form_submissions s ON s.id = p.form_submission_id
+WHERE s.form_definition_id = $1 AND p.data IS NOT NULL FOR UPDATE OF p`},
+	{"public_form_submissions", "submission_data",
+		`SELECT id, submission_data FROM public_form_submissions WHERE form_definition_id = $1 FOR UPDATE`},
+}
+
+// SealFormSubmissionsTx encrypts the answers of encrypted fields wherever a form's submissions
+// are stored, in submissions, their revisions, step progress and public submissions. It is run
+// when a persistence config is saved, and returns the number of rows it encrypted.
+func (store *SQLStore) SealFormSubmissionsTx(ctx context.Context, formID uuid.UUID) (int64, error) {
+	var sealed int64
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		sealed, err = sealStoredSubmissions(ctx, q, formID)
+		return err
+	})
+	return sealed, err
+}
+
+func sealStoredSubmissions(ctx context.Context, q *Queries, formID uuid.UUID) (int64, error) {
+	fields, err := encryptedFormFields(ctx, q, formID)
+	if err != nil || len(fields) == 0 {
+		return 0, err
+	}
+
+	type storedData struct {
+		id   uuid.UUID
+		data json.RawMessage
+	}
+
+	var sealed int64
+	for _, stored := range storedSubmissionData {
+		rows, err := q.db.QueryContext(ctx, stored.query, formID)
+		if err != nil {
+			return sealed, err
+		}
+		var items []storedData
+		for rows.Next() {
+			var item storedData
+			if err := rows.Scan(&item.id, &item.data); err != nil {
+				rows.Close()
+				return sealed, err
+			}
+			items = append(items, item)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return sealed, err
+		}
+
+		for _, item := range items {
+			data := decodeSubmissionData(item.data)
+			if !hasPlaintextAnswers(fields, data) {
+				continue
+			}
+			answers, err := sealAnswers(fields, data)
+			if err != nil {
+				return sealed, err
+			}
+			dataJSON, err := json.Marshal(answers)
+			if err != nil {
+				return sealed, err
+			}
+			// Table and column names come from storedSubmissionData
+			query := fmt.Sprintf(`UPDATE %s SET %s = $2 WHERE id = $1`, stored.table, stored.column)
+			if _, err := q.db.ExecContext(ctx, query, item.id, dataJSON); err != nil {
+				return sealed, err
+			}
+			sealed++
+		}
+	}
+	return sealed, nil
+}
+
+// hasPlaintextAnswers reports whether any answer of fields is stored unencrypted
+func hasPlaintextAnswers(fields map[string]bool, data map[string]interface{}) bool {
+	for field := range fields {
+		value, ok := data[field]
+		if !ok || value == nil {
+			continue
+		}
+		if text, isText := value.(string); !isText || !IsEncryptedFieldValue(text) {
+			return true
+		}
+	}
+	return false
+}
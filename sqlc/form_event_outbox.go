@@ -0,0 +1,213 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	FormEventOutboxStatusPending    = "pending"
+	FormEventOutboxStatusProcessing = "processing"
+	FormEventOutboxStatusDelivered  = "delivered"
+	FormEventOutboxStatusFailed     = "failed"
+)
+
+// FormEventOutbox is a delivery of a form event to one of its handlers. Rows are written when
+// the event fires and retried until the handler succeeds, so every handler sees each event at
+// least once. A row left in processing by a crashed worker is claimed again once its lease expires.
+type FormEventOutbox struct {
+	ID               uuid.UUID       `json:"id"`
+	FormEventID      uuid.UUID       `json:"form_event_id"`
+	FormDefinitionID uuid.UUID       `json:"form_definition_id"`
+	FormSubmissionID uuid.NullUUID   `json:"form_submission_id"`
+	UserID           uuid.NullUUID   `json:"user_id"`
+	EventType        string          `json:"event_type"`
+	HandlerType      string          `json:"handler_type"`
+	HandlerConfig    json.RawMessage `json:"handler_config"`
+	Payload          json.RawMessage `json:"payload"`
+	Status           string          `json:"status"`
+	Attempts         int32           `json:"attempts"`
+	LastError        string          `json:"last_error"`
+	NextAttemptAt    time.Time       `json:"next_attempt_at"`
+	LockedUntil      sql.NullTime    `json:"locked_until"`
+	DeliveredAt      sql.NullTime    `json:"delivered_at"`
+	CreatedAt        time.Time       `json:"created_at"`
+}
+
+type EnqueueFormEventsParams struct {
+	FormDefinitionID uuid.UUID       `json:"form_definition_id"`
+	EventType        string          `json:"event_type"`
+	FormSubmissionID uuid.NullUUID   `json:"form_submission_id"`
+	UserID           uuid.NullUUID   `json:"user_id"`
+	Payload          json.RawMessage `json:"payload"`
+}
+
+type FailFormEventOutboxParams struct {
+	ID            uuid.UUID `json:"id"`
+	LastError     string    `json:"last_error"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	// GiveUp marks the delivery as failed instead of scheduling another attempt
+	GiveUp bool `json:"give_up"`
+}
+
+const formEventOutboxColumns = `id, form_event_id, form_definition_id, form_submission_id, user_id, event_type,
+    handler_type, handler_config, payload, status, attempts, last_error, next_attempt_at, locked_until,
+    delivered_at, created_at`
+
+func queryFormEventOutbox(ctx context.Context, q *Queries, query string, args ...interface{}) ([]FormEventOutbox, error) {
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []FormEventOutbox{}
+	for rows.Next() {
+		var i FormEventOutbox
+		if err := rows.Scan(
+			&i.ID,
+			&i.FormEventID,
+			&i.FormDefinitionID,
+			&i.FormSubmissionID,
+			&i.UserID,
+			&i.EventType,
+			&i.HandlerType,
+			&i.HandlerConfig,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.LastError,
+			&i.NextAttemptAt,
+			&i.LockedUntil,
+			&i.DeliveredAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// FormEventsFunc returns the form events a change fires, given the result of the change. It is
+// called inside the transaction of the change, so the events reach the outbox only if the change
+// commits. A nil FormEventsFunc fires no events.
+type FormEventsFunc[T any] func(result T) ([]EnqueueFormEventsParams, error)
+
+// enqueueFormEvents writes the events fired by a change to the outbox
+func enqueueFormEvents[T any](ctx context.Context, q *Queries, events FormEventsFunc[T], result T) error {
+	if events == nil {
+		return nil
+	}
+
+	params, err := events(result)
+	if err != nil {
+		return err
+	}
+
+	for _, arg := range params {
+		if _, err := q.EnqueueFormEvents(ctx, arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnqueueFormEvents writes a delivery for every active handler of an event to the outbox
+func (q *Queries) EnqueueFormEvents(ctx context.Context, arg EnqueueFormEventsParams) ([]FormEventOutbox, error) {
+	return queryFormEventOutbox(ctx, q, `INSERT INTO form_event_outbox (
+    form_event_id, form_definition_id, form_submission_id, user_id, event_type, handler_type,
+    handler_config, payload, status, next_attempt_at
+)
+SELECT id, form_definition_id, $3, $4, event_type, handler_type, handler_config, $5, $6, NOW()
+FROM form_events
+WHERE form_definition_id = $1
+  AND event_type = $2
+  AND is_active = true
+RETURNING `+formEventOutboxColumns,
+		arg.FormDefinitionID,
+		arg.EventType,
+		arg.FormSubmissionID,
+		arg.UserID,
+		arg.Payload,
+		FormEventOutboxStatusPending,
+	)
+}
+
+// ClaimFormEventOutbox leases up to limit deliveries that are due, counting the attempt.
+// Concurrent workers never claim the same row.
+func (store *SQLStore) ClaimFormEventOutbox(ctx context.Context, limit int32, lease time.Duration) ([]FormEventOutbox, error) {
+	return queryFormEventOutbox(ctx, store.Queries, `UPDATE form_event_outbox
+SET status = $2,
+    attempts = attempts + 1,
+    locked_until = NOW() + make_interval(secs => $3)
+WHERE id IN (
+    SELECT id FROM form_event_outbox
+    WHERE (status = $4 AND next_attempt_at <= NOW())
+       OR (status = $2 AND locked_until < NOW())
+    ORDER BY next_attempt_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING `+formEventOutboxColumns,
+		limit,
+		FormEventOutboxStatusProcessing,
+		lease.Seconds(),
+		FormEventOutboxStatusPending,
+	)
+}
+
+// MarkFormEventOutboxDelivered records a successful delivery
+func (store *SQLStore) MarkFormEventOutboxDelivered(ctx context.Context, id uuid.UUID) error {
+	_, err := store.db.ExecContext(ctx, `UPDATE form_event_outbox
+SET status = $2, delivered_at = NOW(), locked_until = NULL, last_error = ''
+WHERE id = $1`, id, FormEventOutboxStatusDelivered)
+	return err
+}
+
+// FailFormEventOutbox records a failed attempt and either schedules the next one or gives up
+func (store *SQLStore) FailFormEventOutbox(ctx context.Context, arg FailFormEventOutboxParams) error {
+	status := FormEventOutboxStatusPending
+	if arg.GiveUp {
+		status = FormEventOutboxStatusFailed
+	}
+
+	_, err := store.db.ExecContext(ctx, `UPDATE form_event_outbox
+SET status = $2, last_error = $3, next_attempt_at = $4, locked_until = NULL
+WHERE id = $1`, arg.ID, status, arg.LastError, arg.NextAttemptAt)
+	return err
+}
+
+// ListFailedFormEventOutbox returns the deliveries of a form that ran out of attempts, newest first
+func (store *SQLStore) ListFailedFormEventOutbox(ctx context.Context, formID uuid.UUID) ([]FormEventOutbox, error) {
+	return queryFormEventOutbox(ctx, store.Queries, `SELECT `+formEventOutboxColumns+` FROM form_event_outbox
+WHERE form_definition_id = $1
+  AND status = $2
+ORDER BY created_at DESC`, formID, FormEventOutboxStatusFailed)
+}
+
+// RetryFormEventOutbox puts a failed delivery back in the queue with a fresh set of attempts
+func (store *SQLStore) RetryFormEventOutbox(ctx context.Context, id uuid.UUID) error {
+	result, err := store.db.ExecContext(ctx, `UPDATE form_event_outbox
+SET status = $2, attempts = 0, next_attempt_at = NOW()
+WHERE id = $1 AND status = $3`, id, FormEventOutboxStatusPending, FormEventOutboxStatusFailed)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
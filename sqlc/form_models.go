@@ -1,6 +1,7 @@
 package db
 
 import (
+	"database/sql"
 	"encoding/json"
 	"github.com/google/uuid"
 )
@@ -32,11 +33,15 @@ type FormSubmissionUpdateInput struct {
 	Metadata         map[string]interface{}    `json:"metadata"`
 	IsPartialUpdate  bool                      `json:"is_partial_update"`
 	ExistingData     json.RawMessage           `json:"existing_data"`
+	ExpectedRevision int32                     `json:"expected_revision"` // 0 skips the revision check
+	// RevisionSource is recorded with the revision the update makes, RevisionSourceUpdate by default
+	RevisionSource string `json:"revision_source,omitempty"`
 }
 
 type ApprovalWorkflowInput struct {
 	States      []map[string]interface{} `json:"states"`
 	Transitions []map[string]interface{} `json:"transitions"`
+	Stages      []map[string]interface{} `json:"stages,omitempty"`
 }
 
 type StepInput struct {
@@ -44,6 +49,8 @@ type StepInput struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	IsOptional  bool   `json:"is_optional"`
+	// ValidationRules are the custom rules checked across the fields of the step
+	ValidationRules map[string]interface{} `json:"validation_rules"`
 }
 
 type FieldInput struct {
@@ -61,6 +68,7 @@ type FieldInput struct {
 	DefaultValue     *string                `json:"default_value"`
 	ConditionalLogic map[string]interface{} `json:"conditional_logic"`
 	FileConfig       map[string]interface{} `json:"file_config"`
+	Prefill          map[string]interface{} `json:"prefill"`
 }
 
 type PersistenceConfigInput struct {
@@ -79,16 +87,20 @@ type FormSubmissionInput struct {
 	Data             map[string]interface{}    `json:"data"`
 	Files            []FormSubmissionFileInput `json:"files"`
 	Metadata         map[string]interface{}    `json:"metadata"`
+	FormVersion      int32                     `json:"form_version"` // defaults to the current version of the form
 }
 
 type FormSubmissionFileInput struct {
-	FieldName       string `json:"field_name"`
-	FileName        string `json:"file_name"`
-	FilePath        string `json:"file_path"`
-	FileSize        int64  `json:"file_size"`
-	MimeType        string `json:"mime_type"`
-	Bucket          string `json:"bucket"`
-	StorageProvider string `json:"storage_provider"`
+	FieldName       string       `json:"field_name"`
+	FileName        string       `json:"file_name"`
+	FilePath        string       `json:"file_path"`
+	FileSize        int64        `json:"file_size"`
+	MimeType        string       `json:"mime_type"`
+	Bucket          string       `json:"bucket"`
+	StorageProvider string       `json:"storage_provider"`
+	ScanStatus      string       `json:"scan_status"`    // not_scanned, clean or failed when the scanner was unavailable
+	ScanSignature   string       `json:"scan_signature"` // what the scanner reported, if anything
+	ScannedAt       sql.NullTime `json:"scanned_at"`
 }
 
 // FieldMapping and TargetConfig for persistence
@@ -100,19 +112,28 @@ type FieldMapping struct {
 	Transform   *string `json:"transform,omitempty"`
 	IsEncrypted bool    `json:"is_encrypted"`
 	MetaKey     string  `json:"meta_key,omitempty"` // For meta tables
+	// ItemMappings maps the fields of a group's items to columns of TableName, written as one
+	// row per item. ParentColumn links each row to the ParentTable row written for the submission.
+	ItemMappings map[string]string `json:"item_mappings,omitempty"`
+	ParentTable  string            `json:"parent_table,omitempty"`
+	ParentColumn string            `json:"parent_column,omitempty"`
 }
 
 type TargetConfig struct {
 	TableName  string                 `json:"table_name"`
 	Conditions map[string]interface{} `json:"conditions,omitempty"`
 	Priority   int                    `json:"priority"`
+	// UpsertKey names the columns that identify a row, so submitting again updates it
+	UpsertKey []string `json:"upsert_key,omitempty"`
 }
 
 type SaveStepProgressInput struct {
-	SubmissionID uuid.UUID              `json:"submission_id"`
-	StepID       uuid.UUID              `json:"step_id"`
-	StepNumber   int32                  `json:"step_number"`
-	Status       string                 `json:"status"`
-	Data         map[string]interface{} `json:"data"`
-	UserID       uuid.UUID              `json:"user_id"`
+	SubmissionID     uuid.UUID                 `json:"submission_id"`
+	StepID           uuid.UUID                 `json:"step_id"`
+	StepNumber       int32                     `json:"step_number"`
+	Status           string                    `json:"status"`
+	Data             map[string]interface{}    `json:"data"`
+	Files            []FormSubmissionFileInput `json:"files"`
+	UserID           uuid.UUID                 `json:"user_id"`
+	ExpectedRevision int32                     `json:"expected_revision"` // revision of the step; 0 skips the check
 }
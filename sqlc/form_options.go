@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OptionQueryTables is the allow-list of tables, and their columns, that query option sources
+// may read. Nothing outside it can be selected, filtered or ordered by.
+var OptionQueryTables = map[string][]string{
+	"banks":      {"id", "name", "code", "country", "currency", "type", "active", "is_deleted"},
+	"countries":  {"id", "name", "code", "enabled"},
+	"currencies": {"id", "name", "code", "active", "can_have_wallet"},
+}
+
+// MaxOptionRows caps the number of options a query option source returns
+const MaxOptionRows = 500
+
+var ErrOptionQueryNotAllowed = errors.New("option query is not allowed")
+
+// OptionQuery selects a value and label column from an allow-listed table. Filters match
+// columns by their text value.
+type OptionQuery struct {
+	Table       string            `json:"table"`
+	ValueColumn string            `json:"value_column"`
+	LabelColumn string            `json:"label_column"`
+	Filters     map[string]string `json:"filters"`
+	OrderBy     string            `json:"order_by"`
+	Limit       int32             `json:"limit"`
+}
+
+type OptionRow struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+}
+
+func optionColumnAllowed(columns []string, column string) bool {
+	for _, c := range columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// buildOptionQuery checks the query against OptionQueryTables and builds its SQL. Identifiers
+// only ever come from the allow-list; filter values are bound parameters.
+func buildOptionQuery(query OptionQuery) (string, []interface{}, error) {
+	columns, ok := OptionQueryTables[query.Table]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: table %q", ErrOptionQueryNotAllowed, query.Table)
+	}
+
+	for _, column := range []string{query.ValueColumn, query.LabelColumn} {
+		if !optionColumnAllowed(columns, column) {
+			return "", nil, fmt.Errorf("%w: column %q of %s", ErrOptionQueryNotAllowed, column, query.Table)
+		}
+	}
+
+	orderBy := query.LabelColumn
+	if query.OrderBy != "" {
+		if !optionColumnAllowed(columns, query.OrderBy) {
+			return "", nil, fmt.Errorf("%w: column %q of %s", ErrOptionQueryNotAllowed, query.OrderBy, query.Table)
+		}
+		orderBy = query.OrderBy
+	}
+
+	// Sorted so the same query always produces the same SQL
+	filterColumns := make([]string, 0, len(query.Filters))
+	for column := range query.Filters {
+		if !optionColumnAllowed(columns, column) {
+			return "", nil, fmt.Errorf("%w: column %q of %s", ErrOptionQueryNotAllowed, column, query.Table)
+		}
+		filterColumns = append(filterColumns, column)
+	}
+	sort.Strings(filterColumns)
+
+	conditions := make([]string, len(filterColumns))
+	args := make([]interface{}, 0, len(filterColumns)+1)
+	for i, column := range filterColumns {
+		conditions[i] = fmt.Sprintf("%s::text = $%d", column, i+1)
+		args = append(args, query.Filters[column])
+	}
+
+	limit := query.Limit
+	if limit <= 0 || limit > MaxOptionRows {
+		limit = MaxOptionRows
+	}
+	args = append(args, limit)
+
+	sqlQuery := fmt.Sprintf("SELECT COALESCE(%s::text, ''), COALESCE(%s::text, '') FROM %s", query.ValueColumn, query.LabelColumn, query.Table)
+	if len(conditions) > 0 {
+		sqlQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	sqlQuery += fmt.Sprintf(" ORDER BY %s LIMIT $%d", orderBy, len(args))
+
+	return sqlQuery, args, nil
+}
+
+// QueryOptionRows runs an option query in a read-only transaction
+func (store *SQLStore) QueryOptionRows(ctx context.Context, query OptionQuery) ([]OptionRow, error) {
+	sqlQuery, args, err := buildOptionQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := store.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []OptionRow{}
+	for rows.Next() {
+		var i OptionRow
+		if err := rows.Scan(&i.Value, &i.Label); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,29 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildOptionQuery(t *testing.T) {
+	query, args, err := buildOptionQuery(OptionQuery{
+		Table:       "banks",
+		ValueColumn: "code",
+		LabelColumn: "name",
+		Filters:     map[string]string{"country": "NG", "active": "true"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT COALESCE(code::text, ''), COALESCE(name::text, '') FROM banks WHERE active::text = $1 AND country::text = $2 ORDER BY name LIMIT $3", query)
+	assert.Equal(t, []interface{}{"true", "NG", int32(MaxOptionRows)}, args)
+
+	for _, q := range []OptionQuery{
+		{Table: "users", ValueColumn: "id", LabelColumn: "email"},
+		{Table: "banks", ValueColumn: "code", LabelColumn: "name; DROP TABLE banks"},
+		{Table: "banks", ValueColumn: "code", LabelColumn: "name", OrderBy: "random()"},
+		{Table: "banks", ValueColumn: "code", LabelColumn: "name", Filters: map[string]string{"1=1 OR code": "x"}},
+	} {
+		_, _, err := buildOptionQuery(q)
+		assert.ErrorIs(t, err, ErrOptionQueryNotAllowed)
+	}
+}
@@ -0,0 +1,691 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Persistence modes of a form
+const (
+	PersistenceModeJSON       = "json"
+	PersistenceModeDirect     = "direct"
+	PersistenceModeMultiTable = "multi_table"
+)
+
+// UserMetaTable is where mappings with a MetaKey write, as key/value pairs of the submitting user
+const UserMetaTable = "user_meta"
+
+var ErrPersistenceNotAllowed = errors.New("persistence config is not allowed")
+
+// PersistenceTable is a table form submissions may write to. Every table has an id primary key.
+type PersistenceTable struct {
+	// Columns maps the columns forms may write to their ColumnType
+	Columns map[string]string
+	// UserColumn, when set, is written with the ID of the user the submission belongs to
+	UserColumn string
+	// ParentTable and ParentColumn, when set, link every row to a row of ParentTable, a table
+	// with a UserColumn. Rows are only written as group items, with ParentColumn set to the
+	// ParentTable row written for the submitting user; mappings never write it themselves.
+	ParentTable  string
+	ParentColumn string
+	// UniqueKeys are the column sets with a unique index, which upsert keys must be one of so
+	// rows are upserted with ON CONFLICT. Keys include the UserColumn or ParentColumn, so a
+	// submission can only ever update rows of its own user. The indexes are created by
+	// migration/20261018091000_persistence_unique_keys.up.sql, which must be kept in step.
+	UniqueKeys [][]string
+	// PersonalColumns hold the personal data of the user, blanked when their data is erased or a
+	// submission that wrote them is anonymized
+	PersonalColumns []string
+	// OwnedBy selects the rows of a user, given as $1, whose personal data is erased with theirs
+	OwnedBy string
+}
+
+// PersistenceTables is the allow-list of tables, and their columns, that persistence configs
+// may map form fields to. Identifiers in the SQL written for a submission only ever come from
+// here; values are bound parameters.
+var PersistenceTables = map[string]PersistenceTable{
+	"businesses": {
+		UserColumn: "created_by",
+		UniqueKeys: [][]string{
+			{"created_by"},
+			{"created_by", "registration_number"},
+		},
+		PersonalColumns: []string{"phone", "email", "contact_name"},
+		OwnedBy:         `created_by = $1`,
+		Columns: map[string]string{
+			"name":                 ColumnTypeString,
+			"registration_number":  ColumnTypeString,
+			"business_nature":      ColumnTypeString,
+			"business_category":    ColumnTypeString,
+			"address1":             ColumnTypeString,
+			"address2":             ColumnTypeString,
+			"city":                 ColumnTypeString,
+			"post_code":            ColumnTypeString,
+			"state":                ColumnTypeString,
+			"country":              ColumnTypeString,
+			"website":              ColumnTypeString,
+			"product_description":  ColumnTypeString,
+			"registration_date":    ColumnTypeString,
+			"trading_address":      ColumnTypeString,
+			"trading_level":        ColumnTypeString,
+			"primary_contact_type": ColumnTypeString,
+			"phone":                ColumnTypeString,
+			"email":                ColumnTypeString,
+			"contact_name":         ColumnTypeString,
+			"incorporation_region": ColumnTypeJSON,
+			"created_by":           ColumnTypeUUID,
+		},
+	},
+	"business_owners": {
+		ParentTable:  "businesses",
+		ParentColumn: "business_id",
+		UniqueKeys: [][]string{
+			{"business_id", "id_number"},
+		},
+		PersonalColumns: []string{
+			"first_name", "last_name", "dob", "id_type", "id_number", "nationality",
+			"address1", "address2", "state", "city", "country",
+		},
+		OwnedBy: `linked_user_id = $1 OR business_id IN (SELECT id FROM businesses WHERE created_by = $1)`,
+		Columns: map[string]string{
+			"business_id":    ColumnTypeUUID,
+			"owner_role":     ColumnTypeString,
+			"first_name":     ColumnTypeString,
+			"last_name":      ColumnTypeString,
+			"dob":            ColumnTypeString,
+			"id_type":        ColumnTypeString,
+			"id_number":      ColumnTypeString,
+			"nationality":    ColumnTypeString,
+			"address1":       ColumnTypeString,
+			"address2":       ColumnTypeString,
+			"state":          ColumnTypeString,
+			"city":           ColumnTypeString,
+			"country":        ColumnTypeString,
+			"is_ubo":         ColumnTypeBoolean,
+			"holding_ratio":  ColumnTypeInteger,
+			"linked_user_id": ColumnTypeUUID,
+		},
+	},
+}
+
+// isUniqueKey reports whether columns, in any order, are one of the table's unique keys
+func (t PersistenceTable) isUniqueKey(columns []string) bool {
+	for _, key := range t.UniqueKeys {
+		if len(key) != len(columns) {
+			continue
+		}
+		matches := true
+		for _, column := range key {
+			matches = matches && containsColumn(columns, column)
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+func containsColumn(columns []string, column string) bool {
+	for _, c := range columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// ownerColumn is the column tying a table's rows to the submitting user, directly or through
+// the parent row
+func (t PersistenceTable) ownerColumn() string {
+	if t.UserColumn != "" {
+		return t.UserColumn
+	}
+	return t.ParentColumn
+}
+
+// inUniqueKey reports whether a column is part of one of the table's unique keys
+func (t PersistenceTable) inUniqueKey(column string) bool {
+	for _, key := range t.UniqueKeys {
+		if containsColumn(key, column) {
+			return true
+		}
+	}
+	return false
+}
+
+// persistedColumns returns the columns, by table, that the persistence config of a form maps
+// its fields to. Forms without a config persist nothing.
+func persistedColumns(ctx context.Context, q *Queries, formID uuid.UUID) (map[string]map[string]bool, error) {
+	columns := map[string]map[string]bool{}
+	config, err := q.GetPersistenceConfig(ctx, formID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return columns, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get persistence config: %w", err)
+	}
+
+	var mappings map[string]FieldMapping
+	if len(config.FieldMappings) > 0 {
+		if err := json.Unmarshal(config.FieldMappings, &mappings); err != nil {
+			return nil, fmt.Errorf("failed to parse field mappings: %w", err)
+		}
+	}
+
+	add := func(table, column string) {
+		if columns[table] == nil {
+			columns[table] = map[string]bool{}
+		}
+		columns[table][column] = true
+	}
+	for _, mapping := range mappings {
+		switch {
+		case mapping.isGroupMapping():
+			for _, column := range mapping.ItemMappings {
+				add(mapping.TableName, column)
+			}
+		case mapping.MetaKey == "":
+			add(mapping.TableName, mapping.ColumnName)
+		}
+	}
+	return columns, nil
+}
+
+// erasePersonalColumns blanks the personal columns of the rows a user owns in the persistence
+// tables, or only the given columns of each table when columns isn't nil. Columns of a unique
+// key get a placeholder unique to their row instead. It returns how many rows of each table
+// were erased.
+func erasePersonalColumns(ctx context.Context, q *Queries, userID uuid.UUID, columns map[string]map[string]bool) (map[string]int64, error) {
+	names := make([]string, 0, len(PersistenceTables))
+	for name := range PersistenceTables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	erased := map[string]int64{}
+	for _, name := range names {
+		table := PersistenceTables[name]
+		var sets []string
+		for _, column := range table.PersonalColumns {
+			if columns != nil && !columns[name][column] {
+				continue
+			}
+			value := `''`
+			if table.inUniqueKey(column) {
+				value = `'erased:' || id::text`
+			}
+			sets = append(sets, column+" = "+value)
+		}
+		if len(sets) == 0 || table.OwnedBy == "" {
+			continue
+		}
+
+		result, err := q.db.ExecContext(ctx, `UPDATE `+name+` SET `+strings.Join(sets, ", ")+`, updated_at = now()
+WHERE `+table.OwnedBy, userID)
+		if err != nil {
+			return nil, err
+		}
+		if erased[name], err = result.RowsAffected(); err != nil {
+			return nil, err
+		}
+	}
+	return erased, nil
+}
+
+func persistenceNotAllowed(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrPersistenceNotAllowed, fmt.Sprintf(format, args...))
+}
+
+// ParsePersistenceConfig decodes the targets and field mappings of a stored config
+func ParsePersistenceConfig(config FormPersistenceConfig) ([]TargetConfig, map[string]FieldMapping, error) {
+	var targets []TargetConfig
+	var mappings map[string]FieldMapping
+
+	if err := json.Unmarshal(config.TargetConfigs, &targets); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse target configs: %w", err)
+	}
+	if err := json.Unmarshal(config.FieldMappings, &mappings); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse field mappings: %w", err)
+	}
+	return targets, mappings, nil
+}
+
+// ValidatePersistenceConfig checks that a config only writes allow-listed columns, with known
+// types and transforms. Configs in json mode write nothing but the submission.
+func ValidatePersistenceConfig(mode string, targets []TargetConfig, mappings map[string]FieldMapping) error {
+	switch mode {
+	case PersistenceModeJSON:
+		return nil
+	case PersistenceModeDirect, PersistenceModeMultiTable:
+	default:
+		return persistenceNotAllowed("unknown persistence mode %q", mode)
+	}
+
+	if len(targets) == 0 {
+		return persistenceNotAllowed("%s mode needs a target table", mode)
+	}
+	for _, target := range targets {
+		table, ok := PersistenceTables[target.TableName]
+		if !ok {
+			return persistenceNotAllowed("table %q", target.TableName)
+		}
+		for _, column := range target.UpsertKey {
+			if _, ok := table.Columns[column]; !ok {
+				return persistenceNotAllowed("upsert key column %q of %s", column, target.TableName)
+			}
+		}
+		if len(target.UpsertKey) > 0 {
+			if owner := table.ownerColumn(); owner != "" && !containsColumn(target.UpsertKey, owner) {
+				return persistenceNotAllowed("upsert key of %s must include %s", target.TableName, owner)
+			}
+			if !table.isUniqueKey(target.UpsertKey) {
+				return persistenceNotAllowed("upsert key (%s) of %s has no unique index", strings.Join(target.UpsertKey, ", "), target.TableName)
+			}
+		}
+	}
+
+	for formField, mapping := range mappings {
+		if err := validateFieldMapping(mode, targets, mapping); err != nil {
+			return fmt.Errorf("field %s: %w", formField, err)
+		}
+	}
+	return nil
+}
+
+func validateFieldMapping(mode string, targets []TargetConfig, mapping FieldMapping) error {
+	var columnType string
+
+	switch {
+	case mapping.isGroupMapping():
+		table, ok := PersistenceTables[mapping.TableName]
+		if !ok {
+			return persistenceNotAllowed("table %q", mapping.TableName)
+		}
+		for _, column := range mapping.ItemMappings {
+			if _, ok := table.Columns[column]; !ok {
+				return persistenceNotAllowed("column %q of %s", column, mapping.TableName)
+			}
+			if column == table.UserColumn || column == table.ParentColumn {
+				return persistenceNotAllowed("%s.%s is set by the submission", mapping.TableName, column)
+			}
+		}
+		if table.ParentColumn != "" && (mapping.ParentTable != table.ParentTable || mapping.ParentColumn != table.ParentColumn) {
+			return persistenceNotAllowed("items of %s must be linked to %s through %s", mapping.TableName, table.ParentTable, table.ParentColumn)
+		}
+		if mapping.ParentColumn != "" {
+			if _, ok := table.Columns[mapping.ParentColumn]; !ok {
+				return persistenceNotAllowed("column %q of %s", mapping.ParentColumn, mapping.TableName)
+			}
+			if PersistenceTables[mapping.ParentTable].UserColumn == "" {
+				return persistenceNotAllowed("parent table %q has no user column", mapping.ParentTable)
+			}
+			if findTarget(targets, mapping.ParentTable) == nil {
+				return persistenceNotAllowed("parent table %q is not a target", mapping.ParentTable)
+			}
+		}
+		return nil
+
+	case mapping.MetaKey != "":
+		columnType = ColumnTypeString
+		if mapping.DataType != "" {
+			if dataType, _ := normalizeColumnType(mapping.DataType); dataType == ColumnTypeBoolean {
+				columnType = dataType
+			} else if dataType != ColumnTypeString {
+				return persistenceNotAllowed("user meta values are strings or booleans, not %q", mapping.DataType)
+			}
+		}
+
+	default:
+		tableName := mappingTable(mode, targets, mapping)
+		if findTarget(targets, tableName) == nil {
+			return persistenceNotAllowed("table %q is not a target", tableName)
+		}
+		if mode == PersistenceModeDirect && tableName != targets[0].TableName {
+			return persistenceNotAllowed("direct mode only writes to %s", targets[0].TableName)
+		}
+
+		table := PersistenceTables[tableName]
+		if table.ParentColumn != "" {
+			return persistenceNotAllowed("%s rows are only written as items linked to %s", tableName, table.ParentTable)
+		}
+		var ok bool
+		if columnType, ok = table.Columns[mapping.ColumnName]; !ok {
+			return persistenceNotAllowed("column %q of %s", mapping.ColumnName, tableName)
+		}
+		if mapping.ColumnName == table.UserColumn {
+			return persistenceNotAllowed("%s.%s is set by the submission", tableName, mapping.ColumnName)
+		}
+		if mapping.DataType != "" {
+			if dataType, _ := normalizeColumnType(mapping.DataType); dataType != columnType {
+				return persistenceNotAllowed("%s.%s is a %s column, not %q", tableName, mapping.ColumnName, columnType, mapping.DataType)
+			}
+		}
+	}
+
+	if mapping.Transform != nil {
+		if _, err := parseTransforms(*mapping.Transform); err != nil {
+			return persistenceNotAllowed("%v", err)
+		}
+	}
+	if mapping.IsEncrypted && columnType != ColumnTypeString {
+		return persistenceNotAllowed("only string columns can be encrypted")
+	}
+	return nil
+}
+
+// mappingTable is the table a mapping writes to. Mappings of a direct mode form may leave it
+// out to write to the form's table.
+func mappingTable(mode string, targets []TargetConfig, mapping FieldMapping) string {
+	if mapping.TableName == "" && mode == PersistenceModeDirect && len(targets) > 0 {
+		return targets[0].TableName
+	}
+	return mapping.TableName
+}
+
+func findTarget(targets []TargetConfig, tableName string) *TargetConfig {
+	for i := range targets {
+		if targets[i].TableName == tableName {
+			return &targets[i]
+		}
+	}
+	return nil
+}
+
+// persistFormData writes the mapped fields of a submission to the tables of its form's
+// persistence config. Rows are written in the order of their target's priority, so parents
+// exist before their group items, and a target with an upsert key updates the row it matches
+// instead of adding another.
+func persistFormData(ctx context.Context, q *Queries, config FormPersistenceConfig, userID uuid.UUID, data map[string]interface{}) error {
+	if config.PersistenceMode == PersistenceModeJSON {
+		return nil
+	}
+
+	targets, mappings, err := ParsePersistenceConfig(config)
+	if err != nil {
+		return err
+	}
+	if err := ValidatePersistenceConfig(config.PersistenceMode, targets, mappings); err != nil {
+		return err
+	}
+
+	if config.PersistenceMode == PersistenceModeDirect {
+		targets = targets[:1]
+	}
+	sort.SliceStable(targets, func(i, j int) bool { return targets[i].Priority < targets[j].Priority })
+
+	rows, err := buildFormRows(config.PersistenceMode, targets, mappings, data)
+	if err != nil {
+		return err
+	}
+
+	parentIDs := make(map[string]interface{})
+	for _, target := range targets {
+		row := rows[target.TableName]
+		if len(row) == 0 {
+			continue
+		}
+		if userColumn := PersistenceTables[target.TableName].UserColumn; userColumn != "" {
+			row[userColumn] = userID
+		}
+
+		id, err := upsertFormRow(ctx, q, target.TableName, target.UpsertKey, row)
+		if err != nil {
+			return fmt.Errorf("failed to persist %s: %w", target.TableName, err)
+		}
+		parentIDs[target.TableName] = id
+	}
+
+	if err := persistUserMeta(ctx, q, userID, mappings, data); err != nil {
+		return err
+	}
+	return persistGroupItems(ctx, q, userID, targets, mappings, data, parentIDs)
+}
+
+// buildFormRows collects the values of mapped fields by table and column, transformed, coerced
+// to their column's type and encrypted where the mapping asks for it
+func buildFormRows(mode string, targets []TargetConfig, mappings map[string]FieldMapping, data map[string]interface{}) (map[string]map[string]interface{}, error) {
+	rows := make(map[string]map[string]interface{})
+	for formField, mapping := range mappings {
+		if mapping.isGroupMapping() || mapping.MetaKey != "" {
+			continue
+		}
+		tableName := mappingTable(mode, targets, mapping)
+		if findTarget(targets, tableName) == nil {
+			continue
+		}
+		value, ok := data[formField]
+		if !ok {
+			continue
+		}
+
+		value, err := formColumnValue(mapping, PersistenceTables[tableName].Columns[mapping.ColumnName], value)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", formField, err)
+		}
+		if rows[tableName] == nil {
+			rows[tableName] = make(map[string]interface{})
+		}
+		rows[tableName][mapping.ColumnName] = value
+	}
+	return rows, nil
+}
+
+// formColumnValue applies a mapping's transforms, type and encryption to a value
+func formColumnValue(mapping FieldMapping, columnType string, value interface{}) (interface{}, error) {
+	var err error
+	if mapping.Transform != nil {
+		if value, err = applyTransforms(*mapping.Transform, value); err != nil {
+			return nil, err
+		}
+	}
+	if value, err = coerceValue(columnType, value); err != nil {
+		return nil, err
+	}
+	if mapping.IsEncrypted && value != nil {
+		return EncryptFieldValue(value.(string))
+	}
+	return value, nil
+}
+
+// persistUserMeta writes the fields mapped to a MetaKey as user meta
+func persistUserMeta(ctx context.Context, q *Queries, userID uuid.UUID, mappings map[string]FieldMapping, data map[string]interface{}) error {
+	for formField, mapping := range mappings {
+		if mapping.MetaKey == "" || mapping.isGroupMapping() {
+			continue
+		}
+		value, ok := data[formField]
+		if !ok {
+			continue
+		}
+
+		columnType, datatype := ColumnTypeString, DatatypeString
+		if dataType, _ := normalizeColumnType(mapping.DataType); dataType == ColumnTypeBoolean {
+			columnType, datatype = ColumnTypeBoolean, DatatypeBoolean
+		}
+		value, err := formColumnValue(mapping, columnType, value)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", formField, err)
+		}
+		if value == nil {
+			continue
+		}
+
+		if err := q.SetUserMeta(ctx, UserMetaCreateParams{
+			UserID:   userID,
+			Key:      mapping.MetaKey,
+			Value:    fmt.Sprint(value),
+			Datatype: datatype,
+		}); err != nil {
+			return fmt.Errorf("failed to persist %s: %w", formField, err)
+		}
+	}
+	return nil
+}
+
+// persistGroupItems writes each item of a group field as a row of its mapping's table, linked to
+// the parent row written for the user. When the table is a target with an upsert key, items
+// matching a row update it.
+func persistGroupItems(ctx context.Context, q *Queries, userID uuid.UUID, targets []TargetConfig, mappings map[string]FieldMapping, data map[string]interface{}, parentIDs map[string]interface{}) error {
+	for formField, mapping := range mappings {
+		if !mapping.isGroupMapping() {
+			continue
+		}
+
+		items, ok := data[formField].([]interface{})
+		if !ok {
+			continue
+		}
+
+		var parentID interface{}
+		if mapping.ParentColumn != "" {
+			if parentID, ok = parentIDs[mapping.ParentTable]; !ok {
+				return fmt.Errorf("no %s row to link the items of %s to", mapping.ParentTable, formField)
+			}
+		}
+
+		var upsertKey []string
+		if target := findTarget(targets, mapping.TableName); target != nil {
+			upsertKey = target.UpsertKey
+		}
+		table := PersistenceTables[mapping.TableName]
+		columns := table.Columns
+
+		for i, entry := range items {
+			item, ok := entry.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			row := make(map[string]interface{})
+			for itemField, column := range mapping.ItemMappings {
+				value, ok := item[itemField]
+				if !ok {
+					continue
+				}
+				value, err := coerceValue(columns[column], value)
+				if err != nil {
+					return fmt.Errorf("field %s[%d].%s: %w", formField, i, itemField, err)
+				}
+				row[column] = value
+			}
+			if len(row) == 0 {
+				continue
+			}
+			if mapping.ParentColumn != "" {
+				row[mapping.ParentColumn] = parentID
+			}
+			if table.UserColumn != "" {
+				row[table.UserColumn] = userID
+			}
+
+			if _, err := upsertFormRow(ctx, q, mapping.TableName, upsertKey, row); err != nil {
+				return fmt.Errorf("failed to persist items of %s: %w", formField, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// upsertFormRow writes a row and returns its id. With an upsert key, a row whose key columns
+// hold the same values is updated instead, atomically through the key's unique index.
+func upsertFormRow(ctx context.Context, q *Queries, table string, key []string, row map[string]interface{}) (interface{}, error) {
+	query, args, err := buildFormUpsert(table, key, row)
+	if err != nil {
+		return nil, err
+	}
+
+	var id interface{}
+	err = q.db.QueryRowContext(ctx, query, args...).Scan(&id)
+	return id, err
+}
+
+// sortedFormColumns returns the columns of a row in name order, checked against the allow-list
+// so they can be written into SQL
+func sortedFormColumns(table string, row map[string]interface{}) ([]string, error) {
+	allowed, ok := PersistenceTables[table]
+	if !ok {
+		return nil, persistenceNotAllowed("table %q", table)
+	}
+
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		if _, ok := allowed.Columns[column]; !ok {
+			return nil, persistenceNotAllowed("column %q of %s", column, table)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns, nil
+}
+
+func buildFormInsert(table string, row map[string]interface{}) (string, []interface{}, error) {
+	columns, err := sortedFormColumns(table, row)
+	if err != nil {
+		return "", nil, err
+	}
+
+	placeholders := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+	for i, column := range columns {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = row[column]
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING id",
+		table, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	return query, args, nil
+}
+
+// buildFormUpsert inserts a row, updating the columns other than its key when a row with the
+// same key exists. Without a key it is a plain insert.
+func buildFormUpsert(table string, key []string, row map[string]interface{}) (string, []interface{}, error) {
+	if len(key) == 0 {
+		return buildFormInsert(table, row)
+	}
+
+	for _, column := range key {
+		if value, ok := row[column]; !ok || value == nil {
+			return "", nil, fmt.Errorf("upsert key %s of %s has no value", column, table)
+		}
+	}
+	keyColumns, err := sortedFormColumns(table, keyRow(key, row))
+	if err != nil {
+		return "", nil, err
+	}
+
+	query, args, err := buildFormInsert(table, row)
+	if err != nil {
+		return "", nil, err
+	}
+
+	columns, _ := sortedFormColumns(table, row)
+	var assignments []string
+	for _, column := range columns {
+		if !containsColumn(keyColumns, column) {
+			assignments = append(assignments, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+		}
+	}
+	// A row that only holds its key is still updated, so its id is returned
+	assignments = append(assignments, "updated_at = now()")
+
+	query = strings.TrimSuffix(query, " RETURNING id")
+	query += fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s RETURNING id",
+		strings.Join(keyColumns, ", "), strings.Join(assignments, ", "))
+	return query, args, nil
+}
+
+func keyRow(key []string, row map[string]interface{}) map[string]interface{} {
+	values := make(map[string]interface{}, len(key))
+	for _, column := range key {
+		values[column] = row[column]
+	}
+	return values
+}
@@ -0,0 +1,250 @@
+package db
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyTransforms(t *testing.T) {
+	for spec, cases := range map[string]map[interface{}]interface{}{
+		"trim,uppercase": {"  rc 12345 ": "RC 12345", "": ""},
+		"date":           {"2021-03-04": "2021-03-04", "04/03/2021": "2021-03-04", "4 March 2021": "2021-03-04"},
+		"date:Jan 2006":  {"Mar 2021": "2021-03-01"},
+		"phone_e164:234": {"0803 123 4567": "+2348031234567", "+44 20 7946 0958": "+442079460958", "00234-803-123-4567": "+2348031234567"},
+	} {
+		for value, expected := range cases {
+			transformed, err := applyTransforms(spec, value)
+			assert.NoError(t, err, spec)
+			assert.Equal(t, expected, transformed, spec)
+		}
+	}
+
+	for spec, value := range map[string]interface{}{
+		"date":           "31/31/2021",
+		"phone_e164":     "0803 123 4567",
+		"phone_e164:234": "call me",
+		"reverse":        "abc",
+	} {
+		_, err := applyTransforms(spec, value)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestCoerceValue(t *testing.T) {
+	id := uuid.New()
+	for _, c := range []struct {
+		columnType string
+		value      interface{}
+		expected   interface{}
+	}{
+		{ColumnTypeString, 42.0, "42"},
+		{ColumnTypeString, "", ""},
+		{ColumnTypeInteger, 25.0, int64(25)},
+		{ColumnTypeInteger, " 25 ", int64(25)},
+		{ColumnTypeInteger, "", nil},
+		{ColumnTypeDecimal, "1234567890.123456789", "1234567890.123456789"},
+		{ColumnTypeBoolean, "yes", true},
+		{ColumnTypeBoolean, 0.0, false},
+		{ColumnTypeDate, "04/03/2021", "2021-03-04"},
+		{ColumnTypeUUID, id.String(), id},
+		{ColumnTypeJSON, []interface{}{"NG", "GH"}, `["NG","GH"]`},
+	} {
+		value, err := coerceValue(c.columnType, c.value)
+		assert.NoError(t, err, c.columnType)
+		assert.Equal(t, c.expected, value, c.columnType)
+	}
+
+	for columnType, value := range map[string]interface{}{
+		ColumnTypeInteger: 2.5,
+		ColumnTypeDecimal: "lots",
+		ColumnTypeBoolean: "maybe",
+		ColumnTypeUUID:    "not-a-uuid",
+		ColumnTypeString:  map[string]interface{}{},
+	} {
+		_, err := coerceValue(columnType, value)
+		assert.Error(t, err, columnType)
+	}
+}
+
+func TestFieldEncryption(t *testing.T) {
+	assert.NoError(t, LoadFieldEncryptionKeys("old:"+testKey('a')+", new:"+testKey('b')))
+
+	encrypted, err := EncryptFieldValue("A01234567")
+	assert.NoError(t, err)
+	assert.True(t, IsEncryptedFieldValue(encrypted))
+	assert.Contains(t, encrypted, "enc:v1:new:")
+	assert.NotContains(t, encrypted, "A01234567")
+
+	decrypted, err := DecryptFieldValue(encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "A01234567", decrypted)
+
+	// Values written with earlier keys still decrypt
+	assert.NoError(t, SetFieldEncryptionKey("newer", bytes.Repeat([]byte{'c'}, 32)))
+	decrypted, err = DecryptFieldValue(encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "A01234567", decrypted)
+
+	_, err = DecryptFieldValue(encrypted[:len(encrypted)-4] + "AAAA")
+	assert.ErrorIs(t, err, ErrInvalidEncryptedValue)
+	_, err = DecryptFieldValue("enc:v1:gone:AAAA:AAAA")
+	assert.ErrorIs(t, err, ErrNoFieldEncryptionKey)
+	assert.Error(t, LoadFieldEncryptionKeys("short:"+"c2hvcnQ="))
+}
+
+func TestSealSubmissionAnswers(t *testing.T) {
+	assert.NoError(t, LoadFieldEncryptionKeys("seal:"+testKey('s')))
+	fields := map[string]bool{"id_number": true, "holding": true, "notes": true}
+	data := map[string]interface{}{"id_number": "A01234567", "holding": 25.5, "name": "Ada", "notes": nil}
+
+	sealed, err := sealAnswers(fields, data)
+	assert.NoError(t, err)
+	assert.Equal(t, "Ada", sealed["name"])
+	assert.Nil(t, sealed["notes"])
+	assert.True(t, IsEncryptedFieldValue(sealed["id_number"].(string)))
+	assert.True(t, IsEncryptedFieldValue(sealed["holding"].(string)))
+	assert.Equal(t, "A01234567", data["id_number"], "the answers passed in are left as they are")
+	assert.True(t, hasPlaintextAnswers(fields, data))
+	assert.False(t, hasPlaintextAnswers(fields, sealed))
+
+	// Sealing again keeps the encrypted answers
+	resealed, err := sealAnswers(fields, sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, sealed, resealed)
+
+	raw, err := json.Marshal(sealed)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(raw), "A01234567")
+	assert.JSONEq(t, `{"id_number":"A01234567","holding":25.5,"name":"Ada","notes":null}`, string(openSubmissionData(raw)))
+
+	plain := json.RawMessage(`{"name":"Ada"}`)
+	assert.Equal(t, plain, openSubmissionData(plain))
+}
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func TestBuildFormStatements(t *testing.T) {
+	row := map[string]interface{}{"name": "Acme", "registration_number": "RC123", "created_by": "u"}
+
+	query, args, err := buildFormInsert("businesses", row)
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO businesses (created_by, name, registration_number) VALUES ($1, $2, $3) RETURNING id", query)
+	assert.Equal(t, []interface{}{"u", "Acme", "RC123"}, args)
+
+	query, args, err = buildFormUpsert("businesses", []string{"created_by", "registration_number"}, row)
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO businesses (created_by, name, registration_number) VALUES ($1, $2, $3) "+
+		"ON CONFLICT (created_by, registration_number) DO UPDATE SET name = EXCLUDED.name, updated_at = now() RETURNING id", query)
+	assert.Equal(t, []interface{}{"u", "Acme", "RC123"}, args)
+
+	query, _, err = buildFormUpsert("businesses", []string{"created_by"}, map[string]interface{}{"created_by": "u"})
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO businesses (created_by) VALUES ($1) ON CONFLICT (created_by) DO UPDATE SET updated_at = now() RETURNING id", query)
+
+	query, _, err = buildFormUpsert("businesses", nil, row)
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO businesses (created_by, name, registration_number) VALUES ($1, $2, $3) RETURNING id", query)
+
+	_, _, err = buildFormUpsert("businesses", []string{"registration_number"}, map[string]interface{}{"name": "Acme"})
+	assert.Error(t, err)
+
+	for table, row := range map[string]map[string]interface{}{
+		"users":      {"email": "x"},
+		"businesses": {"name) VALUES ('x'); DROP TABLE businesses; --": "x"},
+	} {
+		_, _, err := buildFormInsert(table, row)
+		assert.ErrorIs(t, err, ErrPersistenceNotAllowed)
+	}
+}
+
+func TestValidatePersistenceConfig(t *testing.T) {
+	transform := "trim,date"
+	targets := []TargetConfig{
+		{TableName: "businesses", Priority: 1, UpsertKey: []string{"created_by"}},
+		{TableName: "business_owners", Priority: 2},
+	}
+	mappings := map[string]FieldMapping{
+		"company_name":       {TableName: "businesses", ColumnName: "name", DataType: "varchar"},
+		"incorporation_date": {TableName: "businesses", ColumnName: "registration_date", Transform: &transform},
+		"tax_id":             {TableName: "businesses", ColumnName: "registration_number", IsEncrypted: true},
+		"pep":                {MetaKey: "is_pep", DataType: "bool"},
+		"directors": {
+			TableName:    "business_owners",
+			ItemMappings: map[string]string{"first_name": "first_name", "holding": "holding_ratio"},
+			ParentTable:  "businesses",
+			ParentColumn: "business_id",
+		},
+	}
+	assert.NoError(t, ValidatePersistenceConfig(PersistenceModeMultiTable, targets, mappings))
+	assert.NoError(t, ValidatePersistenceConfig(PersistenceModeJSON, nil, nil))
+
+	unknown := "rot13"
+	for name, mapping := range map[string]FieldMapping{
+		"table":     {TableName: "users", ColumnName: "email"},
+		"column":    {TableName: "businesses", ColumnName: "approval_status"},
+		"type":      {TableName: "businesses", ColumnName: "name", DataType: "integer"},
+		"transform": {TableName: "businesses", ColumnName: "name", Transform: &unknown},
+		"encrypted": {TableName: "business_owners", ColumnName: "is_ubo", IsEncrypted: true},
+		"item":      {TableName: "business_owners", ItemMappings: map[string]string{"x": "password"}},
+		"unlinked":  {TableName: "business_owners", ItemMappings: map[string]string{"x": "first_name"}},
+		"owner item": {
+			TableName:    "business_owners",
+			ItemMappings: map[string]string{"x": "business_id"},
+			ParentTable:  "businesses",
+			ParentColumn: "business_id",
+		},
+		"owner": {TableName: "business_owners", ColumnName: "business_id"},
+		"user":  {TableName: "businesses", ColumnName: "created_by"},
+		"meta":  {MetaKey: "age", DataType: "integer"},
+	} {
+		err := ValidatePersistenceConfig(PersistenceModeMultiTable, targets, map[string]FieldMapping{"field": mapping})
+		assert.ErrorIs(t, err, ErrPersistenceNotAllowed, name)
+	}
+
+	err := ValidatePersistenceConfig(PersistenceModeDirect, targets, map[string]FieldMapping{
+		"first_name": {TableName: "business_owners", ColumnName: "first_name"},
+	})
+	assert.ErrorIs(t, err, ErrPersistenceNotAllowed)
+
+	err = ValidatePersistenceConfig(PersistenceModeMultiTable, []TargetConfig{{TableName: "businesses", UpsertKey: []string{"id; --"}}}, nil)
+	assert.ErrorIs(t, err, ErrPersistenceNotAllowed)
+
+	// Upsert keys must be unique keys, and include the user column so a submission can't
+	// update the rows of other users
+	for _, key := range [][]string{{"registration_number"}, {"name", "created_by"}, {"first_name"}} {
+		table := "businesses"
+		if key[0] == "first_name" {
+			table = "business_owners"
+		}
+		err = ValidatePersistenceConfig(PersistenceModeMultiTable, []TargetConfig{{TableName: table, UpsertKey: key}}, nil)
+		assert.ErrorIs(t, err, ErrPersistenceNotAllowed, key)
+	}
+	err = ValidatePersistenceConfig(PersistenceModeMultiTable, []TargetConfig{
+		{TableName: "businesses", UpsertKey: []string{"registration_number", "created_by"}},
+		{TableName: "business_owners", UpsertKey: []string{"id_number", "business_id"}},
+	}, nil)
+	assert.NoError(t, err)
+}
+
+// Every unique key upserts rely on needs its index in the migration
+func TestPersistenceUniqueKeysHaveIndexes(t *testing.T) {
+	ddl, err := os.ReadFile("../migration/20261018091000_persistence_unique_keys.up.sql")
+	assert.NoError(t, err)
+
+	for name, table := range PersistenceTables {
+		for _, key := range table.UniqueKeys {
+			index := fmt.Sprintf("ON %s (%s)", name, strings.Join(key, ", "))
+			assert.Contains(t, string(ddl), index, name)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// Column types of persisted form data. Field mappings may also use the SQL names in
+// columnTypeAliases.
+const (
+	ColumnTypeString    = "string"
+	ColumnTypeInteger   = "integer"
+	ColumnTypeDecimal   = "decimal"
+	ColumnTypeBoolean   = "boolean"
+	ColumnTypeDate      = "date"
+	ColumnTypeTimestamp = "timestamp"
+	ColumnTypeUUID      = "uuid"
+	ColumnTypeJSON      = "json"
+)
+
+var columnTypeAliases = map[string]string{
+	"varchar":     ColumnTypeString,
+	"text":        ColumnTypeString,
+	"char":        ColumnTypeString,
+	"int":         ColumnTypeInteger,
+	"smallint":    ColumnTypeInteger,
+	"bigint":      ColumnTypeInteger,
+	"numeric":     ColumnTypeDecimal,
+	"float":       ColumnTypeDecimal,
+	"double":      ColumnTypeDecimal,
+	"bool":        ColumnTypeBoolean,
+	"timestamptz": ColumnTypeTimestamp,
+	"datetime":    ColumnTypeTimestamp,
+	"jsonb":       ColumnTypeJSON,
+}
+
+// normalizeColumnType resolves a data type name, or its alias, to a ColumnType constant
+func normalizeColumnType(dataType string) (string, bool) {
+	dataType = strings.ToLower(strings.TrimSpace(dataType))
+	if alias, ok := columnTypeAliases[dataType]; ok {
+		return alias, true
+	}
+	switch dataType {
+	case ColumnTypeString, ColumnTypeInteger, ColumnTypeDecimal, ColumnTypeBoolean,
+		ColumnTypeDate, ColumnTypeTimestamp, ColumnTypeUUID, ColumnTypeJSON:
+		return dataType, true
+	}
+	return "", false
+}
+
+// dateLayouts are the date formats the date transform and date columns accept. Day comes
+// before month, as users write it.
+var dateLayouts = []string{
+	"2006-01-02",
+	time.RFC3339,
+	"02/01/2006",
+	"2/1/2006",
+	"02-01-2006",
+	"02.01.2006",
+	"2 January 2006",
+	"2 Jan 2006",
+	"January 2, 2006",
+	"Jan 2, 2006",
+}
+
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// PersistenceTransforms are the named transforms a field mapping may apply to a value before it
+// is written. Mappings chain them with commas, and pass an argument after a colon, as in
+// "trim,phone_e164:234".
+var PersistenceTransforms = map[string]func(value, arg string) (string, error){
+	"trim":       func(value, _ string) (string, error) { return strings.TrimSpace(value), nil },
+	"uppercase":  func(value, _ string) (string, error) { return strings.ToUpper(value), nil },
+	"lowercase":  func(value, _ string) (string, error) { return strings.ToLower(value), nil },
+	"date":       transformDate,
+	"phone_e164": transformPhoneE164,
+}
+
+// parseTransforms splits a transform spec into its names and arguments
+func parseTransforms(spec string) ([][2]string, error) {
+	var transforms [][2]string
+	for _, part := range strings.Split(spec, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(part), ":")
+		if name == "" {
+			continue
+		}
+		if _, ok := PersistenceTransforms[name]; !ok {
+			return nil, fmt.Errorf("unknown transform %q", name)
+		}
+		transforms = append(transforms, [2]string{name, arg})
+	}
+	return transforms, nil
+}
+
+// applyTransforms runs the transforms of a spec over a value. Empty values are left alone, so
+// optional fields stay empty.
+func applyTransforms(spec string, value interface{}) (interface{}, error) {
+	if value == nil || spec == "" {
+		return value, nil
+	}
+
+	transforms, err := parseTransforms(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	s, ok := scalarString(value)
+	if !ok {
+		return nil, fmt.Errorf("can't transform a %T", value)
+	}
+	if s == "" {
+		return value, nil
+	}
+	for _, t := range transforms {
+		if s, err = PersistenceTransforms[t[0]](s, t[1]); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// transformDate normalizes a date to YYYY-MM-DD. The argument is a Go layout to parse with
+// instead of the usual formats.
+func transformDate(value, layout string) (string, error) {
+	layouts := dateLayouts
+	if layout != "" {
+		layouts = []string{layout}
+	}
+	t, err := parseTime(strings.TrimSpace(value), layouts)
+	if err != nil {
+		return "", err
+	}
+	return t.Format("2006-01-02"), nil
+}
+
+// transformPhoneE164 formats a phone number as +<country code><number>. National numbers,
+// written with or without their trunk 0, take the country calling code given as argument.
+func transformPhoneE164(value, countryCode string) (string, error) {
+	value = strings.TrimSpace(value)
+	international := strings.HasPrefix(value, "+") || strings.HasPrefix(value, "00")
+
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		if strings.ContainsRune(" -().+/", r) {
+			return -1
+		}
+		return 'x'
+	}, value)
+	if strings.ContainsRune(digits, 'x') {
+		return "", fmt.Errorf("%q is not a phone number", value)
+	}
+
+	switch {
+	case strings.HasPrefix(value, "00"):
+		digits = strings.TrimPrefix(digits, "00")
+	case !international:
+		if countryCode == "" {
+			return "", fmt.Errorf("%q has no country code", value)
+		}
+		digits = strings.TrimPrefix(countryCode, "+") + strings.TrimPrefix(digits, "0")
+	}
+
+	// E.164 numbers have at most 15 digits; the shortest in use have 8
+	if len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+		return "", fmt.Errorf("%q is not a valid phone number", value)
+	}
+	return "+" + digits, nil
+}
+
+func parseTime(value string, layouts []string) (time.Time, error) {
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date", value)
+}
+
+// scalarString formats a JSON scalar as text
+func scalarString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case json.Number:
+		return v.String(), true
+	case int, int32, int64:
+		return fmt.Sprint(v), true
+	default:
+		return "", false
+	}
+}
+
+// coerceValue converts a submitted value to the Go type written to a column of columnType.
+// Empty strings are written as NULL to every type but strings.
+func coerceValue(columnType string, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if s, ok := value.(string); ok && columnType != ColumnTypeString {
+		if value = strings.TrimSpace(s); value == "" {
+			return nil, nil
+		}
+	}
+
+	switch columnType {
+	case ColumnTypeString:
+		if s, ok := scalarString(value); ok {
+			return s, nil
+		}
+
+	case ColumnTypeInteger:
+		switch v := value.(type) {
+		case float64:
+			if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+				return int64(v), nil
+			}
+		case string:
+			if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return i, nil
+			}
+		}
+
+	case ColumnTypeDecimal:
+		// Written as text so numeric columns keep every digit
+		switch v := value.(type) {
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case string:
+			if _, err := strconv.ParseFloat(v, 64); err == nil {
+				return v, nil
+			}
+		}
+
+	case ColumnTypeBoolean:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case float64:
+			if v == 0 || v == 1 {
+				return v == 1, nil
+			}
+		case string:
+			switch strings.ToLower(v) {
+			case "true", "yes", "on", "1":
+				return true, nil
+			case "false", "no", "off", "0":
+				return false, nil
+			}
+		}
+
+	case ColumnTypeDate:
+		if s, ok := value.(string); ok {
+			t, err := parseTime(s, dateLayouts)
+			if err != nil {
+				return nil, err
+			}
+			return t.Format("2006-01-02"), nil
+		}
+
+	case ColumnTypeTimestamp:
+		if s, ok := value.(string); ok {
+			return parseTime(s, timestampLayouts)
+		}
+
+	case ColumnTypeUUID:
+		switch v := value.(type) {
+		case uuid.UUID:
+			return v, nil
+		case string:
+			if id, err := uuid.Parse(v); err == nil {
+				return id, nil
+			}
+		}
+
+	case ColumnTypeJSON:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}
+
+	return nil, fmt.Errorf("%v is not a valid %s", value, columnType)
+}
@@ -0,0 +1,350 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	PublicSubmissionStatusPending  = "pending_verification"
+	PublicSubmissionStatusVerified = "verified"
+	// PublicSubmissionStatusUnverified is a submission of a form that doesn't verify emails. Its
+	// email was never proven, so it is never linked to a user.
+	PublicSubmissionStatusUnverified = "unverified"
+)
+
+var (
+	ErrChallengeUsed            = errors.New("challenge has already been used")
+	ErrInvalidVerificationToken = errors.New("verification link is invalid or has expired")
+	ErrFormPublicAccessNotFound = errors.New("form is not public")
+)
+
+// FormPublicAccess lets anonymous visitors submit a form and sets the protections their
+// submissions go through. Limits of 0 are unlimited; a ChallengeDifficulty of 0 only checks the
+// signature of the challenge.
+type FormPublicAccess struct {
+	FormDefinitionID         uuid.UUID      `json:"form_definition_id"`
+	Enabled                  bool           `json:"enabled"`
+	EmailField               string         `json:"email_field"`
+	RequireEmailVerification bool           `json:"require_email_verification"`
+	BlockDisposableEmails    bool           `json:"block_disposable_emails"`
+	BlockedEmailDomains      pq.StringArray `json:"blocked_email_domains"`
+	HoneypotField            string         `json:"honeypot_field"`
+	ChallengeDifficulty      int32          `json:"challenge_difficulty"`
+	HourlyLimit              int32          `json:"hourly_limit"`
+	HourlyLimitPerIP         int32          `json:"hourly_limit_per_ip"`
+	UpdatedBy                uuid.NullUUID  `json:"updated_by"`
+	CreatedAt                time.Time      `json:"created_at"`
+	UpdatedAt                time.Time      `json:"updated_at"`
+}
+
+type UpsertFormPublicAccessParams struct {
+	FormDefinitionID         uuid.UUID `json:"form_definition_id"`
+	Enabled                  bool      `json:"enabled"`
+	EmailField               string    `json:"email_field"`
+	RequireEmailVerification bool      `json:"require_email_verification"`
+	BlockDisposableEmails    bool      `json:"block_disposable_emails"`
+	BlockedEmailDomains      []string  `json:"blocked_email_domains"`
+	HoneypotField            string    `json:"honeypot_field"`
+	ChallengeDifficulty      int32     `json:"challenge_difficulty"`
+	HourlyLimit              int32     `json:"hourly_limit"`
+	HourlyLimitPerIP         int32     `json:"hourly_limit_per_ip"`
+	UpdatedBy                uuid.UUID `json:"updated_by"`
+}
+
+// PublicFormSubmission is a submission of a public form by an anonymous visitor. It counts once
+// its email is verified, and belongs to the user with that email once they have signed up and
+// activated their account.
+type PublicFormSubmission struct {
+	ID                    uuid.UUID       `json:"id"`
+	FormDefinitionID      uuid.UUID       `json:"form_definition_id"`
+	FormVersion           int32           `json:"form_version"`
+	SubmissionData        json.RawMessage `json:"submission_data"`
+	Email                 string          `json:"email"`
+	Status                string          `json:"status"`
+	ChallengeID           string          `json:"-"`
+	IPAddress             string          `json:"ip_address"`
+	UserAgent             string          `json:"user_agent"`
+	VerificationTokenHash []byte          `json:"-"`
+	VerificationExpiresAt sql.NullTime    `json:"verification_expires_at"`
+	VerifiedAt            sql.NullTime    `json:"verified_at"`
+	UserID                uuid.NullUUID   `json:"user_id"`
+	LinkedAt              sql.NullTime    `json:"linked_at"`
+	CreatedAt             time.Time       `json:"created_at"`
+}
+
+type CreatePublicFormSubmissionParams struct {
+	FormDefinitionID uuid.UUID       `json:"form_definition_id"`
+	FormVersion      int32           `json:"form_version"`
+	SubmissionData   json.RawMessage `json:"submission_data"`
+	Email            string          `json:"email"`
+	// ChallengeID is unique, so a challenge can only be used by one submission
+	ChallengeID string `json:"challenge_id"`
+	IPAddress   string `json:"ip_address"`
+	UserAgent   string `json:"user_agent"`
+	// Without a verification token the submission is unverified and never linked to a user
+	VerificationTokenHash []byte    `json:"-"`
+	VerificationExpiresAt time.Time `json:"verification_expires_at"`
+}
+
+type ListPublicFormSubmissionsParams struct {
+	FormDefinitionID uuid.UUID `json:"form_definition_id"`
+	Status           string    `json:"status"` // all statuses when empty
+	Limit            int32     `json:"limit"`
+	Offset           int32     `json:"offset"`
+}
+
+const formPublicAccessColumns = `form_definition_id, enabled, email_field, require_email_verification, block_disposable_emails,
+    blocked_email_domains, honeypot_field, challenge_difficulty, hourly_limit, hourly_limit_per_ip, updated_by, created_at, updated_at`
+
+func scanFormPublicAccess(row interface{ Scan(...interface{}) error }) (FormPublicAccess, error) {
+	var a FormPublicAccess
+	err := row.Scan(
+		&a.FormDefinitionID,
+		&a.Enabled,
+		&a.EmailField,
+		&a.RequireEmailVerification,
+		&a.BlockDisposableEmails,
+		&a.BlockedEmailDomains,
+		&a.HoneypotField,
+		&a.ChallengeDifficulty,
+		&a.HourlyLimit,
+		&a.HourlyLimitPerIP,
+		&a.UpdatedBy,
+		&a.CreatedAt,
+		&a.UpdatedAt,
+	)
+	return a, err
+}
+
+// GetFormPublicAccess returns the public access settings of a form, ErrFormPublicAccessNotFound
+// when it has never been made public
+func (q *Queries) GetFormPublicAccess(ctx context.Context, formID uuid.UUID) (FormPublicAccess, error) {
+	a, err := scanFormPublicAccess(q.db.QueryRowContext(ctx, `SELECT `+formPublicAccessColumns+`
+FROM form_public_access WHERE form_definition_id = $1`, formID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return a, ErrFormPublicAccessNotFound
+	}
+	return a, err
+}
+
+// UpsertFormPublicAccess creates or replaces the public access settings of a form
+func (q *Queries) UpsertFormPublicAccess(ctx context.Context, arg UpsertFormPublicAccessParams) (FormPublicAccess, error) {
+	return scanFormPublicAccess(q.db.QueryRowContext(ctx, `INSERT INTO form_public_access (
+    form_definition_id, enabled, email_field, require_email_verification, block_disposable_emails,
+    blocked_email_domains, honeypot_field, challenge_difficulty, hourly_limit, hourly_limit_per_ip, updated_by
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+ON CONFLICT (form_definition_id) DO UPDATE SET
+    enabled = EXCLUDED.enabled,
+    email_field = EXCLUDED.email_field,
+    require_email_verification = EXCLUDED.require_email_verification,
+    block_disposable_emails = EXCLUDED.block_disposable_emails,
+    blocked_email_domains = EXCLUDED.blocked_email_domains,
+    honeypot_field = EXCLUDED.honeypot_field,
+    challenge_difficulty = EXCLUDED.challenge_difficulty,
+    hourly_limit = EXCLUDED.hourly_limit,
+    hourly_limit_per_ip = EXCLUDED.hourly_limit_per_ip,
+    updated_by = EXCLUDED.updated_by,
+    updated_at = now()
+RETURNING `+formPublicAccessColumns,
+		arg.FormDefinitionID,
+		arg.Enabled,
+		arg.EmailField,
+		arg.RequireEmailVerification,
+		arg.BlockDisposableEmails,
+		pq.StringArray(arg.BlockedEmailDomains),
+		arg.HoneypotField,
+		arg.ChallengeDifficulty,
+		arg.HourlyLimit,
+		arg.HourlyLimitPerIP,
+		NewNullUUID(arg.UpdatedBy),
+	))
+}
+
+const publicFormSubmissionColumns = `id, form_definition_id, form_version, submission_data, email, status, challenge_id, ip_address,
+    user_agent, verification_token_hash, verification_expires_at, verified_at, user_id, linked_at, created_at`
+
+func scanPublicFormSubmission(row interface{ Scan(...interface{}) error }) (PublicFormSubmission, error) {
+	var s PublicFormSubmission
+	err := row.Scan(
+		&s.ID,
+		&s.FormDefinitionID,
+		&s.FormVersion,
+		&s.SubmissionData,
+		&s.Email,
+		&s.Status,
+		&s.ChallengeID,
+		&s.IPAddress,
+		&s.UserAgent,
+		&s.VerificationTokenHash,
+		&s.VerificationExpiresAt,
+		&s.VerifiedAt,
+		&s.UserID,
+		&s.LinkedAt,
+		&s.CreatedAt,
+	)
+	s.SubmissionData = openSubmissionData(s.SubmissionData)
+	return s, err
+}
+
+// CreatePublicFormSubmission saves an anonymous submission, ErrChallengeUsed when its challenge
+// was used before. Submissions without a verification token are unverified.
+func (q *Queries) CreatePublicFormSubmission(ctx context.Context, arg CreatePublicFormSubmissionParams) (PublicFormSubmission, error) {
+	status := PublicSubmissionStatusUnverified
+	expiresAt := sql.NullTime{}
+	if len(arg.VerificationTokenHash) > 0 {
+		status = PublicSubmissionStatusPending
+		expiresAt = NewNullTime(arg.VerificationExpiresAt)
+	}
+	data, err := sealSubmissionJSON(ctx, q, arg.FormDefinitionID, arg.SubmissionData)
+	if err != nil {
+		return PublicFormSubmission{}, err
+	}
+
+	s, err := scanPublicFormSubmission(q.db.QueryRowContext(ctx, `INSERT INTO public_form_submissions (
+    id, form_definition_id, form_version, submission_data, email, status, challenge_id, ip_address,
+    user_agent, verification_token_hash, verification_expires_at
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+ON CONFLICT (challenge_id) DO NOTHING
+RETURNING `+publicFormSubmissionColumns,
+		uuid.New(),
+		arg.FormDefinitionID,
+		arg.FormVersion,
+		data,
+		arg.Email,
+		status,
+		arg.ChallengeID,
+		arg.IPAddress,
+		arg.UserAgent,
+		arg.VerificationTokenHash,
+		expiresAt,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, ErrChallengeUsed
+	}
+	return s, err
+}
+
+// CreatePublicFormSubmissionTx saves an anonymous submission and the form events it fires
+func (store *SQLStore) CreatePublicFormSubmissionTx(ctx context.Context, arg CreatePublicFormSubmissionParams, events FormEventsFunc[PublicFormSubmission]) (PublicFormSubmission, error) {
+	var submission PublicFormSubmission
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		submission, err = q.CreatePublicFormSubmission(ctx, arg)
+		if err != nil {
+			return err
+		}
+		return enqueueFormEvents(ctx, q, events, submission)
+	})
+
+	return submission, err
+}
+
+// GetPublicFormSubmission returns a public submission by ID
+func (q *Queries) GetPublicFormSubmission(ctx context.Context, id uuid.UUID) (PublicFormSubmission, error) {
+	return scanPublicFormSubmission(q.db.QueryRowContext(ctx, `SELECT `+publicFormSubmissionColumns+`
+FROM public_form_submissions
+WHERE id = $1`, id))
+}
+
+// CountPublicFormSubmissions counts the submissions of a form made since a time, from one IP
+// address when ipAddress is set
+func (q *Queries) CountPublicFormSubmissions(ctx context.Context, formID uuid.UUID, ipAddress string, since time.Time) (int64, error) {
+	var count int64
+	err := q.db.QueryRowContext(ctx, `SELECT count(*) FROM public_form_submissions
+WHERE form_definition_id = $1
+  AND ($2::text = '' OR ip_address = $2::text)
+  AND created_at >= $3`, formID, ipAddress, since).Scan(&count)
+	return count, err
+}
+
+// VerifyPublicFormSubmission verifies the submission the token was sent for, unless it has
+// expired, and links it to the activated user with its email if there is one. Tokens work once.
+func (q *Queries) VerifyPublicFormSubmission(ctx context.Context, tokenHash []byte, now time.Time) (PublicFormSubmission, error) {
+	s, err := scanPublicFormSubmission(q.db.QueryRowContext(ctx, `UPDATE public_form_submissions s SET
+    status = '`+PublicSubmissionStatusVerified+`',
+    verified_at = $2,
+    verification_token_hash = NULL,
+    user_id = (SELECT u.id FROM users u WHERE u.active AND lower(u.email) = lower(s.email) ORDER BY u.created_at LIMIT 1),
+    linked_at = CASE WHEN EXISTS (SELECT 1 FROM users u WHERE u.active AND lower(u.email) = lower(s.email)) THEN $2 END
+WHERE verification_token_hash = $1
+  AND status = '`+PublicSubmissionStatusPending+`'
+  AND verification_expires_at > $2
+RETURNING `+publicFormSubmissionColumns, tokenHash, now))
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, ErrInvalidVerificationToken
+	}
+	return s, err
+}
+
+// VerifyPublicFormSubmissionTx verifies the submission the token was sent for and writes the form
+// events it fires
+func (store *SQLStore) VerifyPublicFormSubmissionTx(ctx context.Context, tokenHash []byte, now time.Time, events FormEventsFunc[PublicFormSubmission]) (PublicFormSubmission, error) {
+	var submission PublicFormSubmission
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		submission, err = q.VerifyPublicFormSubmission(ctx, tokenHash, now)
+		if err != nil {
+			return err
+		}
+		return enqueueFormEvents(ctx, q, events, submission)
+	})
+
+	return submission, err
+}
+
+// LinkPublicFormSubmissions gives an activated user the verified public submissions made with
+// their email before they signed up. Activation proves the user owns the email, so users are
+// linked when they activate, never at signup. It returns how many were linked.
+func (q *Queries) LinkPublicFormSubmissions(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, `UPDATE public_form_submissions s SET user_id = u.id, linked_at = now()
+FROM users u
+WHERE u.id = $1
+  AND u.active
+  AND lower(s.email) = lower(u.email)
+  AND s.status = '`+PublicSubmissionStatusVerified+`'
+  AND s.user_id IS NULL`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ListPublicFormSubmissions returns the public submissions of a form, newest first
+func (q *Queries) ListPublicFormSubmissions(ctx context.Context, arg ListPublicFormSubmissionsParams) ([]PublicFormSubmission, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT `+publicFormSubmissionColumns+`
+FROM public_form_submissions
+WHERE form_definition_id = $1
+  AND ($2::text = '' OR status = $2::text)
+ORDER BY created_at DESC
+LIMIT $3 OFFSET $4`, arg.FormDefinitionID, arg.Status, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []PublicFormSubmission{}
+	for rows.Next() {
+		s, err := scanPublicFormSubmission(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, s)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
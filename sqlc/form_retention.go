@@ -0,0 +1,502 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// RetentionTriggerAge counts the days since a submission was last updated
+	RetentionTriggerAge = "age"
+	// RetentionTriggerAccountClosed counts the days since the submitter's account was closed.
+	// Accounts that are only suspended or deactivated are never closed.
+	RetentionTriggerAccountClosed = "account_closed"
+
+	RetentionActionDelete    = "delete"
+	RetentionActionAnonymize = "anonymize"
+)
+
+var (
+	ErrRetentionPolicyNotFound = errors.New("retention policy not found")
+	ErrLegalHoldNotFound       = errors.New("legal hold not found")
+)
+
+// FormRetentionPolicy deletes or anonymizes the submissions of a form AfterDays after its
+// trigger. Statuses limits it to submissions with one of those statuses or approval statuses.
+type FormRetentionPolicy struct {
+	ID               uuid.UUID      `json:"id"`
+	FormDefinitionID uuid.UUID      `json:"form_definition_id"`
+	Name             string         `json:"name"`
+	Trigger          string         `json:"trigger"`
+	Statuses         pq.StringArray `json:"statuses"`
+	AfterDays        int32          `json:"after_days"`
+	Action           string         `json:"action"`
+	Enabled          bool           `json:"enabled"`
+	CreatedBy        uuid.NullUUID  `json:"created_by"`
+	LastRunAt        sql.NullTime   `json:"last_run_at"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+}
+
+type CreateFormRetentionPolicyParams struct {
+	FormDefinitionID uuid.UUID `json:"form_definition_id"`
+	Name             string    `json:"name"`
+	Trigger          string    `json:"trigger"`
+	Statuses         []string  `json:"statuses"`
+	AfterDays        int32     `json:"after_days"`
+	Action           string    `json:"action"`
+	Enabled          bool      `json:"enabled"`
+	CreatedBy        uuid.UUID `json:"created_by"`
+}
+
+type UpdateFormRetentionPolicyParams struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Trigger   string    `json:"trigger"`
+	Statuses  []string  `json:"statuses"`
+	AfterDays int32     `json:"after_days"`
+	Action    string    `json:"action"`
+	Enabled   bool      `json:"enabled"`
+}
+
+// LegalHold keeps the data of a user from being purged or erased until it is released. Holds
+// without a form cover all of the user's data; others only the submissions of their form.
+type LegalHold struct {
+	ID               uuid.UUID     `json:"id"`
+	UserID           uuid.UUID     `json:"user_id"`
+	FormDefinitionID uuid.NullUUID `json:"form_definition_id"`
+	Reason           string        `json:"reason"`
+	PlacedBy         uuid.UUID     `json:"placed_by"`
+	CreatedAt        time.Time     `json:"created_at"`
+	ReleasedAt       sql.NullTime  `json:"released_at"`
+	ReleasedBy       uuid.NullUUID `json:"released_by"`
+}
+
+type CreateLegalHoldParams struct {
+	UserID           uuid.UUID     `json:"user_id"`
+	FormDefinitionID uuid.NullUUID `json:"form_definition_id"`
+	Reason           string        `json:"reason"`
+	PlacedBy         uuid.UUID     `json:"placed_by"`
+}
+
+type ListLegalHoldsParams struct {
+	UserID     uuid.NullUUID `json:"user_id"` // all users when not set
+	ActiveOnly bool          `json:"active_only"`
+	Limit      int32         `json:"limit"`
+	Offset     int32         `json:"offset"`
+}
+
+const formRetentionPolicyColumns = `id, form_definition_id, name, trigger, statuses, after_days, action, enabled, created_by,
+    last_run_at, created_at, updated_at`
+
+func scanFormRetentionPolicy(row interface{ Scan(...interface{}) error }) (FormRetentionPolicy, error) {
+	var p FormRetentionPolicy
+	err := row.Scan(
+		&p.ID,
+		&p.FormDefinitionID,
+		&p.Name,
+		&p.Trigger,
+		&p.Statuses,
+		&p.AfterDays,
+		&p.Action,
+		&p.Enabled,
+		&p.CreatedBy,
+		&p.LastRunAt,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+	return p, err
+}
+
+func queryFormRetentionPolicies(ctx context.Context, q *Queries, query string, args ...interface{}) ([]FormRetentionPolicy, error) {
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []FormRetentionPolicy{}
+	for rows.Next() {
+		p, err := scanFormRetentionPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, p)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (q *Queries) CreateFormRetentionPolicy(ctx context.Context, arg CreateFormRetentionPolicyParams) (FormRetentionPolicy, error) {
+	return scanFormRetentionPolicy(q.db.QueryRowContext(ctx, `INSERT INTO form_retention_policies (
+    id, form_definition_id, name, trigger, statuses, after_days, action, enabled, created_by
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING `+formRetentionPolicyColumns,
+		uuid.New(),
+		arg.FormDefinitionID,
+		arg.Name,
+		arg.Trigger,
+		pq.StringArray(arg.Statuses),
+		arg.AfterDays,
+		arg.Action,
+		arg.Enabled,
+		NewNullUUID(arg.CreatedBy),
+	))
+}
+
+func (q *Queries) GetFormRetentionPolicy(ctx context.Context, id uuid.UUID) (FormRetentionPolicy, error) {
+	p, err := scanFormRetentionPolicy(q.db.QueryRowContext(ctx, `SELECT `+formRetentionPolicyColumns+`
+FROM form_retention_policies WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, ErrRetentionPolicyNotFound
+	}
+	return p, err
+}
+
+func (q *Queries) UpdateFormRetentionPolicy(ctx context.Context, arg UpdateFormRetentionPolicyParams) (FormRetentionPolicy, error) {
+	p, err := scanFormRetentionPolicy(q.db.QueryRowContext(ctx, `UPDATE form_retention_policies SET
+    name = $2,
+    trigger = $3,
+    statuses = $4,
+    after_days = $5,
+    action = $6,
+    enabled = $7,
+    updated_at = now()
+WHERE id = $1
+RETURNING `+formRetentionPolicyColumns,
+		arg.ID,
+		arg.Name,
+		arg.Trigger,
+		pq.StringArray(arg.Statuses),
+		arg.AfterDays,
+		arg.Action,
+		arg.Enabled,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, ErrRetentionPolicyNotFound
+	}
+	return p, err
+}
+
+func (q *Queries) DeleteFormRetentionPolicy(ctx context.Context, id uuid.UUID) error {
+	result, err := q.db.ExecContext(ctx, `DELETE FROM form_retention_policies WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrRetentionPolicyNotFound
+	}
+	return err
+}
+
+// ListFormRetentionPolicies returns the retention policies of a form, or of all forms with
+// enabledOnly
+func (q *Queries) ListFormRetentionPolicies(ctx context.Context, formID uuid.NullUUID, enabledOnly bool) ([]FormRetentionPolicy, error) {
+	return queryFormRetentionPolicies(ctx, q, `SELECT `+formRetentionPolicyColumns+`
+FROM form_retention_policies
+WHERE ($1::uuid IS NULL OR form_definition_id = $1)
+  AND (NOT $2::bool OR enabled)
+ORDER BY form_definition_id, created_at`, formID, enabledOnly)
+}
+
+func (q *Queries) MarkFormRetentionPolicyRun(ctx context.Context, id uuid.UUID, ranAt time.Time) error {
+	_, err := q.db.ExecContext(ctx, `UPDATE form_retention_policies SET last_run_at = $2 WHERE id = $1`, id, ranAt)
+	return err
+}
+
+const formSubmissionColumns = `s.id, s.form_definition_id, s.user_id, s.submission_data, s.status, s.approval_status,
+    s.approval_notes, s.approved_by, s.approved_at, s.metadata, s.created_at, s.updated_at, s.current_step_number,
+    s.completion_percentage, s.form_version, s.revision`
+
+func queryFormSubmissions(ctx context.Context, q *Queries, query string, args ...interface{}) ([]FormSubmission, error) {
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []FormSubmission{}
+	for rows.Next() {
+		var i FormSubmission
+		if err := rows.Scan(
+			&i.ID,
+			&i.FormDefinitionID,
+			&i.UserID,
+			&i.SubmissionData,
+			&i.Status,
+			&i.ApprovalStatus,
+			&i.ApprovalNotes,
+			&i.ApprovedBy,
+			&i.ApprovedAt,
+			&i.Metadata,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.CurrentStepNumber,
+			&i.CompletionPercentage,
+			&i.FormVersion,
+			&i.Revision,
+		); err != nil {
+			return nil, err
+		}
+		i.SubmissionData = openSubmissionData(i.SubmissionData)
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// ListExpiredFormSubmissions returns up to limit submissions a policy applies to as of now,
+// oldest first. Submissions of users under a legal hold, and those already anonymized when the
+// policy anonymizes, are left out.
+func (q *Queries) ListExpiredFormSubmissions(ctx context.Context, policy FormRetentionPolicy, now time.Time, limit int32) ([]FormSubmission, error) {
+	cutoff := now.AddDate(0, 0, -int(policy.AfterDays))
+	return queryFormSubmissions(ctx, q, `SELECT `+formSubmissionColumns+`
+FROM form_submissions s
+JOIN users u ON u.id = s.user_id
+WHERE s.form_definition_id = $1
+  AND (cardinality($2::text[]) = 0 OR s.status = ANY($2::text[]) OR s.approval_status = ANY($2::text[]))
+  AND CASE WHEN $3::text = '`+RetentionTriggerAccountClosed+`'
+           THEN u.closed_at < $4
+           ELSE s.updated_at < $4
+      END
+  AND ($5::text = '`+RetentionActionDelete+`' OR s.metadata->>'redacted_at' IS NULL)
+  AND NOT EXISTS (
+      SELECT 1 FROM legal_holds h
+      WHERE h.user_id = s.user_id
+        AND h.released_at IS NULL
+        AND (h.form_definition_id IS NULL OR h.form_definition_id = s.form_definition_id)
+  )
+ORDER BY s.updated_at
+LIMIT $6`,
+		policy.FormDefinitionID,
+		policy.Statuses,
+		policy.Trigger,
+		cutoff,
+		policy.Action,
+		limit,
+	)
+}
+
+// TryAdvisoryLock takes the session advisory lock called name on a connection of its own, so
+// only one instance runs a job at a time. ok is false when another session holds the lock.
+// unlock releases the lock and the connection.
+func (store *SQLStore) TryAdvisoryLock(ctx context.Context, name string) (unlock func(), ok bool, err error) {
+	conn, err := store.db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, name).Scan(&ok); err != nil || !ok {
+		conn.Close()
+		return nil, false, err
+	}
+
+	return func() {
+		// Closing the connection returns it to the pool, so the lock is released first
+		conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtext($1))`, name)
+		conn.Close()
+	}, true, nil
+}
+
+// DeleteFormSubmissionTx deletes a submission with its revisions, step progress, file records,
+// approvals, validation failures and event deliveries. The files themselves are the caller's
+// to delete from storage.
+func (store *SQLStore) DeleteFormSubmissionTx(ctx context.Context, submissionID uuid.UUID) error {
+	return store.execTx(ctx, func(q *Queries) error {
+		return deleteFormSubmissions(ctx, q, []uuid.UUID{submissionID})
+	})
+}
+
+func deleteFormSubmissions(ctx context.Context, q *Queries, submissionIDs []uuid.UUID) error {
+	if len(submissionIDs) == 0 {
+		return nil
+	}
+	ids := pq.Array(submissionIDs)
+	for _, table := range []string{
+		"form_submission_revisions",
+		"form_step_progress",
+		"form_submission_files",
+		"form_approval_events",
+		"form_approval_tasks",
+		"form_validation_failures",
+		"form_event_outbox",
+	} {
+		if _, err := q.db.ExecContext(ctx, `DELETE FROM `+table+` WHERE form_submission_id = ANY($1::uuid[])`, ids); err != nil {
+			return err
+		}
+	}
+	_, err := q.db.ExecContext(ctx, `DELETE FROM form_submissions WHERE id = ANY($1::uuid[])`, ids)
+	return err
+}
+
+// RedactFormSubmissionTx anonymizes a submission. Its data, revisions, step data, files,
+// reviewer comments and event payloads are removed; its status and dates are kept for reporting
+// and metadata.redacted_at records when. The personal columns the form persisted to are erased
+// too, unless a legal hold on the user keeps them. The files themselves are the caller's to
+// delete from storage.
+func (store *SQLStore) RedactFormSubmissionTx(ctx context.Context, submissionID uuid.UUID, redactedAt time.Time) error {
+	return store.execTx(ctx, func(q *Queries) error {
+		var userID, formID uuid.UUID
+		if err := q.db.QueryRowContext(ctx, `SELECT user_id, form_definition_id FROM form_submissions
+WHERE id = $1 FOR UPDATE`, submissionID).Scan(&userID, &formID); err != nil {
+			return err
+		}
+
+		statements := []string{
+			`UPDATE form_submissions SET
+    submission_data = '{}',
+    approval_notes = '',
+    metadata = COALESCE(metadata, '{}'::jsonb) || jsonb_build_object('redacted_at', $2::timestamptz),
+    updated_at = $2
+WHERE id = $1`,
+			`UPDATE form_submission_revisions SET submission_data = '{}' WHERE form_submission_id = $1`,
+			`UPDATE form_step_progress SET data = '{}' WHERE form_submission_id = $1`,
+			`UPDATE form_approval_events SET comment = '', field_comments = '{}' WHERE form_submission_id = $1`,
+			`UPDATE form_event_outbox SET payload = '{}' WHERE form_submission_id = $1`,
+			`DELETE FROM form_submission_files WHERE form_submission_id = $1`,
+		}
+		for i, statement := range statements {
+			args := []interface{}{submissionID}
+			if i == 0 {
+				args = append(args, redactedAt)
+			}
+			if _, err := q.db.ExecContext(ctx, statement, args...); err != nil {
+				return err
+			}
+		}
+
+		// Persisted rows are shared by the user's submissions of every form, so any hold keeps them
+		heldForms, heldAll, err := activeLegalHolds(ctx, q, userID)
+		if err != nil {
+			return err
+		}
+		if heldAll || len(heldForms) > 0 {
+			return nil
+		}
+		columns, err := persistedColumns(ctx, q, formID)
+		if err != nil {
+			return err
+		}
+		_, err = erasePersonalColumns(ctx, q, userID, columns)
+		return err
+	})
+}
+
+// activeLegalHolds returns the forms the active legal holds on a user keep, and whether one of
+// them keeps all of the user's data. The holds are locked so they can't be released meanwhile.
+func activeLegalHolds(ctx context.Context, q *Queries, userID uuid.UUID) ([]uuid.UUID, bool, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT form_definition_id FROM legal_holds
+WHERE user_id = $1 AND released_at IS NULL
+FOR SHARE`, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var forms []uuid.UUID
+	all := false
+	for rows.Next() {
+		var formID uuid.NullUUID
+		if err := rows.Scan(&formID); err != nil {
+			return nil, false, err
+		}
+		if !formID.Valid {
+			all = true
+			continue
+		}
+		forms = append(forms, formID.UUID)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, false, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+	return forms, all, nil
+}
+
+const legalHoldColumns = `id, user_id, form_definition_id, reason, placed_by, created_at, released_at, released_by`
+
+func scanLegalHold(row interface{ Scan(...interface{}) error }) (LegalHold, error) {
+	var h LegalHold
+	err := row.Scan(
+		&h.ID,
+		&h.UserID,
+		&h.FormDefinitionID,
+		&h.Reason,
+		&h.PlacedBy,
+		&h.CreatedAt,
+		&h.ReleasedAt,
+		&h.ReleasedBy,
+	)
+	return h, err
+}
+
+func (q *Queries) CreateLegalHold(ctx context.Context, arg CreateLegalHoldParams) (LegalHold, error) {
+	return scanLegalHold(q.db.QueryRowContext(ctx, `INSERT INTO legal_holds (
+    id, user_id, form_definition_id, reason, placed_by
+) VALUES ($1, $2, $3, $4, $5)
+RETURNING `+legalHoldColumns,
+		uuid.New(),
+		arg.UserID,
+		arg.FormDefinitionID,
+		arg.Reason,
+		arg.PlacedBy,
+	))
+}
+
+// ReleaseLegalHold releases an active hold, ErrLegalHoldNotFound when there is none with the ID
+func (q *Queries) ReleaseLegalHold(ctx context.Context, id uuid.UUID, releasedBy uuid.UUID) (LegalHold, error) {
+	h, err := scanLegalHold(q.db.QueryRowContext(ctx, `UPDATE legal_holds SET released_at = now(), released_by = $2
+WHERE id = $1 AND released_at IS NULL
+RETURNING `+legalHoldColumns, id, releasedBy))
+	if errors.Is(err, sql.ErrNoRows) {
+		return h, ErrLegalHoldNotFound
+	}
+	return h, err
+}
+
+// ListLegalHolds returns legal holds, newest first
+func (q *Queries) ListLegalHolds(ctx context.Context, arg ListLegalHoldsParams) ([]LegalHold, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT `+legalHoldColumns+`
+FROM legal_holds
+WHERE ($1::uuid IS NULL OR user_id = $1)
+  AND (NOT $2::bool OR released_at IS NULL)
+ORDER BY created_at DESC
+LIMIT $3 OFFSET $4`, arg.UserID, arg.ActiveOnly, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []LegalHold{}
+	for rows.Next() {
+		h, err := scanLegalHold(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, h)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,336 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RevisionSourceCreate   = "create"
+	RevisionSourceUpdate   = "update"
+	RevisionSourceStep     = "step"
+	RevisionSourceAutosave = "autosave"
+	RevisionSourceRestore  = "restore"
+	RevisionSourceMigrate  = "migrate"
+)
+
+var (
+	ErrRevisionConflict     = errors.New("submission was changed since the revision being edited")
+	ErrRevisionNotFound     = errors.New("submission revision not found")
+	ErrRevisionFormMismatch = errors.New("revision was saved against another version of the form")
+)
+
+// FormSubmissionRevision is the data of a submission as it was after one of its changes.
+// Revision matches FormSubmission.Revision; step saves also keep the step's own revision.
+type FormSubmissionRevision struct {
+	ID               uuid.UUID       `json:"id"`
+	FormSubmissionID uuid.UUID       `json:"form_submission_id"`
+	Revision         int32           `json:"revision"`
+	FormVersion      int32           `json:"form_version"`
+	SubmissionData   json.RawMessage `json:"submission_data"`
+	Status           string          `json:"status"`
+	Source           string          `json:"source"`
+	ChangedBy        uuid.NullUUID   `json:"changed_by"`
+	StepNumber       sql.NullInt32   `json:"step_number"`
+	StepRevision     sql.NullInt32   `json:"step_revision"`
+	CreatedAt        time.Time       `json:"created_at"`
+}
+
+// FieldChange is a field another save changed since the revision a client edited. Base is
+// its value at that revision, Current its value now and Yours the value the client sent.
+type FieldChange struct {
+	Field   string      `json:"field"`
+	Base    interface{} `json:"base"`
+	Current interface{} `json:"current"`
+	Yours   interface{} `json:"yours,omitempty"`
+}
+
+// RevisionConflictError is returned when a save was based on a revision that is no longer
+// current. Changes lists the fields saved since.
+type RevisionConflictError struct {
+	SubmissionID     uuid.UUID     `json:"submission_id"`
+	StepNumber       *int32        `json:"step_number,omitempty"`
+	ExpectedRevision int32         `json:"expected_revision"`
+	CurrentRevision  int32         `json:"current_revision"`
+	Changes          []FieldChange `json:"changes"`
+}
+
+func (e *RevisionConflictError) Error() string {
+	return fmt.Sprintf("%s: expected revision %d, current revision is %d", ErrRevisionConflict, e.ExpectedRevision, e.CurrentRevision)
+}
+
+func (e *RevisionConflictError) Unwrap() error {
+	return ErrRevisionConflict
+}
+
+// AutosaveInput patches fields of a draft. Fields the patch doesn't name are left alone, and
+// a patch based on an older revision is applied unless it touches fields changed since.
+type AutosaveInput struct {
+	SubmissionID uuid.UUID              `json:"submission_id"`
+	UserID       uuid.UUID              `json:"user_id"`
+	BaseRevision int32                  `json:"base_revision"`
+	Set          map[string]interface{} `json:"set"`
+	Unset        []string               `json:"unset"`
+}
+
+const formSubmissionRevisionColumns = `id, form_submission_id, revision, form_version, submission_data, status, source, changed_by, step_number, step_revision, created_at`
+
+// recordSubmissionRevision keeps the submission's current data as the revision it is at
+func recordSubmissionRevision(ctx context.Context, q *Queries, submissionID uuid.UUID, source string, changedBy uuid.UUID, stepNumber, stepRevision sql.NullInt32) error {
+	_, err := q.db.ExecContext(ctx, `INSERT INTO form_submission_revisions (
+    id, form_submission_id, revision, form_version, submission_data, status, source, changed_by, step_number, step_revision
+)
+SELECT $2, id, revision, form_version, submission_data, status, $3, $4, $5, $6
+FROM form_submissions WHERE id = $1`,
+		submissionID, uuid.New(), source, uuid.NullUUID{UUID: changedBy, Valid: changedBy != uuid.Nil}, stepNumber, stepRevision)
+	return err
+}
+
+func scanFormSubmissionRevision(row interface{ Scan(...interface{}) error }) (FormSubmissionRevision, error) {
+	var r FormSubmissionRevision
+	err := row.Scan(
+		&r.ID,
+		&r.FormSubmissionID,
+		&r.Revision,
+		&r.FormVersion,
+		&r.SubmissionData,
+		&r.Status,
+		&r.Source,
+		&r.ChangedBy,
+		&r.StepNumber,
+		&r.StepRevision,
+		&r.CreatedAt,
+	)
+	r.SubmissionData = openSubmissionData(r.SubmissionData)
+	return r, err
+}
+
+// ListFormSubmissionRevisions returns the revisions of a submission, newest first
+func (q *Queries) ListFormSubmissionRevisions(ctx context.Context, submissionID uuid.UUID) ([]FormSubmissionRevision, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT `+formSubmissionRevisionColumns+`
+FROM form_submission_revisions WHERE form_submission_id = $1
+ORDER BY revision DESC, created_at DESC`, submissionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []FormSubmissionRevision{}
+	for rows.Next() {
+		r, err := scanFormSubmissionRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, r)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// GetFormSubmissionRevision returns a revision of a submission
+func (q *Queries) GetFormSubmissionRevision(ctx context.Context, submissionID uuid.UUID, revision int32) (FormSubmissionRevision, error) {
+	r, err := scanFormSubmissionRevision(q.db.QueryRowContext(ctx, `SELECT `+formSubmissionRevisionColumns+`
+FROM form_submission_revisions WHERE form_submission_id = $1 AND revision = $2
+ORDER BY created_at DESC LIMIT 1`, submissionID, revision))
+	if errors.Is(err, sql.ErrNoRows) {
+		return r, ErrRevisionNotFound
+	}
+	return r, err
+}
+
+// stepRevisionData returns the submission data saved with a revision of a step
+func stepRevisionData(ctx context.Context, q *Queries, submissionID uuid.UUID, stepNumber, stepRevision int32) (map[string]interface{}, error) {
+	r, err := scanFormSubmissionRevision(q.db.QueryRowContext(ctx, `SELECT `+formSubmissionRevisionColumns+`
+FROM form_submission_revisions WHERE form_submission_id = $1 AND step_number = $2 AND step_revision = $3
+ORDER BY revision DESC LIMIT 1`, submissionID, stepNumber, stepRevision))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeSubmissionData(r.SubmissionData), nil
+}
+
+// revisionData returns the submission data saved with a revision, or nil when it predates
+// revision history
+func revisionData(ctx context.Context, q *Queries, submissionID uuid.UUID, revision int32) (map[string]interface{}, error) {
+	r, err := q.GetFormSubmissionRevision(ctx, submissionID, revision)
+	if errors.Is(err, ErrRevisionNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeSubmissionData(r.SubmissionData), nil
+}
+
+func decodeSubmissionData(raw json.RawMessage) map[string]interface{} {
+	data := make(map[string]interface{})
+	if len(raw) > 0 {
+		_ = json.Unmarshal(raw, &data)
+	}
+	return data
+}
+
+// checkSubmissionRevision fails with a RevisionConflictError when a save based on expected is
+// made to a submission that has moved on. An expected revision of 0 skips the check.
+func checkSubmissionRevision(ctx context.Context, q *Queries, submission FormSubmission, expected int32, yours map[string]interface{}) error {
+	if expected == 0 || expected == submission.Revision {
+		return nil
+	}
+
+	base, err := revisionData(ctx, q, submission.ID, expected)
+	if err != nil {
+		return err
+	}
+	return &RevisionConflictError{
+		SubmissionID:     submission.ID,
+		ExpectedRevision: expected,
+		CurrentRevision:  submission.Revision,
+		Changes:          diffSubmissionData(base, decodeSubmissionData(submission.SubmissionData), yours, nil),
+	}
+}
+
+// diffSubmissionData lists the fields whose value differs between base and current, in name
+// order, with the value the client sent where it sent one. Without a base, when the revision
+// predates history, the fields where current differs from the client's value are listed.
+// With only set, the diff is limited to those fields.
+func diffSubmissionData(base, current, yours map[string]interface{}, only map[string]bool) []FieldChange {
+	fields := make(map[string]bool)
+	if base == nil {
+		for field, value := range yours {
+			if !reflect.DeepEqual(value, current[field]) {
+				fields[field] = true
+			}
+		}
+	} else {
+		for field := range base {
+			fields[field] = true
+		}
+		for field := range current {
+			fields[field] = true
+		}
+	}
+
+	changes := []FieldChange{}
+	for field := range fields {
+		if only != nil && !only[field] {
+			continue
+		}
+		if base != nil && reflect.DeepEqual(base[field], current[field]) {
+			continue
+		}
+		changes = append(changes, FieldChange{
+			Field:   field,
+			Base:    base[field],
+			Current: current[field],
+			Yours:   yours[field],
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// saveSubmissionData replaces the data of a locked submission, keeping its other fields
+func saveSubmissionData(ctx context.Context, q *Queries, submission FormSubmission, data map[string]interface{}) (FormSubmission, error) {
+	dataJSON, err := sealSubmissionData(ctx, q, submission.FormDefinitionID, data)
+	if err != nil {
+		return FormSubmission{}, err
+	}
+
+	updated, err := q.UpdateFormSubmission(ctx, UpdateFormSubmissionParams{
+		ID:             submission.ID,
+		SubmissionData: dataJSON,
+		Status:         submission.Status,
+		ApprovalStatus: submission.ApprovalStatus,
+		ApprovalNotes:  submission.ApprovalNotes,
+		ApprovedBy:     submission.ApprovedBy,
+		ApprovedAt:     submission.ApprovedAt,
+		Metadata:       submission.Metadata,
+	})
+	return openFormSubmission(updated), err
+}
+
+// AutosaveFormSubmissionTx applies a field-level patch to a submission. A patch based on an
+// older revision still applies when none of its fields were changed since; otherwise it fails
+// with a RevisionConflictError listing those fields.
+func (store *SQLStore) AutosaveFormSubmissionTx(ctx context.Context, input *AutosaveInput) (*FormSubmission, error) {
+	var submission FormSubmission
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		current, err := lockFormSubmission(ctx, q, input.SubmissionID)
+		if err != nil {
+			return err
+		}
+
+		var base map[string]interface{}
+		if input.BaseRevision != 0 && input.BaseRevision != current.Revision {
+			if base, err = revisionData(ctx, q, current.ID, input.BaseRevision); err != nil {
+				return err
+			}
+		}
+
+		data, err := patchSubmissionData(current, base, input)
+		if err != nil {
+			return err
+		}
+
+		submission, err = saveSubmissionData(ctx, q, current, data)
+		if err != nil {
+			return err
+		}
+		return recordSubmissionRevision(ctx, q, submission.ID, RevisionSourceAutosave, input.UserID, sql.NullInt32{}, sql.NullInt32{})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &submission, nil
+}
+
+// patchSubmissionData applies a patch to the data of current. base is the data at the revision
+// the patch was based on, or nil when that revision predates revision history, in which case
+// every patched field that differs from current counts as changed.
+func patchSubmissionData(current FormSubmission, base map[string]interface{}, input *AutosaveInput) (map[string]interface{}, error) {
+	patched := make(map[string]bool, len(input.Set)+len(input.Unset))
+	for field := range input.Set {
+		patched[field] = true
+	}
+	for _, field := range input.Unset {
+		patched[field] = true
+	}
+
+	data := decodeSubmissionData(current.SubmissionData)
+	if input.BaseRevision != 0 && input.BaseRevision != current.Revision {
+		if changes := diffSubmissionData(base, data, input.Set, patched); len(changes) > 0 {
+			return nil, &RevisionConflictError{
+				SubmissionID:     current.ID,
+				ExpectedRevision: input.BaseRevision,
+				CurrentRevision:  current.Revision,
+				Changes:          changes,
+			}
+		}
+	}
+
+	for field, value := range input.Set {
+		data[field] = value
+	}
+	for _, field := range input.Unset {
+		delete(data, field)
+	}
+	return data, nil
+}
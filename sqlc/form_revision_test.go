@@ -0,0 +1,100 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffSubmissionData(t *testing.T) {
+	base := map[string]interface{}{"business_name": "Acme", "website": "acme.ng", "employees": float64(10)}
+	current := map[string]interface{}{"business_name": "Acme Ltd", "employees": float64(10), "industry": "fintech"}
+	yours := map[string]interface{}{"business_name": "Acme Limited", "employees": float64(12)}
+
+	changes := diffSubmissionData(base, current, yours, nil)
+	assert.Equal(t, []FieldChange{
+		{Field: "business_name", Base: "Acme", Current: "Acme Ltd", Yours: "Acme Limited"},
+		{Field: "industry", Current: "fintech"},
+		{Field: "website", Base: "acme.ng"},
+	}, changes)
+
+	// Limited to the fields a patch touches, only overlapping changes are conflicts
+	changes = diffSubmissionData(base, current, yours, map[string]bool{"employees": true, "website": true})
+	assert.Equal(t, []FieldChange{{Field: "website", Base: "acme.ng"}}, changes)
+	assert.Empty(t, diffSubmissionData(base, current, yours, map[string]bool{"employees": true}))
+
+	// Revisions saved before history was kept compare the client's values with the current ones
+	changes = diffSubmissionData(nil, current, yours, nil)
+	assert.Equal(t, []FieldChange{
+		{Field: "business_name", Current: "Acme Ltd", Yours: "Acme Limited"},
+		{Field: "employees", Current: float64(10), Yours: float64(12)},
+	}, changes)
+	assert.Empty(t, diffSubmissionData(nil, current, map[string]interface{}{"industry": "fintech"}, nil))
+}
+
+func TestRevisionConflictError(t *testing.T) {
+	err := fmt.Errorf("failed to update submission: %w", &RevisionConflictError{
+		SubmissionID:     uuid.New(),
+		ExpectedRevision: 3,
+		CurrentRevision:  5,
+	})
+
+	assert.True(t, errors.Is(err, ErrRevisionConflict))
+	var conflict *RevisionConflictError
+	assert.True(t, errors.As(err, &conflict))
+	assert.Equal(t, int32(5), conflict.CurrentRevision)
+	assert.Contains(t, err.Error(), "expected revision 3, current revision is 5")
+}
+
+func TestPatchSubmissionData(t *testing.T) {
+	current := FormSubmission{
+		ID:             uuid.New(),
+		Revision:       5,
+		SubmissionData: json.RawMessage(`{"business_name":"Acme Ltd","website":"acme.ng","employees":10}`),
+	}
+	base := map[string]interface{}{"business_name": "Acme", "website": "acme.ng", "employees": float64(10)}
+
+	// A patch of the current revision applies as is
+	data, err := patchSubmissionData(current, nil, &AutosaveInput{
+		BaseRevision: 5,
+		Set:          map[string]interface{}{"business_name": "Acme Limited"},
+		Unset:        []string{"website"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"business_name": "Acme Limited", "employees": float64(10)}, data)
+
+	// A patch of an older revision merges when it leaves the fields changed since alone
+	data, err = patchSubmissionData(current, base, &AutosaveInput{
+		BaseRevision: 3,
+		Set:          map[string]interface{}{"employees": float64(12)},
+		Unset:        []string{"website"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"business_name": "Acme Ltd", "employees": float64(12)}, data)
+
+	// and conflicts on the fields it shares with them
+	_, err = patchSubmissionData(current, base, &AutosaveInput{
+		BaseRevision: 3,
+		Set:          map[string]interface{}{"business_name": "Acme Limited", "employees": float64(12)},
+	})
+	var conflict *RevisionConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, int32(3), conflict.ExpectedRevision)
+	assert.Equal(t, int32(5), conflict.CurrentRevision)
+	assert.Equal(t, []FieldChange{
+		{Field: "business_name", Base: "Acme", Current: "Acme Ltd", Yours: "Acme Limited"},
+	}, conflict.Changes)
+
+	// Without history for the base, only patched fields that already hold the sent value merge
+	_, err = patchSubmissionData(current, nil, &AutosaveInput{
+		BaseRevision: 3,
+		Set:          map[string]interface{}{"business_name": "Acme Ltd", "employees": float64(12)},
+	})
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, []FieldChange{{Field: "employees", Current: float64(10), Yours: float64(12)}}, conflict.Changes)
+}
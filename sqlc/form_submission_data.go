@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// The generated form queries return submission answers as they are stored, with the answers
+// of encrypted fields encrypted. The store methods below shadow them to return the answers
+// decrypted; code running in a transaction opens what it reads with openFormSubmission and
+// openStepProgress.
+
+// openFormSubmission decrypts the encrypted answers of a submission
+func openFormSubmission(s FormSubmission) FormSubmission {
+	s.SubmissionData = openSubmissionData(s.SubmissionData)
+	return s
+}
+
+// openStepProgress decrypts the encrypted answers of a step's progress
+func openStepProgress(p FormStepProgress) FormStepProgress {
+	if p.Data.Valid {
+		p.Data.RawMessage = openSubmissionData(p.Data.RawMessage)
+	}
+	return p
+}
+
+func (store *SQLStore) CreateFormSubmission(ctx context.Context, arg CreateFormSubmissionParams) (FormSubmission, error) {
+	s, err := store.Queries.CreateFormSubmission(ctx, arg)
+	return openFormSubmission(s), err
+}
+
+func (store *SQLStore) GetFormSubmission(ctx context.Context, id uuid.UUID) (FormSubmission, error) {
+	s, err := store.Queries.GetFormSubmission(ctx, id)
+	return openFormSubmission(s), err
+}
+
+func (store *SQLStore) GetFormSubmissionByUserAndForm(ctx context.Context, arg GetFormSubmissionByUserAndFormParams) (FormSubmission, error) {
+	s, err := store.Queries.GetFormSubmissionByUserAndForm(ctx, arg)
+	return openFormSubmission(s), err
+}
+
+func (store *SQLStore) ListFormSubmissions(ctx context.Context, arg ListFormSubmissionsParams) ([]FormSubmission, error) {
+	items, err := store.Queries.ListFormSubmissions(ctx, arg)
+	for i := range items {
+		items[i] = openFormSubmission(items[i])
+	}
+	return items, err
+}
+
+func (store *SQLStore) UpdateFormSubmission(ctx context.Context, arg UpdateFormSubmissionParams) (FormSubmission, error) {
+	s, err := store.Queries.UpdateFormSubmission(ctx, arg)
+	return openFormSubmission(s), err
+}
+
+func (store *SQLStore) UpdateSubmissionProgress(ctx context.Context, arg UpdateSubmissionProgressParams) (FormSubmission, error) {
+	s, err := store.Queries.UpdateSubmissionProgress(ctx, arg)
+	return openFormSubmission(s), err
+}
+
+func (store *SQLStore) CreateStepProgress(ctx context.Context, arg CreateStepProgressParams) (FormStepProgress, error) {
+	p, err := store.Queries.CreateStepProgress(ctx, arg)
+	return openStepProgress(p), err
+}
+
+func (store *SQLStore) GetAllStepProgress(ctx context.Context, formSubmissionID uuid.NullUUID) ([]FormStepProgress, error) {
+	items, err := store.Queries.GetAllStepProgress(ctx, formSubmissionID)
+	for i := range items {
+		items[i] = openStepProgress(items[i])
+	}
+	return items, err
+}
+
+func (store *SQLStore) GetStepProgress(ctx context.Context, arg GetStepProgressParams) (FormStepProgress, error) {
+	p, err := store.Queries.GetStepProgress(ctx, arg)
+	return openStepProgress(p), err
+}
+
+func (store *SQLStore) GetStepProgressBySubmissionAndNumber(ctx context.Context, arg GetStepProgressBySubmissionAndNumberParams) (FormStepProgress, error) {
+	p, err := store.Queries.GetStepProgressBySubmissionAndNumber(ctx, arg)
+	return openStepProgress(p), err
+}
+
+func (store *SQLStore) UpdateStepProgress(ctx context.Context, arg UpdateStepProgressParams) (FormStepProgress, error) {
+	p, err := store.Queries.UpdateStepProgress(ctx, arg)
+	return openStepProgress(p), err
+}
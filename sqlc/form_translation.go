@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+// UpdateFormFieldTextParams sets the translatable text of a form field: its label, placeholder,
+// help text and options, which hold the labels of static options and of group fields
+type UpdateFormFieldTextParams struct {
+	ID          uuid.UUID       `json:"id"`
+	Label       json.RawMessage `json:"label"`
+	Placeholder json.RawMessage `json:"placeholder"`
+	HelpText    json.RawMessage `json:"help_text"`
+	Options     json.RawMessage `json:"options"`
+}
+
+const updateFormFieldText = `-- name: UpdateFormFieldText :exec
+UPDATE form_fields
+SET label = $2, placeholder = $3, help_text = $4, options = $5, updated_at = now()
+WHERE id = $1
+`
+
+func (q *Queries) UpdateFormFieldText(ctx context.Context, arg UpdateFormFieldTextParams) error {
+	_, err := q.db.ExecContext(ctx, updateFormFieldText,
+		arg.ID,
+		arg.Label,
+		arg.Placeholder,
+		arg.HelpText,
+		arg.Options,
+	)
+	return err
+}
+
+// UpdateFormFieldTextsTx updates the text of several fields at once, so an import of
+// translations is applied whole or not at all
+func (store *SQLStore) UpdateFormFieldTextsTx(ctx context.Context, args []UpdateFormFieldTextParams) error {
+	return store.execTx(ctx, func(q *Queries) error {
+		for _, arg := range args {
+			if err := q.UpdateFormFieldText(ctx, arg); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
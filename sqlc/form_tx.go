@@ -7,7 +7,6 @@ import (
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
-	"strings"
 )
 
 // CreateFormDefinitionTx creates a form with all its fields and steps in a transaction
@@ -15,274 +14,307 @@ func (store *SQLStore) CreateFormDefinitionTx(ctx context.Context, input *FormDe
 	var form FormDefinition
 
 	err := store.execTx(ctx, func(q *Queries) error {
-		// Create form definition
-		formParams := CreateFormDefinitionParams{
-			ID:               uuid.New(),
-			Name:             input.Name,
-			Slug:             input.Slug,
-			Description:      input.Description,
-			FormType:         input.FormType,
-			Version:          1,
-			IsActive:         true,
-			IsMultiStep:      input.IsMultiStep,
-			RequiresApproval: input.RequiresApproval,
-			CreatedBy:        NewNullUUID(input.CreatedBy),
-		}
+		var err error
+		form, err = insertFormDefinition(ctx, q, input)
+		return err
+	})
 
-		if input.ApprovalWorkflow != nil {
-			workflowJSON, err := json.Marshal(input.ApprovalWorkflow)
-			if err != nil {
-				return err
-			}
-			formParams.ApprovalWorkflow = workflowJSON
-		} else {
-			formParams.ApprovalWorkflow = json.RawMessage("{}")
+	if err != nil {
+		return nil, err
+	}
+
+	return &form, nil
+}
+
+func insertFormDefinition(ctx context.Context, q *Queries, input *FormDefinitionInput) (FormDefinition, error) {
+	// Create form definition
+	formParams := CreateFormDefinitionParams{
+		ID:                        uuid.New(),
+		Name:                      input.Name,
+		Slug:                      input.Slug,
+		Description:               input.Description,
+		FormType:                  input.FormType,
+		Version:                   1,
+		IsActive:                  true,
+		IsMultiStep:               input.IsMultiStep,
+		RequiresApproval:          input.RequiresApproval,
+		IsEditableAfterSubmission: input.IsEditableAfterSubmission,
+		CreatedBy:                 NewNullUUID(input.CreatedBy),
+	}
+
+	if input.ApprovalWorkflow != nil {
+		workflowJSON, err := json.Marshal(input.ApprovalWorkflow)
+		if err != nil {
+			return FormDefinition{}, err
 		}
+		formParams.ApprovalWorkflow = workflowJSON
+	} else {
+		formParams.ApprovalWorkflow = json.RawMessage("{}")
+	}
 
-		createdForm, err := q.CreateFormDefinition(ctx, formParams)
+	form, err := q.CreateFormDefinition(ctx, formParams)
+	if err != nil {
+		return FormDefinition{}, err
+	}
+
+	if err := createFormStructure(ctx, q, form.ID, form.Version, input); err != nil {
+		return FormDefinition{}, err
+	}
+
+	if err := createFormVersion(ctx, q, form, FormVersionStatusPublished, "", form.CreatedBy); err != nil {
+		return FormDefinition{}, err
+	}
+
+	// Create persistence config if provided
+	if input.PersistenceConfig != nil {
+		configParams, err := persistenceConfigParams(form.ID, input.PersistenceConfig)
 		if err != nil {
-			return err
+			return FormDefinition{}, err
 		}
-		form = createdForm
-
-		// Create steps if multi-step
-		stepMap := make(map[int]uuid.UUID)
-		if input.IsMultiStep && len(input.Steps) > 0 {
-			for _, step := range input.Steps {
-				stepID := uuid.New()
-				stepMap[step.StepNumber] = stepID
-
-				_, err := q.CreateFormStep(ctx, CreateFormStepParams{
-					ID:               stepID,
-					FormDefinitionID: form.ID,
-					StepNumber:       int32(step.StepNumber),
-					Name:             step.Name,
-					Description:      step.Description,
-					IsOptional:       step.IsOptional,
-				})
-				if err != nil {
-					return err
-				}
-			}
-		} else {
-			stepNum := int32(1)
-			stepID := uuid.New()
-			stepMap[int(stepNum)] = stepID
-			_, err := q.CreateFormStep(ctx, CreateFormStepParams{
-				ID:               stepID,
-				FormDefinitionID: form.ID,
-				StepNumber:       stepNum,
-				Name:             input.Name,
-				Description:      input.Description,
-				IsOptional:       false,
-			})
-			if err != nil {
-				return err
-			}
+
+		if _, err := q.CreatePersistenceConfig(ctx, configParams); err != nil {
+			return FormDefinition{}, err
 		}
+	}
 
-		// Create fields
-		for _, field := range input.Fields {
-			fieldParams := CreateFormFieldParams{
-				ID:               uuid.New(),
-				FormDefinitionID: form.ID,
-				FieldName:        field.FieldName,
-				FieldType:        field.FieldType,
-				DisplayOrder:     int32(field.DisplayOrder),
-				IsRequired:       field.IsRequired,
-				IsReadonly:       field.IsReadonly,
-			}
+	return form, nil
+}
 
-			// Handle step assignment
-			if field.StepNumber > 0 {
-				if stepID, ok := stepMap[field.StepNumber]; ok {
-					fieldParams.FormStepID = stepID
-				}
-			}
+// persistenceConfigParams marshals a persistence config for storage
+func persistenceConfigParams(formID uuid.UUID, input *PersistenceConfigInput) (CreatePersistenceConfigParams, error) {
+	configParams := CreatePersistenceConfigParams{
+		ID:               uuid.New(),
+		FormDefinitionID: formID,
+		PersistenceMode:  input.PersistenceMode,
+	}
 
-			// Marshal JSON fields
-			if field.Label != nil {
-				labelJSON, err := json.Marshal(field.Label)
-				if err != nil {
-					return err
-				}
-				fieldParams.Label = labelJSON
-			} else {
-				fieldParams.Label = json.RawMessage("{}")
-			}
+	targetJSON, err := json.Marshal(input.TargetConfigs)
+	if err != nil {
+		return configParams, err
+	}
+	configParams.TargetConfigs = targetJSON
 
-			if field.Placeholder != nil {
-				placeholderJSON, err := json.Marshal(field.Placeholder)
-				if err != nil {
-					return err
-				}
-				fieldParams.Placeholder = placeholderJSON
-			} else {
-				fieldParams.Placeholder = json.RawMessage("{}")
-			}
+	mappingsJSON, err := json.Marshal(input.FieldMappings)
+	if err != nil {
+		return configParams, err
+	}
+	configParams.FieldMappings = mappingsJSON
 
-			if field.HelpText != nil {
-				helpTextJSON, err := json.Marshal(field.HelpText)
-				if err != nil {
-					return err
-				}
-				fieldParams.HelpText = helpTextJSON
-			} else {
-				fieldParams.HelpText = json.RawMessage("{}")
-			}
+	targets, mappings, err := ParsePersistenceConfig(FormPersistenceConfig{
+		TargetConfigs: targetJSON,
+		FieldMappings: mappingsJSON,
+	})
+	if err != nil {
+		return configParams, err
+	}
+	if err := ValidatePersistenceConfig(input.PersistenceMode, targets, mappings); err != nil {
+		return configParams, err
+	}
 
-			if field.ValidationRules != nil {
-				rulesJSON, err := json.Marshal(field.ValidationRules)
-				if err != nil {
-					return err
-				}
-				fieldParams.ValidationRules = rulesJSON
-			} else {
-				fieldParams.ValidationRules = json.RawMessage("{}")
-			}
+	if input.TransformationRules != nil {
+		rulesJSON, err := json.Marshal(input.TransformationRules)
+		if err != nil {
+			return configParams, err
+		}
+		configParams.TransformationRules = rulesJSON
+	} else {
+		configParams.TransformationRules = json.RawMessage("{}")
+	}
+
+	if input.ValidationHooks != nil {
+		hooksJSON, err := json.Marshal(input.ValidationHooks)
+		if err != nil {
+			return configParams, err
+		}
+		configParams.ValidationHooks = hooksJSON
+	} else {
+		configParams.ValidationHooks = json.RawMessage("{}")
+	}
+
+	return configParams, nil
+}
+
+// createFormStructure creates the steps and fields of a form version
+func createFormStructure(ctx context.Context, q *Queries, formID uuid.UUID, version int32, input *FormDefinitionInput) error {
+	// Create steps if multi-step
+	stepMap := make(map[int]uuid.UUID)
+	if input.IsMultiStep && len(input.Steps) > 0 {
+		for _, step := range input.Steps {
+			stepID := uuid.New()
+			stepMap[step.StepNumber] = stepID
 
-			if field.Options != nil {
-				optionsJSON, err := json.Marshal(field.Options)
+			rulesJSON := json.RawMessage("{}")
+			if step.ValidationRules != nil {
+				var err error
+				rulesJSON, err = json.Marshal(step.ValidationRules)
 				if err != nil {
 					return err
 				}
-				fieldParams.Options = optionsJSON
-			} else {
-				fieldParams.Options = json.RawMessage("{}")
 			}
 
-			if field.DefaultValue != nil {
-				fieldParams.DefaultValue = *field.DefaultValue
+			_, err := q.CreateFormStep(ctx, CreateFormStepParams{
+				ID:               stepID,
+				FormDefinitionID: formID,
+				StepNumber:       int32(step.StepNumber),
+				Name:             step.Name,
+				Description:      step.Description,
+				IsOptional:       step.IsOptional,
+				Version:          version,
+				ValidationRules:  rulesJSON,
+			})
+			if err != nil {
+				return err
 			}
+		}
+	} else {
+		stepNum := int32(1)
+		stepID := uuid.New()
+		stepMap[int(stepNum)] = stepID
+		_, err := q.CreateFormStep(ctx, CreateFormStepParams{
+			ID:               stepID,
+			FormDefinitionID: formID,
+			StepNumber:       stepNum,
+			Name:             input.Name,
+			Description:      input.Description,
+			IsOptional:       false,
+			Version:          version,
+			ValidationRules:  json.RawMessage("{}"),
+		})
+		if err != nil {
+			return err
+		}
+	}
 
-			if field.ConditionalLogic != nil {
-				logicJSON, err := json.Marshal(field.ConditionalLogic)
-				if err != nil {
-					return err
-				}
-				fieldParams.ConditionalLogic = logicJSON
-			} else {
-				fieldParams.ConditionalLogic = json.RawMessage("{}")
+	// Create fields
+	for _, field := range input.Fields {
+		fieldParams := CreateFormFieldParams{
+			ID:               uuid.New(),
+			FormDefinitionID: formID,
+			FieldName:        field.FieldName,
+			FieldType:        field.FieldType,
+			DisplayOrder:     int32(field.DisplayOrder),
+			IsRequired:       field.IsRequired,
+			IsReadonly:       field.IsReadonly,
+			Version:          version,
+		}
+
+		// Handle step assignment
+		if field.StepNumber > 0 {
+			if stepID, ok := stepMap[field.StepNumber]; ok {
+				fieldParams.FormStepID = stepID
 			}
+		}
 
-			if field.FileConfig != nil {
-				configJSON, err := json.Marshal(field.FileConfig)
-				if err != nil {
-					return err
-				}
-				fieldParams.FileConfig = configJSON
-			} else {
-				fieldParams.FileConfig = json.RawMessage("{}")
+		// Marshal JSON fields
+		if field.Label != nil {
+			labelJSON, err := json.Marshal(field.Label)
+			if err != nil {
+				return err
 			}
+			fieldParams.Label = labelJSON
+		} else {
+			fieldParams.Label = json.RawMessage("{}")
+		}
 
-			_, err = q.CreateFormField(ctx, fieldParams)
+		if field.Placeholder != nil {
+			placeholderJSON, err := json.Marshal(field.Placeholder)
 			if err != nil {
 				return err
 			}
+			fieldParams.Placeholder = placeholderJSON
+		} else {
+			fieldParams.Placeholder = json.RawMessage("{}")
 		}
 
-		// Create persistence config if provided
-		if input.PersistenceConfig != nil {
-			configParams := CreatePersistenceConfigParams{
-				ID:               uuid.New(),
-				FormDefinitionID: form.ID,
-				PersistenceMode:  input.PersistenceConfig.PersistenceMode,
+		if field.HelpText != nil {
+			helpTextJSON, err := json.Marshal(field.HelpText)
+			if err != nil {
+				return err
 			}
+			fieldParams.HelpText = helpTextJSON
+		} else {
+			fieldParams.HelpText = json.RawMessage("{}")
+		}
 
-			targetJSON, err := json.Marshal(input.PersistenceConfig.TargetConfigs)
+		if field.ValidationRules != nil {
+			rulesJSON, err := json.Marshal(field.ValidationRules)
 			if err != nil {
 				return err
 			}
-			configParams.TargetConfigs = targetJSON
+			fieldParams.ValidationRules = rulesJSON
+		} else {
+			fieldParams.ValidationRules = json.RawMessage("{}")
+		}
 
-			mappingsJSON, err := json.Marshal(input.PersistenceConfig.FieldMappings)
+		if field.Options != nil {
+			optionsJSON, err := json.Marshal(field.Options)
 			if err != nil {
 				return err
 			}
-			configParams.FieldMappings = mappingsJSON
+			fieldParams.Options = optionsJSON
+		} else {
+			fieldParams.Options = json.RawMessage("{}")
+		}
 
-			if input.PersistenceConfig.TransformationRules != nil {
-				rulesJSON, err := json.Marshal(input.PersistenceConfig.TransformationRules)
-				if err != nil {
-					return err
-				}
-				configParams.TransformationRules = rulesJSON
-			} else {
-				configParams.TransformationRules = json.RawMessage("{}")
-			}
+		if field.DefaultValue != nil {
+			fieldParams.DefaultValue = *field.DefaultValue
+		}
 
-			if input.PersistenceConfig.ValidationHooks != nil {
-				hooksJSON, err := json.Marshal(input.PersistenceConfig.ValidationHooks)
-				if err != nil {
-					return err
-				}
-				configParams.ValidationHooks = hooksJSON
-			} else {
-				configParams.ValidationHooks = json.RawMessage("{}")
+		if field.ConditionalLogic != nil {
+			logicJSON, err := json.Marshal(field.ConditionalLogic)
+			if err != nil {
+				return err
 			}
+			fieldParams.ConditionalLogic = logicJSON
+		} else {
+			fieldParams.ConditionalLogic = json.RawMessage("{}")
+		}
 
-			_, err = q.CreatePersistenceConfig(ctx, configParams)
+		if field.FileConfig != nil {
+			configJSON, err := json.Marshal(field.FileConfig)
 			if err != nil {
 				return err
 			}
+			fieldParams.FileConfig = configJSON
+		} else {
+			fieldParams.FileConfig = json.RawMessage("{}")
 		}
 
-		return nil
-	})
+		if field.Prefill != nil {
+			prefillJSON, err := json.Marshal(field.Prefill)
+			if err != nil {
+				return err
+			}
+			fieldParams.Prefill = prefillJSON
+		} else {
+			fieldParams.Prefill = json.RawMessage("{}")
+		}
 
-	if err != nil {
-		return nil, err
+		_, err := q.CreateFormField(ctx, fieldParams)
+		if err != nil {
+			return err
+		}
 	}
 
-	return &form, nil
+	return nil
 }
 
 // ProcessFormSubmissionTx processes a form submission with data persistence
-func (store *SQLStore) ProcessFormSubmissionTx(ctx context.Context, input *FormSubmissionInput) (*FormSubmission, error) {
+func (store *SQLStore) ProcessFormSubmissionTx(ctx context.Context, input *FormSubmissionInput, events FormEventsFunc[*FormSubmission]) (*FormSubmission, error) {
 	var submission FormSubmission
 
 	err := store.execTx(ctx, func(q *Queries) error {
-		// Get persistence config
+		// Write mapped fields to their tables before the submission record
 		config, err := q.GetPersistenceConfig(ctx, input.FormDefinitionID)
 		if err != nil {
 			return fmt.Errorf("failed to get persistence config: %w", err)
 		}
-
-		// Parse config
-		var persistenceMode string
-		var fieldMappings map[string]FieldMapping
-		var targetConfigs []TargetConfig
-
-		persistenceMode = config.PersistenceMode
-
-		if err := json.Unmarshal(config.FieldMappings, &fieldMappings); err != nil {
-			return fmt.Errorf("failed to parse field mappings: %w", err)
-		}
-
-		if err := json.Unmarshal(config.TargetConfigs, &targetConfigs); err != nil {
-			return fmt.Errorf("failed to parse target configs: %w", err)
-		}
-
-		// Process based on persistence mode
-		switch persistenceMode {
-		case "direct":
-			err = store.persistDirectMode(ctx, q, targetConfigs[0].TableName, fieldMappings, input.Data)
-			if err != nil {
-				return err
-			}
-
-		case "multi_table":
-			err = store.persistMultiTableMode(ctx, q, fieldMappings, input.Data)
-			if err != nil {
-				return err
-			}
-
-		case "json":
-			// Data will be stored in submission record
+		if err := persistFormData(ctx, q, config, input.UserID, input.Data); err != nil {
+			return err
 		}
 
 		// Create submission record
-		dataJSON, err := json.Marshal(input.Data)
+		dataJSON, err := sealSubmissionData(ctx, q, input.FormDefinitionID, input.Data)
 		if err != nil {
 			return err
 		}
@@ -292,6 +324,16 @@ func (store *SQLStore) ProcessFormSubmissionTx(ctx context.Context, input *FormS
 			return err
 		}
 
+		// Pin the submission to the version it was started on
+		formVersion := input.FormVersion
+		if formVersion == 0 {
+			form, err := q.GetFormDefinition(ctx, input.FormDefinitionID)
+			if err != nil {
+				return err
+			}
+			formVersion = form.Version
+		}
+
 		submissionParams := CreateFormSubmissionParams{
 			ID:               uuid.New(),
 			FormDefinitionID: input.FormDefinitionID,
@@ -299,35 +341,25 @@ func (store *SQLStore) ProcessFormSubmissionTx(ctx context.Context, input *FormS
 			SubmissionData:   dataJSON,
 			Status:           input.Status,
 			Metadata:         metadataJSON,
+			FormVersion:      formVersion,
 		}
 
 		createdSubmission, err := q.CreateFormSubmission(ctx, submissionParams)
 		if err != nil {
 			return err
 		}
-		submission = createdSubmission
+		submission = openFormSubmission(createdSubmission)
 
 		// Save files
-		for _, file := range input.Files {
-			fileParams := CreateFormSubmissionFileParams{
-				ID:               uuid.New(),
-				FormSubmissionID: submission.ID,
-				FieldName:        file.FieldName,
-				FileName:         file.FileName,
-				FilePath:         file.FilePath,
-				FileSize:         file.FileSize,
-				MimeType:         file.MimeType,
-				Bucket:           file.Bucket,
-				StorageProvider:  file.StorageProvider,
-			}
+		if err := createSubmissionFiles(ctx, q, submission.ID, input.Files); err != nil {
+			return err
+		}
 
-			_, err := q.CreateFormSubmissionFile(ctx, fileParams)
-			if err != nil {
-				return err
-			}
+		if err := recordSubmissionRevision(ctx, q, submission.ID, RevisionSourceCreate, input.UserID, sql.NullInt32{}, sql.NullInt32{}); err != nil {
+			return err
 		}
 
-		return nil
+		return enqueueFormEvents(ctx, q, events, &submission)
 	})
 
 	if err != nil {
@@ -337,129 +369,26 @@ func (store *SQLStore) ProcessFormSubmissionTx(ctx context.Context, input *FormS
 	return &submission, nil
 }
 
-// persistDirectMode saves to a single target table
-func (store *SQLStore) persistDirectMode(ctx context.Context, q *Queries, tableName string, mappings map[string]FieldMapping, data map[string]interface{}) error {
-	// Build dynamic SQL based on table name and mappings
-	columns := []string{}
-	values := []interface{}{}
-	placeholders := []string{}
-
-	i := 1
-	for formField, value := range data {
-		if mapping, ok := mappings[formField]; ok && mapping.TableName == tableName {
-			columns = append(columns, mapping.ColumnName)
-			values = append(values, value)
-			placeholders = append(placeholders, fmt.Sprintf("$%d", i))
-			i++
-		}
-	}
-
-	if len(columns) == 0 {
-		return nil
-	}
-
-	// Execute dynamic insert
-	query := fmt.Sprintf(
-		"INSERT INTO %s (%s) VALUES (%s)",
-		tableName,
-		strings.Join(columns, ", "),
-		strings.Join(placeholders, ", "),
-	)
-
-	_, err := q.db.ExecContext(ctx, query, values...)
-	return err
-}
-
-// persistMultiTableMode saves to multiple tables
-func (store *SQLStore) persistMultiTableMode(ctx context.Context, q *Queries, mappings map[string]FieldMapping, data map[string]interface{}) error {
-	// Group by table
-	tableData := make(map[string]map[string]interface{})
-
-	for formField, value := range data {
-		if mapping, ok := mappings[formField]; ok {
-			if tableData[mapping.TableName] == nil {
-				tableData[mapping.TableName] = make(map[string]interface{})
-			}
-			tableData[mapping.TableName][mapping.ColumnName] = value
-		}
-	}
-
-	// Insert into each table
-	for tableName, tableValues := range tableData {
-		columns := []string{}
-		values := []interface{}{}
-		placeholders := []string{}
-
-		i := 1
-		for column, value := range tableValues {
-			columns = append(columns, column)
-			values = append(values, value)
-			placeholders = append(placeholders, fmt.Sprintf("$%d", i))
-			i++
-		}
-
-		query := fmt.Sprintf(
-			"INSERT INTO %s (%s) VALUES (%s)",
-			tableName,
-			strings.Join(columns, ", "),
-			strings.Join(placeholders, ", "),
-		)
-
-		_, err := q.db.ExecContext(ctx, query, values...)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+// isGroupMapping reports whether the mapping writes the items of a group field to a child table
+func (m FieldMapping) isGroupMapping() bool {
+	return len(m.ItemMappings) > 0
 }
 
 // UpdateFormSubmissionTx updates a form submission with data persistence
-func (store *SQLStore) UpdateFormSubmissionTx(ctx context.Context, input *FormSubmissionUpdateInput) (*FormSubmission, error) {
+func (store *SQLStore) UpdateFormSubmissionTx(ctx context.Context, input *FormSubmissionUpdateInput, events FormEventsFunc[*FormSubmission]) (*FormSubmission, error) {
 	var submission FormSubmission
 
 	err := store.execTx(ctx, func(q *Queries) error {
-		// Get persistence config
-
-		// Parse config
-		var fieldMappings map[string]FieldMapping
-		var targetConfigs []TargetConfig
-		config, err := q.GetPersistenceConfig(ctx, input.FormDefinitionID)
+		// Lock the existing submission to preserve approval fields and check the revision
+		// the update was based on
+		existingSubmission, err := lockFormSubmission(ctx, q, input.SubmissionID)
 		if err != nil {
-			// If no persistence config, just update submission
-			// This is not an error - some forms only store in JSON
-			goto updateSubmission
-		}
-
-		if err := json.Unmarshal(config.FieldMappings, &fieldMappings); err != nil {
-			return fmt.Errorf("failed to parse field mappings: %w", err)
-		}
-
-		if err := json.Unmarshal(config.TargetConfigs, &targetConfigs); err != nil {
-			return fmt.Errorf("failed to parse target configs: %w", err)
+			return err
 		}
-
-		// Process based on persistence mode if status is changing to submitted
-		if input.Status == "submitted" {
-			switch config.PersistenceMode {
-			case "direct":
-				err = store.persistDirectMode(ctx, q, targetConfigs[0].TableName, fieldMappings, input.Data)
-				if err != nil {
-					return fmt.Errorf("failed to persist direct mode: %w", err)
-				}
-
-			case "multi_table":
-				err = store.persistMultiTableMode(ctx, q, fieldMappings, input.Data)
-				if err != nil {
-					return fmt.Errorf("failed to persist multi-table mode: %w", err)
-				}
-
-			case "json":
-				// Data will be stored in submission record
-			}
+		if err := checkSubmissionRevision(ctx, q, existingSubmission, input.ExpectedRevision, input.Data); err != nil {
+			return err
 		}
 
-	updateSubmission:
 		// Prepare final data
 		var finalData map[string]interface{}
 		if input.IsPartialUpdate && input.ExistingData != nil {
@@ -476,19 +405,26 @@ func (store *SQLStore) UpdateFormSubmissionTx(ctx context.Context, input *FormSu
 			finalData = input.Data
 		}
 
-		// Update submission record
-		dataJSON, err := json.Marshal(finalData)
-		if err != nil {
-			return err
+		// Write mapped fields to their tables once the submission is submitted. Forms without
+		// a persistence config only store the submission.
+		if input.Status == "submitted" {
+			config, err := q.GetPersistenceConfig(ctx, input.FormDefinitionID)
+			if err == nil {
+				if err := persistFormData(ctx, q, config, existingSubmission.UserID, finalData); err != nil {
+					return err
+				}
+			} else if !errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("failed to get persistence config: %w", err)
+			}
 		}
 
-		metadataJSON, err := json.Marshal(input.Metadata)
+		// Update submission record
+		dataJSON, err := sealSubmissionData(ctx, q, existingSubmission.FormDefinitionID, finalData)
 		if err != nil {
 			return err
 		}
 
-		// Get existing submission to preserve approval fields
-		existingSubmission, err := q.GetFormSubmission(ctx, input.SubmissionID)
+		metadataJSON, err := json.Marshal(input.Metadata)
 		if err != nil {
 			return err
 		}
@@ -508,29 +444,22 @@ func (store *SQLStore) UpdateFormSubmissionTx(ctx context.Context, input *FormSu
 		if err != nil {
 			return err
 		}
-		submission = updatedSubmission
+		submission = openFormSubmission(updatedSubmission)
 
 		// Save new files
-		for _, file := range input.Files {
-			fileParams := CreateFormSubmissionFileParams{
-				ID:               uuid.New(),
-				FormSubmissionID: submission.ID,
-				FieldName:        file.FieldName,
-				FileName:         file.FileName,
-				FilePath:         file.FilePath,
-				FileSize:         file.FileSize,
-				MimeType:         file.MimeType,
-				Bucket:           file.Bucket,
-				StorageProvider:  file.StorageProvider,
-			}
+		if err := createSubmissionFiles(ctx, q, submission.ID, input.Files); err != nil {
+			return err
+		}
 
-			_, err := q.CreateFormSubmissionFile(ctx, fileParams)
-			if err != nil {
-				return err
-			}
+		source := input.RevisionSource
+		if source == "" {
+			source = RevisionSourceUpdate
+		}
+		if err := recordSubmissionRevision(ctx, q, submission.ID, source, input.UserID, sql.NullInt32{}, sql.NullInt32{}); err != nil {
+			return err
 		}
 
-		return nil
+		return enqueueFormEvents(ctx, q, events, &submission)
 	})
 
 	if err != nil {
@@ -540,33 +469,68 @@ func (store *SQLStore) UpdateFormSubmissionTx(ctx context.Context, input *FormSu
 	return &submission, nil
 }
 
-func (store *SQLStore) SaveStepProgressTx(ctx context.Context, input *SaveStepProgressInput) error {
+// SaveStepProgressTx saves the data of a step and merges it into the submission. With an
+// expected revision, the save fails with a RevisionConflictError when the step was saved since.
+func (store *SQLStore) SaveStepProgressTx(ctx context.Context, input *SaveStepProgressInput, events FormEventsFunc[FormStepProgress]) error {
 	return store.execTx(ctx, func(q *Queries) error {
+		// Lock the submission first so that saves of its steps are serialised
+		submission, err := lockFormSubmission(ctx, q, input.SubmissionID)
+		if err != nil {
+			return err
+		}
+
 		// Check if progress exists
 		existing, err := q.GetStepProgress(ctx, GetStepProgressParams{
 			FormSubmissionID: NewNullUUID(input.SubmissionID),
 			FormStepID:       NewNullUUID(input.StepID),
 		})
-
-		dataJSON, err := json.Marshal(input.Data)
-		if err != nil {
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
 			return err
 		}
 
-		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		if input.ExpectedRevision != 0 && input.ExpectedRevision != existing.Revision {
+			base, err := stepRevisionData(ctx, q, submission.ID, input.StepNumber, input.ExpectedRevision)
+			if err != nil {
+				return err
+			}
+
+			// Only the fields of this step are compared
+			fields := make(map[string]bool, len(input.Data))
+			for key := range input.Data {
+				fields[key] = true
+			}
+			if existing.Data.Valid {
+				for key := range decodeSubmissionData(existing.Data.RawMessage) {
+					fields[key] = true
+				}
+			}
+
+			stepNumber := input.StepNumber
+			return &RevisionConflictError{
+				SubmissionID:     submission.ID,
+				StepNumber:       &stepNumber,
+				ExpectedRevision: input.ExpectedRevision,
+				CurrentRevision:  existing.Revision,
+				Changes:          diffSubmissionData(base, decodeSubmissionData(submission.SubmissionData), input.Data, fields),
+			}
+		}
+
+		dataJSON, err := sealSubmissionData(ctx, q, submission.FormDefinitionID, input.Data)
+		if err != nil {
 			return err
 		}
 
+		var progress FormStepProgress
 		if existing.ID != uuid.Nil {
 			// Update existing
-			_, err = q.UpdateStepProgress(ctx, UpdateStepProgressParams{
+			progress, err = q.UpdateStepProgress(ctx, UpdateStepProgressParams{
 				ID:     existing.ID,
 				Status: NewNullString(input.Status),
 				Data:   NullRawMessage(dataJSON),
 			})
 		} else {
 			// Create new
-			_, err = q.CreateStepProgress(ctx, CreateStepProgressParams{
+			progress, err = q.CreateStepProgress(ctx, CreateStepProgressParams{
 				ID:               uuid.New(),
 				FormSubmissionID: NewNullUUID(input.SubmissionID),
 				FormStepID:       NewNullUUID(input.StepID),
@@ -580,42 +544,54 @@ func (store *SQLStore) SaveStepProgressTx(ctx context.Context, input *SaveStepPr
 			return err
 		}
 
-		// Also update main submission with partial data
-		submission, err := q.GetFormSubmission(ctx, input.SubmissionID)
-		if err != nil {
-			return err
-		}
-
 		// Merge step data into submission data
-		var existingData map[string]interface{}
-		if submission.SubmissionData != nil {
-			json.Unmarshal(submission.SubmissionData, &existingData)
-		} else {
-			existingData = make(map[string]interface{})
-		}
+		existingData := decodeSubmissionData(submission.SubmissionData)
 
 		// Merge new data
 		for key, value := range input.Data {
 			existingData[key] = value
 		}
 
-		mergedJSON, err := json.Marshal(existingData)
-		if err != nil {
+		// Update submission with merged data
+		if _, err := saveSubmissionData(ctx, q, submission, existingData); err != nil {
 			return err
 		}
 
-		// Update submission with merged data
-		_, err = q.UpdateFormSubmission(ctx, UpdateFormSubmissionParams{
-			ID:             input.SubmissionID,
-			SubmissionData: mergedJSON,
-			Status:         submission.Status, // Keep current status
-			ApprovalStatus: submission.ApprovalStatus,
-			ApprovalNotes:  submission.ApprovalNotes,
-			ApprovedBy:     submission.ApprovedBy,
-			ApprovedAt:     submission.ApprovedAt,
-			Metadata:       submission.Metadata,
-		})
+		if err := createSubmissionFiles(ctx, q, submission.ID, input.Files); err != nil {
+			return err
+		}
 
-		return err
+		if err := recordSubmissionRevision(ctx, q, submission.ID, RevisionSourceStep, input.UserID,
+			NewNullInt32(input.StepNumber), NewNullInt32(progress.Revision)); err != nil {
+			return err
+		}
+
+		return enqueueFormEvents(ctx, q, events, progress)
 	})
 }
+
+// createSubmissionFiles records the files uploaded with a submission
+func createSubmissionFiles(ctx context.Context, q *Queries, submissionID uuid.UUID, files []FormSubmissionFileInput) error {
+	for _, file := range files {
+		fileParams := CreateFormSubmissionFileParams{
+			ID:               uuid.New(),
+			FormSubmissionID: submissionID,
+			FieldName:        file.FieldName,
+			FileName:         file.FileName,
+			FilePath:         file.FilePath,
+			FileSize:         file.FileSize,
+			MimeType:         file.MimeType,
+			Bucket:           file.Bucket,
+			StorageProvider:  file.StorageProvider,
+			ScanStatus:       file.ScanStatus,
+			ScanSignature:    file.ScanSignature,
+			ScannedAt:        file.ScannedAt,
+		}
+
+		if _, err := q.CreateFormSubmissionFile(ctx, fileParams); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,715 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sqlc-dev/pqtype"
+)
+
+const (
+	FormVersionStatusDraft     = "draft"
+	FormVersionStatusPublished = "published"
+	FormVersionStatusArchived  = "archived"
+)
+
+var (
+	ErrFormVersionNotDraft = errors.New("only a draft version can be published")
+	ErrFormVersionNotFound = errors.New("form version not found")
+)
+
+// FormVersion is an immutable snapshot of a form definition's metadata. The steps and fields
+// of a version are the form_steps and form_fields rows carrying the same version number.
+// A form has at most one draft version, which becomes current when published.
+type FormVersion struct {
+	ID                        uuid.UUID             `json:"id"`
+	FormDefinitionID          uuid.UUID             `json:"form_definition_id"`
+	Version                   int32                 `json:"version"`
+	Status                    string                `json:"status"`
+	Name                      string                `json:"name"`
+	Description               string                `json:"description"`
+	IsMultiStep               bool                  `json:"is_multi_step"`
+	RequiresApproval          bool                  `json:"requires_approval"`
+	IsEditableAfterSubmission bool                  `json:"is_editable_after_submission"`
+	ApprovalWorkflow          json.RawMessage       `json:"approval_workflow"`
+	PersistenceConfig         pqtype.NullRawMessage `json:"persistence_config"`
+	ChangeNote                string                `json:"change_note"`
+	CreatedBy                 uuid.NullUUID         `json:"created_by"`
+	CreatedAt                 time.Time             `json:"created_at"`
+	PublishedBy               uuid.NullUUID         `json:"published_by"`
+	PublishedAt               sql.NullTime          `json:"published_at"`
+}
+
+// FormDraftVersionInput stages changes to a form. When Definition has no fields, the draft
+// keeps the steps and fields of the version it is based on and only the metadata changes.
+type FormDraftVersionInput struct {
+	FormDefinitionID uuid.UUID            `json:"form_definition_id"`
+	Definition       *FormDefinitionInput `json:"definition"`
+	ChangeNote       string               `json:"change_note"`
+}
+
+// FormSubmissionMigrationInput moves draft submissions from one version of a form to another.
+// FieldMappings renames answers from an old field name to a new one; mapping a field to ""
+// drops its answer. Answers for fields that do not exist in the target version are dropped.
+type FormSubmissionMigrationInput struct {
+	FormDefinitionID uuid.UUID         `json:"form_definition_id"`
+	FromVersion      int32             `json:"from_version"`
+	ToVersion        int32             `json:"to_version"`
+	FieldMappings    map[string]string `json:"field_mappings"`
+}
+
+const formVersionColumns = `id, form_definition_id, version, status, name, description, is_multi_step,
+    requires_approval, is_editable_after_submission, approval_workflow, persistence_config,
+    change_note, created_by, created_at, published_by, published_at`
+
+func scanFormVersion(row interface{ Scan(...interface{}) error }) (FormVersion, error) {
+	var i FormVersion
+	err := row.Scan(
+		&i.ID,
+		&i.FormDefinitionID,
+		&i.Version,
+		&i.Status,
+		&i.Name,
+		&i.Description,
+		&i.IsMultiStep,
+		&i.RequiresApproval,
+		&i.IsEditableAfterSubmission,
+		&i.ApprovalWorkflow,
+		&i.PersistenceConfig,
+		&i.ChangeNote,
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.PublishedBy,
+		&i.PublishedAt,
+	)
+	return i, err
+}
+
+// createFormVersion records the metadata of a form's current version. It is a no-op when
+// the version already exists, which backfills forms created before versioning.
+func createFormVersion(ctx context.Context, q *Queries, form FormDefinition, status, changeNote string, createdBy uuid.NullUUID) error {
+	var publishedAt sql.NullTime
+	if status == FormVersionStatusPublished {
+		publishedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+
+	_, err := q.db.ExecContext(ctx, `
+INSERT INTO form_versions (
+    id, form_definition_id, version, status, name, description, is_multi_step,
+    requires_approval, is_editable_after_submission, approval_workflow,
+    change_note, created_by, published_by, published_at
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $12, $13)
+ON CONFLICT (form_definition_id, version) DO NOTHING`,
+		uuid.New(),
+		form.ID,
+		form.Version,
+		status,
+		form.Name,
+		form.Description,
+		form.IsMultiStep,
+		form.RequiresApproval,
+		form.IsEditableAfterSubmission,
+		form.ApprovalWorkflow,
+		changeNote,
+		createdBy,
+		publishedAt,
+	)
+	return err
+}
+
+// lockFormDefinition serialises version changes to a form for the rest of the transaction
+func lockFormDefinition(ctx context.Context, q *Queries, formID uuid.UUID) (FormDefinition, error) {
+	if _, err := q.db.ExecContext(ctx, `SELECT id FROM form_definitions WHERE id = $1 FOR UPDATE`, formID); err != nil {
+		return FormDefinition{}, err
+	}
+	return q.GetFormDefinition(ctx, formID)
+}
+
+func getFormVersion(ctx context.Context, q *Queries, formID uuid.UUID, version int32) (FormVersion, error) {
+	row := q.db.QueryRowContext(ctx, `SELECT `+formVersionColumns+` FROM form_versions
+WHERE form_definition_id = $1 AND version = $2`, formID, version)
+
+	v, err := scanFormVersion(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return v, ErrFormVersionNotFound
+	}
+	return v, err
+}
+
+func (store *SQLStore) GetFormVersion(ctx context.Context, formID uuid.UUID, version int32) (FormVersion, error) {
+	return getFormVersion(ctx, store.Queries, formID, version)
+}
+
+func (store *SQLStore) ListFormVersions(ctx context.Context, formID uuid.UUID) ([]FormVersion, error) {
+	rows, err := store.db.QueryContext(ctx, `SELECT `+formVersionColumns+` FROM form_versions
+WHERE form_definition_id = $1
+ORDER BY version DESC`, formID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []FormVersion{}
+	for rows.Next() {
+		i, err := scanFormVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// GetFormStepsByVersion returns the steps of a specific version of a form
+func (store *SQLStore) GetFormStepsByVersion(ctx context.Context, formID uuid.UUID, version int32) ([]FormStep, error) {
+	return getFormStepsByVersion(ctx, store.Queries, formID, version)
+}
+
+// GetFormFieldsByVersion returns the fields of a specific version of a form
+func (store *SQLStore) GetFormFieldsByVersion(ctx context.Context, formID uuid.UUID, version int32) ([]FormField, error) {
+	return getFormFieldsByVersion(ctx, store.Queries, formID, version)
+}
+
+func getFormStepsByVersion(ctx context.Context, q *Queries, formID uuid.UUID, version int32) ([]FormStep, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT id, form_definition_id, step_number, name, description, is_optional, created_at, version, validation_rules FROM form_steps
+WHERE form_definition_id = $1 AND version = $2
+ORDER BY step_number`, formID, version)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []FormStep{}
+	for rows.Next() {
+		var i FormStep
+		if err := rows.Scan(
+			&i.ID,
+			&i.FormDefinitionID,
+			&i.StepNumber,
+			&i.Name,
+			&i.Description,
+			&i.IsOptional,
+			&i.CreatedAt,
+			&i.Version,
+			&i.ValidationRules,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func getFormFieldsByVersion(ctx context.Context, q *Queries, formID uuid.UUID, version int32) ([]FormField, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT id, form_definition_id, form_step_id, field_name, field_type, label, placeholder, help_text, validation_rules, options, display_order, is_required, is_readonly, default_value, conditional_logic, file_config, created_at, updated_at, version, prefill FROM form_fields
+WHERE form_definition_id = $1 AND version = $2
+ORDER BY display_order`, formID, version)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []FormField{}
+	for rows.Next() {
+		var i FormField
+		if err := rows.Scan(
+			&i.ID,
+			&i.FormDefinitionID,
+			&i.FormStepID,
+			&i.FieldName,
+			&i.FieldType,
+			&i.Label,
+			&i.Placeholder,
+			&i.HelpText,
+			&i.ValidationRules,
+			&i.Options,
+			&i.DisplayOrder,
+			&i.IsRequired,
+			&i.IsReadonly,
+			&i.DefaultValue,
+			&i.ConditionalLogic,
+			&i.FileConfig,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Version,
+			&i.Prefill,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// copyFormStructure copies the steps and fields of one version of a form into another
+func copyFormStructure(ctx context.Context, q *Queries, formID uuid.UUID, from, to int32) error {
+	steps, err := getFormStepsByVersion(ctx, q, formID, from)
+	if err != nil {
+		return err
+	}
+
+	stepMap := make(map[uuid.UUID]uuid.UUID, len(steps))
+	for _, step := range steps {
+		stepID := uuid.New()
+		stepMap[step.ID] = stepID
+
+		_, err := q.CreateFormStep(ctx, CreateFormStepParams{
+			ID:               stepID,
+			FormDefinitionID: formID,
+			StepNumber:       step.StepNumber,
+			Name:             step.Name,
+			Description:      step.Description,
+			IsOptional:       step.IsOptional,
+			Version:          to,
+			ValidationRules:  step.ValidationRules,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	fields, err := getFormFieldsByVersion(ctx, q, formID, from)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		_, err := q.CreateFormField(ctx, CreateFormFieldParams{
+			ID:               uuid.New(),
+			FormDefinitionID: formID,
+			FormStepID:       stepMap[field.FormStepID],
+			FieldName:        field.FieldName,
+			FieldType:        field.FieldType,
+			Label:            field.Label,
+			Placeholder:      field.Placeholder,
+			HelpText:         field.HelpText,
+			ValidationRules:  field.ValidationRules,
+			Options:          field.Options,
+			DisplayOrder:     field.DisplayOrder,
+			IsRequired:       field.IsRequired,
+			IsReadonly:       field.IsReadonly,
+			DefaultValue:     field.DefaultValue,
+			ConditionalLogic: field.ConditionalLogic,
+			FileConfig:       field.FileConfig,
+			Version:          to,
+			Prefill:          field.Prefill,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteFormStructure removes the steps and fields of a draft version
+func deleteFormStructure(ctx context.Context, q *Queries, formID uuid.UUID, version int32) error {
+	if _, err := q.db.ExecContext(ctx, `DELETE FROM form_fields WHERE form_definition_id = $1 AND version = $2`, formID, version); err != nil {
+		return err
+	}
+	_, err := q.db.ExecContext(ctx, `DELETE FROM form_steps WHERE form_definition_id = $1 AND version = $2`, formID, version)
+	return err
+}
+
+// SaveFormDraftVersionTx creates the draft version of a form, or replaces the existing draft.
+// The live form and the submissions pinned to it are not affected until the draft is published.
+func (store *SQLStore) SaveFormDraftVersionTx(ctx context.Context, input *FormDraftVersionInput) (*FormVersion, error) {
+	var draft FormVersion
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		draft, err = saveFormDraftVersion(ctx, q, input)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &draft, nil
+}
+
+func saveFormDraftVersion(ctx context.Context, q *Queries, input *FormDraftVersionInput) (FormVersion, error) {
+	form, err := lockFormDefinition(ctx, q, input.FormDefinitionID)
+	if err != nil {
+		return FormVersion{}, err
+	}
+
+	if err := createFormVersion(ctx, q, form, FormVersionStatusPublished, "", form.CreatedBy); err != nil {
+		return FormVersion{}, err
+	}
+
+	row := q.db.QueryRowContext(ctx, `SELECT `+formVersionColumns+` FROM form_versions
+WHERE form_definition_id = $1 AND status = $2`, form.ID, FormVersionStatusDraft)
+	existing, err := scanFormVersion(row)
+	hasDraft := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return FormVersion{}, err
+	}
+
+	version := existing.Version
+	if !hasDraft {
+		if err := q.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) + 1 FROM form_versions WHERE form_definition_id = $1`, form.ID).Scan(&version); err != nil {
+			return FormVersion{}, err
+		}
+	}
+
+	def := input.Definition
+	switch {
+	case len(def.Fields) > 0:
+		if hasDraft {
+			if err := deleteFormStructure(ctx, q, form.ID, version); err != nil {
+				return FormVersion{}, err
+			}
+		}
+		if err := createFormStructure(ctx, q, form.ID, version, def); err != nil {
+			return FormVersion{}, err
+		}
+	case !hasDraft:
+		if err := copyFormStructure(ctx, q, form.ID, form.Version, version); err != nil {
+			return FormVersion{}, err
+		}
+	}
+
+	workflowJSON := json.RawMessage("{}")
+	if def.ApprovalWorkflow != nil {
+		workflowJSON, err = json.Marshal(def.ApprovalWorkflow)
+		if err != nil {
+			return FormVersion{}, err
+		}
+	}
+
+	var persistenceJSON pqtype.NullRawMessage
+	if def.PersistenceConfig != nil {
+		raw, err := json.Marshal(def.PersistenceConfig)
+		if err != nil {
+			return FormVersion{}, err
+		}
+		persistenceJSON = pqtype.NullRawMessage{RawMessage: raw, Valid: true}
+	}
+
+	row = q.db.QueryRowContext(ctx, `
+INSERT INTO form_versions (
+    id, form_definition_id, version, status, name, description, is_multi_step,
+    requires_approval, is_editable_after_submission, approval_workflow,
+    persistence_config, change_note, created_by
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+ON CONFLICT (form_definition_id, version) DO UPDATE SET
+    name = EXCLUDED.name,
+    description = EXCLUDED.description,
+    is_multi_step = EXCLUDED.is_multi_step,
+    requires_approval = EXCLUDED.requires_approval,
+    is_editable_after_submission = EXCLUDED.is_editable_after_submission,
+    approval_workflow = EXCLUDED.approval_workflow,
+    persistence_config = COALESCE(EXCLUDED.persistence_config, form_versions.persistence_config),
+    change_note = EXCLUDED.change_note
+RETURNING `+formVersionColumns,
+		uuid.New(),
+		form.ID,
+		version,
+		FormVersionStatusDraft,
+		def.Name,
+		def.Description,
+		def.IsMultiStep,
+		def.RequiresApproval,
+		def.IsEditableAfterSubmission,
+		workflowJSON,
+		persistenceJSON,
+		input.ChangeNote,
+		NewNullUUID(def.CreatedBy),
+	)
+	return scanFormVersion(row)
+}
+
+// PublishFormVersionTx makes a draft version the current version of its form. New submissions
+// use the published version; existing submissions stay pinned to the version they started on.
+func (store *SQLStore) PublishFormVersionTx(ctx context.Context, formID uuid.UUID, version int32, publishedBy uuid.UUID) (*FormDefinition, error) {
+	var form FormDefinition
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		form, err = publishFormVersion(ctx, q, formID, version, publishedBy)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &form, nil
+}
+
+func publishFormVersion(ctx context.Context, q *Queries, formID uuid.UUID, version int32, publishedBy uuid.UUID) (FormDefinition, error) {
+	var form FormDefinition
+
+	if _, err := lockFormDefinition(ctx, q, formID); err != nil {
+		return FormDefinition{}, err
+	}
+
+	draft, err := getFormVersion(ctx, q, formID, version)
+	if err != nil {
+		return FormDefinition{}, err
+	}
+	if draft.Status != FormVersionStatusDraft {
+		return FormDefinition{}, ErrFormVersionNotDraft
+	}
+
+	if _, err := q.db.ExecContext(ctx, `UPDATE form_versions SET status = $2
+WHERE form_definition_id = $1 AND status = $3`, formID, FormVersionStatusArchived, FormVersionStatusPublished); err != nil {
+		return FormDefinition{}, err
+	}
+
+	if _, err := q.db.ExecContext(ctx, `UPDATE form_versions SET status = $3, published_by = $4, published_at = CURRENT_TIMESTAMP
+WHERE form_definition_id = $1 AND version = $2`, formID, version, FormVersionStatusPublished, NewNullUUID(publishedBy)); err != nil {
+		return FormDefinition{}, err
+	}
+
+	row := q.db.QueryRowContext(ctx, `UPDATE form_definitions SET
+    name = $2, description = $3, is_multi_step = $4, requires_approval = $5,
+    is_editable_after_submission = $6, approval_workflow = $7, version = $8,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING id, name, slug, description, form_type, version, is_active, is_multi_step, requires_approval, is_editable_after_submission, approval_workflow, created_at, updated_at, created_by`,
+		formID,
+		draft.Name,
+		draft.Description,
+		draft.IsMultiStep,
+		draft.RequiresApproval,
+		draft.IsEditableAfterSubmission,
+		draft.ApprovalWorkflow,
+		draft.Version,
+	)
+	if err := row.Scan(
+		&form.ID,
+		&form.Name,
+		&form.Slug,
+		&form.Description,
+		&form.FormType,
+		&form.Version,
+		&form.IsActive,
+		&form.IsMultiStep,
+		&form.RequiresApproval,
+		&form.IsEditableAfterSubmission,
+		&form.ApprovalWorkflow,
+		&form.CreatedAt,
+		&form.UpdatedAt,
+		&form.CreatedBy,
+	); err != nil {
+		return FormDefinition{}, err
+	}
+
+	if !draft.PersistenceConfig.Valid {
+		return form, nil
+	}
+
+	var config PersistenceConfigInput
+	if err := json.Unmarshal(draft.PersistenceConfig.RawMessage, &config); err != nil {
+		return FormDefinition{}, err
+	}
+
+	params, err := persistenceConfigParams(formID, &config)
+	if err != nil {
+		return FormDefinition{}, err
+	}
+
+	_, err = q.GetPersistenceConfig(ctx, formID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		_, err = q.CreatePersistenceConfig(ctx, params)
+	case err == nil:
+		_, err = q.UpdatePersistenceConfig(ctx, UpdatePersistenceConfigParams{
+			FormDefinitionID:    formID,
+			PersistenceMode:     params.PersistenceMode,
+			TargetConfigs:       params.TargetConfigs,
+			FieldMappings:       params.FieldMappings,
+			TransformationRules: params.TransformationRules,
+			ValidationHooks:     params.ValidationHooks,
+		})
+	}
+	if err != nil {
+		return FormDefinition{}, err
+	}
+	if _, err := sealStoredSubmissions(ctx, q, formID); err != nil {
+		return FormDefinition{}, err
+	}
+
+	return form, nil
+}
+
+// MigrateFormSubmissionsTx moves the draft submissions of a form from one version to another,
+// renaming answers and step progress to match the target version. Submitted and approved
+// submissions are never migrated. It returns the number of submissions migrated.
+func (store *SQLStore) MigrateFormSubmissionsTx(ctx context.Context, input *FormSubmissionMigrationInput) (int, error) {
+	migrated := 0
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		if _, err := lockFormDefinition(ctx, q, input.FormDefinitionID); err != nil {
+			return err
+		}
+
+		steps, err := getFormStepsByVersion(ctx, q, input.FormDefinitionID, input.ToVersion)
+		if err != nil {
+			return err
+		}
+		stepIDs := make(map[int32]uuid.UUID, len(steps))
+		for _, step := range steps {
+			stepIDs[step.StepNumber] = step.ID
+		}
+
+		fields, err := getFormFieldsByVersion(ctx, q, input.FormDefinitionID, input.ToVersion)
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			return ErrFormVersionNotFound
+		}
+		fieldNames := make(map[string]bool, len(fields))
+		for _, field := range fields {
+			fieldNames[field.FieldName] = true
+		}
+
+		rows, err := q.db.QueryContext(ctx, `SELECT id, submission_data FROM form_submissions
+WHERE form_definition_id = $1 AND form_version = $2 AND status = 'draft'
+FOR UPDATE`, input.FormDefinitionID, input.FromVersion)
+		if err != nil {
+			return err
+		}
+
+		type draftSubmission struct {
+			id   uuid.UUID
+			data json.RawMessage
+		}
+		var drafts []draftSubmission
+		for rows.Next() {
+			var d draftSubmission
+			if err := rows.Scan(&d.id, &d.data); err != nil {
+				rows.Close()
+				return err
+			}
+			drafts = append(drafts, d)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, d := range drafts {
+			data, err := migrateFormData(openSubmissionData(d.data), input.FieldMappings, fieldNames)
+			if err != nil {
+				return err
+			}
+			if data, err = sealSubmissionJSON(ctx, q, input.FormDefinitionID, data); err != nil {
+				return err
+			}
+
+			if _, err := q.db.ExecContext(ctx, `UPDATE form_submissions SET
+    submission_data = $2, form_version = $3, revision = revision + 1, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1`, d.id, data, input.ToVersion); err != nil {
+				return err
+			}
+			if err := recordSubmissionRevision(ctx, q, d.id, RevisionSourceMigrate, uuid.Nil, sql.NullInt32{}, sql.NullInt32{}); err != nil {
+				return err
+			}
+
+			if err := migrateStepProgress(ctx, q, input.FormDefinitionID, d.id, stepIDs, input.FieldMappings, fieldNames); err != nil {
+				return err
+			}
+
+			for from, to := range input.FieldMappings {
+				if to == "" || to == from {
+					continue
+				}
+				if _, err := q.db.ExecContext(ctx, `UPDATE form_submission_files SET field_name = $3
+WHERE form_submission_id = $1 AND field_name = $2`, d.id, from, to); err != nil {
+					return err
+				}
+			}
+
+			migrated++
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return migrated, nil
+}
+
+// migrateStepProgress points a submission's step progress at the target version's steps,
+// matched by step number. Progress for steps that no longer exist is removed.
+func migrateStepProgress(ctx context.Context, q *Queries, formID, submissionID uuid.UUID, stepIDs map[int32]uuid.UUID, mappings map[string]string, fieldNames map[string]bool) error {
+	progress, err := q.GetAllStepProgress(ctx, NewNullUUID(submissionID))
+	if err != nil {
+		return err
+	}
+
+	for _, p := range progress {
+		stepID, ok := stepIDs[p.StepNumber]
+		if !ok {
+			if _, err := q.db.ExecContext(ctx, `DELETE FROM form_step_progress WHERE id = $1`, p.ID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		data := p.Data
+		if data.Valid {
+			migratedData, err := migrateFormData(openSubmissionData(data.RawMessage), mappings, fieldNames)
+			if err != nil {
+				return err
+			}
+			if data.RawMessage, err = sealSubmissionJSON(ctx, q, formID, migratedData); err != nil {
+				return err
+			}
+		}
+
+		if _, err := q.db.ExecContext(ctx, `UPDATE form_step_progress SET
+    form_step_id = $2, data = $3, revision = revision + 1, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1`, p.ID, NewNullUUID(stepID), data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateFormData renames answers using mappings and keeps only those for fields in fieldNames
+func migrateFormData(raw json.RawMessage, mappings map[string]string, fieldNames map[string]bool) (json.RawMessage, error) {
+	data := make(map[string]interface{})
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return nil, err
+		}
+	}
+
+	// Unmapped answers are copied first so that a renamed answer wins over a stale one
+	migrated := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if _, ok := mappings[key]; !ok && fieldNames[key] {
+			migrated[key] = value
+		}
+	}
+	for key, value := range data {
+		if to, ok := mappings[key]; ok && to != "" && fieldNames[to] {
+			migrated[to] = value
+		}
+	}
+
+	return json.Marshal(migrated)
+}
@@ -333,6 +333,8 @@ type FormField struct {
 	FileConfig       json.RawMessage `json:"file_config"`
 	CreatedAt        time.Time       `json:"created_at"`
 	UpdatedAt        time.Time       `json:"updated_at"`
+	Version          int32           `json:"version"`
+	Prefill          json.RawMessage `json:"prefill"`
 }
 
 type FormPersistenceConfig struct {
@@ -348,13 +350,15 @@ type FormPersistenceConfig struct {
 }
 
 type FormStep struct {
-	ID               uuid.UUID `json:"id"`
-	FormDefinitionID uuid.UUID `json:"form_definition_id"`
-	StepNumber       int32     `json:"step_number"`
-	Name             string    `json:"name"`
-	Description      string    `json:"description"`
-	IsOptional       bool      `json:"is_optional"`
-	CreatedAt        time.Time `json:"created_at"`
+	ID               uuid.UUID       `json:"id"`
+	FormDefinitionID uuid.UUID       `json:"form_definition_id"`
+	StepNumber       int32           `json:"step_number"`
+	Name             string          `json:"name"`
+	Description      string          `json:"description"`
+	IsOptional       bool            `json:"is_optional"`
+	CreatedAt        time.Time       `json:"created_at"`
+	Version          int32           `json:"version"`
+	ValidationRules  json.RawMessage `json:"validation_rules"`
 }
 
 type FormStepProgress struct {
@@ -367,6 +371,7 @@ type FormStepProgress struct {
 	Data             pqtype.NullRawMessage `json:"data"`
 	CreatedAt        sql.NullTime          `json:"created_at"`
 	UpdatedAt        sql.NullTime          `json:"updated_at"`
+	Revision         int32                 `json:"revision"`
 }
 
 type FormSubmission struct {
@@ -384,19 +389,24 @@ type FormSubmission struct {
 	UpdatedAt            time.Time       `json:"updated_at"`
 	CurrentStepNumber    sql.NullInt32   `json:"current_step_number"`
 	CompletionPercentage sql.NullInt32   `json:"completion_percentage"`
+	FormVersion          int32           `json:"form_version"`
+	Revision             int32           `json:"revision"`
 }
 
 type FormSubmissionFile struct {
-	ID               uuid.UUID `json:"id"`
-	FormSubmissionID uuid.UUID `json:"form_submission_id"`
-	FieldName        string    `json:"field_name"`
-	FileName         string    `json:"file_name"`
-	FilePath         string    `json:"file_path"`
-	FileSize         int64     `json:"file_size"`
-	MimeType         string    `json:"mime_type"`
-	Bucket           string    `json:"bucket"`
-	StorageProvider  string    `json:"storage_provider"`
-	UploadedAt       time.Time `json:"uploaded_at"`
+	ID               uuid.UUID    `json:"id"`
+	FormSubmissionID uuid.UUID    `json:"form_submission_id"`
+	FieldName        string       `json:"field_name"`
+	FileName         string       `json:"file_name"`
+	FilePath         string       `json:"file_path"`
+	FileSize         int64        `json:"file_size"`
+	MimeType         string       `json:"mime_type"`
+	Bucket           string       `json:"bucket"`
+	StorageProvider  string       `json:"storage_provider"`
+	UploadedAt       time.Time    `json:"uploaded_at"`
+	ScanStatus       string       `json:"scan_status"`
+	ScanSignature    string       `json:"scan_signature"`
+	ScannedAt        sql.NullTime `json:"scanned_at"`
 }
 
 type IdentityVerificationDatum struct {
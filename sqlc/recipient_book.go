@@ -0,0 +1,480 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	RecipientBookRoleOwner  = "owner"
+	RecipientBookRoleEditor = "editor"
+	RecipientBookRoleViewer = "viewer"
+
+	RecipientBookSortRecent        = "recent"
+	RecipientBookSortLastUsed      = "last_used"
+	RecipientBookSortTransferCount = "transfer_count"
+	RecipientBookSortNickname      = "nickname"
+)
+
+var ValidRecipientBookRoles = []string{RecipientBookRoleEditor, RecipientBookRoleViewer}
+
+var ValidRecipientBookSorts = []string{
+	RecipientBookSortRecent,
+	RecipientBookSortLastUsed,
+	RecipientBookSortTransferCount,
+	RecipientBookSortNickname,
+}
+
+// RecipientProfile holds the address book attributes of a recipient.
+type RecipientProfile struct {
+	RecipientID uuid.UUID     `json:"recipient_id"`
+	Nickname    string        `json:"nickname"`
+	Tags        []string      `json:"tags"`
+	IsFavourite bool          `json:"is_favourite"`
+	BusinessID  uuid.NullUUID `json:"business_id"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// RecipientUsageStats is computed from the ext-transfer transactions sent to a recipient.
+type RecipientUsageStats struct {
+	LastUsedAt    sql.NullTime   `json:"last_used_at"`
+	TotalSent     CurrencyTotals `json:"total_sent"`
+	TransferCount int64          `json:"transfer_count"`
+}
+
+// CurrencyTotals are amounts keyed by currency code. Transfers to a recipient can be paid from
+// wallets in different currencies, so their amounts are only added up per currency.
+type CurrencyTotals map[string]decimal.Decimal
+
+// Scan reads the JSON object recipientUsageJoin aggregates the totals into.
+func (t *CurrencyTotals) Scan(src interface{}) error {
+	totals := CurrencyTotals{}
+	switch v := src.(type) {
+	case nil:
+	case []byte:
+		if err := json.Unmarshal(v, &totals); err != nil {
+			return err
+		}
+	case string:
+		if err := json.Unmarshal([]byte(v), &totals); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("cannot scan %T into CurrencyTotals", src)
+	}
+	*t = totals
+	return nil
+}
+
+// RecipientBookEntry is a recipient together with its profile and usage stats.
+type RecipientBookEntry struct {
+	Recipient
+	Nickname    string        `json:"nickname"`
+	Tags        []string      `json:"tags"`
+	IsFavourite bool          `json:"is_favourite"`
+	BusinessID  uuid.NullUUID `json:"business_id"`
+	RecipientUsageStats
+}
+
+type RecipientBookMember struct {
+	BusinessID uuid.UUID `json:"business_id"`
+	UserID     uuid.UUID `json:"user_id"`
+	Role       string    `json:"role"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type RecipientBookFilter struct {
+	Filter
+	Query          string        `json:"query" form:"query"`
+	Tag            string        `json:"tag" form:"tag"`
+	FavouritesOnly bool          `json:"favourites_only" form:"favourites_only"`
+	SortBy         string        `json:"sort_by" form:"sort_by"`
+	UserID         uuid.UUID     `json:"-"`
+	BusinessID     uuid.NullUUID `json:"-"`
+}
+
+type UpsertRecipientProfileParams struct {
+	RecipientID uuid.UUID `json:"recipient_id"`
+	Nickname    string    `json:"nickname"`
+	Tags        []string  `json:"tags"`
+	IsFavourite bool      `json:"is_favourite"`
+}
+
+// recipientUsageJoin aggregates the ext-transfer transactions that reference a recipient
+// through payload->recipient->id, totalling their amounts per currency. Failed and canceled
+// transfers are not counted.
+//
+// The lookup is served by transactions_ext_transfer_recipient_idx, created in
+// migration/20261018092000_recipient_book.up.sql, so the predicate below must keep the exact
+// same expression and action filter as the index.
+const recipientUsageJoin = `
+LEFT JOIN LATERAL (
+    SELECT MAX(s.last_used_at) AS last_used_at,
+           COALESCE(jsonb_object_agg(s.currency, s.total), '{}') AS total_sent,
+           COALESCE(SUM(s.transfer_count), 0) AS transfer_count
+    FROM (
+        SELECT c.code AS currency,
+               MAX(t.created_at) AS last_used_at,
+               SUM(t.amount) AS total,
+               COUNT(t.id) AS transfer_count
+        FROM transactions t
+        JOIN currencies c ON c.id = t.currency_id
+        WHERE t.action = 'ext-transfer'
+          AND t.payload->'recipient'->>'id' = r.id::text
+          AND t.status NOT IN ('failed', 'canceled')
+        GROUP BY c.code
+    ) s
+) u ON TRUE`
+
+func (store *SQLStore) GetRecipientBook(ctx context.Context, filter *RecipientBookFilter) ([]RecipientBookEntry, Metadata, error) {
+
+	qt, params := buildRecipientBookQuery(filter)
+
+	rows, err := store.db.QueryContext(ctx, qt, params...)
+	if err != nil {
+		return nil, EmptyMetadata, err
+	}
+	defer rows.Close()
+
+	var entries []RecipientBookEntry
+
+	totalRecords := 0
+	for rows.Next() {
+		var i RecipientBookEntry
+		if err := rows.Scan(
+			&totalRecords,
+			&i.ID,
+			&i.UserID,
+			&i.Scheme,
+			&i.Currency,
+			&i.Data,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Nickname,
+			pq.Array(&i.Tags),
+			&i.IsFavourite,
+			&i.BusinessID,
+			&i.LastUsedAt,
+			&i.TotalSent,
+			&i.TransferCount,
+		); err != nil {
+			return nil, EmptyMetadata, err
+		}
+		entries = append(entries, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, EmptyMetadata, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, EmptyMetadata, err
+	}
+
+	metadata := CalculateMetadata(totalRecords, filter.Page, filter.Limit())
+	return entries, metadata, nil
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern, with the ESCAPE '\' the pattern is used with
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func buildRecipientBookQuery(filter *RecipientBookFilter) (string, []interface{}) {
+	var params []interface{}
+	var where []string
+
+	columns := []string{
+		"r.id",
+		"r.user_id",
+		"r.scheme",
+		"r.currency",
+		"r.data",
+		"r.created_at",
+		"r.updated_at",
+		"COALESCE(p.nickname, '')",
+		"COALESCE(p.tags, '{}')",
+		"COALESCE(p.is_favourite, FALSE)",
+		"p.business_id",
+		"u.last_used_at",
+		"u.total_sent",
+		"u.transfer_count",
+	}
+
+	qt := `SELECT count(*) OVER() AS total_records, ` + strings.Join(columns, ", ") +
+		` FROM recipients r LEFT JOIN recipient_profiles p ON p.recipient_id = r.id` + recipientUsageJoin
+
+	if filter.BusinessID.Valid {
+		params = append(params, filter.BusinessID.UUID)
+		where = append(where, fmt.Sprintf("p.business_id = $%d", len(params)))
+	} else {
+		params = append(params, filter.UserID)
+		where = append(where, fmt.Sprintf("r.user_id = $%d", len(params)))
+	}
+
+	if filter.FavouritesOnly {
+		where = append(where, "p.is_favourite = TRUE")
+	}
+
+	if filter.Tag != "" {
+		params = append(params, strings.ToLower(filter.Tag))
+		where = append(where, fmt.Sprintf("$%d = ANY(p.tags)", len(params)))
+	}
+
+	if filter.Query != "" {
+		params = append(params, filter.Query)
+		fts := fmt.Sprintf(
+			"to_tsvector('simple', r.data::text || ' ' || COALESCE(p.nickname, '') || ' ' || array_to_string(COALESCE(p.tags, '{}'), ' ')) @@ plainto_tsquery('simple', $%d)",
+			len(params),
+		)
+
+		params = append(params, "%"+likeEscaper.Replace(strings.ToLower(filter.Query))+"%")
+		like := fmt.Sprintf(`(LOWER(r.data::text) LIKE $%d ESCAPE '\' OR LOWER(COALESCE(p.nickname, '')) LIKE $%d ESCAPE '\')`, len(params), len(params))
+
+		where = append(where, "("+fts+" OR "+like+")")
+	}
+
+	qt += " WHERE " + strings.Join(where, " AND ")
+
+	switch filter.SortBy {
+	case RecipientBookSortLastUsed:
+		qt += ` ORDER BY u.last_used_at DESC NULLS LAST, r.created_at DESC`
+	case RecipientBookSortTransferCount:
+		qt += ` ORDER BY u.transfer_count DESC, r.created_at DESC`
+	case RecipientBookSortNickname:
+		qt += ` ORDER BY LOWER(COALESCE(p.nickname, '')) ASC, r.created_at DESC`
+	default:
+		qt += ` ORDER BY COALESCE(p.is_favourite, FALSE) DESC, r.created_at DESC`
+	}
+
+	qt += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(params)+1, len(params)+2)
+	params = append(params, filter.Limit(), filter.Offset())
+
+	return qt, params
+}
+
+// GetRecipientProfile returns the address book attributes of a recipient. A recipient
+// without a profile row gets an empty profile.
+func (store *SQLStore) GetRecipientProfile(ctx context.Context, recipientID uuid.UUID) (RecipientProfile, error) {
+	query := `
+        SELECT recipient_id, nickname, tags, is_favourite, business_id, updated_at
+        FROM recipient_profiles
+        WHERE recipient_id = $1
+    `
+	profile := RecipientProfile{RecipientID: recipientID, Tags: []string{}}
+	err := store.db.QueryRowContext(ctx, query, recipientID).Scan(
+		&profile.RecipientID,
+		&profile.Nickname,
+		pq.Array(&profile.Tags),
+		&profile.IsFavourite,
+		&profile.BusinessID,
+		&profile.UpdatedAt,
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return profile, fmt.Errorf("failed to fetch recipient profile: %w", err)
+	}
+	return profile, nil
+}
+
+// UpsertRecipientProfile sets the nickname, tags and favourite flag of a recipient.
+func (store *SQLStore) UpsertRecipientProfile(ctx context.Context, arg UpsertRecipientProfileParams) (RecipientProfile, error) {
+	query := `
+        INSERT INTO recipient_profiles (recipient_id, nickname, tags, is_favourite, updated_at)
+        VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+        ON CONFLICT (recipient_id) DO UPDATE
+        SET nickname = EXCLUDED.nickname,
+            tags = EXCLUDED.tags,
+            is_favourite = EXCLUDED.is_favourite,
+            updated_at = CURRENT_TIMESTAMP
+        RETURNING recipient_id, nickname, tags, is_favourite, business_id, updated_at
+    `
+	tags := make([]string, 0, len(arg.Tags))
+	for _, tag := range arg.Tags {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	var profile RecipientProfile
+	err := store.db.QueryRowContext(ctx, query, arg.RecipientID, arg.Nickname, pq.Array(tags), arg.IsFavourite).Scan(
+		&profile.RecipientID,
+		&profile.Nickname,
+		pq.Array(&profile.Tags),
+		&profile.IsFavourite,
+		&profile.BusinessID,
+		&profile.UpdatedAt,
+	)
+	if err != nil {
+		return profile, fmt.Errorf("failed to save recipient profile: %w", err)
+	}
+	return profile, nil
+}
+
+// SetRecipientBusinessShare shares a recipient into a business recipient book, or
+// removes it from the book when businessID is not valid.
+func (store *SQLStore) SetRecipientBusinessShare(ctx context.Context, recipientID uuid.UUID, businessID uuid.NullUUID) error {
+	query := `
+        INSERT INTO recipient_profiles (recipient_id, business_id, updated_at)
+        VALUES ($1, $2, CURRENT_TIMESTAMP)
+        ON CONFLICT (recipient_id) DO UPDATE
+        SET business_id = EXCLUDED.business_id, updated_at = CURRENT_TIMESTAMP
+    `
+	_, err := store.db.ExecContext(ctx, query, recipientID, businessID)
+	if err != nil {
+		return fmt.Errorf("failed to share recipient: %w", err)
+	}
+	return nil
+}
+
+// GetSharedRecipient returns a recipient that has been shared into a business book.
+func (store *SQLStore) GetSharedRecipient(ctx context.Context, recipientID, businessID uuid.UUID) (Recipient, error) {
+	query := `
+        SELECT r.id, r.user_id, r.scheme, r.currency, r.data, r.created_at, r.updated_at
+        FROM recipients r
+        JOIN recipient_profiles p ON p.recipient_id = r.id
+        WHERE r.id = $1 AND p.business_id = $2
+    `
+	var i Recipient
+	err := store.db.QueryRowContext(ctx, query, recipientID, businessID).Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Scheme,
+		&i.Currency,
+		&i.Data,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+// GetTransferRecipient returns a recipient the user can send to: one they own, or one
+// shared into the recipient book of a business they created or are a member of.
+func (store *SQLStore) GetTransferRecipient(ctx context.Context, recipientID, userID uuid.UUID) (Recipient, error) {
+	query := `
+        SELECT r.id, r.user_id, r.scheme, r.currency, r.data, r.created_at, r.updated_at
+        FROM recipients r
+        LEFT JOIN recipient_profiles p ON p.recipient_id = r.id
+        WHERE r.id = $1
+          AND (
+              r.user_id = $2
+              OR p.business_id IN (
+                  SELECT b.id FROM businesses b WHERE b.created_by = $2
+                  UNION
+                  SELECT m.business_id FROM recipient_book_members m WHERE m.user_id = $2
+              )
+          )
+    `
+	var i Recipient
+	err := store.db.QueryRowContext(ctx, query, recipientID, userID).Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Scheme,
+		&i.Currency,
+		&i.Data,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+// GetRecipientBookRole returns the role a user holds on a business recipient book.
+// The business creator is always the owner. An empty role means no access.
+func (store *SQLStore) GetRecipientBookRole(ctx context.Context, businessID, userID uuid.UUID) (string, error) {
+	query := `
+        SELECT CASE WHEN b.created_by = $2 THEN 'owner' ELSE COALESCE(m.role, '') END
+        FROM businesses b
+        LEFT JOIN recipient_book_members m ON m.business_id = b.id AND m.user_id = $2
+        WHERE b.id = $1
+    `
+	var role string
+	err := store.db.QueryRowContext(ctx, query, businessID, userID).Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to fetch recipient book role: %w", err)
+	}
+	return role, nil
+}
+
+// GetUserRecipientBookBusiness returns the business whose recipient book the user can access,
+// either as its creator or as a member. A business the user created comes first, then the one
+// they were made a member of first.
+func (store *SQLStore) GetUserRecipientBookBusiness(ctx context.Context, userID uuid.UUID) (uuid.UUID, error) {
+	query := `
+        SELECT b.id
+        FROM businesses b
+        LEFT JOIN recipient_book_members m ON m.business_id = b.id AND m.user_id = $1
+        WHERE b.created_by = $1 OR m.user_id IS NOT NULL
+        ORDER BY b.created_by = $1 DESC, m.created_at ASC NULLS FIRST, b.id ASC
+        LIMIT 1
+    `
+	var businessID uuid.UUID
+	err := store.db.QueryRowContext(ctx, query, userID).Scan(&businessID)
+	return businessID, err
+}
+
+func (store *SQLStore) SetRecipientBookMember(ctx context.Context, businessID, userID uuid.UUID, role string) error {
+	query := `
+        INSERT INTO recipient_book_members (business_id, user_id, role, created_at)
+        VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+        ON CONFLICT (business_id, user_id) DO UPDATE SET role = EXCLUDED.role
+    `
+	_, err := store.db.ExecContext(ctx, query, businessID, userID, role)
+	if err != nil {
+		return fmt.Errorf("failed to set recipient book member: %w", err)
+	}
+	return nil
+}
+
+func (store *SQLStore) RemoveRecipientBookMember(ctx context.Context, businessID, userID uuid.UUID) error {
+	query := `DELETE FROM recipient_book_members WHERE business_id = $1 AND user_id = $2`
+	_, err := store.db.ExecContext(ctx, query, businessID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove recipient book member: %w", err)
+	}
+	return nil
+}
+
+func (store *SQLStore) ListRecipientBookMembers(ctx context.Context, businessID uuid.UUID) ([]RecipientBookMember, error) {
+	query := `
+        SELECT business_id, user_id, role, created_at
+        FROM recipient_book_members
+        WHERE business_id = $1
+        ORDER BY created_at ASC
+    `
+	rows, err := store.db.QueryContext(ctx, query, businessID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []RecipientBookMember
+	for rows.Next() {
+		var m RecipientBookMember
+		if err := rows.Scan(&m.BusinessID, &m.UserID, &m.Role, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// GetRecipientUsageStats returns the transfer stats of a single recipient.
+func (store *SQLStore) GetRecipientUsageStats(ctx context.Context, recipientID uuid.UUID) (RecipientUsageStats, error) {
+	query := `SELECT u.last_used_at, u.total_sent, u.transfer_count FROM recipients r` +
+		recipientUsageJoin + ` WHERE r.id = $1`
+
+	var stats RecipientUsageStats
+	err := store.db.QueryRowContext(ctx, query, recipientID).Scan(&stats.LastUsedAt, &stats.TotalSent, &stats.TransferCount)
+	return stats, err
+}
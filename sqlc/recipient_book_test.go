@@ -0,0 +1,30 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildRecipientBookQuerySearch(t *testing.T) {
+	filter := &RecipientBookFilter{Query: `50%_off\`, UserID: uuid.New()}
+	filter.Page, filter.PageSize = 1, 20
+
+	query, args := buildRecipientBookQuery(filter)
+	assert.Contains(t, query, `LIKE $3 ESCAPE '\'`)
+	assert.Equal(t, `%50\%\_off\\%`, args[2])
+	assert.Equal(t, `50%_off\`, args[1])
+}
+
+func TestCurrencyTotalsScan(t *testing.T) {
+	var totals CurrencyTotals
+	assert.NoError(t, totals.Scan([]byte(`{"NGN": 150000.50, "USD": "20"}`)))
+	assert.True(t, decimal.RequireFromString("150000.5").Equal(totals["NGN"]))
+	assert.True(t, decimal.NewFromInt(20).Equal(totals["USD"]))
+
+	assert.NoError(t, totals.Scan(nil))
+	assert.Empty(t, totals)
+	assert.Error(t, totals.Scan(42))
+}
@@ -2,9 +2,13 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/timchuks/monieverse/internal/common"
 )
 
 func createRandomRecipient(t *testing.T, user User) Recipient {
@@ -21,3 +25,53 @@ func createRandomRecipient(t *testing.T, user User) Recipient {
 
 	return recipient
 }
+
+func createRandomBusiness(t *testing.T, owner User) Business {
+	business, err := testQueries.CreateBusinessKYB(context.Background(), CreateBusinessKYBParams{
+		Name:                common.RandomOwner(),
+		RegistrationNumber:  common.RandomString(10),
+		IncorporationRegion: json.RawMessage(`{}`),
+		CreatedBy:           owner.ID,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, owner.ID, business.CreatedBy)
+
+	return business
+}
+
+func TestGetTransferRecipient(t *testing.T) {
+	store := SQLStore{
+		db:      testDB,
+		Queries: testQueries,
+	}
+
+	ctx := context.Background()
+
+	owner := createRandomUser(t, "Business")
+	member := createRandomUser(t, "Business")
+	stranger := createRandomUser(t, "Business")
+	recipient := createRandomRecipient(t, owner)
+
+	got, err := store.GetTransferRecipient(ctx, recipient.ID, owner.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, recipient.ID, got.ID)
+
+	_, err = store.GetTransferRecipient(ctx, recipient.ID, member.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	business := createRandomBusiness(t, owner)
+	assert.NoError(t, store.SetRecipientBusinessShare(ctx, recipient.ID, uuid.NullUUID{UUID: business.ID, Valid: true}))
+	assert.NoError(t, store.SetRecipientBookMember(ctx, business.ID, member.ID, RecipientBookRoleViewer))
+
+	got, err = store.GetTransferRecipient(ctx, recipient.ID, member.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, recipient.ID, got.ID)
+	assert.Equal(t, owner.ID, got.UserID)
+
+	_, err = store.GetTransferRecipient(ctx, recipient.ID, stranger.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	assert.NoError(t, store.SetRecipientBusinessShare(ctx, recipient.ID, uuid.NullUUID{}))
+	_, err = store.GetTransferRecipient(ctx, recipient.ID, member.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
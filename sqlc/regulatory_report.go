@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type RegulatoryReportFilter struct {
+	Currency string
+	From     time.Time
+	To       time.Time
+}
+
+// RegulatoryReportRow is an external transfer flattened with the compliance data in its payload.
+type RegulatoryReportRow struct {
+	TransactionID  uuid.UUID       `json:"transaction_id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	Status         string          `json:"status"`
+	Amount         decimal.Decimal `json:"amount"`
+	Currency       string          `json:"currency"`
+	Sender         string          `json:"sender"`
+	SenderEmail    string          `json:"sender_email"`
+	Beneficiary    string          `json:"beneficiary"`
+	Scheme         string          `json:"scheme"`
+	PayoutCurrency string          `json:"payout_currency"`
+	Country        string          `json:"country"`
+	PurposeCode    string          `json:"purpose_code"`
+	SourceOfFunds  string          `json:"source_of_funds"`
+	Relationship   string          `json:"relationship"`
+}
+
+// GetRegulatoryReportRows returns the external transfers created within the filter range,
+// optionally restricted to a payout currency.
+func (store *SQLStore) GetRegulatoryReportRows(ctx context.Context, filter RegulatoryReportFilter) ([]RegulatoryReportRow, error) {
+	query, params := buildRegulatoryReportQuery(filter)
+
+	rows, err := store.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []RegulatoryReportRow
+	for rows.Next() {
+		var i RegulatoryReportRow
+		if err := rows.Scan(
+			&i.TransactionID,
+			&i.CreatedAt,
+			&i.Status,
+			&i.Amount,
+			&i.Currency,
+			&i.Sender,
+			&i.SenderEmail,
+			&i.Beneficiary,
+			&i.Scheme,
+			&i.PayoutCurrency,
+			&i.Country,
+			&i.PurposeCode,
+			&i.SourceOfFunds,
+			&i.Relationship,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// buildRegulatoryReportQuery selects the external transfers of the filter range, and of its
+// payout currency when one is set.
+func buildRegulatoryReportQuery(filter RegulatoryReportFilter) (string, []interface{}) {
+	params := []interface{}{TransactionActionExternalTransfer, filter.From, filter.To}
+	where := []string{"t.action = $1", "t.created_at >= $2", "t.created_at < $3"}
+
+	if filter.Currency != "" {
+		params = append(params, strings.ToUpper(filter.Currency))
+		where = append(where, fmt.Sprintf("UPPER(t.payload->'recipient'->>'currency') = $%d", len(params)))
+	}
+
+	query := `
+        SELECT t.id,
+               t.created_at,
+               t.status,
+               t.amount,
+               COALESCE(c.code, ''),
+               CONCAT(u.first_name, ' ', u.last_name),
+               COALESCE(u.email, ''),
+               COALESCE(t.payload->'recipient'->'data'->>'account_holder_fullname', t.payload->'recipient'->'data'->>'business_name', ''),
+               COALESCE(t.payload->'recipient'->>'scheme', ''),
+               COALESCE(t.payload->'recipient'->>'currency', ''),
+               COALESCE(t.payload->'recipient'->'data'->>'countries', ''),
+               COALESCE(t.payload->'compliance'->>'purpose_code', ''),
+               COALESCE(t.payload->'compliance'->>'source_of_funds', ''),
+               COALESCE(t.payload->'compliance'->>'relationship', '')
+        FROM transactions t
+        LEFT JOIN users u ON u.id = t.user_id
+        LEFT JOIN currencies c ON c.id = t.currency_id
+        WHERE ` + strings.Join(where, " AND ") + `
+        ORDER BY t.created_at ASC`
+
+	return query, params
+}
@@ -0,0 +1,27 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildRegulatoryReportQuery(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	query, args := buildRegulatoryReportQuery(RegulatoryReportFilter{From: from, To: to})
+	assert.Contains(t, query, "WHERE t.action = $1 AND t.created_at >= $2 AND t.created_at < $3\n")
+	assert.NotContains(t, query, "$4")
+	assert.Equal(t, []interface{}{TransactionActionExternalTransfer, from, to}, args)
+
+	// The compliance columns are read from where the transfer payload keeps them
+	for _, column := range []string{"purpose_code", "source_of_funds", "relationship"} {
+		assert.Contains(t, query, "t.payload->'compliance'->>'"+column+"'")
+	}
+
+	query, args = buildRegulatoryReportQuery(RegulatoryReportFilter{Currency: "cny", From: from, To: to})
+	assert.Contains(t, query, "AND UPPER(t.payload->'recipient'->>'currency') = $4")
+	assert.Equal(t, []interface{}{TransactionActionExternalTransfer, from, to, "CNY"}, args)
+}
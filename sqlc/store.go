@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"github.com/timchuks/monieverse/internal/mapper"
 	"github.com/timchuks/monieverse/internal/settings"
 )
@@ -19,6 +20,7 @@ var (
 type Store interface {
 	Querier
 	CreateUserTx(ctx context.Context, arg CreateUserTxParams, afterCreate AfterCreateUserFunc) (CreateUserTxResult, error)
+	ActivateUserTx(ctx context.Context, userID uuid.UUID) (User, error)
 	GetOneTokenForUser(ctx context.Context, userID uuid.UUID, scope string) (Token, error)
 	AddUserPermission(ctx context.Context, userID uuid.UUID, codes ...string) error
 	PerformTransaction(ctx context.Context, wallet *Wallet, arg CreateTransactionParams, transactionKey []byte) (*Transaction, error)
@@ -30,6 +32,26 @@ type Store interface {
 	UpdateDocumentTx(ctx context.Context, before BeforeDocumentUpdateFunc) (*Document, error)
 	GetPaginatedSwapRequests(ctx context.Context, filter *TransactionFilter) ([]SwapRequestTransactionRow, Metadata, error)
 	GetPaginatedRecipients(ctx context.Context, filter *RecipientFilter) ([]Recipient, Metadata, error)
+	GetRecipientBook(ctx context.Context, filter *RecipientBookFilter) ([]RecipientBookEntry, Metadata, error)
+	GetRecipientProfile(ctx context.Context, recipientID uuid.UUID) (RecipientProfile, error)
+	UpsertRecipientProfile(ctx context.Context, arg UpsertRecipientProfileParams) (RecipientProfile, error)
+	SetRecipientBusinessShare(ctx context.Context, recipientID uuid.UUID, businessID uuid.NullUUID) error
+	GetSharedRecipient(ctx context.Context, recipientID, businessID uuid.UUID) (Recipient, error)
+	GetTransferRecipient(ctx context.Context, recipientID, userID uuid.UUID) (Recipient, error)
+	GetRecipientBookRole(ctx context.Context, businessID, userID uuid.UUID) (string, error)
+	GetUserRecipientBookBusiness(ctx context.Context, userID uuid.UUID) (uuid.UUID, error)
+	SetRecipientBookMember(ctx context.Context, businessID, userID uuid.UUID, role string) error
+	RemoveRecipientBookMember(ctx context.Context, businessID, userID uuid.UUID) error
+	ListRecipientBookMembers(ctx context.Context, businessID uuid.UUID) ([]RecipientBookMember, error)
+	GetRecipientUsageStats(ctx context.Context, recipientID uuid.UUID) (RecipientUsageStats, error)
+	GetRegulatoryReportRows(ctx context.Context, filter RegulatoryReportFilter) ([]RegulatoryReportRow, error)
+	CreateTransferInvoiceCheck(ctx context.Context, arg CreateTransferInvoiceCheckParams) (TransferInvoiceCheck, error)
+	CompleteTransferInvoiceCheck(ctx context.Context, arg CreateTransferInvoiceCheckParams) (TransferInvoiceCheck, error)
+	GetTransferInvoiceCheck(ctx context.Context, transactionID uuid.UUID) (TransferInvoiceCheck, error)
+	ListTransferInvoiceChecks(ctx context.Context, filter TransferInvoiceCheckFilter) ([]TransferInvoiceCheck, Metadata, error)
+	ReviewTransferInvoiceCheck(ctx context.Context, arg ReviewTransferInvoiceCheckParams) (TransferInvoiceCheck, error)
+	SetTransactionTrackingNumber(ctx context.Context, id uuid.UUID, trackingNumber string) (bool, error)
+	GetTransactionByTrackingNumber(ctx context.Context, trackingNumber string) (Transaction, error)
 	GetUserSettings(ctx context.Context, userID uuid.UUID) (settings.UserSettings, error)
 	UpdateTransactionTx(ctx context.Context, arg UpdateTransactionTxParams, afterUpdate AfterTransactionUpdateFunc) (UpdateTransactionTxResult, error)
 	GetPaginatedTransactions(ctx context.Context, filter *TransactionFilter) ([]TransactionRow, Metadata, error)
@@ -44,6 +66,7 @@ type Store interface {
 	GetUserMetas(ctx context.Context, userID uuid.UUID) (UserMeta, error)
 	UserMetaExists(ctx context.Context, userID uuid.UUID, key string) (bool, error)
 	SetUserMeta(ctx context.Context, input UserMetaCreateParams) error
+	ListUserMetaValues(ctx context.Context, userID uuid.UUID) (map[string]string, error)
 	GetSystemUser(email string) (*User, error)
 	GetCountry(ctx context.Context, identifier interface{}) (*Country, error)
 	GetCountries(ctx context.Context, queryFilter CountryQueryFilter, pagination Filter) ([]Country, error)
@@ -66,14 +89,82 @@ type Store interface {
 	SetBusinessApprovalStatus(ctx context.Context, id uuid.UUID, status BusinessStatus, reason string, approvedBy uuid.UUID) error
 	SetBusinessOwnersApprovalStatus(ctx context.Context, id uuid.UUID, status BusinessStatus, reason string, approvedBy uuid.UUID) error
 	PaginatedBusinesses(ctx context.Context, filter BusinessListFilter) ([]Business, error)
+	GetBusinessByRegistrationNumber(ctx context.Context, registrationNumber string) (Business, error)
 	VerifyWalletHistory(ctx context.Context, id int64) (bool, error)
 	UpdateWalletHistoryStatusTx(ctx context.Context, arg UpdateWalletHistoryStatusParams) (*WalletHistory, error)
 	GetWalletHistory(ctx context.Context, id int64) (*WalletHistory, error)
 	CreateWalletHistoryTx(ctx context.Context, arg CreateWalletHistoryParams) (*WalletHistory, error)
 	CreateFormDefinitionTx(ctx context.Context, input *FormDefinitionInput) (*FormDefinition, error)
-	ProcessFormSubmissionTx(ctx context.Context, input *FormSubmissionInput) (*FormSubmission, error)
-	UpdateFormSubmissionTx(ctx context.Context, input *FormSubmissionUpdateInput) (*FormSubmission, error)
-	SaveStepProgressTx(ctx context.Context, input *SaveStepProgressInput) error
+	ProcessFormSubmissionTx(ctx context.Context, input *FormSubmissionInput, events FormEventsFunc[*FormSubmission]) (*FormSubmission, error)
+	SealFormSubmissionsTx(ctx context.Context, formID uuid.UUID) (int64, error)
+	UpdateFormSubmissionTx(ctx context.Context, input *FormSubmissionUpdateInput, events FormEventsFunc[*FormSubmission]) (*FormSubmission, error)
+	SaveStepProgressTx(ctx context.Context, input *SaveStepProgressInput, events FormEventsFunc[FormStepProgress]) error
+	GetFormVersion(ctx context.Context, formID uuid.UUID, version int32) (FormVersion, error)
+	ListFormVersions(ctx context.Context, formID uuid.UUID) ([]FormVersion, error)
+	GetFormStepsByVersion(ctx context.Context, formID uuid.UUID, version int32) ([]FormStep, error)
+	GetFormFieldsByVersion(ctx context.Context, formID uuid.UUID, version int32) ([]FormField, error)
+	SaveFormDraftVersionTx(ctx context.Context, input *FormDraftVersionInput) (*FormVersion, error)
+	PublishFormVersionTx(ctx context.Context, formID uuid.UUID, version int32, publishedBy uuid.UUID) (*FormDefinition, error)
+	MigrateFormSubmissionsTx(ctx context.Context, input *FormSubmissionMigrationInput) (int, error)
+	GetCurrentApprovalTasks(ctx context.Context, submissionID uuid.UUID) ([]FormApprovalTask, error)
+	ListActiveApprovalTasks(ctx context.Context) ([]FormApprovalTask, error)
+	ListFormApprovalEvents(ctx context.Context, submissionID uuid.UUID) ([]FormApprovalEvent, error)
+	CreateFormApprovalEvent(ctx context.Context, arg CreateFormApprovalEventParams) error
+	StartApprovalRoundTx(ctx context.Context, input *StartApprovalRoundInput, events FormEventsFunc[[]FormApprovalTask]) ([]FormApprovalTask, error)
+	DecideApprovalTaskTx(ctx context.Context, input *ApprovalDecisionInput, events FormEventsFunc[*ApprovalDecisionResult]) (*ApprovalDecisionResult, error)
+	EscalateOverdueApprovalTasksTx(ctx context.Context, events FormEventsFunc[EscalatedApprovalTask]) ([]FormApprovalTask, error)
+	ClaimFormEventOutbox(ctx context.Context, limit int32, lease time.Duration) ([]FormEventOutbox, error)
+	MarkFormEventOutboxDelivered(ctx context.Context, id uuid.UUID) error
+	FailFormEventOutbox(ctx context.Context, arg FailFormEventOutboxParams) error
+	ListFailedFormEventOutbox(ctx context.Context, formID uuid.UUID) ([]FormEventOutbox, error)
+	RetryFormEventOutbox(ctx context.Context, id uuid.UUID) error
+	QueryOptionRows(ctx context.Context, query OptionQuery) ([]OptionRow, error)
+	RecordFormValidationFailures(ctx context.Context, arg RecordFormValidationFailuresParams) error
+	GetFormSubmissionTotals(ctx context.Context, filter FormAnalyticsFilter) (FormSubmissionTotals, error)
+	GetFormStepFunnel(ctx context.Context, filter FormAnalyticsFilter) ([]FormStepFunnelRow, error)
+	GetFormAbandonment(ctx context.Context, filter FormAnalyticsFilter, idleSince time.Time) ([]FormAbandonmentRow, error)
+	GetFormFieldErrors(ctx context.Context, filter FormAnalyticsFilter) ([]FormFieldErrorRow, error)
+	GetFormApprovalTurnaround(ctx context.Context, filter FormAnalyticsFilter) ([]FormApprovalStageRow, error)
+	ListFormEvents(ctx context.Context, formID uuid.UUID) ([]FormEvent, error)
+	ListFormAssignments(ctx context.Context, formID uuid.UUID) ([]FormAssignment, error)
+	ListFormAssignmentsByType(ctx context.Context, formType string) ([]GetFormAssignmentsRow, error)
+	GetUserTransactionVolume(ctx context.Context, filter TransactionVolumeFilter) (decimal.Decimal, error)
+	ImportFormDefinitionTx(ctx context.Context, input *FormImportInput) (*FormImportResult, error)
+	ListFormSubmissionRevisions(ctx context.Context, submissionID uuid.UUID) ([]FormSubmissionRevision, error)
+	GetFormSubmissionRevision(ctx context.Context, submissionID uuid.UUID, revision int32) (FormSubmissionRevision, error)
+	AutosaveFormSubmissionTx(ctx context.Context, input *AutosaveInput) (*FormSubmission, error)
+	GetFormPublicAccess(ctx context.Context, formID uuid.UUID) (FormPublicAccess, error)
+	UpsertFormPublicAccess(ctx context.Context, arg UpsertFormPublicAccessParams) (FormPublicAccess, error)
+	CreatePublicFormSubmissionTx(ctx context.Context, arg CreatePublicFormSubmissionParams, events FormEventsFunc[PublicFormSubmission]) (PublicFormSubmission, error)
+	GetPublicFormSubmission(ctx context.Context, id uuid.UUID) (PublicFormSubmission, error)
+	CountPublicFormSubmissions(ctx context.Context, formID uuid.UUID, ipAddress string, since time.Time) (int64, error)
+	VerifyPublicFormSubmissionTx(ctx context.Context, tokenHash []byte, now time.Time, events FormEventsFunc[PublicFormSubmission]) (PublicFormSubmission, error)
+	LinkPublicFormSubmissions(ctx context.Context, userID uuid.UUID) (int64, error)
+	ListPublicFormSubmissions(ctx context.Context, arg ListPublicFormSubmissionsParams) ([]PublicFormSubmission, error)
+	CreateFormRetentionPolicy(ctx context.Context, arg CreateFormRetentionPolicyParams) (FormRetentionPolicy, error)
+	GetFormRetentionPolicy(ctx context.Context, id uuid.UUID) (FormRetentionPolicy, error)
+	UpdateFormRetentionPolicy(ctx context.Context, arg UpdateFormRetentionPolicyParams) (FormRetentionPolicy, error)
+	DeleteFormRetentionPolicy(ctx context.Context, id uuid.UUID) error
+	ListFormRetentionPolicies(ctx context.Context, formID uuid.NullUUID, enabledOnly bool) ([]FormRetentionPolicy, error)
+	MarkFormRetentionPolicyRun(ctx context.Context, id uuid.UUID, ranAt time.Time) error
+	ListExpiredFormSubmissions(ctx context.Context, policy FormRetentionPolicy, now time.Time, limit int32) ([]FormSubmission, error)
+	DeleteFormSubmissionTx(ctx context.Context, submissionID uuid.UUID) error
+	RedactFormSubmissionTx(ctx context.Context, submissionID uuid.UUID, redactedAt time.Time) error
+	CreateLegalHold(ctx context.Context, arg CreateLegalHoldParams) (LegalHold, error)
+	ReleaseLegalHold(ctx context.Context, id uuid.UUID, releasedBy uuid.UUID) (LegalHold, error)
+	ListLegalHolds(ctx context.Context, arg ListLegalHoldsParams) ([]LegalHold, error)
+	CreateDataSubjectRequest(ctx context.Context, arg CreateDataSubjectRequestParams) (DataSubjectRequest, error)
+	GetDataSubjectRequest(ctx context.Context, id uuid.UUID) (DataSubjectRequest, error)
+	ListDataSubjectRequests(ctx context.Context, arg ListDataSubjectRequestsParams) ([]DataSubjectRequest, error)
+	CompleteDataSubjectRequest(ctx context.Context, arg CompleteDataSubjectRequestParams) (DataSubjectRequest, error)
+	ListExpiredDataExports(ctx context.Context, now time.Time, limit int32) ([]DataSubjectRequest, error)
+	ClearDataSubjectExport(ctx context.Context, id uuid.UUID) error
+	GetUserPersonalData(ctx context.Context, userID uuid.UUID, email string) (UserPersonalData, error)
+	EraseUserDataTx(ctx context.Context, input EraseUserDataInput) (EraseUserDataResult, error)
+	CloseUserAccount(ctx context.Context, userID uuid.UUID) (time.Time, error)
+	TryAdvisoryLock(ctx context.Context, name string) (unlock func(), ok bool, err error)
+	UpdateFormFieldText(ctx context.Context, arg UpdateFormFieldTextParams) error
+	UpdateFormFieldTextsTx(ctx context.Context, args []UpdateFormFieldTextParams) error
 }
 
 type SQLStore struct {
@@ -111,3 +111,31 @@ func (store *SQLStore) UpdateTransactionRecipientData(ctx context.Context, newDa
 
 	return nil
 }
+
+// SetTransactionTrackingNumber sets the tracking number of a transaction that does not have one yet.
+// It returns false when the transaction already had a tracking number.
+func (store *SQLStore) SetTransactionTrackingNumber(ctx context.Context, id uuid.UUID, trackingNumber string) (bool, error) {
+	query := `
+        UPDATE transactions SET tracking_number = $2
+        WHERE id = $1 AND (tracking_number IS NULL OR tracking_number = '')
+    `
+	res, err := store.db.ExecContext(ctx, query, id, trackingNumber)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (store *SQLStore) GetTransactionByTrackingNumber(ctx context.Context, trackingNumber string) (Transaction, error) {
+	var id uuid.UUID
+	err := store.db.QueryRowContext(ctx, `SELECT id FROM transactions WHERE tracking_number = $1`, trackingNumber).Scan(&id)
+	if err != nil {
+		return Transaction{}, err
+	}
+	return store.GetTransaction(ctx, id)
+}
@@ -0,0 +1,182 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TransferInvoiceCheck is the result of extracting and matching an uploaded transfer invoice.
+type TransferInvoiceCheck struct {
+	ID            uuid.UUID       `json:"id"`
+	TransactionID uuid.UUID       `json:"transaction_id"`
+	DocumentID    uuid.UUID       `json:"document_id"`
+	Status        string          `json:"status"`
+	Extracted     json.RawMessage `json:"extracted"`
+	Mismatches    json.RawMessage `json:"mismatches"`
+	Error         string          `json:"error"`
+	ReviewedBy    uuid.NullUUID   `json:"reviewed_by"`
+	ReviewNote    string          `json:"review_note"`
+	ReviewedAt    sql.NullTime    `json:"reviewed_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+type CreateTransferInvoiceCheckParams struct {
+	TransactionID uuid.UUID       `json:"transaction_id"`
+	DocumentID    uuid.UUID       `json:"document_id"`
+	Status        string          `json:"status"`
+	Extracted     json.RawMessage `json:"extracted"`
+	Mismatches    json.RawMessage `json:"mismatches"`
+	Error         string          `json:"error"`
+}
+
+type ReviewTransferInvoiceCheckParams struct {
+	ID         uuid.UUID `json:"id"`
+	Status     string    `json:"status"`
+	ReviewedBy uuid.UUID `json:"reviewed_by"`
+	ReviewNote string    `json:"review_note"`
+}
+
+type TransferInvoiceCheckFilter struct {
+	Filter
+	Status string
+}
+
+const transferInvoiceCheckColumns = `id, transaction_id, document_id, status, extracted, mismatches, error,
+        reviewed_by, review_note, reviewed_at, created_at`
+
+func scanTransferInvoiceCheck(row *sql.Row) (TransferInvoiceCheck, error) {
+	var i TransferInvoiceCheck
+	err := row.Scan(
+		&i.ID,
+		&i.TransactionID,
+		&i.DocumentID,
+		&i.Status,
+		&i.Extracted,
+		&i.Mismatches,
+		&i.Error,
+		&i.ReviewedBy,
+		&i.ReviewNote,
+		&i.ReviewedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+// CreateTransferInvoiceCheck stores the check of a newly uploaded invoice. A transaction keeps
+// only the check of its latest invoice.
+func (store *SQLStore) CreateTransferInvoiceCheck(ctx context.Context, arg CreateTransferInvoiceCheckParams) (TransferInvoiceCheck, error) {
+	query := `
+        INSERT INTO transfer_invoice_checks (transaction_id, document_id, status, extracted, mismatches, error, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP)
+        ON CONFLICT (transaction_id) DO UPDATE
+        SET document_id = EXCLUDED.document_id,
+            status = EXCLUDED.status,
+            extracted = EXCLUDED.extracted,
+            mismatches = EXCLUDED.mismatches,
+            error = EXCLUDED.error,
+            reviewed_by = NULL,
+            review_note = '',
+            reviewed_at = NULL,
+            created_at = CURRENT_TIMESTAMP
+        RETURNING ` + transferInvoiceCheckColumns
+
+	if len(arg.Extracted) == 0 {
+		arg.Extracted = json.RawMessage("{}")
+	}
+	if len(arg.Mismatches) == 0 {
+		arg.Mismatches = json.RawMessage("[]")
+	}
+
+	check, err := scanTransferInvoiceCheck(store.db.QueryRowContext(ctx, query,
+		arg.TransactionID, arg.DocumentID, arg.Status, arg.Extracted, arg.Mismatches, arg.Error))
+	if err != nil {
+		return check, fmt.Errorf("failed to save invoice check: %w", err)
+	}
+	return check, nil
+}
+
+// CompleteTransferInvoiceCheck stores the extraction result of a pending check. It returns
+// sql.ErrNoRows when the check is no longer pending for the document, such as after a newer
+// invoice was uploaded for the transaction.
+func (store *SQLStore) CompleteTransferInvoiceCheck(ctx context.Context, arg CreateTransferInvoiceCheckParams) (TransferInvoiceCheck, error) {
+	query := `
+        UPDATE transfer_invoice_checks
+        SET status = $3, extracted = $4, mismatches = $5, error = $6
+        WHERE transaction_id = $1 AND document_id = $2 AND status = 'pending'
+        RETURNING ` + transferInvoiceCheckColumns
+
+	if len(arg.Extracted) == 0 {
+		arg.Extracted = json.RawMessage("{}")
+	}
+	if len(arg.Mismatches) == 0 {
+		arg.Mismatches = json.RawMessage("[]")
+	}
+
+	return scanTransferInvoiceCheck(store.db.QueryRowContext(ctx, query,
+		arg.TransactionID, arg.DocumentID, arg.Status, arg.Extracted, arg.Mismatches, arg.Error))
+}
+
+func (store *SQLStore) GetTransferInvoiceCheck(ctx context.Context, transactionID uuid.UUID) (TransferInvoiceCheck, error) {
+	query := `SELECT ` + transferInvoiceCheckColumns + ` FROM transfer_invoice_checks WHERE transaction_id = $1`
+	return scanTransferInvoiceCheck(store.db.QueryRowContext(ctx, query, transactionID))
+}
+
+// ListTransferInvoiceChecks returns invoice checks, oldest first, optionally filtered by status.
+func (store *SQLStore) ListTransferInvoiceChecks(ctx context.Context, filter TransferInvoiceCheckFilter) ([]TransferInvoiceCheck, Metadata, error) {
+	query := `SELECT count(*) OVER() AS total_records, ` + transferInvoiceCheckColumns + `
+        FROM transfer_invoice_checks
+        WHERE ($1 = '' OR status = $1)
+        ORDER BY created_at ASC
+        LIMIT $2 OFFSET $3`
+
+	rows, err := store.db.QueryContext(ctx, query, filter.Status, filter.Limit(), filter.Offset())
+	if err != nil {
+		return nil, EmptyMetadata, err
+	}
+	defer rows.Close()
+
+	var items []TransferInvoiceCheck
+	totalRecords := 0
+	for rows.Next() {
+		var i TransferInvoiceCheck
+		if err := rows.Scan(
+			&totalRecords,
+			&i.ID,
+			&i.TransactionID,
+			&i.DocumentID,
+			&i.Status,
+			&i.Extracted,
+			&i.Mismatches,
+			&i.Error,
+			&i.ReviewedBy,
+			&i.ReviewNote,
+			&i.ReviewedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, EmptyMetadata, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, EmptyMetadata, err
+	}
+
+	metadata := CalculateMetadata(totalRecords, filter.Page, filter.Limit())
+	return items, metadata, nil
+}
+
+// ReviewTransferInvoiceCheck records the compliance decision on an invoice check.
+func (store *SQLStore) ReviewTransferInvoiceCheck(ctx context.Context, arg ReviewTransferInvoiceCheckParams) (TransferInvoiceCheck, error) {
+	query := `
+        UPDATE transfer_invoice_checks
+        SET status = $2, reviewed_by = $3, review_note = $4, reviewed_at = CURRENT_TIMESTAMP
+        WHERE id = $1
+        RETURNING ` + transferInvoiceCheckColumns
+
+	return scanTransferInvoiceCheck(store.db.QueryRowContext(ctx, query, arg.ID, arg.Status, arg.ReviewedBy, arg.ReviewNote))
+}
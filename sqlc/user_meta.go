@@ -109,3 +109,28 @@ func mapUserMeta(u *UserMeta, key string, value string, datatype string) {
 	}
 
 }
+
+// ListUserMetaValues returns every meta value of a user by key
+func (q *Queries) ListUserMetaValues(ctx context.Context, userID uuid.UUID) (map[string]string, error) {
+	rows, err := q.db.QueryContext(ctx, "SELECT key, value FROM user_meta WHERE user_id = $1", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
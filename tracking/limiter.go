@@ -0,0 +1,91 @@
+package tracking
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/timchuks/monieverse/internal/config"
+)
+
+// Counter counts hits on keys in fixed windows that start with a key's first hit. Every API
+// instance must share it for limits to hold across instances.
+type Counter interface {
+	// Incr records a hit on key and returns the hits in its current window.
+	Incr(ctx context.Context, key string, window time.Duration) (int64, error)
+	// Count returns the hits on key in its current window.
+	Count(ctx context.Context, key string) (int64, error)
+}
+
+// incrScript increments a key and starts its window with the first hit, in one round trip so a
+// key never outlives its window.
+var incrScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return n
+`)
+
+var countScript = redis.NewScript(`return tonumber(redis.call('GET', KEYS[1]) or '0')`)
+
+// RedisCounter is a Counter kept in the Redis the API instances share.
+type RedisCounter struct {
+	client redis.Scripter
+}
+
+// NewRedisCounter creates a counter on the Redis of the configuration.
+func NewRedisCounter(cfg config.Config) *RedisCounter {
+	return &RedisCounter{client: redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddress,
+		Username: cfg.RedisUsername,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})}
+}
+
+func (c *RedisCounter) Incr(ctx context.Context, key string, window time.Duration) (int64, error) {
+	return incrScript.Run(ctx, c.client, []string{key}, window.Milliseconds()).Int64()
+}
+
+func (c *RedisCounter) Count(ctx context.Context, key string) (int64, error) {
+	return countScript.Run(ctx, c.client, []string{key}).Int64()
+}
+
+// Limiter is a fixed window request limiter keyed by client.
+type Limiter struct {
+	counter Counter
+	prefix  string
+	limit   int
+	window  time.Duration
+}
+
+// NewLimiter creates a limiter allowing limit hits per client in every window, counted under
+// keys starting with prefix.
+func NewLimiter(counter Counter, prefix string, limit int, every time.Duration) *Limiter {
+	return &Limiter{
+		counter: counter,
+		prefix:  prefix,
+		limit:   limit,
+		window:  every,
+	}
+}
+
+// Allow records a hit from key and returns false once the key is over its limit.
+func (l *Limiter) Allow(ctx context.Context, key string) (bool, error) {
+	n, err := l.counter.Incr(ctx, l.prefix+":"+key, l.window)
+	if err != nil {
+		return false, err
+	}
+	return n <= int64(l.limit), nil
+}
+
+// Exceeded reports whether key has used up its limit in the current window, without recording
+// a hit.
+func (l *Limiter) Exceeded(ctx context.Context, key string) (bool, error) {
+	n, err := l.counter.Count(ctx, l.prefix+":"+key)
+	if err != nil {
+		return false, err
+	}
+	return n >= int64(l.limit), nil
+}
@@ -0,0 +1,96 @@
+package tracking
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	StageReceived   = "received"
+	StageProcessing = "processing"
+	StageOnHold     = "on_hold"
+	StageDelivered  = "delivered"
+	StageFailed     = "failed"
+	StageCanceled   = "canceled"
+)
+
+// publicStages maps internal transaction statuses to the stages shown on the public tracking page.
+var publicStages = map[string]string{
+	"pending":       StageReceived,
+	"processing":    StageProcessing,
+	"swap-approved": StageProcessing,
+	"issue":         StageOnHold,
+	"completed":     StageDelivered,
+	"failed":        StageFailed,
+	"canceled":      StageCanceled,
+}
+
+var stageLabels = map[string]string{
+	StageReceived:   "Transfer received",
+	StageProcessing: "Transfer is being processed",
+	StageOnHold:     "Transfer is on hold, our team is on it",
+	StageDelivered:  "Funds delivered to the beneficiary",
+	StageFailed:     "Transfer could not be completed",
+	StageCanceled:   "Transfer was canceled",
+}
+
+// Event is a status change recorded in the transaction history.
+type Event struct {
+	OldStatus string
+	NewStatus string
+	At        time.Time
+}
+
+// TimelineEntry is a redacted step of the public tracking timeline.
+type TimelineEntry struct {
+	Stage string    `json:"stage"`
+	Label string    `json:"label"`
+	At    time.Time `json:"at"`
+}
+
+// Stage returns the public stage of an internal transaction status.
+func Stage(status string) string {
+	if s, ok := publicStages[status]; ok {
+		return s
+	}
+	return StageProcessing
+}
+
+// Timeline builds the public timeline of a transfer from its history, oldest first. Events that
+// do not change the public stage, such as invoice uploads, are dropped along with their reasons.
+func Timeline(createdAt time.Time, events []Event) []TimelineEntry {
+	timeline := []TimelineEntry{{Stage: StageReceived, Label: stageLabels[StageReceived], At: createdAt}}
+
+	sorted := make([]Event, len(events))
+	copy(sorted, events)
+	for i := 1; i < len(sorted); i++ {
+		for j := i; j > 0 && sorted[j].At.Before(sorted[j-1].At); j-- {
+			sorted[j], sorted[j-1] = sorted[j-1], sorted[j]
+		}
+	}
+
+	for _, e := range sorted {
+		if e.OldStatus == e.NewStatus {
+			continue
+		}
+
+		stage := Stage(e.NewStatus)
+		if stage == timeline[len(timeline)-1].Stage {
+			continue
+		}
+
+		timeline = append(timeline, TimelineEntry{Stage: stage, Label: stageLabels[stage], At: e.At})
+	}
+
+	return timeline
+}
+
+// MaskName keeps the first letter of each word of a name, e.g. "Jane Doe" becomes "J*** D***".
+func MaskName(name string) string {
+	words := strings.Fields(name)
+	for i, w := range words {
+		r := []rune(w)
+		words[i] = string(r[0]) + "***"
+	}
+	return strings.Join(words, " ")
+}
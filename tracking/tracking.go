@@ -0,0 +1,105 @@
+package tracking
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+const (
+	// DefaultPrefix is used for corridors without a dedicated prefix.
+	DefaultPrefix = "MV"
+
+	// serialLength is the number of random digits before the check digit.
+	serialLength = 10
+)
+
+var ErrInvalidTrackingNumber = errors.New("invalid tracking number")
+
+// corridorPrefixes maps payout currencies to the two letter prefix of their tracking numbers.
+var corridorPrefixes = map[string]string{
+	"CNY": "CN",
+	"INR": "IN",
+	"USD": "US",
+	"GBP": "GB",
+	"EUR": "EU",
+	"CAD": "CA",
+	"NGN": "NG",
+}
+
+// Prefix returns the tracking number prefix of the corridor paying out in currency.
+func Prefix(currency string) string {
+	if p, ok := corridorPrefixes[strings.ToUpper(currency)]; ok {
+		return p
+	}
+	return DefaultPrefix
+}
+
+// Generate returns a new tracking number for the corridor paying out in currency, made of the
+// corridor prefix, random digits and a Luhn check digit, e.g. CN48213390571.
+func Generate(currency string) (string, error) {
+	max := big.NewInt(10)
+
+	var serial strings.Builder
+	for i := 0; i < serialLength; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		serial.WriteString(n.String())
+	}
+
+	digits := serial.String()
+	return Prefix(currency) + digits + strconv.Itoa(CheckDigit(digits)), nil
+}
+
+// CheckDigit returns the Luhn check digit of a digit string.
+func CheckDigit(digits string) int {
+	sum := 0
+	double := true
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return (10 - sum%10) % 10
+}
+
+// Normalize upper-cases a tracking number and strips spaces and dashes.
+func Normalize(number string) string {
+	return validator.NormalizeBankCode(number)
+}
+
+// Valid returns true if number has a known prefix, the expected length and a valid check digit.
+func Valid(number string) bool {
+	number = Normalize(number)
+	if len(number) != 2+serialLength+1 {
+		return false
+	}
+
+	prefix := number[:2]
+	if prefix != DefaultPrefix {
+		known := false
+		for _, p := range corridorPrefixes {
+			if p == prefix {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return false
+		}
+	}
+
+	return validator.IsLuhn(number[2:])
+}
@@ -0,0 +1,89 @@
+package tracking
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		number, err := Generate("cny")
+		require.NoError(t, err)
+		require.Len(t, number, 13)
+		require.Equal(t, "CN", number[:2])
+		require.True(t, Valid(number))
+	}
+
+	number, err := Generate("XYZ")
+	require.NoError(t, err)
+	require.Equal(t, DefaultPrefix, number[:2])
+}
+
+func TestValid(t *testing.T) {
+	number := "CN" + "7992739871" + "3"
+	require.True(t, Valid(number))
+	require.True(t, Valid("cn-7992739871-3"))
+	require.False(t, Valid("CN79927398714"))
+	require.False(t, Valid("ZZ79927398713"))
+	require.False(t, Valid("CN7992739871"))
+}
+
+func TestTimeline(t *testing.T) {
+	created := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	timeline := Timeline(created, []Event{
+		{OldStatus: "processing", NewStatus: "completed", At: created.Add(3 * time.Hour)},
+		{OldStatus: "pending", NewStatus: "pending", At: created.Add(time.Hour)},
+		{OldStatus: "pending", NewStatus: "processing", At: created.Add(2 * time.Hour)},
+	})
+
+	require.Len(t, timeline, 3)
+	require.Equal(t, StageReceived, timeline[0].Stage)
+	require.Equal(t, StageProcessing, timeline[1].Stage)
+	require.Equal(t, StageDelivered, timeline[2].Stage)
+	require.Equal(t, created.Add(3*time.Hour), timeline[2].At)
+}
+
+func TestMaskName(t *testing.T) {
+	require.Equal(t, "J*** D***", MaskName("Jane  Doe"))
+	require.Equal(t, "", MaskName(""))
+}
+
+// memoryCounter counts hits in memory; windows don't expire
+type memoryCounter map[string]int64
+
+func (c memoryCounter) Incr(_ context.Context, key string, _ time.Duration) (int64, error) {
+	c[key]++
+	return c[key], nil
+}
+
+func (c memoryCounter) Count(_ context.Context, key string) (int64, error) {
+	return c[key], nil
+}
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	l := NewLimiter(memoryCounter{}, "test", 2, time.Minute)
+
+	allow := func(key string) bool {
+		ok, err := l.Allow(ctx, key)
+		require.NoError(t, err)
+		return ok
+	}
+	exceeded := func(key string) bool {
+		over, err := l.Exceeded(ctx, key)
+		require.NoError(t, err)
+		return over
+	}
+
+	require.True(t, allow("a"))
+	require.False(t, exceeded("a"))
+	require.True(t, allow("a"))
+	require.True(t, exceeded("a"))
+	require.False(t, allow("a"))
+	require.True(t, allow("b"))
+	require.False(t, exceeded("b"))
+}
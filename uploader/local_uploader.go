@@ -20,6 +20,9 @@ func NewLocalUploader(uploadDirectory string) *LocalUploader {
 
 func (u *LocalUploader) Upload(file io.Reader, _ string, path string) error {
 	outputPath := filepath.Join(u.UploadDirectory, path)
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
+		return err
+	}
 	outFile, err := os.Create(outputPath)
 	if err != nil {
 		return err
@@ -58,3 +61,7 @@ func (u *LocalUploader) Delete(bucket string, path string) error {
 func (u *LocalUploader) GetTempURL(bucket string, path string) (string, error) {
 	return "", nil
 }
+
+func (u *LocalUploader) Download(_ string, path string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(u.UploadDirectory, path))
+}
@@ -72,3 +72,16 @@ func (u *S3Uploader) GetTempURL(bucket string, path string) (string, error) {
 
 	return url, nil
 }
+
+// Download returns the content of a file in s3. The caller must close it.
+func (u *S3Uploader) Download(bucket string, path string) (io.ReadCloser, error) {
+	output, err := u.S3Client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(path),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return output.Body, nil
+}
@@ -2,6 +2,7 @@ package uploader
 
 import (
 	"io"
+	"strings"
 )
 
 const (
@@ -10,6 +11,15 @@ const (
 	LocalProvider        = "local"
 )
 
+// QuarantineDirectory holds uploads that failed a virus scan. They are kept for review instead
+// of being deleted, and nothing links to them.
+const QuarantineDirectory = "quarantine"
+
+// QuarantinePath returns where an infected upload meant for path is kept
+func QuarantinePath(path string) string {
+	return QuarantineDirectory + "/" + strings.TrimPrefix(path, "/")
+}
+
 // FileInfo is a struct that contains information about a file
 type FileInfo struct {
 	URL      string `json:"url"`
@@ -24,4 +34,5 @@ type FileUploader interface {
 	Info(bucket string, path string) (*FileInfo, error)
 	Delete(bucket string, path string) error
 	GetTempURL(bucket string, path string) (string, error)
+	Download(bucket string, path string) (io.ReadCloser, error)
 }
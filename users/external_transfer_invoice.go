@@ -104,6 +104,8 @@ func (c *usersController) UploadTransferInvoice(ctx *gin.Context) {
 		NewStatus:     transfer.Status,
 	})
 
+	c.queueTransferInvoiceCheck(ctx, transfer, dbRes.ID, req.ContentType)
+
 	srv.SuccessJSONResponse(ctx, http.StatusOK, "file uploaded successfully",
 		domain.UploadIdentityDocumentResponse{
 			ID: dbRes.ID.String(),
@@ -0,0 +1,400 @@
+package users
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+const maxRecipientTags = 10
+
+type recipientBookQuery struct {
+	db.Filter
+	Query          string `form:"query"`
+	Tag            string `form:"tag"`
+	FavouritesOnly bool   `form:"favourites_only"`
+	SortBy         string `form:"sort_by"`
+	Shared         bool   `form:"shared"`
+}
+
+type updateRecipientProfileRequest struct {
+	Nickname    string   `json:"nickname"`
+	Tags        []string `json:"tags"`
+	IsFavourite bool     `json:"is_favourite"`
+}
+
+type recipientBookMemberRequest struct {
+	UserID uuid.UUID `json:"user_id"`
+	Role   string    `json:"role"`
+}
+
+// GetRecipientBook lists the user's recipients, or the shared business book when shared=true,
+// with nicknames, tags, favourites and usage stats.
+func (c *usersController) GetRecipientBook(ctx *gin.Context) {
+
+	srv := c.srv
+	user := srv.ContextGetUser(ctx)
+
+	var req recipientBookQuery
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+
+	v := validator.New()
+	v.Check(req.PageSize <= db.MaxFilterSize, "page_size", fmt.Sprintf("must not be more than %d", db.MaxFilterSize))
+	v.Check(req.SortBy == "" || validator.In(req.SortBy, db.ValidRecipientBookSorts...), "sort_by", "invalid sort option")
+	if !v.Valid() {
+		srv.SendValidationError(ctx, validator.NewValidationError("validation failed", v.Errors))
+		return
+	}
+
+	filter := db.RecipientBookFilter{
+		Filter:         req.Filter,
+		Query:          strings.TrimSpace(req.Query),
+		Tag:            strings.TrimSpace(req.Tag),
+		FavouritesOnly: req.FavouritesOnly,
+		SortBy:         req.SortBy,
+		UserID:         user.ID,
+	}
+
+	if req.Shared {
+		businessID, ok := c.recipientBookBusiness(ctx, user.ID)
+		if !ok {
+			return
+		}
+		filter.BusinessID = uuid.NullUUID{UUID: businessID, Valid: true}
+	}
+
+	res, meta, err := srv.Store.GetRecipientBook(ctx, &filter)
+	if err != nil {
+		srv.Logger.Error(err, map[string]interface{}{
+			"request": user.ID,
+		})
+		srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, errors.New("unable to fetch recipients"))
+		return
+	}
+
+	srv.SuccessJSONResponse(ctx, http.StatusOK, "recipients retrieved successfully", gin.H{
+		"recipients": res,
+		"meta":       meta,
+	})
+}
+
+// GetRecipientStats returns the transfer stats of one of the user's recipients.
+func (c *usersController) GetRecipientStats(ctx *gin.Context) {
+
+	srv := c.srv
+	user := srv.ContextGetUser(ctx)
+
+	recipient, ok := c.readOwnRecipient(ctx, user.ID)
+	if !ok {
+		return
+	}
+
+	stats, err := srv.Store.GetRecipientUsageStats(ctx, recipient.ID)
+	if err != nil {
+		srv.Logger.Error(err, map[string]interface{}{
+			"recipient_id": recipient.ID,
+		})
+		srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, errors.New("unable to fetch recipient stats"))
+		return
+	}
+
+	srv.SuccessJSONResponse(ctx, http.StatusOK, "recipient stats retrieved successfully", stats)
+}
+
+// UpdateRecipientProfile sets the nickname, tags and favourite flag of a recipient.
+func (c *usersController) UpdateRecipientProfile(ctx *gin.Context) {
+
+	srv := c.srv
+	user := srv.ContextGetUser(ctx)
+
+	recipient, ok := c.readOwnRecipient(ctx, user.ID)
+	if !ok {
+		return
+	}
+
+	var req updateRecipientProfileRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		srv.ErrorJSONResponse(ctx, http.StatusBadRequest, errors.New("invalid request"))
+		return
+	}
+
+	req.Nickname = strings.TrimSpace(req.Nickname)
+
+	v := validator.New()
+	v.Check(len(req.Nickname) <= 100, "nickname", "must not be more than 100 characters")
+	v.Check(len(req.Tags) <= maxRecipientTags, "tags", fmt.Sprintf("must not have more than %d tags", maxRecipientTags))
+	for _, tag := range req.Tags {
+		v.Check(len(tag) <= 30, "tags", "each tag must not be more than 30 characters")
+	}
+	if !v.Valid() {
+		srv.SendValidationError(ctx, validator.NewValidationError("validation failed", v.Errors))
+		return
+	}
+
+	profile, err := srv.Store.UpsertRecipientProfile(ctx, db.UpsertRecipientProfileParams{
+		RecipientID: recipient.ID,
+		Nickname:    req.Nickname,
+		Tags:        req.Tags,
+		IsFavourite: req.IsFavourite,
+	})
+	if err != nil {
+		srv.Logger.Error(err, map[string]interface{}{
+			"recipient_id": recipient.ID,
+		})
+		srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, errors.New("unable to update recipient"))
+		return
+	}
+
+	srv.SuccessJSONResponse(ctx, http.StatusOK, "recipient updated successfully", profile)
+}
+
+// ShareRecipient adds one of the user's recipients to the business recipient book.
+// Only owners and editors of the book can share.
+func (c *usersController) ShareRecipient(ctx *gin.Context) {
+	c.setRecipientShare(ctx, true)
+}
+
+// UnshareRecipient removes a recipient from the business recipient book.
+func (c *usersController) UnshareRecipient(ctx *gin.Context) {
+	c.setRecipientShare(ctx, false)
+}
+
+func (c *usersController) setRecipientShare(ctx *gin.Context, share bool) {
+
+	srv := c.srv
+	user := srv.ContextGetUser(ctx)
+
+	recipientID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		srv.ErrorJSONResponse(ctx, http.StatusUnprocessableEntity, fmt.Errorf("invalid recipient_id param"))
+		return
+	}
+
+	businessID, ok := c.recipientBookBusiness(ctx, user.ID)
+	if !ok {
+		return
+	}
+
+	if !c.requireRecipientBookRole(ctx, businessID, user.ID, db.RecipientBookRoleOwner, db.RecipientBookRoleEditor) {
+		return
+	}
+
+	if share {
+		_, err = srv.Store.GetUserRecipient(ctx, db.GetUserRecipientParams{UserID: user.ID, ID: recipientID})
+	} else {
+		_, err = srv.Store.GetSharedRecipient(ctx, recipientID, businessID)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			srv.ErrorJSONResponse(ctx, http.StatusNotFound, errors.New("recipient not found"))
+			return
+		}
+		srv.Logger.Error(err, map[string]interface{}{
+			"recipient_id": recipientID,
+		})
+		srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	target := uuid.NullUUID{}
+	message := "recipient removed from business book"
+	if share {
+		target = uuid.NullUUID{UUID: businessID, Valid: true}
+		message = "recipient shared successfully"
+	}
+
+	if err := srv.Store.SetRecipientBusinessShare(ctx, recipientID, target); err != nil {
+		srv.Logger.Error(err, map[string]interface{}{
+			"recipient_id": recipientID,
+			"business_id":  businessID,
+		})
+		srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, errors.New("unable to update recipient"))
+		return
+	}
+
+	srv.SuccessJSONResponse(ctx, http.StatusOK, message, nil)
+}
+
+// GetRecipientBookMembers lists the members of the business recipient book.
+func (c *usersController) GetRecipientBookMembers(ctx *gin.Context) {
+
+	srv := c.srv
+	user := srv.ContextGetUser(ctx)
+
+	businessID, ok := c.recipientBookBusiness(ctx, user.ID)
+	if !ok {
+		return
+	}
+
+	members, err := srv.Store.ListRecipientBookMembers(ctx, businessID)
+	if err != nil {
+		srv.Logger.Error(err, map[string]interface{}{
+			"business_id": businessID,
+		})
+		srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, errors.New("unable to fetch members"))
+		return
+	}
+
+	srv.SuccessJSONResponse(ctx, http.StatusOK, "members retrieved successfully", members)
+}
+
+// SetRecipientBookMember grants a team member access to the business recipient book.
+// Only the owner can manage members.
+func (c *usersController) SetRecipientBookMember(ctx *gin.Context) {
+
+	srv := c.srv
+	user := srv.ContextGetUser(ctx)
+
+	var req recipientBookMemberRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		srv.ErrorJSONResponse(ctx, http.StatusBadRequest, errors.New("invalid request"))
+		return
+	}
+
+	v := validator.NewWithStore(ctx, srv.Store)
+	v.Check(req.UserID != uuid.Nil, "user_id", "must be provided")
+	v.Check(req.UserID != user.ID, "user_id", "cannot change your own role")
+	v.Check(validator.In(req.Role, db.ValidRecipientBookRoles...), "role", "must be editor or viewer")
+	if v.Valid() {
+		v.UserIDExists(req.UserID)
+	}
+	if !v.Valid() {
+		srv.SendValidationError(ctx, validator.NewValidationError("validation failed", v.Errors))
+		return
+	}
+
+	businessID, ok := c.recipientBookBusiness(ctx, user.ID)
+	if !ok {
+		return
+	}
+
+	if !c.requireRecipientBookRole(ctx, businessID, user.ID, db.RecipientBookRoleOwner) {
+		return
+	}
+
+	if err := srv.Store.SetRecipientBookMember(ctx, businessID, req.UserID, req.Role); err != nil {
+		srv.Logger.Error(err, map[string]interface{}{
+			"business_id": businessID,
+			"user_id":     req.UserID,
+		})
+		srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, errors.New("unable to save member"))
+		return
+	}
+
+	srv.SuccessJSONResponse(ctx, http.StatusOK, "member saved successfully", nil)
+}
+
+// RemoveRecipientBookMember revokes a team member's access to the business recipient book.
+func (c *usersController) RemoveRecipientBookMember(ctx *gin.Context) {
+
+	srv := c.srv
+	user := srv.ContextGetUser(ctx)
+
+	memberID, err := uuid.Parse(ctx.Param("user_id"))
+	if err != nil {
+		srv.ErrorJSONResponse(ctx, http.StatusUnprocessableEntity, fmt.Errorf("invalid user_id param"))
+		return
+	}
+
+	businessID, ok := c.recipientBookBusiness(ctx, user.ID)
+	if !ok {
+		return
+	}
+
+	if !c.requireRecipientBookRole(ctx, businessID, user.ID, db.RecipientBookRoleOwner) {
+		return
+	}
+
+	if err := srv.Store.RemoveRecipientBookMember(ctx, businessID, memberID); err != nil {
+		srv.Logger.Error(err, map[string]interface{}{
+			"business_id": businessID,
+			"user_id":     memberID,
+		})
+		srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, errors.New("unable to remove member"))
+		return
+	}
+
+	srv.SuccessJSONResponse(ctx, http.StatusOK, "member removed successfully", nil)
+}
+
+func (c *usersController) readOwnRecipient(ctx *gin.Context, userID uuid.UUID) (db.Recipient, bool) {
+	srv := c.srv
+
+	recipientID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		srv.ErrorJSONResponse(ctx, http.StatusUnprocessableEntity, fmt.Errorf("invalid recipient_id param"))
+		return db.Recipient{}, false
+	}
+
+	recipient, err := srv.Store.GetUserRecipient(ctx, db.GetUserRecipientParams{
+		UserID: userID,
+		ID:     recipientID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			srv.ErrorJSONResponse(ctx, http.StatusNotFound, errors.New("recipient not found"))
+			return db.Recipient{}, false
+		}
+		srv.Logger.Error(err, map[string]interface{}{
+			"request": userID,
+		})
+		srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+		return db.Recipient{}, false
+	}
+
+	return recipient, true
+}
+
+func (c *usersController) recipientBookBusiness(ctx *gin.Context, userID uuid.UUID) (uuid.UUID, bool) {
+	srv := c.srv
+
+	businessID, err := srv.Store.GetUserRecipientBookBusiness(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			srv.ErrorJSONResponse(ctx, http.StatusForbidden, errors.New("no business recipient book available"))
+			return uuid.Nil, false
+		}
+		srv.Logger.Error(err, map[string]interface{}{
+			"user_id": userID,
+		})
+		srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+		return uuid.Nil, false
+	}
+
+	return businessID, true
+}
+
+func (c *usersController) requireRecipientBookRole(ctx *gin.Context, businessID, userID uuid.UUID, roles ...string) bool {
+	srv := c.srv
+
+	role, err := srv.Store.GetRecipientBookRole(ctx, businessID, userID)
+	if err != nil {
+		srv.Logger.Error(err, map[string]interface{}{
+			"business_id": businessID,
+			"user_id":     userID,
+		})
+		srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, err)
+		return false
+	}
+
+	if !validator.In(role, roles...) {
+		srv.ErrorJSONResponse(ctx, http.StatusForbidden, errors.New("you do not have access to perform this action"))
+		return false
+	}
+
+	return true
+}
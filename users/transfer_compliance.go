@@ -0,0 +1,118 @@
+package users
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/schemes"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+const regulatoryReportDateLayout = "2006-01-02"
+
+// GetTransferComplianceRequirements returns the compliance fields expected for transfers with
+// a payout scheme, and destination country for SWIFT, so clients can render purpose code,
+// source of funds and relationship inputs.
+func (c *usersController) GetTransferComplianceRequirements(ctx *gin.Context) {
+
+	srv := c.srv
+
+	scheme := ctx.Query("scheme")
+	country := ctx.Query("country")
+
+	req, regulated := schemes.CorridorRequirementFor(scheme, country)
+
+	srv.SuccessJSONResponse(ctx, http.StatusOK, "compliance requirements retrieved successfully", gin.H{
+		"regulated":        regulated,
+		"requirement":      req,
+		"sources_of_funds": schemes.SourcesOfFunds,
+		"relationships":    schemes.Relationships,
+	})
+}
+
+// ExportRegulatoryReport streams the external transfers of a date range, with their
+// compliance data, as CSV.
+func (c *usersController) ExportRegulatoryReport(ctx *gin.Context) {
+
+	srv := c.srv
+
+	input := struct {
+		Currency string `form:"currency"`
+		From     string `form:"from"`
+		To       string `form:"to"`
+	}{}
+
+	if err := ctx.ShouldBindQuery(&input); err != nil {
+		srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	from, fromErr := time.Parse(regulatoryReportDateLayout, input.From)
+	to, toErr := time.Parse(regulatoryReportDateLayout, input.To)
+
+	v := validator.New()
+	v.Check(fromErr == nil, "from", "must be a date in the format YYYY-MM-DD")
+	v.Check(toErr == nil, "to", "must be a date in the format YYYY-MM-DD")
+	if v.Valid() {
+		v.Check(!to.Before(from), "to", "must not be before from")
+		v.Check(to.Sub(from) <= 366*24*time.Hour, "to", "date range must not exceed one year")
+	}
+	if !v.Valid() {
+		srv.SendValidationError(ctx, validator.NewValidationError("validation failed", v.Errors))
+		return
+	}
+
+	rows, err := srv.Store.GetRegulatoryReportRows(ctx, db.RegulatoryReportFilter{
+		Currency: input.Currency,
+		From:     from,
+		To:       to.AddDate(0, 0, 1),
+	})
+	if err != nil {
+		srv.Logger.Error(err, map[string]interface{}{
+			"request": input,
+		})
+		srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, errors.New("unable to generate report"))
+		return
+	}
+
+	filename := fmt.Sprintf("regulatory-report-%s-%s.csv", input.From, input.To)
+	ctx.Header("Content-Type", "text/csv")
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	ctx.Status(http.StatusOK)
+
+	w := csv.NewWriter(ctx.Writer)
+	_ = w.Write([]string{
+		"transaction_id", "created_at", "status", "amount", "currency", "sender", "sender_email",
+		"beneficiary", "scheme", "payout_currency", "country", "purpose_code", "source_of_funds", "relationship",
+	})
+	for _, r := range rows {
+		_ = w.Write([]string{
+			r.TransactionID.String(),
+			r.CreatedAt.Format(time.RFC3339),
+			r.Status,
+			r.Amount.String(),
+			r.Currency,
+			r.Sender,
+			r.SenderEmail,
+			r.Beneficiary,
+			r.Scheme,
+			r.PayoutCurrency,
+			r.Country,
+			r.PurposeCode,
+			r.SourceOfFunds,
+			r.Relationship,
+		})
+	}
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		srv.Logger.Error(err, map[string]interface{}{
+			"request": input,
+		})
+	}
+}
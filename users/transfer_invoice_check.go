@@ -0,0 +1,131 @@
+package users
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/invoice"
+	"github.com/timchuks/monieverse/internal/validator"
+)
+
+// queueTransferInvoiceCheck records a pending check of an uploaded invoice and queues the task
+// that extracts and matches it, which invoice.Checker runs in the worker. Failures are logged
+// and never fail the upload; the check then stays pending for manual review.
+func (c *usersController) queueTransferInvoiceCheck(ctx context.Context, transfer *db.Transaction, documentID uuid.UUID, contentType string) {
+	srv := c.srv
+
+	_, err := srv.Store.CreateTransferInvoiceCheck(ctx, db.CreateTransferInvoiceCheckParams{
+		TransactionID: transfer.ID,
+		DocumentID:    documentID,
+		Status:        invoice.StatusPending,
+	})
+	if err == nil {
+		err = srv.TaskDistributor.Fire(ctx, invoice.TaskCheckTransferInvoice, invoice.CheckTask{
+			TransactionID: transfer.ID,
+			DocumentID:    documentID,
+			ContentType:   contentType,
+		})
+	}
+	if err != nil {
+		srv.Logger.Error(err, map[string]interface{}{
+			"transfer_id": transfer.ID,
+			"document_id": documentID,
+		})
+	}
+}
+
+// GetTransferInvoiceChecks lists invoice checks for compliance review, defaulting to flagged ones.
+func (c *usersController) GetTransferInvoiceChecks(ctx *gin.Context) {
+
+	srv := c.srv
+
+	input := struct {
+		db.Filter
+		Status string `form:"status"`
+	}{}
+
+	if err := ctx.ShouldBindQuery(&input); err != nil {
+		srv.ErrorJSONResponse(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if input.Page <= 0 {
+		input.Page = 1
+	}
+	if input.Status == "" {
+		input.Status = invoice.StatusFlagged
+	}
+
+	checks, meta, err := srv.Store.ListTransferInvoiceChecks(ctx, db.TransferInvoiceCheckFilter{
+		Filter: input.Filter,
+		Status: input.Status,
+	})
+	if err != nil {
+		srv.Logger.Error(err, nil)
+		srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, errors.New("unable to fetch invoice checks"))
+		return
+	}
+
+	srv.SuccessJSONResponse(ctx, http.StatusOK, "invoice checks retrieved successfully", gin.H{
+		"checks": checks,
+		"meta":   meta,
+	})
+}
+
+// ReviewTransferInvoiceCheck records a compliance officer's decision on a flagged invoice.
+func (c *usersController) ReviewTransferInvoiceCheck(ctx *gin.Context) {
+
+	srv := c.srv
+	authUser := srv.ContextGetUser(ctx)
+
+	checkID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		srv.ErrorJSONResponse(ctx, http.StatusUnprocessableEntity, fmt.Errorf("invalid id param"))
+		return
+	}
+
+	input := struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}{}
+
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		srv.ErrorJSONResponse(ctx, http.StatusBadRequest, errors.New("invalid request"))
+		return
+	}
+
+	v := validator.New()
+	v.Check(validator.In(input.Status, invoice.StatusApproved, invoice.StatusRejected), "status", "must be approved or rejected")
+	v.Check(input.Status != invoice.StatusRejected || strings.TrimSpace(input.Note) != "", "note", "must be provided when rejecting")
+	if !v.Valid() {
+		srv.SendValidationError(ctx, validator.NewValidationError("validation failed", v.Errors))
+		return
+	}
+
+	check, err := srv.Store.ReviewTransferInvoiceCheck(ctx, db.ReviewTransferInvoiceCheckParams{
+		ID:         checkID,
+		Status:     input.Status,
+		ReviewedBy: authUser.ID,
+		ReviewNote: strings.TrimSpace(input.Note),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			srv.ErrorJSONResponse(ctx, http.StatusNotFound, errors.New("invoice check not found"))
+			return
+		}
+		srv.Logger.Error(err, map[string]interface{}{
+			"check_id": checkID,
+		})
+		srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, errors.New("unable to review invoice check"))
+		return
+	}
+
+	srv.SuccessJSONResponse(ctx, http.StatusOK, "invoice check reviewed successfully", check)
+}
@@ -0,0 +1,225 @@
+package users
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	db "github.com/timchuks/monieverse/internal/db/sqlc"
+	"github.com/timchuks/monieverse/internal/tracking"
+)
+
+const (
+	trackingNumberAttempts = 5
+
+	// trackingRequestsPerMinute is how many public tracking lookups a client IP may make per minute.
+	trackingRequestsPerMinute = 30
+
+	// trackingFailedLookups is how many lookups of unknown tracking numbers lock a client IP out
+	// of tracking until trackingLockout has passed since the first of them.
+	trackingFailedLookups = 10
+	trackingLockout       = 30 * time.Minute
+
+	trackingTimelineLimit = 100
+)
+
+// assignTrackingNumber gives a transfer a tracking number for the corridor paying out in currency,
+// retrying on the unlikely collision with an existing number.
+func (c *usersController) assignTrackingNumber(ctx context.Context, transactionID uuid.UUID, currency string) (string, error) {
+	srv := c.srv
+
+	var lastErr error
+	for i := 0; i < trackingNumberAttempts; i++ {
+		number, err := tracking.Generate(currency)
+		if err != nil {
+			return "", err
+		}
+
+		set, err := srv.Store.SetTransactionTrackingNumber(ctx, transactionID, number)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if !set {
+			transfer, err := srv.Store.GetTransaction(ctx, transactionID)
+			if err != nil {
+				return "", err
+			}
+			return transfer.TrackingNumber, nil
+		}
+
+		return number, nil
+	}
+
+	return "", fmt.Errorf("unable to assign tracking number: %w", lastErr)
+}
+
+func (c *usersController) trackingURL(trackingNumber string) string {
+	return fmt.Sprintf("%s/track/%s", strings.TrimRight(c.srv.Config.AppBaseURL, "/"), trackingNumber)
+}
+
+// GetTransferTrackingLink returns the tracking number and shareable link of one of the user's transfers.
+func (c *usersController) GetTransferTrackingLink(ctx *gin.Context) {
+
+	srv := c.srv
+	authUser := srv.ContextGetUser(ctx)
+
+	transferID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		srv.ErrorJSONResponse(ctx, http.StatusUnprocessableEntity, fmt.Errorf("invalid transfer id"))
+		return
+	}
+
+	transfer, err := srv.Store.GetTransaction(ctx, transferID)
+	if err != nil || transfer.UserID != authUser.ID || transfer.Action != db.TransactionActionExternalTransfer {
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			srv.Logger.Error(err, map[string]interface{}{
+				"transfer_id": transferID,
+			})
+		}
+		srv.ErrorJSONResponse(ctx, http.StatusNotFound, fmt.Errorf("transfer not found"))
+		return
+	}
+
+	trackingNumber := transfer.TrackingNumber
+	if trackingNumber == "" {
+		trackingNumber, err = c.assignTrackingNumber(ctx, transfer.ID, transferPayoutCurrency(transfer))
+		if err != nil {
+			srv.Logger.Error(err, map[string]interface{}{
+				"transfer_id": transfer.ID,
+			})
+			srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, errors.New("unable to generate tracking number"))
+			return
+		}
+	}
+
+	srv.SuccessJSONResponse(ctx, http.StatusOK, "tracking link retrieved successfully", gin.H{
+		"tracking_number": trackingNumber,
+		"tracking_url":    c.trackingURL(trackingNumber),
+	})
+}
+
+// TrackingRateLimit limits public tracking lookups per client IP, and turns away IPs locked out
+// for looking up too many unknown tracking numbers. The limits are counted in the shared Redis;
+// lookups are let through when it can't be reached.
+func (c *usersController) TrackingRateLimit() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ip := ctx.ClientIP()
+
+		locked, err := c.trackingFailures.Exceeded(ctx, ip)
+		if err == nil && !locked {
+			var allowed bool
+			allowed, err = c.trackingRequests.Allow(ctx, ip)
+			locked = !allowed
+		}
+		if err != nil {
+			c.srv.Logger.Error(err, map[string]interface{}{
+				"ip": ip,
+			})
+		} else if locked {
+			c.srv.ErrorJSONResponse(ctx, http.StatusTooManyRequests, errors.New("too many requests, try again later"))
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// trackingLookupFailed counts a lookup of an unknown tracking number against the client IP
+func (c *usersController) trackingLookupFailed(ctx *gin.Context) {
+	if _, err := c.trackingFailures.Allow(ctx, ctx.ClientIP()); err != nil {
+		c.srv.Logger.Error(err, map[string]interface{}{
+			"ip": ctx.ClientIP(),
+		})
+	}
+}
+
+// TrackTransfer is the public, unauthenticated status page of a transfer. It only exposes the
+// payout currency, a masked beneficiary name and a redacted status timeline.
+func (c *usersController) TrackTransfer(ctx *gin.Context) {
+
+	srv := c.srv
+
+	trackingNumber := tracking.Normalize(ctx.Param("tracking_number"))
+	if !tracking.Valid(trackingNumber) {
+		c.trackingLookupFailed(ctx)
+		srv.ErrorJSONResponse(ctx, http.StatusNotFound, tracking.ErrInvalidTrackingNumber)
+		return
+	}
+
+	transfer, err := srv.Store.GetTransactionByTrackingNumber(ctx, trackingNumber)
+	if err != nil || transfer.Action != db.TransactionActionExternalTransfer {
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			srv.Logger.Error(err, map[string]interface{}{
+				"tracking_number": trackingNumber,
+			})
+		} else {
+			c.trackingLookupFailed(ctx)
+		}
+		srv.ErrorJSONResponse(ctx, http.StatusNotFound, fmt.Errorf("transfer not found"))
+		return
+	}
+
+	histories, err := srv.Store.GetTransactionHistories(ctx, db.GetTransactionHistoriesParams{
+		TransactionID: transfer.ID,
+		Limit:         trackingTimelineLimit,
+	})
+	if err != nil {
+		srv.Logger.Error(err, map[string]interface{}{
+			"transfer_id": transfer.ID,
+		})
+		srv.ErrorJSONResponse(ctx, http.StatusInternalServerError, errors.New("unable to fetch transfer status"))
+		return
+	}
+
+	events := make([]tracking.Event, 0, len(histories))
+	for _, h := range histories {
+		events = append(events, tracking.Event{OldStatus: h.OldStatus, NewStatus: h.NewStatus, At: h.CreatedAt})
+	}
+
+	timeline := tracking.Timeline(transfer.CreatedAt, events)
+	stage := tracking.Stage(transfer.Status)
+
+	srv.SuccessJSONResponse(ctx, http.StatusOK, "transfer status retrieved successfully", gin.H{
+		"tracking_number": trackingNumber,
+		"stage":           stage,
+		"currency":        transferPayoutCurrency(transfer),
+		"beneficiary":     tracking.MaskName(transferBeneficiaryName(transfer)),
+		"timeline":        timeline,
+		"last_updated":    timeline[len(timeline)-1].At,
+	})
+}
+
+func transferPayoutCurrency(transfer db.Transaction) string {
+	var pl ExternalTransferPayload
+	if err := json.Unmarshal(transfer.Payload, &pl); err != nil || pl.Recipient == nil {
+		return ""
+	}
+	return pl.Recipient.Currency
+}
+
+func transferBeneficiaryName(transfer db.Transaction) string {
+	var pl ExternalTransferPayload
+	if err := json.Unmarshal(transfer.Payload, &pl); err != nil || pl.Recipient == nil {
+		return ""
+	}
+
+	var data struct {
+		AccountHolderFullname string `json:"account_holder_fullname"`
+		BusinessName          string `json:"business_name"`
+	}
+	_ = json.Unmarshal(pl.Recipient.Data, &data)
+
+	if data.AccountHolderFullname != "" {
+		return data.AccountHolderFullname
+	}
+	return data.BusinessName
+}
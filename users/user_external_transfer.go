@@ -19,14 +19,16 @@ import (
 	db "github.com/timchuks/monieverse/internal/db/sqlc"
 	"github.com/timchuks/monieverse/internal/domain"
 	"github.com/timchuks/monieverse/internal/notifier"
+	"github.com/timchuks/monieverse/internal/schemes"
 	"github.com/timchuks/monieverse/internal/useraction"
 	"github.com/timchuks/monieverse/internal/validator"
 )
 
 type ExternalTransferPayload struct {
-	Recipient *db.Recipient `json:"recipient"`
-	Customer  *db.Customer  `json:"customer"`
-	Wallet    *db.Wallet    `json:"wallet"`
+	Recipient  *db.Recipient           `json:"recipient"`
+	Customer   *db.Customer            `json:"customer"`
+	Wallet     *db.Wallet              `json:"wallet"`
+	Compliance *schemes.ComplianceInfo `json:"compliance,omitempty"`
 }
 
 func (s *ExternalTransferPayload) Bytes() []byte {
@@ -52,12 +54,24 @@ func (c *usersController) CreateNewExternalTransfer(ctx *gin.Context) {
 		return
 	}
 
+	compliance := schemes.ComplianceInfo{
+		PurposeCode:   req.PurposeCode,
+		SourceOfFunds: req.SourceOfFunds,
+		Relationship:  req.Relationship,
+	}.Normalize()
+
 	v := validator.NewWithStore(ctx, srv.Store)
 	if !req.Validate(v, authUser) {
 		srv.SendValidationError(ctx, validator.NewValidationError(server.ResponseValidationFailed, v.Errors))
 		return
 	}
 
+	schemes.ValidateTransferCompliance(v, req.Recipient, compliance)
+	if !v.Valid() {
+		srv.SendValidationError(ctx, validator.NewValidationError(server.ResponseValidationFailed, v.Errors))
+		return
+	}
+
 	fee, err := srv.Store.GetSchemaPaymentFeeConfig(ctx, strings.ToLower(req.Recipient.Scheme))
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		srv.Logger.Error(err, map[string]interface{}{
@@ -104,6 +118,10 @@ func (c *usersController) CreateNewExternalTransfer(ctx *gin.Context) {
 		Wallet:    req.Wallet,
 	}
 
+	if !compliance.IsEmpty() {
+		pl.Compliance = &compliance
+	}
+
 	if req.Reason == "" {
 		req.Reason = "external fund transfer"
 	}
@@ -122,7 +140,7 @@ func (c *usersController) CreateNewExternalTransfer(ctx *gin.Context) {
 		Tag:              req.Reason,
 	}
 
-	_, err = srv.WalletManager.Debit(ctx, req.Wallet, args)
+	transfer, err := srv.WalletManager.Debit(ctx, req.Wallet, args)
 
 	if err != nil {
 
@@ -147,7 +165,20 @@ func (c *usersController) CreateNewExternalTransfer(ctx *gin.Context) {
 		args.PaymentMethod,
 		args.Amount), nil)
 
-	srv.SuccessJSONResponse(ctx, http.StatusOK, server.ResponseOk, nil)
+	trackingNumber, err := c.assignTrackingNumber(ctx, transfer.ID, req.Recipient.Currency)
+	if err != nil {
+		// the transfer went through, the sender can fetch the tracking link later
+		srv.Logger.Error(err, map[string]interface{}{
+			"transfer_id": transfer.ID,
+		})
+		srv.SuccessJSONResponse(ctx, http.StatusOK, server.ResponseOk, nil)
+		return
+	}
+
+	srv.SuccessJSONResponse(ctx, http.StatusOK, server.ResponseOk, gin.H{
+		"tracking_number": trackingNumber,
+		"tracking_url":    c.trackingURL(trackingNumber),
+	})
 }
 
 func (c *usersController) sendExternalTransferNotification(ctx *gin.Context, args db.CreateTransactionParams, authUser *db.User) error {
@@ -15,6 +15,7 @@ import (
 	"github.com/timchuks/monieverse/internal/core"
 	db "github.com/timchuks/monieverse/internal/db/sqlc"
 	"github.com/timchuks/monieverse/internal/domain"
+	"github.com/timchuks/monieverse/internal/schemes"
 	"github.com/timchuks/monieverse/internal/validator"
 )
 
@@ -43,7 +44,10 @@ func (c *usersController) CreateRecipient(ctx *gin.Context) {
 	}
 
 	v := validator.NewWithStore(ctx, srv.Store)
-	if !req.Validate(v) {
+	if req.Validate(v) {
+		schemes.ValidateRecipientData(v, req.GetScheme(), req.GetData())
+	}
+	if !v.Valid() {
 		srv.SendValidationError(ctx, validator.NewValidationError("validation failed", v.Errors))
 		return
 	}
@@ -113,7 +117,10 @@ func (c *usersController) UpdateRecipient(ctx *gin.Context) {
 	}
 
 	v := validator.NewWithStore(ctx, srv.Store)
-	if !req.Validate(v) {
+	if req.Validate(v) {
+		schemes.ValidateRecipientData(v, req.GetScheme(), req.GetData())
+	}
+	if !v.Valid() {
 		srv.SendValidationError(ctx, validator.NewValidationError("validation failed", v.Errors))
 		return
 	}
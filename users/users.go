@@ -1,15 +1,26 @@
 package users
 
 import (
+	"time"
+
 	"github.com/timchuks/monieverse/core/server"
+	"github.com/timchuks/monieverse/internal/tracking"
 )
 
 type usersController struct {
 	srv *server.Server
+
+	trackingRequests *tracking.Limiter
+	trackingFailures *tracking.Limiter
 }
 
 func NewUsersController(srv *server.Server) *usersController {
+	counter := tracking.NewRedisCounter(srv.Config)
+
 	return &usersController{
 		srv: srv,
+
+		trackingRequests: tracking.NewLimiter(counter, "tracking:requests", trackingRequestsPerMinute, time.Minute),
+		trackingFailures: tracking.NewLimiter(counter, "tracking:failures", trackingFailedLookups, trackingLockout),
 	}
 }
@@ -20,6 +20,8 @@ func registerUserRoutes(r *gin.RouterGroup, srv *server.Server) {
 
 	uctr := userCtr.NewUsersController(srv)
 
+	r.GET("/track/:tracking_number", uctr.TrackingRateLimit(), uctr.TrackTransfer)
+
 	user.GET("/users/profile", uctr.GetUserProfile)
 	user.PATCH("/users/profile", srv.Idempotency(ratelimiter.OperationTypeUpdateUserProfile, nil),
 		uctr.UpdateUserProfile)
@@ -53,6 +55,15 @@ func registerUserRoutes(r *gin.RouterGroup, srv *server.Server) {
 	user.PUT("/users/recipients/:id", srv.RequirePIN(), uctr.UpdateRecipient)
 	user.GET("/users/recipients/supported-fields", uctr.GetCurrencySupportedFields)
 
+	user.GET("/users/recipient-book", uctr.GetRecipientBook)
+	user.PUT("/users/recipient-book/:id", uctr.UpdateRecipientProfile)
+	user.GET("/users/recipient-book/:id/stats", uctr.GetRecipientStats)
+	user.POST("/users/recipient-book/:id/share", uctr.ShareRecipient)
+	user.DELETE("/users/recipient-book/:id/share", uctr.UnshareRecipient)
+	user.GET("/users/recipient-book-members", uctr.GetRecipientBookMembers)
+	user.PUT("/users/recipient-book-members", uctr.SetRecipientBookMember)
+	user.DELETE("/users/recipient-book-members/:user_id", uctr.RemoveRecipientBookMember)
+
 	user.POST("/users/settings/set-transaction-pin", srv.CheckIfTransactionPINAlreadySet(), uctr.SetTransactionPin)
 	user.PATCH("/users/settings/change-transaction-pin", uctr.ChangeTransactionPin)
 
@@ -66,6 +77,8 @@ func registerUserRoutes(r *gin.RouterGroup, srv *server.Server) {
 	user.POST("/transfer/new", srv.Idempotency(ratelimiter.OperationTypeCreateTransfer, nil),
 		srv.RequirePIN(), uctr.CreateNewExternalTransfer)
 	user.POST("/transfer/invoice", srv.RequirePIN(), uctr.UploadTransferInvoice)
+	user.GET("/transfer/compliance-requirements", uctr.GetTransferComplianceRequirements)
+	user.GET("/transfer/:id/tracking", uctr.GetTransferTrackingLink)
 
 	user.GET("/settings/schemes",
 		uctr.GetAllPaymentSchemeConfigs)
@@ -110,5 +123,26 @@ func registerUserRoutes(r *gin.RouterGroup, srv *server.Server) {
 	biz.PATCH("/kyb/:id/documents", businessCtr.UpdateBusinessDocumentByID)
 
 	registerAdminRoutes(srv, user)
+	registerComplianceAdminRoutes(srv, user, uctr)
+
+}
+
+// complianceController serves the compliance tools of the admin API
+type complianceController interface {
+	ExportRegulatoryReport(ctx *gin.Context)
+	GetTransferInvoiceChecks(ctx *gin.Context)
+	ReviewTransferInvoiceCheck(ctx *gin.Context)
+}
+
+// registerComplianceAdminRoutes registers the compliance tools of the admin API next to
+// registerAdminRoutes. Each route requires its own permission, so compliance staff need no
+// other admin access.
+func registerComplianceAdminRoutes(srv *server.Server, r *gin.RouterGroup, uctr complianceController) {
+	admin := r.Group("/admin")
+
+	admin.GET("/reports/regulatory", srv.RequirePermission(perms.AdminCanExportRegulatoryReports), uctr.ExportRegulatoryReport)
 
+	invoiceReview := srv.RequirePermission(perms.AdminCanReviewTransferInvoices)
+	admin.GET("/transfer-invoices", invoiceReview, uctr.GetTransferInvoiceChecks)
+	admin.POST("/transfer-invoices/:id/review", invoiceReview, uctr.ReviewTransferInvoiceCheck)
 }
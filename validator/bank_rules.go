@@ -0,0 +1,200 @@
+package validator
+
+import (
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Names of the structural rules that can be attached to payment scheme fields.
+const (
+	RuleIBAN             = "iban"
+	RuleBIC              = "bic"
+	RuleABARoutingNumber = "aba_routing_number"
+	RuleUKSortCode       = "uk_sort_code"
+	RuleCAInstitution    = "ca_institution_number"
+	RuleCATransit        = "ca_transit_number"
+	RuleUnionPayCard     = "unionpay_card"
+	RuleCNAPS            = "cnaps"
+)
+
+var (
+	bicRgx         = regexp.MustCompile(`^[A-Z]{4}[A-Z]{2}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+	ibanRgx        = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]+$`)
+	digitsRgx      = regexp.MustCompile(`^[0-9]+$`)
+	separatorsRgx  = regexp.MustCompile(`[\s-]`)
+	ninetySeven    = big.NewInt(97)
+	ibanCountryLen = map[string]int{
+		"AD": 24, "AE": 23, "AL": 28, "AT": 20, "AZ": 28, "BA": 20, "BE": 16, "BG": 22,
+		"BH": 22, "BR": 29, "BY": 28, "CH": 21, "CR": 22, "CY": 28, "CZ": 24, "DE": 22,
+		"DK": 18, "DO": 28, "EE": 20, "EG": 29, "ES": 24, "FI": 18, "FO": 18, "FR": 27,
+		"GB": 22, "GE": 22, "GI": 23, "GL": 18, "GR": 27, "GT": 28, "HR": 21, "HU": 28,
+		"IE": 22, "IL": 23, "IQ": 23, "IS": 26, "IT": 27, "JO": 30, "KW": 30, "KZ": 20,
+		"LB": 28, "LC": 32, "LI": 21, "LT": 20, "LU": 20, "LV": 21, "MC": 27, "MD": 24,
+		"ME": 22, "MK": 19, "MR": 27, "MT": 31, "MU": 30, "NL": 18, "NO": 15, "PK": 24,
+		"PL": 28, "PS": 29, "PT": 25, "QA": 29, "RO": 24, "RS": 22, "SA": 24, "SC": 31,
+		"SE": 24, "SI": 19, "SK": 24, "SM": 27, "ST": 25, "SV": 28, "TL": 23, "TN": 24,
+		"TR": 26, "UA": 29, "VA": 22, "VG": 24, "XK": 20,
+	}
+	isoCountryCodes = strings.Fields(`
+		AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ BA BB BD BE BF BG BH BI BJ BL BM BN BO BQ BR BS
+		BT BV BW BY BZ CA CC CD CF CG CH CI CK CL CM CN CO CR CU CV CW CX CY CZ DE DJ DK DM DO DZ EC EE
+		EG EH ER ES ET FI FJ FK FM FO FR GA GB GD GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY HK HM
+		HN HR HT HU ID IE IL IM IN IO IQ IR IS IT JE JM JO JP KE KG KH KI KM KN KP KR KW KY KZ LA LB LC
+		LI LK LR LS LT LU LV LY MA MC MD ME MF MG MH MK ML MM MN MO MP MQ MR MS MT MU MV MW MX MY MZ NA
+		NC NE NF NG NI NL NO NP NR NU NZ OM PA PE PF PG PH PK PL PM PN PR PS PT PW PY QA RE RO RS RU RW
+		SA SB SC SD SE SG SH SI SJ SK SL SM SN SO SR SS ST SV SX SY SZ TC TD TF TG TH TJ TK TL TM TN TO
+		TR TT TV TW TZ UA UG UM US UY UZ VA VC VE VG VI VN VU WF WS XK YE YT ZA ZM ZW`)
+)
+
+// FieldRule is a named structural check applied to a single string value.
+type FieldRule struct {
+	Check   func(value string) bool
+	Message string
+}
+
+// FieldRules holds the structural rules that scheme fields can refer to by name.
+var FieldRules = map[string]FieldRule{
+	RuleIBAN:             {Check: IsIBAN, Message: "invalid IBAN"},
+	RuleBIC:              {Check: IsBIC, Message: "invalid SWIFT/BIC code"},
+	RuleABARoutingNumber: {Check: IsABARoutingNumber, Message: "invalid routing number"},
+	RuleUKSortCode:       {Check: IsUKSortCode, Message: "sort code must be 6 digits"},
+	RuleCAInstitution:    {Check: IsCAInstitutionNumber, Message: "institution number must be 3 digits"},
+	RuleCATransit:        {Check: IsCATransitNumber, Message: "transit number must be 5 digits"},
+	RuleUnionPayCard:     {Check: IsUnionPayCard, Message: "invalid UnionPay card number"},
+	RuleCNAPS:            {Check: IsCNAPS, Message: "CNAPS code must be 12 digits"},
+}
+
+// CheckRule adds an error for key if value fails the named rule. Unknown rules are ignored.
+func (v *Validator) CheckRule(rule, key, value string) {
+	r, ok := FieldRules[rule]
+	if !ok {
+		return
+	}
+	v.Check(r.Check(value), key, r.Message)
+}
+
+// NormalizeBankCode strips spaces and dashes and upper-cases a bank identifier.
+func NormalizeBankCode(value string) string {
+	return strings.ToUpper(separatorsRgx.ReplaceAllString(value, ""))
+}
+
+// IsIBAN returns true if value has the correct length for its country and passes the mod-97 check.
+func IsIBAN(value string) bool {
+	iban := NormalizeBankCode(value)
+	if !ibanRgx.MatchString(iban) {
+		return false
+	}
+
+	length, ok := ibanCountryLen[iban[:2]]
+	if !ok || len(iban) != length {
+		return false
+	}
+
+	rearranged := iban[4:] + iban[:4]
+	var numeric strings.Builder
+	for _, r := range rearranged {
+		if r >= 'A' && r <= 'Z' {
+			numeric.WriteString(strconv.Itoa(int(r-'A') + 10))
+			continue
+		}
+		numeric.WriteRune(r)
+	}
+
+	n, ok := new(big.Int).SetString(numeric.String(), 10)
+	if !ok {
+		return false
+	}
+
+	return new(big.Int).Mod(n, ninetySeven).Int64() == 1
+}
+
+// IsBIC returns true if value is an 8 or 11 character BIC with a valid ISO country code.
+func IsBIC(value string) bool {
+	bic := NormalizeBankCode(value)
+	if !bicRgx.MatchString(bic) {
+		return false
+	}
+	return IsCountryCode(bic[4:6])
+}
+
+// IsCountryCode returns true if value is an ISO 3166-1 alpha-2 country code.
+func IsCountryCode(value string) bool {
+	return In(strings.ToUpper(value), isoCountryCodes...)
+}
+
+// IsABARoutingNumber returns true if value is a 9 digit ABA routing number with a valid checksum.
+func IsABARoutingNumber(value string) bool {
+	rn := NormalizeBankCode(value)
+	if len(rn) != 9 || !digitsRgx.MatchString(rn) {
+		return false
+	}
+
+	weights := []int{3, 7, 1}
+	sum := 0
+	for i, r := range rn {
+		sum += int(r-'0') * weights[i%3]
+	}
+
+	return sum%10 == 0
+}
+
+// IsUKSortCode returns true if value is a 6 digit UK sort code, with or without dashes.
+func IsUKSortCode(value string) bool {
+	sc := NormalizeBankCode(value)
+	return len(sc) == 6 && digitsRgx.MatchString(sc)
+}
+
+// IsCAInstitutionNumber returns true if value is a 3 digit Canadian institution number.
+func IsCAInstitutionNumber(value string) bool {
+	n := NormalizeBankCode(value)
+	return len(n) == 3 && digitsRgx.MatchString(n)
+}
+
+// IsCATransitNumber returns true if value is a 5 digit Canadian transit number.
+func IsCATransitNumber(value string) bool {
+	n := NormalizeBankCode(value)
+	return len(n) == 5 && digitsRgx.MatchString(n)
+}
+
+// IsLuhn returns true if value is a digit string that passes the Luhn checksum.
+func IsLuhn(value string) bool {
+	if value == "" || !digitsRgx.MatchString(value) {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(value) - 1; i >= 0; i-- {
+		d := int(value[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+
+	return sum%10 == 0
+}
+
+// IsUnionPayCard returns true if value is a 16-19 digit card number in the UnionPay 62 range
+// that passes the Luhn checksum.
+func IsUnionPayCard(value string) bool {
+	card := NormalizeBankCode(value)
+	if !Between(len(card), 16, 19) || !strings.HasPrefix(card, "62") {
+		return false
+	}
+	return IsLuhn(card)
+}
+
+// IsCNAPS returns true if value has the format of a China National Advanced Payment System code:
+// 12 digits. It is a format check only; whether the leading bank code belongs to a bank is not
+// validated, so the code must still be confirmed by the payout partner.
+func IsCNAPS(value string) bool {
+	code := NormalizeBankCode(value)
+	return len(code) == 12 && digitsRgx.MatchString(code)
+}
@@ -0,0 +1,69 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsIBAN(t *testing.T) {
+	require.True(t, IsIBAN("GB82WEST12345698765432"))
+	require.True(t, IsIBAN("de89 3704 0044 0532 0130 00"))
+	require.False(t, IsIBAN("GB82WEST12345698765431"))
+	require.False(t, IsIBAN("GB82WEST1234569876543"))
+	require.False(t, IsIBAN("ZZ82WEST12345698765432"))
+	require.False(t, IsIBAN(""))
+}
+
+func TestIsBIC(t *testing.T) {
+	require.True(t, IsBIC("DEUTDEFF"))
+	require.True(t, IsBIC("DEUTDEFF500"))
+	require.True(t, IsBIC("nwbkgb2l"))
+	require.False(t, IsBIC("DEUTZZFF"))
+	require.False(t, IsBIC("DEUTDEF"))
+	require.False(t, IsBIC("DEUTDEFF50"))
+}
+
+func TestIsABARoutingNumber(t *testing.T) {
+	require.True(t, IsABARoutingNumber("011000015"))
+	require.True(t, IsABARoutingNumber("021000021"))
+	require.False(t, IsABARoutingNumber("021000022"))
+	require.False(t, IsABARoutingNumber("02100002"))
+	require.False(t, IsABARoutingNumber("02100002A"))
+}
+
+func TestIsUKSortCode(t *testing.T) {
+	require.True(t, IsUKSortCode("40-47-84"))
+	require.True(t, IsUKSortCode("404784"))
+	require.False(t, IsUKSortCode("40478"))
+}
+
+func TestCanadianBankNumbers(t *testing.T) {
+	require.True(t, IsCAInstitutionNumber("004"))
+	require.False(t, IsCAInstitutionNumber("04"))
+	require.True(t, IsCATransitNumber("12345"))
+	require.False(t, IsCATransitNumber("1234A"))
+}
+
+func TestIsUnionPayCard(t *testing.T) {
+	require.True(t, IsUnionPayCard("6212345678901232"))
+	require.True(t, IsUnionPayCard("6212 3456 7890 1232"))
+	require.False(t, IsUnionPayCard("6212345678901233"))
+	require.False(t, IsUnionPayCard("4111111111111111"))
+}
+
+func TestIsCNAPS(t *testing.T) {
+	require.True(t, IsCNAPS("102100099996"))
+	require.False(t, IsCNAPS("10210009999"))
+}
+
+func TestValidator_CheckRule(t *testing.T) {
+	v := New()
+	v.CheckRule(RuleIBAN, "iban", "GB82WEST12345698765431")
+	v.CheckRule(RuleBIC, "swift_code", "DEUTDEFF")
+	v.CheckRule("unknown", "other", "")
+
+	require.False(t, v.Valid())
+	require.Len(t, v.Errors, 1)
+	require.Equal(t, "invalid IBAN", v.Errors["iban"])
+}
@@ -11,6 +11,10 @@ import (
 	db "github.com/timchuks/monieverse/internal/db/sqlc"
 )
 
+// MessageNotVerified is the error of a check whose lookup failed, so the value could be neither
+// accepted nor rejected.
+const MessageNotVerified = "could not be verified, try again later"
+
 // EmailExists checks if an email exists.
 func (v *Validator) EmailExists(email string) {
 	_, err := v.store.GetUserByEmail(v.ctx, email)
@@ -224,13 +228,11 @@ func (v *Validator) WalletExists(walletID uuid.UUID) *db.Wallet {
 	return &wallet
 }
 
-// UserRecipientExists checks if a recipient exists
+// UserRecipientExists checks if a recipient exists and the user can send to it, either
+// as its owner or through a business recipient book they have access to
 func (v *Validator) UserRecipientExists(recipientID uuid.UUID, userID uuid.UUID) *db.Recipient {
 
-	recipient, err := v.store.GetUserRecipient(v.ctx, db.GetUserRecipientParams{
-		ID:     recipientID,
-		UserID: userID,
-	})
+	recipient, err := v.store.GetTransferRecipient(v.ctx, recipientID, userID)
 	if err != nil {
 		v.Check(false, "recipient", "recipient does not exist")
 		return nil
@@ -349,6 +351,29 @@ func (v *Validator) BusinessNameAlreadyExists(name string) {
 	v.Check(err != nil, "name", "name already exist")
 }
 
+// BusinessNameExistsForOthers checks if a business name is taken by a business of other users.
+func (v *Validator) BusinessNameExistsForOthers(name string, userID uuid.UUID) {
+	business, err := v.store.GetBusinessByName(v.ctx, name)
+	v.businessNotTakenByOthers(business, err, userID, "name", "name already exist")
+}
+
+// RegistrationNumberExistsForOthers checks if a registration number is taken by a business of
+// other users.
+func (v *Validator) RegistrationNumberExistsForOthers(number string, userID uuid.UUID) {
+	business, err := v.store.GetBusinessByRegistrationNumber(v.ctx, number)
+	v.businessNotTakenByOthers(business, err, userID, "registration_number", "registration number already exist")
+}
+
+// businessNotTakenByOthers checks the result of a business lookup. Only a missing row means the
+// value is free; a failed lookup is reported instead of being taken as available.
+func (v *Validator) businessNotTakenByOthers(business db.Business, err error, userID uuid.UUID, key, message string) {
+	if err != nil {
+		v.Check(errors.Is(err, sql.ErrNoRows), key, MessageNotVerified)
+		return
+	}
+	v.Check(business.CreatedBy == userID, key, message)
+}
+
 func (v *Validator) BusinessNameShouldExists(name string) {
 	business, _ := v.store.GetBusinessByName(v.ctx, name)
 	v.Check(business.Name == name, "name", "business name cannot be changed")
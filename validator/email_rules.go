@@ -0,0 +1,95 @@
+package validator
+
+import "strings"
+
+// disposableEmailDomains are throwaway mailbox providers. Subdomains of these are disposable too.
+var disposableEmailDomains = map[string]bool{
+	"10minutemail.com":       true,
+	"20minutemail.com":       true,
+	"33mail.com":             true,
+	"burnermail.io":          true,
+	"discard.email":          true,
+	"dispostable.com":        true,
+	"emailondeck.com":        true,
+	"fakeinbox.com":          true,
+	"getairmail.com":         true,
+	"getnada.com":            true,
+	"guerrillamail.biz":      true,
+	"guerrillamail.com":      true,
+	"guerrillamail.de":       true,
+	"guerrillamail.info":     true,
+	"guerrillamail.net":      true,
+	"guerrillamail.org":      true,
+	"guerrillamailblock.com": true,
+	"harakirimail.com":       true,
+	"inboxbear.com":          true,
+	"incognitomail.org":      true,
+	"mailcatch.com":          true,
+	"maildrop.cc":            true,
+	"mailinator.com":         true,
+	"mailinator.net":         true,
+	"mailnesia.com":          true,
+	"mintemail.com":          true,
+	"mohmal.com":             true,
+	"moakt.com":              true,
+	"mytemp.email":           true,
+	"sharklasers.com":        true,
+	"spam4.me":               true,
+	"spamgourmet.com":        true,
+	"temp-mail.io":           true,
+	"temp-mail.org":          true,
+	"tempail.com":            true,
+	"tempmail.dev":           true,
+	"tempmail.net":           true,
+	"tempmailo.com":          true,
+	"tempr.email":            true,
+	"throwawaymail.com":      true,
+	"trashmail.com":          true,
+	"trashmail.de":           true,
+	"yopmail.com":            true,
+	"yopmail.fr":             true,
+	"yopmail.net":            true,
+}
+
+// EmailDomain returns the lower-cased domain of an email address, or an empty string.
+func EmailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(email[at+1:]), "."))
+}
+
+// IsDisposableEmail returns true if the email address is at a throwaway mailbox provider.
+func IsDisposableEmail(email string) bool {
+	return emailDomainMatches(email, func(domain string) bool { return disposableEmailDomains[domain] })
+}
+
+// IsEmailAtDomain returns true if the email address is at one of the domains or their subdomains.
+func IsEmailAtDomain(email string, domains ...string) bool {
+	if len(domains) == 0 {
+		return false
+	}
+	set := make(map[string]bool, len(domains))
+	for _, d := range domains {
+		set[strings.ToLower(strings.TrimSpace(d))] = true
+	}
+	return emailDomainMatches(email, func(domain string) bool { return set[domain] })
+}
+
+func emailDomainMatches(email string, match func(domain string) bool) bool {
+	domain := EmailDomain(email)
+	if domain == "" {
+		return false
+	}
+	for {
+		if match(domain) {
+			return true
+		}
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			return false
+		}
+		domain = domain[dot+1:]
+	}
+}
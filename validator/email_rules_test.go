@@ -0,0 +1,20 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsDisposableEmail(t *testing.T) {
+	require.True(t, IsDisposableEmail("lead@mailinator.com"))
+	require.True(t, IsDisposableEmail("lead@Inbox.YOPMAIL.com"))
+	require.False(t, IsDisposableEmail("lead@gmail.com"))
+	require.False(t, IsDisposableEmail("not-an-email"))
+
+	require.True(t, IsEmailAtDomain("lead@mail.example.com", "Example.com"))
+	require.False(t, IsEmailAtDomain("lead@notexample.com", "example.com"))
+	require.False(t, IsEmailAtDomain("lead@example.com"))
+
+	require.Equal(t, "acme.ng", EmailDomain("Ops@ACME.ng"))
+}
@@ -0,0 +1,211 @@
+package validator
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Types of national identity numbers that form fields can ask for.
+const (
+	IDTypeNIN          = "nin"
+	IDTypeBVN          = "bvn"
+	IDTypeGhanaCard    = "ghana_card"
+	IDTypeKENationalID = "ke_national_id"
+	IDTypeKRAPIN       = "kra_pin"
+	IDTypeZANationalID = "za_national_id"
+	IDTypeSSN          = "ssn"
+	IDTypeNINO         = "nino"
+	IDTypeSIN          = "sin"
+)
+
+// E.164 numbers have at most 15 digits; the shortest in use have 8
+const (
+	minPhoneDigits = 8
+	maxPhoneDigits = 15
+)
+
+var (
+	phoneFormattingRgx = regexp.MustCompile(`[\s().\-/]`)
+	ninoRgx            = regexp.MustCompile(`^[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z][0-9]{6}[A-D]$`)
+	ninoInvalidPrefix  = []string{"BG", "GB", "KN", "NK", "NT", "TN", "ZZ"}
+	nationalIDPatterns = make(map[string]*regexp.Regexp)
+
+	ErrInvalidPhoneNumber = errors.New("invalid phone number")
+)
+
+// NationalIDFormat describes a national identity number of a country. Pattern matches the
+// number once normalized and is meant for client side checks; IsNationalID also verifies
+// checksums and embedded dates where the number has them.
+type NationalIDFormat struct {
+	Country string `json:"country"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	Example string `json:"example"`
+	check   func(value string) bool
+}
+
+// NationalIDFormats holds the identity numbers that national_id fields can accept.
+var NationalIDFormats = []NationalIDFormat{
+	{Country: "NG", Type: IDTypeNIN, Name: "National Identification Number", Pattern: `^[0-9]{11}$`, Example: "12345678901"},
+	{Country: "NG", Type: IDTypeBVN, Name: "Bank Verification Number", Pattern: `^22[0-9]{9}$`, Example: "22123456789"},
+	{Country: "GH", Type: IDTypeGhanaCard, Name: "Ghana Card", Pattern: `^GHA[0-9]{10}$`, Example: "GHA-123456789-0"},
+	{Country: "KE", Type: IDTypeKENationalID, Name: "National ID", Pattern: `^[0-9]{7,8}$`, Example: "12345678"},
+	{Country: "KE", Type: IDTypeKRAPIN, Name: "KRA PIN", Pattern: `^[AP][0-9]{9}[A-Z]$`, Example: "A123456789Z"},
+	{Country: "ZA", Type: IDTypeZANationalID, Name: "South African ID Number", Pattern: `^[0-9]{13}$`, Example: "8001015009087", check: isZANationalID},
+	{Country: "US", Type: IDTypeSSN, Name: "Social Security Number", Pattern: `^[0-9]{9}$`, Example: "123-45-6789", check: isSSN},
+	{Country: "GB", Type: IDTypeNINO, Name: "National Insurance Number", Pattern: `^[A-Z]{2}[0-9]{6}[A-D]$`, Example: "AB 12 34 56 C", check: isNINO},
+	{Country: "CA", Type: IDTypeSIN, Name: "Social Insurance Number", Pattern: `^[0-9]{9}$`, Example: "046 454 286", check: IsLuhn},
+}
+
+func init() {
+	for _, format := range NationalIDFormats {
+		nationalIDPatterns[format.Country+"/"+format.Type] = regexp.MustCompile(format.Pattern)
+	}
+}
+
+// NationalIDFormatsFor returns the identity numbers of a country, limited to types when given.
+func NationalIDFormatsFor(country string, types ...string) []NationalIDFormat {
+	country = strings.ToUpper(country)
+	var formats []NationalIDFormat
+	for _, format := range NationalIDFormats {
+		if format.Country == country && (len(types) == 0 || In(format.Type, types...)) {
+			formats = append(formats, format)
+		}
+	}
+	return formats
+}
+
+// NormalizeNationalID strips spaces and dashes and upper-cases an identity number.
+func NormalizeNationalID(value string) string {
+	return NormalizeBankCode(value)
+}
+
+// IsNationalID returns true if value is an identity number of the given type.
+func IsNationalID(country, idType, value string) bool {
+	formats := NationalIDFormatsFor(country, idType)
+	if len(formats) == 0 {
+		return false
+	}
+	return formats[0].Matches(value)
+}
+
+// Matches returns true if value is a number of this format.
+func (f NationalIDFormat) Matches(value string) bool {
+	id := NormalizeNationalID(value)
+	if !nationalIDPatterns[f.Country+"/"+f.Type].MatchString(id) {
+		return false
+	}
+	return f.check == nil || f.check(id)
+}
+
+// isZANationalID checks the date of birth and Luhn check digit of a South African ID number.
+func isZANationalID(id string) bool {
+	if _, err := time.Parse("060102", id[:6]); err != nil {
+		return false
+	}
+	return IsLuhn(id)
+}
+
+// isSSN rejects the area, group and serial numbers that are never issued.
+func isSSN(id string) bool {
+	area, group, serial := id[:3], id[3:5], id[5:]
+	return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+}
+
+func isNINO(id string) bool {
+	return ninoRgx.MatchString(id) && NotIn(id[:2], ninoInvalidPrefix...)
+}
+
+// PhoneNumberPlan is the numbering plan of a country: its calling code and the lengths of its
+// national numbers without the trunk prefix.
+type PhoneNumberPlan struct {
+	Country     string `json:"country"`
+	CallingCode string `json:"calling_code"`
+	Lengths     []int  `json:"lengths"`
+	Example     string `json:"example"`
+}
+
+// PhoneNumberPlans holds the numbering plans phone fields check numbers against. Numbers of
+// other countries only need to be valid E.164 numbers.
+var PhoneNumberPlans = map[string]PhoneNumberPlan{
+	"NG": {Country: "NG", CallingCode: "234", Lengths: []int{10}, Example: "0803 123 4567"},
+	"GH": {Country: "GH", CallingCode: "233", Lengths: []int{9}, Example: "024 123 4567"},
+	"KE": {Country: "KE", CallingCode: "254", Lengths: []int{9}, Example: "0712 345678"},
+	"ZA": {Country: "ZA", CallingCode: "27", Lengths: []int{9}, Example: "082 123 4567"},
+	"CM": {Country: "CM", CallingCode: "237", Lengths: []int{9}, Example: "6 71 23 45 67"},
+	"SN": {Country: "SN", CallingCode: "221", Lengths: []int{9}, Example: "77 123 45 67"},
+	"CI": {Country: "CI", CallingCode: "225", Lengths: []int{10}, Example: "01 23 45 67 89"},
+	"EG": {Country: "EG", CallingCode: "20", Lengths: []int{9, 10}, Example: "0100 123 4567"},
+	"US": {Country: "US", CallingCode: "1", Lengths: []int{10}, Example: "(201) 555-0123"},
+	"CA": {Country: "CA", CallingCode: "1", Lengths: []int{10}, Example: "(506) 234-5678"},
+	"GB": {Country: "GB", CallingCode: "44", Lengths: []int{9, 10}, Example: "07400 123456"},
+	"FR": {Country: "FR", CallingCode: "33", Lengths: []int{9}, Example: "06 12 34 56 78"},
+	"DE": {Country: "DE", CallingCode: "49", Lengths: []int{6, 7, 8, 9, 10, 11}, Example: "01512 3456789"},
+	"AE": {Country: "AE", CallingCode: "971", Lengths: []int{8, 9}, Example: "050 123 4567"},
+	"IN": {Country: "IN", CallingCode: "91", Lengths: []int{10}, Example: "081234 56789"},
+	"CN": {Country: "CN", CallingCode: "86", Lengths: []int{10, 11}, Example: "131 2345 6789"},
+}
+
+// NormalizePhoneNumber formats a phone number as E.164. Numbers written without a + or 00
+// prefix are national numbers of country, with or without their trunk 0. Numbers of a
+// country with a known numbering plan must have one of its lengths.
+func NormalizePhoneNumber(value, country string) (string, error) {
+	value = strings.TrimSpace(value)
+	digits := phoneFormattingRgx.ReplaceAllString(value, "")
+
+	switch {
+	case strings.HasPrefix(digits, "+"):
+		digits = digits[1:]
+	case strings.HasPrefix(digits, "00"):
+		digits = digits[2:]
+	default:
+		plan, ok := PhoneNumberPlans[strings.ToUpper(country)]
+		if !ok {
+			return "", ErrInvalidPhoneNumber
+		}
+		digits = plan.CallingCode + strings.TrimPrefix(digits, "0")
+	}
+
+	if !digitsRgx.MatchString(digits) || !Between(len(digits), minPhoneDigits, maxPhoneDigits) || digits[0] == '0' {
+		return "", ErrInvalidPhoneNumber
+	}
+
+	// Numbers of the requested country, or of any known country when dialled internationally,
+	// must fit its plan
+	plans := phonePlansFor(digits, country)
+	for _, plan := range plans {
+		if In(len(digits)-len(plan.CallingCode), plan.Lengths...) {
+			return "+" + digits, nil
+		}
+	}
+	if len(plans) > 0 {
+		return "", ErrInvalidPhoneNumber
+	}
+	return "+" + digits, nil
+}
+
+// PhoneNumberCountries returns the countries with a known plan whose calling code starts an
+// E.164 number.
+func PhoneNumberCountries(e164 string) []string {
+	var countries []string
+	for _, plan := range phonePlansFor(strings.TrimPrefix(e164, "+"), "") {
+		countries = append(countries, plan.Country)
+	}
+	return countries
+}
+
+func phonePlansFor(digits, country string) []PhoneNumberPlan {
+	var plans []PhoneNumberPlan
+	for code, plan := range PhoneNumberPlans {
+		if country != "" && code != strings.ToUpper(country) {
+			continue
+		}
+		if strings.HasPrefix(digits, plan.CallingCode) {
+			plans = append(plans, plan)
+		}
+	}
+	return plans
+}
@@ -0,0 +1,57 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsNationalID(t *testing.T) {
+	require.True(t, IsNationalID("NG", IDTypeNIN, "123 4567 8901"))
+	require.False(t, IsNationalID("NG", IDTypeNIN, "1234567890"))
+	require.True(t, IsNationalID("ng", IDTypeBVN, "22123456789"))
+	require.False(t, IsNationalID("NG", IDTypeBVN, "12345678901"))
+	require.True(t, IsNationalID("GH", IDTypeGhanaCard, "gha-123456789-0"))
+	require.True(t, IsNationalID("KE", IDTypeKRAPIN, "A123456789Z"))
+	require.True(t, IsNationalID("ZA", IDTypeZANationalID, "8001015009087"))
+	require.False(t, IsNationalID("ZA", IDTypeZANationalID, "8001015009088"))
+	require.False(t, IsNationalID("ZA", IDTypeZANationalID, "8013015009080"))
+	require.True(t, IsNationalID("US", IDTypeSSN, "123-45-6789"))
+	require.False(t, IsNationalID("US", IDTypeSSN, "666-45-6789"))
+	require.True(t, IsNationalID("GB", IDTypeNINO, "AB 12 34 56 C"))
+	require.False(t, IsNationalID("GB", IDTypeNINO, "GB 12 34 56 C"))
+	require.True(t, IsNationalID("CA", IDTypeSIN, "046 454 286"))
+	require.False(t, IsNationalID("NG", IDTypeSSN, "123-45-6789"))
+
+	require.Len(t, NationalIDFormatsFor("NG"), 2)
+	require.Len(t, NationalIDFormatsFor("NG", IDTypeBVN), 1)
+	require.Empty(t, NationalIDFormatsFor("FR"))
+}
+
+func TestNormalizePhoneNumber(t *testing.T) {
+	for value, expected := range map[string]string{
+		"0803 123 4567":     "+2348031234567",
+		"803-123-4567":      "+2348031234567",
+		"+234 803 123 4567": "+2348031234567",
+		"00447400123456":    "+447400123456",
+		"+1 (201) 555-0123": "+12015550123",
+		"+49 1512 3456789":  "+4915123456789",
+		"+212 612 345678":   "+212612345678", // no known plan
+	} {
+		normalized, err := NormalizePhoneNumber(value, "NG")
+		require.NoError(t, err, value)
+		require.Equal(t, expected, normalized, value)
+	}
+
+	for _, value := range []string{"0803 123 456", "+234 803 123 45678", "call me", "12345", "0803 123 4567 ext 2"} {
+		_, err := NormalizePhoneNumber(value, "NG")
+		require.ErrorIs(t, err, ErrInvalidPhoneNumber, value)
+	}
+
+	// National numbers need a country with a known plan
+	_, err := NormalizePhoneNumber("0612 345678", "MA")
+	require.ErrorIs(t, err, ErrInvalidPhoneNumber)
+
+	require.ElementsMatch(t, []string{"US", "CA"}, PhoneNumberCountries("+12015550123"))
+	require.Equal(t, []string{"NG"}, PhoneNumberCountries("+2348031234567"))
+}
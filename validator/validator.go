@@ -2,16 +2,39 @@ package validator
 
 import (
 	"context"
+	"fmt"
 
 	db "github.com/timchuks/monieverse/internal/db/sqlc"
 )
 
 type ErrorFields map[string]string
 
+// Message is a validation message as its catalog key, the English text with fmt verbs, and
+// the arguments it is rendered with, so it can be translated without parsing the text.
+type Message struct {
+	Key  string
+	Args []interface{}
+}
+
+// Msg returns the message of a catalog key rendered with args
+func Msg(key string, args ...interface{}) Message {
+	return Message{Key: key, Args: args}
+}
+
+// String renders the message in English
+func (m Message) String() string {
+	if len(m.Args) == 0 {
+		return m.Key
+	}
+	return fmt.Sprintf(m.Key, m.Args...)
+}
+
 // ValidationError Custom error type for better error handling
 type ValidationError struct {
 	Fields  map[string]string
 	Message string
+	// Messages holds the keys and arguments of the field errors added as messages
+	Messages map[string]Message
 }
 
 func (e *ValidationError) Error() string {
@@ -19,9 +42,10 @@ func (e *ValidationError) Error() string {
 }
 
 type Validator struct {
-	Errors ErrorFields
-	store  db.Store
-	ctx    context.Context
+	Errors   ErrorFields
+	Messages map[string]Message
+	store    db.Store
+	ctx      context.Context
 }
 
 // New returns a new Validator instance.
@@ -64,3 +88,34 @@ func (v *Validator) Check(ok bool, key, message string) {
 		v.AddError(key, message)
 	}
 }
+
+// AddMessage adds an error message with its key and arguments, so long as no entry already
+// exists for the given key.
+func (v *Validator) AddMessage(key string, message Message) {
+	if _, exists := v.Errors[key]; exists {
+		return
+	}
+	if v.Messages == nil {
+		v.Messages = make(map[string]Message)
+	}
+	v.Errors[key] = message.String()
+	v.Messages[key] = message
+}
+
+// CheckMessage adds an error message with its key and arguments only if a validation check
+// is not 'ok'.
+func (v *Validator) CheckMessage(ok bool, key string, message Message) {
+	if !ok {
+		v.AddMessage(key, message)
+	}
+}
+
+// ValidationError returns a validation error with the errors of the validator and the keys
+// of their messages.
+func (v *Validator) ValidationError(message string) *ValidationError {
+	return &ValidationError{
+		Fields:   v.Errors,
+		Message:  message,
+		Messages: v.Messages,
+	}
+}
@@ -46,3 +46,16 @@ func TestValidator_Valid(t *testing.T) {
 		t.Error("validator.Valid() should return false")
 	}
 }
+
+func TestValidator_AddMessage(t *testing.T) {
+	validator := New()
+	validator.AddMessage("name", Msg("must be at least %d characters", 3))
+	validator.AddMessage("name", Msg("must be at most %d characters", 10))
+	validator.AddError("email", "invalid email format")
+
+	require.Equal(t, ErrorFields{"name": "must be at least 3 characters", "email": "invalid email format"}, validator.Errors)
+
+	err := validator.ValidationError("validation failed")
+	require.Equal(t, "validation failed", err.Message)
+	require.Equal(t, map[string]Message{"name": Msg("must be at least %d characters", 3)}, err.Messages)
+}